@@ -0,0 +1,150 @@
+package main
+
+import (
+    "bytes"
+    "context"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "io"
+    "log"
+    "net/http"
+    "net/url"
+    "strings"
+    "time"
+
+    "github.com/YumeNoTenshi/platypus/pkg/hostpower"
+)
+
+// governorDirective - указание сервера по режиму CPU (GET /governor/{id})
+type governorDirective struct {
+    hostpower.GovernorDirective
+    Reason   string    `json:"reason"`
+    IssuedAt time.Time `json:"issued_at"`
+}
+
+// governorReport - результат применения указания (POST /governor/{id}/report)
+type governorReport struct {
+    Governor        string    `json:"governor"`
+    MaxFrequencyMHz int       `json:"max_frequency_mhz,omitempty"`
+    IssuedAt        time.Time `json:"issued_at"`
+    Applied         bool      `json:"applied"`
+    Error           string    `json:"error,omitempty"`
+}
+
+// governorClient опрашивает сервер об указаниях по режиму CPU хоста и
+// сообщает результат их применения
+type governorClient struct {
+    baseURL string
+    apiKey  string
+    client  *http.Client
+}
+
+func newGovernorClient(serverURL, apiKey, serverID string) *governorClient {
+    return &governorClient{
+        baseURL: strings.TrimRight(serverURL, "/") + "/api/v1/governor/" + url.PathEscape(serverID),
+        apiKey:  apiKey,
+        client:  &http.Client{Timeout: 15 * time.Second},
+    }
+}
+
+// runGovernor раз в интервал запрашивает указание и применяет его, если оно
+// изменилось или прошлое применение не удалось. Результат каждого
+// применения отправляется серверу. Ошибки не останавливают агента; на хосте
+// без управления частотой CPU опрос прекращается после первого отчета.
+func runGovernor(ctx context.Context, client *governorClient, interval time.Duration) error {
+    var applied *hostpower.GovernorDirective
+    ticker := time.NewTicker(interval)
+    defer ticker.Stop()
+
+    for {
+        directive, err := client.directive(ctx)
+        switch {
+        case ctx.Err() != nil:
+            return ctx.Err()
+        case err != nil:
+            log.Printf("Не удалось получить указание по режиму CPU: %v", err)
+        case applied == nil || *applied != directive.GovernorDirective:
+            report := governorReport{
+                Governor:        directive.Governor,
+                MaxFrequencyMHz: directive.MaxFrequencyMHz,
+                IssuedAt:        directive.IssuedAt,
+            }
+            applyErr := directive.Apply()
+            if applyErr != nil {
+                log.Printf("Не удалось применить режим CPU %s: %v", directive.Governor, applyErr)
+                report.Error = applyErr.Error()
+                applied = nil
+            } else {
+                log.Printf("Режим CPU %s применен: %s", directive.Governor, directive.Reason)
+                report.Applied = true
+                applied = &directive.GovernorDirective
+            }
+            if err := client.report(ctx, report); err != nil && ctx.Err() == nil {
+                log.Printf("Не удалось отправить результат применения режима CPU: %v", err)
+            }
+            if errors.Is(applyErr, hostpower.ErrUnsupported) {
+                return applyErr
+            }
+        }
+
+        select {
+        case <-ctx.Done():
+            return ctx.Err()
+        case <-ticker.C:
+        }
+    }
+}
+
+func (c *governorClient) directive(ctx context.Context) (governorDirective, error) {
+    var envelope struct {
+        Data governorDirective `json:"data"`
+    }
+    if err := c.do(ctx, http.MethodGet, "", nil, &envelope); err != nil {
+        return governorDirective{}, err
+    }
+    if envelope.Data.Governor == "" {
+        return governorDirective{}, fmt.Errorf("server returned a directive without a governor")
+    }
+    return envelope.Data, nil
+}
+
+func (c *governorClient) report(ctx context.Context, report governorReport) error {
+    return c.do(ctx, http.MethodPost, "/report", report, nil)
+}
+
+func (c *governorClient) do(ctx context.Context, method, path string, in, out interface{}) error {
+    var body io.Reader
+    if in != nil {
+        data, err := json.Marshal(in)
+        if err != nil {
+            return err
+        }
+        body = bytes.NewReader(data)
+    }
+    req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, body)
+    if err != nil {
+        return err
+    }
+    if in != nil {
+        req.Header.Set("Content-Type", "application/json")
+    }
+    req.Header.Set("User-Agent", "platypus-agent")
+    if c.apiKey != "" {
+        req.Header.Set("X-API-Key", c.apiKey)
+    }
+
+    resp, err := c.client.Do(req)
+    if err != nil {
+        return err
+    }
+    defer resp.Body.Close()
+    if resp.StatusCode/100 != 2 {
+        message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+        return fmt.Errorf("server responded with status %d: %s", resp.StatusCode, bytes.TrimSpace(message))
+    }
+    if out == nil {
+        return nil
+    }
+    return json.NewDecoder(resp.Body).Decode(out)
+}
@@ -0,0 +1,214 @@
+// Команда agent - агент Platypus на хосте. Снимает измеренную мощность (RAPL,
+// измеритель ACPI) и загрузку хоста и его контейнеров и отправляет точки
+// серверу по HTTP API или gRPC, чтобы сервер не оценивал мощность по модели.
+// С PLATYPUS_AGENT_GOVERNOR агент также применяет указания сервера по режиму
+// CPU (governor и ограничение частоты) и сообщает результат.
+package main
+
+import (
+    "context"
+    "errors"
+    "log"
+    "os"
+    "os/signal"
+    "strconv"
+    "syscall"
+    "time"
+
+    "github.com/YumeNoTenshi/platypus/internal/models"
+    "github.com/YumeNoTenshi/platypus/pkg/edgeagent"
+    "github.com/YumeNoTenshi/platypus/pkg/hostpower"
+)
+
+type config struct {
+    serverURL        string        // PLATYPUS_SERVER_URL, например https://platypus.example.com
+    grpcTarget       string        // PLATYPUS_GRPC_TARGET, например platypus:9090; задан - отправка по gRPC
+    grpcTLS          bool          // PLATYPUS_GRPC_TLS
+    apiKey           string        // PLATYPUS_API_KEY
+    serverID         string        // PLATYPUS_SERVER_ID; по умолчанию имя хоста
+    interval         time.Duration // PLATYPUS_AGENT_INTERVAL
+    containers       bool          // PLATYPUS_AGENT_CONTAINERS: загрузка контейнеров по cgroup
+    cgroupRoot       string        // PLATYPUS_CGROUP_ROOT
+    spoolDir         string        // PLATYPUS_AGENT_SPOOL: задан - store-and-forward через спул на диске
+    governor         bool          // PLATYPUS_AGENT_GOVERNOR: применять указания сервера по режиму CPU
+    governorInterval time.Duration // PLATYPUS_AGENT_GOVERNOR_INTERVAL
+}
+
+func configFromEnv() (config, error) {
+    c := config{
+        serverURL:        os.Getenv("PLATYPUS_SERVER_URL"),
+        grpcTarget:       os.Getenv("PLATYPUS_GRPC_TARGET"),
+        apiKey:           os.Getenv("PLATYPUS_API_KEY"),
+        serverID:         os.Getenv("PLATYPUS_SERVER_ID"),
+        interval:         30 * time.Second,
+        cgroupRoot:       "/sys/fs/cgroup",
+        spoolDir:         os.Getenv("PLATYPUS_AGENT_SPOOL"),
+        governorInterval: time.Minute,
+    }
+    if c.serverURL == "" && c.grpcTarget == "" {
+        return c, errors.New("PLATYPUS_SERVER_URL or PLATYPUS_GRPC_TARGET is required")
+    }
+    if c.spoolDir != "" && c.serverURL == "" {
+        return c, errors.New("PLATYPUS_AGENT_SPOOL requires PLATYPUS_SERVER_URL")
+    }
+    if c.serverID == "" {
+        hostname, err := os.Hostname()
+        if err != nil {
+            return c, err
+        }
+        c.serverID = hostname
+    }
+    if value := os.Getenv("PLATYPUS_AGENT_INTERVAL"); value != "" {
+        interval, err := time.ParseDuration(value)
+        if err != nil || interval < time.Second {
+            return c, errors.New("PLATYPUS_AGENT_INTERVAL must be a duration of at least 1s")
+        }
+        c.interval = interval
+    }
+    if value := os.Getenv("PLATYPUS_CGROUP_ROOT"); value != "" {
+        c.cgroupRoot = value
+    }
+    c.containers, _ = strconv.ParseBool(os.Getenv("PLATYPUS_AGENT_CONTAINERS"))
+    c.grpcTLS, _ = strconv.ParseBool(os.Getenv("PLATYPUS_GRPC_TLS"))
+    c.governor, _ = strconv.ParseBool(os.Getenv("PLATYPUS_AGENT_GOVERNOR"))
+    if c.governor && c.serverURL == "" {
+        return c, errors.New("PLATYPUS_AGENT_GOVERNOR requires PLATYPUS_SERVER_URL")
+    }
+    if value := os.Getenv("PLATYPUS_AGENT_GOVERNOR_INTERVAL"); value != "" {
+        interval, err := time.ParseDuration(value)
+        if err != nil || interval < time.Second {
+            return c, errors.New("PLATYPUS_AGENT_GOVERNOR_INTERVAL must be a duration of at least 1s")
+        }
+        c.governorInterval = interval
+    }
+    return c, nil
+}
+
+func main() {
+    cfg, err := configFromEnv()
+    if err != nil {
+        log.Fatalf("Некорректная настройка агента: %v", err)
+    }
+
+    sampler, err := hostpower.NewSampler()
+    if err != nil {
+        log.Fatalf("Не удалось инициализировать сбор показаний: %v", err)
+    }
+    defer sampler.Close()
+
+    ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+    defer stop()
+
+    // Указания по режиму CPU опрашиваются по HTTP API при любом способе отправки точек
+    if cfg.governor {
+        log.Printf("Агент %s: указания по режиму CPU каждые %s", cfg.serverID, cfg.governorInterval)
+        go runGovernor(ctx, newGovernorClient(cfg.serverURL, cfg.apiKey, cfg.serverID), cfg.governorInterval)
+    }
+
+    // На периферийных устройствах со связью урывками точки копятся на диске
+    if cfg.spoolDir != "" {
+        if cfg.containers {
+            log.Printf("Загрузка контейнеров в режиме спула не отправляется")
+        }
+        agent, err := edgeagent.New(edgeagent.Config{
+            ServerURL:      cfg.serverURL,
+            ServerID:       cfg.serverID,
+            APIKey:         cfg.apiKey,
+            Dir:            cfg.spoolDir,
+            SampleInterval: cfg.interval,
+        }, sampler)
+        if err != nil {
+            log.Fatalf("Ошибка настройки спула: %v", err)
+        }
+        log.Printf("Агент %s: спул %s, выгрузка на %s", cfg.serverID, cfg.spoolDir, cfg.serverURL)
+        if err := agent.Run(ctx); err != nil && !errors.Is(err, context.Canceled) {
+            log.Fatal(err)
+        }
+        return
+    }
+
+    var sender shipper
+    if cfg.grpcTarget != "" {
+        sender, err = newGRPCShipper(cfg.grpcTarget, cfg.grpcTLS, cfg.apiKey)
+        if err != nil {
+            log.Fatalf("Ошибка подключения по gRPC: %v", err)
+        }
+        log.Printf("Агент %s: отправка по gRPC на %s каждые %s", cfg.serverID, cfg.grpcTarget, cfg.interval)
+    } else {
+        sender = newHTTPShipper(cfg.serverURL, cfg.apiKey)
+        log.Printf("Агент %s: отправка на %s каждые %s", cfg.serverID, cfg.serverURL, cfg.interval)
+    }
+    defer sender.Close()
+
+    var containers hostpower.ContainerSampler
+    if cfg.containers {
+        containers, err = hostpower.NewContainerSampler(cfg.cgroupRoot)
+        if err != nil {
+            log.Fatalf("Не удалось инициализировать сбор по cgroup: %v", err)
+        }
+    }
+
+    if err := run(ctx, cfg, sampler, containers, sender); err != nil && !errors.Is(err, context.Canceled) {
+        log.Fatal(err)
+    }
+}
+
+// run снимает показания раз в интервал и отправляет их. Неудачная отправка
+// не останавливает агента: точка теряется, следующая уйдет по расписанию.
+func run(ctx context.Context, cfg config, sampler hostpower.Sampler, containers hostpower.ContainerSampler, sender shipper) error {
+    reported, reportedSource := false, ""
+    for {
+        sample, err := sampler.Sample(ctx, cfg.interval)
+        if ctx.Err() != nil {
+            return ctx.Err()
+        }
+        if err != nil {
+            log.Printf("Не удалось снять показания: %v", err)
+            select {
+            case <-ctx.Done():
+                return ctx.Err()
+            case <-time.After(cfg.interval):
+            }
+            continue
+        }
+        if !reported || sample.PowerSource != reportedSource {
+            reported, reportedSource = true, sample.PowerSource
+            if reportedSource == "" {
+                log.Printf("Мощность хоста не измеряется; ее оценит модель на сервере")
+            } else {
+                log.Printf("Источник мощности: %s", reportedSource)
+            }
+        }
+
+        host := models.MetricData{
+            ServerID:      cfg.serverID,
+            Timestamp:     sample.Timestamp,
+            PowerUsage:    sample.PowerUsage,
+            CPUUsage:      sample.CPUUsage,
+            MemoryUsage:   sample.MemoryUsage,
+            GPUUsage:      sample.GPUUsage,
+            GPUPowerUsage: sample.GPUPowerUsage,
+        }
+
+        var points []models.MetricData
+        if containers != nil {
+            stats, err := containers.Sample(time.Now())
+            if err != nil {
+                log.Printf("Не удалось прочитать cgroup: %v", err)
+            }
+            for _, stat := range stats {
+                points = append(points, models.MetricData{
+                    ServerID:    cfg.serverID,
+                    ContainerID: stat.ContainerID,
+                    Timestamp:   sample.Timestamp,
+                    CPUUsage:    stat.CPUUsage,
+                    MemoryUsage: stat.MemoryUsage,
+                })
+            }
+        }
+
+        if err := sender.Ship(ctx, host, points); err != nil && ctx.Err() == nil {
+            log.Printf("Не удалось отправить показания: %v", err)
+        }
+    }
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+    "bytes"
+    "context"
+    "crypto/tls"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "io"
+    "net/http"
+    "net/url"
+    "strings"
+    "time"
+
+    "google.golang.org/grpc"
+    "google.golang.org/grpc/credentials"
+    "google.golang.org/grpc/credentials/insecure"
+    "google.golang.org/grpc/metadata"
+
+    "github.com/YumeNoTenshi/platypus/internal/grpc/ingestpb"
+    "github.com/YumeNoTenshi/platypus/internal/models"
+)
+
+// shipper отправляет точку хоста и точки его контейнеров
+type shipper interface {
+    Ship(ctx context.Context, host models.MetricData, containers []models.MetricData) error
+    Close() error
+}
+
+// httpShipper отправляет точки в HTTP API: хост - POST /metrics, контейнеры -
+// POST /containers/{id}/metrics
+type httpShipper struct {
+    baseURL string
+    apiKey  string
+    client  *http.Client
+}
+
+func newHTTPShipper(serverURL, apiKey string) *httpShipper {
+    return &httpShipper{
+        baseURL: strings.TrimRight(serverURL, "/") + "/api/v1",
+        apiKey:  apiKey,
+        client:  &http.Client{Timeout: 15 * time.Second},
+    }
+}
+
+func (s *httpShipper) Ship(ctx context.Context, host models.MetricData, containers []models.MetricData) error {
+    if err := s.post(ctx, "/metrics", host); err != nil {
+        return err
+    }
+    var failures []error
+    for _, point := range containers {
+        if err := s.post(ctx, "/containers/"+url.PathEscape(point.ContainerID)+"/metrics", point); err != nil {
+            failures = append(failures, fmt.Errorf("container %s: %w", point.ContainerID, err))
+        }
+    }
+    return errors.Join(failures...)
+}
+
+func (s *httpShipper) post(ctx context.Context, path string, point models.MetricData) error {
+    body, err := json.Marshal(point)
+    if err != nil {
+        return err
+    }
+    req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.baseURL+path, bytes.NewReader(body))
+    if err != nil {
+        return err
+    }
+    req.Header.Set("Content-Type", "application/json")
+    req.Header.Set("User-Agent", "platypus-agent")
+    if s.apiKey != "" {
+        req.Header.Set("X-API-Key", s.apiKey)
+    }
+
+    resp, err := s.client.Do(req)
+    if err != nil {
+        return err
+    }
+    defer resp.Body.Close()
+    if resp.StatusCode/100 != 2 {
+        message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+        return fmt.Errorf("server responded with status %d: %s", resp.StatusCode, bytes.TrimSpace(message))
+    }
+    return nil
+}
+
+func (s *httpShipper) Close() error {
+    return nil
+}
+
+// grpcShipper отправляет точки хоста и контейнеров одним пакетом PushMetrics
+type grpcShipper struct {
+    conn   *grpc.ClientConn
+    client ingestpb.MetricIngestClient
+    apiKey string
+}
+
+func newGRPCShipper(target string, useTLS bool, apiKey string) (*grpcShipper, error) {
+    creds := insecure.NewCredentials()
+    if useTLS {
+        creds = credentials.NewTLS(&tls.Config{MinVersion: tls.VersionTLS12})
+    }
+    conn, err := grpc.NewClient(target, grpc.WithTransportCredentials(creds))
+    if err != nil {
+        return nil, err
+    }
+    return &grpcShipper{conn: conn, client: ingestpb.NewMetricIngestClient(conn), apiKey: apiKey}, nil
+}
+
+func (s *grpcShipper) Ship(ctx context.Context, host models.MetricData, containers []models.MetricData) error {
+    if s.apiKey != "" {
+        ctx = metadata.AppendToOutgoingContext(ctx, "x-api-key", s.apiKey)
+    }
+    ctx, cancel := context.WithTimeout(ctx, 15*time.Second)
+    defer cancel()
+
+    batch := &ingestpb.MetricBatch{ServerId: host.ServerID}
+    for _, point := range append([]models.MetricData{host}, containers...) {
+        batch.Points = append(batch.Points, &ingestpb.MetricPoint{
+            Timestamp:     point.Timestamp,
+            ContainerId:   point.ContainerID,
+            PowerUsage:    point.PowerUsage,
+            CpuUsage:      point.CPUUsage,
+            MemoryUsage:   point.MemoryUsage,
+            GpuUsage:      point.GPUUsage,
+            GpuPowerUsage: point.GPUPowerUsage,
+        })
+    }
+
+    response, err := s.client.PushMetrics(ctx, batch)
+    if err != nil {
+        return err
+    }
+    if rejected := response.GetRejected(); len(rejected) > 0 {
+        return fmt.Errorf("server rejected %d of %d points: %s", len(rejected), len(batch.Points), rejected[0].GetMessage())
+    }
+    return nil
+}
+
+func (s *grpcShipper) Close() error {
+    return s.conn.Close()
+}
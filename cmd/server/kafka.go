@@ -0,0 +1,59 @@
+//go:build kafka
+
+package main
+
+// Клиент Kafka для metrics.KafkaIngester подключается только в сборке
+// с тегом kafka, чтобы основной бинарник не зависел от него:
+//
+//	go get github.com/segmentio/kafka-go && go build -tags kafka ./cmd/server
+import (
+    "context"
+
+    "github.com/segmentio/kafka-go"
+
+    "github.com/YumeNoTenshi/platypus/internal/metrics"
+)
+
+func init() {
+    metrics.NewKafkaReader = newKafkaReader
+}
+
+type kafkaReader struct {
+    reader *kafka.Reader
+}
+
+func newKafkaReader(config metrics.KafkaConfig) (metrics.KafkaReader, error) {
+    return &kafkaReader{reader: kafka.NewReader(kafka.ReaderConfig{
+        Brokers:  config.Brokers,
+        Topic:    config.Topic,
+        GroupID:  config.GroupID,
+        MinBytes: 1,
+        MaxBytes: 10 << 20,
+    })}, nil
+}
+
+func (r *kafkaReader) FetchMessage(ctx context.Context) (metrics.KafkaMessage, error) {
+    message, err := r.reader.FetchMessage(ctx)
+    if err != nil {
+        return metrics.KafkaMessage{}, err
+    }
+    return metrics.KafkaMessage{
+        Topic:     message.Topic,
+        Partition: message.Partition,
+        Offset:    message.Offset,
+        Key:       message.Key,
+        Value:     message.Value,
+    }, nil
+}
+
+func (r *kafkaReader) CommitMessages(ctx context.Context, messages ...metrics.KafkaMessage) error {
+    committed := make([]kafka.Message, len(messages))
+    for i, message := range messages {
+        committed[i] = kafka.Message{Topic: message.Topic, Partition: message.Partition, Offset: message.Offset}
+    }
+    return r.reader.CommitMessages(ctx, committed...)
+}
+
+func (r *kafkaReader) Close() error {
+    return r.reader.Close()
+}
@@ -4,40 +4,363 @@ import (
     "context"
     "log"
     "net/http"
+    "os"
+    "os/signal"
+    "strings"
+    "syscall"
     "time"
     
+    "github.com/YumeNoTenshi/platypus/internal/airgap"
+    "github.com/YumeNoTenshi/platypus/internal/alerting"
+    "github.com/YumeNoTenshi/platypus/internal/budgets"
+    "github.com/YumeNoTenshi/platypus/internal/calendar"
+    "github.com/YumeNoTenshi/platypus/internal/errtrack"
+    "github.com/YumeNoTenshi/platypus/internal/annotations"
     "github.com/YumeNoTenshi/platypus/internal/api"
     "github.com/YumeNoTenshi/platypus/internal/metrics"
     "github.com/YumeNoTenshi/platypus/internal/scaling"
     "github.com/YumeNoTenshi/platypus/internal/migration"
+    "github.com/YumeNoTenshi/platypus/internal/models"
+    "github.com/YumeNoTenshi/platypus/internal/objectives"
+    "github.com/YumeNoTenshi/platypus/internal/peakhours"
+    "github.com/YumeNoTenshi/platypus/internal/preflight"
+    "github.com/YumeNoTenshi/platypus/internal/recommendations"
+    "github.com/YumeNoTenshi/platypus/internal/remotewrite"
+    "github.com/YumeNoTenshi/platypus/internal/replication"
+    "github.com/YumeNoTenshi/platypus/internal/reports"
+    "github.com/YumeNoTenshi/platypus/internal/scheduler"
+    "github.com/YumeNoTenshi/platypus/internal/scrape"
+    "github.com/YumeNoTenshi/platypus/internal/statsd"
+    "github.com/YumeNoTenshi/platypus/internal/supervisor"
+    "github.com/YumeNoTenshi/platypus/pkg/carbon"
     "github.com/YumeNoTenshi/platypus/pkg/cloud"
     "github.com/YumeNoTenshi/platypus/pkg/ml"
+    "github.com/YumeNoTenshi/platypus/pkg/powermodel"
     "github.com/YumeNoTenshi/platypus/internal/ecotags"
+    "github.com/YumeNoTenshi/platypus/internal/ecoscore"
+    "github.com/YumeNoTenshi/platypus/internal/encryption"
+    "github.com/YumeNoTenshi/platypus/internal/energy"
+    "github.com/YumeNoTenshi/platypus/internal/federation"
+    "github.com/YumeNoTenshi/platypus/internal/edge"
+    "github.com/YumeNoTenshi/platypus/internal/governor"
+    grpcingest "github.com/YumeNoTenshi/platypus/internal/grpc"
+    "github.com/YumeNoTenshi/platypus/internal/groups"
+    "github.com/YumeNoTenshi/platypus/internal/imagescan"
+    "github.com/YumeNoTenshi/platypus/internal/incidents"
+    "github.com/YumeNoTenshi/platypus/internal/insights"
+    "github.com/YumeNoTenshi/platypus/internal/inventory"
+    "github.com/YumeNoTenshi/platypus/internal/mqtt"
 )
 
+const listenAddr = ":8080"
+
 func main() {
+    if len(os.Args) > 1 && os.Args[1] == "validate" {
+        os.Exit(runValidate(os.Args[2:]))
+    }
+    if len(os.Args) > 1 && os.Args[1] == "metadata" {
+        os.Exit(runMetadata(os.Args[2:]))
+    }
+
+    // Автономный режим: без исходящих соединений, инвентарь и данные об углероде из файлов
+    airgapConfig := airgapConfigFromEnv()
+
+    carbonDataset := carbon.Bundled()
+    if airgapConfig.CarbonDatasetPath != "" {
+        dataset, err := carbon.LoadDataset(airgapConfig.CarbonDatasetPath)
+        if err != nil {
+            log.Fatalf("Не удалось загрузить данные об углеродной интенсивности: %v", err)
+        }
+        carbonDataset = dataset
+    }
+
+    // Модели оценки мощности для провайдеров, не отдающих измеренное потребление
+    if path := os.Getenv("PLATYPUS_POWER_MODELS"); path != "" {
+        registry, err := powermodel.Load(path)
+        if err != nil {
+            log.Fatalf("Не удалось загрузить модели энергопотребления: %v", err)
+        }
+        cloud.SetPowerModels(registry)
+    }
+
+    provider, providerName, err := newProvider(airgapConfig)
+    if err != nil {
+        log.Fatalf("Не удалось открыть файл инвентаря: %v", err)
+    }
+
+    // Все вызовы провайдера идут через дедлайны, повторы и предохранители
+    resilientProvider := cloud.NewResilientProvider(providerName, provider, cloud.DefaultResilienceConfig())
+    provider = resilientProvider
+
+    // Новые провайдеры подключаются в режиме наблюдения: их серверы
+    // собираются и оцениваются, но автоматика их не трогает, пока оператор
+    // не снимет флаг (PUT /api/v1/providers/{name}/mode)
+    rollout := cloud.NewRollout(strings.Split(os.Getenv("PLATYPUS_OBSERVE_ONLY_PROVIDERS"), ",")...)
+
     // Инициализация коллектора метрик
     collectorConfig := metrics.CollectorConfig{
         RetentionPeriod:    168 * time.Hour,
         CollectionInterval: time.Minute,
         BatchSize:         100,
         BufferSize:        1000,
+        PointsPerServer:   10080,
+        Filter: metrics.FilterConfig{
+            SmoothingFactor:    0.3,
+            ExpectedInterval:   time.Minute,
+            MaxGapIntervals:    5,
+            TreatZeroAsMissing: true,
+        },
+    }
+    // Метки точек, экспортируемые в Prometheus: PLATYPUS_METRIC_LABELS=team,environment
+    if value := os.Getenv("PLATYPUS_METRIC_LABELS"); value != "" {
+        collectorConfig.MetricLabels, err = metrics.ParseMetricLabels(value)
+        if err != nil {
+            log.Fatalf("Некорректный список меток PLATYPUS_METRIC_LABELS: %v", err)
+        }
+    }
+    // Корзины гистограмм потребления и загрузки CPU: PLATYPUS_POWER_BUCKETS=50,100,200,400,800
+    if value := os.Getenv("PLATYPUS_POWER_BUCKETS"); value != "" {
+        collectorConfig.PowerBuckets, err = metrics.ParseBuckets(value)
+        if err != nil {
+            log.Fatalf("Некорректный PLATYPUS_POWER_BUCKETS: %v", err)
+        }
+    }
+    if value := os.Getenv("PLATYPUS_CPU_BUCKETS"); value != "" {
+        collectorConfig.CPUBuckets, err = metrics.ParseBuckets(value)
+        if err != nil {
+            log.Fatalf("Некорректный PLATYPUS_CPU_BUCKETS: %v", err)
+        }
+    }
+    // Сроки хранения отдельных метрик: PLATYPUS_METRIC_RETENTION=carbon_footprint=8760h,cpu_usage=72h
+    if value := os.Getenv("PLATYPUS_METRIC_RETENTION"); value != "" {
+        collectorConfig.MetricRetention, err = metrics.ParseMetricRetention(value)
+        if err != nil {
+            log.Fatalf("Некорректный PLATYPUS_METRIC_RETENTION: %v", err)
+        }
+    }
+
+    // Внешнее хранилище метрик для больших парков: PLATYPUS_METRICS_STORE=postgres|timescale|influxdb
+    store, storeName, err := newMetricsStore(collectorConfig.LongestRetention())
+    if err != nil {
+        log.Fatalf("Ошибка подключения к хранилищу метрик: %v", err)
+    }
+    collectorConfig.Store = store
+
+    // Шифрование точек на диске (журнал, вытеснение, снимки): ключи берутся
+    // из PLATYPUS_ENCRYPTION_KEYS или файла секрета PLATYPUS_ENCRYPTION_KEYS_FILE
+    keyring, err := loadEncryptionKeys()
+    if err != nil {
+        log.Fatalf("Не удалось загрузить ключи шифрования: %v", err)
+    }
+
+    // Журнал упреждающей записи буфера: пакеты, принятые, но не сохраненные
+    // до падения процесса, сохраняются при следующем запуске
+    if dir := os.Getenv("PLATYPUS_WAL_DIR"); dir != "" {
+        wal, err := metrics.OpenWAL(metrics.WALConfig{Dir: dir, Encryption: keyring})
+        if err != nil {
+            log.Fatalf("Не удалось открыть журнал метрик: %v", err)
+        }
+        collectorConfig.WAL = wal
+    }
+
+    // Потолок памяти точек: PLATYPUS_MEMORY_LIMIT=2GiB. Старые точки
+    // усредняются, а с PLATYPUS_MEMORY_SPILL_DIR - вытесняются на диск
+    var spill *metrics.DiskStore
+    if value := os.Getenv("PLATYPUS_MEMORY_LIMIT"); value != "" {
+        limit, err := metrics.ParseByteSize(value)
+        if err != nil || limit <= 0 {
+            log.Fatalf("Некорректный PLATYPUS_MEMORY_LIMIT %q", value)
+        }
+        ceiling := &metrics.MemoryCeiling{Limit: limit, Policy: metrics.CeilingDownsample}
+        if dir := os.Getenv("PLATYPUS_MEMORY_SPILL_DIR"); dir != "" {
+            spill, err = metrics.OpenDiskStore(dir)
+            if err != nil {
+                log.Fatalf("Не удалось открыть каталог вытеснения точек: %v", err)
+            }
+            if keyring != nil {
+                spill.SetEncryption(keyring)
+            }
+            ceiling.Policy, ceiling.Spill = metrics.CeilingSpill, spill
+        }
+        collectorConfig.MemoryCeiling = ceiling
+    }
+
+    // Снимки точек в памяти: короткий перезапуск не обнуляет историю,
+    // по которой анализатор и прогноз принимают решения
+    if path := os.Getenv("PLATYPUS_SNAPSHOT_PATH"); path != "" {
+        interval, err := time.ParseDuration(envOrDefault("PLATYPUS_SNAPSHOT_INTERVAL", "5m"))
+        if err != nil {
+            log.Fatalf("Некорректный PLATYPUS_SNAPSHOT_INTERVAL: %v", err)
+        }
+        collectorConfig.Snapshot = &metrics.SnapshotConfig{Path: path, Interval: interval, Encryption: keyring}
+    }
+
+    // Отправка датчиков в OpenTelemetry Collector наряду с /metrics для
+    // Prometheus; в автономном режиме исходящие соединения не используются
+    if endpoint := otlpEndpoint(); endpoint != "" && !airgapConfig.Enabled {
+        headers, err := metrics.ParseOTLPPairs(envOrDefault("PLATYPUS_OTLP_HEADERS", os.Getenv("OTEL_EXPORTER_OTLP_HEADERS")))
+        if err != nil {
+            log.Fatalf("Некорректные заголовки OTLP: %v", err)
+        }
+        attributes, err := metrics.ParseOTLPPairs(os.Getenv("OTEL_RESOURCE_ATTRIBUTES"))
+        if err != nil {
+            log.Fatalf("Некорректные атрибуты OTEL_RESOURCE_ATTRIBUTES: %v", err)
+        }
+        interval, err := time.ParseDuration(envOrDefault("PLATYPUS_OTLP_INTERVAL", "1m"))
+        if err != nil || interval <= 0 {
+            log.Fatalf("Некорректный интервал PLATYPUS_OTLP_INTERVAL: %q", os.Getenv("PLATYPUS_OTLP_INTERVAL"))
+        }
+        collectorConfig.OTLP = &metrics.OTLPConfig{
+            Endpoint:           endpoint,
+            Headers:            headers,
+            ResourceAttributes: attributes,
+            Interval:           interval,
+            Timeout:            10 * time.Second,
+        }
+    }
+
+    // Проверка окружения до запуска подсистем: сломанная подсистема должна
+    // останавливать запуск, а не молча работать вполсилы
+    if os.Getenv("PLATYPUS_SKIP_PREFLIGHT") != "true" {
+        report := preflight.Run(context.Background(), preflightChecks(airgapConfig, providerName, provider, storeName, store)...)
+        if report.Failed() {
+            report.Write(os.Stderr)
+            log.Fatalf("Предстартовая проверка не пройдена; PLATYPUS_SKIP_PREFLIGHT=true отключает ее")
+        }
     }
 
     collector := metrics.NewCollector(collectorConfig)
     
     // Инициализация анализатора
     analyzerConfig := metrics.AnalyzerConfig{
-        MinDataPoints:    10,
-        SmoothingFactor:  0.2,
-        AnomalyThreshold: 2.5,
+        MinDataPoints:      10,
+        SmoothingFactor:    0.2,
+        AnomalyThreshold:   2.5,
+        Window:             24 * time.Hour,
+        NetworkEnergyPerGB: 0.06, // Оценка энергоемкости передачи данных, кВт*ч/ГБ
+        // Новый сервер ищет аномалии по когорте (тип инстанса и сервис),
+        // пока не накопит столько своих точек
+        OwnBaselinePoints:  120,
+        // Две недели истории: недельному сезону нужно хотя бы два периода
+        DecompositionWindow: 14 * 24 * time.Hour,
+    }
+    // Веса эко-рейтинга: PLATYPUS_ECO_SCORE_WEIGHTS=power,utilization,carbon.
+    // Смена весов дает новую версию методики, история пересчитывается в ее ряд.
+    if value := os.Getenv("PLATYPUS_ECO_SCORE_WEIGHTS"); value != "" {
+        analyzerConfig.ScoreWeights, err = metrics.ParseScoreWeights(value)
+        if err != nil {
+            log.Fatalf("Некорректные веса PLATYPUS_ECO_SCORE_WEIGHTS: %v", err)
+        }
+    }
+    // Поиск аномалий: zscore по окну, ewma - по сглаженному уровню с
+    // контрольной полосой, устойчивый к самим аномалиям и замечающий дрейф,
+    // или mad - по медиане окна, которую не сдвигают единичные выбросы
+    analyzerConfig.AnomalyMethod, err = metrics.ParseAnomalyMethod(os.Getenv("PLATYPUS_ANOMALY_METHOD"))
+    if err != nil {
+        log.Fatalf("Некорректный PLATYPUS_ANOMALY_METHOD: %v", err)
     }
 
     analyzer := metrics.NewAnalyzer(analyzerConfig, collector)
     
-    // Инициализация HTTP сервера
-    server := api.NewServer(collector, analyzer)
-    
+    governorConfig := governor.PolicyConfig{
+        IdleCPUThreshold:   10.0,
+        MinIdleWindow:      30 * time.Minute,
+        FrequencyCapMHz:    0,
+        EvaluationInterval: 5 * time.Minute,
+    }
+
+    governorManager := governor.NewManager(governorConfig, collector, analyzer)
+
+    // Агенты периферийных устройств копят точки на диске и выгружают их
+    // пачками; интервал сбора сервер подбирает по изменчивости потребления
+    edgePlanner, err := edge.NewPlanner(edge.DefaultPolicy, collector)
+    if err != nil {
+        log.Fatalf("Некорректная политика агентов устройств: %v", err)
+    }
+
+    // Общий планировщик периодических задач: джиттер разводит запуски
+    // подсистем во времени, статус последнего запуска виден в /status
+    // Ошибки фоновых задач не останавливают их, поэтому учитываются отдельно:
+    // затяжной сбой подсистемы виден в /errors и вызывает оповещение
+    errorTracker := errtrack.New(errtrack.Config{
+        Default:     errtrack.Rule{Consecutive: 3, For: 15 * time.Minute},
+        LogInterval: 10 * time.Minute,
+    })
+    collector.SetErrorRecorder(errorTracker)
+
+    // Паника фоновой подсистемы не останавливает сервер: подсистема
+    // перезапускается с растущей паузой, сбои видны в /status
+    subsystems := supervisor.New(supervisor.Config{
+        InitialBackoff: time.Second,
+        MaxBackoff:     5 * time.Minute,
+        StableAfter:    10 * time.Minute,
+        Errors:         errorTracker,
+    })
+    collector.SetPanicHandler(func(err error) { subsystems.Report("collector", err) })
+
+    jobs := scheduler.New(scheduler.Config{
+        JitterFraction: 0.1,
+        Errors:         errorTracker,
+        Panics:         func(job string, err error) { subsystems.Report("jobs/"+job, err) },
+    })
+    registerJobs := func(list ...scheduler.Job) {
+        if err := jobs.Register(list...); err != nil {
+            log.Fatalf("Ошибка регистрации периодических задач: %v", err)
+        }
+    }
+    if keyring != nil {
+        registerJobs(encryptionJob(keyring, spill))
+    }
+    registerJobs(governorManager.Jobs()...)
+
+    authProvider := api.NewAPIKeyProvider(parseAPIKeys(os.Getenv("PLATYPUS_API_KEYS")))
+    // Области ключей: агент команды пишет только в свои серверы, ключ чтения
+    // видит только серверы с нужными метками
+    if path := os.Getenv("PLATYPUS_API_KEY_SCOPES"); path != "" {
+        scopes, err := api.LoadScopes(path)
+        if err != nil {
+            log.Fatalf("Не удалось загрузить области ключей API: %v", err)
+        }
+        authProvider.SetScopes(scopes)
+    }
+    serverOpts := []api.ServerOption{
+        api.WithErrorTracker(errorTracker),
+        api.WithAuthProvider(authProvider),
+        // Частые POST метрик от агентов журналируем только при медленной обработке
+        api.WithRequestLogger(api.NewRequestLogger(api.LoggingConfig{
+            Default: api.RouteLogRule{Level: api.LogAll, SampleRate: 1},
+            Routes: map[string]api.RouteLogRule{
+                "POST /api/v1/metrics": {Level: api.LogAll, SampleRate: 1, SlowThreshold: 500 * time.Millisecond},
+            },
+        })),
+        api.WithGovernor(governorManager),
+        api.WithEdge(edgePlanner),
+        api.WithStatusSection("operation", func() interface{} {
+            return airgap.Report(airgapConfig, carbonDataset.Version)
+        }),
+    }
+    // Ключи, выпущенные через /api/v1/api-keys, хранятся в файле; без файла
+    // управление ключами через API выключено
+    if path := os.Getenv("PLATYPUS_API_KEYS_FILE"); path != "" {
+        if err := authProvider.SetKeyStore(path); err != nil {
+            log.Fatalf("Не удалось загрузить ключи API: %v", err)
+        }
+        serverOpts = append(serverOpts, api.WithAPIKeys(authProvider))
+    }
+
+    // Веса целей (мощность, углерод, деньги, простой) по окружениям: в prod
+    // обычно важнее простой, в dev - экономия. Действующий набор весов
+    // записывается в каждый план миграции и объяснение решения.
+    objectivePolicy := objectives.DefaultPolicy()
+    if path := os.Getenv("PLATYPUS_OBJECTIVE_WEIGHTS"); path != "" {
+        policy, err := objectives.LoadPolicy(path)
+        if err != nil {
+            log.Fatalf("Не удалось загрузить веса целей: %v", err)
+        }
+        objectivePolicy = policy
+        log.Printf("Веса целей: по умолчанию и %d окружений из %s", len(policy.Environments), path)
+    }
+
     config := scaling.AutoscalerConfig{
         CPUThresholdHigh:    80.0,
         CPUThresholdLow:     20.0,
@@ -45,20 +368,271 @@ func main() {
         ScaleUpCooldown:     5 * time.Minute,
         ScaleDownCooldown:   15 * time.Minute,
         EvaluationInterval:  1 * time.Minute,
+        Groups:              nil, // Пусто - автомасштабирование всех серверов
+        Objectives:          objectivePolicy,
     }
 
-    autoscaler := scaling.NewAutoscaler(config, collector, analyzer, cloud.NewCloudProvider())
-    go autoscaler.Start(context.Background())
+    autoscaler := scaling.NewAutoscaler(config, collector, analyzer, provider)
+    autoscaler.SetRollout(rollout)
+    registerJobs(autoscaler.Jobs()...)
+    serverOpts = append(serverOpts, api.WithAutoscaler(autoscaler))
 
     plannerConfig := migration.PlannerConfig{
         MinPowerSaving:      100.0,
         MaxDowntime:         2 * time.Minute,
         PlanningInterval:    5 * time.Minute,
         ConcurrentMigrations: 3,
+        // Облачные API троттлят запросы, а сеть региона насыщается параллельными переносами
+        Limits: migration.MigrationLimits{
+            PerProvider: 2,
+            PerRegion:   1,
+            Rate:        migration.RateLimit{Max: 20, Per: time.Hour},
+            ProviderRates: map[string]migration.RateLimit{
+                "aws": {Max: 10, Per: 10 * time.Minute},
+                "gcp": {Max: 10, Per: 10 * time.Minute},
+            },
+        },
+        DispatchInterval: 10 * time.Second,
+        Hints: migration.PlacementHints{
+            PreferGreenRegions: true,
+        },
+        Downtime: migration.DefaultDowntimePolicy(),
+        // Рекомендуемый ASHRAE верхний предел температуры на входе - 27°C
+        Thermal: migration.ThermalPolicy{
+            MaxInletTemperature: 27,
+            Headroom:            2,
+            Window:              15 * time.Minute,
+        },
+        Objectives: objectivePolicy,
     }
 
-    planner := migration.NewPlanner(plannerConfig, collector, analyzer, cloud.NewCloudProvider())
-    go planner.Start(context.Background())
+    planner := migration.NewPlanner(plannerConfig, collector, analyzer, provider)
+    // Резервирования (RI, committed use): с зарезервированных хостов миграции
+    // планируются в последнюю очередь, использование видно в предпросмотре
+    if path := os.Getenv("PLATYPUS_RESERVATIONS"); path != "" {
+        reservations, err := migration.LoadReservations(path)
+        if err != nil {
+            log.Fatalf("Не удалось загрузить резервирования: %v", err)
+        }
+        planner.SetReservations(reservations)
+        log.Printf("Загружено резервирований: %d из %s", len(reservations), path)
+    }
+    planner.SetRollout(rollout)
+    // Запуски контейнера после миграции попадают в ее историю (GET /api/v1/migrations/history)
+    collector.OnContainerEvent(planner.ContainerEvent)
+    registerJobs(planner.Jobs()...)
+    serverOpts = append(serverOpts, api.WithStatusSection("migrations", func() interface{} {
+        return planner.QueueStatus()
+    }))
+    serverOpts = append(serverOpts, api.WithMigrationPlanner(planner))
+    serverOpts = append(serverOpts, api.WithRegionSimulator(migration.NewRegionSimulator(collector, provider, carbonDataset)))
+
+    // Метки сервисов (команда, окружение, центр затрат) выводятся из тегов
+    // облака и метаданных Kubernetes при обнаружении
+    // Серверы без облачного API регистрируются через POST
+    // /api/v1/inventory/servers/register; реестр хранится в PLATYPUS_SERVER_REGISTRY
+    inv := inventory.New(inventory.Config{
+        RefreshInterval: 10 * time.Minute,
+        Labels:          inventory.DefaultLabelConfig(),
+        RegistryPath:    os.Getenv("PLATYPUS_SERVER_REGISTRY"),
+    }, provider)
+    if err := inv.LoadRegistrations(); err != nil {
+        log.Fatalf("Не удалось загрузить реестр серверов: %v", err)
+    }
+    for _, registration := range inv.Registrations() {
+        if registration.InstanceType != "" {
+            analyzer.RegisterInstance(registration.ServerID, registration.InstanceType)
+        }
+    }
+    // Класс простоя берется из метки downtime-class пода, namespace или переопределения сервиса
+    planner.SetDowntimeClassifier(func(container models.Container) string {
+        return inv.ContainerLabels(container).Get("downtime_class")
+    })
+    planner.SetEnvironmentClassifier(func(container models.Container) string {
+        return inv.ContainerLabels(container).Get("environment")
+    })
+    autoscaler.SetEnvironmentClassifier(func(serverID string) string {
+        return inv.ServerLabels(serverID).Get("environment")
+    })
+    registerJobs(inv.Jobs()...)
+
+    // Метаданные серверов (владельцы, метки, исключения, классы простоя) хранятся
+    // в памяти: файл из PLATYPUS_SERVER_METADATA загружается при каждом запуске,
+    // изменения вносятся через POST /api/v1/inventory/servers/import
+    if path := os.Getenv("PLATYPUS_SERVER_METADATA"); path != "" {
+        records, err := inventory.LoadMetadata(path)
+        if err != nil {
+            log.Fatalf("Не удалось загрузить метаданные серверов: %v", err)
+        }
+        if err := inv.ImportMetadata(records); err != nil {
+            log.Fatalf("Не удалось загрузить метаданные серверов: %v", err)
+        }
+        log.Printf("Загружены метаданные %d серверов из %s", len(records), path)
+    }
+    planner.SetExemptions(func(serverID string) bool {
+        return inv.Exempt(serverID, inventory.ExemptMigration)
+    })
+    governorManager.SetExemptions(func(serverID string) bool {
+        return inv.Exempt(serverID, inventory.ExemptGovernor)
+    })
+
+    groupManager := groups.NewManager(collector, inv)
+    autoscaler.SetGroups(groupManager)
+    serverOpts = append(serverOpts, api.WithInventory(inv), api.WithGroups(groupManager))
+
+    energyAccountant := energy.New(energy.Config{
+        UpdateInterval: 15 * time.Minute,
+        MaxGap:         10 * time.Minute,
+        Retention:      90 * 24 * time.Hour,
+        Errors:         errorTracker,
+    }, collector, inv)
+    registerJobs(energyAccountant.Jobs()...)
+    serverOpts = append(serverOpts, api.WithEnergyAccountant(energyAccountant))
+
+    // История эко-рейтинга по версиям методики; PLATYPUS_ECO_SCORE_HISTORY -
+    // файл, в котором она переживает перезапуск
+    scoreHistory, err := ecoscore.New(ecoscore.Config{
+        UpdateInterval: time.Hour,
+        MaxGap:         10 * time.Minute,
+        Retention:      2 * 365 * 24 * time.Hour,
+        Path:           os.Getenv("PLATYPUS_ECO_SCORE_HISTORY"),
+    }, collector, analyzer, inv, carbonDataset)
+    if err != nil {
+        log.Fatalf("Не удалось загрузить историю эко-рейтинга: %v", err)
+    }
+    registerJobs(scoreHistory.Jobs()...)
+    serverOpts = append(serverOpts, api.WithEcoScoreHistory(scoreHistory))
+
+    recommendationsConfig := recommendations.ManagerConfig{
+        RefreshInterval: 15 * time.Minute,
+        ImpactWindow:    24 * time.Hour,
+        Errors:          errorTracker,
+    }
+
+    // Рост потребления в простое неделями быстрее когорты - повод проверить
+    // блок питания и вентиляторы; суточные базовые линии переживают перезапуск
+    // в PLATYPUS_HARDWARE_BASELINES
+    hardwareInspector, err := recommendations.NewHardwareInspector(recommendations.HardwareInspectorConfig{
+        Path: os.Getenv("PLATYPUS_HARDWARE_BASELINES"),
+    }, collector, analyzer)
+    if err != nil {
+        log.Fatalf("Не удалось загрузить базовые линии простоя: %v", err)
+    }
+    recommendationManager := recommendations.NewManager(recommendationsConfig, collector,
+        &recommendations.IdleDetector{Collector: collector, Analyzer: analyzer, CPUThreshold: 5.0, MinIdle: 6 * time.Hour},
+        &recommendations.RightSizer{Collector: collector, Analyzer: analyzer, CPUThreshold: 20.0},
+        &recommendations.ARMAdvisor{Collector: collector, Analyzer: analyzer},
+        &recommendations.ConsolidationPlanner{Planner: planner},
+        hardwareInspector,
+    )
+    recommendationManager.SetExemptions(func(serverID string) bool {
+        return inv.Exempt(serverID, inventory.ExemptRecommendations)
+    })
+    registerJobs(recommendationManager.Jobs()...)
+
+    forecastConfig := recommendations.ForecastConfig{
+        Horizon:            30 * 24 * time.Hour,
+        PricePerKWh:        0.12,
+        DefaultGramsPerKWh: 400,
+    }
+    teamOf := func(item recommendations.Recommendation) string {
+        if item.TargetKind == "service" {
+            return inv.ServiceLabels(item.TargetID).Get("team")
+        }
+        return inv.ServerLabels(item.TargetID).Get("team")
+    }
+    forecaster := recommendations.NewForecaster(forecastConfig, recommendationManager, teamOf, nil)
+
+    // Пометки людей на шкале метрик (нагрузочный тест, отказ кондиционера);
+    // PLATYPUS_ANNOTATIONS - файл, в котором они переживают перезапуск
+    annotationStore, err := annotations.New(annotations.Config{Path: os.Getenv("PLATYPUS_ANNOTATIONS")})
+    if err != nil {
+        log.Fatalf("Не удалось загрузить пометки: %v", err)
+    }
+    serverOpts = append(serverOpts, api.WithAnnotations(annotationStore))
+
+    reportGenerator := reports.NewGenerator(reports.GeneratorConfig{
+        Type:     "weekly",
+        Interval: 7 * 24 * time.Hour,
+        Retain:   12,
+    })
+    reportGenerator.AddSection("energy", func(from, to time.Time, filter reports.Filter) (interface{}, error) {
+        energyAccountant.Update()
+        // Отчет команды или региона содержит только их расход: разбивка по
+        // другому измерению относилась бы ко всему парку
+        if filter.Team != "" || filter.Region != "" {
+            groupBy, value := "team", filter.Team
+            if filter.Region != "" {
+                groupBy, value = "region", filter.Region
+            }
+            shares, err := energyAccountant.Showback(groupBy, from, to)
+            if err != nil {
+                return nil, err
+            }
+            return map[string]interface{}{
+                "total_kwh":     shares[value],
+                "by_" + groupBy: map[string]float64{value: shares[value]},
+            }, nil
+        }
+
+        byTeam, err := energyAccountant.Showback("team", from, to)
+        if err != nil {
+            return nil, err
+        }
+        var total float64
+        for _, kwh := range byTeam {
+            total += kwh
+        }
+        section := map[string]interface{}{"total_kwh": total, "by_team": byTeam}
+        // Резюме отчета объясняет изменение итога группами by_*, в том числе регионами
+        if byRegion, err := energyAccountant.Showback("region", from, to); err == nil {
+            section["by_region"] = byRegion
+        }
+        return section, nil
+    })
+    reportGenerator.AddSection("savings_forecast", func(from, to time.Time, filter reports.Filter) (interface{}, error) {
+        return forecaster.Forecast(0), nil
+    })
+    // Известные события периода: читатель отчета видит причины всплесков
+    reportGenerator.AddSection("annotations", func(from, to time.Time, filter reports.Filter) (interface{}, error) {
+        return annotationStore.List(annotations.Query{From: from, To: to}), nil
+    })
+    // Резюме отчетов: по шаблону или языковой моделью с API, совместимым с
+    // OpenAI Chat Completions; модель недоступна в автономном режиме
+    reportGenerator.SetSummarizer(reports.TemplateSummarizer{})
+    if url := os.Getenv("PLATYPUS_REPORT_SUMMARY_URL"); url != "" && !airgapConfig.Enabled {
+        summarizer, err := reports.NewChatSummarizer(reports.ChatSummarizerConfig{
+            URL:     url,
+            Model:   os.Getenv("PLATYPUS_REPORT_SUMMARY_MODEL"),
+            APIKey:  os.Getenv("PLATYPUS_REPORT_SUMMARY_API_KEY"),
+            Timeout: time.Minute,
+        })
+        if err != nil {
+            log.Fatalf("Ошибка настройки резюме отчетов: %v", err)
+        }
+        reportGenerator.SetSummarizer(summarizer)
+    }
+    registerJobs(reportGenerator.Jobs()...)
+
+    // Подписки команд на регулярные отчеты; доставка идет во внешние
+    // вебхуки, поэтому в автономном режиме подписок нет.
+    // PLATYPUS_REPORT_SUBSCRIPTIONS - файл, в котором они переживают перезапуск
+    if !airgapConfig.Enabled {
+        subscriptions, err := reports.NewSubscriptions(reports.SubscriptionsConfig{
+            Path: os.Getenv("PLATYPUS_REPORT_SUBSCRIPTIONS"),
+        }, reportGenerator)
+        if err != nil {
+            log.Fatalf("Не удалось загрузить подписки на отчеты: %v", err)
+        }
+        registerJobs(subscriptions.Jobs()...)
+        serverOpts = append(serverOpts, api.WithReportSubscriptions(subscriptions))
+    }
+
+    serverOpts = append(serverOpts,
+        api.WithRecommendations(recommendationManager),
+        api.WithSavingsForecaster(forecaster),
+        api.WithReports(reportGenerator),
+    )
 
     predictorConfig := ml.PredictorConfig{
         HistoryWindow:    168 * time.Hour,
@@ -69,7 +643,103 @@ func main() {
     }
 
     predictor := ml.NewPredictor(predictorConfig, collector)
-    go predictor.Start(context.Background())
+    predictor.SetServers(inv)
+    if err := predictor.LoadModels(); err != nil {
+        log.Printf("Не удалось загрузить модели предиктора: %v", err)
+    }
+    registerJobs(predictor.Jobs()...)
+
+    // Лента iCalendar для дежурных: прогнозные пики, окна и миграции
+    feed := calendar.NewFeed("Platypus")
+    feed.Add("migrations", planner)
+    feed.Add("peaks", calendar.FleetPeaks{
+        Servers: collector.ServerIDs,
+        Predict: func(ctx context.Context, serverID string, horizon time.Duration) ([]calendar.PowerForecast, error) {
+            predictions, err := predictor.PredictServerMetrics(ctx, serverID, horizon)
+            if err != nil {
+                return nil, err
+            }
+            forecasts := make([]calendar.PowerForecast, len(predictions))
+            for i, p := range predictions {
+                forecasts[i] = calendar.PowerForecast{At: p.Timestamp, Watts: p.PowerUsage}
+            }
+            return forecasts, nil
+        },
+    })
+    if path := os.Getenv("PLATYPUS_CALENDAR_WINDOWS"); path != "" {
+        windows, err := calendar.LoadWindows(path)
+        if err != nil {
+            log.Fatalf("Не удалось загрузить окна календаря: %v", err)
+        }
+        feed.Add("windows", windows)
+    }
+    serverOpts = append(serverOpts, api.WithCalendar(feed))
+
+    // Повторы одного оповещения сворачиваются для всех каналов, а внешние
+    // каналы ограничиваются, чтобы шторм аномалий не обесценил уведомления
+    alerts := alerting.NewDispatcher(alerting.DispatcherConfig{
+        DedupWindow:   30 * time.Minute,
+        FlushInterval: 1 * time.Minute,
+        Limits: map[string]alerting.RateLimit{
+            "slack":   {Max: 5, Per: 10 * time.Minute},
+            "webhook": {Max: 30, Per: 10 * time.Minute},
+        },
+    }, alerting.LogChannel{})
+    if !airgapConfig.Enabled {
+        if url := os.Getenv("PLATYPUS_ALERT_WEBHOOK"); url != "" {
+            alerts.AddChannel(alerting.NewWebhookChannel("webhook", url))
+        }
+        if url := os.Getenv("PLATYPUS_SLACK_WEBHOOK"); url != "" {
+            alerts.AddChannel(alerting.NewSlackChannel("slack", url))
+        }
+    }
+    subsystems.Go(context.Background(), "alerting", alerts.Start)
+    errorTracker.SetAlerts(alerts)
+    // Отозванные учетные данные и исчерпанные квоты провайдера требуют вмешательства
+    planner.SetAlerts(alerts)
+    autoscaler.SetAlerts(alerts)
+
+    // Аномалии серверов одной стойки или с коррелирующим потреблением
+    // объединяются в инцидент, чтобы событие на стойке давало одно уведомление
+    incidentManager := incidents.NewManager(incidents.Config{
+        EvaluationInterval: time.Minute,
+        Lookback:           time.Hour,
+        Window:             10 * time.Minute,
+        MinCorrelation:     0.8,
+        ResolveAfter:       30 * time.Minute,
+        Retention:          7 * 24 * time.Hour,
+        TopologyKeys:       strings.Split(envOrDefault("PLATYPUS_INCIDENT_TOPOLOGY_KEYS", "rack,pdu"), ","),
+    }, collector, analyzer, alerts)
+    // Топология - метки из метаданных сервера поверх тегов облака
+    incidentManager.SetTopology(func(serverID string) map[string]string {
+        labels := make(map[string]string)
+        if server, exists := inv.Server(serverID); exists {
+            for key, value := range server.Tags {
+                labels[key] = value
+            }
+        }
+        if metadata, exists := inv.Metadata(serverID); exists {
+            for key, value := range metadata.Labels {
+                labels[key] = value
+            }
+        }
+        return labels
+    })
+    registerJobs(incidentManager.Jobs()...)
+    serverOpts = append(serverOpts, api.WithIncidents(incidentManager))
+
+    budgetManager := budgets.NewManager(budgets.Config{
+        EvaluationInterval: 15 * time.Minute,
+        DefaultGramsPerKWh: 400,
+        Errors:             errorTracker,
+    }, energyAccountant, inv, carbonDataset, predictor, alerts)
+    budgetManager.SetGroups(groupManager)
+    registerJobs(budgetManager.Jobs()...)
+
+    serverOpts = append(serverOpts,
+        api.WithAlerts(alerts),
+        api.WithBudgets(budgetManager),
+    )
 
     tagManagerConfig := ecotags.TagManagerConfig{
         UpdateInterval: 15 * time.Minute,
@@ -77,12 +747,480 @@ func main() {
     }
 
     tagManager := ecotags.NewTagManager(tagManagerConfig, collector, analyzer)
-    go tagManager.Start(context.Background())
+    tagManager.SetInventory(inv)
+    // Развертывание новой версии сбрасывает эко-профиль сервиса
+    collector.OnContainerEvent(tagManager.ContainerEvent)
+    // Эко-цели сервисов для проверок в конвейерах развертывания
+    // (GET /api/v1/services/{name}/eco-check)
+    if path := os.Getenv("PLATYPUS_ECO_SLOS"); path != "" {
+        policy, err := ecotags.LoadSLOPolicy(path)
+        if err != nil {
+            log.Fatalf("Не удалось загрузить эко-цели сервисов: %v", err)
+        }
+        tagManager.SetSLOs(policy)
+        log.Printf("Эко-цели: по умолчанию и %d сервисов из %s", len(policy.Services), path)
+    }
+    // Часы пик площадок для тега peak-hours: по умолчанию 09:00-18:00 по
+    // локальному времени, у регионов и арендаторов - свои пояса и праздники
+    if path := os.Getenv("PLATYPUS_PEAK_HOURS"); path != "" {
+        policy, err := peakhours.LoadPolicy(path)
+        if err != nil {
+            log.Fatalf("Не удалось загрузить часы пик: %v", err)
+        }
+        tagManager.SetPeakHours(policy)
+        log.Printf("Часы пик: по умолчанию, %d регионов и %d арендаторов из %s", len(policy.Regions), len(policy.Tenants), path)
+    }
+
+    // Сканирование образов обращается к внешним реестрам, поэтому недоступно в автономном режиме
+    if os.Getenv("PLATYPUS_IMAGE_SCAN") == "true" && !airgapConfig.Enabled {
+        scannerConfig := imagescan.ScannerConfig{
+            ScanInterval:      6 * time.Hour,
+            Timeout:           30 * time.Second,
+            LargeImageBytes:   1 << 30,
+            LargeLayerBytes:   300 << 20,
+            MaxLayers:         20,
+            DeploymentsPerDay: 1,
+        }
+        // Реестры, к которым разрешены обращения; без списка - только Docker Hub
+        for _, registry := range strings.Split(os.Getenv("PLATYPUS_IMAGE_SCAN_REGISTRIES"), ",") {
+            if registry = strings.TrimSpace(registry); registry != "" {
+                scannerConfig.Registries = append(scannerConfig.Registries, registry)
+            }
+        }
+
+        imageScanner := imagescan.NewScanner(scannerConfig)
+        tagManager.SetImageScanner(imageScanner)
+        serverOpts = append(serverOpts, api.WithImageScanner(imageScanner))
+        registerJobs(imageScanner.Jobs()...)
+    }
+    registerJobs(tagManager.Jobs()...)
+
+    fleetInsights := insights.New(collector, tagManager)
+    serverOpts = append(serverOpts, api.WithInsights(fleetInsights), api.WithTagManager(tagManager))
+
+    // Прогноз углеродной интенсивности для GET /insights/green-windows: файл
+    // (PLATYPUS_CARBON_FORECAST) или Electricity Maps; в автономном режиме - только файл
+    if path := os.Getenv("PLATYPUS_CARBON_FORECAST"); path != "" {
+        forecast, err := carbon.LoadForecast(path)
+        if err != nil {
+            log.Fatalf("Ошибка загрузки прогноза интенсивности: %v", err)
+        }
+        fleetInsights.SetCarbonForecast(forecast)
+    } else if token := os.Getenv("PLATYPUS_ELECTRICITYMAPS_TOKEN"); token != "" && !airgapConfig.Enabled {
+        zones, err := carbon.ParseZones(os.Getenv("PLATYPUS_ELECTRICITYMAPS_ZONES"))
+        if err != nil {
+            log.Fatalf("Некорректный PLATYPUS_ELECTRICITYMAPS_ZONES: %v", err)
+        }
+        forecast, err := carbon.NewElectricityMaps(carbon.ElectricityMapsConfig{Token: token, Zones: zones})
+        if err != nil {
+            log.Fatalf("Ошибка настройки прогноза Electricity Maps: %v", err)
+        }
+        fleetInsights.SetCarbonForecast(forecast)
+    }
+
+    // Режим федерации: standalone, edge или central
+    switch federation.Mode(os.Getenv("PLATYPUS_FEDERATION_MODE")) {
+    case federation.ModeCentral:
+        hub := federation.NewHub(federation.HubConfig{StaleAfter: 15 * time.Minute}, federation.Policy{
+            CPUThresholdHigh:   config.CPUThresholdHigh,
+            CPUThresholdLow:    config.CPUThresholdLow,
+            PowerThresholdHigh: config.PowerThresholdHigh,
+            MinPowerSaving:     plannerConfig.MinPowerSaving,
+        })
+        serverOpts = append(serverOpts, api.WithFederationHub(hub))
+    case federation.ModeEdge:
+        if airgapConfig.Enabled {
+            log.Println("Отчеты федерации отключены в автономном режиме")
+            break
+        }
 
-    go collector.Start(context.Background())
+        reporterConfig := federation.ReporterConfig{
+            SiteID:         os.Getenv("PLATYPUS_SITE_ID"),
+            CentralURL:     os.Getenv("PLATYPUS_CENTRAL_URL"),
+            APIKey:         os.Getenv("PLATYPUS_CENTRAL_API_KEY"),
+            ReportInterval: 1 * time.Minute,
+            Timeout:        10 * time.Second,
+        }
 
-    log.Println("Запуск Platypus сервера на порту :8080")
-    if err := http.ListenAndServe(":8080", server.Router()); err != nil {
+        reporter := federation.NewReporter(reporterConfig, collector, analyzer, func(policy federation.Policy) {
+            log.Printf("Применена политика федерации версии %d", policy.Version)
+            autoscaler.SetThresholds(policy.CPUThresholdHigh, policy.CPUThresholdLow, policy.PowerThresholdHigh)
+            planner.SetMinPowerSaving(policy.MinPowerSaving)
+        })
+        registerJobs(reporter.Jobs()...)
+    }
+
+    // Репликация окна метрик на теплый резерв: primary отправляет, standby принимает
+    switch replication.Mode(os.Getenv("PLATYPUS_REPLICATION_MODE")) {
+    case replication.ModePrimary:
+        if airgapConfig.Enabled {
+            log.Println("Репликация на резерв отключена в автономном режиме")
+            break
+        }
+
+        sourceID, _ := os.Hostname()
+        replicator := replication.NewReplicator(replication.ReplicatorConfig{
+            SourceID:      sourceID,
+            StandbyURL:    os.Getenv("PLATYPUS_STANDBY_URL"),
+            APIKey:        os.Getenv("PLATYPUS_STANDBY_API_KEY"),
+            QueueSize:     10000,
+            MaxBatches:    500,
+            FlushInterval: 1 * time.Second,
+            RetryInterval: 5 * time.Second,
+            Timeout:       10 * time.Second,
+        }, collector)
+        // Подписываемся до запуска сборщика, чтобы не пропустить ни одного пакета
+        collector.OnIngest(replicator.Enqueue)
+        subsystems.Go(context.Background(), "replication", replicator.Start)
+        serverOpts = append(serverOpts, api.WithReplicator(replicator))
+    case replication.ModeStandby:
+        serverOpts = append(serverOpts, api.WithReplicationReceiver(replication.NewReceiver(collector)))
+    }
+
+    // Отправка принятых точек в хранилище с Prometheus remote write (Thanos, Mimir)
+    if url := os.Getenv("PLATYPUS_REMOTE_WRITE_URL"); url != "" {
+        if airgapConfig.Enabled {
+            log.Println("Remote write отключен в автономном режиме")
+        } else {
+            externalLabels, err := remotewrite.ParseExternalLabels(os.Getenv("PLATYPUS_REMOTE_WRITE_EXTERNAL_LABELS"))
+            if err != nil {
+                log.Fatalf("Некорректные метки PLATYPUS_REMOTE_WRITE_EXTERNAL_LABELS: %v", err)
+            }
+            writeLabels := collectorConfig.MetricLabels
+            if writeLabels == nil {
+                writeLabels = metrics.DefaultMetricLabels
+            }
+            headers := map[string]string{}
+            if orgID := os.Getenv("PLATYPUS_REMOTE_WRITE_ORG_ID"); orgID != "" {
+                headers["X-Scope-OrgID"] = orgID // Арендатор Mimir/Cortex
+            }
+            writer, err := remotewrite.New(remotewrite.Config{
+                URL:            url,
+                Headers:        headers,
+                BearerToken:    os.Getenv("PLATYPUS_REMOTE_WRITE_BEARER_TOKEN"),
+                Username:       os.Getenv("PLATYPUS_REMOTE_WRITE_USERNAME"),
+                Password:       os.Getenv("PLATYPUS_REMOTE_WRITE_PASSWORD"),
+                ExternalLabels: externalLabels,
+                Labels:         writeLabels,
+                QueueSize:      10000,
+                MaxSamples:     2000,
+                FlushInterval:  5 * time.Second,
+                Timeout:        30 * time.Second,
+            })
+            if err != nil {
+                log.Fatalf("Ошибка настройки remote write: %v", err)
+            }
+            // Подписываемся до запуска сборщика, чтобы не пропустить ни одного пакета
+            collector.OnIngest(writer.Enqueue)
+            registerJobs(writer.Jobs()...)
+            serverOpts = append(serverOpts, api.WithStatusSection("remote_write", func() interface{} {
+                return writer.Stats()
+            }))
+        }
+    }
+
+    if err := collector.Start(context.Background()); err != nil {
+        log.Fatalf("Ошибка запуска коллектора: %v", err)
+    }
+    registerJobs(collector.Jobs()...)
+    serverOpts = append(serverOpts, api.WithStatusSection("tenants", func() interface{} {
+        return collector.Tenants()
+    }))
+    serverOpts = append(serverOpts, api.WithStatusSection("anomaly_cohorts", func() interface{} {
+        return analyzer.Cohorts()
+    }))
+    if keyring != nil {
+        serverOpts = append(serverOpts, api.WithStatusSection("encryption", func() interface{} {
+            return map[string]interface{}{"primary_key": keyring.Primary()}
+        }))
+    }
+    if collectorConfig.WAL != nil {
+        serverOpts = append(serverOpts, api.WithStatusSection("wal", func() interface{} {
+            return collectorConfig.WAL.Stats()
+        }))
+    }
+    if collectorConfig.MemoryCeiling != nil {
+        serverOpts = append(serverOpts, api.WithStatusSection("memory", func() interface{} {
+            return collector.MemoryStats()
+        }))
+    }
+    if collectorConfig.Snapshot != nil {
+        serverOpts = append(serverOpts, api.WithStatusSection("snapshot", func() interface{} {
+            return collector.SnapshotStats()
+        }))
+        // При штатной остановке снимок сохраняется, чтобы не терять точки
+        // с последнего периодического снимка
+        go func() {
+            stop := make(chan os.Signal, 1)
+            signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+            <-stop
+            if err := collector.SaveSnapshot(); err != nil {
+                log.Printf("Снимок метрик при остановке не сохранен: %v", err)
+            }
+            os.Exit(0)
+        }()
+    }
+
+    // Прием метрик из Kafka для площадок, которые уже везут телеметрию через нее
+    if brokers := os.Getenv("PLATYPUS_KAFKA_BROKERS"); brokers != "" {
+        kafkaIngester, err := metrics.NewKafkaIngester(metrics.KafkaConfig{
+            Brokers: strings.Split(brokers, ","),
+            Topic:   envOrDefault("PLATYPUS_KAFKA_TOPIC", "platypus-metrics"),
+            GroupID: envOrDefault("PLATYPUS_KAFKA_GROUP", "platypus"),
+            Errors:  errorTracker,
+        }, collector)
+        if err != nil {
+            log.Fatalf("Ошибка подключения к Kafka: %v", err)
+        }
+        subsystems.Go(context.Background(), "kafka", kafkaIngester.Start)
+        serverOpts = append(serverOpts, api.WithStatusSection("kafka", func() interface{} {
+            return kafkaIngester.Stats()
+        }))
+    }
+    // Показания умных PDU и счетчиков из MQTT; топики сопоставляются серверам по файлу правил
+    if broker := os.Getenv("PLATYPUS_MQTT_BROKER"); broker != "" {
+        mappings, err := mqtt.LoadMappings(os.Getenv("PLATYPUS_MQTT_MAPPINGS"))
+        if err != nil {
+            log.Fatalf("Не удалось загрузить правила топиков MQTT: %v", err)
+        }
+        subscriber, err := mqtt.NewSubscriber(mqtt.Config{
+            Broker:   broker,
+            ClientID: envOrDefault("PLATYPUS_MQTT_CLIENT_ID", "platypus"),
+            Username: os.Getenv("PLATYPUS_MQTT_USERNAME"),
+            Password: os.Getenv("PLATYPUS_MQTT_PASSWORD"),
+            QoS:      1,
+            Mappings: mappings,
+            Errors:   errorTracker,
+        }, collector)
+        if err != nil {
+            log.Fatalf("Ошибка настройки приема из MQTT: %v", err)
+        }
+        subsystems.Go(context.Background(), "mqtt", subscriber.Start)
+        serverOpts = append(serverOpts, api.WithStatusSection("mqtt", func() interface{} {
+            return subscriber.Stats()
+        }))
+    }
+    // Оценки мощности, которые сервисы отправляют по StatsD/DogStatsD;
+    // имена метрик сопоставляются серверам и полям точки по файлу правил
+    if address := os.Getenv("PLATYPUS_STATSD_ADDR"); address != "" {
+        mappings, err := statsd.LoadMappings(os.Getenv("PLATYPUS_STATSD_MAPPINGS"))
+        if err != nil {
+            log.Fatalf("Не удалось загрузить правила метрик StatsD: %v", err)
+        }
+        listener, err := statsd.NewListener(statsd.Config{
+            Address:       address,
+            Mappings:      mappings,
+            FlushInterval: 10 * time.Second,
+            Errors:        errorTracker,
+        }, collector)
+        if err != nil {
+            log.Fatalf("Ошибка настройки приема StatsD: %v", err)
+        }
+        subsystems.Go(context.Background(), "statsd", listener.Start)
+        registerJobs(listener.Jobs()...)
+        serverOpts = append(serverOpts, api.WithStatusSection("statsd", func() interface{} {
+            return listener.Stats()
+        }))
+    }
+    // Опрос node_exporter и экспортеров RAPL: показания забираются с хостов,
+    // которые сами ничего не отправляют
+    if path := os.Getenv("PLATYPUS_SCRAPE_TARGETS"); path != "" {
+        targets, err := scrape.LoadTargets(path)
+        if err != nil {
+            log.Fatalf("Не удалось загрузить цели опроса: %v", err)
+        }
+        scrapeInterval := 30 * time.Second
+        if value := os.Getenv("PLATYPUS_SCRAPE_INTERVAL"); value != "" {
+            if scrapeInterval, err = time.ParseDuration(value); err != nil {
+                log.Fatalf("Некорректный PLATYPUS_SCRAPE_INTERVAL: %v", err)
+            }
+        }
+        scraper, err := scrape.NewScraper(scrape.Config{
+            Targets:  targets,
+            Interval: scrapeInterval,
+            Timeout:  10 * time.Second,
+        }, collector)
+        if err != nil {
+            log.Fatalf("Ошибка настройки опроса экспортеров: %v", err)
+        }
+        registerJobs(scraper.Jobs()...)
+        serverOpts = append(serverOpts, api.WithStatusSection("scrape", func() interface{} {
+            return scraper.Status()
+        }))
+        log.Printf("Опрос экспортеров: %d целей каждые %s", len(targets), scrapeInterval)
+    }
+    go jobs.Start(context.Background())
+    serverOpts = append(serverOpts, api.WithStatusSection("jobs", func() interface{} {
+        return jobs.Status()
+    }))
+    serverOpts = append(serverOpts, api.WithStatusSection("subsystems", func() interface{} {
+        return subsystems.Status()
+    }))
+
+    // Старые точки сворачиваются: последние 6 часов хранятся как есть,
+    // до 48 часов - средние за 5 минут, дальше - за час
+    rollup := metrics.NewRollup(metrics.RollupConfig{
+        Tiers: []metrics.RollupTier{
+            {After: 6 * time.Hour, Resolution: 5 * time.Minute},
+            {After: 48 * time.Hour, Resolution: time.Hour},
+        },
+        Interval: 10 * time.Minute,
+    }, collector)
+    registerJobs(rollup.Jobs()...)
+    serverOpts = append(serverOpts, api.WithStatusSection("rollup", func() interface{} {
+        return rollup.Stats()
+    }))
+    serverOpts = append(serverOpts, api.WithStatusSection("provider", func() interface{} {
+        return resilientProvider.Breakers()
+    }))
+    serverOpts = append(serverOpts, api.WithStatusSection("provider_health", func() interface{} {
+        return resilientProvider.Health()
+    }))
+    serverOpts = append(serverOpts, api.WithRollout(rollout), api.WithStatusSection("provider_modes", func() interface{} {
+        return rollout.Modes()
+    }))
+
+    // Потоковый прием метрик от агентов по gRPC, с теми же ключами API
+    if addr := os.Getenv("PLATYPUS_GRPC_ADDR"); addr != "" {
+        ingestServer := grpcingest.NewServer(collector, authProvider, func(serverID string) map[string]string {
+            return inv.ServerLabels(serverID).Values
+        })
+        subsystems.Go(context.Background(), "grpc", func(ctx context.Context) error {
+            return ingestServer.Serve(ctx, addr)
+        })
+    }
+
+    // Инициализация HTTP сервера
+    server := api.NewServer(collector, analyzer, serverOpts...)
+
+    log.Printf("Запуск Platypus сервера на %s", listenAddr)
+    if err := http.ListenAndServe(listenAddr, server.Router()); err != nil {
         log.Fatal(err)
     }
-} 
\ No newline at end of file
+}
+
+// airgapConfigFromEnv читает настройки автономного режима
+func airgapConfigFromEnv() airgap.Config {
+    return airgap.Config{
+        Enabled:           os.Getenv("PLATYPUS_OFFLINE") == "true",
+        InventoryPath:     "./data/inventory.json",
+        CarbonDatasetPath: os.Getenv("PLATYPUS_CARBON_DATASET"),
+        ModelPath:         "./data/models",
+    }
+}
+
+// newProvider создает облачного провайдера, в автономном режиме - файловый
+func newProvider(airgapConfig airgap.Config) (cloud.CloudProvider, string, error) {
+    if !airgapConfig.Enabled {
+        return cloud.NewCloudProvider(), "cloud", nil
+    }
+    fileProvider, err := cloud.NewFileProvider(airgapConfig.InventoryPath)
+    if err != nil {
+        return nil, "", err
+    }
+    return fileProvider, "file", nil
+}
+
+// newMetricsStore создает внешнее хранилище по PLATYPUS_METRICS_STORE.
+// nil означает хранение в памяти.
+func newMetricsStore(retention time.Duration) (metrics.Store, string, error) {
+    switch name := os.Getenv("PLATYPUS_METRICS_STORE"); name {
+    case "influxdb":
+        store, err := metrics.NewInfluxStore(metrics.InfluxConfig{
+            URL:      os.Getenv("PLATYPUS_INFLUX_URL"),
+            Org:      os.Getenv("PLATYPUS_INFLUX_ORG"),
+            Bucket:   os.Getenv("PLATYPUS_INFLUX_BUCKET"),
+            Token:    os.Getenv("PLATYPUS_INFLUX_TOKEN"),
+            Lookback: retention,
+            Timeout:  10 * time.Second,
+        })
+        return store, name, err
+    case "postgres", "timescale":
+        store, err := metrics.NewPostgresStore(metrics.PostgresConfig{
+            DSN:             os.Getenv("PLATYPUS_POSTGRES_DSN"),
+            Timescale:       name == "timescale",
+            InsertBatchSize: 1000,
+            Timeout:         10 * time.Second,
+        })
+        return store, name, err
+    }
+    return nil, "memory", nil
+}
+
+// loadEncryptionKeys загружает ключи шифрования данных на диске из
+// PLATYPUS_ENCRYPTION_KEYS_FILE или PLATYPUS_ENCRYPTION_KEYS. nil - шифрование
+// выключено.
+func loadEncryptionKeys() (*encryption.Keyring, error) {
+    var source encryption.KeySource
+    if path := os.Getenv("PLATYPUS_ENCRYPTION_KEYS_FILE"); path != "" {
+        source = encryption.FileKeys(path)
+    } else if os.Getenv("PLATYPUS_ENCRYPTION_KEYS") != "" {
+        source = encryption.EnvKeys("PLATYPUS_ENCRYPTION_KEYS")
+    } else {
+        return nil, nil
+    }
+    return encryption.Load(context.Background(), source)
+}
+
+// encryptionJob перечитывает ключи и после смены основного ключа
+// перешифровывает вытесненные точки. Снимок перешифровывается при следующем
+// сохранении, сегменты журнала - по мере удаления старых.
+func encryptionJob(keyring *encryption.Keyring, spill *metrics.DiskStore) scheduler.Job {
+    return scheduler.Job{
+        Name:     "encryption.reload",
+        Interval: 5 * time.Minute,
+        Run: func(ctx context.Context) error {
+            changed, err := keyring.Reload(ctx)
+            if err != nil || !changed {
+                return err
+            }
+            log.Printf("Основной ключ шифрования сменился на %s", keyring.Primary())
+            if spill == nil {
+                return nil
+            }
+            rewritten, err := spill.Reencrypt()
+            if err != nil {
+                return err
+            }
+            log.Printf("Перешифровано рядов вытесненных точек: %d", rewritten)
+            return nil
+        },
+    }
+}
+
+// envOrDefault возвращает значение переменной окружения или fallback, если она пуста
+func envOrDefault(name, fallback string) string {
+    if value := os.Getenv(name); value != "" {
+        return value
+    }
+    return fallback
+}
+
+// otlpEndpoint возвращает адрес приема метрик OTLP/HTTP: PLATYPUS_OTLP_ENDPOINT
+// или стандартные переменные OpenTelemetry. Пусто - отправка выключена.
+func otlpEndpoint() string {
+    if endpoint := envOrDefault("PLATYPUS_OTLP_ENDPOINT", os.Getenv("OTEL_EXPORTER_OTLP_METRICS_ENDPOINT")); endpoint != "" {
+        return endpoint
+    }
+    if base := os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"); base != "" {
+        return strings.TrimSuffix(base, "/") + "/v1/metrics"
+    }
+    return ""
+}
+
+// parseAPIKeys разбирает список ключей вида "key1:client1,key2:client2"
+func parseAPIKeys(value string) map[string]string {
+    keys := make(map[string]string)
+    for _, entry := range strings.Split(value, ",") {
+        entry = strings.TrimSpace(entry)
+        if entry == "" {
+            continue
+        }
+        key, id, found := strings.Cut(entry, ":")
+        if !found {
+            id = key
+        }
+        keys[key] = id
+    }
+    return keys
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+    "encoding/json"
+    "flag"
+    "fmt"
+    "io"
+    "net/http"
+    "net/url"
+    "os"
+    "strings"
+    "time"
+
+    "github.com/YumeNoTenshi/platypus/internal/inventory"
+)
+
+const metadataUsage = `usage:
+  platypus metadata import [--url URL] [--dry-run] <file.csv|file.yaml|file.json>
+  platypus metadata export [--url URL] [--format csv|yaml|json] [--output FILE]
+
+Ключ API берется из PLATYPUS_API_KEY.`
+
+// runMetadata реализует "platypus metadata import|export": массовая загрузка
+// метаданных серверов в работающий сервер и выгрузка текущего инвентаря.
+// Возвращает код выхода.
+func runMetadata(args []string) int {
+    if len(args) == 0 {
+        fmt.Fprintln(os.Stderr, metadataUsage)
+        return 2
+    }
+
+    flags := flag.NewFlagSet("metadata "+args[0], flag.ContinueOnError)
+    baseURL := flags.String("url", envOrDefault("PLATYPUS_URL", "http://localhost"+listenAddr), "адрес сервера platypus")
+    dryRun := flags.Bool("dry-run", false, "только проверить файл на сервере")
+    format := flags.String("format", inventory.FormatCSV, "формат выгрузки: csv, yaml или json")
+    output := flags.String("output", "", "файл выгрузки; по умолчанию stdout")
+    if err := flags.Parse(args[1:]); err != nil {
+        return 2
+    }
+
+    var err error
+    switch args[0] {
+    case "import":
+        if flags.NArg() != 1 {
+            fmt.Fprintln(os.Stderr, metadataUsage)
+            return 2
+        }
+        err = importMetadata(*baseURL, flags.Arg(0), *dryRun)
+    case "export":
+        err = exportMetadata(*baseURL, *format, *output)
+    default:
+        fmt.Fprintln(os.Stderr, metadataUsage)
+        return 2
+    }
+    if err != nil {
+        fmt.Fprintln(os.Stderr, err)
+        return 1
+    }
+    return 0
+}
+
+func importMetadata(baseURL, path string, dryRun bool) error {
+    format := inventory.FormatFromPath(path)
+
+    // Файл проверяется до отправки, чтобы ошибки указывали на строку файла
+    records, err := inventory.LoadMetadata(path)
+    if err != nil {
+        return err
+    }
+    body, err := os.Open(path)
+    if err != nil {
+        return err
+    }
+    defer body.Close()
+
+    query := url.Values{"format": {format}}
+    if dryRun {
+        query.Set("dry_run", "true")
+    }
+    response, err := metadataRequest(http.MethodPost, baseURL+"/api/v1/inventory/servers/import?"+query.Encode(), body)
+    if err != nil {
+        return err
+    }
+    defer response.Body.Close()
+
+    var result struct {
+        Message string `json:"message"`
+    }
+    json.NewDecoder(response.Body).Decode(&result)
+    if response.StatusCode != http.StatusOK {
+        return fmt.Errorf("import failed: %s: %s", response.Status, result.Message)
+    }
+
+    if dryRun {
+        fmt.Printf("%s: %d servers valid, nothing imported\n", path, len(records))
+    } else {
+        fmt.Printf("%s: imported %d servers\n", path, len(records))
+    }
+    return nil
+}
+
+func exportMetadata(baseURL, format, output string) error {
+    response, err := metadataRequest(http.MethodGet, baseURL+"/api/v1/inventory/servers/export?format="+url.QueryEscape(format), nil)
+    if err != nil {
+        return err
+    }
+    defer response.Body.Close()
+    if response.StatusCode != http.StatusOK {
+        message, _ := io.ReadAll(io.LimitReader(response.Body, 4096))
+        return fmt.Errorf("export failed: %s: %s", response.Status, strings.TrimSpace(string(message)))
+    }
+
+    var w io.Writer = os.Stdout
+    if output != "" {
+        file, err := os.Create(output)
+        if err != nil {
+            return err
+        }
+        defer file.Close()
+        w = file
+    }
+    _, err = io.Copy(w, response.Body)
+    return err
+}
+
+func metadataRequest(method, target string, body io.Reader) (*http.Response, error) {
+    request, err := http.NewRequest(method, target, body)
+    if err != nil {
+        return nil, err
+    }
+    request.Header.Set("X-API-Key", os.Getenv("PLATYPUS_API_KEY"))
+
+    client := &http.Client{Timeout: 5 * time.Minute}
+    return client.Do(request)
+}
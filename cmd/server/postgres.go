@@ -0,0 +1,9 @@
+//go:build postgres
+
+package main
+
+// Драйвер PostgreSQL для metrics.PostgresStore подключается только в сборке
+// с тегом postgres, чтобы основной бинарник не зависел от него:
+//
+//	go build -tags postgres ./cmd/server
+import _ "github.com/jackc/pgx/v5/stdlib"
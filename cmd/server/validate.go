@@ -0,0 +1,186 @@
+package main
+
+import (
+    "context"
+    "flag"
+    "fmt"
+    "os"
+    "path/filepath"
+    "time"
+
+    "github.com/YumeNoTenshi/platypus/internal/airgap"
+    "github.com/YumeNoTenshi/platypus/internal/encryption"
+    "github.com/YumeNoTenshi/platypus/internal/federation"
+    "github.com/YumeNoTenshi/platypus/internal/metrics"
+    "github.com/YumeNoTenshi/platypus/internal/preflight"
+    "github.com/YumeNoTenshi/platypus/internal/replication"
+    "github.com/YumeNoTenshi/platypus/pkg/cloud"
+)
+
+// runValidate реализует "platypus validate --config <файл>": проверяет файл
+// конфигурации и окружение так же, как при запуске, и ничего не запускает.
+// Возвращает код выхода: 0 - все в порядке, 1 - есть ошибки.
+func runValidate(args []string) int {
+    flags := flag.NewFlagSet("validate", flag.ContinueOnError)
+    configPath := flags.String("config", "configs/config.yaml", "путь к файлу конфигурации")
+    if err := flags.Parse(args); err != nil {
+        return 2
+    }
+
+    var report preflight.Report
+    report.Add(preflight.ValidateConfigFile(*configPath)...)
+
+    airgapConfig := airgapConfigFromEnv()
+
+    provider, providerName, err := newProvider(airgapConfig)
+    if err != nil {
+        report.Add(preflight.Result{
+            Name:    "provider inventory",
+            Status:  preflight.StatusFailed,
+            Message: err.Error(),
+            Hint:    "Проверьте файл " + airgapConfig.InventoryPath,
+        })
+    }
+    store, storeName, err := newMetricsStore(168 * time.Hour)
+    if err != nil {
+        report.Add(preflight.Result{
+            Name:    "metrics store " + storeName,
+            Status:  preflight.StatusFailed,
+            Message: err.Error(),
+            Hint:    "Проверьте PLATYPUS_METRICS_STORE и параметры подключения; для postgres нужна сборка с -tags postgres",
+        })
+        store = nil
+    }
+
+    checks := preflightChecks(airgapConfig, providerName, provider, storeName, store)
+    validated := preflight.Run(context.Background(), checks...)
+    report.Add(validated.Results...)
+
+    report.Write(os.Stdout)
+    if report.Failed() {
+        fmt.Fprintln(os.Stderr, "validation failed")
+        return 1
+    }
+    return 0
+}
+
+// preflightChecks собирает проверки для текущего окружения. Общая для
+// команды validate и запуска сервера.
+func preflightChecks(airgapConfig airgap.Config, providerName string, provider cloud.CloudProvider, storeName string, store metrics.Store) []preflight.Check {
+    checks := []preflight.Check{
+        preflight.CarbonData(airgapConfig.CarbonDatasetPath),
+        preflight.Writable("models", airgapConfig.ModelPath),
+        preflight.PortAvailable(listenAddr),
+    }
+    if addr := os.Getenv("PLATYPUS_GRPC_ADDR"); addr != "" {
+        checks = append(checks, preflight.PortAvailable(addr))
+    }
+    if provider != nil {
+        checks = append(checks, preflight.ProviderCredentials(providerName, provider, 30*time.Second))
+    }
+    if store != nil {
+        checks = append(checks, preflight.MetricsStore(storeName, store))
+    }
+    if path := os.Getenv("PLATYPUS_POWER_MODELS"); path != "" {
+        checks = append(checks, preflight.PowerModels(path))
+    }
+    if path := os.Getenv("PLATYPUS_API_KEY_SCOPES"); path != "" {
+        checks = append(checks, preflight.APIKeyScopes(path))
+    }
+    if path := os.Getenv("PLATYPUS_API_KEYS_FILE"); path != "" {
+        checks = append(checks, preflight.Writable("api keys", filepath.Dir(path)))
+    }
+    if dir := os.Getenv("PLATYPUS_WAL_DIR"); dir != "" {
+        checks = append(checks, preflight.Writable("wal", dir))
+    }
+    if dir := os.Getenv("PLATYPUS_MEMORY_SPILL_DIR"); dir != "" {
+        checks = append(checks, preflight.Writable("memory spill", dir))
+    }
+    if path := os.Getenv("PLATYPUS_SNAPSHOT_PATH"); path != "" {
+        checks = append(checks, preflight.Writable("snapshot", filepath.Dir(path)))
+    }
+    if value := os.Getenv("PLATYPUS_METRIC_LABELS"); value != "" {
+        checks = append(checks, preflight.MetricLabels(value))
+    }
+    for _, name := range []string{"PLATYPUS_POWER_BUCKETS", "PLATYPUS_CPU_BUCKETS"} {
+        if value := os.Getenv(name); value != "" {
+            checks = append(checks, preflight.HistogramBuckets(name, value))
+        }
+    }
+    if value := os.Getenv("PLATYPUS_METRIC_RETENTION"); value != "" {
+        checks = append(checks, preflight.MetricRetention(value))
+    }
+    if value := os.Getenv("PLATYPUS_ECO_SCORE_WEIGHTS"); value != "" {
+        checks = append(checks, preflight.ScoreWeights(value))
+    }
+    if value := os.Getenv("PLATYPUS_ANOMALY_METHOD"); value != "" {
+        checks = append(checks, preflight.AnomalyMethod(value))
+    }
+    if path := os.Getenv("PLATYPUS_ECO_SCORE_HISTORY"); path != "" {
+        checks = append(checks, preflight.Writable("eco-score history", filepath.Dir(path)))
+    }
+    if path := os.Getenv("PLATYPUS_HARDWARE_BASELINES"); path != "" {
+        checks = append(checks, preflight.Writable("hardware baselines", filepath.Dir(path)))
+    }
+    if path := os.Getenv("PLATYPUS_ANNOTATIONS"); path != "" {
+        checks = append(checks, preflight.Writable("annotations", filepath.Dir(path)))
+    }
+    if path := os.Getenv("PLATYPUS_SERVER_METADATA"); path != "" {
+        checks = append(checks, preflight.ServerMetadata(path))
+    }
+    if path := os.Getenv("PLATYPUS_RESERVATIONS"); path != "" {
+        checks = append(checks, preflight.Reservations(path))
+    }
+    if path := os.Getenv("PLATYPUS_OBJECTIVE_WEIGHTS"); path != "" {
+        checks = append(checks, preflight.ObjectiveWeights(path))
+    }
+    if path := os.Getenv("PLATYPUS_ENCRYPTION_KEYS_FILE"); path != "" {
+        checks = append(checks, preflight.EncryptionKeys(encryption.FileKeys(path)))
+    } else if os.Getenv("PLATYPUS_ENCRYPTION_KEYS") != "" {
+        checks = append(checks, preflight.EncryptionKeys(encryption.EnvKeys("PLATYPUS_ENCRYPTION_KEYS")))
+    }
+    if path := os.Getenv("PLATYPUS_ECO_SLOS"); path != "" {
+        checks = append(checks, preflight.EcoSLOs(path))
+    }
+    if path := os.Getenv("PLATYPUS_PEAK_HOURS"); path != "" {
+        checks = append(checks, preflight.PeakHours(path))
+    }
+    if path := os.Getenv("PLATYPUS_CARBON_FORECAST"); path != "" {
+        checks = append(checks, preflight.CarbonForecast(path))
+    } else if os.Getenv("PLATYPUS_ELECTRICITYMAPS_TOKEN") != "" {
+        checks = append(checks, preflight.ElectricityMapsZones(os.Getenv("PLATYPUS_ELECTRICITYMAPS_ZONES")))
+    }
+    if path := os.Getenv("PLATYPUS_CALENDAR_WINDOWS"); path != "" {
+        checks = append(checks, preflight.CalendarWindows(path))
+    }
+    if os.Getenv("PLATYPUS_MQTT_BROKER") != "" {
+        checks = append(checks, preflight.MQTTMappings(os.Getenv("PLATYPUS_MQTT_MAPPINGS")))
+    }
+    if os.Getenv("PLATYPUS_STATSD_ADDR") != "" {
+        checks = append(checks, preflight.StatsDMappings(os.Getenv("PLATYPUS_STATSD_MAPPINGS")))
+    }
+    if path := os.Getenv("PLATYPUS_SCRAPE_TARGETS"); path != "" {
+        checks = append(checks, preflight.ScrapeTargets(path))
+    }
+
+    // В автономном режиме исходящие соединения не используются
+    if airgapConfig.Enabled {
+        return checks
+    }
+    if federation.Mode(os.Getenv("PLATYPUS_FEDERATION_MODE")) == federation.ModeEdge {
+        checks = append(checks, preflight.Endpoint("central", os.Getenv("PLATYPUS_CENTRAL_URL"), 5*time.Second))
+    }
+    if replication.Mode(os.Getenv("PLATYPUS_REPLICATION_MODE")) == replication.ModePrimary {
+        checks = append(checks, preflight.Endpoint("standby", os.Getenv("PLATYPUS_STANDBY_URL"), 5*time.Second))
+    }
+    if url := os.Getenv("PLATYPUS_REMOTE_WRITE_URL"); url != "" {
+        checks = append(checks, preflight.Endpoint("remote write", url, 5*time.Second))
+    }
+    if url := otlpEndpoint(); url != "" {
+        checks = append(checks, preflight.Endpoint("otlp", url, 5*time.Second))
+    }
+    if url := os.Getenv("PLATYPUS_REPORT_SUMMARY_URL"); url != "" {
+        checks = append(checks, preflight.Endpoint("report summary", url, 5*time.Second))
+    }
+    return checks
+}
@@ -0,0 +1,53 @@
+// Package airgap описывает автономный режим работы без исходящих соединений
+// и возможности платформы, которые в нем деградируют
+package airgap
+
+type Config struct {
+	Enabled           bool
+	InventoryPath     string // JSON-файл с инвентарем серверов
+	CarbonDatasetPath string // Необязательный файл, заменяющий встроенный набор данных
+	ModelPath         string // Локальный каталог для моделей предиктора
+}
+
+// Capability - состояние одной возможности платформы
+type Capability struct {
+	Name      string `json:"name"`
+	Available bool   `json:"available"`
+	Degraded  bool   `json:"degraded"`
+	Note      string `json:"note,omitempty"`
+}
+
+// Status - раздел /status, описывающий режим работы
+type Status struct {
+	Mode         string       `json:"mode"` // online | offline
+	Capabilities []Capability `json:"capabilities"`
+}
+
+// Report возвращает список возможностей с учетом режима работы
+func Report(config Config, carbonDatasetVersion string) Status {
+	if !config.Enabled {
+		return Status{
+			Mode: "online",
+			Capabilities: []Capability{
+				{Name: "cloud_provider_api", Available: true},
+				{Name: "carbon_intensity", Available: true, Note: "dataset " + carbonDatasetVersion},
+				{Name: "container_migration", Available: true},
+				{Name: "federation", Available: true},
+				{Name: "metrics_ingestion", Available: true},
+				{Name: "prediction", Available: true},
+			},
+		}
+	}
+
+	return Status{
+		Mode: "offline",
+		Capabilities: []Capability{
+			{Name: "cloud_provider_api", Available: false, Note: "inventory is read from " + config.InventoryPath},
+			{Name: "carbon_intensity", Available: true, Degraded: true, Note: "static dataset " + carbonDatasetVersion + ", no live grid data"},
+			{Name: "container_migration", Available: false, Note: "requires provider API"},
+			{Name: "federation", Available: false, Note: "outbound reporting is disabled"},
+			{Name: "metrics_ingestion", Available: true, Note: "agent push only"},
+			{Name: "prediction", Available: true, Note: "models stored in " + config.ModelPath},
+		},
+	}
+}
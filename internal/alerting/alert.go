@@ -0,0 +1,329 @@
+// Package alerting доставляет оповещения подсистем платформы
+// (бюджеты, аномалии) в каналы уведомлений
+package alerting
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/YumeNoTenshi/platypus/internal/requestid"
+)
+
+type Severity string
+
+const (
+	SeverityInfo     Severity = "info"
+	SeverityWarning  Severity = "warning"
+	SeverityCritical Severity = "critical"
+)
+
+type Alert struct {
+	Key        string            `json:"key"`    // Идентичность оповещения: повторы с тем же ключом - одно событие
+	Source     string            `json:"source"` // Подсистема-источник, например budgets
+	Severity   Severity          `json:"severity"`
+	Title      string            `json:"title"`
+	Message    string            `json:"message"`
+	Labels     map[string]string `json:"labels,omitempty"`
+	Timestamp  time.Time         `json:"timestamp"`
+	Count      int               `json:"count,omitempty"`       // Сколько повторов свернуто в это уведомление
+	Suppressed int               `json:"suppressed,omitempty"`  // Сколько уведомлений канал пропустил из-за лимита до этого
+	SilencedBy string            `json:"silenced_by,omitempty"` // ID тишины, подавившей оповещение
+}
+
+// Channel - канал доставки уведомлений
+type Channel interface {
+	Name() string
+	Send(ctx context.Context, alert Alert) error
+}
+
+// RateLimit ограничивает число уведомлений канала: не более Max за Per
+type RateLimit struct {
+	Max int           `json:"max"`
+	Per time.Duration `json:"per"`
+}
+
+type DispatcherConfig struct {
+	DedupWindow   time.Duration        // Повторы с тем же ключом внутри окна не рассылаются повторно
+	Limits        map[string]RateLimit // Имя канала -> лимит; каналы без лимита не ограничиваются
+	FlushInterval time.Duration        // Как часто рассылать сводки о пропущенных уведомлениях
+}
+
+// ChannelStats - счетчики доставки канала
+type ChannelStats struct {
+	Sent        int `json:"sent"`
+	RateLimited int `json:"rate_limited"`
+	Failed      int `json:"failed"`
+}
+
+// maxRecent - сколько последних оповещений хранится для API
+const maxRecent = 100
+
+type dedupState struct {
+	sentAt  time.Time
+	repeats int   // Повторы после последней рассылки
+	last    Alert // Последний свернутый повтор
+}
+
+type channelState struct {
+	channel Channel
+	sent    []time.Time // Время отправок внутри окна лимита
+	dropped int         // Пропущено из-за лимита с последней доставки
+	stats   ChannelStats
+}
+
+// Dispatcher рассылает оповещения во все каналы. Повторы одного оповещения
+// сворачиваются в счетчик для всех каналов сразу, а лимиты применяются к
+// каждому каналу отдельно, чтобы шторм оповещений не забивал уведомления.
+type Dispatcher struct {
+	config       DispatcherConfig
+	mu           sync.Mutex
+	channels     []*channelState
+	seen         map[string]*dedupState
+	deduplicated int
+	recent       []Alert
+	silences     *Silences
+}
+
+func NewDispatcher(config DispatcherConfig, channels ...Channel) *Dispatcher {
+	d := &Dispatcher{
+		config:   config,
+		seen:     make(map[string]*dedupState),
+		silences: NewSilences(),
+	}
+	for _, channel := range channels {
+		d.channels = append(d.channels, &channelState{channel: channel})
+	}
+	return d
+}
+
+func (d *Dispatcher) AddChannel(channel Channel) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.channels = append(d.channels, &channelState{channel: channel})
+}
+
+// Silences возвращает правила тишины диспетчера
+func (d *Dispatcher) Silences() *Silences {
+	return d.silences
+}
+
+// Start периодически рассылает сводки о пропущенных уведомлениях
+// и забывает истекшие ключи дедупликации
+func (d *Dispatcher) Start(ctx context.Context) error {
+	ticker := time.NewTicker(d.config.FlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			d.flush(ctx)
+		}
+	}
+}
+
+// Notify отправляет оповещение во все каналы, если его не подавляет
+// тишина. Ошибка одного канала не мешает доставке в остальные.
+func (d *Dispatcher) Notify(ctx context.Context, alert Alert) error {
+	now := time.Now()
+	if alert.Timestamp.IsZero() {
+		alert.Timestamp = now
+	}
+	// Оповещение, вызванное запросом API, несет его идентификатор
+	if id := requestid.FromContext(ctx); id != "" {
+		labels := make(map[string]string, len(alert.Labels)+1)
+		for name, value := range alert.Labels {
+			labels[name] = value
+		}
+		labels["request_id"] = id
+		alert.Labels = labels
+	}
+	if id, silenced := d.silences.Match(alert, now); silenced {
+		alert.SilencedBy = id
+	}
+
+	d.mu.Lock()
+	d.recent = append(d.recent, alert)
+	if len(d.recent) > maxRecent {
+		d.recent = d.recent[len(d.recent)-maxRecent:]
+	}
+	if alert.SilencedBy != "" {
+		// Подавленное оповещение остается в истории, но не рассылается
+		d.mu.Unlock()
+		return nil
+	}
+
+	var collapsed *Alert
+	if alert.Key != "" {
+		state, exists := d.seen[alert.Key]
+		if exists && now.Sub(state.sentAt) < d.config.DedupWindow {
+			state.repeats++
+			state.last = alert
+			d.deduplicated++
+			d.mu.Unlock()
+			return nil
+		}
+		// Ключ истек, но flush до него еще не дошел: накопленные повторы
+		// рассылаются сейчас, иначе новое состояние их затрет
+		if exists && state.repeats > 0 {
+			pending := state.last
+			pending.Count = state.repeats
+			collapsed = &pending
+		}
+		d.seen[alert.Key] = &dedupState{sentAt: now}
+	}
+	d.mu.Unlock()
+
+	if collapsed != nil {
+		if err := d.deliver(ctx, *collapsed, now); err != nil {
+			return errors.Join(err, d.deliver(ctx, alert, now))
+		}
+	}
+	return d.deliver(ctx, alert, now)
+}
+
+// deliver отправляет оповещение во все каналы с учетом их лимитов
+func (d *Dispatcher) deliver(ctx context.Context, alert Alert, now time.Time) error {
+	type delivery struct {
+		state *channelState
+		alert Alert
+	}
+
+	d.mu.Lock()
+	var deliveries []delivery
+	for _, state := range d.channels {
+		if !d.allowLocked(state, now) {
+			state.dropped++
+			state.stats.RateLimited++
+			continue
+		}
+		item := delivery{state: state, alert: alert}
+		item.alert.Suppressed = state.dropped
+		state.dropped = 0
+		deliveries = append(deliveries, item)
+	}
+	d.mu.Unlock()
+
+	var errs []error
+	for _, item := range deliveries {
+		err := item.state.channel.Send(ctx, item.alert)
+		d.mu.Lock()
+		if err != nil {
+			item.state.stats.Failed++
+			errs = append(errs, fmt.Errorf("%s: %w", item.state.channel.Name(), err))
+		} else {
+			item.state.stats.Sent++
+		}
+		d.mu.Unlock()
+	}
+	return errors.Join(errs...)
+}
+
+// allowLocked проверяет лимит канала и резервирует отправку
+func (d *Dispatcher) allowLocked(state *channelState, now time.Time) bool {
+	limit, exists := d.config.Limits[state.channel.Name()]
+	if !exists || limit.Max <= 0 {
+		return true
+	}
+
+	recent := state.sent[:0]
+	for _, at := range state.sent {
+		if now.Sub(at) < limit.Per {
+			recent = append(recent, at)
+		}
+	}
+	state.sent = recent
+
+	if len(state.sent) >= limit.Max {
+		return false
+	}
+	state.sent = append(state.sent, now)
+	return true
+}
+
+func (d *Dispatcher) flush(ctx context.Context) {
+	now := time.Now()
+
+	// Истекшие ключи забываются; накопленные повторы рассылаются одним уведомлением
+	d.mu.Lock()
+	var collapsed []Alert
+	for key, state := range d.seen {
+		if now.Sub(state.sentAt) < d.config.DedupWindow {
+			continue
+		}
+		if state.repeats > 0 {
+			alert := state.last
+			alert.Count = state.repeats
+			collapsed = append(collapsed, alert)
+		}
+		delete(d.seen, key)
+	}
+
+	type digest struct {
+		channel Channel
+		dropped int
+	}
+	var digests []digest
+	for _, state := range d.channels {
+		if state.dropped == 0 || !d.allowLocked(state, now) {
+			continue
+		}
+		digests = append(digests, digest{channel: state.channel, dropped: state.dropped})
+		state.dropped = 0
+	}
+	d.mu.Unlock()
+
+	for _, alert := range collapsed {
+		d.deliver(ctx, alert, now)
+	}
+
+	for _, item := range digests {
+		item.channel.Send(ctx, Alert{
+			Source:     "alerting",
+			Severity:   SeverityInfo,
+			Title:      "Notifications suppressed",
+			Message:    fmt.Sprintf("%d notifications were suppressed by the rate limit of channel %s", item.dropped, item.channel.Name()),
+			Timestamp:  now,
+			Suppressed: item.dropped,
+		})
+	}
+}
+
+// Recent возвращает последние оповещения (включая свернутые повторы), от новых к старым
+func (d *Dispatcher) Recent() []Alert {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	alerts := make([]Alert, 0, len(d.recent))
+	for i := len(d.recent) - 1; i >= 0; i-- {
+		alerts = append(alerts, d.recent[i])
+	}
+	return alerts
+}
+
+// Stats возвращает счетчики доставки по каналам и число свернутых повторов
+func (d *Dispatcher) Stats() (map[string]ChannelStats, int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	stats := make(map[string]ChannelStats, len(d.channels))
+	for _, state := range d.channels {
+		stats[state.channel.Name()] = state.stats
+	}
+	return stats, d.deduplicated
+}
+
+// LogChannel пишет оповещения в журнал сервера
+type LogChannel struct{}
+
+func (LogChannel) Name() string { return "log" }
+
+func (LogChannel) Send(ctx context.Context, alert Alert) error {
+	log.Printf("Оповещение [%s] %s: %s", alert.Severity, alert.Title, alert.Message)
+	return nil
+}
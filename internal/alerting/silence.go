@@ -0,0 +1,177 @@
+package alerting
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/YumeNoTenshi/platypus/pkg/schedule"
+)
+
+// maxSilenceDuration ограничивает окно повторяющейся тишины
+const maxSilenceDuration = 7 * 24 * time.Hour
+
+// Recurrence - повторяющееся окно тишины: начинается по cron-расписанию
+// и длится Duration, например "0 22 * * *" и 8h - каждую ночь 22:00-06:00
+type Recurrence struct {
+	Schedule string        `json:"schedule"`
+	Duration time.Duration `json:"duration"`           // В JSON - наносекунды
+	Timezone string        `json:"timezone,omitempty"` // IANA, по умолчанию UTC
+
+	parsed   schedule.Schedule
+	location *time.Location
+}
+
+// Silence подавляет оповещения, у которых совпадают все Matchers. Ключи
+// сопоставляются с метками оповещения, а также с полями source и severity.
+// Тишина либо разовая (StartsAt/EndsAt), либо повторяющаяся (Recurrence).
+type Silence struct {
+	ID         string            `json:"id"`
+	Comment    string            `json:"comment,omitempty"`
+	Matchers   map[string]string `json:"matchers"`
+	StartsAt   *time.Time        `json:"starts_at,omitempty"`
+	EndsAt     *time.Time        `json:"ends_at,omitempty"`
+	Recurrence *Recurrence       `json:"recurrence,omitempty"`
+	CreatedAt  time.Time         `json:"created_at"`
+}
+
+// Active сообщает, действует ли тишина в момент t
+func (s Silence) Active(t time.Time) bool {
+	if s.Recurrence != nil {
+		return s.Recurrence.parsed.ActiveWithin(t.In(s.Recurrence.location), s.Recurrence.Duration)
+	}
+	if s.StartsAt != nil && t.Before(*s.StartsAt) {
+		return false
+	}
+	return s.EndsAt == nil || t.Before(*s.EndsAt)
+}
+
+// Expired сообщает, что разовая тишина закончилась и больше не сработает
+func (s Silence) Expired(t time.Time) bool {
+	return s.Recurrence == nil && s.EndsAt != nil && !t.Before(*s.EndsAt)
+}
+
+func (s Silence) matches(alert Alert) bool {
+	for key, value := range s.Matchers {
+		var actual string
+		switch key {
+		case "source":
+			actual = alert.Source
+		case "severity":
+			actual = string(alert.Severity)
+		default:
+			actual = alert.Labels[key]
+		}
+		if actual != value {
+			return false
+		}
+	}
+	return true
+}
+
+// Silences хранит правила тишины
+type Silences struct {
+	mu       sync.RWMutex
+	silences map[string]*Silence
+}
+
+func NewSilences() *Silences {
+	return &Silences{silences: make(map[string]*Silence)}
+}
+
+// Add проверяет и сохраняет правило тишины
+func (s *Silences) Add(silence Silence) (Silence, error) {
+	if len(silence.Matchers) == 0 {
+		return Silence{}, fmt.Errorf("at least one matcher is required")
+	}
+
+	if r := silence.Recurrence; r != nil {
+		if silence.StartsAt != nil || silence.EndsAt != nil {
+			return Silence{}, fmt.Errorf("recurring silence cannot have starts_at or ends_at")
+		}
+		parsed, err := schedule.Parse(r.Schedule)
+		if err != nil {
+			return Silence{}, err
+		}
+		if r.Duration <= 0 || r.Duration > maxSilenceDuration {
+			return Silence{}, fmt.Errorf("recurrence duration must be between 0 and %s", maxSilenceDuration)
+		}
+		location := time.UTC
+		if r.Timezone != "" {
+			if location, err = time.LoadLocation(r.Timezone); err != nil {
+				return Silence{}, fmt.Errorf("invalid timezone: %s", r.Timezone)
+			}
+		}
+		r.parsed = parsed
+		r.location = location
+	} else {
+		if silence.EndsAt == nil {
+			return Silence{}, fmt.Errorf("ends_at or recurrence is required")
+		}
+		if silence.StartsAt != nil && !silence.EndsAt.After(*silence.StartsAt) {
+			return Silence{}, fmt.Errorf("ends_at must be after starts_at")
+		}
+	}
+
+	silence.ID = newID()
+	silence.CreatedAt = time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.silences[silence.ID] = &silence
+	return silence, nil
+}
+
+func (s *Silences) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.silences[id]; !exists {
+		return fmt.Errorf("silence not found: %s", id)
+	}
+	delete(s.silences, id)
+	return nil
+}
+
+// List возвращает правила тишины; истекшие разовые правила удаляются
+func (s *Silences) List() []Silence {
+	now := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	silences := make([]Silence, 0, len(s.silences))
+	for id, silence := range s.silences {
+		if silence.Expired(now) {
+			delete(s.silences, id)
+			continue
+		}
+		silences = append(silences, *silence)
+	}
+	sort.Slice(silences, func(i, j int) bool {
+		return silences[i].CreatedAt.Before(silences[j].CreatedAt)
+	})
+	return silences
+}
+
+// Match возвращает ID действующей тишины, подавляющей оповещение
+func (s *Silences) Match(alert Alert, t time.Time) (string, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for id, silence := range s.silences {
+		if silence.matches(alert) && silence.Active(t) {
+			return id, true
+		}
+	}
+	return "", false
+}
+
+func newID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
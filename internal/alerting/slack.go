@@ -0,0 +1,59 @@
+package alerting
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// SlackChannel отправляет оповещения через входящий вебхук Slack
+type SlackChannel struct {
+	name       string
+	webhookURL string
+	client     *http.Client
+}
+
+func NewSlackChannel(name, webhookURL string) *SlackChannel {
+	return &SlackChannel{
+		name:       name,
+		webhookURL: webhookURL,
+		client:     &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (s *SlackChannel) Name() string { return s.name }
+
+func (s *SlackChannel) Send(ctx context.Context, alert Alert) error {
+	text := fmt.Sprintf("*[%s] %s*\n%s", alert.Severity, alert.Title, alert.Message)
+	if alert.Count > 0 {
+		text += fmt.Sprintf("\n_Repeated %d more times since the last notification_", alert.Count)
+	}
+	if alert.Suppressed > 0 {
+		text += fmt.Sprintf("\n_%d earlier notifications were suppressed by the rate limit_", alert.Suppressed)
+	}
+
+	body, err := json.Marshal(map[string]string{"text": text})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.webhookURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("slack webhook returned %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,233 @@
+// Package annotations хранит пометки людей на временной шкале метрик
+// сервера или сервиса: нагрузочный тест, отказ кондиционирования, релиз.
+// Пометки возвращаются вместе с точками и попадают в отчеты, чтобы при
+// анализе не приходилось заново выяснять причины известных событий.
+package annotations
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+var ErrNotFound = errors.New("annotation not found")
+
+// maxTextLength - предел длины текста пометки
+const maxTextLength = 4096
+
+// Annotation - пометка интервала [Start, End] на шкале сервера или сервиса.
+// Пометка без End относится к моменту Start.
+type Annotation struct {
+	ID        string    `json:"id"`
+	ServerID  string    `json:"server_id,omitempty"`
+	Service   string    `json:"service,omitempty"`
+	Text      string    `json:"text"`
+	Author    string    `json:"author"`
+	Start     time.Time `json:"start"`
+	End       time.Time `json:"end,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// end возвращает конец интервала пометки
+func (a Annotation) end() time.Time {
+	if a.End.IsZero() {
+		return a.Start
+	}
+	return a.End
+}
+
+func validate(a Annotation) error {
+	if a.ServerID == "" && a.Service == "" {
+		return fmt.Errorf("server_id or service is required")
+	}
+	if a.ServerID != "" && a.Service != "" {
+		return fmt.Errorf("annotation must target either server_id or service, not both")
+	}
+	if a.Text == "" {
+		return fmt.Errorf("text is required")
+	}
+	if len(a.Text) > maxTextLength {
+		return fmt.Errorf("text must not exceed %d bytes", maxTextLength)
+	}
+	if a.Start.IsZero() {
+		return fmt.Errorf("start is required")
+	}
+	if !a.End.IsZero() && a.End.Before(a.Start) {
+		return fmt.Errorf("end must not be before start")
+	}
+	return nil
+}
+
+// Query отбирает пометки. Пустые ServerIDs и Services не ограничивают
+// выборку; нулевые From и To - интервал не ограничен.
+type Query struct {
+	ServerIDs []string
+	Services  []string
+	From, To  time.Time // Пометка подходит, если пересекается с [From, To)
+}
+
+func (q Query) matches(a Annotation) bool {
+	if len(q.ServerIDs) > 0 || len(q.Services) > 0 {
+		if !contains(q.ServerIDs, a.ServerID) && !contains(q.Services, a.Service) {
+			return false
+		}
+	}
+	if !q.To.IsZero() && !a.Start.Before(q.To) {
+		return false
+	}
+	if !q.From.IsZero() && a.end().Before(q.From) {
+		return false
+	}
+	return true
+}
+
+func contains(values []string, value string) bool {
+	if value == "" {
+		return false
+	}
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+type Config struct {
+	Path string // JSON-файл пометок; пусто - пометки живут только в памяти процесса
+}
+
+// Store хранит пометки
+type Store struct {
+	config Config
+
+	mu          sync.RWMutex
+	annotations map[string]Annotation
+
+	saveMu sync.Mutex // Упорядочивает запись файла
+}
+
+// New создает хранилище и загружает сохраненные пометки
+func New(config Config) (*Store, error) {
+	s := &Store{config: config, annotations: make(map[string]Annotation)}
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *Store) Create(a Annotation) (Annotation, error) {
+	if err := validate(a); err != nil {
+		return Annotation{}, err
+	}
+	a.ID = newID()
+	a.CreatedAt = time.Now()
+
+	s.mu.Lock()
+	s.annotations[a.ID] = a
+	s.mu.Unlock()
+	s.save()
+	return a, nil
+}
+
+func (s *Store) Get(id string) (Annotation, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	a, exists := s.annotations[id]
+	if !exists {
+		return Annotation{}, fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+	return a, nil
+}
+
+func (s *Store) Delete(id string) error {
+	s.mu.Lock()
+	if _, exists := s.annotations[id]; !exists {
+		s.mu.Unlock()
+		return fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+	delete(s.annotations, id)
+	s.mu.Unlock()
+	s.save()
+	return nil
+}
+
+// List возвращает подходящие пометки по времени начала
+func (s *Store) List(query Query) []Annotation {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make([]Annotation, 0)
+	for _, a := range s.annotations {
+		if query.matches(a) {
+			result = append(result, a)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if !result[i].Start.Equal(result[j].Start) {
+			return result[i].Start.Before(result[j].Start)
+		}
+		return result[i].ID < result[j].ID
+	})
+	return result
+}
+
+func (s *Store) load() error {
+	if s.config.Path == "" {
+		return nil
+	}
+	data, err := os.ReadFile(s.config.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var saved []Annotation
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return fmt.Errorf("invalid annotations file %s: %w", s.config.Path, err)
+	}
+	for _, a := range saved {
+		s.annotations[a.ID] = a
+	}
+	return nil
+}
+
+// save записывает пометки во временный файл и подменяет им прежний
+func (s *Store) save() {
+	if s.config.Path == "" {
+		return
+	}
+
+	s.saveMu.Lock()
+	defer s.saveMu.Unlock()
+
+	data, err := json.Marshal(s.List(Query{}))
+	if err != nil {
+		log.Printf("Не удалось сохранить пометки: %v", err)
+		return
+	}
+	tmp := s.config.Path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		log.Printf("Не удалось сохранить пометки: %v", err)
+		return
+	}
+	if err := os.Rename(tmp, s.config.Path); err != nil {
+		log.Printf("Не удалось сохранить пометки: %v", err)
+	}
+}
+
+func newID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,96 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/YumeNoTenshi/platypus/internal/alerting"
+)
+
+func (s *Server) requireAlerts(w http.ResponseWriter) bool {
+	if s.alerts == nil {
+		respondWithError(w, http.StatusNotImplemented, "alerting is disabled")
+		return false
+	}
+	return true
+}
+
+func (s *Server) handleGetAlerts(w http.ResponseWriter, r *http.Request) {
+	if !s.requireAlerts(w) {
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"status": "success",
+		"data":   s.alerts.Recent(),
+	})
+}
+
+// handleGetAlertChannels возвращает счетчики доставки по каналам уведомлений
+func (s *Server) handleGetAlertChannels(w http.ResponseWriter, r *http.Request) {
+	if !s.requireAlerts(w) {
+		return
+	}
+
+	channels, deduplicated := s.alerts.Stats()
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"status": "success",
+		"data": map[string]interface{}{
+			"channels":     channels,
+			"deduplicated": deduplicated,
+		},
+	})
+}
+
+func (s *Server) handleListSilences(w http.ResponseWriter, r *http.Request) {
+	if !s.requireAlerts(w) {
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"status": "success",
+		"data":   s.alerts.Silences().List(),
+	})
+}
+
+// handleCreateSilence создает разовую (starts_at/ends_at) или повторяющуюся
+// (recurrence с cron-расписанием и длительностью) тишину
+func (s *Server) handleCreateSilence(w http.ResponseWriter, r *http.Request) {
+	if !s.requireAlerts(w) {
+		return
+	}
+
+	var silence alerting.Silence
+	if err := json.NewDecoder(r.Body).Decode(&silence); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	defer r.Body.Close()
+
+	created, err := s.alerts.Silences().Add(silence)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, map[string]interface{}{
+		"status": "success",
+		"data":   created,
+	})
+}
+
+func (s *Server) handleDeleteSilence(w http.ResponseWriter, r *http.Request) {
+	if !s.requireAlerts(w) {
+		return
+	}
+
+	if err := s.alerts.Silences().Delete(mux.Vars(r)["id"]); err != nil {
+		respondWithError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]string{
+		"status": "success",
+	})
+}
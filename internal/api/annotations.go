@@ -0,0 +1,144 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/YumeNoTenshi/platypus/internal/annotations"
+	"github.com/gorilla/mux"
+)
+
+func (s *Server) requireAnnotations(w http.ResponseWriter) bool {
+	if s.annotations == nil {
+		respondWithError(w, http.StatusNotImplemented, "annotations are disabled")
+		return false
+	}
+	return true
+}
+
+// visibleAnnotations оставляет пометки серверов, доступных ключу; пометки
+// сервисов видны всем
+func (s *Server) visibleAnnotations(r *http.Request, list []annotations.Annotation) []annotations.Annotation {
+	visible := make([]annotations.Annotation, 0, len(list))
+	for _, a := range list {
+		if a.ServerID == "" || s.allowedServer(r, a.ServerID) {
+			visible = append(visible, a)
+		}
+	}
+	return visible
+}
+
+// metricAnnotations возвращает пометки для ответа на запрос точек: серверов
+// выборки и сервиса из селектора меток (service=...)
+func (s *Server) metricAnnotations(r *http.Request, serverIDs []string, service string, from, to time.Time) []annotations.Annotation {
+	if s.annotations == nil {
+		return nil
+	}
+	query := annotations.Query{ServerIDs: serverIDs, From: from, To: to}
+	if service != "" {
+		query.Services = []string{service}
+	}
+	if len(query.ServerIDs) == 0 && len(query.Services) == 0 {
+		return nil
+	}
+	return s.visibleAnnotations(r, s.annotations.List(query))
+}
+
+// handleListAnnotations возвращает пометки: ?server_id=, ?service=,
+// ?window= или ?from=&to= (RFC3339)
+func (s *Server) handleListAnnotations(w http.ResponseWriter, r *http.Request) {
+	if !s.requireAnnotations(w) {
+		return
+	}
+	from, to, _, err := metricsRange(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	query := annotations.Query{From: from, To: to}
+	if serverID := r.URL.Query().Get("server_id"); serverID != "" {
+		query.ServerIDs = []string{serverID}
+	}
+	if service := r.URL.Query().Get("service"); service != "" {
+		query.Services = []string{service}
+	}
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"status": "success",
+		"data":   s.visibleAnnotations(r, s.annotations.List(query)),
+	})
+}
+
+// handleCreateAnnotation добавляет пометку сервера или сервиса. Без author
+// автором считается клиент API.
+func (s *Server) handleCreateAnnotation(w http.ResponseWriter, r *http.Request) {
+	if !s.requireAnnotations(w) {
+		return
+	}
+
+	var annotation annotations.Annotation
+	if err := json.NewDecoder(r.Body).Decode(&annotation); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	defer r.Body.Close()
+
+	if annotation.Author == "" {
+		if principal, ok := PrincipalFromContext(r.Context()); ok && principal != nil {
+			annotation.Author = principal.ID
+		}
+	}
+	created, err := s.annotations.Create(annotation)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	respondWithJSON(w, http.StatusCreated, map[string]interface{}{
+		"status": "success",
+		"data":   created,
+	})
+}
+
+func (s *Server) handleGetAnnotation(w http.ResponseWriter, r *http.Request) {
+	if !s.requireAnnotations(w) {
+		return
+	}
+
+	annotation, err := s.annotations.Get(mux.Vars(r)["id"])
+	if err != nil || (annotation.ServerID != "" && !s.allowedServer(r, annotation.ServerID)) {
+		respondWithError(w, http.StatusNotFound, annotations.ErrNotFound.Error())
+		return
+	}
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"status": "success",
+		"data":   annotation,
+	})
+}
+
+func (s *Server) handleDeleteAnnotation(w http.ResponseWriter, r *http.Request) {
+	if !s.requireAnnotations(w) {
+		return
+	}
+
+	id := mux.Vars(r)["id"]
+	annotation, err := s.annotations.Get(id)
+	if err == nil && annotation.ServerID != "" && !s.allowedServer(r, annotation.ServerID) {
+		err = annotations.ErrNotFound
+	}
+	if err == nil {
+		err = s.annotations.Delete(id)
+	}
+	switch {
+	case errors.Is(err, annotations.ErrNotFound):
+		respondWithError(w, http.StatusNotFound, err.Error())
+		return
+	case err != nil:
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	respondWithJSON(w, http.StatusOK, map[string]string{
+		"status": "success",
+	})
+}
@@ -0,0 +1,111 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// requireAPIKeys проверяет, что управление ключами включено и запрос
+// сделан известным ключом без области: ключ с областью не выпускает ключи
+// шире своей
+func (s *Server) requireAPIKeys(w http.ResponseWriter, r *http.Request) bool {
+	if s.apiKeys == nil {
+		respondWithError(w, http.StatusNotImplemented, "api key management is disabled")
+		return false
+	}
+	principal, _ := PrincipalFromContext(r.Context())
+	if principal == nil || principal.ID == anonymousPrincipal {
+		respondWithError(w, http.StatusForbidden, "api key management requires a configured api key")
+		return false
+	}
+	if principal.Scope != nil {
+		respondWithError(w, http.StatusForbidden, "api key management requires a key without a scope")
+		return false
+	}
+	return true
+}
+
+func (s *Server) handleListAPIKeys(w http.ResponseWriter, r *http.Request) {
+	if !s.requireAPIKeys(w, r) {
+		return
+	}
+
+	keys := s.apiKeys.Keys()
+	if keys == nil {
+		keys = []APIKey{}
+	}
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"status": "success",
+		"data":   keys,
+	})
+}
+
+func (s *Server) handleGetAPIKey(w http.ResponseWriter, r *http.Request) {
+	if !s.requireAPIKeys(w, r) {
+		return
+	}
+
+	id := mux.Vars(r)["id"]
+	key, exists := s.apiKeys.Key(id)
+	if !exists {
+		respondWithError(w, http.StatusNotFound, "api key "+id+" not found")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"status": "success",
+		"data":   key,
+	})
+}
+
+// handleCreateAPIKey выпускает ключ клиенту; ключ есть только в этом ответе
+func (s *Server) handleCreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	if !s.requireAPIKeys(w, r) {
+		return
+	}
+
+	var req APIKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	defer r.Body.Close()
+
+	key, err := s.apiKeys.CreateKey(req.ID, req.Scope)
+	if errors.Is(err, ErrKeyExists) {
+		respondWithError(w, http.StatusConflict, err.Error())
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, map[string]interface{}{
+		"status": "success",
+		"data":   key,
+	})
+}
+
+func (s *Server) handleDeleteAPIKey(w http.ResponseWriter, r *http.Request) {
+	if !s.requireAPIKeys(w, r) {
+		return
+	}
+
+	err := s.apiKeys.DeleteKey(mux.Vars(r)["id"])
+	if errors.Is(err, ErrStaticKey) {
+		respondWithError(w, http.StatusConflict, err.Error())
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]string{
+		"status": "success",
+	})
+}
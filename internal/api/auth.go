@@ -0,0 +1,338 @@
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrNoCredentials возвращается провайдером, если запрос не содержит
+// учетных данных его типа; в цепочке провайдеров это означает "попробуй следующий"
+var ErrNoCredentials = errors.New("no credentials provided")
+
+// ErrInvalidCredentials возвращается, если учетные данные предъявлены, но не прошли проверку
+var ErrInvalidCredentials = errors.New("invalid credentials")
+
+// Principal описывает аутентифицированного клиента API
+type Principal struct {
+	ID         string            `json:"id"`
+	Method     string            `json:"method"` // Механизм аутентификации, например "api_key"
+	Attributes map[string]string `json:"attributes,omitempty"`
+	Scope      *Scope            `json:"scope,omitempty"` // nil - доступ ко всем серверам
+	Tenant     string            `json:"tenant,omitempty"` // Арендатор клиента; пусто - оператор инстанса
+}
+
+// TenantID возвращает арендатора клиента; для nil - арендатор по умолчанию
+func (p *Principal) TenantID() string {
+	if p == nil {
+		return ""
+	}
+	return p.Tenant
+}
+
+// AuthProvider проверяет запрос и возвращает аутентифицированного клиента.
+// Реализации для SSO, LDAP и т.п. подключаются через WithAuthProvider.
+type AuthProvider interface {
+	ValidateRequest(r *http.Request) (*Principal, error)
+}
+
+// anonymousPrincipal - клиент провайдера без настроенных ключей, который
+// принимает любой ключ
+const anonymousPrincipal = "anonymous"
+
+// ErrKeyExists возвращается при создании ключа для клиента, у которого ключ уже есть
+var ErrKeyExists = errors.New("api key already exists")
+
+// ErrStaticKey возвращается при попытке удалить через API ключ из PLATYPUS_API_KEYS
+var ErrStaticKey = errors.New("api key is configured in PLATYPUS_API_KEYS")
+
+// APIKey описывает ключ клиента. Сам ключ (Key) возвращается только при
+// создании; сервер хранит лишь его SHA-256.
+type APIKey struct {
+	ID        string    `json:"id"`
+	Scope     *Scope    `json:"scope,omitempty"`
+	Managed   bool      `json:"managed"` // Создан через API; ключи из PLATYPUS_API_KEYS через API не удаляются
+	CreatedAt time.Time `json:"created_at,omitempty"`
+	Key       string    `json:"key,omitempty"`
+}
+
+// managedKey - ключ, созданный через API
+type managedKey struct {
+	ID        string    `json:"id"`
+	Hash      string    `json:"hash"` // SHA-256 ключа
+	Scope     *Scope    `json:"scope,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// APIKeyProvider аутентифицирует запросы по заголовку X-API-Key
+type APIKeyProvider struct {
+	keys   map[string]string // ключ -> идентификатор клиента
+	scopes map[string]Scope  // идентификатор клиента -> область
+
+	mu      sync.RWMutex
+	managed map[string]managedKey // SHA-256 ключа -> ключ, созданный через API
+	path    string                // Файл ключей, созданных через API; пусто - только в памяти
+	saveMu  sync.Mutex
+}
+
+// NewAPIKeyProvider создает провайдер с набором ключей. Пустой набор
+// сохраняет прежнее поведение: принимается любой непустой ключ.
+func NewAPIKeyProvider(keys map[string]string) *APIKeyProvider {
+	return &APIKeyProvider{keys: keys, managed: make(map[string]managedKey)}
+}
+
+// SetScopes ограничивает клиентов подмножествами серверов; клиенты без
+// области сохраняют доступ ко всему парку. Вызывается до начала обслуживания.
+func (p *APIKeyProvider) SetScopes(scopes map[string]Scope) {
+	p.scopes = scopes
+}
+
+// SetKeyStore загружает ключи, созданные через API, из JSON-файла и
+// сохраняет в него последующие изменения. Вызывается после SetScopes и до
+// начала обслуживания. Выпускать ключи может только администратор, поэтому
+// в PLATYPUS_API_KEYS должен быть ключ без области; с файлом ключей
+// провайдер никогда не принимает любой ключ, даже когда выпущенных не
+// осталось.
+func (p *APIKeyProvider) SetKeyStore(path string) error {
+	admin := false
+	for _, id := range p.keys {
+		if _, scoped := p.scopes[id]; !scoped {
+			admin = true
+			break
+		}
+	}
+	if !admin {
+		return errors.New("api key management requires a key without a scope in PLATYPUS_API_KEYS")
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.path = path
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var saved []managedKey
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return fmt.Errorf("invalid api key file %s: %w", path, err)
+	}
+	for _, key := range saved {
+		p.managed[key.Hash] = key
+	}
+	return nil
+}
+
+func (p *APIKeyProvider) ValidateRequest(r *http.Request) (*Principal, error) {
+	key := r.Header.Get("X-API-Key")
+	if key == "" {
+		return nil, ErrNoCredentials
+	}
+
+	p.mu.RLock()
+	managed, isManaged := p.managed[hashKey(key)]
+	open := len(p.keys) == 0 && len(p.managed) == 0 && p.path == ""
+	p.mu.RUnlock()
+
+	if open {
+		return &Principal{ID: anonymousPrincipal, Method: "api_key"}, nil
+	}
+	if isManaged {
+		principal := &Principal{ID: managed.ID, Method: "api_key", Scope: managed.Scope}
+		if managed.Scope != nil {
+			principal.Tenant = managed.Scope.Tenant
+		}
+		return principal, nil
+	}
+
+	id, exists := p.keys[key]
+	if !exists {
+		return nil, ErrInvalidCredentials
+	}
+	principal := &Principal{ID: id, Method: "api_key"}
+	if scope, scoped := p.scopes[id]; scoped {
+		principal.Scope = &scope
+		principal.Tenant = scope.Tenant
+	}
+	return principal, nil
+}
+
+// Keys возвращает ключи из PLATYPUS_API_KEYS и созданные через API, без самих ключей
+func (p *APIKeyProvider) Keys() []APIKey {
+	seen := make(map[string]bool)
+	var keys []APIKey
+	for _, id := range p.keys {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		key := APIKey{ID: id}
+		if scope, scoped := p.scopes[id]; scoped {
+			key.Scope = &scope
+		}
+		keys = append(keys, key)
+	}
+
+	p.mu.RLock()
+	for _, managed := range p.managed {
+		keys = append(keys, APIKey{ID: managed.ID, Scope: managed.Scope, Managed: true, CreatedAt: managed.CreatedAt})
+	}
+	p.mu.RUnlock()
+
+	sort.Slice(keys, func(i, j int) bool { return keys[i].ID < keys[j].ID })
+	return keys
+}
+
+// Key возвращает ключ клиента id без самого ключа
+func (p *APIKeyProvider) Key(id string) (APIKey, bool) {
+	for _, key := range p.Keys() {
+		if key.ID == id {
+			return key, true
+		}
+	}
+	return APIKey{}, false
+}
+
+// CreateKey выпускает ключ клиенту id. Ключ возвращается один раз: сервер
+// хранит только его SHA-256.
+func (p *APIKeyProvider) CreateKey(id string, scope *Scope) (APIKey, error) {
+	if id == "" {
+		return APIKey{}, fmt.Errorf("api key id is required")
+	}
+	if id == anonymousPrincipal {
+		return APIKey{}, fmt.Errorf("api key id %s is reserved", id)
+	}
+	if scope != nil {
+		if err := scope.validate(); err != nil {
+			return APIKey{}, err
+		}
+	}
+	for _, static := range p.keys {
+		if static == id {
+			return APIKey{}, fmt.Errorf("%w: %s", ErrKeyExists, id)
+		}
+	}
+
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return APIKey{}, err
+	}
+	secret := hex.EncodeToString(b)
+	managed := managedKey{ID: id, Hash: hashKey(secret), Scope: scope, CreatedAt: time.Now().UTC()}
+
+	p.mu.Lock()
+	for _, existing := range p.managed {
+		if existing.ID == id {
+			p.mu.Unlock()
+			return APIKey{}, fmt.Errorf("%w: %s", ErrKeyExists, id)
+		}
+	}
+	p.managed[managed.Hash] = managed
+	p.mu.Unlock()
+	p.save()
+
+	return APIKey{ID: id, Scope: scope, Managed: true, CreatedAt: managed.CreatedAt, Key: secret}, nil
+}
+
+// DeleteKey отзывает ключ клиента id, созданный через API
+func (p *APIKeyProvider) DeleteKey(id string) error {
+	p.mu.Lock()
+	deleted := false
+	for hash, managed := range p.managed {
+		if managed.ID == id {
+			delete(p.managed, hash)
+			deleted = true
+		}
+	}
+	p.mu.Unlock()
+
+	if deleted {
+		p.save()
+		return nil
+	}
+	for _, static := range p.keys {
+		if static == id {
+			return fmt.Errorf("%w: %s", ErrStaticKey, id)
+		}
+	}
+	return fmt.Errorf("api key %s not found", id)
+}
+
+// save записывает ключи, созданные через API, во временный файл и
+// подменяет им прежний
+func (p *APIKeyProvider) save() {
+	p.saveMu.Lock()
+	defer p.saveMu.Unlock()
+
+	p.mu.RLock()
+	path := p.path
+	saved := make([]managedKey, 0, len(p.managed))
+	for _, key := range p.managed {
+		saved = append(saved, key)
+	}
+	p.mu.RUnlock()
+	if path == "" {
+		return
+	}
+
+	sort.Slice(saved, func(i, j int) bool { return saved[i].ID < saved[j].ID })
+	data, err := json.Marshal(saved)
+	if err != nil {
+		log.Printf("Не удалось сохранить ключи API: %v", err)
+		return
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		log.Printf("Не удалось сохранить ключи API: %v", err)
+		return
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		log.Printf("Не удалось сохранить ключи API: %v", err)
+	}
+}
+
+func hashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// ChainAuthProvider опрашивает провайдеров по порядку до первого,
+// распознавшего учетные данные запроса
+type ChainAuthProvider []AuthProvider
+
+func (c ChainAuthProvider) ValidateRequest(r *http.Request) (*Principal, error) {
+	for _, provider := range c {
+		principal, err := provider.ValidateRequest(r)
+		if errors.Is(err, ErrNoCredentials) {
+			continue
+		}
+		return principal, err
+	}
+	return nil, ErrNoCredentials
+}
+
+type principalKey struct{}
+
+// ContextWithPrincipal сохраняет аутентифицированного клиента в контексте
+func ContextWithPrincipal(ctx context.Context, principal *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+// PrincipalFromContext возвращает клиента, аутентифицированного AuthMiddleware
+func PrincipalFromContext(ctx context.Context) (*Principal, bool) {
+	principal, ok := ctx.Value(principalKey{}).(*Principal)
+	return principal, ok
+}
@@ -0,0 +1,286 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/YumeNoTenshi/platypus/internal/metrics"
+	"github.com/YumeNoTenshi/platypus/internal/models"
+)
+
+const (
+	maxBatchBytes  = 32 << 20 // Тело запроса и распакованный поток
+	maxBatchPoints = 100000
+)
+
+// errBodyTooLarge - тело или распакованный поток превышает лимит
+var errBodyTooLarge = errors.New("request body is too large")
+
+// requestBody возвращает тело запроса не длиннее limit байт, распаковывая
+// gzip (Content-Encoding: gzip). Распакованный поток тоже ограничен limit:
+// это защищает от gzip-бомб. Чтение сверх лимита дает errBodyTooLarge.
+func requestBody(w http.ResponseWriter, r *http.Request, limit int64) (io.Reader, func(), error) {
+	var body io.Reader = http.MaxBytesReader(w, r.Body, limit)
+	switch r.Header.Get("Content-Encoding") {
+	case "", "identity":
+		return &limitedBody{reader: body, remaining: limit}, func() {}, nil
+	case "gzip":
+		reader, err := gzip.NewReader(body)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid gzip body: %w", err)
+		}
+		return &limitedBody{reader: reader, remaining: limit}, func() { reader.Close() }, nil
+	default:
+		return nil, nil, errUnsupportedEncoding
+	}
+}
+
+var errUnsupportedEncoding = errors.New("unsupported content encoding")
+
+// limitedBody - io.LimitReader, который сообщает о превышении лимита, а не
+// обрезает поток молча
+type limitedBody struct {
+	reader    io.Reader
+	remaining int64
+}
+
+func (l *limitedBody) Read(p []byte) (int, error) {
+	if l.remaining <= 0 {
+		// Ровно limit байт допустимо, если дальше поток пуст
+		var probe [1]byte
+		n, err := l.reader.Read(probe[:])
+		var tooLarge *http.MaxBytesError
+		if n > 0 || errors.As(err, &tooLarge) {
+			return 0, errBodyTooLarge
+		}
+		return 0, io.EOF
+	}
+	if int64(len(p)) > l.remaining {
+		p = p[:l.remaining]
+	}
+	n, err := l.reader.Read(p)
+	l.remaining -= int64(n)
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		err = errBodyTooLarge
+	}
+	return n, err
+}
+
+// respondBodyError отвечает на ошибку чтения тела запроса
+func respondBodyError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, errBodyTooLarge):
+		respondWithError(w, http.StatusRequestEntityTooLarge, err.Error())
+	case errors.Is(err, errUnsupportedEncoding):
+		respondWithError(w, http.StatusUnsupportedMediaType, err.Error())
+	default:
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+	}
+}
+
+// batchPoint - точка пачки и ее позиция для отчета об ошибках
+type batchPoint struct {
+	position int
+	point    models.MetricData
+}
+
+// decodeBatch читает точки пачки: JSON-массив или NDJSON (по точке на
+// строку). Формат определяется по первому непробельному символу. Позиция
+// точки - индекс в массиве или номер строки NDJSON. Точки, которые не
+// удалось разобрать, попадают в rejected; синтаксическая ошибка массива
+// прерывает чтение.
+func decodeBatch(body io.Reader, rejected map[int]string) ([]batchPoint, error) {
+	reader := bufio.NewReaderSize(body, 64*1024)
+	var points []batchPoint
+	add := func(position int, point models.MetricData) error {
+		if len(points) >= maxBatchPoints {
+			return fmt.Errorf("%w: at most %d points per batch", errBodyTooLarge, maxBatchPoints)
+		}
+		points = append(points, batchPoint{position: position, point: point})
+		return nil
+	}
+
+	first, err := firstNonSpace(reader)
+	if err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if first == '[' {
+		decoder := json.NewDecoder(reader)
+		if _, err := decoder.Token(); err != nil {
+			return nil, err
+		}
+		for index := 0; decoder.More(); index++ {
+			var point models.MetricData
+			if err := decoder.Decode(&point); err != nil {
+				var typeErr *json.UnmarshalTypeError
+				if !errors.As(err, &typeErr) {
+					return nil, err
+				}
+				// Значение неверного типа прочитано целиком: остальные точки доступны
+				rejected[index] = "invalid field " + typeErr.Field
+				continue
+			}
+			if err := add(index, point); err != nil {
+				return nil, err
+			}
+		}
+		if _, err := decoder.Token(); err != nil {
+			return nil, err
+		}
+		return points, nil
+	}
+
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1<<20)
+	line := 0
+	for scanner.Scan() {
+		line++
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var point models.MetricData
+		if err := json.Unmarshal(scanner.Bytes(), &point); err != nil {
+			rejected[line] = "invalid json"
+			continue
+		}
+		if err := add(line, point); err != nil {
+			return nil, err
+		}
+	}
+	return points, scanner.Err()
+}
+
+// firstNonSpace возвращает первый непробельный байт, не извлекая его из reader
+func firstNonSpace(reader *bufio.Reader) (byte, error) {
+	for {
+		b, err := reader.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		if b != ' ' && b != '\t' && b != '\r' && b != '\n' {
+			return b, reader.UnreadByte()
+		}
+	}
+}
+
+// handlePostMetricsBatch принимает пачку точек одного или многих серверов
+// одним запросом: JSON-массив или NDJSON, при необходимости сжатый gzip
+// (Content-Encoding: gzip). Метки времени точек сохраняются; точка без
+// метки получает время приема. Точки каждого сервера ставятся в буфер одним
+// пакетом. Ошибки отдельных точек перечисляются в rejected по позиции (индекс
+// массива или номер строки NDJSON); такие точки повторять не нужно. Если
+// буфер заполнен, точки не принятых серверов тоже попадают в rejected, а
+// ответ содержит Retry-After; если не принято ничего - 503. Ключ с областью
+// или арендатором пишет только свои серверы: точки чужих отклоняются.
+func (s *Server) handlePostMetricsBatch(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	body, closeBody, err := requestBody(w, r, maxBatchBytes)
+	if err != nil {
+		respondBodyError(w, err)
+		return
+	}
+	defer closeBody()
+
+	rejected := make(map[int]string)
+	points, err := decodeBatch(body, rejected)
+	if err != nil {
+		respondBodyError(w, err)
+		return
+	}
+
+	tenantID := requestTenant(r)
+	now := time.Now()
+	servers := make(map[string][]batchPoint)
+	for _, item := range points {
+		point := item.point
+		if point.ServerID == "" {
+			rejected[item.position] = "server_id is required"
+			continue
+		}
+		if err := s.allowsWrite(r, point.ServerID); err != nil {
+			rejected[item.position] = err.Error()
+			continue
+		}
+		if point.Timestamp <= 0 {
+			point.Timestamp = now.Unix()
+		} else if time.Unix(point.Timestamp, 0).After(now.Add(maxEdgeClockSkew)) {
+			rejected[item.position] = "timestamp is in the future"
+			continue
+		}
+		// Арендатор точки определяется ключом, а не телом запроса
+		point.TenantID = tenantID
+		servers[point.ServerID] = append(servers[point.ServerID], batchPoint{position: item.position, point: point})
+	}
+
+	serverIDs := make([]string, 0, len(servers))
+	for serverID := range servers {
+		serverIDs = append(serverIDs, serverID)
+	}
+	sort.Strings(serverIDs)
+
+	accepted, bufferFull := 0, false
+	for _, serverID := range serverIDs {
+		items := servers[serverID]
+		reject := func(message string) {
+			for _, item := range items {
+				rejected[item.position] = message
+			}
+		}
+		if bufferFull {
+			reject(metrics.ErrBufferFull.Error())
+			continue
+		}
+
+		data := make([]models.MetricData, len(items))
+		for i, item := range items {
+			data[i] = item.point
+		}
+		source := r.Header.Get("X-Metrics-Source")
+		if source == "" {
+			source = serverID
+		}
+		n, invalid, err := s.collector.CollectBatchFrom(source, serverID, data)
+		if errors.Is(err, metrics.ErrBufferFull) {
+			bufferFull = true
+			reject(err.Error())
+			continue
+		}
+		if err != nil {
+			reject(err.Error())
+			continue
+		}
+		for i, pointErr := range invalid {
+			rejected[items[i].position] = pointErr.Error()
+		}
+		accepted += n
+	}
+
+	if bufferFull {
+		w.Header().Set("Retry-After", "30")
+		if accepted == 0 {
+			respondWithError(w, http.StatusServiceUnavailable, metrics.ErrBufferFull.Error())
+			return
+		}
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"status": "success",
+		"data": map[string]interface{}{
+			"accepted": accepted,
+			"rejected": rejected,
+		},
+	})
+}
@@ -0,0 +1,110 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/YumeNoTenshi/platypus/internal/metrics"
+	"github.com/YumeNoTenshi/platypus/internal/models"
+)
+
+// Ключ с областью и ключ арендатора пишут пачкой только свои серверы:
+// точки чужих серверов отклоняются по позиции, остальные принимаются
+func TestPostMetricsBatchScopedKeys(t *testing.T) {
+	collector := metrics.NewCollector(metrics.CollectorConfig{
+		RetentionPeriod:    time.Hour,
+		CollectionInterval: time.Minute,
+		BufferSize:         100,
+	})
+	// globex-1 уже закреплен за другим арендатором
+	if err := collector.CollectMetrics("globex-1", models.MetricData{TenantID: "globex", Timestamp: time.Now().Unix()}); err != nil {
+		t.Fatal(err)
+	}
+
+	auth := NewAPIKeyProvider(map[string]string{
+		"admin-key":  "admin",
+		"team-a-key": "team-a-agent",
+		"acme-key":   "acme-agent",
+	})
+	auth.SetScopes(map[string]Scope{
+		"team-a-agent": {Access: AccessWrite, Servers: []string{"team-a-*"}},
+		"acme-agent":   {Tenant: "acme", Access: AccessWrite},
+	})
+	router := NewServer(collector, nil, WithAuthProvider(auth)).Router()
+
+	post := func(key, body string) (int, batchResult) {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/metrics/batch", strings.NewReader(body))
+		req.Header.Set("X-API-Key", key)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+
+		var resp struct {
+			Data batchResult `json:"data"`
+		}
+		json.Unmarshal(rec.Body.Bytes(), &resp)
+		return rec.Code, resp.Data
+	}
+
+	tests := []struct {
+		name     string
+		key      string
+		body     string
+		accepted int
+		rejected []string
+	}{
+		{
+			name:     "unscoped key",
+			key:      "admin-key",
+			body:     `[{"server_id": "team-b-1", "power_usage": 100}]`,
+			accepted: 1,
+		},
+		{
+			name: "scoped key",
+			key:  "team-a-key",
+			body: `[{"server_id": "team-a-1", "power_usage": 100},
+				{"server_id": "team-b-1", "power_usage": 100}]`,
+			accepted: 1,
+			rejected: []string{"1"},
+		},
+		{
+			name: "tenant key",
+			key:  "acme-key",
+			body: `{"server_id": "acme-1", "power_usage": 100}
+				{"server_id": "globex-1", "power_usage": 100}`,
+			accepted: 1,
+			rejected: []string{"2"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, result := post(tt.key, tt.body)
+			if code != http.StatusOK {
+				t.Fatalf("status = %d, want 200", code)
+			}
+			if result.Accepted != tt.accepted {
+				t.Errorf("accepted = %d, want %d (rejected %v)", result.Accepted, tt.accepted, result.Rejected)
+			}
+			if len(result.Rejected) != len(tt.rejected) {
+				t.Fatalf("rejected = %v, want positions %v", result.Rejected, tt.rejected)
+			}
+			for _, position := range tt.rejected {
+				if !strings.Contains(result.Rejected[position], ErrOutOfScope.Error()) {
+					t.Errorf("rejected[%s] = %q, want out of scope", position, result.Rejected[position])
+				}
+			}
+		})
+	}
+
+	if owner, _ := collector.ServerTenant("acme-1"); owner != "acme" {
+		t.Errorf("acme-1 tenant = %q, want acme", owner)
+	}
+}
+
+type batchResult struct {
+	Accepted int               `json:"accepted"`
+	Rejected map[string]string `json:"rejected"`
+}
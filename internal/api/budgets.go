@@ -0,0 +1,144 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/YumeNoTenshi/platypus/internal/budgets"
+)
+
+func (s *Server) requireBudgets(w http.ResponseWriter) bool {
+	if s.budgets == nil {
+		respondWithError(w, http.StatusNotImplemented, "carbon budgets are disabled")
+		return false
+	}
+	return true
+}
+
+func (s *Server) handleListBudgets(w http.ResponseWriter, r *http.Request) {
+	if !s.requireBudgets(w) {
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"status": "success",
+		"data":   s.budgets.List(),
+	})
+}
+
+func (s *Server) handleCreateBudget(w http.ResponseWriter, r *http.Request) {
+	if !s.requireBudgets(w) {
+		return
+	}
+
+	var budget budgets.Budget
+	if err := json.NewDecoder(r.Body).Decode(&budget); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	defer r.Body.Close()
+
+	created, err := s.budgets.Create(budget)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, map[string]interface{}{
+		"status": "success",
+		"data":   created,
+	})
+}
+
+func (s *Server) handleGetBudget(w http.ResponseWriter, r *http.Request) {
+	if !s.requireBudgets(w) {
+		return
+	}
+
+	budget, err := s.budgets.Get(mux.Vars(r)["id"])
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"status": "success",
+		"data":   budget,
+	})
+}
+
+func (s *Server) handleUpdateBudget(w http.ResponseWriter, r *http.Request) {
+	if !s.requireBudgets(w) {
+		return
+	}
+
+	var budget budgets.Budget
+	if err := json.NewDecoder(r.Body).Decode(&budget); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	defer r.Body.Close()
+
+	id := mux.Vars(r)["id"]
+	if _, err := s.budgets.Get(id); err != nil {
+		respondWithError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	updated, err := s.budgets.Update(id, budget)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"status": "success",
+		"data":   updated,
+	})
+}
+
+func (s *Server) handleDeleteBudget(w http.ResponseWriter, r *http.Request) {
+	if !s.requireBudgets(w) {
+		return
+	}
+
+	if err := s.budgets.Delete(mux.Vars(r)["id"]); err != nil {
+		respondWithError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]string{
+		"status": "success",
+	})
+}
+
+// handleGetBudgetBurnDown возвращает расход бюджета в текущем месяце и прогноз на конец месяца
+func (s *Server) handleGetBudgetBurnDown(w http.ResponseWriter, r *http.Request) {
+	if !s.requireBudgets(w) {
+		return
+	}
+
+	burn, err := s.budgets.BurnDown(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"status": "success",
+		"data":   burn,
+	})
+}
+
+// handleGetBudgetDashboard возвращает состояние всех бюджетов, начиная с наиболее рискованных
+func (s *Server) handleGetBudgetDashboard(w http.ResponseWriter, r *http.Request) {
+	if !s.requireBudgets(w) {
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"status": "success",
+		"data":   s.budgets.Dashboard(r.Context()),
+	})
+}
@@ -0,0 +1,62 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/YumeNoTenshi/platypus/internal/calendar"
+)
+
+const (
+	// feedKeyParam - параметр адреса с ключом API: календари подписываются
+	// по ссылке и не умеют передавать заголовок X-API-Key. Для ленты стоит
+	// выпускать отдельный ключ только для чтения.
+	feedKeyParam = "key"
+
+	defaultCalendarDays = 14
+	maxCalendarDays     = 90
+)
+
+// WithCalendar включает ленту iCalendar /api/v1/calendar.ics
+func WithCalendar(feed *calendar.Feed) ServerOption {
+	return func(s *Server) {
+		s.calendar = feed
+	}
+}
+
+// feedKeyMiddleware принимает ключ API из параметра key, если заголовок не задан
+func feedKeyMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if key := r.URL.Query().Get(feedKeyParam); key != "" && r.Header.Get("X-API-Key") == "" {
+			r.Header.Set("X-API-Key", key)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// handleGetCalendar отдает ожидаемые события парка на ?days= дней вперед
+// (по умолчанию 14, не больше 90)
+func (s *Server) handleGetCalendar(w http.ResponseWriter, r *http.Request) {
+	if s.calendar == nil {
+		respondWithError(w, http.StatusNotImplemented, "calendar feed is disabled")
+		return
+	}
+
+	days := defaultCalendarDays
+	if value := r.URL.Query().Get("days"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed <= 0 || parsed > maxCalendarDays {
+			respondWithError(w, http.StatusBadRequest, "days must be between 1 and 90")
+			return
+		}
+		days = parsed
+	}
+
+	now := time.Now()
+	events := s.calendar.Events(r.Context(), now, now.AddDate(0, 0, days))
+
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Content-Disposition", `inline; filename="platypus.ics"`)
+	s.calendar.Write(w, events, now)
+}
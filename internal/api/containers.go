@@ -0,0 +1,183 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/YumeNoTenshi/platypus/internal/metrics"
+	"github.com/YumeNoTenshi/platypus/internal/models"
+)
+
+// handleGetContainers возвращает идентификаторы контейнеров, для которых есть
+// метрики. ?server_id= оставляет только контейнеры этого сервера.
+func (s *Server) handleGetContainers(w http.ResponseWriter, r *http.Request) {
+	all := s.collector.ContainerIDs(r.URL.Query().Get("server_id"))
+
+	// Ключ с областью видит только контейнеры своих серверов
+	ids := make([]string, 0, len(all))
+	for _, containerID := range all {
+		serverID, _ := s.collector.ContainerServerID(containerID)
+		if s.allowedServer(r, serverID) {
+			ids = append(ids, containerID)
+		}
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"status": "success",
+		"data":   ids,
+	})
+}
+
+// handleGetContainerMetrics возвращает точки контейнера; ?window= и ?from=&to=
+// работают так же, как для /metrics
+func (s *Server) handleGetContainerMetrics(w http.ResponseWriter, r *http.Request) {
+	containerID := mux.Vars(r)["id"]
+
+	from, to, ranged, err := metricsRange(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var data []models.MetricData
+	if ranged {
+		data, err = s.collector.GetContainerMetricsRange(containerID, from, to)
+	} else {
+		data, err = s.collector.GetContainerMetrics(containerID)
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, MetricResponse{
+		Status: "success",
+		Data:   data,
+	})
+}
+
+// handlePostContainerMetrics принимает точку контейнера. server_id в теле -
+// сервер, на котором работает контейнер.
+func (s *Server) handlePostContainerMetrics(w http.ResponseWriter, r *http.Request) {
+	var metricData models.MetricData
+	if err := json.NewDecoder(r.Body).Decode(&metricData); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	defer r.Body.Close()
+
+	if metricData.ServerID == "" {
+		respondWithError(w, http.StatusBadRequest, "server_id is required")
+		return
+	}
+	metricData.Timestamp = time.Now().Unix()
+	metricData.TenantID = requestTenant(r)
+
+	source := r.Header.Get("X-Metrics-Source")
+	if source == "" {
+		source = metricData.ServerID
+	}
+
+	if err := s.collector.CollectContainerMetrics(source, mux.Vars(r)["id"], metricData); err != nil {
+		if errors.Is(err, metrics.ErrInvalidLabels) {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if errors.Is(err, metrics.ErrTenantMismatch) {
+			respondWithError(w, http.StatusForbidden, err.Error())
+			return
+		}
+		var unitErr *metrics.UnitError
+		if errors.As(err, &unitErr) {
+			respondWithJSON(w, http.StatusUnprocessableEntity, map[string]interface{}{
+				"status":      "error",
+				"message":     unitErr.Error(),
+				"diagnostics": unitErr.Diagnostics,
+			})
+			return
+		}
+		var validationErr *metrics.ValidationError
+		if errors.As(err, &validationErr) {
+			respondWithJSON(w, http.StatusUnprocessableEntity, map[string]interface{}{
+				"status":  "error",
+				"message": validationErr.Error(),
+				"errors":  validationErr.Violations,
+			})
+			return
+		}
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, map[string]string{
+		"status":  "success",
+		"message": "Container metrics collected successfully",
+	})
+}
+
+// handlePostContainerEvent принимает событие жизненного цикла контейнера:
+// {"container_id", "type": "start"|"stop"|"reschedule", "server_id",
+// "previous_server_id", "service", "image", "reason", "timestamp"}.
+// Оркестратор или агент сообщает о запусках и остановках, чтобы энергия
+// сервера не делилась между уже остановленными контейнерами.
+func (s *Server) handlePostContainerEvent(w http.ResponseWriter, r *http.Request) {
+	var event metrics.ContainerEvent
+	if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	defer r.Body.Close()
+	event.TenantID = requestTenant(r)
+
+	recorded, err := s.collector.RecordContainerEvent(event)
+	if err != nil {
+		switch {
+		case errors.Is(err, metrics.ErrInvalidContainerEvent):
+			respondWithError(w, http.StatusBadRequest, err.Error())
+		case errors.Is(err, metrics.ErrTenantMismatch):
+			respondWithError(w, http.StatusForbidden, err.Error())
+		default:
+			respondWithError(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, map[string]interface{}{
+		"status": "success",
+		"data":   recorded,
+	})
+}
+
+// handleGetContainerEvents возвращает события контейнеров в порядке времени;
+// ?server_id=, ?container_id= и ?window= или ?from=&to= сужают выборку
+func (s *Server) handleGetContainerEvents(w http.ResponseWriter, r *http.Request) {
+	from, to, _, err := metricsRange(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	query := r.URL.Query()
+	all := s.collector.ContainerEvents(metrics.ContainerEventFilter{
+		ServerID:    query.Get("server_id"),
+		ContainerID: query.Get("container_id"),
+		From:        from,
+		To:          to,
+	})
+
+	// Ключ с областью видит только события своих серверов
+	events := make([]metrics.ContainerEvent, 0, len(all))
+	for _, event := range all {
+		if s.allowedServer(r, event.ServerID) {
+			events = append(events, event)
+		}
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"status": "success",
+		"data":   events,
+	})
+}
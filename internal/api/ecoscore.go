@@ -0,0 +1,111 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/YumeNoTenshi/platypus/internal/ecoscore"
+	"github.com/YumeNoTenshi/platypus/internal/metrics"
+	"github.com/gorilla/mux"
+)
+
+func (s *Server) requireScoreHistory(w http.ResponseWriter) bool {
+	if s.scoreHistory == nil {
+		respondWithError(w, http.StatusNotImplemented, "eco-score history is disabled")
+		return false
+	}
+	return true
+}
+
+// handleGetEcoScoreHistory возвращает суточный ряд эко-рейтинга и углеродного
+// следа сервера. Параметры: version (по умолчанию текущая методика), window
+// (например, 720h).
+func (s *Server) handleGetEcoScoreHistory(w http.ResponseWriter, r *http.Request) {
+	if !s.requireScoreHistory(w) {
+		return
+	}
+
+	from, to, err := queryWindow(r, 30*24*time.Hour)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid window: "+err.Error())
+		return
+	}
+
+	version := r.URL.Query().Get("version")
+	if version == "" {
+		version = s.scoreHistory.Current().Version()
+	}
+	points, err := s.scoreHistory.Series(version, mux.Vars(r)["server_id"], from, to)
+	if errors.Is(err, ecoscore.ErrUnknownVersion) {
+		respondWithError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"status": "success",
+		"data": map[string]interface{}{
+			"version": version,
+			"points":  points,
+		},
+	})
+}
+
+// handleGetEcoScoreMethodologies возвращает версии методики с рядами и
+// последние задания пересчета
+func (s *Server) handleGetEcoScoreMethodologies(w http.ResponseWriter, r *http.Request) {
+	if !s.requireScoreHistory(w) {
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"status": "success",
+		"data": map[string]interface{}{
+			"current":  s.scoreHistory.Current().Version(),
+			"versions": s.scoreHistory.Versions(),
+			"jobs":     s.scoreHistory.BackfillJobs(),
+		},
+	})
+}
+
+// handleStartEcoScoreBackfill запускает пересчет истории по методике с
+// указанными весами, например чтобы сравнить ряды до смены методики.
+// Без весов история пересчитывается по текущей методике.
+func (s *Server) handleStartEcoScoreBackfill(w http.ResponseWriter, r *http.Request) {
+	if !s.requireScoreHistory(w) {
+		return
+	}
+
+	var req struct {
+		Weights       *metrics.ScoreWeights `json:"weights"`
+		CarbonDataset string                `json:"carbon_dataset"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	methodology := s.scoreHistory.Current()
+	if req.Weights != nil {
+		methodology.Weights = *req.Weights
+	}
+	if req.CarbonDataset != "" {
+		methodology.CarbonDataset = req.CarbonDataset
+	}
+
+	job, err := s.scoreHistory.Backfill(methodology)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusAccepted, map[string]interface{}{
+		"status": "success",
+		"data":   job,
+	})
+}
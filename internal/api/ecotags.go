@@ -0,0 +1,178 @@
+package api
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/YumeNoTenshi/platypus/internal/ecotags"
+	"github.com/YumeNoTenshi/platypus/internal/requestid"
+)
+
+func (s *Server) requireTags(w http.ResponseWriter) bool {
+	if s.tags == nil {
+		respondWithError(w, http.StatusNotImplemented, "eco tags are disabled")
+		return false
+	}
+	return true
+}
+
+func (s *Server) handleGetEcoTags(w http.ResponseWriter, r *http.Request) {
+	if !s.requireTags(w) {
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"status": "success",
+		"data":   s.tags.Definitions(),
+	})
+}
+
+func (s *Server) handleGetEcoTagVersion(w http.ResponseWriter, r *http.Request) {
+	if !s.requireTags(w) {
+		return
+	}
+
+	version, err := strconv.Atoi(mux.Vars(r)["version"])
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "version must be an integer")
+		return
+	}
+	definitions, err := s.tags.DefinitionsVersion(version)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"status": "success",
+		"data":   definitions,
+	})
+}
+
+// handlePutEcoTag добавляет или изменяет тег; профили сразу перестраиваются
+// по новой версии определений
+func (s *Server) handlePutEcoTag(w http.ResponseWriter, r *http.Request) {
+	if !s.requireTags(w) {
+		return
+	}
+
+	var tag ecotags.EcoTag
+	if err := json.NewDecoder(r.Body).Decode(&tag); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	defer r.Body.Close()
+
+	tag.Name = mux.Vars(r)["name"]
+	definitions, err := s.tags.PutTag(r.Context(), tag)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	s.respondWithReevaluation(w, r, definitions)
+}
+
+func (s *Server) handleDeleteEcoTag(w http.ResponseWriter, r *http.Request) {
+	if !s.requireTags(w) {
+		return
+	}
+
+	definitions, err := s.tags.DeleteTag(r.Context(), mux.Vars(r)["name"])
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	s.respondWithReevaluation(w, r, definitions)
+}
+
+// respondWithReevaluation перестраивает профили после изменения тегов.
+// Изменение уже сохранено, поэтому ошибка перестройки не отменяет его:
+// профили обновятся при следующем плановом обновлении.
+func (s *Server) respondWithReevaluation(w http.ResponseWriter, r *http.Request, definitions ecotags.Definitions) {
+	updated, err := s.tags.Reevaluate(r.Context())
+	data := map[string]interface{}{
+		"definitions": definitions,
+		"reevaluated": updated,
+	}
+	if err != nil {
+		log.Printf("%sОшибка перестройки эко-профилей после изменения тегов: %v", requestid.Prefix(r.Context()), err)
+		data["reevaluation_error"] = err.Error()
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"status": "success",
+		"data":   data,
+	})
+}
+
+// profileTenant возвращает арендатора, чьи профили сервисов читает запрос:
+// арендатора ключа, а для оператора инстанса - параметр ?tenant=
+func profileTenant(w http.ResponseWriter, r *http.Request) (string, bool) {
+	principal, _ := PrincipalFromContext(r.Context())
+	tenantID := principal.TenantID()
+	if tenantID == "" {
+		// Профиль сервиса охватывает серверы вне области ключа
+		if principal != nil && principal.Scope != nil {
+			respondWithError(w, http.StatusForbidden, ErrOutOfScope.Error()+": key is limited to servers and the request names none")
+			return "", false
+		}
+		tenantID = r.URL.Query().Get("tenant")
+	}
+	return tenantID, true
+}
+
+// handleGetEcoProfileHistory возвращает историю профиля сервиса арендатора
+// ключа. Оператор инстанса выбирает арендатора параметром ?tenant=.
+func (s *Server) handleGetEcoProfileHistory(w http.ResponseWriter, r *http.Request) {
+	if !s.requireTags(w) {
+		return
+	}
+
+	tenantID, ok := profileTenant(w, r)
+	if !ok {
+		return
+	}
+
+	profiles, definitions, err := s.tags.ProfileHistory(tenantID, mux.Vars(r)["service"])
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"status": "success",
+		"data": map[string]interface{}{
+			"profiles":    profiles,
+			"definitions": definitions,
+		},
+	})
+}
+
+// handleGetServiceEcoCheck проверяет сервис по его эко-целям для конвейеров
+// развертывания: pass или fail и текущие показатели в стабильной схеме
+// (schema_version). Ответ 200 при любом итоге; конвейер смотрит data.pass.
+func (s *Server) handleGetServiceEcoCheck(w http.ResponseWriter, r *http.Request) {
+	if !s.requireTags(w) {
+		return
+	}
+	tenantID, ok := profileTenant(w, r)
+	if !ok {
+		return
+	}
+
+	check, err := s.tags.Check(tenantID, mux.Vars(r)["name"], time.Now())
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"status": "success",
+		"data":   check,
+	})
+}
@@ -0,0 +1,131 @@
+package api
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/YumeNoTenshi/platypus/internal/metrics"
+	"github.com/YumeNoTenshi/platypus/internal/models"
+	"github.com/gorilla/mux"
+)
+
+// maxEdgeClockSkew - насколько точка устройства может опережать часы сервера
+const maxEdgeClockSkew = 5 * time.Minute
+
+func (s *Server) requireEdge(w http.ResponseWriter) bool {
+	if s.edge == nil {
+		respondWithError(w, http.StatusNotImplemented, "edge agents are disabled")
+		return false
+	}
+	return true
+}
+
+// handleGetEdgeDirective отдает агенту устройства интервалы сбора и выгрузки
+func (s *Server) handleGetEdgeDirective(w http.ResponseWriter, r *http.Request) {
+	if !s.requireEdge(w) {
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"status": "success",
+		"data":   s.edge.Directive(mux.Vars(r)["server_id"]),
+	})
+}
+
+// handlePostEdgeUpload принимает накопленные агентом устройства точки: NDJSON,
+// по точке на строку, при необходимости сжатый gzip (Content-Encoding: gzip).
+// В отличие от POST /metrics время точек сохраняется, так как они могли
+// пролежать на устройстве часы. Пачка ставится в буфер целиком; при 503 агент
+// повторяет ее позже, при 200 удаляет у себя. Точки с ошибками перечисляются
+// в rejected и не повторяются. В ответе - новое указание агенту.
+func (s *Server) handlePostEdgeUpload(w http.ResponseWriter, r *http.Request) {
+	if !s.requireEdge(w) {
+		return
+	}
+	defer r.Body.Close()
+
+	serverID := mux.Vars(r)["server_id"]
+	limit := s.edge.Policy().MaxUploadBytes
+
+	body, closeBody, err := requestBody(w, r, limit)
+	if err != nil {
+		respondBodyError(w, err)
+		return
+	}
+	defer closeBody()
+
+	tenantID := requestTenant(r)
+	now := time.Now()
+	var points []models.MetricData
+	var lines []int // Номер строки каждой точки для отчета об ошибках
+	rejected := make(map[int]string)
+
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1<<20)
+	line := 0
+	for scanner.Scan() {
+		line++
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		if len(points) >= s.edge.Policy().MaxBatch {
+			respondWithError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("at most %d points per upload", s.edge.Policy().MaxBatch))
+			return
+		}
+
+		var point models.MetricData
+		if err := json.Unmarshal(scanner.Bytes(), &point); err != nil {
+			rejected[line] = "invalid json"
+			continue
+		}
+		if point.Timestamp <= 0 || time.Unix(point.Timestamp, 0).After(now.Add(maxEdgeClockSkew)) {
+			rejected[line] = "timestamp is missing or in the future"
+			continue
+		}
+		// Сервер задается путем, арендатор - ключом, а не телом
+		point.ServerID = serverID
+		point.TenantID = tenantID
+		points = append(points, point)
+		lines = append(lines, line)
+	}
+	if err := scanner.Err(); err != nil {
+		respondBodyError(w, err)
+		return
+	}
+
+	source := r.Header.Get("X-Metrics-Source")
+	if source == "" {
+		source = serverID
+	}
+
+	accepted, invalid, err := s.collector.CollectBatchFrom(source, serverID, points)
+	if errors.Is(err, metrics.ErrTenantMismatch) {
+		respondWithError(w, http.StatusForbidden, err.Error())
+		return
+	}
+	if errors.Is(err, metrics.ErrBufferFull) {
+		w.Header().Set("Retry-After", "30")
+		respondWithError(w, http.StatusServiceUnavailable, err.Error())
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	for i, pointErr := range invalid {
+		rejected[lines[i]] = pointErr.Error()
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"status": "success",
+		"data": map[string]interface{}{
+			"accepted":  accepted,
+			"rejected":  rejected,
+			"directive": s.edge.Directive(serverID),
+		},
+	})
+}
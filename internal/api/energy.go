@@ -0,0 +1,87 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/YumeNoTenshi/platypus/internal/energy"
+)
+
+func (s *Server) requireEnergy(w http.ResponseWriter) bool {
+	if s.energy == nil {
+		respondWithError(w, http.StatusNotImplemented, "energy accounting is disabled")
+		return false
+	}
+	return true
+}
+
+// queryWindow разбирает параметр window (по умолчанию fallback) и возвращает [from, to)
+func queryWindow(r *http.Request, fallback time.Duration) (time.Time, time.Time, error) {
+	window := fallback
+	if value := r.URL.Query().Get("window"); value != "" {
+		parsed, err := time.ParseDuration(value)
+		if err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+		window = parsed
+	}
+	to := time.Now()
+	return to.Add(-window), to, nil
+}
+
+// handleGetServerEnergy возвращает ряд потребленной энергии сервера.
+// Параметры: resolution=hour|day, window (например, 24h).
+func (s *Server) handleGetServerEnergy(w http.ResponseWriter, r *http.Request) {
+	if !s.requireEnergy(w) {
+		return
+	}
+
+	from, to, err := queryWindow(r, 24*time.Hour)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid window: "+err.Error())
+		return
+	}
+
+	resolution := energy.Resolution(r.URL.Query().Get("resolution"))
+	series, err := s.energy.Series(mux.Vars(r)["server_id"], resolution, from, to)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"status": "success",
+		"data":   series,
+	})
+}
+
+// handleGetEnergyShowback распределяет потребленную энергию по группам.
+// Параметры: by=server|service|region|team|environment|cost_center, window (например, 168h).
+func (s *Server) handleGetEnergyShowback(w http.ResponseWriter, r *http.Request) {
+	if !s.requireEnergy(w) {
+		return
+	}
+
+	from, to, err := queryWindow(r, 7*24*time.Hour)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid window: "+err.Error())
+		return
+	}
+
+	by := r.URL.Query().Get("by")
+	if by == "" {
+		by = "team"
+	}
+
+	showback, err := s.energy.Showback(by, from, to)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"status": "success",
+		"data":   showback,
+	})
+}
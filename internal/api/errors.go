@@ -0,0 +1,30 @@
+package api
+
+import (
+	"net/http"
+)
+
+// handleGetErrors возвращает счетчики и последние ошибки фоновых подсистем.
+// ?failing=true оставляет только подсистемы, сбоящие прямо сейчас.
+func (s *Server) handleGetErrors(w http.ResponseWriter, r *http.Request) {
+	if s.errors == nil {
+		respondWithError(w, http.StatusNotImplemented, "error tracking is disabled")
+		return
+	}
+
+	statuses := s.errors.Status()
+	if r.URL.Query().Get("failing") == "true" {
+		failing := statuses[:0]
+		for _, status := range statuses {
+			if status.Consecutive > 0 {
+				failing = append(failing, status)
+			}
+		}
+		statuses = failing
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"status": "success",
+		"data":   statuses,
+	})
+}
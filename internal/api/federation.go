@@ -0,0 +1,99 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/YumeNoTenshi/platypus/internal/federation"
+)
+
+func (s *Server) requireHub(w http.ResponseWriter) bool {
+	if s.hub == nil {
+		respondWithError(w, http.StatusNotImplemented, "federation central mode is disabled")
+		return false
+	}
+	return true
+}
+
+// handlePostSiteReport принимает отчет edge-инстанса и возвращает актуальную политику
+func (s *Server) handlePostSiteReport(w http.ResponseWriter, r *http.Request) {
+	if !s.requireHub(w) {
+		return
+	}
+
+	var report federation.SiteReport
+	if err := json.NewDecoder(r.Body).Decode(&report); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	defer r.Body.Close()
+
+	policy, err := s.hub.Report(report)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"status": "success",
+		"data":   policy,
+	})
+}
+
+func (s *Server) handleGetFleetView(w http.ResponseWriter, r *http.Request) {
+	if !s.requireHub(w) {
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"status": "success",
+		"data":   s.hub.FleetView(),
+	})
+}
+
+func (s *Server) handleGetSite(w http.ResponseWriter, r *http.Request) {
+	if !s.requireHub(w) {
+		return
+	}
+
+	report, err := s.hub.Site(mux.Vars(r)["site_id"])
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"status": "success",
+		"data":   report,
+	})
+}
+
+func (s *Server) handleGetFederationPolicy(w http.ResponseWriter, r *http.Request) {
+	if !s.requireHub(w) {
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"status": "success",
+		"data":   s.hub.Policy(),
+	})
+}
+
+func (s *Server) handlePutFederationPolicy(w http.ResponseWriter, r *http.Request) {
+	if !s.requireHub(w) {
+		return
+	}
+
+	var policy federation.Policy
+	if err := json.NewDecoder(r.Body).Decode(&policy); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	defer r.Body.Close()
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"status": "success",
+		"data":   s.hub.SetPolicy(policy),
+	})
+}
@@ -0,0 +1,161 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// fieldTree - выбранные поля: имя -> вложенные поля; пустое поддерево - поле целиком
+type fieldTree map[string]fieldTree
+
+// parseFields разбирает ?fields=id,power_usage,labels.team
+func parseFields(value string) fieldTree {
+	tree := fieldTree{}
+	for _, field := range strings.Split(value, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		node := tree
+		for _, name := range strings.Split(field, ".") {
+			child, exists := node[name]
+			if !exists {
+				child = fieldTree{}
+				node[name] = child
+			}
+			node = child
+		}
+	}
+	return tree
+}
+
+// project оставляет в объектах только выбранные поля. Массивы проходятся
+// насквозь: поля применяются к каждому элементу.
+func (t fieldTree) project(value interface{}) interface{} {
+	if len(t) == 0 {
+		return value
+	}
+	switch v := value.(type) {
+	case map[string]interface{}:
+		projected := make(map[string]interface{}, len(t))
+		for name, subtree := range t {
+			if field, exists := v[name]; exists {
+				projected[name] = subtree.project(field)
+			}
+		}
+		return projected
+	case []interface{}:
+		for i, item := range v {
+			v[i] = t.project(item)
+		}
+		return v
+	}
+	return value
+}
+
+// fieldsWriter придерживает JSON-ответ, чтобы отфильтровать его поля.
+// Остальные ответы и ответы, которые обработчик сбрасывает по частям
+// (поток SSE), идут клиенту без фильтрации.
+type fieldsWriter struct {
+	http.ResponseWriter
+	code        int
+	wroteHeader bool
+	passthrough bool
+	body        bytes.Buffer
+}
+
+func (w *fieldsWriter) WriteHeader(code int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader, w.code = true, code
+	if !strings.HasPrefix(w.Header().Get("Content-Type"), "application/json") {
+		w.passthrough = true
+		w.ResponseWriter.WriteHeader(code)
+	}
+}
+
+func (w *fieldsWriter) Write(data []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.passthrough {
+		return w.ResponseWriter.Write(data)
+	}
+	return w.body.Write(data)
+}
+
+// FlushError прекращает буферизацию: обработчику нужен ответ по частям,
+// и отфильтровать его целиком уже нельзя
+func (w *fieldsWriter) FlushError() error {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if !w.passthrough {
+		w.passthrough = true
+		w.ResponseWriter.WriteHeader(w.code)
+		if _, err := w.ResponseWriter.Write(w.body.Bytes()); err != nil {
+			return err
+		}
+		w.body.Reset()
+	}
+	return http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+// Unwrap открывает исходный writer для http.ResponseController
+func (w *fieldsWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// fieldsMiddleware поддерживает ?fields= на маршрутах чтения: в data
+// успешного JSON-ответа остаются только перечисленные поля, например
+// ?fields=timestamp,power_usage для ряда метрик. Вложенные поля задаются
+// через точку. Без параметра ответ не буферизуется.
+func fieldsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fields := parseFields(r.URL.Query().Get("fields"))
+		if len(fields) == 0 || r.Method != http.MethodGet {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		buffered := &fieldsWriter{ResponseWriter: w, code: http.StatusOK}
+		next.ServeHTTP(buffered, r)
+		if buffered.passthrough {
+			return
+		}
+
+		body := buffered.body.Bytes()
+		if buffered.code >= 200 && buffered.code < 300 {
+			body = projectData(body, fields)
+		}
+		w.WriteHeader(buffered.code)
+		w.Write(body)
+	})
+}
+
+// projectData применяет поля к data ответа. Ответ, который не удалось
+// разобрать, возвращается без изменений.
+func projectData(body []byte, fields fieldTree) []byte {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	// Числа сохраняются как есть: float64 исказил бы большие целые
+	decoder.UseNumber()
+
+	var envelope map[string]interface{}
+	if err := decoder.Decode(&envelope); err != nil {
+		return body
+	}
+	data, exists := envelope["data"]
+	if !exists {
+		return body
+	}
+	envelope["data"] = fields.project(data)
+
+	projected, err := json.Marshal(envelope)
+	if err != nil {
+		return body
+	}
+	return projected
+}
@@ -0,0 +1,90 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/YumeNoTenshi/platypus/internal/governor"
+)
+
+// handleGetGovernorDirective отдает агенту текущее указание по режиму CPU для хоста
+func (s *Server) handleGetGovernorDirective(w http.ResponseWriter, r *http.Request) {
+	if s.governor == nil {
+		respondWithError(w, http.StatusNotImplemented, "governor policy is disabled")
+		return
+	}
+
+	serverID := mux.Vars(r)["server_id"]
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"status": "success",
+		"data":   s.governor.Directive(serverID),
+	})
+}
+
+func (s *Server) handleSetLatencySensitive(w http.ResponseWriter, r *http.Request) {
+	if s.governor == nil {
+		respondWithError(w, http.StatusNotImplemented, "governor policy is disabled")
+		return
+	}
+
+	var req LatencySensitiveRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	defer r.Body.Close()
+
+	serverID := mux.Vars(r)["server_id"]
+	s.governor.SetLatencySensitive(serverID, req.LatencySensitive)
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"status": "success",
+		"data":   s.governor.Directive(serverID),
+	})
+}
+
+// handlePostGovernorReport принимает от агента результат применения указания
+func (s *Server) handlePostGovernorReport(w http.ResponseWriter, r *http.Request) {
+	if s.governor == nil {
+		respondWithError(w, http.StatusNotImplemented, "governor policy is disabled")
+		return
+	}
+
+	var report governor.Report
+	if err := json.NewDecoder(r.Body).Decode(&report); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	defer r.Body.Close()
+
+	report.ServerID = mux.Vars(r)["server_id"]
+	report.ReportedAt = time.Now()
+	s.governor.SetReport(report)
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"status": "success",
+		"data":   report,
+	})
+}
+
+// handleGetGovernorReport возвращает последний результат применения указания на хосте
+func (s *Server) handleGetGovernorReport(w http.ResponseWriter, r *http.Request) {
+	if s.governor == nil {
+		respondWithError(w, http.StatusNotImplemented, "governor policy is disabled")
+		return
+	}
+
+	serverID := mux.Vars(r)["server_id"]
+	report, exists := s.governor.Report(serverID)
+	if !exists {
+		respondWithError(w, http.StatusNotFound, "no governor report for server "+serverID)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"status": "success",
+		"data":   report,
+	})
+}
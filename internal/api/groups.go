@@ -0,0 +1,350 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/YumeNoTenshi/platypus/internal/groups"
+	"github.com/YumeNoTenshi/platypus/internal/metrics"
+	"github.com/YumeNoTenshi/platypus/internal/models"
+	"github.com/YumeNoTenshi/platypus/internal/selector"
+	"github.com/gorilla/mux"
+)
+
+var errGroupsDisabled = errors.New("server groups are disabled")
+
+type BatchEcoScoreRequest struct {
+	ServerIDs []string `json:"server_ids,omitempty"`
+	Group     string   `json:"group,omitempty"`    // ID или имя сохраненной группы
+	Selector  string   `json:"selector,omitempty"` // Серверы по меткам, например "team=payments,environment!=dev"
+}
+
+func (s *Server) requireGroups(w http.ResponseWriter) bool {
+	if s.groups == nil {
+		respondWithError(w, http.StatusNotImplemented, "server groups are disabled")
+		return false
+	}
+	return true
+}
+
+// resolveTargets объединяет явно перечисленные серверы, состав группы и
+// серверы, подходящие под селектор меток
+func (s *Server) resolveTargets(serverIDs []string, group, expr string) ([]string, error) {
+	targets := append([]string(nil), serverIDs...)
+	if group == "" && expr == "" {
+		return targets, nil
+	}
+	if s.groups == nil {
+		return nil, errGroupsDisabled
+	}
+
+	var members []string
+	if group != "" {
+		groupMembers, err := s.groups.Members(group)
+		if err != nil {
+			return nil, err
+		}
+		members = append(members, groupMembers...)
+	}
+	if expr != "" {
+		sel, err := selector.Parse(expr)
+		if err != nil {
+			return nil, err
+		}
+		members = append(members, s.groups.Select(sel)...)
+	}
+
+	seen := make(map[string]bool, len(targets))
+	for _, id := range targets {
+		seen[id] = true
+	}
+	for _, id := range members {
+		if !seen[id] {
+			targets = append(targets, id)
+		}
+	}
+	return targets, nil
+}
+
+func (s *Server) handleListGroups(w http.ResponseWriter, r *http.Request) {
+	if !s.requireGroups(w) {
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"status": "success",
+		"data":   s.groups.List(),
+	})
+}
+
+func (s *Server) handleCreateGroup(w http.ResponseWriter, r *http.Request) {
+	if !s.requireGroups(w) {
+		return
+	}
+
+	var group groups.Group
+	if err := json.NewDecoder(r.Body).Decode(&group); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	defer r.Body.Close()
+
+	created, err := s.groups.Create(group)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, map[string]interface{}{
+		"status": "success",
+		"data":   created,
+	})
+}
+
+func (s *Server) handleGetGroup(w http.ResponseWriter, r *http.Request) {
+	if !s.requireGroups(w) {
+		return
+	}
+
+	group, err := s.groups.Get(mux.Vars(r)["id"])
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"status": "success",
+		"data":   group,
+	})
+}
+
+func (s *Server) handleUpdateGroup(w http.ResponseWriter, r *http.Request) {
+	if !s.requireGroups(w) {
+		return
+	}
+
+	var group groups.Group
+	if err := json.NewDecoder(r.Body).Decode(&group); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	defer r.Body.Close()
+
+	id := mux.Vars(r)["id"]
+	if _, err := s.groups.Get(id); err != nil {
+		respondWithError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	updated, err := s.groups.Update(id, group)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"status": "success",
+		"data":   updated,
+	})
+}
+
+func (s *Server) handleDeleteGroup(w http.ResponseWriter, r *http.Request) {
+	if !s.requireGroups(w) {
+		return
+	}
+
+	if err := s.groups.Delete(mux.Vars(r)["id"]); err != nil {
+		respondWithError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]string{
+		"status": "success",
+	})
+}
+
+func (s *Server) handleGetGroupMembers(w http.ResponseWriter, r *http.Request) {
+	if !s.requireGroups(w) {
+		return
+	}
+
+	members, err := s.groups.Members(mux.Vars(r)["id"])
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"status": "success",
+		"data":   members,
+	})
+}
+
+// handleBatchEcoScore рассчитывает эко-рейтинги для списка серверов и/или группы
+func (s *Server) handleBatchEcoScore(w http.ResponseWriter, r *http.Request) {
+	var req BatchEcoScoreRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	defer r.Body.Close()
+
+	targets, err := s.resolveTargets(req.ServerIDs, req.Group, req.Selector)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	results := make([]map[string]interface{}, 0, len(targets))
+	for _, serverID := range targets {
+		data, err := s.collector.GetMetrics(serverID)
+		if err != nil {
+			results = append(results, map[string]interface{}{
+				"server_id": serverID,
+				"error":     err.Error(),
+			})
+			continue
+		}
+
+		scores := s.analyzer.CalculateEcoScores(serverID, data)
+		results = append(results, map[string]interface{}{
+			"server_id":            serverID,
+			"eco_score":            scores.Raw,
+			"normalized_eco_score": scores.Normalized,
+			"instance_type":        scores.InstanceType,
+		})
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"status": "success",
+		"data":   results,
+	})
+}
+
+// handleGetMetricsAggregate сводит последние метрики серверов.
+// Параметры: server_id (через запятую), group и/или selector
+// (?selector=environment=prod,team!=infra). С параметром metric
+// возвращает статистику метрики по интервалам, см. handleMetricStatistics.
+func (s *Server) handleGetMetricsAggregate(w http.ResponseWriter, r *http.Request) {
+	var serverIDs []string
+	if value := r.URL.Query().Get("server_id"); value != "" {
+		serverIDs = strings.Split(value, ",")
+	}
+
+	targets, err := s.resolveTargets(serverIDs, r.URL.Query().Get("group"), r.URL.Query().Get("selector"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if len(targets) == 0 {
+		respondWithError(w, http.StatusBadRequest, "server_id, group or selector is required")
+		return
+	}
+	if metric := r.URL.Query().Get("metric"); metric != "" {
+		s.handleMetricStatistics(w, r, targets, metric)
+		return
+	}
+
+	var latest []models.MetricData
+	var missing []string
+	for _, serverID := range targets {
+		data, err := s.collector.GetMetrics(serverID)
+		if err != nil || len(data) == 0 {
+			missing = append(missing, serverID)
+			continue
+		}
+		latest = append(latest, data[len(data)-1])
+	}
+
+	aggregate := map[string]interface{}{
+		"servers":          len(targets),
+		"reporting":        len(latest),
+		"missing":          missing,
+		"total_power":      0.0,
+		"avg_cpu_usage":    0.0,
+		"avg_memory_usage": 0.0,
+	}
+	if len(latest) > 0 {
+		var power, cpu, memory float64
+		for _, m := range latest {
+			power += m.PowerUsage
+			cpu += m.CPUUsage
+			memory += m.MemoryUsage
+		}
+		aggregate["total_power"] = power
+		aggregate["avg_cpu_usage"] = cpu / float64(len(latest))
+		aggregate["avg_memory_usage"] = memory / float64(len(latest))
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"status": "success",
+		"data":   aggregate,
+	})
+}
+
+// handleMetricStatistics возвращает avg, min, max и p95 метрики каждого
+// сервера по интервалам step в окне: ?metric=power_usage&window=24h&step=1h.
+// Окно задается так же, как в GET /metrics (window или from/to), по
+// умолчанию 24h; без step - один интервал на все окно. filtered=true
+// агрегирует ряд после сглаживания и заполнения пропусков.
+func (s *Server) handleMetricStatistics(w http.ResponseWriter, r *http.Request, targets []string, metric string) {
+	from, to, ranged, err := metricsRange(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if !ranged {
+		to = time.Now()
+		from = to.Add(-24 * time.Hour)
+	}
+
+	var step time.Duration
+	if value := r.URL.Query().Get("step"); value != "" {
+		step, err = time.ParseDuration(value)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "invalid step: "+err.Error())
+			return
+		}
+		if step <= 0 {
+			respondWithError(w, http.StatusBadRequest, "step must be positive")
+			return
+		}
+	}
+	filtered := r.URL.Query().Get("filtered") == "true"
+
+	// Метрика и шаг проверяются до чтения точек
+	if _, err := metrics.AggregateMetric(nil, metric, from, to, step); err != nil {
+		message := err.Error()
+		if errors.Is(err, metrics.ErrUnknownMetric) {
+			message += "; available: " + strings.Join(metrics.MetricNames(), ", ")
+		}
+		respondWithError(w, http.StatusBadRequest, message)
+		return
+	}
+
+	series := make(map[string][]metrics.AggregatePoint, len(targets))
+	missing := []string{}
+	for _, serverID := range targets {
+		points, err := s.collector.Aggregate(serverID, metric, from, to, step, filtered)
+		if err != nil || len(points) == 0 {
+			missing = append(missing, serverID)
+			continue
+		}
+		series[serverID] = points
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"status": "success",
+		"data": map[string]interface{}{
+			"metric":  metric,
+			"from":    from.UTC(),
+			"to":      to.UTC(),
+			"step":    step.String(),
+			"series":  series,
+			"missing": missing,
+		},
+	})
+}
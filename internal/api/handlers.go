@@ -2,66 +2,454 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/YumeNoTenshi/platypus/internal/metrics"
 	"github.com/YumeNoTenshi/platypus/internal/models"
+	"github.com/YumeNoTenshi/platypus/pkg/cloud"
 )
 
-type Server struct {
-	collector *metrics.Collector
-	analyzer  *metrics.Analyzer
+func NewServer(collector *metrics.Collector, analyzer *metrics.Analyzer, opts ...ServerOption) *Server {
+	s := &Server{
+		collector:      collector,
+		analyzer:       analyzer,
+		auth:           NewAPIKeyProvider(nil),
+		logger:         NewRequestLogger(LoggingConfig{Default: RouteLogRule{Level: LogAll, SampleRate: 1}}),
+		statusSections: make(map[string]func() interface{}),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
 }
 
 func (s *Server) Router() *mux.Router {
 	r := mux.NewRouter()
 	
 	// Добавляем middleware для всех маршрутов
-	r.Use(LoggingMiddleware)
+	r.Use(requestIDMiddleware)
+	r.Use(s.logger.Middleware)
+	// Middleware не вызываются для несуществующих маршрутов, а идентификатор нужен в любом ответе
+	r.NotFoundHandler = requestIDMiddleware(http.NotFoundHandler())
+	r.MethodNotAllowedHandler = requestIDMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}))
 	
 	// API версия v1
 	v1 := r.PathPrefix("/api/v1").Subrouter()
 	
 	// Применяем аутентификацию ко всем маршрутам, кроме /health
 	protected := v1.NewRoute().Subrouter()
-	protected.Use(AuthMiddleware)
+	protected.Use(AuthMiddleware(s.auth))
+	protected.Use(s.scopeMiddleware)
+	protected.Use(fieldsMiddleware)
 	
+	// Лента календаря: ключ API можно передать в адресе (?key=)
+	feeds := v1.NewRoute().Subrouter()
+	feeds.Use(feedKeyMiddleware)
+	feeds.Use(AuthMiddleware(s.auth))
+	feeds.Use(s.scopeMiddleware)
+	feeds.HandleFunc("/calendar.ics", s.handleGetCalendar).Methods("GET")
+
 	// Открытые маршруты
 	v1.HandleFunc("/health", s.handleHealth).Methods("GET")
  
 	// Защищенные маршруты
 	protected.HandleFunc("/metrics", s.handleGetMetrics).Methods("GET")
 	protected.HandleFunc("/metrics", s.handlePostMetrics).Methods("POST")
+	protected.HandleFunc("/metrics/batch", s.handlePostMetricsBatch).Methods("POST")
+	protected.HandleFunc("/metrics/stream", s.handleStreamMetrics).Methods("GET")
+	protected.HandleFunc("/metrics/aggregate", s.handleGetMetricsAggregate).Methods("GET")
+	protected.HandleFunc("/metrics/buckets", s.handleGetMetricBuckets).Methods("GET")
+	protected.HandleFunc("/metrics/analysis", s.handleGetMetricAnalysis).Methods("GET")
+	protected.HandleFunc("/analysis/fleet", s.handleGetFleetAnalysis).Methods("GET")
+	protected.HandleFunc("/analysis/correlations", s.handleGetCorrelationClusters).Methods("GET")
+	protected.HandleFunc("/analysis/{server_id}", s.handleGetServerAnalysis).Methods("GET")
+	protected.HandleFunc("/annotations", s.handleListAnnotations).Methods("GET")
+	protected.HandleFunc("/annotations", s.handleCreateAnnotation).Methods("POST")
+	protected.HandleFunc("/annotations/{id}", s.handleGetAnnotation).Methods("GET")
+	protected.HandleFunc("/annotations/{id}", s.handleDeleteAnnotation).Methods("DELETE")
+	protected.HandleFunc("/ingest/sources/{source}/units", s.handleGetSourceUnits).Methods("GET")
+	protected.HandleFunc("/ingest/sources/{source}/units", s.handlePutSourceUnits).Methods("PUT")
+	protected.HandleFunc("/ingest/diagnostics", s.handleGetIngestDiagnostics).Methods("GET")
+	protected.HandleFunc("/ingest/rejections", s.handleGetIngestRejections).Methods("GET")
+	protected.HandleFunc("/containers", s.handleGetContainers).Methods("GET")
+	protected.HandleFunc("/containers/events", s.handleGetContainerEvents).Methods("GET")
+	protected.HandleFunc("/containers/events", s.handlePostContainerEvent).Methods("POST")
+	protected.HandleFunc("/containers/{id}/metrics", s.handleGetContainerMetrics).Methods("GET")
+	protected.HandleFunc("/containers/{id}/metrics", s.handlePostContainerMetrics).Methods("POST")
+	protected.HandleFunc("/providers/modes", s.handleListProviderModes).Methods("GET")
+	protected.HandleFunc("/providers/{name}/mode", s.handlePutProviderMode).Methods("PUT")
 	protected.HandleFunc("/servers", s.handleGetServers).Methods("GET")
 	protected.HandleFunc("/servers/{id}", s.handleGetServer).Methods("GET")
 	protected.HandleFunc("/eco-score", s.handleGetEcoScore).Methods("POST")
+	protected.HandleFunc("/eco-score/batch", s.handleBatchEcoScore).Methods("POST")
+	protected.HandleFunc("/eco-score/history/{server_id}", s.handleGetEcoScoreHistory).Methods("GET")
+	protected.HandleFunc("/eco-score/methodologies", s.handleGetEcoScoreMethodologies).Methods("GET")
+	protected.HandleFunc("/eco-score/backfills", s.handleStartEcoScoreBackfill).Methods("POST")
+	protected.HandleFunc("/groups", s.handleListGroups).Methods("GET")
+	protected.HandleFunc("/groups", s.handleCreateGroup).Methods("POST")
+	protected.HandleFunc("/groups/{id}", s.handleGetGroup).Methods("GET")
+	protected.HandleFunc("/groups/{id}", s.handleUpdateGroup).Methods("PUT")
+	protected.HandleFunc("/groups/{id}", s.handleDeleteGroup).Methods("DELETE")
+	protected.HandleFunc("/groups/{id}/members", s.handleGetGroupMembers).Methods("GET")
 	protected.HandleFunc("/eco-tags", s.handleGetEcoTags).Methods("GET")
+	protected.HandleFunc("/eco-tags/versions/{version}", s.handleGetEcoTagVersion).Methods("GET")
+	protected.HandleFunc("/eco-tags/profiles/{service}/history", s.handleGetEcoProfileHistory).Methods("GET")
+	protected.HandleFunc("/services/{name}/eco-check", s.handleGetServiceEcoCheck).Methods("GET")
+	protected.HandleFunc("/eco-tags/{name}", s.handlePutEcoTag).Methods("PUT")
+	protected.HandleFunc("/eco-tags/{name}", s.handleDeleteEcoTag).Methods("DELETE")
 	protected.HandleFunc("/status", s.handleStatus).Methods("GET")
+	protected.HandleFunc("/governor/{server_id}", s.handleGetGovernorDirective).Methods("GET")
+	protected.HandleFunc("/governor/{server_id}/latency-sensitive", s.handleSetLatencySensitive).Methods("PUT")
+	protected.HandleFunc("/governor/{server_id}/report", s.handleGetGovernorReport).Methods("GET")
+	protected.HandleFunc("/governor/{server_id}/report", s.handlePostGovernorReport).Methods("POST")
+	protected.HandleFunc("/edge/{server_id}/directive", s.handleGetEdgeDirective).Methods("GET")
+	protected.HandleFunc("/edge/{server_id}/upload", s.handlePostEdgeUpload).Methods("POST")
+	protected.HandleFunc("/insights/top-offenders", s.handleGetTopOffenders).Methods("GET")
+	protected.HandleFunc("/insights/green-windows", s.handleGetGreenWindows).Methods("GET")
+	protected.HandleFunc("/recommendations", s.handleListRecommendations).Methods("GET")
+	protected.HandleFunc("/recommendations", s.handleCreateRecommendation).Methods("POST")
+	protected.HandleFunc("/recommendations/forecast", s.handleGetSavingsForecast).Methods("GET")
+	protected.HandleFunc("/recommendations/{id}", s.handleGetRecommendation).Methods("GET")
+	protected.HandleFunc("/recommendations/{id}", s.handleUpdateRecommendation).Methods("PATCH")
+	protected.HandleFunc("/recommendations/{id}", s.handleDeleteRecommendation).Methods("DELETE")
+	protected.HandleFunc("/inventory/servers", s.handleListInventoryServers).Methods("GET")
+	protected.HandleFunc("/inventory/servers/register", s.handleRegisterServers).Methods("POST")
+	protected.HandleFunc("/inventory/servers/deregister", s.handleDeregisterServers).Methods("POST")
+	protected.HandleFunc("/inventory/servers/{server_id}/registration", s.handleDeregisterServer).Methods("DELETE")
+	protected.HandleFunc("/inventory/servers/import", s.handleImportServerMetadata).Methods("POST")
+	protected.HandleFunc("/inventory/servers/export", s.handleExportServerMetadata).Methods("GET")
+	protected.HandleFunc("/inventory/servers/{server_id}/labels", s.handleGetServerLabels).Methods("GET")
+	protected.HandleFunc("/inventory/services/{service}/labels", s.handleGetServiceLabels).Methods("GET")
+	protected.HandleFunc("/inventory/services/{service}/labels", s.handleSetServiceOverride).Methods("PUT")
+	protected.HandleFunc("/inventory/namespaces/{namespace}/labels", s.handleSetNamespaceLabels).Methods("PUT")
+	protected.HandleFunc("/energy/servers/{server_id}", s.handleGetServerEnergy).Methods("GET")
+	protected.HandleFunc("/energy/showback", s.handleGetEnergyShowback).Methods("GET")
+	protected.HandleFunc("/budgets", s.handleListBudgets).Methods("GET")
+	protected.HandleFunc("/budgets", s.handleCreateBudget).Methods("POST")
+	protected.HandleFunc("/budgets/dashboard", s.handleGetBudgetDashboard).Methods("GET")
+	protected.HandleFunc("/budgets/{id}", s.handleGetBudget).Methods("GET")
+	protected.HandleFunc("/budgets/{id}", s.handleUpdateBudget).Methods("PUT")
+	protected.HandleFunc("/budgets/{id}", s.handleDeleteBudget).Methods("DELETE")
+	protected.HandleFunc("/budgets/{id}/burn-down", s.handleGetBudgetBurnDown).Methods("GET")
+	protected.HandleFunc("/alerts", s.handleGetAlerts).Methods("GET")
+	protected.HandleFunc("/alerts/channels", s.handleGetAlertChannels).Methods("GET")
+	protected.HandleFunc("/alerts/silences", s.handleListSilences).Methods("GET")
+	protected.HandleFunc("/alerts/silences", s.handleCreateSilence).Methods("POST")
+	protected.HandleFunc("/alerts/silences/{id}", s.handleDeleteSilence).Methods("DELETE")
+	protected.HandleFunc("/incidents", s.handleListIncidents).Methods("GET")
+	protected.HandleFunc("/incidents/{id}", s.handleGetIncident).Methods("GET")
+	protected.HandleFunc("/incidents/{id}", s.handleUpdateIncident).Methods("PATCH")
+	protected.HandleFunc("/simulate/region-move", s.handleSimulateRegionMove).Methods("POST")
+	protected.HandleFunc("/migrations/preview", s.handleGetMigrationPreview).Methods("GET")
+	protected.HandleFunc("/migrations/history", s.handleGetMigrationHistory).Methods("GET")
+	protected.HandleFunc("/migrations/{container_id}/explain", s.handleExplainMigration).Methods("GET")
+	protected.HandleFunc("/scaling/groups", s.handleListScalingGroups).Methods("GET")
+	protected.HandleFunc("/scaling/groups/{id}", s.handleGetScalingGroup).Methods("GET")
+	protected.HandleFunc("/scaling/groups/{id}", s.handlePutScalingGroup).Methods("PUT")
+	protected.HandleFunc("/scaling/groups/{id}", s.handleDeleteScalingGroup).Methods("DELETE")
+	protected.HandleFunc("/scaling/{server_id}/explain", s.handleExplainScaling).Methods("GET")
+	protected.HandleFunc("/reports", s.handleListReports).Methods("GET")
+	protected.HandleFunc("/reports/subscriptions", s.handleListReportSubscriptions).Methods("GET")
+	protected.HandleFunc("/reports/subscriptions", s.handleCreateReportSubscription).Methods("POST")
+	protected.HandleFunc("/reports/subscriptions/{id}", s.handleGetReportSubscription).Methods("GET")
+	protected.HandleFunc("/reports/subscriptions/{id}", s.handleUpdateReportSubscription).Methods("PUT")
+	protected.HandleFunc("/reports/subscriptions/{id}", s.handleDeleteReportSubscription).Methods("DELETE")
+	protected.HandleFunc("/reports/subscriptions/{id}/send", s.handleSendReportSubscription).Methods("POST")
+	protected.HandleFunc("/reports/{id}", s.handleGetReport).Methods("GET")
+	protected.HandleFunc("/images", s.handleGetImageReports).Methods("GET")
+	protected.HandleFunc("/images/scan", s.handleScanImage).Methods("POST")
+	protected.HandleFunc("/admin/logging", s.handleGetLoggingConfig).Methods("GET")
+	protected.HandleFunc("/admin/logging", s.handlePutLoggingConfig).Methods("PUT")
+	protected.HandleFunc("/api-keys", s.handleListAPIKeys).Methods("GET")
+	protected.HandleFunc("/api-keys", s.handleCreateAPIKey).Methods("POST")
+	protected.HandleFunc("/api-keys/{id}", s.handleGetAPIKey).Methods("GET")
+	protected.HandleFunc("/api-keys/{id}", s.handleDeleteAPIKey).Methods("DELETE")
+	protected.HandleFunc("/federation/reports", s.handlePostSiteReport).Methods("POST")
+	protected.HandleFunc("/federation/fleet", s.handleGetFleetView).Methods("GET")
+	protected.HandleFunc("/federation/sites/{site_id}", s.handleGetSite).Methods("GET")
+	protected.HandleFunc("/federation/policy", s.handleGetFederationPolicy).Methods("GET")
+	protected.HandleFunc("/federation/policy", s.handlePutFederationPolicy).Methods("PUT")
+	protected.HandleFunc("/replication/batches", s.handlePostReplicationBatches).Methods("POST")
+	protected.HandleFunc("/replication/status", s.handleGetReplicationStatus).Methods("GET")
+	protected.HandleFunc("/errors", s.handleGetErrors).Methods("GET")
 	
 	return r
 }
 
+// handleGetMetrics возвращает точки сервера (?server_id=). ?labels=team=payments,environment!=dev
+// оставляет только точки, подходящие под селектор (синтаксис - пакет selector); с селектором меток server_id необязателен,
+// и тогда возвращаются подходящие точки всех серверов. Вместе с точками
+// возвращаются пометки (annotations) сервера или сервиса из селектора за тот же период.
 func (s *Server) handleGetMetrics(w http.ResponseWriter, r *http.Request) {
+	serverID := r.URL.Query().Get("server_id")
+	selector, err := metrics.ParseLabelSelector(r.URL.Query().Get("labels"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if serverID == "" && selector.Empty() {
+		respondWithError(w, http.StatusBadRequest, "server_id or labels is required")
+		return
+	}
+
+	// ?filtered=true возвращает ряд после сглаживания и заполнения пропусков
+	filtered := r.URL.Query().Get("filtered") == "true"
+	getMetrics := s.collector.GetMetrics
+	getRange := s.collector.GetMetricsRange
+	if filtered {
+		getMetrics = s.collector.GetFilteredMetrics
+		getRange = s.collector.GetFilteredMetricsRange
+	}
+
+	// ?window=1h или ?from=&to= (RFC3339) ограничивают выборку; без них - вся история
+	from, to, ranged, err := metricsRange(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	serverIDs := []string{serverID}
+	if serverID == "" {
+		serverIDs = s.collector.ServerIDs()
+		sort.Strings(serverIDs)
+	}
+
+	data := []models.MetricData{}
+	var queried []string
+	for _, id := range serverIDs {
+		if serverID == "" && !s.allowedServer(r, id) {
+			continue
+		}
+		var points []models.MetricData
+		if ranged {
+			points, err = getRange(id, from, to)
+		} else {
+			points, err = getMetrics(id)
+		}
+		if err != nil {
+			// При выборке по меткам сервер без точек просто пропускается
+			if serverID == "" {
+				continue
+			}
+			respondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		data = append(data, selector.Filter(points)...)
+		queried = append(queried, id)
+	}
+
+	// Пометки выборки: при выборке по меткам - только сервиса из селектора,
+	// иначе ответ получил бы пометки всех серверов
+	if serverID == "" {
+		queried = nil
+	}
+	service, _ := selector.Value("service")
+	respondWithJSON(w, http.StatusOK, MetricResponse{
+		Status:      "success",
+		Data:        data,
+		Annotations: s.metricAnnotations(r, queried, service, from, to),
+	})
+}
+
+// handleGetMetricBuckets возвращает агрегаты по интервалам: ?server_id=&window=24h&bucket=1h.
+// Хранилища с поддержкой агрегации считают их без загрузки точек в память.
+func (s *Server) handleGetMetricBuckets(w http.ResponseWriter, r *http.Request) {
 	serverID := r.URL.Query().Get("server_id")
 	if serverID == "" {
 		respondWithError(w, http.StatusBadRequest, "server_id is required")
 		return
 	}
 
-	metrics, err := s.collector.GetMetrics(serverID)
+	from, to, err := queryWindow(r, 24*time.Hour)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid window: "+err.Error())
+		return
+	}
+
+	bucket := time.Hour
+	if value := r.URL.Query().Get("bucket"); value != "" {
+		bucket, err = time.ParseDuration(value)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "invalid bucket: "+err.Error())
+			return
+		}
+		if bucket <= 0 {
+			respondWithError(w, http.StatusBadRequest, "bucket must be positive")
+			return
+		}
+	}
+
+	buckets, err := s.collector.GetBuckets(serverID, from, to, bucket)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 
-	respondWithJSON(w, http.StatusOK, MetricResponse{
-		Status: "success",
-		Data:   metrics,
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"status": "success",
+		"data":   buckets,
 	})
 }
 
+// handleGetMetricAnalysis возвращает статистику, процентили, тренд и
+// аномалии измерения сервера: ?server_id=&dimension=cpu (power, cpu,
+// memory, carbon; по умолчанию power). decomposition - почасовой ряд,
+// разложенный на тренд, суточный и недельный сезоны и остаток
+func (s *Server) handleGetMetricAnalysis(w http.ResponseWriter, r *http.Request) {
+	serverID := r.URL.Query().Get("server_id")
+	if serverID == "" {
+		respondWithError(w, http.StatusBadRequest, "server_id is required")
+		return
+	}
+	s.respondWithAnalysis(w, r, serverID)
+}
+
+// handleGetServerAnalysis - то же для сервера из пути: p50-p99 нужны для
+// планирования мощностей, медианы для этого мало
+func (s *Server) handleGetServerAnalysis(w http.ResponseWriter, r *http.Request) {
+	s.respondWithAnalysis(w, r, mux.Vars(r)["server_id"])
+}
+
+func (s *Server) respondWithAnalysis(w http.ResponseWriter, r *http.Request, serverID string) {
+	dimension, err := metrics.ParseDimension(r.URL.Query().Get("dimension"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	analysis, err := s.analyzer.AnalyzeServerMetrics(serverID, dimension)
+	if err != nil {
+		respondWithError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"status": "success",
+		"data":   analysis,
+	})
+}
+
+// handleGetFleetAnalysis сводит потребление, углеродный след и эко-рейтинг
+// всех серверов области ключа; ?top= - сколько худших серверов вернуть
+// (по умолчанию 10)
+func (s *Server) handleGetFleetAnalysis(w http.ResponseWriter, r *http.Request) {
+	var top int
+	if value := r.URL.Query().Get("top"); value != "" {
+		var err error
+		top, err = strconv.Atoi(value)
+		if err != nil || top <= 0 {
+			respondWithError(w, http.StatusBadRequest, "top must be a positive integer")
+			return
+		}
+	}
+
+	serverIDs := make([]string, 0)
+	for _, serverID := range s.collector.ServerIDs() {
+		if s.allowedServer(r, serverID) {
+			serverIDs = append(serverIDs, serverID)
+		}
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"status": "success",
+		"data":   s.analyzer.AnalyzeFleet(serverIDs, top),
+	})
+}
+
+// handleGetCorrelationClusters возвращает кластеры серверов, всплески
+// которых идут вместе. Параметры: dimension (power по умолчанию),
+// min_correlation (0-1, по умолчанию 0.8) и серверы - server_id через
+// запятую, group и/или selector; без них - все серверы области ключа.
+func (s *Server) handleGetCorrelationClusters(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	dimension, err := metrics.ParseDimension(query.Get("dimension"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	var minCorrelation float64
+	if value := query.Get("min_correlation"); value != "" {
+		minCorrelation, err = strconv.ParseFloat(value, 64)
+		if err != nil || minCorrelation <= 0 || minCorrelation > 1 {
+			respondWithError(w, http.StatusBadRequest, "min_correlation must be in (0, 1]")
+			return
+		}
+	}
+
+	var serverIDs []string
+	if value := query.Get("server_id"); value != "" {
+		serverIDs = strings.Split(value, ",")
+	}
+	targets, err := s.resolveTargets(serverIDs, query.Get("group"), query.Get("selector"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if len(targets) == 0 {
+		targets = s.collector.ServerIDs()
+	}
+	allowed := make([]string, 0, len(targets))
+	for _, serverID := range targets {
+		if s.allowedServer(r, serverID) {
+			allowed = append(allowed, serverID)
+		}
+	}
+
+	clusters, err := s.analyzer.CorrelationClusters(allowed, dimension, minCorrelation)
+	if err != nil {
+		respondWithError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"status": "success",
+		"data": map[string]interface{}{
+			"dimension": dimension,
+			"servers":   len(allowed),
+			"clusters":  clusters,
+		},
+	})
+}
+
+// metricsRange разбирает ?window= или ?from=&to=; ranged=false, если окно не задано
+func metricsRange(r *http.Request) (from, to time.Time, ranged bool, err error) {
+	query := r.URL.Query()
+	if query.Get("window") != "" {
+		from, to, err = queryWindow(r, 0)
+		if err != nil {
+			return from, to, false, fmt.Errorf("invalid window: %w", err)
+		}
+		return from, to, true, nil
+	}
+	if query.Get("from") == "" && query.Get("to") == "" {
+		return from, to, false, nil
+	}
+
+	to = time.Now()
+	if value := query.Get("to"); value != "" {
+		if to, err = time.Parse(time.RFC3339, value); err != nil {
+			return from, to, false, fmt.Errorf("invalid to: %w", err)
+		}
+	}
+	if value := query.Get("from"); value != "" {
+		if from, err = time.Parse(time.RFC3339, value); err != nil {
+			return from, to, false, fmt.Errorf("invalid from: %w", err)
+		}
+	}
+	if !from.Before(to) {
+		return from, to, false, fmt.Errorf("from must be before to")
+	}
+	return from, to, true, nil
+}
+
 func (s *Server) handlePostMetrics(w http.ResponseWriter, r *http.Request) {
 	var metricData models.MetricData
 	if err := json.NewDecoder(r.Body).Decode(&metricData); err != nil {
@@ -71,8 +459,42 @@ func (s *Server) handlePostMetrics(w http.ResponseWriter, r *http.Request) {
 	defer r.Body.Close()
 
 	metricData.Timestamp = time.Now().Unix()
-	
-	if err := s.collector.CollectMetrics(metricData.ServerID, metricData); err != nil {
+	// Арендатор точки определяется ключом, а не телом запроса
+	metricData.TenantID = requestTenant(r)
+
+	// Единицы измерения объявляются на источник; по умолчанию источник - сам сервер
+	source := r.Header.Get("X-Metrics-Source")
+	if source == "" {
+		source = metricData.ServerID
+	}
+
+	if err := s.collector.CollectMetricsFrom(source, metricData.ServerID, metricData); err != nil {
+		if errors.Is(err, metrics.ErrInvalidLabels) {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if errors.Is(err, metrics.ErrTenantMismatch) {
+			respondWithError(w, http.StatusForbidden, err.Error())
+			return
+		}
+		var unitErr *metrics.UnitError
+		if errors.As(err, &unitErr) {
+			respondWithJSON(w, http.StatusUnprocessableEntity, map[string]interface{}{
+				"status":      "error",
+				"message":     unitErr.Error(),
+				"diagnostics": unitErr.Diagnostics,
+			})
+			return
+		}
+		var validationErr *metrics.ValidationError
+		if errors.As(err, &validationErr) {
+			respondWithJSON(w, http.StatusUnprocessableEntity, map[string]interface{}{
+				"status":  "error",
+				"message": validationErr.Error(),
+				"errors":  validationErr.Violations,
+			})
+			return
+		}
 		respondWithError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
@@ -97,13 +519,18 @@ func (s *Server) handleGetEcoScore(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	score := s.analyzer.CalculateEcoScore(metrics)
+	if req.InstanceType != "" {
+		s.analyzer.RegisterInstance(req.ServerID, req.InstanceType)
+	}
+	scores := s.analyzer.CalculateEcoScores(req.ServerID, metrics)
 
 	respondWithJSON(w, http.StatusOK, map[string]interface{}{
 		"status": "success",
 		"data": map[string]interface{}{
 			"server_id": req.ServerID,
-			"eco_score": score,
+			"eco_score": scores.Raw,
+			"normalized_eco_score": scores.Normalized,
+			"instance_type": scores.InstanceType,
 			"period": req.Period,
 		},
 	})
@@ -123,6 +550,27 @@ func respondWithError(w http.ResponseWriter, code int, message string) {
 	})
 }
 
+// respondWithProviderError отвечает на ошибку облачного провайдера кодом по ее классу;
+// неклассифицированные ошибки получают fallback
+func respondWithProviderError(w http.ResponseWriter, err error, fallback int) {
+	code := fallback
+	switch cloud.Kind(err) {
+	case cloud.ErrThrottled:
+		code = http.StatusServiceUnavailable
+		w.Header().Set("Retry-After", strconv.Itoa(int(cloud.RetryAfter(err, time.Minute).Seconds())))
+	case cloud.ErrNotFound:
+		code = http.StatusNotFound
+	case cloud.ErrUnauthorized:
+		// Учетные данные платформы отклонены провайдером - это не ошибка клиента API
+		code = http.StatusBadGateway
+	case cloud.ErrCapacity:
+		code = http.StatusConflict
+	case cloud.ErrUnsupported:
+		code = http.StatusNotImplemented
+	}
+	respondWithError(w, code, err.Error())
+}
+
 func respondWithJSON(w http.ResponseWriter, code int, payload interface{}) {
 	response, err := json.Marshal(payload)
 	if err != nil {
@@ -0,0 +1,66 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+type ImageScanRequest struct {
+	Image string `json:"image"`
+}
+
+func (s *Server) handleGetImageReports(w http.ResponseWriter, r *http.Request) {
+	if s.images == nil {
+		respondWithError(w, http.StatusNotImplemented, "image scanning is disabled")
+		return
+	}
+
+	if image := r.URL.Query().Get("image"); image != "" {
+		report, exists := s.images.Report(image)
+		if !exists {
+			respondWithError(w, http.StatusNotFound, "image has not been scanned yet")
+			return
+		}
+		respondWithJSON(w, http.StatusOK, map[string]interface{}{
+			"status": "success",
+			"data":   report,
+		})
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"status": "success",
+		"data":   s.images.Reports(),
+	})
+}
+
+// handleScanImage сканирует образ немедленно и добавляет его в периодическое сканирование
+func (s *Server) handleScanImage(w http.ResponseWriter, r *http.Request) {
+	if s.images == nil {
+		respondWithError(w, http.StatusNotImplemented, "image scanning is disabled")
+		return
+	}
+
+	var req ImageScanRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Image == "" {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	defer r.Body.Close()
+
+	if err := s.images.CheckRegistry(req.Image); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	report := s.images.Scan(r.Context(), req.Image)
+	if report.Error != "" {
+		respondWithError(w, http.StatusBadGateway, report.Error)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"status": "success",
+		"data":   report,
+	})
+}
@@ -0,0 +1,80 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/YumeNoTenshi/platypus/internal/incidents"
+	"github.com/gorilla/mux"
+)
+
+func (s *Server) requireIncidents(w http.ResponseWriter) bool {
+	if s.incidents == nil {
+		respondWithError(w, http.StatusNotImplemented, "incident grouping is disabled")
+		return false
+	}
+	return true
+}
+
+// handleListIncidents возвращает инциденты; ?status=open|acknowledged|resolved
+func (s *Server) handleListIncidents(w http.ResponseWriter, r *http.Request) {
+	if !s.requireIncidents(w) {
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"status": "success",
+		"data":   s.incidents.List(incidents.Status(r.URL.Query().Get("status"))),
+	})
+}
+
+func (s *Server) handleGetIncident(w http.ResponseWriter, r *http.Request) {
+	if !s.requireIncidents(w) {
+		return
+	}
+
+	incident, err := s.incidents.Get(mux.Vars(r)["id"])
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"status": "success",
+		"data":   incident,
+	})
+}
+
+// handleUpdateIncident подтверждает, закрывает или вновь открывает инцидент
+func (s *Server) handleUpdateIncident(w http.ResponseWriter, r *http.Request) {
+	if !s.requireIncidents(w) {
+		return
+	}
+
+	var req struct {
+		Status incidents.Status `json:"status"`
+		Note   string           `json:"note"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	defer r.Body.Close()
+
+	incident, err := s.incidents.SetStatus(r.Context(), mux.Vars(r)["id"], req.Status, req.Note)
+	switch {
+	case errors.Is(err, incidents.ErrNotFound):
+		respondWithError(w, http.StatusNotFound, err.Error())
+		return
+	case errors.Is(err, incidents.ErrInvalidStatus):
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	case err != nil:
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"status": "success",
+		"data":   incident,
+	})
+}
@@ -0,0 +1,55 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/YumeNoTenshi/platypus/internal/metrics"
+)
+
+func (s *Server) handleGetSourceUnits(w http.ResponseWriter, r *http.Request) {
+	source := mux.Vars(r)["source"]
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"status": "success",
+		"data":   s.collector.Units().Units(source),
+	})
+}
+
+// handlePutSourceUnits объявляет единицы измерения, в которых источник присылает метрики
+func (s *Server) handlePutSourceUnits(w http.ResponseWriter, r *http.Request) {
+	var units metrics.SourceUnits
+	if err := json.NewDecoder(r.Body).Decode(&units); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	defer r.Body.Close()
+
+	source := mux.Vars(r)["source"]
+	if err := s.collector.Units().Declare(source, units); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"status": "success",
+		"data":   s.collector.Units().Units(source),
+	})
+}
+
+func (s *Server) handleGetIngestDiagnostics(w http.ResponseWriter, r *http.Request) {
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"status": "success",
+		"data":   s.collector.Units().Diagnostics(),
+	})
+}
+
+// handleGetIngestRejections возвращает число точек, отклоненных проверкой
+// перед сохранением, по причинам
+func (s *Server) handleGetIngestRejections(w http.ResponseWriter, r *http.Request) {
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"status": "success",
+		"data":   s.collector.Rejections(),
+	})
+}
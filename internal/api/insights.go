@@ -0,0 +1,112 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/YumeNoTenshi/platypus/internal/insights"
+	"github.com/YumeNoTenshi/platypus/pkg/carbon"
+)
+
+// handleGetTopOffenders возвращает N худших серверов или сервисов.
+// Параметры: scope=servers|services|teams, by=power|carbon|regression, n, window (например, 168h).
+func (s *Server) handleGetTopOffenders(w http.ResponseWriter, r *http.Request) {
+	if s.insights == nil {
+		respondWithError(w, http.StatusNotImplemented, "insights are disabled")
+		return
+	}
+
+	query := insights.OffenderQuery{
+		Scope: insights.Scope(r.URL.Query().Get("scope")),
+		By:    insights.RankBy(r.URL.Query().Get("by")),
+	}
+
+	if n := r.URL.Query().Get("n"); n != "" {
+		limit, err := strconv.Atoi(n)
+		if err != nil || limit <= 0 {
+			respondWithError(w, http.StatusBadRequest, "n must be a positive integer")
+			return
+		}
+		query.Limit = limit
+	}
+
+	if window := r.URL.Query().Get("window"); window != "" {
+		duration, err := time.ParseDuration(window)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "invalid window: "+err.Error())
+			return
+		}
+		query.Window = duration
+	}
+
+	offenders, err := s.insights.TopOffenders(query)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"status": "success",
+		"data":   offenders,
+	})
+}
+
+// handleGetGreenWindows возвращает ближайшие окна с самой чистой прогнозной
+// энергией для задания заданной длительности, чтобы команды сами планировали
+// пакетные задания. Параметры: region, duration (например, 2h), horizon, n.
+func (s *Server) handleGetGreenWindows(w http.ResponseWriter, r *http.Request) {
+	if s.insights == nil {
+		respondWithError(w, http.StatusNotImplemented, "insights are disabled")
+		return
+	}
+
+	query := insights.GreenWindowQuery{Region: r.URL.Query().Get("region")}
+	if query.Region == "" {
+		respondWithError(w, http.StatusBadRequest, "region is required")
+		return
+	}
+	for name, target := range map[string]*time.Duration{"duration": &query.Duration, "horizon": &query.Horizon} {
+		value := r.URL.Query().Get(name)
+		if value == "" {
+			continue
+		}
+		duration, err := time.ParseDuration(value)
+		if err != nil || duration <= 0 {
+			respondWithError(w, http.StatusBadRequest, "invalid "+name+": expected a positive duration such as 2h")
+			return
+		}
+		*target = duration
+	}
+	if query.Duration == 0 {
+		respondWithError(w, http.StatusBadRequest, "duration is required")
+		return
+	}
+	if n := r.URL.Query().Get("n"); n != "" {
+		limit, err := strconv.Atoi(n)
+		if err != nil || limit <= 0 {
+			respondWithError(w, http.StatusBadRequest, "n must be a positive integer")
+			return
+		}
+		query.Limit = limit
+	}
+
+	windows, err := s.insights.GreenWindows(r.Context(), query, time.Now())
+	switch {
+	case errors.Is(err, insights.ErrNoCarbonForecast):
+		respondWithError(w, http.StatusNotImplemented, err.Error())
+		return
+	case errors.Is(err, carbon.ErrNoForecast):
+		respondWithError(w, http.StatusNotFound, err.Error())
+		return
+	case err != nil:
+		respondWithError(w, http.StatusBadGateway, "carbon forecast: "+err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"status": "success",
+		"data":   windows,
+	})
+}
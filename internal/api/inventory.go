@@ -0,0 +1,260 @@
+package api
+
+import (
+	"encoding/json"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/YumeNoTenshi/platypus/internal/inventory"
+)
+
+func (s *Server) requireInventory(w http.ResponseWriter) bool {
+	if s.inventory == nil {
+		respondWithError(w, http.StatusNotImplemented, "inventory is disabled")
+		return false
+	}
+	return true
+}
+
+func (s *Server) handleGetServerLabels(w http.ResponseWriter, r *http.Request) {
+	if !s.requireInventory(w) {
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"status": "success",
+		"data":   s.inventory.ServerLabels(mux.Vars(r)["server_id"]),
+	})
+}
+
+func (s *Server) handleGetServiceLabels(w http.ResponseWriter, r *http.Request) {
+	if !s.requireInventory(w) {
+		return
+	}
+
+	service := mux.Vars(r)["service"]
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"status": "success",
+		"data": map[string]interface{}{
+			"labels":   s.inventory.ServiceLabels(service),
+			"override": s.inventory.Override(service),
+		},
+	})
+}
+
+// handleSetServiceOverride задает ручные значения меток сервиса,
+// имеющие наивысший приоритет; пустой объект снимает переопределение
+func (s *Server) handleSetServiceOverride(w http.ResponseWriter, r *http.Request) {
+	if !s.requireInventory(w) {
+		return
+	}
+
+	var labels map[string]string
+	if err := json.NewDecoder(r.Body).Decode(&labels); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	service := mux.Vars(r)["service"]
+	s.inventory.SetOverride(service, labels)
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"status": "success",
+		"data":   s.inventory.ServiceLabels(service),
+	})
+}
+
+// handleSetNamespaceLabels принимает метки namespace Kubernetes
+// (например, от контроллера, наблюдающего за кластером)
+func (s *Server) handleSetNamespaceLabels(w http.ResponseWriter, r *http.Request) {
+	if !s.requireInventory(w) {
+		return
+	}
+
+	var labels map[string]string
+	if err := json.NewDecoder(r.Body).Decode(&labels); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	s.inventory.SetNamespaceLabels(mux.Vars(r)["namespace"], labels)
+	respondWithJSON(w, http.StatusOK, map[string]string{
+		"status": "success",
+	})
+}
+
+// metadataFormat берет формат из ?format= или из Content-Type тела
+func metadataFormat(r *http.Request) string {
+	if format := r.URL.Query().Get("format"); format != "" {
+		return strings.ToLower(format)
+	}
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch mediaType {
+	case "text/csv":
+		return inventory.FormatCSV
+	case "application/yaml", "application/x-yaml", "text/yaml":
+		return inventory.FormatYAML
+	}
+	return inventory.FormatJSON
+}
+
+// handleImportServerMetadata принимает метаданные серверов (владелец, метки,
+// исключения, класс простоя) файлом CSV, YAML или JSON. Запись заменяет
+// метаданные своего сервера; при ошибке в любой записи не применяется ничего.
+// ?dry_run=true только проверяет файл.
+func (s *Server) handleImportServerMetadata(w http.ResponseWriter, r *http.Request) {
+	if !s.requireInventory(w) {
+		return
+	}
+	defer r.Body.Close()
+
+	records, err := inventory.DecodeMetadata(r.Body, metadataFormat(r))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	dryRun := r.URL.Query().Get("dry_run") == "true"
+	if !dryRun {
+		if err := s.inventory.ImportMetadata(records); err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"status": "success",
+		"data": map[string]interface{}{
+			"servers": len(records),
+			"dry_run": dryRun,
+		},
+	})
+}
+
+// handleExportServerMetadata выгружает метаданные всех известных серверов
+// в формате ?format= (csv, yaml, json; по умолчанию json), пригодном для импорта
+func (s *Server) handleExportServerMetadata(w http.ResponseWriter, r *http.Request) {
+	if !s.requireInventory(w) {
+		return
+	}
+
+	format := strings.ToLower(r.URL.Query().Get("format"))
+	if format == "" {
+		format = inventory.FormatJSON
+	}
+	contentType, known := map[string]string{
+		inventory.FormatCSV:  "text/csv",
+		inventory.FormatYAML: "application/yaml",
+		inventory.FormatJSON: "application/json",
+	}[format]
+	if !known {
+		respondWithError(w, http.StatusBadRequest, "format must be csv, yaml or json")
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", `attachment; filename="servers.`+format+`"`)
+	w.WriteHeader(http.StatusOK)
+	inventory.EncodeMetadata(w, format, s.inventory.ExportMetadata())
+}
+
+// RegisterServersRequest - тело POST /inventory/servers/register
+type RegisterServersRequest struct {
+	Servers []inventory.Registration `json:"servers"`
+}
+
+// DeregisterServersRequest - тело POST /inventory/servers/deregister
+type DeregisterServersRequest struct {
+	ServerIDs []string `json:"server_ids"`
+}
+
+// handleListInventoryServers возвращает обнаруженные и зарегистрированные
+// серверы вместе с регистрациями (емкость, время регистрации)
+func (s *Server) handleListInventoryServers(w http.ResponseWriter, r *http.Request) {
+	if !s.requireInventory(w) {
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"status": "success",
+		"data": map[string]interface{}{
+			"servers":       s.inventory.Servers(),
+			"registrations": s.inventory.Registrations(),
+		},
+	})
+}
+
+// handleRegisterServers регистрирует пачку серверов; при ошибке в любой
+// записи не регистрируется ничего. Повторная регистрация обновляет запись.
+func (s *Server) handleRegisterServers(w http.ResponseWriter, r *http.Request) {
+	if !s.requireInventory(w) {
+		return
+	}
+
+	var req RegisterServersRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	defer r.Body.Close()
+	if len(req.Servers) == 0 {
+		respondWithError(w, http.StatusBadRequest, "servers is required")
+		return
+	}
+
+	registered, err := s.inventory.Register(req.Servers)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	for _, registration := range registered {
+		if registration.InstanceType != "" {
+			s.analyzer.RegisterInstance(registration.ServerID, registration.InstanceType)
+		}
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"status": "success",
+		"data":   registered,
+	})
+}
+
+// handleDeregisterServers снимает регистрацию пачки серверов. Точки серверов
+// не удаляются и стареют по сроку хранения.
+func (s *Server) handleDeregisterServers(w http.ResponseWriter, r *http.Request) {
+	if !s.requireInventory(w) {
+		return
+	}
+
+	var req DeregisterServersRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	defer r.Body.Close()
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"status": "success",
+		"data": map[string]interface{}{
+			"deregistered": s.inventory.Deregister(req.ServerIDs...),
+		},
+	})
+}
+
+// handleDeregisterServer снимает регистрацию одного сервера
+func (s *Server) handleDeregisterServer(w http.ResponseWriter, r *http.Request) {
+	if !s.requireInventory(w) {
+		return
+	}
+
+	serverID := mux.Vars(r)["server_id"]
+	if s.inventory.Deregister(serverID) == 0 {
+		respondWithError(w, http.StatusNotFound, "server is not registered: "+serverID)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, map[string]string{
+		"status": "success",
+	})
+}
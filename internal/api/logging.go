@@ -0,0 +1,185 @@
+package api
+
+import (
+	"encoding/json"
+	"log"
+	"math/rand/v2"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/YumeNoTenshi/platypus/internal/requestid"
+)
+
+// LogLevel определяет, какие запросы маршрута попадают в журнал
+type LogLevel string
+
+const (
+	LogOff    LogLevel = "off"    // Не журналировать
+	LogErrors LogLevel = "errors" // Только ответы со статусом 4xx/5xx
+	LogAll    LogLevel = "all"    // Все запросы (с учетом выборки и порога задержки)
+)
+
+// RouteLogRule - правило журналирования для маршрута
+type RouteLogRule struct {
+	Level         LogLevel      `json:"level"`
+	SampleRate    float64       `json:"sample_rate"`    // Доля журналируемых успешных запросов, 0-1
+	SlowThreshold time.Duration `json:"slow_threshold"` // Если > 0, успешные запросы журналируются только при превышении (в JSON - наносекунды)
+}
+
+// LoggingConfig задает правило по умолчанию и переопределения для маршрутов.
+// Ключ маршрута - метод и шаблон пути, например "POST /api/v1/metrics".
+type LoggingConfig struct {
+	Default RouteLogRule            `json:"default"`
+	Routes  map[string]RouteLogRule `json:"routes"`
+}
+
+// RequestLogger журналирует запросы согласно конфигурации, изменяемой во время работы
+type RequestLogger struct {
+	mu     sync.RWMutex
+	config LoggingConfig
+}
+
+func NewRequestLogger(config LoggingConfig) *RequestLogger {
+	if config.Routes == nil {
+		config.Routes = make(map[string]RouteLogRule)
+	}
+	return &RequestLogger{config: config}
+}
+
+func (l *RequestLogger) Config() LoggingConfig {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	config := LoggingConfig{
+		Default: l.config.Default,
+		Routes:  make(map[string]RouteLogRule, len(l.config.Routes)),
+	}
+	for route, rule := range l.config.Routes {
+		config.Routes[route] = rule
+	}
+	return config
+}
+
+func (l *RequestLogger) SetConfig(config LoggingConfig) {
+	if config.Routes == nil {
+		config.Routes = make(map[string]RouteLogRule)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.config = config
+}
+
+func (l *RequestLogger) rule(r *http.Request) RouteLogRule {
+	key := r.Method + " " + r.URL.Path
+	if route := mux.CurrentRoute(r); route != nil {
+		if template, err := route.GetPathTemplate(); err == nil {
+			key = r.Method + " " + template
+		}
+	}
+
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	if rule, exists := l.config.Routes[key]; exists {
+		return rule
+	}
+	return l.config.Default
+}
+
+func (l *RequestLogger) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+
+		// Вызов следующего обработчика
+		next.ServeHTTP(recorder, r)
+
+		elapsed := time.Since(start)
+		if !shouldLog(l.rule(r), recorder.status, elapsed) {
+			return
+		}
+
+		// Логирование после обработки запроса
+		log.Printf(
+			"%s%s %s %s %d %v",
+			requestid.Prefix(r.Context()),
+			r.Method,
+			redactedURI(r),
+			r.RemoteAddr,
+			recorder.status,
+			elapsed,
+		)
+	})
+}
+
+// redactedURI скрывает ключ API, переданный в адресе ленты календаря
+func redactedURI(r *http.Request) string {
+	query := r.URL.Query()
+	if !query.Has(feedKeyParam) {
+		return r.RequestURI
+	}
+	query.Set(feedKeyParam, "REDACTED")
+	return r.URL.Path + "?" + query.Encode()
+}
+
+func shouldLog(rule RouteLogRule, status int, elapsed time.Duration) bool {
+	switch rule.Level {
+	case LogOff:
+		return false
+	case LogErrors:
+		return status >= http.StatusBadRequest
+	}
+
+	// Ошибки журналируются всегда, независимо от выборки
+	if status >= http.StatusBadRequest {
+		return true
+	}
+	if rule.SlowThreshold > 0 && elapsed < rule.SlowThreshold {
+		return false
+	}
+	return rule.SampleRate >= 1 || rand.Float64() < rule.SampleRate
+}
+
+// statusRecorder запоминает код ответа для журналирования
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(code int) {
+	r.status = code
+	r.ResponseWriter.WriteHeader(code)
+}
+
+// Unwrap открывает http.ResponseController исходный ответ, например для
+// Flush в потоке событий
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+func (s *Server) handleGetLoggingConfig(w http.ResponseWriter, r *http.Request) {
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"status": "success",
+		"data":   s.logger.Config(),
+	})
+}
+
+func (s *Server) handlePutLoggingConfig(w http.ResponseWriter, r *http.Request) {
+	var config LoggingConfig
+	if err := json.NewDecoder(r.Body).Decode(&config); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	defer r.Body.Close()
+
+	s.logger.SetConfig(config)
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"status": "success",
+		"data":   s.logger.Config(),
+	})
+}
@@ -1,40 +1,41 @@
 package api
 
 import (
-	"log"
+	"errors"
 	"net/http"
-	"time"
+
+	"github.com/YumeNoTenshi/platypus/internal/requestid"
 )
 
-func LoggingMiddleware(next http.Handler) http.Handler {
+// requestIDMiddleware присваивает запросу идентификатор: принимает
+// X-Request-ID клиента или создает новый. Идентификатор возвращается в
+// ответе и передается через контекст в действия, запущенные запросом.
+func requestIDMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		start := time.Now()
-
-		// Вызов следующего обработчика
-		next.ServeHTTP(w, r)
-
-		// Логирование после обработки запроса
-		log.Printf(
-			"%s %s %s %v",
-			r.Method,
-			r.RequestURI,
-			r.RemoteAddr,
-			time.Since(start),
-		)
+		id := r.Header.Get(requestid.Header)
+		if !requestid.Valid(id) {
+			id = requestid.New()
+		}
+		w.Header().Set(requestid.Header, id)
+		next.ServeHTTP(w, r.WithContext(requestid.NewContext(r.Context(), id)))
 	})
 }
 
-func AuthMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		token := r.Header.Get("X-API-Key")
-		
-		// В реальном приложении здесь должна быть проверка токена
-		if token == "" {
-			respondWithError(w, http.StatusUnauthorized, "No API key provided")
-			return
-		}
+func AuthMiddleware(provider AuthProvider) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			principal, err := provider.ValidateRequest(r)
+			if errors.Is(err, ErrNoCredentials) {
+				respondWithError(w, http.StatusUnauthorized, "No credentials provided")
+				return
+			}
+			if err != nil {
+				respondWithError(w, http.StatusUnauthorized, err.Error())
+				return
+			}
 
-		// Продолжаем выполнение
-		next.ServeHTTP(w, r)
-	})
+			// Продолжаем выполнение с клиентом в контексте запроса
+			next.ServeHTTP(w, r.WithContext(ContextWithPrincipal(r.Context(), principal)))
+		})
+	}
 } 
\ No newline at end of file
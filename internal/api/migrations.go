@@ -0,0 +1,66 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/YumeNoTenshi/platypus/internal/migration"
+	"github.com/gorilla/mux"
+)
+
+// handleGetMigrationPreview возвращает очередь миграций и контейнеры, для
+// которых цель не выбрана из-за ограничений размещения, с причинами отказа
+func (s *Server) handleGetMigrationPreview(w http.ResponseWriter, r *http.Request) {
+	if s.planner == nil {
+		respondWithError(w, http.StatusNotImplemented, "migration planner is disabled")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"status": "success",
+		"data":   s.planner.Preview(),
+	})
+}
+
+// handleExplainMigration объясняет последнее решение планировщика по
+// контейнеру: входные данные, пороги, рассмотренные цели и причины отказа
+func (s *Server) handleExplainMigration(w http.ResponseWriter, r *http.Request) {
+	if s.planner == nil {
+		respondWithError(w, http.StatusNotImplemented, "migration planner is disabled")
+		return
+	}
+
+	containerID := mux.Vars(r)["container_id"]
+	// Контейнер чужого сервера неотличим от нерассмотренного
+	explanation, exists := s.planner.Explain(containerID)
+	if !exists || !s.allowedServer(r, explanation.SourceServerID) {
+		respondWithError(w, http.StatusNotFound, "no planning decision for container "+containerID)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"status": "success",
+		"data":   explanation,
+	})
+}
+
+// handleGetMigrationHistory возвращает выполненные миграции от новых к
+// старым с запусками контейнера после каждой; ?container_id= - одного контейнера
+func (s *Server) handleGetMigrationHistory(w http.ResponseWriter, r *http.Request) {
+	if s.planner == nil {
+		respondWithError(w, http.StatusNotImplemented, "migration planner is disabled")
+		return
+	}
+
+	all := s.planner.History(r.URL.Query().Get("container_id"))
+	records := make([]migration.MigrationRecord, 0, len(all))
+	for _, record := range all {
+		if s.allowedServer(r, record.SourceServerID) && s.allowedServer(r, record.TargetServerID) {
+			records = append(records, record)
+		}
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"status": "success",
+		"data":   records,
+	})
+}
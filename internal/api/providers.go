@@ -0,0 +1,58 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// ProviderModeRequest - тело PUT /providers/{name}/mode
+type ProviderModeRequest struct {
+	ObserveOnly *bool `json:"observe_only"`
+}
+
+func (s *Server) requireRollout(w http.ResponseWriter) bool {
+	if s.rollout == nil {
+		respondWithError(w, http.StatusNotImplemented, "provider modes are disabled")
+		return false
+	}
+	return true
+}
+
+// handleListProviderModes возвращает провайдеров с заданным режимом наблюдения
+func (s *Server) handleListProviderModes(w http.ResponseWriter, r *http.Request) {
+	if !s.requireRollout(w) {
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"status": "success",
+		"data":   s.rollout.Modes(),
+	})
+}
+
+// handlePutProviderMode включает или снимает режим наблюдения провайдера:
+// после снятия его серверы участвуют в миграциях и масштабировании со
+// следующего цикла
+func (s *Server) handlePutProviderMode(w http.ResponseWriter, r *http.Request) {
+	if !s.requireRollout(w) {
+		return
+	}
+
+	var req ProviderModeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	defer r.Body.Close()
+	if req.ObserveOnly == nil {
+		respondWithError(w, http.StatusBadRequest, "observe_only is required")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"status": "success",
+		"data":   s.rollout.SetObserveOnly(mux.Vars(r)["name"], *req.ObserveOnly),
+	})
+}
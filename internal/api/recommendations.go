@@ -0,0 +1,150 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/YumeNoTenshi/platypus/internal/recommendations"
+)
+
+type RecommendationStateRequest struct {
+	State recommendations.State `json:"state"`
+}
+
+func (s *Server) requireRecommendations(w http.ResponseWriter) bool {
+	if s.recommendations == nil {
+		respondWithError(w, http.StatusNotImplemented, "recommendations are disabled")
+		return false
+	}
+	return true
+}
+
+func (s *Server) handleListRecommendations(w http.ResponseWriter, r *http.Request) {
+	if !s.requireRecommendations(w) {
+		return
+	}
+
+	filter := recommendations.Filter{
+		State:    recommendations.State(r.URL.Query().Get("state")),
+		Type:     recommendations.Type(r.URL.Query().Get("type")),
+		TargetID: r.URL.Query().Get("target_id"),
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"status": "success",
+		"data":   s.recommendations.List(filter),
+	})
+}
+
+func (s *Server) handleCreateRecommendation(w http.ResponseWriter, r *http.Request) {
+	if !s.requireRecommendations(w) {
+		return
+	}
+
+	var item recommendations.Recommendation
+	if err := json.NewDecoder(r.Body).Decode(&item); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	defer r.Body.Close()
+
+	created, err := s.recommendations.Create(item)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, map[string]interface{}{
+		"status": "success",
+		"data":   created,
+	})
+}
+
+func (s *Server) handleGetRecommendation(w http.ResponseWriter, r *http.Request) {
+	if !s.requireRecommendations(w) {
+		return
+	}
+
+	item, err := s.recommendations.Get(mux.Vars(r)["id"])
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"status": "success",
+		"data":   item,
+	})
+}
+
+// handleUpdateRecommendation меняет состояние рекомендации (accepted, dismissed, implemented, open)
+func (s *Server) handleUpdateRecommendation(w http.ResponseWriter, r *http.Request) {
+	if !s.requireRecommendations(w) {
+		return
+	}
+
+	var req RecommendationStateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	defer r.Body.Close()
+
+	id := mux.Vars(r)["id"]
+	if _, err := s.recommendations.Get(id); err != nil {
+		respondWithError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	item, err := s.recommendations.SetState(r.Context(), id, req.State)
+	if err != nil {
+		respondWithError(w, http.StatusConflict, err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"status": "success",
+		"data":   item,
+	})
+}
+
+func (s *Server) handleDeleteRecommendation(w http.ResponseWriter, r *http.Request) {
+	if !s.requireRecommendations(w) {
+		return
+	}
+
+	if err := s.recommendations.Delete(mux.Vars(r)["id"]); err != nil {
+		respondWithError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]string{
+		"status": "success",
+	})
+}
+
+// handleGetSavingsForecast проецирует экономию при внедрении всех незакрытых рекомендаций.
+// Параметр horizon (например, 720h) переопределяет горизонт по умолчанию.
+func (s *Server) handleGetSavingsForecast(w http.ResponseWriter, r *http.Request) {
+	if s.forecaster == nil {
+		respondWithError(w, http.StatusNotImplemented, "savings forecast is disabled")
+		return
+	}
+
+	var horizon time.Duration
+	if value := r.URL.Query().Get("horizon"); value != "" {
+		parsed, err := time.ParseDuration(value)
+		if err != nil || parsed <= 0 {
+			respondWithError(w, http.StatusBadRequest, "invalid horizon")
+			return
+		}
+		horizon = parsed
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"status": "success",
+		"data":   s.forecaster.Forecast(horizon),
+	})
+}
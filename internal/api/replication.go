@@ -0,0 +1,65 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/YumeNoTenshi/platypus/internal/replication"
+)
+
+func (s *Server) requireStandby(w http.ResponseWriter) bool {
+	if s.standby == nil {
+		respondWithError(w, http.StatusNotImplemented, "replication standby mode is disabled")
+		return false
+	}
+	return true
+}
+
+// handlePostReplicationBatches применяет конверт основного инстанса.
+// 409 просит основной инстанс прислать полный снимок окна.
+func (s *Server) handlePostReplicationBatches(w http.ResponseWriter, r *http.Request) {
+	if !s.requireStandby(w) {
+		return
+	}
+
+	var envelope replication.Envelope
+	if err := json.NewDecoder(r.Body).Decode(&envelope); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	defer r.Body.Close()
+
+	if err := s.standby.Apply(envelope); err != nil {
+		if errors.Is(err, replication.ErrSnapshotRequired) {
+			respondWithError(w, http.StatusConflict, err.Error())
+			return
+		}
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]string{
+		"status": "success",
+	})
+}
+
+func (s *Server) handleGetReplicationStatus(w http.ResponseWriter, r *http.Request) {
+	if s.replicator == nil && s.standby == nil {
+		respondWithError(w, http.StatusNotImplemented, "replication is disabled")
+		return
+	}
+
+	status := make(map[string]interface{})
+	if s.replicator != nil {
+		status["primary"] = s.replicator.Stats()
+	}
+	if s.standby != nil {
+		status["standby"] = s.standby.Stats()
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"status": "success",
+		"data":   status,
+	})
+}
@@ -0,0 +1,187 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/YumeNoTenshi/platypus/internal/reports"
+	"github.com/gorilla/mux"
+)
+
+func (s *Server) requireReports(w http.ResponseWriter) bool {
+	if s.reports == nil {
+		respondWithError(w, http.StatusNotImplemented, "reports are disabled")
+		return false
+	}
+	return true
+}
+
+func (s *Server) handleListReports(w http.ResponseWriter, r *http.Request) {
+	if !s.requireReports(w) {
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"status": "success",
+		"data":   s.reports.List(),
+	})
+}
+
+func (s *Server) handleGetReport(w http.ResponseWriter, r *http.Request) {
+	if !s.requireReports(w) {
+		return
+	}
+
+	report, err := s.reports.Get(mux.Vars(r)["id"])
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"status": "success",
+		"data":   report,
+	})
+}
+
+func (s *Server) requireSubscriptions(w http.ResponseWriter) bool {
+	if s.subscriptions == nil {
+		respondWithError(w, http.StatusNotImplemented, "report subscriptions are disabled")
+		return false
+	}
+	return true
+}
+
+// handleListReportSubscriptions возвращает подписки; ?owner= оставляет
+// подписки одного клиента API, ?owner=me - подписки клиента запроса
+func (s *Server) handleListReportSubscriptions(w http.ResponseWriter, r *http.Request) {
+	if !s.requireSubscriptions(w) {
+		return
+	}
+
+	owner := r.URL.Query().Get("owner")
+	if owner == "me" {
+		owner = requestClient(r)
+	}
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"status": "success",
+		"data":   s.subscriptions.List(owner),
+	})
+}
+
+// handleCreateReportSubscription заводит подписку; владельцем становится
+// клиент API запроса
+func (s *Server) handleCreateReportSubscription(w http.ResponseWriter, r *http.Request) {
+	if !s.requireSubscriptions(w) {
+		return
+	}
+
+	var subscription reports.Subscription
+	if err := json.NewDecoder(r.Body).Decode(&subscription); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	defer r.Body.Close()
+
+	subscription.Owner = requestClient(r)
+	created, err := s.subscriptions.Create(subscription)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	respondWithJSON(w, http.StatusCreated, map[string]interface{}{
+		"status": "success",
+		"data":   created,
+	})
+}
+
+func (s *Server) handleGetReportSubscription(w http.ResponseWriter, r *http.Request) {
+	if !s.requireSubscriptions(w) {
+		return
+	}
+
+	subscription, err := s.subscriptions.Get(mux.Vars(r)["id"])
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"status": "success",
+		"data":   subscription,
+	})
+}
+
+func (s *Server) handleUpdateReportSubscription(w http.ResponseWriter, r *http.Request) {
+	if !s.requireSubscriptions(w) {
+		return
+	}
+
+	var subscription reports.Subscription
+	if err := json.NewDecoder(r.Body).Decode(&subscription); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	defer r.Body.Close()
+
+	updated, err := s.subscriptions.Update(mux.Vars(r)["id"], subscription)
+	switch {
+	case errors.Is(err, reports.ErrSubscriptionNotFound):
+		respondWithError(w, http.StatusNotFound, err.Error())
+		return
+	case err != nil:
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"status": "success",
+		"data":   updated,
+	})
+}
+
+func (s *Server) handleDeleteReportSubscription(w http.ResponseWriter, r *http.Request) {
+	if !s.requireSubscriptions(w) {
+		return
+	}
+
+	if err := s.subscriptions.Delete(mux.Vars(r)["id"]); err != nil {
+		respondWithError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	respondWithJSON(w, http.StatusOK, map[string]string{"status": "success"})
+}
+
+// handleSendReportSubscription отправляет отчет подписки сейчас, чтобы
+// проверить получателей; ошибки доставки возвращаются с кодом 502 вместе
+// с отчетом
+func (s *Server) handleSendReportSubscription(w http.ResponseWriter, r *http.Request) {
+	if !s.requireSubscriptions(w) {
+		return
+	}
+
+	report, err := s.subscriptions.SendNow(r.Context(), mux.Vars(r)["id"])
+	switch {
+	case errors.Is(err, reports.ErrSubscriptionNotFound):
+		respondWithError(w, http.StatusNotFound, err.Error())
+		return
+	case err != nil:
+		respondWithJSON(w, http.StatusBadGateway, map[string]interface{}{
+			"status":  "error",
+			"message": err.Error(),
+			"data":    report,
+		})
+		return
+	}
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"status": "success",
+		"data":   report,
+	})
+}
+
+// requestClient возвращает идентификатор клиента API запроса
+func requestClient(r *http.Request) string {
+	if principal, ok := PrincipalFromContext(r.Context()); ok && principal != nil {
+		return principal.ID
+	}
+	return ""
+}
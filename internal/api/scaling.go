@@ -0,0 +1,155 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// handleExplainScaling объясняет последнее решение автоскейлера по серверу:
+// последняя точка, пороги и паузы, причины и рассмотренные цели переноса
+func (s *Server) handleExplainScaling(w http.ResponseWriter, r *http.Request) {
+	if s.autoscaler == nil {
+		respondWithError(w, http.StatusNotImplemented, "autoscaler is disabled")
+		return
+	}
+
+	serverID := mux.Vars(r)["server_id"]
+	explanation, exists := s.autoscaler.Explain(serverID)
+	if !exists {
+		respondWithError(w, http.StatusNotFound, "no scaling decision for server "+serverID)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"status": "success",
+		"data":   explanation,
+	})
+}
+
+// ScalingGroup - группа серверов под управлением автоскейлера
+type ScalingGroup struct {
+	GroupID string   `json:"group_id"`
+	Name    string   `json:"name"`
+	Servers []string `json:"servers"` // Текущий состав группы
+}
+
+// requireScalingGroups проверяет, что включены автоскейлер и группы серверов
+func (s *Server) requireScalingGroups(w http.ResponseWriter) bool {
+	if s.autoscaler == nil {
+		respondWithError(w, http.StatusNotImplemented, "autoscaler is disabled")
+		return false
+	}
+	return s.requireGroups(w)
+}
+
+// scalingGroup описывает группу ref, если она под управлением автоскейлера
+func (s *Server) scalingGroup(ref string) (ScalingGroup, error) {
+	group, err := s.groups.Get(ref)
+	if err != nil {
+		return ScalingGroup{}, err
+	}
+	managed := false
+	for _, scaled := range s.autoscaler.ScalingGroups() {
+		if scaled == group.ID || scaled == group.Name {
+			managed = true
+			break
+		}
+	}
+	if !managed {
+		return ScalingGroup{}, fmt.Errorf("group %s is not managed by the autoscaler", ref)
+	}
+
+	members, err := s.groups.Members(group.ID)
+	if err != nil {
+		return ScalingGroup{}, err
+	}
+	return ScalingGroup{GroupID: group.ID, Name: group.Name, Servers: members}, nil
+}
+
+// handleListScalingGroups перечисляет группы под управлением автоскейлера.
+// Пустой список - автоскейлер управляет всеми серверами.
+func (s *Server) handleListScalingGroups(w http.ResponseWriter, r *http.Request) {
+	if !s.requireScalingGroups(w) {
+		return
+	}
+
+	scalingGroups := make([]ScalingGroup, 0)
+	for _, ref := range s.autoscaler.ScalingGroups() {
+		group, err := s.scalingGroup(ref)
+		if err != nil {
+			// Группа из конфигурации могла быть удалена
+			continue
+		}
+		scalingGroups = append(scalingGroups, group)
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"status": "success",
+		"data":   scalingGroups,
+	})
+}
+
+func (s *Server) handleGetScalingGroup(w http.ResponseWriter, r *http.Request) {
+	if !s.requireScalingGroups(w) {
+		return
+	}
+
+	group, err := s.scalingGroup(mux.Vars(r)["id"])
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"status": "success",
+		"data":   group,
+	})
+}
+
+// handlePutScalingGroup ставит сохраненную группу под управление автоскейлера
+func (s *Server) handlePutScalingGroup(w http.ResponseWriter, r *http.Request) {
+	if !s.requireScalingGroups(w) {
+		return
+	}
+
+	group, err := s.groups.Get(mux.Vars(r)["id"])
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	s.autoscaler.AddScalingGroup(group.ID)
+
+	scalingGroup, err := s.scalingGroup(group.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"status": "success",
+		"data":   scalingGroup,
+	})
+}
+
+// handleDeleteScalingGroup снимает группу с управления автоскейлера
+func (s *Server) handleDeleteScalingGroup(w http.ResponseWriter, r *http.Request) {
+	if !s.requireScalingGroups(w) {
+		return
+	}
+
+	// Группа, заданная в конфигурации именем, снимается и по ID
+	ref := mux.Vars(r)["id"]
+	removed := s.autoscaler.RemoveScalingGroup(ref)
+	if group, err := s.groups.Get(ref); !removed && err == nil {
+		removed = s.autoscaler.RemoveScalingGroup(group.ID)
+	}
+	if !removed {
+		respondWithError(w, http.StatusNotFound, fmt.Sprintf("group %s is not managed by the autoscaler", ref))
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]string{
+		"status": "success",
+	})
+}
@@ -0,0 +1,335 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/YumeNoTenshi/platypus/internal/selector"
+	"github.com/gorilla/mux"
+)
+
+// Access - какие операции разрешены ключу
+type Access string
+
+const (
+	AccessAll   Access = ""      // Чтение и запись
+	AccessRead  Access = "read"  // Только чтение
+	AccessWrite Access = "write" // Только запись, например ключ агента
+)
+
+// ErrOutOfScope возвращается, если запрос выходит за пределы области ключа
+var ErrOutOfScope = errors.New("request is outside the key scope")
+
+// maxScopeBody - сколько байт тела запроса читается, чтобы найти server_id
+const maxScopeBody = 1 << 20
+
+// Scope ограничивает ключ подмножеством серверов. Сервер входит в область,
+// если его идентификатор подходит под один из шаблонов Servers и его метки
+// инвентаря подходят под Selector; пустое условие не ограничивает.
+//
+// Ключ с Tenant принадлежит арендатору: его точки помечаются арендатором,
+// а остальные условия области действуют только внутри серверов арендатора.
+type Scope struct {
+	Tenant   string            `json:"tenant,omitempty"`
+	Access   Access            `json:"access,omitempty"`
+	Servers  []string          `json:"servers,omitempty"`  // Идентификаторы или шаблоны path.Match, например team-a-*
+	Selector selector.Selector `json:"selector,omitempty"` // По каноническим меткам, например "team=payments,environment!=dev"
+}
+
+// LoadScopes читает области ключей из JSON-файла: идентификатор клиента
+// (из PLATYPUS_API_KEYS) -> область
+func LoadScopes(path string) (map[string]Scope, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var scopes map[string]Scope
+	if err := json.Unmarshal(data, &scopes); err != nil {
+		return nil, fmt.Errorf("invalid key scope file %s: %w", path, err)
+	}
+	for id, scope := range scopes {
+		if err := scope.validate(); err != nil {
+			return nil, fmt.Errorf("scope for %s: %w", id, err)
+		}
+	}
+	return scopes, nil
+}
+
+// tenantName - допустимое имя арендатора
+var tenantName = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,62}$`)
+
+func (sc Scope) validate() error {
+	if sc.Tenant != "" && (!tenantName.MatchString(sc.Tenant) || sc.Tenant == "default") {
+		return fmt.Errorf("invalid tenant %q: use lowercase letters, digits, - and _; default is reserved", sc.Tenant)
+	}
+	switch sc.Access {
+	case AccessAll, AccessRead, AccessWrite:
+	default:
+		return fmt.Errorf("unknown access %q", sc.Access)
+	}
+	for _, pattern := range sc.Servers {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid server pattern %q: %w", pattern, err)
+		}
+	}
+	return nil
+}
+
+// Allows проверяет, что ключу разрешена операция над сервером. labels
+// возвращает метки сервера; nil-область ничего не ограничивает.
+func (sc *Scope) Allows(serverID string, write bool, labels func(serverID string) map[string]string) error {
+	if sc == nil {
+		return nil
+	}
+	if err := sc.allowsAccess(write); err != nil {
+		return err
+	}
+
+	if len(sc.Servers) > 0 && !sc.matchesServer(serverID) {
+		return fmt.Errorf("%w: server %s", ErrOutOfScope, serverID)
+	}
+	if !sc.Selector.Empty() {
+		var values map[string]string
+		if labels != nil {
+			values = labels(serverID)
+		}
+		if !sc.Selector.Matches(values) {
+			return fmt.Errorf("%w: server %s does not match %s", ErrOutOfScope, serverID, sc.Selector)
+		}
+	}
+	return nil
+}
+
+func (sc *Scope) allowsAccess(write bool) error {
+	switch {
+	case write && sc.Access == AccessRead:
+		return fmt.Errorf("%w: key is read-only", ErrOutOfScope)
+	case !write && sc.Access == AccessWrite:
+		return fmt.Errorf("%w: key is write-only", ErrOutOfScope)
+	}
+	return nil
+}
+
+func (sc *Scope) matchesServer(serverID string) bool {
+	for _, pattern := range sc.Servers {
+		if matched, _ := path.Match(pattern, serverID); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// readOnlyPosts - маршруты, принимающие запрос в теле POST, но ничего не меняющие
+var readOnlyPosts = map[string]bool{
+	"/api/v1/eco-score":            true,
+	"/api/v1/eco-score/batch":      true,
+	"/api/v1/simulate/region-move": true,
+}
+
+// scopedLists - списки, которые ключ с областью читает без указания сервера:
+// обработчик сам оставляет в ответе только серверы области
+var scopedLists = map[string]bool{
+	"/api/v1/analysis/correlations":               true,
+	"/api/v1/analysis/fleet":                      true,
+	"/api/v1/containers":                          true,
+	"/api/v1/containers/events":                   true,
+	"/api/v1/migrations/history":                  true,
+	"/api/v1/metrics":                             true,
+	"/api/v1/eco-tags/profiles/{service}/history": true,
+}
+
+// scopedBatches - пачки точек многих серверов: серверы перечислены в
+// каждой точке, а не в запросе, поэтому область и арендатора проверяет
+// обработчик для каждой точки и отклоняет чужие по позиции
+var scopedBatches = map[string]bool{
+	"/api/v1/metrics/batch": true,
+}
+
+// scopeMiddleware применяет область ключа ко всем защищенным маршрутам.
+// Серверы запроса берутся из пути, параметров server_id и group и полей
+// server_id, server_ids и group тела. Запрос ключа с областью, не
+// называющий ни одного сервера, отклоняется: такие маршруты действуют на
+// весь парк.
+func (s *Server) scopeMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		principal, _ := PrincipalFromContext(r.Context())
+		if principal == nil || principal.Scope == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		template := routeTemplate(r)
+		write := r.Method != http.MethodGet && r.Method != http.MethodHead && !readOnlyPosts[template]
+		if err := principal.Scope.allowsAccess(write); err != nil {
+			respondWithError(w, http.StatusForbidden, err.Error())
+			return
+		}
+		if scopedBatches[template] {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		serverIDs, err := s.requestServers(r, template)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if len(serverIDs) == 0 {
+			if !write && scopedLists[template] {
+				next.ServeHTTP(w, r)
+				return
+			}
+			respondWithError(w, http.StatusForbidden, ErrOutOfScope.Error()+": key is limited to servers and the request names none")
+			return
+		}
+
+		for _, serverID := range serverIDs {
+			if err := s.allowsTenant(principal, serverID); err != nil {
+				respondWithError(w, http.StatusForbidden, err.Error())
+				return
+			}
+			if err := principal.Scope.Allows(serverID, write, s.serverLabels); err != nil {
+				respondWithError(w, http.StatusForbidden, err.Error())
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func routeTemplate(r *http.Request) string {
+	route := mux.CurrentRoute(r)
+	if route == nil {
+		return r.URL.Path
+	}
+	template, err := route.GetPathTemplate()
+	if err != nil {
+		return r.URL.Path
+	}
+	return template
+}
+
+// requestServers собирает серверы, к которым обращается запрос
+func (s *Server) requestServers(r *http.Request, template string) ([]string, error) {
+	var serverIDs []string
+	add := func(ids ...string) {
+		for _, id := range ids {
+			if id = strings.TrimSpace(id); id != "" {
+				serverIDs = append(serverIDs, id)
+			}
+		}
+	}
+
+	vars := mux.Vars(r)
+	add(vars["server_id"])
+	switch template {
+	case "/api/v1/servers/{id}":
+		add(vars["id"])
+	case "/api/v1/containers/{id}/metrics":
+		if serverID, ok := s.collector.ContainerServerID(vars["id"]); ok {
+			add(serverID)
+		}
+	}
+
+	query := r.URL.Query()
+	if value := query.Get("server_id"); value != "" {
+		add(strings.Split(value, ",")...)
+	}
+	group := query.Get("group")
+
+	if r.Body != nil && r.ContentLength != 0 {
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxScopeBody))
+		if err != nil {
+			return nil, err
+		}
+		r.Body = io.NopCloser(io.MultiReader(bytes.NewReader(body), r.Body))
+
+		// Тело может быть не объектом (метки сервиса, список точек) - тогда в нем нет серверов
+		var fields struct {
+			ServerID  string   `json:"server_id"`
+			ServerIDs []string `json:"server_ids"`
+			Group     string   `json:"group"`
+		}
+		if json.Unmarshal(body, &fields) == nil {
+			add(fields.ServerID)
+			add(fields.ServerIDs...)
+			if fields.Group != "" {
+				group = fields.Group
+			}
+		}
+	}
+
+	if group != "" {
+		members, err := s.resolveTargets(nil, group, "")
+		if err != nil {
+			return nil, err
+		}
+		add(members...)
+	}
+	return serverIDs, nil
+}
+
+// serverLabels возвращает канонические метки сервера из инвентаря
+func (s *Server) serverLabels(serverID string) map[string]string {
+	if s.inventory == nil {
+		return nil
+	}
+	return s.inventory.ServerLabels(serverID).Values
+}
+
+// allowsTenant проверяет, что сервер принадлежит арендатору клиента.
+// Сервер без точек еще ничей: первая запись закрепит его за арендатором.
+// Клиенты без арендатора (операторы инстанса) видят серверы всех арендаторов.
+func (s *Server) allowsTenant(principal *Principal, serverID string) error {
+	if principal.TenantID() == "" {
+		return nil
+	}
+	owner, known := s.collector.ServerTenant(serverID)
+	if known && owner != principal.TenantID() {
+		return fmt.Errorf("%w: server %s", ErrOutOfScope, serverID)
+	}
+	return nil
+}
+
+// allowsWrite проверяет, что клиент запроса может писать точки сервера:
+// сервер в области ключа и не принадлежит другому арендатору. Для
+// маршрутов из scopedBatches.
+func (s *Server) allowsWrite(r *http.Request, serverID string) error {
+	principal, _ := PrincipalFromContext(r.Context())
+	if principal == nil {
+		return nil
+	}
+	if err := s.allowsTenant(principal, serverID); err != nil {
+		return err
+	}
+	return principal.Scope.Allows(serverID, true, s.serverLabels)
+}
+
+// allowedServer сообщает, входит ли сервер в область ключа запроса; для
+// фильтрации списков на маршрутах из scopedLists
+func (s *Server) allowedServer(r *http.Request, serverID string) bool {
+	principal, _ := PrincipalFromContext(r.Context())
+	if principal == nil {
+		return true
+	}
+	if principal.TenantID() != "" {
+		if owner, _ := s.collector.ServerTenant(serverID); owner != principal.TenantID() {
+			return false
+		}
+	}
+	return principal.Scope.Allows(serverID, false, s.serverLabels) == nil
+}
+
+// requestTenant возвращает арендатора клиента запроса
+func requestTenant(r *http.Request) string {
+	principal, _ := PrincipalFromContext(r.Context())
+	return principal.TenantID()
+}
@@ -0,0 +1,42 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/YumeNoTenshi/platypus/internal/migration"
+)
+
+type RegionMoveRequest struct {
+	ServerID     string                   `json:"server_id"`
+	TargetRegion string                   `json:"target_region,omitempty"` // Пусто - сравнить все регионы провайдера
+	Hints        migration.PlacementHints `json:"hints"`
+}
+
+func (s *Server) handleSimulateRegionMove(w http.ResponseWriter, r *http.Request) {
+	if s.regionSimulator == nil {
+		respondWithError(w, http.StatusNotImplemented, "region move simulation is disabled")
+		return
+	}
+
+	var req RegionMoveRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	if req.ServerID == "" {
+		respondWithError(w, http.StatusBadRequest, "server_id is required")
+		return
+	}
+
+	estimates, err := s.regionSimulator.Simulate(r.Context(), req.ServerID, req.TargetRegion, req.Hints)
+	if err != nil {
+		respondWithProviderError(w, err, http.StatusUnprocessableEntity)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"status": "success",
+		"data":   estimates,
+	})
+}
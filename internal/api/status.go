@@ -0,0 +1,23 @@
+package api
+
+import (
+	"net/http"
+	"time"
+)
+
+// handleStatus собирает состояние подсистем из зарегистрированных разделов
+func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
+	data := map[string]interface{}{
+		"timestamp": time.Now().Format(time.RFC3339),
+		"servers":   len(s.collector.ServerIDs()),
+	}
+
+	for name, section := range s.statusSections {
+		data[name] = section()
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"status": "success",
+		"data":   data,
+	})
+}
@@ -0,0 +1,81 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/YumeNoTenshi/platypus/internal/metrics"
+)
+
+// streamKeepAlive - интервал комментариев, которые не дают прокси закрыть
+// молчащее соединение
+const streamKeepAlive = 30 * time.Second
+
+// handleStreamMetrics передает новые точки по мере сохранения как
+// Server-Sent Events (событие metric, data - точка в JSON). Выборка задается
+// так же, как в GET /metrics: ?server_id= или ?labels=. Если клиент не
+// успевает читать, часть точек пропускается; событие dropped сообщает,
+// сколько точек пропущено с начала потока.
+func (s *Server) handleStreamMetrics(w http.ResponseWriter, r *http.Request) {
+	serverID := r.URL.Query().Get("server_id")
+	selector, err := metrics.ParseLabelSelector(r.URL.Query().Get("labels"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if serverID == "" && selector.Empty() {
+		respondWithError(w, http.StatusBadRequest, "server_id or labels is required")
+		return
+	}
+
+	subscription := s.collector.Subscribe(serverID, 0)
+	defer subscription.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	controller := http.NewResponseController(w)
+	if err := controller.Flush(); err != nil {
+		return
+	}
+
+	keepAlive := time.NewTicker(streamKeepAlive)
+	defer keepAlive.Stop()
+	var reported uint64
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+		case point, open := <-subscription.C:
+			if !open {
+				return
+			}
+			if (serverID == "" && !s.allowedServer(r, point.ServerID)) || !selector.Matches(point) {
+				continue
+			}
+			if dropped := subscription.Dropped(); dropped != reported {
+				reported = dropped
+				if _, err := fmt.Fprintf(w, "event: dropped\ndata: %d\n\n", dropped); err != nil {
+					return
+				}
+			}
+			data, err := json.Marshal(point)
+			if err != nil {
+				continue
+			}
+			if _, err := fmt.Fprintf(w, "event: metric\ndata: %s\n\n", data); err != nil {
+				return
+			}
+		}
+		if err := controller.Flush(); err != nil {
+			return
+		}
+	}
+}
@@ -1,18 +1,267 @@
 package api
 
 import (
+	"github.com/YumeNoTenshi/platypus/internal/alerting"
+	"github.com/YumeNoTenshi/platypus/internal/annotations"
+	"github.com/YumeNoTenshi/platypus/internal/budgets"
+	"github.com/YumeNoTenshi/platypus/internal/calendar"
+	"github.com/YumeNoTenshi/platypus/internal/ecoscore"
+	"github.com/YumeNoTenshi/platypus/internal/ecotags"
+	"github.com/YumeNoTenshi/platypus/internal/edge"
+	"github.com/YumeNoTenshi/platypus/internal/energy"
+	"github.com/YumeNoTenshi/platypus/internal/errtrack"
+	"github.com/YumeNoTenshi/platypus/internal/federation"
+	"github.com/YumeNoTenshi/platypus/internal/governor"
+	"github.com/YumeNoTenshi/platypus/internal/groups"
+	"github.com/YumeNoTenshi/platypus/internal/imagescan"
+	"github.com/YumeNoTenshi/platypus/internal/incidents"
+	"github.com/YumeNoTenshi/platypus/internal/insights"
+	"github.com/YumeNoTenshi/platypus/internal/inventory"
 	"github.com/YumeNoTenshi/platypus/internal/metrics"
+	"github.com/YumeNoTenshi/platypus/internal/migration"
+	"github.com/YumeNoTenshi/platypus/internal/recommendations"
+	"github.com/YumeNoTenshi/platypus/internal/replication"
+	"github.com/YumeNoTenshi/platypus/internal/reports"
 	"github.com/YumeNoTenshi/platypus/internal/models"
+	"github.com/YumeNoTenshi/platypus/internal/scaling"
+	"github.com/YumeNoTenshi/platypus/pkg/cloud"
 )
 
 type Server struct {
 	collector *metrics.Collector
 	analyzer  *metrics.Analyzer
+	governor  *governor.Manager
+	hub       *federation.Hub
+	images    *imagescan.Scanner
+	insights  *insights.Insights
+	tags      *ecotags.TagManager
+	auth      AuthProvider
+	logger    *RequestLogger
+
+	recommendations *recommendations.Manager
+	forecaster      *recommendations.Forecaster
+	reports         *reports.Generator
+	subscriptions   *reports.Subscriptions
+	regionSimulator *migration.RegionSimulator
+	planner         *migration.Planner
+	autoscaler      *scaling.Autoscaler
+	inventory       *inventory.Inventory
+	energy          *energy.Accountant
+	scoreHistory    *ecoscore.History
+	budgets         *budgets.Manager
+	alerts          *alerting.Dispatcher
+	groups          *groups.Manager
+	replicator      *replication.Replicator
+	standby         *replication.Receiver
+	errors          *errtrack.Tracker
+	calendar        *calendar.Feed
+	edge            *edge.Planner
+	incidents       *incidents.Manager
+	annotations     *annotations.Store
+	rollout         *cloud.Rollout
+	apiKeys         *APIKeyProvider
+
+	statusSections map[string]func() interface{}
+}
+
+// ServerOption подключает к API необязательные подсистемы
+type ServerOption func(*Server)
+
+func WithGovernor(manager *governor.Manager) ServerOption {
+	return func(s *Server) {
+		s.governor = manager
+	}
+}
+
+// WithAuthProvider заменяет механизм аутентификации защищенных маршрутов
+func WithAuthProvider(provider AuthProvider) ServerOption {
+	return func(s *Server) {
+		s.auth = provider
+	}
+}
+
+// WithAPIKeys включает API управления ключами клиентов
+func WithAPIKeys(provider *APIKeyProvider) ServerOption {
+	return func(s *Server) {
+		s.apiKeys = provider
+	}
+}
+
+// WithRequestLogger задает начальные правила журналирования запросов
+func WithRequestLogger(logger *RequestLogger) ServerOption {
+	return func(s *Server) {
+		s.logger = logger
+	}
+}
+
+// WithStatusSection добавляет раздел в ответ /status
+func WithStatusSection(name string, section func() interface{}) ServerOption {
+	return func(s *Server) {
+		s.statusSections[name] = section
+	}
+}
+
+func WithImageScanner(scanner *imagescan.Scanner) ServerOption {
+	return func(s *Server) {
+		s.images = scanner
+	}
+}
+
+func WithInsights(i *insights.Insights) ServerOption {
+	return func(s *Server) {
+		s.insights = i
+	}
+}
+
+// WithTagManager включает управление определениями эко-тегов
+func WithTagManager(manager *ecotags.TagManager) ServerOption {
+	return func(s *Server) {
+		s.tags = manager
+	}
+}
+
+func WithRecommendations(manager *recommendations.Manager) ServerOption {
+	return func(s *Server) {
+		s.recommendations = manager
+	}
+}
+
+func WithSavingsForecaster(forecaster *recommendations.Forecaster) ServerOption {
+	return func(s *Server) {
+		s.forecaster = forecaster
+	}
+}
+
+func WithReports(generator *reports.Generator) ServerOption {
+	return func(s *Server) {
+		s.reports = generator
+	}
+}
+
+// WithReportSubscriptions включает подписки на отчеты (/reports/subscriptions)
+func WithReportSubscriptions(subscriptions *reports.Subscriptions) ServerOption {
+	return func(s *Server) {
+		s.subscriptions = subscriptions
+	}
+}
+
+func WithRegionSimulator(simulator *migration.RegionSimulator) ServerOption {
+	return func(s *Server) {
+		s.regionSimulator = simulator
+	}
+}
+
+// WithMigrationPlanner включает просмотр очереди миграций и отклоненных целей
+func WithMigrationPlanner(planner *migration.Planner) ServerOption {
+	return func(s *Server) {
+		s.planner = planner
+	}
+}
+
+// WithAnnotations включает пометки на шкале метрик
+func WithAnnotations(store *annotations.Store) ServerOption {
+	return func(s *Server) {
+		s.annotations = store
+	}
+}
+
+// WithAutoscaler включает объяснения решений автоскейлера
+func WithAutoscaler(autoscaler *scaling.Autoscaler) ServerOption {
+	return func(s *Server) {
+		s.autoscaler = autoscaler
+	}
+}
+
+func WithInventory(inv *inventory.Inventory) ServerOption {
+	return func(s *Server) {
+		s.inventory = inv
+	}
+}
+
+func WithEnergyAccountant(accountant *energy.Accountant) ServerOption {
+	return func(s *Server) {
+		s.energy = accountant
+	}
+}
+
+// WithEcoScoreHistory подключает историю эко-рейтинга по версиям методики
+func WithEcoScoreHistory(history *ecoscore.History) ServerOption {
+	return func(s *Server) {
+		s.scoreHistory = history
+	}
+}
+
+func WithBudgets(manager *budgets.Manager) ServerOption {
+	return func(s *Server) {
+		s.budgets = manager
+	}
+}
+
+func WithAlerts(dispatcher *alerting.Dispatcher) ServerOption {
+	return func(s *Server) {
+		s.alerts = dispatcher
+	}
+}
+
+func WithGroups(manager *groups.Manager) ServerOption {
+	return func(s *Server) {
+		s.groups = manager
+	}
+}
+
+// WithReplicator публикует состояние репликации основного инстанса
+func WithReplicator(replicator *replication.Replicator) ServerOption {
+	return func(s *Server) {
+		s.replicator = replicator
+	}
+}
+
+// WithReplicationReceiver включает прием реплицированных пакетов на резервном инстансе
+func WithReplicationReceiver(receiver *replication.Receiver) ServerOption {
+	return func(s *Server) {
+		s.standby = receiver
+	}
+}
+
+// WithFederationHub включает API центрального инстанса федерации
+func WithFederationHub(hub *federation.Hub) ServerOption {
+	return func(s *Server) {
+		s.hub = hub
+	}
+}
+
+// WithErrorTracker включает API учета ошибок фоновых подсистем
+func WithErrorTracker(tracker *errtrack.Tracker) ServerOption {
+	return func(s *Server) {
+		s.errors = tracker
+	}
+}
+
+// WithEdge включает прием выгрузок от агентов периферийных устройств
+func WithEdge(planner *edge.Planner) ServerOption {
+	return func(s *Server) {
+		s.edge = planner
+	}
+}
+
+// WithIncidents включает API инцидентов, объединяющих аномалии серверов
+func WithIncidents(manager *incidents.Manager) ServerOption {
+	return func(s *Server) {
+		s.incidents = manager
+	}
+}
+
+// WithRollout включает API режимов провайдеров (режим наблюдения)
+func WithRollout(rollout *cloud.Rollout) ServerOption {
+	return func(s *Server) {
+		s.rollout = rollout
+	}
 }
 
 type MetricResponse struct {
 	Status string         `json:"status"`
 	Data   []models.MetricData `json:"data"`
+	Annotations []annotations.Annotation `json:"annotations,omitempty"` // Пометки серверов и сервиса выборки
 }
 
 type ServerResponse struct {
@@ -28,4 +277,16 @@ type ErrorResponse struct {
 type EcoScoreRequest struct {
 	ServerID string `json:"server_id"`
 	Period   string `json:"period"` // "1h", "24h", "7d"
-} 
\ No newline at end of file
+	// InstanceType необязателен: если указан, используется для нормализации рейтинга
+	InstanceType string `json:"instance_type,omitempty"`
+}
+
+type LatencySensitiveRequest struct {
+	LatencySensitive bool `json:"latency_sensitive"`
+}
+
+// APIKeyRequest - запрос на выпуск ключа клиенту; без Scope ключ действует на весь парк
+type APIKeyRequest struct {
+	ID    string `json:"id"`
+	Scope *Scope `json:"scope,omitempty"`
+}
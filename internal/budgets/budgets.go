@@ -0,0 +1,477 @@
+// Package budgets ведет углеродные бюджеты (кг CO2 в месяц) для команд
+// и регионов: расход в реальном времени, прогноз на конец месяца и
+// оповещения при достижении порогов
+package budgets
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/YumeNoTenshi/platypus/internal/alerting"
+	"github.com/YumeNoTenshi/platypus/internal/energy"
+	"github.com/YumeNoTenshi/platypus/internal/errtrack"
+	"github.com/YumeNoTenshi/platypus/internal/groups"
+	"github.com/YumeNoTenshi/platypus/internal/inventory"
+	"github.com/YumeNoTenshi/platypus/internal/scheduler"
+	"github.com/YumeNoTenshi/platypus/internal/selector"
+	"github.com/YumeNoTenshi/platypus/pkg/carbon"
+	"github.com/YumeNoTenshi/platypus/pkg/catalog"
+	"github.com/YumeNoTenshi/platypus/pkg/ml"
+)
+
+// Scope определяет, к чему относится бюджет
+type Scope string
+
+const (
+	ScopeTeam     Scope = "team"
+	ScopeRegion   Scope = "region"
+	ScopeGroup    Scope = "group"    // Сохраненная группа серверов, Target - ID или имя группы
+	ScopeSelector Scope = "selector" // Серверы по меткам, Target - селектор, например "team=payments,environment!=dev"
+)
+
+// Status - состояние бюджета в текущем месяце
+type Status string
+
+const (
+	StatusOnTrack  Status = "on_track"
+	StatusAtRisk   Status = "at_risk"  // Прогноз превышает лимит
+	StatusExceeded Status = "exceeded" // Лимит уже израсходован
+)
+
+// defaultThresholds - пороги оповещений по умолчанию, % от лимита
+var defaultThresholds = []float64{50, 80, 100}
+
+type Budget struct {
+	ID              string    `json:"id"`
+	Name            string    `json:"name"`
+	Scope           Scope     `json:"scope"`
+	Target          string    `json:"target"` // Команда, регион, группа или селектор
+	MonthlyLimitKg  float64   `json:"monthly_limit_kg"`
+	AlertThresholds []float64 `json:"alert_thresholds,omitempty"` // % от лимита
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+// DailySpend - расход за сутки (UTC)
+type DailySpend struct {
+	Date string  `json:"date"`
+	Kg   float64 `json:"kg"`
+}
+
+// BurnDown - расход бюджета в текущем месяце
+type BurnDown struct {
+	Budget               Budget       `json:"budget"`
+	Month                string       `json:"month"`
+	SpentKg              float64      `json:"spent_kg"`
+	RemainingKg          float64      `json:"remaining_kg"`
+	UsedPercent          float64      `json:"used_percent"`
+	ProjectedKg          float64      `json:"projected_kg"`           // Ожидаемый расход к концу месяца
+	ProjectedOverspendKg float64      `json:"projected_overspend_kg"` // 0, если прогноз в пределах лимита
+	Status               Status       `json:"status"`
+	Daily                []DailySpend `json:"daily"`
+}
+
+type Config struct {
+	EvaluationInterval time.Duration
+	DefaultGramsPerKWh float64 // Интенсивность для серверов с неизвестным регионом
+	// Errors получает ошибки расчета расхода под именем "budgets"; необязателен
+	Errors errtrack.Recorder
+}
+
+type Manager struct {
+	config     Config
+	accountant *energy.Accountant
+	inventory  *inventory.Inventory
+	dataset    *carbon.Dataset
+	predictor  *ml.Predictor        // Необязателен; без него прогноз линейный
+	alerts     *alerting.Dispatcher // Необязателен
+	groups     *groups.Manager      // Необязателен: нужен для бюджетов на группы серверов
+	mu         sync.RWMutex
+	budgets    map[string]*Budget
+	fired      map[string]float64 // ID бюджета + месяц -> наибольший пройденный порог
+}
+
+func NewManager(config Config, accountant *energy.Accountant, inv *inventory.Inventory, dataset *carbon.Dataset, predictor *ml.Predictor, alerts *alerting.Dispatcher) *Manager {
+	return &Manager{
+		config:     config,
+		accountant: accountant,
+		inventory:  inv,
+		dataset:    dataset,
+		predictor:  predictor,
+		alerts:     alerts,
+		budgets:    make(map[string]*Budget),
+		fired:      make(map[string]float64),
+	}
+}
+
+// SetGroups включает бюджеты на сохраненные группы серверов
+func (m *Manager) SetGroups(g *groups.Manager) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.groups = g
+}
+
+// Jobs возвращает периодическую проверку порогов бюджетов
+func (m *Manager) Jobs() []scheduler.Job {
+	return []scheduler.Job{{
+		Name:     "budgets.evaluate",
+		Interval: m.config.EvaluationInterval,
+		Run: func(ctx context.Context) error {
+			m.evaluate(ctx)
+			return nil
+		},
+	}}
+}
+
+func validate(budget Budget) error {
+	switch budget.Scope {
+	case ScopeTeam, ScopeRegion, ScopeGroup, ScopeSelector:
+	default:
+		return fmt.Errorf("scope must be %q, %q, %q or %q", ScopeTeam, ScopeRegion, ScopeGroup, ScopeSelector)
+	}
+	if budget.Target == "" {
+		return fmt.Errorf("target is required")
+	}
+	if budget.Scope == ScopeSelector {
+		if _, err := selector.Parse(budget.Target); err != nil {
+			return err
+		}
+	}
+	if budget.MonthlyLimitKg <= 0 {
+		return fmt.Errorf("monthly_limit_kg must be positive")
+	}
+	for _, threshold := range budget.AlertThresholds {
+		if threshold <= 0 {
+			return fmt.Errorf("alert thresholds must be positive")
+		}
+	}
+	return nil
+}
+
+func (m *Manager) Create(budget Budget) (Budget, error) {
+	if err := validate(budget); err != nil {
+		return Budget{}, err
+	}
+
+	now := time.Now()
+	budget.ID = newID()
+	budget.CreatedAt = now
+	budget.UpdatedAt = now
+	if len(budget.AlertThresholds) == 0 {
+		budget.AlertThresholds = append([]float64(nil), defaultThresholds...)
+	}
+	sort.Float64s(budget.AlertThresholds)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.budgets[budget.ID] = &budget
+	return budget, nil
+}
+
+func (m *Manager) Update(id string, budget Budget) (Budget, error) {
+	if err := validate(budget); err != nil {
+		return Budget{}, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	existing, exists := m.budgets[id]
+	if !exists {
+		return Budget{}, fmt.Errorf("budget not found: %s", id)
+	}
+
+	budget.ID = id
+	budget.CreatedAt = existing.CreatedAt
+	budget.UpdatedAt = time.Now()
+	if len(budget.AlertThresholds) == 0 {
+		budget.AlertThresholds = append([]float64(nil), defaultThresholds...)
+	}
+	sort.Float64s(budget.AlertThresholds)
+	m.budgets[id] = &budget
+	return budget, nil
+}
+
+func (m *Manager) Get(id string) (Budget, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	budget, exists := m.budgets[id]
+	if !exists {
+		return Budget{}, fmt.Errorf("budget not found: %s", id)
+	}
+	return *budget, nil
+}
+
+func (m *Manager) List() []Budget {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	budgets := make([]Budget, 0, len(m.budgets))
+	for _, budget := range m.budgets {
+		budgets = append(budgets, *budget)
+	}
+	sort.Slice(budgets, func(i, j int) bool {
+		return budgets[i].CreatedAt.Before(budgets[j].CreatedAt)
+	})
+	return budgets
+}
+
+func (m *Manager) Delete(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, exists := m.budgets[id]; !exists {
+		return fmt.Errorf("budget not found: %s", id)
+	}
+	delete(m.budgets, id)
+	return nil
+}
+
+// BurnDown рассчитывает расход бюджета в текущем месяце
+func (m *Manager) BurnDown(ctx context.Context, id string) (BurnDown, error) {
+	budget, err := m.Get(id)
+	if err != nil {
+		return BurnDown{}, err
+	}
+	return m.burnDown(ctx, budget, time.Now())
+}
+
+// Dashboard возвращает расход всех бюджетов, начиная с наиболее рискованных
+func (m *Manager) Dashboard(ctx context.Context) []BurnDown {
+	now := time.Now()
+
+	var dashboard []BurnDown
+	var errs []error
+	for _, budget := range m.List() {
+		burn, err := m.burnDown(ctx, budget, now)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("budget %s: %w", budget.ID, err))
+			continue
+		}
+		dashboard = append(dashboard, burn)
+	}
+	// Успех панели не сбрасывает серию ошибок: ее ведет фоновая оценка
+	if err := errors.Join(errs...); err != nil && m.config.Errors != nil {
+		m.config.Errors.Record("budgets", err)
+	}
+
+	sort.Slice(dashboard, func(i, j int) bool {
+		return dashboard[i].ProjectedKg/dashboard[i].Budget.MonthlyLimitKg >
+			dashboard[j].ProjectedKg/dashboard[j].Budget.MonthlyLimitKg
+	})
+	return dashboard
+}
+
+func (m *Manager) burnDown(ctx context.Context, budget Budget, now time.Time) (BurnDown, error) {
+	monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	monthEnd := monthStart.AddDate(0, 1, 0)
+
+	burn := BurnDown{
+		Budget: budget,
+		Month:  monthStart.Format("2006-01"),
+	}
+
+	shareOf, err := m.shareFunc(budget)
+	if err != nil {
+		return BurnDown{}, err
+	}
+
+	daily := make(map[string]float64)
+	var projectedRest float64
+	for _, serverID := range m.accountant.ServerIDs() {
+		share, err := shareOf(serverID)
+		if err != nil {
+			return BurnDown{}, err
+		}
+		if share == 0 {
+			continue
+		}
+
+		kgPerKWh := m.intensity(serverID) / 1000 * share
+		series, err := m.accountant.Series(serverID, energy.ResolutionDay, monthStart, now)
+		if err != nil {
+			return BurnDown{}, err
+		}
+		for _, day := range series {
+			kg := day.KWh * kgPerKWh
+			daily[day.Start.Format("2006-01-02")] += kg
+			burn.SpentKg += kg
+		}
+
+		projectedRest += m.projectKWh(ctx, serverID, monthStart, now, monthEnd) * kgPerKWh
+	}
+
+	for date, kg := range daily {
+		burn.Daily = append(burn.Daily, DailySpend{Date: date, Kg: kg})
+	}
+	sort.Slice(burn.Daily, func(i, j int) bool {
+		return burn.Daily[i].Date < burn.Daily[j].Date
+	})
+
+	burn.RemainingKg = budget.MonthlyLimitKg - burn.SpentKg
+	burn.UsedPercent = burn.SpentKg / budget.MonthlyLimitKg * 100
+	burn.ProjectedKg = burn.SpentKg + projectedRest
+	if burn.ProjectedKg > budget.MonthlyLimitKg {
+		burn.ProjectedOverspendKg = burn.ProjectedKg - budget.MonthlyLimitKg
+	}
+
+	switch {
+	case burn.SpentKg >= budget.MonthlyLimitKg:
+		burn.Status = StatusExceeded
+	case burn.ProjectedOverspendKg > 0:
+		burn.Status = StatusAtRisk
+	default:
+		burn.Status = StatusOnTrack
+	}
+	return burn, nil
+}
+
+// shareFunc возвращает функцию доли энергии сервера, относящейся к бюджету
+func (m *Manager) shareFunc(budget Budget) (func(serverID string) (float64, error), error) {
+	if budget.Scope != ScopeGroup && budget.Scope != ScopeSelector {
+		return func(serverID string) (float64, error) {
+			shares, err := m.accountant.Shares(string(budget.Scope), serverID)
+			return shares[budget.Target], err
+		}, nil
+	}
+
+	m.mu.RLock()
+	g := m.groups
+	m.mu.RUnlock()
+	if g == nil {
+		return nil, fmt.Errorf("%s budgets require server groups", budget.Scope)
+	}
+
+	var members []string
+	if budget.Scope == ScopeSelector {
+		sel, err := selector.Parse(budget.Target)
+		if err != nil {
+			return nil, err
+		}
+		members = g.Select(sel)
+	} else {
+		var err error
+		if members, err = g.Members(budget.Target); err != nil {
+			return nil, err
+		}
+	}
+	inGroup := make(map[string]bool, len(members))
+	for _, member := range members {
+		inGroup[member] = true
+	}
+	return func(serverID string) (float64, error) {
+		if inGroup[serverID] {
+			return 1, nil
+		}
+		return 0, nil
+	}, nil
+}
+
+// projectKWh оценивает потребление сервера с now до конца месяца по прогнозу
+// предиктора; если прогноз недоступен, экстраполирует средний расход месяца
+func (m *Manager) projectKWh(ctx context.Context, serverID string, monthStart, now, monthEnd time.Time) float64 {
+	remaining := monthEnd.Sub(now)
+
+	if m.predictor != nil {
+		predictions, err := m.predictor.PredictServerMetrics(ctx, serverID, remaining)
+		if err == nil && len(predictions) > 0 {
+			// Прогноз почасовой: каждая точка - средняя мощность за час
+			var kwh float64
+			for _, p := range predictions {
+				kwh += p.PowerUsage / 1000
+			}
+			return kwh
+		}
+	}
+
+	elapsed := now.Sub(monthStart)
+	if elapsed <= 0 {
+		return 0
+	}
+	spent := m.accountant.ServerEnergy(serverID, monthStart, now)
+	return spent / elapsed.Hours() * remaining.Hours()
+}
+
+// intensity возвращает углеродную интенсивность региона сервера, г CO2/кВт*ч
+func (m *Manager) intensity(serverID string) float64 {
+	if m.inventory != nil {
+		if server, exists := m.inventory.Server(serverID); exists {
+			if grams, ok := m.dataset.Intensity(catalog.RegionOf(server.Region)); ok {
+				return grams
+			}
+		}
+	}
+	return m.config.DefaultGramsPerKWh
+}
+
+// evaluate отправляет оповещения о пройденных порогах; каждый порог
+// срабатывает не более одного раза за месяц
+func (m *Manager) evaluate(ctx context.Context) {
+	if m.alerts == nil {
+		return
+	}
+
+	now := time.Now()
+	var errs []error
+	defer func() {
+		if m.config.Errors != nil {
+			m.config.Errors.Record("budgets", errors.Join(errs...))
+		}
+	}()
+	for _, budget := range m.List() {
+		burn, err := m.burnDown(ctx, budget, now)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("budget %s: %w", budget.ID, err))
+			continue
+		}
+
+		key := budget.ID + "/" + burn.Month
+		m.mu.RLock()
+		previous := m.fired[key]
+		m.mu.RUnlock()
+
+		var crossed float64
+		for _, threshold := range budget.AlertThresholds {
+			if burn.UsedPercent >= threshold && threshold > previous {
+				crossed = threshold
+			}
+		}
+		if crossed == 0 {
+			continue
+		}
+
+		m.mu.Lock()
+		m.fired[key] = crossed
+		m.mu.Unlock()
+
+		severity := alerting.SeverityWarning
+		if crossed >= 100 {
+			severity = alerting.SeverityCritical
+		}
+		m.alerts.Notify(ctx, alerting.Alert{
+			Key:      fmt.Sprintf("budget/%s/%s/%.0f", budget.ID, burn.Month, crossed),
+			Source:   "budgets",
+			Severity: severity,
+			Title:    fmt.Sprintf("Carbon budget %q reached %.0f%%", budget.Name, crossed),
+			Message: fmt.Sprintf("%s %s: %.1f of %.1f kg CO2 used in %s, projected %.1f kg",
+				budget.Scope, budget.Target, burn.SpentKg, budget.MonthlyLimitKg, burn.Month, burn.ProjectedKg),
+			Labels: map[string]string{
+				string(budget.Scope): budget.Target,
+				"budget_id":          budget.ID,
+			},
+		})
+	}
+}
+
+func newID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
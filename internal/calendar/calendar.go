@@ -0,0 +1,196 @@
+// Package calendar публикует ожидаемые события парка как календарь
+// iCalendar (RFC 5545): прогнозные пики нагрузки, окна масштабирования и
+// обслуживания, запланированные миграции. Дежурные подписываются на ленту
+// в своем календаре и видят, когда Platypus ждет нагрузку или собирается
+// выполнять действия с простоем.
+package calendar
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Категории событий (CATEGORIES)
+const (
+	CategoryPeak        = "peak"
+	CategoryScaling     = "scaling"
+	CategoryMaintenance = "maintenance"
+	CategoryMigration   = "migration"
+)
+
+// Event - событие ленты. UID должен быть стабильным между запросами ленты:
+// по нему календарь обновляет событие, а не создает копию.
+type Event struct {
+	UID         string
+	Summary     string
+	Description string
+	Start       time.Time
+	End         time.Time
+	Category    string
+	Tentative   bool // Прогноз или план, время которого может сдвинуться
+}
+
+// Source - источник событий ленты
+type Source interface {
+	// Events возвращает события, пересекающиеся с [from, to)
+	Events(ctx context.Context, from, to time.Time) ([]Event, error)
+}
+
+// SourceFunc позволяет использовать функцию как Source
+type SourceFunc func(ctx context.Context, from, to time.Time) ([]Event, error)
+
+func (f SourceFunc) Events(ctx context.Context, from, to time.Time) ([]Event, error) {
+	return f(ctx, from, to)
+}
+
+// Feed собирает события источников в одну ленту
+type Feed struct {
+	name string
+
+	mu      sync.RWMutex
+	sources map[string]Source
+}
+
+// NewFeed создает ленту; name - название календаря у подписчика
+func NewFeed(name string) *Feed {
+	return &Feed{name: name, sources: make(map[string]Source)}
+}
+
+// Add подключает источник. Имя источника входит в сообщения об ошибках.
+func (f *Feed) Add(name string, source Source) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.sources[name] = source
+}
+
+// Events собирает события всех источников за [from, to), упорядоченные по
+// началу. Ошибка источника не прерывает ленту: его события пропускаются,
+// остальные публикуются.
+func (f *Feed) Events(ctx context.Context, from, to time.Time) []Event {
+	f.mu.RLock()
+	sources := make(map[string]Source, len(f.sources))
+	for name, source := range f.sources {
+		sources[name] = source
+	}
+	f.mu.RUnlock()
+
+	var events []Event
+	for name, source := range sources {
+		found, err := source.Events(ctx, from, to)
+		if err != nil {
+			log.Printf("Календарь: источник %s недоступен: %v", name, err)
+			continue
+		}
+		for _, event := range found {
+			if event.End.After(from) && event.Start.Before(to) {
+				events = append(events, event)
+			}
+		}
+	}
+	sort.Slice(events, func(i, j int) bool {
+		if !events[i].Start.Equal(events[j].Start) {
+			return events[i].Start.Before(events[j].Start)
+		}
+		return events[i].UID < events[j].UID
+	})
+	return events
+}
+
+// Write записывает события в формате iCalendar
+func (f *Feed) Write(w io.Writer, events []Event, now time.Time) error {
+	out := &icsWriter{w: bufio.NewWriter(w)}
+	out.line("BEGIN:VCALENDAR")
+	out.line("VERSION:2.0")
+	out.line("PRODID:-//platypus//calendar//EN")
+	out.line("CALSCALE:GREGORIAN")
+	out.line("METHOD:PUBLISH")
+	out.line("X-WR-CALNAME:" + escape(f.name))
+	// Подсказка клиентам: лента меняется с прогнозами, обновлять ее стоит часто
+	out.line("REFRESH-INTERVAL;VALUE=DURATION:PT1H")
+	out.line("X-PUBLISHED-TTL:PT1H")
+
+	stamp := formatTime(now)
+	for _, event := range events {
+		out.line("BEGIN:VEVENT")
+		out.line("UID:" + escape(event.UID))
+		out.line("DTSTAMP:" + stamp)
+		out.line("DTSTART:" + formatTime(event.Start))
+		out.line("DTEND:" + formatTime(event.End))
+		out.line("SUMMARY:" + escape(event.Summary))
+		if event.Description != "" {
+			out.line("DESCRIPTION:" + escape(event.Description))
+		}
+		if event.Category != "" {
+			out.line("CATEGORIES:" + escape(event.Category))
+		}
+		if event.Tentative {
+			out.line("STATUS:TENTATIVE")
+		} else {
+			out.line("STATUS:CONFIRMED")
+		}
+		// События информационные и не должны занимать время в календаре дежурного
+		out.line("TRANSP:TRANSPARENT")
+		out.line("END:VEVENT")
+	}
+	out.line("END:VCALENDAR")
+
+	if out.err != nil {
+		return out.err
+	}
+	return out.w.Flush()
+}
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format("20060102T150405Z")
+}
+
+// escape экранирует текст значения (RFC 5545, 3.3.11)
+func escape(text string) string {
+	return strings.NewReplacer(
+		`\`, `\\`,
+		";", `\;`,
+		",", `\,`,
+		"\r\n", `\n`,
+		"\n", `\n`,
+	).Replace(text)
+}
+
+// icsWriter пишет строки содержимого с CRLF, перенося строки длиннее 75
+// октетов (RFC 5545, 3.1) без разрыва символов UTF-8
+type icsWriter struct {
+	w   *bufio.Writer
+	err error
+}
+
+func (o *icsWriter) line(content string) {
+	if o.err != nil {
+		return
+	}
+	const limit = 75
+	width := limit
+	for len(content) > width {
+		cut := width
+		for cut > 0 && !utf8Start(content[cut]) {
+			cut--
+		}
+		if _, o.err = fmt.Fprintf(o.w, "%s\r\n ", content[:cut]); o.err != nil {
+			return
+		}
+		content = content[cut:]
+		// Строка продолжения начинается с пробела, который входит в лимит
+		width = limit - 1
+	}
+	_, o.err = fmt.Fprintf(o.w, "%s\r\n", content)
+}
+
+// utf8Start сообщает, что байт начинает символ UTF-8
+func utf8Start(b byte) bool {
+	return b&0xC0 != 0x80
+}
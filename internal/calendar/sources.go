@@ -0,0 +1,205 @@
+package calendar
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/YumeNoTenshi/platypus/pkg/schedule"
+)
+
+// PowerForecast - прогноз средней мощности сервера за час, начатый в At
+type PowerForecast struct {
+	At    time.Time
+	Watts float64
+}
+
+// FleetPeaks публикует прогнозный пик суммарной мощности парка: для каждых
+// суток (UTC) - час с наибольшей суммой прогнозов по всем серверам
+type FleetPeaks struct {
+	Servers func() []string
+	Predict func(ctx context.Context, serverID string, horizon time.Duration) ([]PowerForecast, error)
+	// MinWatts - пики ниже порога не публикуются; 0 - публикуются все
+	MinWatts float64
+}
+
+func (p FleetPeaks) Events(ctx context.Context, from, to time.Time) ([]Event, error) {
+	horizon := time.Until(to)
+	if horizon <= 0 {
+		return nil, nil
+	}
+
+	// Суммарный прогноз по часам; серверы без модели пропускаются
+	totals := make(map[time.Time]float64)
+	servers := make(map[time.Time]int)
+	for _, serverID := range p.Servers() {
+		forecasts, err := p.Predict(ctx, serverID, horizon)
+		if err != nil {
+			continue
+		}
+		for _, forecast := range forecasts {
+			hour := forecast.At.UTC().Truncate(time.Hour)
+			totals[hour] += forecast.Watts
+			servers[hour]++
+		}
+	}
+
+	peaks := make(map[time.Time]time.Time) // Сутки -> час пика
+	for hour, watts := range totals {
+		if hour.Before(from.Truncate(time.Hour)) || !hour.Before(to) {
+			continue
+		}
+		day := hour.Truncate(24 * time.Hour)
+		if best, exists := peaks[day]; !exists || watts > totals[best] {
+			peaks[day] = hour
+		}
+	}
+
+	events := make([]Event, 0, len(peaks))
+	for day, hour := range peaks {
+		watts := totals[hour]
+		if watts < p.MinWatts {
+			continue
+		}
+		events = append(events, Event{
+			UID:         fmt.Sprintf("peak-%s@platypus", day.Format("20060102")),
+			Summary:     fmt.Sprintf("Expected fleet peak: %.1f kW", watts/1000),
+			Description: fmt.Sprintf("Forecast total power of %d servers for this hour is %.0f W. The forecast is refreshed as new metrics arrive.", servers[hour], watts),
+			Start:       hour,
+			End:         hour.Add(time.Hour),
+			Category:    CategoryPeak,
+			Tentative:   true,
+		})
+	}
+	return events, nil
+}
+
+// maxWindowDuration ограничивает длительность повторяющегося окна
+const maxWindowDuration = 7 * 24 * time.Hour
+
+// Window - объявленное окно масштабирования или обслуживания: разовое
+// (StartsAt/EndsAt) или повторяющееся (Schedule и Duration), например
+// "0 2 * * 0" и 4h - каждое воскресенье 02:00-06:00
+type Window struct {
+	Name        string        `json:"name"`
+	Kind        string        `json:"kind"` // maintenance или scaling
+	Description string        `json:"description,omitempty"`
+	StartsAt    *time.Time    `json:"starts_at,omitempty"`
+	EndsAt      *time.Time    `json:"ends_at,omitempty"`
+	Schedule    string        `json:"schedule,omitempty"`
+	Duration    time.Duration `json:"duration,omitempty"` // В JSON - наносекунды
+	Timezone    string        `json:"timezone,omitempty"` // IANA, по умолчанию UTC
+
+	parsed   schedule.Schedule
+	location *time.Location
+}
+
+// Windows публикует объявленные окна
+type Windows []Window
+
+// LoadWindows читает окна из JSON-файла (массив Window)
+func LoadWindows(path string) (Windows, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var windows Windows
+	if err := json.Unmarshal(data, &windows); err != nil {
+		return nil, fmt.Errorf("invalid calendar window file %s: %w", path, err)
+	}
+	for i := range windows {
+		if err := windows[i].prepare(); err != nil {
+			return nil, fmt.Errorf("window %d: %w", i+1, err)
+		}
+	}
+	return windows, nil
+}
+
+func (w *Window) prepare() error {
+	if w.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	switch w.Kind {
+	case CategoryMaintenance, CategoryScaling:
+	default:
+		return fmt.Errorf("window %s: kind must be maintenance or scaling", w.Name)
+	}
+
+	if w.Schedule == "" {
+		if w.StartsAt == nil || w.EndsAt == nil {
+			return fmt.Errorf("window %s: schedule or starts_at and ends_at are required", w.Name)
+		}
+		if !w.EndsAt.After(*w.StartsAt) {
+			return fmt.Errorf("window %s: ends_at must be after starts_at", w.Name)
+		}
+		return nil
+	}
+
+	if w.StartsAt != nil || w.EndsAt != nil {
+		return fmt.Errorf("window %s: recurring window cannot have starts_at or ends_at", w.Name)
+	}
+	parsed, err := schedule.Parse(w.Schedule)
+	if err != nil {
+		return fmt.Errorf("window %s: %w", w.Name, err)
+	}
+	if w.Duration <= 0 || w.Duration > maxWindowDuration {
+		return fmt.Errorf("window %s: duration must be between 0 and %s", w.Name, maxWindowDuration)
+	}
+	w.location = time.UTC
+	if w.Timezone != "" {
+		if w.location, err = time.LoadLocation(w.Timezone); err != nil {
+			return fmt.Errorf("window %s: invalid timezone: %s", w.Name, w.Timezone)
+		}
+	}
+	w.parsed = parsed
+	return nil
+}
+
+func (ws Windows) Events(ctx context.Context, from, to time.Time) ([]Event, error) {
+	var events []Event
+	for _, w := range ws {
+		event := Event{
+			Summary:     w.Name,
+			Description: w.Description,
+			Category:    w.Kind,
+		}
+		if w.Schedule == "" {
+			event.UID = fmt.Sprintf("%s-%s@platypus", w.Kind, slug(w.Name))
+			event.Start, event.End = *w.StartsAt, *w.EndsAt
+			events = append(events, event)
+			continue
+		}
+
+		// Окно, начатое до from, может еще продолжаться
+		at := w.parsed.Next(from.Add(-w.Duration).In(w.location).Add(-time.Minute))
+		var previous string
+		for ; !at.IsZero() && at.Before(to); at = w.parsed.Next(at) {
+			// При переводе часов назад местное время повторяется: окно одно
+			local := at.Format("200601021504")
+			if local == previous {
+				continue
+			}
+			previous = local
+
+			occurrence := event
+			occurrence.UID = fmt.Sprintf("%s-%s-%s@platypus", w.Kind, slug(w.Name), at.UTC().Format("20060102T1504"))
+			occurrence.Start, occurrence.End = at, at.Add(w.Duration)
+			events = append(events, occurrence)
+		}
+	}
+	return events, nil
+}
+
+// slug приводит имя окна к виду, пригодному для UID
+func slug(name string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return '-'
+	}, name)
+}
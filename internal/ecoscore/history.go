@@ -0,0 +1,574 @@
+// Package ecoscore хранит суточную историю эко-рейтинга и углеродного следа
+// серверов отдельными рядами для каждой версии методики. При смене весов
+// рейтинга или набора углеродных интенсивностей история пересчитывается в
+// ряд новой версии, а ряды прежних версий сохраняются: графики трендов не
+// показывают скачков, которых не было в нагрузке.
+package ecoscore
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/YumeNoTenshi/platypus/internal/inventory"
+	"github.com/YumeNoTenshi/platypus/internal/metrics"
+	"github.com/YumeNoTenshi/platypus/internal/models"
+	"github.com/YumeNoTenshi/platypus/internal/scheduler"
+	"github.com/YumeNoTenshi/platypus/internal/supervisor"
+	"github.com/YumeNoTenshi/platypus/pkg/carbon"
+)
+
+const day = 24 * time.Hour
+
+// maxJobs - сколько последних заданий пересчета видно в API
+const maxJobs = 20
+
+// ErrUnknownVersion - ряда такой версии методики нет
+var ErrUnknownVersion = errors.New("unknown methodology version")
+
+// Methodology - методика расчета эко-рейтинга и углеродного следа
+type Methodology struct {
+	Weights       metrics.ScoreWeights `json:"weights"`
+	CarbonDataset string               `json:"carbon_dataset"` // Версия набора углеродных интенсивностей
+}
+
+// Version - идентификатор методики вида "w0.4-0.3-0.3+2024.1": любая смена
+// весов или набора интенсивностей дает новую версию
+func (m Methodology) Version() string {
+	return fmt.Sprintf("w%g-%g-%g+%s", m.Weights.Power, m.Weights.Utilization, m.Weights.Carbon, m.CarbonDataset)
+}
+
+// DayInputs - входы рейтинга сервера за сутки (UTC). По ним рейтинг
+// пересчитывается и после удаления сырых точек по сроку хранения.
+type DayInputs struct {
+	metrics.ScoreInputs
+	Points           int     `json:"points"` // Исходных точек с учетом агрегированных
+	KWh              float64 `json:"kwh"`
+	ReportedCarbonKg float64 `json:"reported_carbon_kg"` // Углеродный след, присланный агентами
+	Region           string  `json:"region,omitempty"`
+}
+
+// Point - рейтинг сервера за сутки по одной версии методики
+type Point struct {
+	Start      time.Time `json:"start"`
+	EcoScore   float64   `json:"eco_score"`
+	Normalized float64   `json:"normalized_eco_score"`
+	CarbonKg   float64   `json:"carbon_kg"`
+	KWh        float64   `json:"kwh"`
+}
+
+// VersionInfo описывает ряд одной версии методики
+type VersionInfo struct {
+	Version     string      `json:"version"`
+	Methodology Methodology `json:"methodology"`
+	Current     bool        `json:"current"`
+	Servers     int         `json:"servers"`
+	Days        int         `json:"days"`
+	ComputedAt  time.Time   `json:"computed_at"`
+}
+
+// Состояния задания пересчета
+const (
+	JobRunning = "running"
+	JobDone    = "done"
+	JobFailed  = "failed"
+)
+
+// Job - задание пересчета истории по методике
+type Job struct {
+	ID         int       `json:"id"`
+	Version    string    `json:"version"`
+	State      string    `json:"state"`
+	Servers    int       `json:"servers"`
+	Days       int       `json:"days"`
+	Error      string    `json:"error,omitempty"`
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at,omitempty"`
+}
+
+type Config struct {
+	UpdateInterval time.Duration
+	MaxGap         time.Duration // Пропуски длиннее не интегрируются в энергию
+	Retention      time.Duration // Сколько хранить входы и ряды; обычно дольше сырых метрик
+	Path           string        // Файл истории; пусто - история живет только в памяти процесса
+}
+
+type series struct {
+	Methodology Methodology                `json:"methodology"`
+	ComputedAt  time.Time                  `json:"computed_at"`
+	Servers     map[string]map[int64]Point `json:"servers"`
+}
+
+// History ведет входы рейтинга и ряды всех версий методики
+type History struct {
+	config    Config
+	collector *metrics.Collector
+	analyzer  *metrics.Analyzer
+	inventory *inventory.Inventory // Необязателен: без него след берется из точек
+	dataset   *carbon.Dataset
+	current   Methodology
+
+	mu     sync.RWMutex
+	inputs map[string]map[int64]DayInputs // Сервер -> начало суток -> входы
+	series map[string]*series             // Версия -> ряд
+	jobs   []*Job
+	nextID int
+	// backfilled - пересчет при старте выполнен
+	backfilled bool
+
+	saveMu sync.Mutex // Упорядочивает запись файла истории
+}
+
+// New создает историю. Текущая методика складывается из весов анализатора и
+// версии набора интенсивностей.
+func New(config Config, collector *metrics.Collector, analyzer *metrics.Analyzer, inv *inventory.Inventory, dataset *carbon.Dataset) (*History, error) {
+	if config.UpdateInterval <= 0 {
+		config.UpdateInterval = time.Hour
+	}
+	h := &History{
+		config:    config,
+		collector: collector,
+		analyzer:  analyzer,
+		inventory: inv,
+		dataset:   dataset,
+		current:   Methodology{Weights: analyzer.ScoreWeights(), CarbonDataset: dataset.Version},
+		inputs:    make(map[string]map[int64]DayInputs),
+		series:    make(map[string]*series),
+	}
+	if err := h.load(); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// Current возвращает текущую методику
+func (h *History) Current() Methodology {
+	return h.current
+}
+
+// Jobs возвращает задачи истории: обновление ряда текущей версии с
+// интервалом UpdateInterval и однократный пересчет при старте. Если ряда
+// текущей версии методики еще нет, например после смены весов, история
+// пересчитывается в него; неудачный пересчет повторяется со следующим
+// запуском задачи, удачный - больше не выполняется.
+func (h *History) Jobs() []scheduler.Job {
+	return []scheduler.Job{{
+		Name:      "ecoscore.update",
+		Interval:  h.config.UpdateInterval,
+		Immediate: true,
+		Run: func(ctx context.Context) error {
+			h.Update()
+			return nil
+		},
+	}, {
+		Name:      "ecoscore.backfill",
+		Interval:  h.config.UpdateInterval,
+		Immediate: true,
+		Run:       h.startupBackfill,
+	}}
+}
+
+// startupBackfill пересчитывает историю в ряд текущей версии, если в нем
+// не хватает суток
+func (h *History) startupBackfill(ctx context.Context) error {
+	h.mu.RLock()
+	done := h.backfilled
+	h.mu.RUnlock()
+	if done {
+		return nil
+	}
+
+	if h.missingDays(h.current.Version()) > 0 {
+		job := h.newJob(h.current)
+		h.run(job, h.current)
+
+		h.mu.RLock()
+		state, message := job.State, job.Error
+		h.mu.RUnlock()
+		if state == JobFailed {
+			return fmt.Errorf("backfill of methodology %s: %s", job.Version, message)
+		}
+	}
+
+	h.mu.Lock()
+	h.backfilled = true
+	h.mu.Unlock()
+	return nil
+}
+
+// Update обновляет входы по сырым точкам и пересчитывает затронутые сутки
+// в ряду текущей версии
+func (h *History) Update() {
+	updated := h.refreshInputs()
+
+	h.mu.Lock()
+	current := h.seriesFor(h.current)
+	for serverID, days := range updated {
+		for _, start := range days {
+			h.setPoint(current, h.current, serverID, start, h.inputs[serverID][start])
+		}
+	}
+	current.ComputedAt = time.Now()
+	h.prune()
+	h.mu.Unlock()
+
+	h.save()
+}
+
+// Backfill запускает пересчет всей хранимой истории по методике в ряд ее
+// версии. Ряды других версий не меняются; ряд той же версии заменяется.
+func (h *History) Backfill(methodology Methodology) (Job, error) {
+	if err := methodology.Weights.Validate(); err != nil {
+		return Job{}, err
+	}
+	if methodology.CarbonDataset == "" {
+		methodology.CarbonDataset = h.dataset.Version
+	}
+	if methodology.CarbonDataset != h.dataset.Version {
+		return Job{}, fmt.Errorf("carbon dataset %s is not loaded, only %s is available", methodology.CarbonDataset, h.dataset.Version)
+	}
+
+	job := h.newJob(methodology)
+	go h.run(job, methodology)
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return *job, nil
+}
+
+func (h *History) newJob(methodology Methodology) *Job {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.nextID++
+	job := &Job{
+		ID:        h.nextID,
+		Version:   methodology.Version(),
+		State:     JobRunning,
+		StartedAt: time.Now(),
+	}
+	h.jobs = append(h.jobs, job)
+	if len(h.jobs) > maxJobs {
+		h.jobs = h.jobs[len(h.jobs)-maxJobs:]
+	}
+	return job
+}
+
+func (h *History) run(job *Job, methodology Methodology) {
+	err := supervisor.Call(func() error {
+		h.backfill(job, methodology)
+		return nil
+	})
+
+	h.mu.Lock()
+	job.FinishedAt = time.Now()
+	if err != nil {
+		job.State = JobFailed
+		job.Error = err.Error()
+	} else {
+		job.State = JobDone
+	}
+	h.mu.Unlock()
+
+	if err != nil {
+		log.Printf("Пересчет эко-рейтинга по методике %s не выполнен: %v", job.Version, err)
+		return
+	}
+	log.Printf("Эко-рейтинг пересчитан по методике %s: %d серверов, %d суток", job.Version, job.Servers, job.Days)
+	h.save()
+}
+
+func (h *History) backfill(job *Job, methodology Methodology) {
+	h.refreshInputs()
+
+	// Ряд собирается отдельно и подменяет прежний целиком, чтобы графики
+	// не видели смеси версий во время пересчета
+	rebuilt := &series{Methodology: methodology, Servers: make(map[string]map[int64]Point)}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for serverID, days := range h.inputs {
+		for start, in := range days {
+			h.setPoint(rebuilt, methodology, serverID, start, in)
+			job.Days++
+		}
+		job.Servers++
+	}
+	rebuilt.ComputedAt = time.Now()
+	h.series[methodology.Version()] = rebuilt
+}
+
+// refreshInputs пересчитывает входы по сырым точкам. Ранее сохраненные сутки
+// заменяются только более полными данными: сутки, частично удаленные по
+// сроку хранения, не портят сохраненные входы. Возвращает обновленные сутки.
+func (h *History) refreshInputs() map[string][]int64 {
+	updated := make(map[string][]int64)
+	for _, serverID := range h.collector.ServerIDs() {
+		data, err := h.collector.GetMetrics(serverID)
+		if err != nil || len(data) == 0 {
+			continue
+		}
+
+		region := ""
+		if h.inventory != nil {
+			if server, exists := h.inventory.Server(serverID); exists {
+				region = server.Region
+			}
+		}
+
+		energy := make(map[int64]float64)
+		for _, bucket := range metrics.IntegrateEnergy(data, day, h.config.MaxGap) {
+			energy[bucket.Start.Unix()] = bucket.KWh
+		}
+
+		byDay := make(map[int64][]models.MetricData)
+		for _, m := range data {
+			start := time.Unix(m.Timestamp, 0).UTC().Truncate(day).Unix()
+			byDay[start] = append(byDay[start], m)
+		}
+
+		h.mu.Lock()
+		days := h.inputs[serverID]
+		if days == nil {
+			days = make(map[int64]DayInputs)
+			h.inputs[serverID] = days
+		}
+		for start, points := range byDay {
+			in := DayInputs{
+				ScoreInputs: h.analyzer.ScoreInputs(points),
+				KWh:         energy[start],
+				Region:      region,
+			}
+			for _, m := range points {
+				weight := m.Samples
+				if weight <= 0 {
+					weight = 1
+				}
+				in.Points += weight
+				in.ReportedCarbonKg += m.CarbonFootprint * float64(weight)
+			}
+			if existing, exists := days[start]; exists && in.Points < existing.Points {
+				continue
+			}
+			days[start] = in
+			updated[serverID] = append(updated[serverID], start)
+		}
+		h.mu.Unlock()
+	}
+	return updated
+}
+
+// setPoint рассчитывает сутки сервера по методике. Если регион сервера есть в
+// наборе интенсивностей, углеродный след считается по энергии, а не берется
+// из точек: так смена набора пересчитывает и след, и его вклад в рейтинг.
+func (h *History) setPoint(s *series, methodology Methodology, serverID string, start int64, in DayInputs) {
+	inputs := in.ScoreInputs
+	carbonKg := in.ReportedCarbonKg
+	if grams, exists := h.dataset.Intensity(in.Region); exists && in.KWh > 0 && in.Points > 0 {
+		carbonKg = in.KWh * grams / 1000
+		inputs.AvgCarbon = carbonKg / float64(in.Points)
+	}
+
+	points := s.Servers[serverID]
+	if points == nil {
+		points = make(map[int64]Point)
+		s.Servers[serverID] = points
+	}
+	points[start] = Point{
+		Start:      time.Unix(start, 0).UTC(),
+		EcoScore:   methodology.Weights.Score(inputs, 1),
+		Normalized: methodology.Weights.Score(inputs, h.analyzer.NormalizationFactor(serverID)),
+		CarbonKg:   carbonKg,
+		KWh:        in.KWh,
+	}
+}
+
+// seriesFor возвращает ряд методики, создавая его при необходимости.
+// Вызывается под h.mu.
+func (h *History) seriesFor(methodology Methodology) *series {
+	version := methodology.Version()
+	s, exists := h.series[version]
+	if !exists {
+		s = &series{Methodology: methodology, Servers: make(map[string]map[int64]Point)}
+		h.series[version] = s
+	}
+	return s
+}
+
+// missingDays - число суток с входами, которых нет в ряду версии
+func (h *History) missingDays(version string) int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	s := h.series[version]
+	missing := 0
+	for serverID, days := range h.inputs {
+		for start := range days {
+			if s == nil {
+				missing++
+				continue
+			}
+			if _, exists := s.Servers[serverID][start]; !exists {
+				missing++
+			}
+		}
+	}
+	return missing
+}
+
+// prune удаляет входы и точки старше Retention. Вызывается под h.mu.
+func (h *History) prune() {
+	if h.config.Retention <= 0 {
+		return
+	}
+	cutoff := time.Now().Add(-h.config.Retention).Unix()
+
+	for serverID, days := range h.inputs {
+		for start := range days {
+			if start < cutoff {
+				delete(days, start)
+			}
+		}
+		if len(days) == 0 {
+			delete(h.inputs, serverID)
+		}
+	}
+	for _, s := range h.series {
+		for serverID, points := range s.Servers {
+			for start := range points {
+				if start < cutoff {
+					delete(points, start)
+				}
+			}
+			if len(points) == 0 {
+				delete(s.Servers, serverID)
+			}
+		}
+	}
+}
+
+// Series возвращает суточный ряд сервера за [from, to) по версии методики;
+// пустая версия - текущая
+func (h *History) Series(version, serverID string, from, to time.Time) ([]Point, error) {
+	if version == "" {
+		version = h.current.Version()
+	}
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	s, exists := h.series[version]
+	if !exists {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownVersion, version)
+	}
+
+	points := make([]Point, 0, len(s.Servers[serverID]))
+	for _, point := range s.Servers[serverID] {
+		if !point.Start.Before(from) && point.Start.Before(to) {
+			points = append(points, point)
+		}
+	}
+	sort.Slice(points, func(i, j int) bool { return points[i].Start.Before(points[j].Start) })
+	return points, nil
+}
+
+// Versions возвращает версии методики, для которых есть ряды
+func (h *History) Versions() []VersionInfo {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	current := h.current.Version()
+	versions := make([]VersionInfo, 0, len(h.series))
+	for version, s := range h.series {
+		info := VersionInfo{
+			Version:     version,
+			Methodology: s.Methodology,
+			Current:     version == current,
+			Servers:     len(s.Servers),
+			ComputedAt:  s.ComputedAt,
+		}
+		for _, points := range s.Servers {
+			info.Days += len(points)
+		}
+		versions = append(versions, info)
+	}
+	sort.Slice(versions, func(i, j int) bool { return versions[i].ComputedAt.Before(versions[j].ComputedAt) })
+	return versions
+}
+
+// BackfillJobs возвращает последние задания пересчета, новые в конце
+func (h *History) BackfillJobs() []Job {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	jobs := make([]Job, len(h.jobs))
+	for i, job := range h.jobs {
+		jobs[i] = *job
+	}
+	return jobs
+}
+
+// state - содержимое файла истории
+type state struct {
+	Inputs map[string]map[int64]DayInputs `json:"inputs"`
+	Series map[string]*series             `json:"series"`
+}
+
+func (h *History) load() error {
+	if h.config.Path == "" {
+		return nil
+	}
+	data, err := os.ReadFile(h.config.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var saved state
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return fmt.Errorf("invalid eco-score history %s: %w", h.config.Path, err)
+	}
+	if saved.Inputs != nil {
+		h.inputs = saved.Inputs
+	}
+	if saved.Series != nil {
+		h.series = saved.Series
+	}
+	return nil
+}
+
+// save записывает историю во временный файл и подменяет им прежний, чтобы
+// сбой во время записи не оставил файл обрезанным
+func (h *History) save() {
+	if h.config.Path == "" {
+		return
+	}
+
+	h.saveMu.Lock()
+	defer h.saveMu.Unlock()
+
+	h.mu.RLock()
+	data, err := json.Marshal(state{Inputs: h.inputs, Series: h.series})
+	h.mu.RUnlock()
+	if err != nil {
+		log.Printf("Не удалось сохранить историю эко-рейтинга: %v", err)
+		return
+	}
+
+	tmp := h.config.Path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		log.Printf("Не удалось сохранить историю эко-рейтинга: %v", err)
+		return
+	}
+	if err := os.Rename(tmp, h.config.Path); err != nil {
+		log.Printf("Не удалось сохранить историю эко-рейтинга: %v", err)
+	}
+}
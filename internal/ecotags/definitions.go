@@ -0,0 +1,153 @@
+package ecotags
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/YumeNoTenshi/platypus/internal/requestid"
+)
+
+// defaultHistorySize - сутки профилей при обновлении раз в 15 минут
+const defaultHistorySize = 96
+
+// Definitions - версия набора определений тегов. Каждое изменение тега
+// создает новую версию; профиль хранит версию, по которой построен, поэтому
+// прошлые профили и отчеты читаются с теми порогами, что действовали тогда.
+type Definitions struct {
+	Version   int               `json:"version"`
+	Tags      map[string]EcoTag `json:"tags"`
+	ChangedAt time.Time         `json:"changed_at"`
+	RequestID string            `json:"request_id,omitempty"` // Запрос API, создавший версию
+}
+
+// Definitions возвращает текущие определения тегов
+func (tm *TagManager) Definitions() Definitions {
+	tm.mu.RLock()
+	defer tm.mu.RUnlock()
+	return tm.versions[len(tm.versions)-1]
+}
+
+// DefinitionsVersion возвращает определения тегов указанной версии
+func (tm *TagManager) DefinitionsVersion(version int) (Definitions, error) {
+	tm.mu.RLock()
+	defer tm.mu.RUnlock()
+
+	if version < 1 || version > len(tm.versions) {
+		return Definitions{}, fmt.Errorf("tag definitions version %d not found", version)
+	}
+	return tm.versions[version-1], nil
+}
+
+// PutTag добавляет или изменяет тег и возвращает новую версию определений.
+// Имя должно быть одним из предопределенных тегов: критерии присвоения
+// заданы в коде, изменяются только описание, оценка, вес и порог.
+func (tm *TagManager) PutTag(ctx context.Context, tag EcoTag) (Definitions, error) {
+	if _, known := defaultTags()[tag.Name]; !known {
+		return Definitions{}, fmt.Errorf("unknown tag %q: supported tags are %s", tag.Name, strings.Join(supportedTags(), ", "))
+	}
+	if tag.Score < 0 || tag.Score > 100 {
+		return Definitions{}, fmt.Errorf("tag %s: score must be between 0 and 100", tag.Name)
+	}
+	if tag.Weight < 0 {
+		return Definitions{}, fmt.Errorf("tag %s: weight must not be negative", tag.Name)
+	}
+
+	return tm.changeTags(ctx, func(tags map[string]EcoTag) error {
+		tags[tag.Name] = tag
+		return nil
+	})
+}
+
+// DeleteTag отключает тег и возвращает новую версию определений.
+// Удаленный тег можно вернуть через PutTag.
+func (tm *TagManager) DeleteTag(ctx context.Context, name string) (Definitions, error) {
+	return tm.changeTags(ctx, func(tags map[string]EcoTag) error {
+		if _, exists := tags[name]; !exists {
+			return fmt.Errorf("tag %s not found", name)
+		}
+		delete(tags, name)
+		return nil
+	})
+}
+
+// changeTags применяет fn к копии текущих определений и сохраняет ее как
+// новую версию: выданные ранее определения не изменяются
+func (tm *TagManager) changeTags(ctx context.Context, fn func(tags map[string]EcoTag) error) (Definitions, error) {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+
+	current := tm.versions[len(tm.versions)-1]
+	tags := make(map[string]EcoTag, len(current.Tags))
+	for name, tag := range current.Tags {
+		tags[name] = tag
+	}
+	if err := fn(tags); err != nil {
+		return Definitions{}, err
+	}
+
+	next := Definitions{
+		Version:   current.Version + 1,
+		Tags:      tags,
+		ChangedAt: time.Now(),
+		RequestID: requestid.FromContext(ctx),
+	}
+	tm.versions = append(tm.versions, next)
+	log.Printf("%sОпределения эко-тегов изменены, версия %d", requestid.Prefix(ctx), next.Version)
+	return next, nil
+}
+
+func supportedTags() []string {
+	names := make([]string, 0)
+	for name := range defaultTags() {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// storeProfile сохраняет профиль как текущий и добавляет его в историю
+// сервиса. Профиль, построенный по более старой версии определений, чем
+// текущий (например, плановое обновление, начатое до изменения тега), не
+// сохраняется.
+func (tm *TagManager) storeProfile(profile *ServiceEcoProfile) bool {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+
+	key := profileKey(profile.TenantID, profile.ServiceName)
+	if current, exists := tm.profiles[key]; exists && current.TagsVersion > profile.TagsVersion {
+		return false
+	}
+	tm.profiles[key] = profile
+
+	history := append(tm.history[key], profile)
+	if len(history) > tm.config.HistorySize {
+		history = history[len(history)-tm.config.HistorySize:]
+	}
+	tm.history[key] = history
+	return true
+}
+
+// ProfileHistory возвращает прошлые профили сервиса арендатора от старых к
+// новым и определения тегов всех версий, по которым они построены
+func (tm *TagManager) ProfileHistory(tenantID, serviceName string) ([]*ServiceEcoProfile, map[int]Definitions, error) {
+	tm.mu.RLock()
+	defer tm.mu.RUnlock()
+
+	history, exists := tm.history[profileKey(tenantID, serviceName)]
+	if !exists {
+		return nil, nil, fmt.Errorf("profile not found for service: %s", serviceName)
+	}
+
+	profiles := append([]*ServiceEcoProfile(nil), history...)
+	definitions := make(map[int]Definitions)
+	for _, profile := range profiles {
+		if version := profile.TagsVersion; version >= 1 && version <= len(tm.versions) {
+			definitions[version] = tm.versions[version-1]
+		}
+	}
+	return profiles, definitions, nil
+}
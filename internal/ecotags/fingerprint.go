@@ -0,0 +1,170 @@
+package ecotags
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/YumeNoTenshi/platypus/internal/models"
+)
+
+const (
+	// fingerprintBandWidth - ширина полосы загрузки CPU, %
+	fingerprintBandWidth = 10
+	// minBandPoints - меньше точек в полосе не дают ее потребления
+	minBandPoints = 5
+	// minComparableShare - какая доля точек текущего развертывания должна
+	// приходиться на полосы, известные и по предыдущему, чтобы сравнение
+	// что-то значило
+	minComparableShare = 0.5
+)
+
+// FingerprintBand - потребление сервиса в полосе загрузки CPU [LoadFrom, LoadTo)
+type FingerprintBand struct {
+	LoadFrom    float64 `json:"load_from"` // %
+	LoadTo      float64 `json:"load_to"`
+	Points      int     `json:"points"`
+	MedianPower float64 `json:"median_power"` // Вт
+	P90Power    float64 `json:"p90_power"`
+}
+
+// EnergyFingerprint - энергетический отпечаток развертывания сервиса:
+// распределение потребления по полосам загрузки CPU и прямая
+// потребление = IdlePower + PowerPerLoad * загрузка. В отличие от среднего
+// потребления, отпечаток не меняется, когда меняется только трафик.
+type EnergyFingerprint struct {
+	Image        string            `json:"image,omitempty"`
+	DeployedAt   time.Time         `json:"deployed_at,omitempty"`
+	Points       int               `json:"points"`
+	IdlePower    float64           `json:"idle_power"`     // Вт при нулевой загрузке по прямой
+	PowerPerLoad float64           `json:"power_per_load"` // Вт на 1% загрузки CPU
+	Bands        []FingerprintBand `json:"bands"`          // Полосы, в которых есть точки
+}
+
+// BandShift - изменение медианного потребления в одной полосе загрузки
+type BandShift struct {
+	LoadFrom      float64 `json:"load_from"`
+	LoadTo        float64 `json:"load_to"`
+	PreviousPower float64 `json:"previous_power"`
+	CurrentPower  float64 `json:"current_power"`
+	ShiftPercent  float64 `json:"shift_percent"`
+}
+
+// FingerprintChange - сравнение отпечатка текущего развертывания с
+// предыдущим. ShiftPercent - рост потребления при той же загрузке,
+// взвешенный по точкам текущего развертывания; Coverage - доля его точек
+// в полосах, где сравнение возможно. Comparable ложно, если доля меньше
+// половины: нагрузка изменилась настолько, что сравнивать нечего.
+type FingerprintChange struct {
+	PreviousImage      string      `json:"previous_image,omitempty"`
+	PreviousDeployedAt time.Time   `json:"previous_deployed_at,omitempty"`
+	Comparable         bool        `json:"comparable"`
+	Coverage           float64     `json:"coverage"`
+	ShiftPercent       float64     `json:"shift_percent"`
+	IdlePowerChange    float64     `json:"idle_power_change"`     // Вт
+	PowerPerLoadChange float64     `json:"power_per_load_change"` // Вт на 1% загрузки CPU
+	Bands              []BandShift `json:"bands"`
+}
+
+// newFingerprint строит отпечаток по точкам развертывания
+func newFingerprint(image string, deployedAt time.Time, data []models.MetricData) *EnergyFingerprint {
+	fingerprint := &EnergyFingerprint{Image: image, DeployedAt: deployedAt}
+	bands := make(map[int][]float64)
+	var sx, sy, sxx, sxy float64
+	for _, m := range data {
+		// Восстановленные точки не измерены и не входят в отпечаток
+		if m.Interpolated {
+			continue
+		}
+		load := math.Max(0, math.Min(m.CPUUsage, 100))
+		band := min(int(load)/fingerprintBandWidth, 100/fingerprintBandWidth-1)
+		bands[band] = append(bands[band], m.PowerUsage)
+		sx += load
+		sy += m.PowerUsage
+		sxx += load * load
+		sxy += load * m.PowerUsage
+		fingerprint.Points++
+	}
+	if fingerprint.Points == 0 {
+		return fingerprint
+	}
+
+	n := float64(fingerprint.Points)
+	fingerprint.IdlePower = sy / n
+	if denominator := n*sxx - sx*sx; denominator > 1e-9 {
+		fingerprint.PowerPerLoad = (n*sxy - sx*sy) / denominator
+		fingerprint.IdlePower = (sy - fingerprint.PowerPerLoad*sx) / n
+	}
+
+	indexes := make([]int, 0, len(bands))
+	for band := range bands {
+		indexes = append(indexes, band)
+	}
+	sort.Ints(indexes)
+	for _, band := range indexes {
+		values := bands[band]
+		sort.Float64s(values)
+		fingerprint.Bands = append(fingerprint.Bands, FingerprintBand{
+			LoadFrom:    float64(band * fingerprintBandWidth),
+			LoadTo:      float64((band + 1) * fingerprintBandWidth),
+			Points:      len(values),
+			MedianPower: quantile(values, 0.5),
+			P90Power:    quantile(values, 0.9),
+		})
+	}
+	return fingerprint
+}
+
+// compareFingerprints сравнивает потребление в полосах, где точек
+// достаточно у обоих развертываний
+func compareFingerprints(previous, current *EnergyFingerprint) *FingerprintChange {
+	change := &FingerprintChange{
+		PreviousImage:      previous.Image,
+		PreviousDeployedAt: previous.DeployedAt,
+		IdlePowerChange:    current.IdlePower - previous.IdlePower,
+		PowerPerLoadChange: current.PowerPerLoad - previous.PowerPerLoad,
+		Bands:              []BandShift{},
+	}
+	earlier := make(map[float64]FingerprintBand, len(previous.Bands))
+	for _, band := range previous.Bands {
+		earlier[band.LoadFrom] = band
+	}
+
+	var weighted float64
+	var comparable int
+	for _, band := range current.Bands {
+		before, ok := earlier[band.LoadFrom]
+		if !ok || band.Points < minBandPoints || before.Points < minBandPoints || before.MedianPower <= 0 {
+			continue
+		}
+		shift := (band.MedianPower - before.MedianPower) / before.MedianPower * 100
+		change.Bands = append(change.Bands, BandShift{
+			LoadFrom:      band.LoadFrom,
+			LoadTo:        band.LoadTo,
+			PreviousPower: before.MedianPower,
+			CurrentPower:  band.MedianPower,
+			ShiftPercent:  shift,
+		})
+		weighted += shift * float64(band.Points)
+		comparable += band.Points
+	}
+
+	if current.Points > 0 {
+		change.Coverage = float64(comparable) / float64(current.Points)
+	}
+	if comparable > 0 {
+		change.ShiftPercent = weighted / float64(comparable)
+	}
+	change.Comparable = comparable > 0 && change.Coverage >= minComparableShare
+	return change
+}
+
+// quantile - квантиль q отсортированных значений с линейной интерполяцией
+func quantile(sorted []float64, q float64) float64 {
+	rank := q * float64(len(sorted)-1)
+	lower := int(rank)
+	if lower >= len(sorted)-1 {
+		return sorted[len(sorted)-1]
+	}
+	return sorted[lower] + (sorted[lower+1]-sorted[lower])*(rank-float64(lower))
+}
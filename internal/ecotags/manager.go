@@ -2,11 +2,18 @@ package ecotags
 
 import (
     "context"
+    "fmt"
+    "log"
     "sync"
     "time"
     
+    "github.com/YumeNoTenshi/platypus/internal/imagescan"
+    "github.com/YumeNoTenshi/platypus/internal/inventory"
     "github.com/YumeNoTenshi/platypus/internal/metrics"
     "github.com/YumeNoTenshi/platypus/internal/models"
+    "github.com/YumeNoTenshi/platypus/internal/peakhours"
+    "github.com/YumeNoTenshi/platypus/internal/requestid"
+    "github.com/YumeNoTenshi/platypus/internal/scheduler"
 )
 
 // EcoTag представляет экологический тег
@@ -21,16 +28,36 @@ type EcoTag struct {
 // ServiceEcoProfile содержит экологический профиль сервиса
 type ServiceEcoProfile struct {
     ServiceName     string    `json:"service_name"`
+    TenantID        string    `json:"tenant_id,omitempty"` // Арендатор сервера контейнера
     Tags           []string  `json:"tags"`
     EcoScore       float64   `json:"eco_score"`
     PowerUsage     float64   `json:"power_usage"`     // Среднее энергопотребление
     CarbonFootprint float64  `json:"carbon_footprint"` // Углеродный след
+    DiskIOBytesPerSec float64 `json:"disk_io_bps,omitempty"`     // Среднее чтение и запись
+    StorageUsedBytes  float64 `json:"storage_used_bytes,omitempty"`
+    ImageSizeBytes int64     `json:"image_size_bytes,omitempty"`
+    DeploymentEnergyWh float64 `json:"deployment_energy_wh,omitempty"` // Энергия на доставку образа при развертывании
+    ImageFindings  []string  `json:"image_findings,omitempty"`
+    Labels         map[string]string `json:"labels,omitempty"` // Команда, окружение, центр затрат
+    TagsVersion    int       `json:"tags_version"` // Версия определений тегов, по которой построен профиль
+    Image          string    `json:"image,omitempty"`
+    DeployedAt     time.Time `json:"deployed_at,omitempty"` // Развертывание, с которого учитываются точки; пусто - вся история
+    Fingerprint    *EnergyFingerprint `json:"fingerprint,omitempty"`        // Потребление по полосам загрузки CPU
+    FingerprintChange *FingerprintChange `json:"fingerprint_change,omitempty"` // Сравнение с предыдущим развертыванием
     LastUpdate     time.Time `json:"last_update"`
 }
 
+// deployment - текущее развертывание сервиса по событиям контейнеров
+type deployment struct {
+    image    string
+    since    time.Time          // Нулевое - образ встречен впервые, начало неизвестно
+    previous *EnergyFingerprint // Отпечаток предыдущего развертывания; nil - неизвестен
+}
+
 type TagManagerConfig struct {
     UpdateInterval time.Duration
     MinDataPoints  int
+    HistorySize    int // Сколько прошлых профилей сервиса хранится; по умолчанию 96
 }
 
 type TagManager struct {
@@ -39,26 +66,46 @@ type TagManager struct {
     analyzer   *metrics.Analyzer
     mu         sync.RWMutex
     profiles   map[string]*ServiceEcoProfile
-    tags       map[string]EcoTag
+    versions   []Definitions // Все версии определений тегов; последняя - текущая
+    history    map[string][]*ServiceEcoProfile
+    images     *imagescan.Scanner // Необязательный анализ контейнерных образов
+    inventory  *inventory.Inventory // Необязательный вывод меток сервисов
+    slos       SLOPolicy // Эко-цели сервисов для проверок перед развертыванием
+    peakHours  *peakhours.Policy // Часы пик по регионам и арендаторам
+    deployments map[string]deployment // Ключ профиля -> текущее развертывание
 }
 
 func NewTagManager(config TagManagerConfig, collector *metrics.Collector, analyzer *metrics.Analyzer) *TagManager {
+    if config.HistorySize <= 0 {
+        config.HistorySize = defaultHistorySize
+    }
     tm := &TagManager{
         config:    config,
         collector: collector,
         analyzer:  analyzer,
         profiles:  make(map[string]*ServiceEcoProfile),
-        tags:      make(map[string]EcoTag),
+        history:   make(map[string][]*ServiceEcoProfile),
+        peakHours: peakhours.DefaultPolicy(),
+        deployments: make(map[string]deployment),
     }
     
     // Инициализация предопределенных тегов
-    tm.initializeTags()
+    tm.versions = []Definitions{{Version: 1, Tags: defaultTags(), ChangedAt: time.Now()}}
     
     return tm
 }
 
-func (tm *TagManager) initializeTags() {
-    tm.tags = map[string]EcoTag{
+// SetImageScanner включает учет эффективности контейнерных образов в профилях
+func (tm *TagManager) SetImageScanner(scanner *imagescan.Scanner) {
+    tm.mu.Lock()
+    defer tm.mu.Unlock()
+    tm.images = scanner
+}
+
+// defaultTags возвращает предопределенные теги. Набор имен фиксирован:
+// каждому тегу соответствует свой критерий в analyzeContainer.
+func defaultTags() map[string]EcoTag {
+    return map[string]EcoTag{
         "eco-efficient": {
             Name:        "eco-efficient",
             Description: "Сервис демонстрирует высокую энергоэффективность",
@@ -94,64 +141,100 @@ func (tm *TagManager) initializeTags() {
             Weight:      0.7,
             Threshold:   0.8, // Коэффициент активности в пиковые часы
         },
+        "storage-intensive": {
+            Name:        "storage-intensive",
+            Description: "Сервис интенсивно работает с дисками: энергия уходит на хранилище, а не на CPU",
+            Score:       40,
+            Weight:      0.6,
+            Threshold:   50, // МБ/с чтения и записи
+        },
+        "bloated-image": {
+            Name:        "bloated-image",
+            Description: "Контейнерный образ сервиса избыточно велик, доставка расходует лишнюю энергию",
+            Score:       40,
+            Weight:      0.5,
+            Threshold:   1024, // МБ
+        },
     }
 }
 
-func (tm *TagManager) Start(ctx context.Context) error {
-    ticker := time.NewTicker(tm.config.UpdateInterval)
-    defer ticker.Stop()
-
-    for {
-        select {
-        case <-ctx.Done():
-            return ctx.Err()
-        case <-ticker.C:
-            if err := tm.updateProfiles(ctx); err != nil {
-                continue
-            }
-        }
-    }
+// Jobs возвращает периодические задачи менеджера эко-тегов
+func (tm *TagManager) Jobs() []scheduler.Job {
+    return []scheduler.Job{{
+        Name:     "ecotags.update",
+        Interval: tm.config.UpdateInterval,
+        Run:      tm.updateProfiles,
+    }}
 }
 
 func (tm *TagManager) updateProfiles(ctx context.Context) error {
+    _, err := tm.Reevaluate(ctx)
+    return err
+}
+
+// Reevaluate перестраивает профили всех активных сервисов по текущим
+// определениям тегов и возвращает число обновленных профилей
+func (tm *TagManager) Reevaluate(ctx context.Context) (int, error) {
     containers, err := tm.getActiveContainers(ctx)
     if err != nil {
-        return err
+        return 0, err
     }
 
+    defs := tm.Definitions()
+    updated := 0
     for _, container := range containers {
-        profile := tm.analyzeContainer(container)
-        if profile != nil {
-            tm.mu.Lock()
-            tm.profiles[container.ServiceName] = profile
-            tm.mu.Unlock()
+        profile := tm.analyzeContainer(container, defs)
+        if profile != nil && tm.storeProfile(profile) {
+            updated++
         }
     }
 
-    return nil
+    // Плановые обновления не журналируются, перестройка по запросу - да
+    if requestid.FromContext(ctx) != "" {
+        log.Printf("%sЭко-профили перестроены по версии тегов %d: %d", requestid.Prefix(ctx), defs.Version, updated)
+    }
+    return updated, nil
 }
 
-func (tm *TagManager) analyzeContainer(container models.Container) *ServiceEcoProfile {
-    metrics, err := tm.collector.GetMetrics(container.ServerID)
+func (tm *TagManager) analyzeContainer(container models.Container, defs Definitions) *ServiceEcoProfile {
+    // Собственные метрики контейнера точнее метрик всего сервера; без них
+    // профиль строится по серверу, как раньше
+    // После развертывания профиль строится только по точкам новой версии
+    tenantID, _ := tm.collector.ServerTenant(container.ServerID)
+    tm.mu.RLock()
+    deployed := tm.deployments[profileKey(tenantID, container.ServiceName)]
+    tm.mu.RUnlock()
+
+    metrics, err := tm.collector.GetContainerMetrics(container.ID)
+    metrics = since(metrics, deployed.since)
+    if err != nil || len(metrics) < tm.config.MinDataPoints {
+        metrics, err = tm.collector.GetMetrics(container.ServerID)
+        metrics = since(metrics, deployed.since)
+    }
     if err != nil || len(metrics) < tm.config.MinDataPoints {
         return nil
     }
 
     // Рассчитываем средние показатели
-    var totalPower, totalCarbon float64
+    var totalPower, totalCarbon, totalDiskIO, totalStorage float64
     for _, m := range metrics {
         totalPower += m.PowerUsage
         totalCarbon += m.CarbonFootprint
+        totalDiskIO += m.DiskReadBytesPerSec + m.DiskWriteBytesPerSec
+        totalStorage += m.StorageUsedBytes
     }
     avgPower := totalPower / float64(len(metrics))
     avgCarbon := totalCarbon / float64(len(metrics))
+    avgDiskIO := totalDiskIO / float64(len(metrics))
+
+    imageReport, hasImageReport := tm.imageReport(container.Image)
 
     // Определяем подходящие теги
     var tags []string
     var totalScore float64
     var totalWeight float64
 
-    for tagName, tag := range tm.tags {
+    for tagName, tag := range defs.Tags {
         switch tagName {
         case "eco-efficient":
             if tm.analyzer.CalculateEcoScore(metrics) >= tag.Threshold {
@@ -178,7 +261,19 @@ func (tm *TagManager) analyzeContainer(container models.Container) *ServiceEcoPr
                 totalWeight += tag.Weight
             }
         case "peak-hours":
-            if tm.isPeakHoursActive(metrics) {
+            if tm.isPeakHoursActive(metrics, tm.peakSchedule(container.ServerID)) {
+                tags = append(tags, tagName)
+                totalScore += tag.Score * tag.Weight
+                totalWeight += tag.Weight
+            }
+        case "storage-intensive":
+            if avgDiskIO/(1<<20) >= tag.Threshold {
+                tags = append(tags, tagName)
+                totalScore += tag.Score * tag.Weight
+                totalWeight += tag.Weight
+            }
+        case "bloated-image":
+            if hasImageReport && float64(imageReport.SizeBytes>>20) >= tag.Threshold {
                 tags = append(tags, tagName)
                 totalScore += tag.Score * tag.Weight
                 totalWeight += tag.Weight
@@ -192,21 +287,85 @@ func (tm *TagManager) analyzeContainer(container models.Container) *ServiceEcoPr
         ecoScore = 50 // Значение по умолчанию
     }
 
-    return &ServiceEcoProfile{
+    profile := &ServiceEcoProfile{
         ServiceName:     container.ServiceName,
+        TenantID:        tenantID,
         Tags:           tags,
         EcoScore:       ecoScore,
         PowerUsage:     avgPower,
         CarbonFootprint: avgCarbon,
+        DiskIOBytesPerSec: avgDiskIO,
+        StorageUsedBytes:  totalStorage / float64(len(metrics)),
+        Labels:         tm.serviceLabels(container),
+        TagsVersion:    defs.Version,
+        Image:          container.Image,
+        DeployedAt:     deployed.since,
+        Fingerprint:    newFingerprint(container.Image, deployed.since, metrics),
         LastUpdate:     time.Now(),
     }
+    if deployed.previous != nil {
+        profile.FingerprintChange = compareFingerprints(deployed.previous, profile.Fingerprint)
+    }
+
+    if hasImageReport {
+        profile.ImageSizeBytes = imageReport.SizeBytes
+        profile.DeploymentEnergyWh = imageReport.DeploymentEnergyWh
+        profile.ImageFindings = imageReport.Findings
+    }
+
+    return profile
 }
 
-func (tm *TagManager) GetServiceProfile(serviceName string) (*ServiceEcoProfile, error) {
+// SetInventory включает вывод меток сервисов из метаданных оркестратора и облака
+func (tm *TagManager) SetInventory(inv *inventory.Inventory) {
+    tm.mu.Lock()
+    defer tm.mu.Unlock()
+    tm.inventory = inv
+}
+
+// serviceLabels возвращает выведенные метки сервиса контейнера
+func (tm *TagManager) serviceLabels(container models.Container) map[string]string {
+    tm.mu.RLock()
+    inv := tm.inventory
+    tm.mu.RUnlock()
+
+    if inv == nil {
+        return nil
+    }
+    return inv.ContainerLabels(container).Values
+}
+
+// imageReport возвращает результат сканирования образа и ставит образ на периодическое сканирование
+func (tm *TagManager) imageReport(image string) (imagescan.Report, bool) {
+    tm.mu.RLock()
+    scanner := tm.images
+    tm.mu.RUnlock()
+
+    if scanner == nil || image == "" {
+        return imagescan.Report{}, false
+    }
+
+    scanner.Track(image)
+    report, exists := scanner.Report(image)
+    if !exists || report.Error != "" {
+        return imagescan.Report{}, false
+    }
+    return report, true
+}
+
+// profileKey - ключ профиля: одноименные сервисы разных арендаторов не смешиваются
+func profileKey(tenantID, serviceName string) string {
+    if tenantID == metrics.DefaultTenant {
+        return serviceName
+    }
+    return tenantID + "/" + serviceName
+}
+
+func (tm *TagManager) GetServiceProfile(tenantID, serviceName string) (*ServiceEcoProfile, error) {
     tm.mu.RLock()
     defer tm.mu.RUnlock()
 
-    profile, exists := tm.profiles[serviceName]
+    profile, exists := tm.profiles[profileKey(tenantID, serviceName)]
     if !exists {
         return nil, fmt.Errorf("profile not found for service: %s", serviceName)
     }
@@ -224,23 +383,34 @@ func (tm *TagManager) GetAllProfiles() []*ServiceEcoProfile {
     return profiles
 }
 
-func (tm *TagManager) isPeakHoursActive(metrics []models.MetricData) bool {
-    peakHours := map[int]bool{
-        9:  true,
-        10: true,
-        11: true,
-        12: true,
-        13: true,
-        14: true,
-        15: true,
-        16: true,
-        17: true,
+// SetPeakHours задает часы пик по регионам и арендаторам для тега peak-hours
+func (tm *TagManager) SetPeakHours(policy *peakhours.Policy) {
+    tm.mu.Lock()
+    defer tm.mu.Unlock()
+    tm.peakHours = policy
+}
+
+// peakSchedule возвращает часы пик площадки сервера: по его арендатору и
+// региону из инвентаря
+func (tm *TagManager) peakSchedule(serverID string) *peakhours.Schedule {
+    tm.mu.RLock()
+    policy, inv := tm.peakHours, tm.inventory
+    tm.mu.RUnlock()
+
+    var region string
+    if inv != nil {
+        if server, exists := inv.Server(serverID); exists {
+            region = server.Region
+        }
     }
+    tenantID, _ := tm.collector.ServerTenant(serverID)
+    return policy.For(region, tenantID)
+}
 
+func (tm *TagManager) isPeakHoursActive(metrics []models.MetricData, schedule *peakhours.Schedule) bool {
     var peakCount, totalCount int
     for _, m := range metrics {
-        hour := time.Unix(m.Timestamp, 0).Hour()
-        if peakHours[hour] {
+        if schedule.IsPeak(time.Unix(m.Timestamp, 0)) {
             if m.PowerUsage > 0 {
                 peakCount++
             }
@@ -255,7 +425,62 @@ func (tm *TagManager) isPeakHoursActive(metrics []models.MetricData) bool {
     return float64(peakCount)/float64(totalCount) >= 0.8
 }
 
+// getActiveContainers возвращает работающие контейнеры сервисов по их
+// точкам и событиям жизненного цикла
 func (tm *TagManager) getActiveContainers(ctx context.Context) ([]models.Container, error) {
-    // Здесь должна быть реализация получения списка активных контейнеров
-    return []models.Container{}, nil
+    var active []models.Container
+    for _, container := range tm.collector.Containers("") {
+        if container.ServiceName != "" {
+            active = append(active, container)
+        }
+    }
+    return active, nil
+}
+
+// ContainerEvent сбрасывает профиль сервиса при развертывании: запуске
+// контейнера с новым образом или с причиной deploy. Перезапуск и перенос
+// того же образа профиль не сбрасывают. Новый профиль строится только по
+// точкам после развертывания, когда их наберется MinDataPoints, и его
+// энергетический отпечаток сравнивается с отпечатком прежнего профиля.
+func (tm *TagManager) ContainerEvent(event metrics.ContainerEvent) {
+    if event.Type == metrics.ContainerStopped || event.ServiceName == "" {
+        return
+    }
+    tenantID, _ := tm.collector.ServerTenant(event.ServerID)
+    key := profileKey(tenantID, event.ServiceName)
+
+    tm.mu.Lock()
+    defer tm.mu.Unlock()
+
+    current, known := tm.deployments[key]
+    redeployed := event.Reason == "deploy" || known && event.Image != "" && event.Image != current.image
+    switch {
+    case redeployed && event.Timestamp.After(current.since):
+        // Развертывание без собственного профиля (например, сразу
+        // замененное следующим) не заменяет отпечаток для сравнения
+        previous := current.previous
+        if profile, exists := tm.profiles[key]; exists && profile.Fingerprint != nil {
+            previous = profile.Fingerprint
+        }
+        tm.deployments[key] = deployment{image: event.Image, since: event.Timestamp, previous: previous}
+        if _, exists := tm.profiles[key]; exists {
+            delete(tm.profiles, key)
+            log.Printf("Эко-профиль сервиса %s сброшен: развертывание образа %s", event.ServiceName, event.Image)
+        }
+    case !known && event.Image != "":
+        tm.deployments[key] = deployment{image: event.Image}
+    }
+}
+
+// since оставляет точки не раньше from; нулевое from - все точки
+func since(data []models.MetricData, from time.Time) []models.MetricData {
+    if from.IsZero() {
+        return data
+    }
+    for i, m := range data {
+        if m.Timestamp >= from.Unix() {
+            return data[i:]
+        }
+    }
+    return nil
 } 
\ No newline at end of file
@@ -0,0 +1,260 @@
+package ecotags
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+)
+
+// EcoCheckSchemaVersion - версия схемы ответа eco-check. Поля схемы не
+// переименовываются и не удаляются: на них опираются конвейеры развертывания.
+const EcoCheckSchemaVersion = 1
+
+// ErrNoSLO - для сервиса не задана эко-цель
+var ErrNoSLO = errors.New("no eco slo defined for service")
+
+// Итог проверки сервиса
+const (
+	CheckPass = "pass"
+	CheckFail = "fail"
+)
+
+// SLO - эко-цели сервиса; нулевое поле не проверяется
+type SLO struct {
+	MinEcoScore        float64 `json:"min_eco_score,omitempty"`
+	MaxPowerUsage      float64 `json:"max_power_usage,omitempty"`      // Среднее потребление, Вт
+	MaxCarbonFootprint float64 `json:"max_carbon_footprint,omitempty"` // кг CO2
+	// MaxRegressionPercent - допустимый рост среднего потребления
+	// относительно базового уровня: медианы прошлых профилей сервиса
+	MaxRegressionPercent float64 `json:"max_regression_percent,omitempty"`
+	// MaxFingerprintShiftPercent - допустимый рост потребления при той же
+	// загрузке CPU относительно предыдущего развертывания: регрессия видна,
+	// даже когда среднее потребление не выросло из-за спада трафика
+	MaxFingerprintShiftPercent float64 `json:"max_fingerprint_shift_percent,omitempty"`
+}
+
+func (s SLO) empty() bool {
+	return s == SLO{}
+}
+
+func (s SLO) validate() error {
+	if s.MinEcoScore < 0 || s.MinEcoScore > 100 {
+		return fmt.Errorf("min_eco_score must be between 0 and 100")
+	}
+	if s.MaxPowerUsage < 0 || s.MaxCarbonFootprint < 0 || s.MaxRegressionPercent < 0 || s.MaxFingerprintShiftPercent < 0 {
+		return fmt.Errorf("max_power_usage, max_carbon_footprint, max_regression_percent and max_fingerprint_shift_percent must not be negative")
+	}
+	return nil
+}
+
+// SLOPolicy - эко-цели по сервисам; Default действует для сервисов без
+// своих целей
+type SLOPolicy struct {
+	Default  SLO            `json:"default"`
+	Services map[string]SLO `json:"services,omitempty"`
+}
+
+func (p SLOPolicy) Validate() error {
+	if err := p.Default.validate(); err != nil {
+		return fmt.Errorf("default: %w", err)
+	}
+	for _, service := range p.ServiceNames() {
+		if err := p.Services[service].validate(); err != nil {
+			return fmt.Errorf("%s: %w", service, err)
+		}
+	}
+	return nil
+}
+
+// ServiceNames возвращает сервисы со своими целями
+func (p SLOPolicy) ServiceNames() []string {
+	names := make([]string, 0, len(p.Services))
+	for name := range p.Services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// For возвращает цели сервиса; false - целей нет ни у сервиса, ни по умолчанию
+func (p SLOPolicy) For(service string) (SLO, bool) {
+	if slo, ok := p.Services[service]; ok {
+		return slo, !slo.empty()
+	}
+	return p.Default, !p.Default.empty()
+}
+
+// LoadSLOPolicy читает эко-цели из JSON-файла вида {"default":
+// {"max_regression_percent": 10}, "services": {"checkout": {"max_power_usage": 250}}}
+func LoadSLOPolicy(path string) (SLOPolicy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return SLOPolicy{}, err
+	}
+	var policy SLOPolicy
+	if err := json.Unmarshal(data, &policy); err != nil {
+		return SLOPolicy{}, fmt.Errorf("invalid eco slo file %s: %w", path, err)
+	}
+	return policy, policy.Validate()
+}
+
+// SetSLOs задает эко-цели сервисов
+func (tm *TagManager) SetSLOs(policy SLOPolicy) {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	tm.slos = policy
+}
+
+// Objective - результат одной цели. Actual - текущее значение; Skipped -
+// цель не проверена (например, нет прошлых профилей для базового уровня).
+type Objective struct {
+	Name      string  `json:"name"`
+	Threshold float64 `json:"threshold"`
+	Actual    float64 `json:"actual"`
+	Unit      string  `json:"unit"`
+	Pass      bool    `json:"pass"`
+	Skipped   bool    `json:"skipped,omitempty"`
+	Detail    string  `json:"detail,omitempty"`
+}
+
+// EcoCheckValues - текущие показатели сервиса и базовый уровень
+type EcoCheckValues struct {
+	EcoScore           float64 `json:"eco_score"`
+	PowerUsage         float64 `json:"power_usage"`
+	CarbonFootprint    float64 `json:"carbon_footprint"`
+	BaselinePowerUsage float64 `json:"baseline_power_usage,omitempty"` // Медиана прошлых профилей
+	BaselineProfiles   int     `json:"baseline_profiles"`
+}
+
+// EcoCheck - итог проверки сервиса по эко-целям для конвейеров
+// развертывания: Result = pass, если пройдены все проверенные цели
+type EcoCheck struct {
+	SchemaVersion    int            `json:"schema_version"`
+	Service          string         `json:"service"`
+	TenantID         string         `json:"tenant_id,omitempty"`
+	Result           string         `json:"result"`
+	Pass             bool           `json:"pass"`
+	SLO              SLO            `json:"slo"`
+	Objectives       []Objective    `json:"objectives"`
+	Current          EcoCheckValues `json:"current"`
+	ProfileUpdatedAt time.Time      `json:"profile_updated_at"`
+	CheckedAt        time.Time      `json:"checked_at"`
+	// Fingerprint - сравнение энергетического отпечатка с предыдущим
+	// развертыванием; есть, если сервис развертывался при работе Platypus
+	Fingerprint *FingerprintChange `json:"fingerprint,omitempty"`
+}
+
+// Check проверяет текущий профиль сервиса арендатора по его эко-целям
+func (tm *TagManager) Check(tenantID, serviceName string, now time.Time) (EcoCheck, error) {
+	tm.mu.RLock()
+	slo, defined := tm.slos.For(serviceName)
+	history := append([]*ServiceEcoProfile(nil), tm.history[profileKey(tenantID, serviceName)]...)
+	profile, exists := tm.profiles[profileKey(tenantID, serviceName)]
+	tm.mu.RUnlock()
+
+	if !defined {
+		return EcoCheck{}, fmt.Errorf("%w: %s", ErrNoSLO, serviceName)
+	}
+	if !exists {
+		return EcoCheck{}, fmt.Errorf("profile not found for service: %s", serviceName)
+	}
+
+	check := EcoCheck{
+		SchemaVersion: EcoCheckSchemaVersion,
+		Service:       serviceName,
+		TenantID:      profile.TenantID,
+		Pass:          true,
+		SLO:           slo,
+		Objectives:    []Objective{},
+		Current: EcoCheckValues{
+			EcoScore:        profile.EcoScore,
+			PowerUsage:      profile.PowerUsage,
+			CarbonFootprint: profile.CarbonFootprint,
+		},
+		ProfileUpdatedAt: profile.LastUpdate,
+		CheckedAt:        now,
+		Fingerprint:      profile.FingerprintChange,
+	}
+	add := func(objective Objective) {
+		if !objective.Skipped && !objective.Pass {
+			check.Pass = false
+		}
+		check.Objectives = append(check.Objectives, objective)
+	}
+
+	if slo.MinEcoScore > 0 {
+		add(Objective{Name: "min_eco_score", Threshold: slo.MinEcoScore, Actual: profile.EcoScore, Unit: "score",
+			Pass: profile.EcoScore >= slo.MinEcoScore})
+	}
+	if slo.MaxPowerUsage > 0 {
+		add(Objective{Name: "max_power_usage", Threshold: slo.MaxPowerUsage, Actual: profile.PowerUsage, Unit: "W",
+			Pass: profile.PowerUsage <= slo.MaxPowerUsage})
+	}
+	if slo.MaxCarbonFootprint > 0 {
+		add(Objective{Name: "max_carbon_footprint", Threshold: slo.MaxCarbonFootprint, Actual: profile.CarbonFootprint, Unit: "kg",
+			Pass: profile.CarbonFootprint <= slo.MaxCarbonFootprint})
+	}
+	if slo.MaxRegressionPercent > 0 {
+		objective := Objective{Name: "max_regression_percent", Threshold: slo.MaxRegressionPercent, Unit: "%", Pass: true}
+		baseline, n := baselinePower(history, profile)
+		check.Current.BaselinePowerUsage, check.Current.BaselineProfiles = baseline, n
+		if n == 0 || baseline <= 0 {
+			objective.Skipped = true
+			objective.Detail = "no earlier profiles to compare with"
+		} else {
+			objective.Actual = (profile.PowerUsage - baseline) / baseline * 100
+			objective.Pass = objective.Actual <= slo.MaxRegressionPercent
+			objective.Detail = fmt.Sprintf("%.1f W against median %.1f W of %d earlier profiles", profile.PowerUsage, baseline, n)
+		}
+		add(objective)
+	}
+	if slo.MaxFingerprintShiftPercent > 0 {
+		objective := Objective{Name: "max_fingerprint_shift_percent", Threshold: slo.MaxFingerprintShiftPercent, Unit: "%", Pass: true}
+		switch change := profile.FingerprintChange; {
+		case change == nil:
+			objective.Skipped = true
+			objective.Detail = "no earlier deployment fingerprint to compare with"
+		case !change.Comparable:
+			objective.Skipped = true
+			objective.Detail = fmt.Sprintf("only %.0f%% of points fall into load bands seen before the deployment", change.Coverage*100)
+		default:
+			objective.Actual = change.ShiftPercent
+			objective.Pass = objective.Actual <= slo.MaxFingerprintShiftPercent
+			previous := "the previous deployment"
+			if change.PreviousImage != "" {
+				previous = "image " + change.PreviousImage
+			}
+			objective.Detail = fmt.Sprintf("power at equal CPU load against %s over %d load bands", previous, len(change.Bands))
+		}
+		add(objective)
+	}
+
+	check.Result = CheckPass
+	if !check.Pass {
+		check.Result = CheckFail
+	}
+	return check, nil
+}
+
+// baselinePower - медиана потребления прошлых профилей сервиса без
+// текущего; медиана не сдвигается от одного неудачного обновления
+func baselinePower(history []*ServiceEcoProfile, current *ServiceEcoProfile) (float64, int) {
+	values := make([]float64, 0, len(history))
+	for _, profile := range history {
+		if profile != current {
+			values = append(values, profile.PowerUsage)
+		}
+	}
+	if len(values) == 0 {
+		return 0, 0
+	}
+	sort.Float64s(values)
+	mid := len(values) / 2
+	if len(values)%2 == 0 {
+		return (values[mid-1] + values[mid]) / 2, len(values)
+	}
+	return values[mid], len(values)
+}
@@ -0,0 +1,140 @@
+// Package edge управляет агентами на периферийных устройствах: подсказывает им
+// интервал сбора по тому, насколько изменчиво потребление устройства, и задает
+// размер и частоту выгрузки накопленных точек.
+package edge
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/YumeNoTenshi/platypus/internal/metrics"
+)
+
+type Policy struct {
+	MinInterval     time.Duration // Интервал сбора при сильно меняющемся потреблении
+	MaxInterval     time.Duration // Интервал сбора при стабильном потреблении
+	DefaultInterval time.Duration // Интервал, пока данных об устройстве недостаточно
+	UploadInterval  time.Duration // Как часто агент выгружает накопленные точки
+	MaxBatch        int           // Максимум точек в одной выгрузке
+	Window          time.Duration // За какой период оценивается изменчивость
+	// StableVariation и VolatileVariation - коэффициенты вариации мощности,
+	// при которых выбираются MaxInterval и MinInterval; между ними интервал
+	// меняется линейно
+	StableVariation   float64
+	VolatileVariation float64
+	MaxUploadBytes    int64 // Ограничение размера распакованного тела выгрузки
+}
+
+// DefaultPolicy подходит для устройств с ограниченным каналом связи
+var DefaultPolicy = Policy{
+	MinInterval:       15 * time.Second,
+	MaxInterval:       5 * time.Minute,
+	DefaultInterval:   time.Minute,
+	UploadInterval:    5 * time.Minute,
+	MaxBatch:          1000,
+	Window:            time.Hour,
+	StableVariation:   0.05,
+	VolatileVariation: 0.3,
+	MaxUploadBytes:    8 << 20,
+}
+
+// minSamples - сколько точек нужно, чтобы судить об изменчивости
+const minSamples = 5
+
+// Directive - указание агенту устройства. Агент применяет его после каждой
+// выгрузки и при запросе GET /edge/{server_id}/directive.
+type Directive struct {
+	ServerID       string    `json:"server_id"`
+	SampleInterval int       `json:"sample_interval_seconds"`
+	UploadInterval int       `json:"upload_interval_seconds"`
+	MaxBatch       int       `json:"max_batch"`
+	Variation      float64   `json:"variation"` // Коэффициент вариации мощности за Window
+	Reason         string    `json:"reason"`
+	IssuedAt       time.Time `json:"issued_at"`
+}
+
+type Planner struct {
+	policy    Policy
+	collector *metrics.Collector
+}
+
+func NewPlanner(policy Policy, collector *metrics.Collector) (*Planner, error) {
+	if policy.MinInterval <= 0 || policy.MaxInterval < policy.MinInterval {
+		return nil, fmt.Errorf("edge sampling intervals must satisfy 0 < min <= max")
+	}
+	if policy.DefaultInterval < policy.MinInterval || policy.DefaultInterval > policy.MaxInterval {
+		policy.DefaultInterval = policy.MinInterval + (policy.MaxInterval-policy.MinInterval)/2
+	}
+	if policy.UploadInterval <= 0 {
+		policy.UploadInterval = DefaultPolicy.UploadInterval
+	}
+	if policy.MaxBatch <= 0 {
+		policy.MaxBatch = DefaultPolicy.MaxBatch
+	}
+	if policy.Window <= 0 {
+		policy.Window = DefaultPolicy.Window
+	}
+	if policy.VolatileVariation <= policy.StableVariation {
+		return nil, fmt.Errorf("edge volatile variation must be greater than stable variation")
+	}
+	if policy.MaxUploadBytes <= 0 {
+		policy.MaxUploadBytes = DefaultPolicy.MaxUploadBytes
+	}
+	return &Planner{policy: policy, collector: collector}, nil
+}
+
+// Policy возвращает действующую политику
+func (p *Planner) Policy() Policy {
+	return p.policy
+}
+
+// Directive подбирает интервал сбора для устройства. Чем стабильнее мощность
+// за последнее окно, тем реже агент снимает показания и тем меньше расходует
+// батарею, память и канал.
+func (p *Planner) Directive(serverID string) Directive {
+	now := time.Now()
+	directive := Directive{
+		ServerID:       serverID,
+		SampleInterval: int(p.policy.DefaultInterval / time.Second),
+		UploadInterval: int(p.policy.UploadInterval / time.Second),
+		MaxBatch:       p.policy.MaxBatch,
+		IssuedAt:       now.UTC(),
+	}
+
+	data, err := p.collector.GetMetricsRange(serverID, now.Add(-p.policy.Window), now)
+	if err != nil || len(data) < minSamples {
+		directive.Reason = "not enough recent samples, using default interval"
+		return directive
+	}
+
+	var sum, sumSquares float64
+	for _, m := range data {
+		sum += m.PowerUsage
+		sumSquares += m.PowerUsage * m.PowerUsage
+	}
+	mean := sum / float64(len(data))
+	if mean <= 0 {
+		directive.Reason = "no power reported, using default interval"
+		return directive
+	}
+	variance := math.Max(sumSquares/float64(len(data))-mean*mean, 0)
+	variation := math.Sqrt(variance) / mean
+	directive.Variation = math.Round(variation*1000) / 1000
+
+	// Доля пути от стабильного к изменчивому потреблению
+	share := (variation - p.policy.StableVariation) / (p.policy.VolatileVariation - p.policy.StableVariation)
+	share = math.Min(math.Max(share, 0), 1)
+	interval := p.policy.MaxInterval - time.Duration(share*float64(p.policy.MaxInterval-p.policy.MinInterval))
+	directive.SampleInterval = int(interval / time.Second)
+
+	switch share {
+	case 0:
+		directive.Reason = "power is stable, sampling at the longest interval"
+	case 1:
+		directive.Reason = "power is volatile, sampling at the shortest interval"
+	default:
+		directive.Reason = fmt.Sprintf("power variation %.2f, interval scaled between policy bounds", variation)
+	}
+	return directive
+}
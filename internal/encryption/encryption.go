@@ -0,0 +1,262 @@
+// Package encryption шифрует данные, которые platypus хранит на диске:
+// журнал приема, снимки и вытесненные точки. Шифр - AES-256-GCM; каждый
+// зашифрованный блок несет идентификатор ключа, поэтому после смены
+// основного ключа прежние блоки читаются прежними ключами, пока данные не
+// перезаписаны новым.
+package encryption
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+// magic открывает зашифрованный блок: magic, длина идентификатора ключа
+// (1 байт), идентификатор, nonce, шифртекст с тегом GCM. Заголовок до nonce
+// аутентифицируется вместе с данными.
+var magic = []byte("PLE1")
+
+// keySize - длина ключа AES-256
+const keySize = 32
+
+var (
+	// ErrUnknownKey - блок зашифрован ключом, которого нет в связке
+	ErrUnknownKey = errors.New("encryption key not found")
+	// ErrNotEncrypted - данные не являются зашифрованным блоком
+	ErrNotEncrypted = errors.New("data is not encrypted")
+)
+
+// Key - ключ шифрования и его идентификатор
+type Key struct {
+	ID       string
+	Material []byte
+}
+
+func (k Key) validate() error {
+	if k.ID == "" || len(k.ID) > 255 {
+		return fmt.Errorf("key id must be 1-255 bytes")
+	}
+	if strings.ContainsAny(k.ID, ":,\n") {
+		return fmt.Errorf("key id %q must not contain ':', ',' or newlines", k.ID)
+	}
+	if len(k.Material) != keySize {
+		return fmt.Errorf("key %s must be %d bytes, got %d", k.ID, keySize, len(k.Material))
+	}
+	return nil
+}
+
+// KeySource - хранилище секретов, из которого берутся ключи. Первый ключ -
+// основной: им шифруются новые данные; остальные нужны для чтения данных,
+// записанных до смены ключа.
+type KeySource interface {
+	Keys(ctx context.Context) ([]Key, error)
+}
+
+// EnvKeys читает ключи из переменной окружения с этим именем
+type EnvKeys string
+
+func (e EnvKeys) Keys(ctx context.Context) ([]Key, error) {
+	value := os.Getenv(string(e))
+	if value == "" {
+		return nil, fmt.Errorf("%s is not set", string(e))
+	}
+	return ParseKeys(value)
+}
+
+// FileKeys читает ключи из файла, например смонтированного секрета
+// Kubernetes; файл перечитывается при каждой загрузке, так что смена ключа
+// не требует перезапуска
+type FileKeys string
+
+func (f FileKeys) Keys(ctx context.Context) ([]Key, error) {
+	data, err := os.ReadFile(string(f))
+	if err != nil {
+		return nil, err
+	}
+	return ParseKeys(string(data))
+}
+
+// ParseKeys разбирает список "id:ключ в base64" через запятую или перевод
+// строки; первый ключ - основной. Ключ - 32 случайных байта, например
+// openssl rand -base64 32.
+func ParseKeys(value string) ([]Key, error) {
+	var keys []Key
+	seen := make(map[string]bool)
+	for _, item := range strings.FieldsFunc(value, func(r rune) bool { return r == ',' || r == '\n' || r == '\r' }) {
+		item = strings.TrimSpace(item)
+		if item == "" || strings.HasPrefix(item, "#") {
+			continue
+		}
+		id, encoded, found := strings.Cut(item, ":")
+		if !found {
+			return nil, fmt.Errorf("invalid key %q: expected id:base64", item)
+		}
+		material, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+		if err != nil {
+			return nil, fmt.Errorf("invalid key %s: %w", id, err)
+		}
+		key := Key{ID: strings.TrimSpace(id), Material: material}
+		if err := key.validate(); err != nil {
+			return nil, err
+		}
+		if seen[key.ID] {
+			return nil, fmt.Errorf("duplicate key id %s", key.ID)
+		}
+		seen[key.ID] = true
+		keys = append(keys, key)
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no encryption keys")
+	}
+	return keys, nil
+}
+
+// Keyring - связка ключей: основной шифрует, все расшифровывают
+type Keyring struct {
+	source KeySource
+
+	mu      sync.RWMutex
+	primary string
+	ciphers map[string]cipher.AEAD
+}
+
+// Load загружает ключи из источника
+func Load(ctx context.Context, source KeySource) (*Keyring, error) {
+	k := &Keyring{source: source}
+	if _, err := k.Reload(ctx); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+// NewKeyring создает связку из готовых ключей; первый - основной
+func NewKeyring(keys ...Key) (*Keyring, error) {
+	k := &Keyring{}
+	if err := k.set(keys); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+// Reload перечитывает ключи из источника; changed - сменился основной ключ.
+// Ошибка оставляет прежние ключи.
+func (k *Keyring) Reload(ctx context.Context) (changed bool, err error) {
+	if k.source == nil {
+		return false, nil
+	}
+	keys, err := k.source.Keys(ctx)
+	if err != nil {
+		return false, err
+	}
+	previous := k.Primary()
+	if err := k.set(keys); err != nil {
+		return false, err
+	}
+	return previous != "" && previous != k.Primary(), nil
+}
+
+func (k *Keyring) set(keys []Key) error {
+	if len(keys) == 0 {
+		return fmt.Errorf("no encryption keys")
+	}
+	ciphers := make(map[string]cipher.AEAD, len(keys))
+	for _, key := range keys {
+		if err := key.validate(); err != nil {
+			return err
+		}
+		block, err := aes.NewCipher(key.Material)
+		if err != nil {
+			return err
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return err
+		}
+		ciphers[key.ID] = aead
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.primary, k.ciphers = keys[0].ID, ciphers
+	return nil
+}
+
+// Primary возвращает идентификатор основного ключа
+func (k *Keyring) Primary() string {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.primary
+}
+
+// Seal шифрует данные основным ключом
+func (k *Keyring) Seal(plaintext []byte) ([]byte, error) {
+	k.mu.RLock()
+	id, aead := k.primary, k.ciphers[k.primary]
+	k.mu.RUnlock()
+
+	header := make([]byte, 0, len(magic)+1+len(id))
+	header = append(header, magic...)
+	header = append(header, byte(len(id)))
+	header = append(header, id...)
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	sealed := make([]byte, 0, len(header)+len(nonce)+len(plaintext)+aead.Overhead())
+	sealed = append(sealed, header...)
+	sealed = append(sealed, nonce...)
+	return aead.Seal(sealed, nonce, plaintext, header), nil
+}
+
+// Open расшифровывает блок ключом, указанным в его заголовке
+func (k *Keyring) Open(sealed []byte) ([]byte, error) {
+	id, ok := KeyID(sealed)
+	if !ok {
+		return nil, ErrNotEncrypted
+	}
+	k.mu.RLock()
+	aead, exists := k.ciphers[id]
+	k.mu.RUnlock()
+	if !exists {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownKey, id)
+	}
+
+	headerSize := len(magic) + 1 + len(id)
+	if len(sealed) < headerSize+aead.NonceSize() {
+		return nil, fmt.Errorf("encrypted block is truncated")
+	}
+	nonce := sealed[headerSize : headerSize+aead.NonceSize()]
+	plaintext, err := aead.Open(nil, nonce, sealed[headerSize+aead.NonceSize():], sealed[:headerSize])
+	if err != nil {
+		return nil, fmt.Errorf("decrypt with key %s: %w", id, err)
+	}
+	return plaintext, nil
+}
+
+// KeyID возвращает идентификатор ключа зашифрованного блока; false - данные
+// не зашифрованы
+func KeyID(data []byte) (string, bool) {
+	if !bytes.HasPrefix(data, magic) || len(data) < len(magic)+1 {
+		return "", false
+	}
+	size := int(data[len(magic)])
+	if size == 0 || len(data) < len(magic)+1+size {
+		return "", false
+	}
+	return string(data[len(magic)+1 : len(magic)+1+size]), true
+}
+
+// Encrypted сообщает, является ли data зашифрованным блоком
+func Encrypted(data []byte) bool {
+	_, ok := KeyID(data)
+	return ok
+}
@@ -0,0 +1,286 @@
+// Package energy ведет учет потребленной энергии (кВт*ч) по серверам и сервисам,
+// вычисляя ее из отсчетов мощности и сохраняя как производные ряды
+package energy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/YumeNoTenshi/platypus/internal/errtrack"
+	"github.com/YumeNoTenshi/platypus/internal/inventory"
+	"github.com/YumeNoTenshi/platypus/internal/metrics"
+	"github.com/YumeNoTenshi/platypus/internal/scheduler"
+	"github.com/YumeNoTenshi/platypus/pkg/catalog"
+)
+
+// Resolution - шаг производного ряда энергии
+type Resolution string
+
+const (
+	ResolutionHour Resolution = "hour"
+	ResolutionDay  Resolution = "day"
+)
+
+type Config struct {
+	UpdateInterval time.Duration
+	MaxGap         time.Duration // Пропуски длиннее не интегрируются
+	Retention      time.Duration // Сколько хранить производные ряды; обычно дольше сырых метрик
+	// Errors получает исход пересчета под именем "energy"; необязателен
+	Errors errtrack.Recorder
+}
+
+type Accountant struct {
+	config    Config
+	collector *metrics.Collector
+	inventory *inventory.Inventory // Необязателен: нужен для разбивки по сервисам и командам
+	mu        sync.RWMutex
+	hourly    map[string]map[int64]metrics.EnergyBucket // Сервер -> начало часа -> энергия
+}
+
+func New(config Config, collector *metrics.Collector, inv *inventory.Inventory) *Accountant {
+	return &Accountant{
+		config:    config,
+		collector: collector,
+		inventory: inv,
+		hourly:    make(map[string]map[int64]metrics.EnergyBucket),
+	}
+}
+
+// Jobs возвращает периодический пересчет энергии
+func (a *Accountant) Jobs() []scheduler.Job {
+	return []scheduler.Job{{
+		Name:     "energy.update",
+		Interval: a.config.UpdateInterval,
+		Run: func(ctx context.Context) error {
+			a.Update()
+			return nil
+		},
+	}}
+}
+
+// Update пересчитывает почасовую энергию по сырым метрикам. Ранее сохраненные
+// часы заменяются только более полными данными, поэтому ряды переживают
+// удаление сырых метрик по сроку хранения.
+func (a *Accountant) Update() {
+	cutoff := time.Now().Add(-a.config.Retention).Unix()
+
+	var errs []error
+	for _, serverID := range a.collector.ServerIDs() {
+		data, err := a.collector.GetMetrics(serverID)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("metrics of server %s: %w", serverID, err))
+			continue
+		}
+		buckets := metrics.IntegrateEnergy(data, time.Hour, a.config.MaxGap)
+
+		a.mu.Lock()
+		series := a.hourly[serverID]
+		if series == nil {
+			series = make(map[int64]metrics.EnergyBucket)
+			a.hourly[serverID] = series
+		}
+		for _, bucket := range buckets {
+			key := bucket.Start.Unix()
+			if existing, exists := series[key]; !exists || bucket.Coverage >= existing.Coverage {
+				series[key] = bucket
+			}
+		}
+		a.mu.Unlock()
+	}
+
+	a.mu.Lock()
+	for serverID, series := range a.hourly {
+		for key := range series {
+			if key < cutoff {
+				delete(series, key)
+			}
+		}
+		if len(series) == 0 {
+			delete(a.hourly, serverID)
+		}
+	}
+	a.mu.Unlock()
+
+	if a.config.Errors != nil {
+		a.config.Errors.Record("energy", errors.Join(errs...))
+	}
+}
+
+// Series возвращает ряд энергии сервера за [from, to) с шагом час или сутки (UTC)
+func (a *Accountant) Series(serverID string, resolution Resolution, from, to time.Time) ([]metrics.EnergyBucket, error) {
+	var step time.Duration
+	switch resolution {
+	case ResolutionHour, "":
+		step = time.Hour
+	case ResolutionDay:
+		step = 24 * time.Hour
+	default:
+		return nil, fmt.Errorf("unknown resolution: %s", resolution)
+	}
+
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	grouped := make(map[int64]metrics.EnergyBucket)
+	for _, bucket := range a.hourly[serverID] {
+		if bucket.Start.Before(from) || !bucket.Start.Before(to) {
+			continue
+		}
+		start := bucket.Start.Truncate(step).Unix()
+		g := grouped[start]
+		g.Start = bucket.Start.Truncate(step)
+		g.KWh += bucket.KWh
+		g.Coverage += bucket.Coverage * float64(time.Hour) / float64(step)
+		grouped[start] = g
+	}
+
+	series := make([]metrics.EnergyBucket, 0, len(grouped))
+	for _, bucket := range grouped {
+		series = append(series, bucket)
+	}
+	sort.Slice(series, func(i, j int) bool {
+		return series[i].Start.Before(series[j].Start)
+	})
+	return series, nil
+}
+
+// ServerEnergy возвращает энергию сервера за [from, to), кВт*ч
+func (a *Accountant) ServerEnergy(serverID string, from, to time.Time) float64 {
+	series, _ := a.Series(serverID, ResolutionHour, from, to)
+	return metrics.TotalKWh(series)
+}
+
+// ServerIDs возвращает серверы, для которых есть производные ряды
+func (a *Accountant) ServerIDs() []string {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	serverIDs := make([]string, 0, len(a.hourly))
+	for serverID := range a.hourly {
+		serverIDs = append(serverIDs, serverID)
+	}
+	sort.Strings(serverIDs)
+	return serverIDs
+}
+
+// Shares возвращает доли энергии сервера по группам: server, service, region или
+// имени метки (team, environment, cost_center). Энергия сервера делится поровну
+// между сервисами, контейнеры которых на нем работают: по событиям жизненного
+// цикла, а без них - по всем встречавшимся на сервере. Сервер без известных
+// сервисов относится к группе по собственным облачным тегам.
+func (a *Accountant) Shares(groupBy, serverID string) (map[string]float64, error) {
+	return a.shares(groupBy, serverID, func() map[string]float64 {
+		services, known := a.collector.RunningServices(serverID)
+		if !known {
+			services = a.inventory.ServicesOn(serverID)
+		}
+		return equalWeights(services)
+	})
+}
+
+// SharesBetween - то же, что Shares, за [from, to): по событиям жизненного
+// цикла доля сервиса пропорциональна времени работы его контейнеров, и
+// энергия не достается сервисам, которые на сервере уже не работали
+func (a *Accountant) SharesBetween(groupBy, serverID string, from, to time.Time) (map[string]float64, error) {
+	return a.shares(groupBy, serverID, func() map[string]float64 {
+		runtime, known := a.collector.ServiceRuntime(serverID, from, to)
+		if !known {
+			return equalWeights(a.inventory.ServicesOn(serverID))
+		}
+		weights := make(map[string]float64, len(runtime))
+		for service, d := range runtime {
+			weights[service] = d.Seconds()
+		}
+		return weights
+	})
+}
+
+// shares делит энергию сервера по группам пропорционально весам сервисов;
+// сервис "" - контейнеры без известного сервиса
+func (a *Accountant) shares(groupBy, serverID string, services func() map[string]float64) (map[string]float64, error) {
+	switch groupBy {
+	case "server":
+		return map[string]float64{serverID: 1}, nil
+	case "":
+		return nil, fmt.Errorf("grouping is required")
+	}
+	if a.inventory == nil {
+		return nil, fmt.Errorf("grouping by %s requires inventory", groupBy)
+	}
+
+	if groupBy == "region" {
+		server, _ := a.inventory.Server(serverID)
+		return map[string]float64{orUnassigned(catalog.RegionOf(server.Region)): 1}, nil
+	}
+
+	unattributed := func() string {
+		if groupBy == "service" {
+			return "unattributed"
+		}
+		return orUnassigned(a.inventory.ServerLabels(serverID).Get(groupBy))
+	}
+
+	weights := services()
+	var total float64
+	for _, weight := range weights {
+		total += weight
+	}
+	if total == 0 {
+		return map[string]float64{unattributed(): 1}, nil
+	}
+
+	shares := make(map[string]float64)
+	for service, weight := range weights {
+		group := service
+		switch {
+		case service == "":
+			group = unattributed()
+		case groupBy != "service":
+			group = a.inventory.ServiceLabels(service).Get(groupBy)
+			if group == "" {
+				group = orUnassigned(a.inventory.ServerLabels(serverID).Get(groupBy))
+			}
+		}
+		shares[group] += weight / total
+	}
+	return shares, nil
+}
+
+// Showback распределяет энергию за [from, to) по группам (см. SharesBetween), кВт*ч
+func (a *Accountant) Showback(groupBy string, from, to time.Time) (map[string]float64, error) {
+	result := make(map[string]float64)
+	for _, serverID := range a.ServerIDs() {
+		kwh := a.ServerEnergy(serverID, from, to)
+		if kwh == 0 {
+			continue
+		}
+
+		shares, err := a.SharesBetween(groupBy, serverID, from, to)
+		if err != nil {
+			return nil, err
+		}
+		for group, share := range shares {
+			result[group] += kwh * share
+		}
+	}
+	return result, nil
+}
+
+func equalWeights(services []string) map[string]float64 {
+	weights := make(map[string]float64, len(services))
+	for _, service := range services {
+		weights[service] = 1
+	}
+	return weights
+}
+
+func orUnassigned(value string) string {
+	if value == "" {
+		return "unassigned"
+	}
+	return value
+}
@@ -0,0 +1,186 @@
+// Package errtrack учитывает ошибки, после которых фоновые циклы подсистем
+// продолжают работу. Такие ошибки не останавливают сервис, поэтому без учета
+// он выглядит здоровым, ничего не делая. Трекер считает ошибки, хранит
+// последнюю по каждой подсистеме и оповещает о затяжных сбоях.
+package errtrack
+
+import (
+	"context"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/YumeNoTenshi/platypus/internal/alerting"
+)
+
+// Recorder принимает исход очередного запуска подсистемы; nil - успех
+type Recorder interface {
+	Record(subsystem string, err error)
+}
+
+// Rule задает, какой сбой считается затяжным: не меньше Consecutive ошибок
+// подряд на протяжении не меньше For
+type Rule struct {
+	Consecutive int           `json:"consecutive"`
+	For         time.Duration `json:"for"`
+}
+
+type Config struct {
+	Default     Rule            // Правило для подсистем без своего
+	Rules       map[string]Rule // Имя подсистемы -> правило
+	LogInterval time.Duration   // Повторные ошибки подсистемы пишутся в журнал не чаще
+}
+
+// SubsystemStatus - счетчики ошибок подсистемы
+type SubsystemStatus struct {
+	Subsystem     string     `json:"subsystem"`
+	Errors        int        `json:"errors"`
+	Successes     int        `json:"successes"`
+	Consecutive   int        `json:"consecutive_errors"`
+	FailingSince  *time.Time `json:"failing_since,omitempty"`
+	LastError     string     `json:"last_error,omitempty"`
+	LastErrorAt   *time.Time `json:"last_error_at,omitempty"`
+	LastSuccessAt *time.Time `json:"last_success_at,omitempty"`
+	Alerting      bool       `json:"alerting"` // Затяжной сбой, оповещение отправлено
+}
+
+type state struct {
+	status   SubsystemStatus
+	loggedAt time.Time
+	unlogged int // Ошибки, не попавшие в журнал из-за LogInterval
+}
+
+type Tracker struct {
+	config Config
+
+	mu         sync.Mutex
+	subsystems map[string]*state
+	alerts     *alerting.Dispatcher
+}
+
+func New(config Config) *Tracker {
+	if config.Default.Consecutive <= 0 {
+		config.Default.Consecutive = 3
+	}
+	return &Tracker{
+		config:     config,
+		subsystems: make(map[string]*state),
+	}
+}
+
+// SetAlerts включает оповещения о затяжных сбоях и восстановлении
+func (t *Tracker) SetAlerts(alerts *alerting.Dispatcher) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.alerts = alerts
+}
+
+// Record учитывает исход запуска подсистемы
+func (t *Tracker) Record(subsystem string, err error) {
+	now := time.Now()
+
+	t.mu.Lock()
+	s, exists := t.subsystems[subsystem]
+	if !exists {
+		s = &state{status: SubsystemStatus{Subsystem: subsystem}}
+		t.subsystems[subsystem] = s
+	}
+
+	var alert *alerting.Alert
+	if err == nil {
+		s.status.Successes++
+		s.status.LastSuccessAt = &now
+		if s.status.Alerting {
+			alert = t.recovered(s)
+		}
+		s.status.Consecutive = 0
+		s.status.FailingSince = nil
+		s.status.Alerting = false
+		s.unlogged = 0
+	} else {
+		s.status.Errors++
+		s.status.Consecutive++
+		s.status.LastError = err.Error()
+		s.status.LastErrorAt = &now
+		if s.status.FailingSince == nil {
+			s.status.FailingSince = &now
+		}
+		t.log(s, now)
+
+		rule := t.rule(subsystem)
+		if !s.status.Alerting && s.status.Consecutive >= rule.Consecutive && now.Sub(*s.status.FailingSince) >= rule.For {
+			s.status.Alerting = true
+			alert = t.sustained(s)
+		}
+	}
+	alerts := t.alerts
+	t.mu.Unlock()
+
+	if alert != nil && alerts != nil {
+		alerts.Notify(context.Background(), *alert)
+	}
+}
+
+// Status возвращает счетчики всех подсистем, сначала сбоящие
+func (t *Tracker) Status() []SubsystemStatus {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	statuses := make([]SubsystemStatus, 0, len(t.subsystems))
+	for _, s := range t.subsystems {
+		statuses = append(statuses, s.status)
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		if (statuses[i].Consecutive > 0) != (statuses[j].Consecutive > 0) {
+			return statuses[i].Consecutive > 0
+		}
+		return statuses[i].Subsystem < statuses[j].Subsystem
+	})
+	return statuses
+}
+
+func (t *Tracker) rule(subsystem string) Rule {
+	if rule, exists := t.config.Rules[subsystem]; exists {
+		return rule
+	}
+	return t.config.Default
+}
+
+// log пишет первую ошибку серии сразу, а повторы - не чаще LogInterval,
+// с числом пропущенных. Вызывается под блокировкой.
+func (t *Tracker) log(s *state, now time.Time) {
+	if s.status.Consecutive > 1 && now.Sub(s.loggedAt) < t.config.LogInterval {
+		s.unlogged++
+		return
+	}
+	if s.unlogged > 0 {
+		log.Printf("Ошибка подсистемы %s (еще %d с прошлой записи): %s", s.status.Subsystem, s.unlogged, s.status.LastError)
+	} else {
+		log.Printf("Ошибка подсистемы %s: %s", s.status.Subsystem, s.status.LastError)
+	}
+	s.loggedAt = now
+	s.unlogged = 0
+}
+
+func (t *Tracker) sustained(s *state) *alerting.Alert {
+	return &alerting.Alert{
+		Key:      "errors/" + s.status.Subsystem,
+		Source:   "errtrack",
+		Severity: alerting.SeverityCritical,
+		Title:    "Subsystem failing repeatedly: " + s.status.Subsystem,
+		Message:  s.status.LastError,
+		Labels:   map[string]string{"subsystem": s.status.Subsystem},
+	}
+}
+
+func (t *Tracker) recovered(s *state) *alerting.Alert {
+	return &alerting.Alert{
+		Key:      "errors/" + s.status.Subsystem + "/recovered",
+		Source:   "errtrack",
+		Severity: alerting.SeverityInfo,
+		Title:    "Subsystem recovered: " + s.status.Subsystem,
+		Message:  "last error: " + s.status.LastError,
+		Labels:   map[string]string{"subsystem": s.status.Subsystem},
+	}
+}
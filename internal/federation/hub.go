@@ -0,0 +1,120 @@
+package federation
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+type HubConfig struct {
+	StaleAfter time.Duration // Через сколько без отчетов площадка считается недоступной
+}
+
+// Hub принимает отчеты площадок на центральном инстансе
+type Hub struct {
+	config  HubConfig
+	mu      sync.RWMutex
+	reports map[string]SiteReport
+	policy  Policy
+}
+
+func NewHub(config HubConfig, policy Policy) *Hub {
+	if policy.Version == 0 {
+		policy.Version = 1
+	}
+	if policy.UpdatedAt.IsZero() {
+		policy.UpdatedAt = time.Now()
+	}
+
+	return &Hub{
+		config:  config,
+		reports: make(map[string]SiteReport),
+		policy:  policy,
+	}
+}
+
+// Report сохраняет отчет площадки и возвращает актуальную политику
+func (h *Hub) Report(report SiteReport) (Policy, error) {
+	if report.SiteID == "" {
+		return Policy{}, fmt.Errorf("site_id is required")
+	}
+	if report.Timestamp.IsZero() {
+		report.Timestamp = time.Now()
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.reports[report.SiteID] = report
+	return h.policy, nil
+}
+
+func (h *Hub) Policy() Policy {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.policy
+}
+
+// SetPolicy заменяет политику федерации; площадки получат ее со следующим отчетом
+func (h *Hub) SetPolicy(policy Policy) Policy {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	policy.Version = h.policy.Version + 1
+	policy.UpdatedAt = time.Now()
+	h.policy = policy
+	return policy
+}
+
+// FleetView объединяет последние отчеты всех площадок
+func (h *Hub) FleetView() FleetView {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	var view FleetView
+	var scoreSum float64
+
+	for _, report := range h.reports {
+		view.Sites = append(view.Sites, h.siteStatus(report))
+		view.ServerCount += len(report.Servers)
+		view.TotalPower += report.TotalPower
+		view.TotalCarbon += report.TotalCarbon
+		scoreSum += report.AvgEcoScore * float64(len(report.Servers))
+	}
+
+	if view.ServerCount > 0 {
+		view.AvgEcoScore = scoreSum / float64(view.ServerCount)
+	}
+
+	sort.Slice(view.Sites, func(i, j int) bool {
+		return view.Sites[i].SiteID < view.Sites[j].SiteID
+	})
+
+	return view
+}
+
+// Site возвращает последний полный отчет площадки
+func (h *Hub) Site(siteID string) (SiteReport, error) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	report, exists := h.reports[siteID]
+	if !exists {
+		return SiteReport{}, fmt.Errorf("site not found: %s", siteID)
+	}
+	return report, nil
+}
+
+func (h *Hub) siteStatus(report SiteReport) SiteStatus {
+	return SiteStatus{
+		SiteID:        report.SiteID,
+		LastReport:    report.Timestamp,
+		Stale:         h.config.StaleAfter > 0 && time.Since(report.Timestamp) > h.config.StaleAfter,
+		PolicyVersion: report.PolicyVersion,
+		ServerCount:   len(report.Servers),
+		TotalPower:    report.TotalPower,
+		TotalCarbon:   report.TotalCarbon,
+		AvgEcoScore:   report.AvgEcoScore,
+	}
+}
@@ -0,0 +1,134 @@
+package federation
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/YumeNoTenshi/platypus/internal/metrics"
+	"github.com/YumeNoTenshi/platypus/internal/scheduler"
+)
+
+type ReporterConfig struct {
+	SiteID         string
+	CentralURL     string        // Базовый адрес центрального инстанса, например https://platypus.example.com
+	APIKey         string
+	ReportInterval time.Duration
+	Timeout        time.Duration
+}
+
+// Reporter работает на edge-инстансе: отправляет агрегаты площадки
+// центральному инстансу и применяет полученную политику
+type Reporter struct {
+	config        ReporterConfig
+	collector     *metrics.Collector
+	analyzer      *metrics.Analyzer
+	client        *http.Client
+	onPolicy      func(Policy)
+	policyVersion int64
+}
+
+// NewReporter создает Reporter; onPolicy вызывается при получении новой версии политики
+func NewReporter(config ReporterConfig, collector *metrics.Collector, analyzer *metrics.Analyzer, onPolicy func(Policy)) *Reporter {
+	return &Reporter{
+		config:    config,
+		collector: collector,
+		analyzer:  analyzer,
+		client:    &http.Client{Timeout: config.Timeout},
+		onPolicy:  onPolicy,
+	}
+}
+
+// Jobs возвращает периодическую отправку отчета центральному инстансу
+func (r *Reporter) Jobs() []scheduler.Job {
+	return []scheduler.Job{{
+		Name:     "federation.report",
+		Interval: r.config.ReportInterval,
+		Run:      r.report,
+	}}
+}
+
+func (r *Reporter) report(ctx context.Context) error {
+	body, err := json.Marshal(r.buildReport())
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.config.CentralURL+"/api/v1/federation/reports", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-API-Key", r.config.APIKey)
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("central instance responded with status %d", resp.StatusCode)
+	}
+
+	var payload struct {
+		Data Policy `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
+		return err
+	}
+
+	if payload.Data.Version > r.policyVersion {
+		r.policyVersion = payload.Data.Version
+		if r.onPolicy != nil {
+			r.onPolicy(payload.Data)
+		}
+	}
+
+	return nil
+}
+
+func (r *Reporter) buildReport() SiteReport {
+	report := SiteReport{
+		SiteID:        r.config.SiteID,
+		Timestamp:     time.Now(),
+		PolicyVersion: r.policyVersion,
+	}
+
+	var scoreSum float64
+	for _, serverID := range r.collector.ServerIDs() {
+		data, err := r.collector.GetMetrics(serverID)
+		if err != nil || len(data) == 0 {
+			continue
+		}
+
+		summary := ServerSummary{ServerID: serverID}
+		for _, m := range data {
+			summary.PowerUsage += m.PowerUsage
+			summary.CarbonFootprint += m.CarbonFootprint
+			summary.CPUUsage += m.CPUUsage
+		}
+		n := float64(len(data))
+		summary.PowerUsage /= n
+		summary.CarbonFootprint /= n
+		summary.CPUUsage /= n
+
+		scores := r.analyzer.CalculateEcoScores(serverID, data)
+		summary.EcoScore = scores.Raw
+		summary.NormalizedEcoScore = scores.Normalized
+
+		report.Servers = append(report.Servers, summary)
+		report.TotalPower += summary.PowerUsage
+		report.TotalCarbon += summary.CarbonFootprint
+		scoreSum += summary.EcoScore
+	}
+
+	if len(report.Servers) > 0 {
+		report.AvgEcoScore = scoreSum / float64(len(report.Servers))
+	}
+
+	return report
+}
@@ -0,0 +1,67 @@
+// Package federation реализует режим федерации: edge-инстансы Platypus
+// (по одному на площадку или кластер) отправляют агрегаты центральному
+// инстансу и получают от него политику
+package federation
+
+import "time"
+
+// Mode определяет роль инстанса в федерации
+type Mode string
+
+const (
+	ModeStandalone Mode = "standalone"
+	ModeEdge       Mode = "edge"
+	ModeCentral    Mode = "central"
+)
+
+// ServerSummary содержит агрегированные показатели сервера площадки
+type ServerSummary struct {
+	ServerID           string  `json:"server_id"`
+	PowerUsage         float64 `json:"power_usage"`      // Среднее, Вт
+	CarbonFootprint    float64 `json:"carbon_footprint"` // Среднее, кг CO2
+	CPUUsage           float64 `json:"cpu_usage"`        // Среднее, %
+	EcoScore           float64 `json:"eco_score"`
+	NormalizedEcoScore float64 `json:"normalized_eco_score"`
+}
+
+// SiteReport - отчет edge-инстанса о состоянии площадки
+type SiteReport struct {
+	SiteID        string          `json:"site_id"`
+	Timestamp     time.Time       `json:"timestamp"`
+	PolicyVersion int64           `json:"policy_version"` // Версия политики, примененной на площадке
+	Servers       []ServerSummary `json:"servers"`
+	TotalPower    float64         `json:"total_power"`
+	TotalCarbon   float64         `json:"total_carbon"`
+	AvgEcoScore   float64         `json:"avg_eco_score"`
+}
+
+// Policy - политика, распространяемая центральным инстансом на все площадки
+type Policy struct {
+	Version            int64     `json:"version"`
+	UpdatedAt          time.Time `json:"updated_at"`
+	CPUThresholdHigh   float64   `json:"cpu_threshold_high"`
+	CPUThresholdLow    float64   `json:"cpu_threshold_low"`
+	PowerThresholdHigh float64   `json:"power_threshold_high"`
+	MinPowerSaving     float64   `json:"min_power_saving"`
+}
+
+// SiteStatus - состояние площадки в сводном представлении
+type SiteStatus struct {
+	SiteID        string    `json:"site_id"`
+	LastReport    time.Time `json:"last_report"`
+	Stale         bool      `json:"stale"`
+	PolicyVersion int64     `json:"policy_version"`
+	ServerCount   int       `json:"server_count"`
+	TotalPower    float64   `json:"total_power"`
+	TotalCarbon   float64   `json:"total_carbon"`
+	AvgEcoScore   float64   `json:"avg_eco_score"`
+}
+
+// FleetView - объединенное представление всех площадок федерации
+type FleetView struct {
+	Sites       []SiteStatus `json:"sites"`
+	ServerCount int          `json:"server_count"`
+	TotalPower  float64      `json:"total_power"`
+	TotalCarbon float64      `json:"total_carbon"`
+	AvgEcoScore float64      `json:"avg_eco_score"`
+}
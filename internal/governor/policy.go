@@ -0,0 +1,207 @@
+package governor
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/YumeNoTenshi/platypus/internal/metrics"
+	"github.com/YumeNoTenshi/platypus/internal/scheduler"
+)
+
+// Mode определяет режим CPU governor на хосте
+type Mode string
+
+const (
+	ModePerformance Mode = "performance"
+	ModePowersave   Mode = "powersave"
+)
+
+// Directive содержит указание агенту, какой режим процессора применить на хосте
+type Directive struct {
+	ServerID        string    `json:"server_id"`
+	Governor        Mode      `json:"governor"`
+	MaxFrequencyMHz int       `json:"max_frequency_mhz,omitempty"` // 0 - без ограничения частоты
+	Reason          string    `json:"reason"`
+	IssuedAt        time.Time `json:"issued_at"`
+}
+
+// Report - результат применения указания агентом на хосте
+type Report struct {
+	ServerID        string    `json:"server_id"`
+	Governor        Mode      `json:"governor"`
+	MaxFrequencyMHz int       `json:"max_frequency_mhz,omitempty"`
+	IssuedAt        time.Time `json:"issued_at"` // Время выдачи примененного указания
+	Applied         bool      `json:"applied"`
+	Error           string    `json:"error,omitempty"`
+	ReportedAt      time.Time `json:"reported_at"`
+}
+
+type PolicyConfig struct {
+	IdleCPUThreshold   float64       // Порог загрузки CPU, ниже которого хост считается простаивающим (%)
+	MinIdleWindow      time.Duration // Минимальная длительность простоя для перехода в powersave
+	FrequencyCapMHz    int           // Ограничение частоты в режиме powersave (0 - не ограничивать)
+	EvaluationInterval time.Duration // Интервал пересмотра политики
+	LatencySensitive   []string      // Хосты, для которых режим powersave запрещен
+}
+
+type Manager struct {
+	config     PolicyConfig
+	collector  *metrics.Collector
+	analyzer   *metrics.Analyzer
+	mu         sync.RWMutex
+	directives map[string]Directive
+	reports    map[string]Report // Последний результат применения по хостам
+	excluded   map[string]bool
+	exempt     func(serverID string) bool
+}
+
+func NewManager(config PolicyConfig, collector *metrics.Collector, analyzer *metrics.Analyzer) *Manager {
+	m := &Manager{
+		config:     config,
+		collector:  collector,
+		analyzer:   analyzer,
+		directives: make(map[string]Directive),
+		reports:    make(map[string]Report),
+		excluded:   make(map[string]bool),
+	}
+
+	for _, serverID := range config.LatencySensitive {
+		m.excluded[serverID] = true
+	}
+
+	return m
+}
+
+// Jobs возвращает периодический пересмотр политики
+func (m *Manager) Jobs() []scheduler.Job {
+	return []scheduler.Job{{
+		Name:     "governor.evaluate",
+		Interval: m.config.EvaluationInterval,
+		Run: func(ctx context.Context) error {
+			m.evaluate()
+			return nil
+		},
+	}}
+}
+
+func (m *Manager) evaluate() {
+	for _, serverID := range m.collector.ServerIDs() {
+		directive := m.decide(serverID)
+
+		m.mu.Lock()
+		// Переиздаем указание только при смене режима, чтобы агенты не дергали sysfs зря
+		if current, exists := m.directives[serverID]; !exists ||
+			current.Governor != directive.Governor ||
+			current.MaxFrequencyMHz != directive.MaxFrequencyMHz {
+			m.directives[serverID] = directive
+		}
+		m.mu.Unlock()
+	}
+}
+
+func (m *Manager) decide(serverID string) Directive {
+	directive := Directive{
+		ServerID: serverID,
+		Governor: ModePerformance,
+		IssuedAt: time.Now(),
+	}
+
+	if m.IsLatencySensitive(serverID) {
+		directive.Reason = "host is latency-sensitive"
+		return directive
+	}
+	if m.exempted(serverID) {
+		directive.Reason = "host is exempt from the governor"
+		return directive
+	}
+
+	windows, err := m.analyzer.FindIdleWindows(serverID, m.config.IdleCPUThreshold, m.config.MinIdleWindow)
+	if err != nil || len(windows) == 0 {
+		directive.Reason = "no idle window detected"
+		return directive
+	}
+
+	// Переключаемся в powersave только если хост простаивает прямо сейчас
+	metricsData, err := m.collector.GetFilteredMetrics(serverID)
+	if err != nil || len(metricsData) == 0 {
+		directive.Reason = "no recent metrics"
+		return directive
+	}
+	last := windows[len(windows)-1]
+	if last.End.Unix() != metricsData[len(metricsData)-1].Timestamp {
+		directive.Reason = "host left idle window"
+		return directive
+	}
+
+	directive.Governor = ModePowersave
+	directive.MaxFrequencyMHz = m.config.FrequencyCapMHz
+	directive.Reason = "host idle since " + last.Start.Format(time.RFC3339)
+	return directive
+}
+
+// Directive возвращает текущее указание для хоста. Хостам без данных
+// всегда выдается performance, чтобы не деградировать неизвестную нагрузку.
+func (m *Manager) Directive(serverID string) Directive {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if directive, exists := m.directives[serverID]; exists {
+		return directive
+	}
+	return Directive{
+		ServerID: serverID,
+		Governor: ModePerformance,
+		Reason:   "no policy evaluated yet",
+		IssuedAt: time.Now(),
+	}
+}
+
+// SetReport сохраняет результат применения указания, присланный агентом
+func (m *Manager) SetReport(report Report) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.reports[report.ServerID] = report
+}
+
+// Report возвращает последний результат применения указания на хосте
+func (m *Manager) Report(serverID string) (Report, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	report, exists := m.reports[serverID]
+	return report, exists
+}
+
+// SetLatencySensitive включает или снимает исключение хоста из энергосберегающей политики
+func (m *Manager) SetLatencySensitive(serverID string, sensitive bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if sensitive {
+		m.excluded[serverID] = true
+		delete(m.directives, serverID)
+		return
+	}
+	delete(m.excluded, serverID)
+}
+
+// SetExemptions подключает список хостов, исключенных из политики (например,
+// метаданные инвентаря): им всегда назначается режим performance
+func (m *Manager) SetExemptions(exempt func(serverID string) bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.exempt = exempt
+}
+
+func (m *Manager) exempted(serverID string) bool {
+	m.mu.RLock()
+	exempt := m.exempt
+	m.mu.RUnlock()
+	return exempt != nil && exempt(serverID)
+}
+
+func (m *Manager) IsLatencySensitive(serverID string) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.excluded[serverID]
+}
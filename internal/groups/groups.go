@@ -0,0 +1,237 @@
+// Package groups хранит сохраненные группы серверов: статические списки или
+// динамические селекторы по меткам. Группы используются как цели в API,
+// бюджетах и автомасштабировании вместо перечисления серверов в каждом запросе.
+package groups
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/YumeNoTenshi/platypus/internal/inventory"
+	"github.com/YumeNoTenshi/platypus/internal/metrics"
+	"github.com/YumeNoTenshi/platypus/internal/selector"
+)
+
+type Group struct {
+	ID          string            `json:"id"`
+	Name        string            `json:"name"`
+	Description string            `json:"description,omitempty"`
+	ServerIDs   []string          `json:"server_ids,omitempty"` // Статический состав
+	Selector    selector.Selector `json:"selector,omitempty"`   // Динамический состав, например "environment=prod,team!=infra"
+	CreatedAt   time.Time         `json:"created_at"`
+	UpdatedAt   time.Time         `json:"updated_at"`
+}
+
+type Manager struct {
+	collector *metrics.Collector
+	inventory *inventory.Inventory // Необязателен: без него селекторы видят только server_id
+	mu        sync.RWMutex
+	groups    map[string]*Group
+}
+
+func NewManager(collector *metrics.Collector, inv *inventory.Inventory) *Manager {
+	return &Manager{
+		collector: collector,
+		inventory: inv,
+		groups:    make(map[string]*Group),
+	}
+}
+
+func validate(group Group) error {
+	if group.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if len(group.ServerIDs) > 0 && len(group.Selector) > 0 {
+		return fmt.Errorf("group must have either server_ids or selector, not both")
+	}
+	if len(group.ServerIDs) == 0 && len(group.Selector) == 0 {
+		return fmt.Errorf("server_ids or selector is required")
+	}
+	return nil
+}
+
+func (m *Manager) Create(group Group) (Group, error) {
+	if err := validate(group); err != nil {
+		return Group{}, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.findLocked(group.Name) != nil {
+		return Group{}, fmt.Errorf("group already exists: %s", group.Name)
+	}
+
+	now := time.Now()
+	group.ID = newID()
+	group.CreatedAt = now
+	group.UpdatedAt = now
+	m.groups[group.ID] = &group
+	return group, nil
+}
+
+func (m *Manager) Update(ref string, group Group) (Group, error) {
+	if err := validate(group); err != nil {
+		return Group{}, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	existing := m.findLocked(ref)
+	if existing == nil {
+		return Group{}, fmt.Errorf("group not found: %s", ref)
+	}
+	if other := m.findLocked(group.Name); other != nil && other.ID != existing.ID {
+		return Group{}, fmt.Errorf("group already exists: %s", group.Name)
+	}
+
+	group.ID = existing.ID
+	group.CreatedAt = existing.CreatedAt
+	group.UpdatedAt = time.Now()
+	m.groups[group.ID] = &group
+	return group, nil
+}
+
+// Get ищет группу по ID или имени
+func (m *Manager) Get(ref string) (Group, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	group := m.findLocked(ref)
+	if group == nil {
+		return Group{}, fmt.Errorf("group not found: %s", ref)
+	}
+	return *group, nil
+}
+
+func (m *Manager) List() []Group {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	groups := make([]Group, 0, len(m.groups))
+	for _, group := range m.groups {
+		groups = append(groups, *group)
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		return groups[i].Name < groups[j].Name
+	})
+	return groups
+}
+
+func (m *Manager) Delete(ref string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	group := m.findLocked(ref)
+	if group == nil {
+		return fmt.Errorf("group not found: %s", ref)
+	}
+	delete(m.groups, group.ID)
+	return nil
+}
+
+// Members возвращает текущий состав группы. Состав динамической группы
+// вычисляется при каждом вызове по известным серверам и их меткам.
+func (m *Manager) Members(ref string) ([]string, error) {
+	group, err := m.Get(ref)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(group.ServerIDs) > 0 {
+		return append([]string(nil), group.ServerIDs...), nil
+	}
+
+	return m.Select(group.Selector), nil
+}
+
+// Select возвращает известные серверы, метки которых подходят под селектор
+func (m *Manager) Select(sel selector.Selector) []string {
+	var members []string
+	for _, serverID := range m.knownServers() {
+		if sel.Matches(m.serverLabels(serverID)) {
+			members = append(members, serverID)
+		}
+	}
+	return members
+}
+
+// Contains сообщает, входит ли сервер в группу
+func (m *Manager) Contains(ref, serverID string) bool {
+	members, err := m.Members(ref)
+	if err != nil {
+		return false
+	}
+	for _, member := range members {
+		if member == serverID {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *Manager) findLocked(ref string) *Group {
+	if group, exists := m.groups[ref]; exists {
+		return group
+	}
+	for _, group := range m.groups {
+		if group.Name == ref {
+			return group
+		}
+	}
+	return nil
+}
+
+// knownServers объединяет серверы из инвентаря и серверы, присылающие метрики
+func (m *Manager) knownServers() []string {
+	seen := make(map[string]bool)
+	for _, serverID := range m.collector.ServerIDs() {
+		seen[serverID] = true
+	}
+	if m.inventory != nil {
+		for _, server := range m.inventory.Servers() {
+			seen[server.ID] = true
+		}
+	}
+
+	servers := make([]string, 0, len(seen))
+	for serverID := range seen {
+		servers = append(servers, serverID)
+	}
+	sort.Strings(servers)
+	return servers
+}
+
+// serverLabels собирает метки, доступные селекторам: облачные теги, выведенные
+// метки (team, environment, cost_center) и атрибуты сервера
+func (m *Manager) serverLabels(serverID string) map[string]string {
+	labels := map[string]string{"server_id": serverID}
+	if m.inventory == nil {
+		return labels
+	}
+
+	server, exists := m.inventory.Server(serverID)
+	if exists {
+		for key, value := range server.Tags {
+			labels[key] = value
+		}
+		labels["provider"] = server.Provider
+		labels["region"] = server.Region
+		labels["instance_type"] = server.InstanceType
+	}
+	for key, value := range m.inventory.ServerLabels(serverID).Values {
+		labels[key] = value
+	}
+	return labels
+}
+
+func newID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,161 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: internal/grpc/ingestpb/ingest.proto
+
+package ingestpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	MetricIngest_StreamMetrics_FullMethodName = "/platypus.ingest.v1.MetricIngest/StreamMetrics"
+	MetricIngest_PushMetrics_FullMethodName   = "/platypus.ingest.v1.MetricIngest/PushMetrics"
+)
+
+// MetricIngestClient is the client API for MetricIngest service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// MetricIngest принимает метрики от агентов на узлах
+type MetricIngestClient interface {
+	// StreamMetrics принимает поток пакетов и отвечает итогом после закрытия потока
+	StreamMetrics(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[MetricBatch, IngestResponse], error)
+	// PushMetrics принимает один пакет
+	PushMetrics(ctx context.Context, in *MetricBatch, opts ...grpc.CallOption) (*IngestResponse, error)
+}
+
+type metricIngestClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewMetricIngestClient(cc grpc.ClientConnInterface) MetricIngestClient {
+	return &metricIngestClient{cc}
+}
+
+func (c *metricIngestClient) StreamMetrics(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[MetricBatch, IngestResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &MetricIngest_ServiceDesc.Streams[0], MetricIngest_StreamMetrics_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[MetricBatch, IngestResponse]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type MetricIngest_StreamMetricsClient = grpc.ClientStreamingClient[MetricBatch, IngestResponse]
+
+func (c *metricIngestClient) PushMetrics(ctx context.Context, in *MetricBatch, opts ...grpc.CallOption) (*IngestResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(IngestResponse)
+	err := c.cc.Invoke(ctx, MetricIngest_PushMetrics_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// MetricIngestServer is the server API for MetricIngest service.
+// All implementations must embed UnimplementedMetricIngestServer
+// for forward compatibility.
+//
+// MetricIngest принимает метрики от агентов на узлах
+type MetricIngestServer interface {
+	// StreamMetrics принимает поток пакетов и отвечает итогом после закрытия потока
+	StreamMetrics(grpc.ClientStreamingServer[MetricBatch, IngestResponse]) error
+	// PushMetrics принимает один пакет
+	PushMetrics(context.Context, *MetricBatch) (*IngestResponse, error)
+	mustEmbedUnimplementedMetricIngestServer()
+}
+
+// UnimplementedMetricIngestServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedMetricIngestServer struct{}
+
+func (UnimplementedMetricIngestServer) StreamMetrics(grpc.ClientStreamingServer[MetricBatch, IngestResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method StreamMetrics not implemented")
+}
+func (UnimplementedMetricIngestServer) PushMetrics(context.Context, *MetricBatch) (*IngestResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method PushMetrics not implemented")
+}
+func (UnimplementedMetricIngestServer) mustEmbedUnimplementedMetricIngestServer() {}
+func (UnimplementedMetricIngestServer) testEmbeddedByValue()                      {}
+
+// UnsafeMetricIngestServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to MetricIngestServer will
+// result in compilation errors.
+type UnsafeMetricIngestServer interface {
+	mustEmbedUnimplementedMetricIngestServer()
+}
+
+func RegisterMetricIngestServer(s grpc.ServiceRegistrar, srv MetricIngestServer) {
+	// If the following call pancis, it indicates UnimplementedMetricIngestServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&MetricIngest_ServiceDesc, srv)
+}
+
+func _MetricIngest_StreamMetrics_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(MetricIngestServer).StreamMetrics(&grpc.GenericServerStream[MetricBatch, IngestResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type MetricIngest_StreamMetricsServer = grpc.ClientStreamingServer[MetricBatch, IngestResponse]
+
+func _MetricIngest_PushMetrics_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(MetricBatch)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MetricIngestServer).PushMetrics(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: MetricIngest_PushMetrics_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MetricIngestServer).PushMetrics(ctx, req.(*MetricBatch))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// MetricIngest_ServiceDesc is the grpc.ServiceDesc for MetricIngest service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var MetricIngest_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "platypus.ingest.v1.MetricIngest",
+	HandlerType: (*MetricIngestServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "PushMetrics",
+			Handler:    _MetricIngest_PushMetrics_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamMetrics",
+			Handler:       _MetricIngest_StreamMetrics_Handler,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "internal/grpc/ingestpb/ingest.proto",
+}
@@ -0,0 +1,221 @@
+// Package grpc принимает метрики агентов по gRPC. Поток пакетов дешевле,
+// чем JSON-запрос POST /metrics на каждую точку: одно соединение, бинарный
+// формат и точки с меткой времени агента.
+package grpc
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative ingestpb/ingest.proto
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"time"
+
+	gogrpc "google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/YumeNoTenshi/platypus/internal/api"
+	"github.com/YumeNoTenshi/platypus/internal/grpc/ingestpb"
+	"github.com/YumeNoTenshi/platypus/internal/metrics"
+	"github.com/YumeNoTenshi/platypus/internal/models"
+	"github.com/YumeNoTenshi/platypus/internal/supervisor"
+)
+
+// Server реализует сервис MetricIngest поверх сборщика метрик
+type Server struct {
+	ingestpb.UnimplementedMetricIngestServer
+
+	collector *metrics.Collector
+	auth      api.AuthProvider                        // nil - без аутентификации
+	labels    func(serverID string) map[string]string // Метки сервера для селекторов областей ключей
+}
+
+func NewServer(collector *metrics.Collector, auth api.AuthProvider, labels func(serverID string) map[string]string) *Server {
+	return &Server{collector: collector, auth: auth, labels: labels}
+}
+
+// Serve принимает соединения на addr до отмены контекста, после чего
+// дожидается завершения начатых вызовов
+func (s *Server) Serve(ctx context.Context, addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	server := gogrpc.NewServer(
+		gogrpc.UnaryInterceptor(s.unaryAuth),
+		gogrpc.StreamInterceptor(s.streamAuth),
+	)
+	ingestpb.RegisterMetricIngestServer(server, s)
+
+	go func() {
+		<-ctx.Done()
+		server.GracefulStop()
+	}()
+
+	log.Printf("Прием метрик по gRPC на %s", addr)
+	return server.Serve(listener)
+}
+
+func (s *Server) PushMetrics(ctx context.Context, batch *ingestpb.MetricBatch) (*ingestpb.IngestResponse, error) {
+	response := &ingestpb.IngestResponse{}
+	if err := s.ingest(ctx, batch, response); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+func (s *Server) StreamMetrics(stream gogrpc.ClientStreamingServer[ingestpb.MetricBatch, ingestpb.IngestResponse]) error {
+	response := &ingestpb.IngestResponse{}
+	for {
+		batch, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return stream.SendAndClose(response)
+		}
+		if err != nil {
+			return err
+		}
+		if err := s.ingest(stream.Context(), batch, response); err != nil {
+			return err
+		}
+	}
+}
+
+// ingest передает точки пакета сборщику. Отклоненные точки не прерывают
+// пакет, а попадают в ответ с причиной.
+func (s *Server) ingest(ctx context.Context, batch *ingestpb.MetricBatch, response *ingestpb.IngestResponse) error {
+	serverID := batch.GetServerId()
+	if serverID == "" {
+		return status.Error(codes.InvalidArgument, "server_id is required")
+	}
+	principal, _ := api.PrincipalFromContext(ctx)
+	if principal != nil {
+		if err := principal.Scope.Allows(serverID, true, s.labels); err != nil {
+			return status.Error(codes.PermissionDenied, err.Error())
+		}
+	}
+	source := batch.GetSource()
+	if source == "" {
+		source = serverID
+	}
+
+	now := time.Now().Unix()
+	for _, point := range batch.GetPoints() {
+		data := metricData(serverID, point)
+		data.TenantID = principal.TenantID()
+		if data.Timestamp == 0 {
+			data.Timestamp = now
+		}
+
+		var err error
+		if data.ContainerID != "" {
+			err = s.collector.CollectContainerMetrics(source, data.ContainerID, data)
+		} else {
+			err = s.collector.CollectMetricsFrom(source, serverID, data)
+		}
+		if err != nil {
+			response.Rejected = append(response.Rejected, &ingestpb.Rejection{
+				Timestamp:   data.Timestamp,
+				ContainerId: data.ContainerID,
+				Message:     err.Error(),
+			})
+			continue
+		}
+		response.Accepted++
+	}
+	return nil
+}
+
+func metricData(serverID string, point *ingestpb.MetricPoint) models.MetricData {
+	return models.MetricData{
+		ServerID:             serverID,
+		ContainerID:          point.GetContainerId(),
+		Timestamp:            point.GetTimestamp(),
+		PowerUsage:           point.GetPowerUsage(),
+		CarbonFootprint:      point.GetCarbonFootprint(),
+		CPUUsage:             point.GetCpuUsage(),
+		MemoryUsage:          point.GetMemoryUsage(),
+		GPUUsage:             point.GetGpuUsage(),
+		GPUPowerUsage:        point.GetGpuPowerUsage(),
+		NetworkRxBytes:       point.GetNetworkRxBytes(),
+		NetworkTxBytes:       point.GetNetworkTxBytes(),
+		DiskReadBytesPerSec:  point.GetDiskReadBps(),
+		DiskWriteBytesPerSec: point.GetDiskWriteBps(),
+		StorageUsedBytes:     point.GetStorageUsedBytes(),
+		InletTemperature:     point.GetInletTempC(),
+		EnergyCounter:        point.GetEnergyCounter(),
+	}
+}
+
+func (s *Server) unaryAuth(ctx context.Context, req interface{}, info *gogrpc.UnaryServerInfo, handler gogrpc.UnaryHandler) (interface{}, error) {
+	ctx, err := s.authenticate(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var response interface{}
+	err = supervisor.Call(func() (err error) {
+		response, err = handler(ctx, req)
+		return err
+	})
+	return response, contain(info.FullMethod, err)
+}
+
+func (s *Server) streamAuth(srv interface{}, stream gogrpc.ServerStream, info *gogrpc.StreamServerInfo, handler gogrpc.StreamHandler) error {
+	ctx, err := s.authenticate(stream.Context())
+	if err != nil {
+		return err
+	}
+	err = supervisor.Call(func() error {
+		return handler(srv, &authenticatedStream{ServerStream: stream, ctx: ctx})
+	})
+	return contain(info.FullMethod, err)
+}
+
+// contain превращает панику обработчика в ошибку вызова: gRPC, в отличие
+// от net/http, не перехватывает паники, и одна точка ломала бы весь сервер
+func contain(method string, err error) error {
+	var panicErr *supervisor.PanicError
+	if !errors.As(err, &panicErr) {
+		return err
+	}
+	log.Printf("Паника в обработчике gRPC %s: %v\n%s", method, panicErr.Value, panicErr.Stack)
+	return status.Error(codes.Internal, "internal error")
+}
+
+// authenticatedStream подменяет контекст потока контекстом с клиентом
+type authenticatedStream struct {
+	gogrpc.ServerStream
+	ctx context.Context
+}
+
+func (s *authenticatedStream) Context() context.Context {
+	return s.ctx
+}
+
+// authenticate проверяет учетные данные тем же провайдером, что и HTTP API:
+// метаданные вызова (например, x-api-key) передаются ему как заголовки.
+// Возвращает контекст с аутентифицированным клиентом.
+func (s *Server) authenticate(ctx context.Context) (context.Context, error) {
+	if s.auth == nil {
+		return ctx, nil
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	r := (&http.Request{Header: make(http.Header)}).WithContext(ctx)
+	for key, values := range md {
+		for _, value := range values {
+			r.Header.Add(key, value)
+		}
+	}
+
+	principal, err := s.auth.ValidateRequest(r)
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+	return api.ContextWithPrincipal(ctx, principal), nil
+}
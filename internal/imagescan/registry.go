@@ -0,0 +1,243 @@
+package imagescan
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const (
+	dockerHubRegistry = "registry-1.docker.io"
+	// dockerHubRealm - сервер токенов Docker Hub; учетные данные Docker Hub
+	// передаются ему без явной настройки
+	dockerHubRealm = "auth.docker.io"
+
+	mediaTypeDockerManifest = "application/vnd.docker.distribution.manifest.v2+json"
+	mediaTypeOCIManifest    = "application/vnd.oci.image.manifest.v1+json"
+)
+
+// Credentials - учетные данные для приватного реестра. Серверу токенов они
+// передаются, только если он на хосте самого реестра или в RealmHosts.
+type Credentials struct {
+	Username   string
+	Password   string
+	RealmHosts []string // Хосты выдачи токенов реестра, например auth.example.com
+}
+
+type reference struct {
+	registry   string
+	repository string
+	tag        string
+}
+
+// parseReference разбирает ссылку на образ вида [registry/]repository[:tag|@digest]
+func parseReference(image string) reference {
+	ref := reference{registry: dockerHubRegistry, tag: "latest"}
+
+	name := image
+	if i := strings.Index(name, "@"); i >= 0 {
+		ref.tag = name[i+1:]
+		name = name[:i]
+	} else if i := strings.LastIndex(name, ":"); i > strings.LastIndex(name, "/") {
+		ref.tag = name[i+1:]
+		name = name[:i]
+	}
+
+	parts := strings.SplitN(name, "/", 2)
+	if len(parts) == 2 && (strings.ContainsAny(parts[0], ".:") || parts[0] == "localhost") {
+		ref.registry = normalizeRegistry(parts[0])
+		name = parts[1]
+	}
+	if ref.registry == dockerHubRegistry && !strings.Contains(name, "/") {
+		name = "library/" + name
+	}
+	ref.repository = name
+
+	return ref
+}
+
+// normalizeRegistry приводит имя реестра к хосту API: docker.io и
+// index.docker.io - псевдонимы Docker Hub
+func normalizeRegistry(registry string) string {
+	registry = strings.ToLower(registry)
+	if registry == "docker.io" || registry == "index.docker.io" {
+		return dockerHubRegistry
+	}
+	return registry
+}
+
+type manifest struct {
+	MediaType string `json:"mediaType"`
+	Config    struct {
+		Digest string `json:"digest"`
+		Size   int64  `json:"size"`
+	} `json:"config"`
+	Layers []struct {
+		Digest string `json:"digest"`
+		Size   int64  `json:"size"`
+	} `json:"layers"`
+}
+
+type imageConfig struct {
+	Config struct {
+		Labels map[string]string `json:"Labels"`
+	} `json:"config"`
+	History []struct {
+		CreatedBy  string `json:"created_by"`
+		EmptyLayer bool   `json:"empty_layer"`
+	} `json:"history"`
+}
+
+// registryClient - минимальный клиент Docker Registry HTTP API v2
+type registryClient struct {
+	http        *http.Client
+	credentials map[string]Credentials // registry -> учетные данные
+}
+
+func (c *registryClient) fetchManifest(ctx context.Context, ref reference) (*manifest, error) {
+	path := fmt.Sprintf("/v2/%s/manifests/%s", ref.repository, ref.tag)
+	body, err := c.get(ctx, ref, path, mediaTypeDockerManifest+", "+mediaTypeOCIManifest)
+	if err != nil {
+		return nil, err
+	}
+
+	var m manifest
+	if err := json.Unmarshal(body, &m); err != nil {
+		return nil, err
+	}
+	if len(m.Layers) == 0 {
+		return nil, fmt.Errorf("manifest for %s has no layers (multi-arch index is not supported)", ref.repository)
+	}
+	return &m, nil
+}
+
+func (c *registryClient) fetchConfig(ctx context.Context, ref reference, digest string) (*imageConfig, error) {
+	body, err := c.get(ctx, ref, fmt.Sprintf("/v2/%s/blobs/%s", ref.repository, digest), "")
+	if err != nil {
+		return nil, err
+	}
+
+	var cfg imageConfig
+	if err := json.Unmarshal(body, &cfg); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+func (c *registryClient) get(ctx context.Context, ref reference, path, accept string) ([]byte, error) {
+	resp, err := c.do(ctx, ref, path, accept, "")
+	if err != nil {
+		return nil, err
+	}
+
+	// Реестры с токен-аутентификацией (Docker Hub, GHCR) отвечают 401 с адресом выдачи токена
+	if resp.StatusCode == http.StatusUnauthorized {
+		challenge := resp.Header.Get("WWW-Authenticate")
+		resp.Body.Close()
+
+		token, err := c.fetchToken(ctx, ref, challenge)
+		if err != nil {
+			return nil, err
+		}
+		resp, err = c.do(ctx, ref, path, accept, token)
+		if err != nil {
+			return nil, err
+		}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("registry %s responded with status %d for %s", ref.registry, resp.StatusCode, path)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+func (c *registryClient) do(ctx context.Context, ref reference, path, accept, token string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "https://"+ref.registry+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	} else if creds, ok := c.credentials[ref.registry]; ok {
+		req.SetBasicAuth(creds.Username, creds.Password)
+	}
+	return c.http.Do(req)
+}
+
+func (c *registryClient) fetchToken(ctx context.Context, ref reference, challenge string) (string, error) {
+	if !strings.HasPrefix(challenge, "Bearer ") {
+		return "", fmt.Errorf("unsupported auth challenge from %s", ref.registry)
+	}
+
+	params := map[string]string{}
+	for _, part := range strings.Split(strings.TrimPrefix(challenge, "Bearer "), ",") {
+		key, value, found := strings.Cut(strings.TrimSpace(part), "=")
+		if found {
+			params[key] = strings.Trim(value, `"`)
+		}
+	}
+
+	// Адрес выдачи токена задает реестр; учетные данные по нему уходят,
+	// только если этот хост доверен для реестра
+	realm, err := url.Parse(params["realm"])
+	if err != nil || realm.Scheme != "https" || realm.Host == "" {
+		return "", fmt.Errorf("invalid token realm %q from %s", params["realm"], ref.registry)
+	}
+	query := realm.Query()
+	query.Set("service", params["service"])
+	query.Set("scope", fmt.Sprintf("repository:%s:pull", ref.repository))
+	realm.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, realm.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	if creds, ok := c.credentials[ref.registry]; ok && trustedRealm(ref.registry, realm.Host, creds) {
+		req.SetBasicAuth(creds.Username, creds.Password)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("token endpoint responded with status %d", resp.StatusCode)
+	}
+
+	var payload struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
+		return "", err
+	}
+	if payload.Token != "" {
+		return payload.Token, nil
+	}
+	return payload.AccessToken, nil
+}
+
+// trustedRealm проверяет, можно ли передать учетные данные реестра серверу
+// токенов host
+func trustedRealm(registry, host string, creds Credentials) bool {
+	host = strings.ToLower(host)
+	if host == registry || registry == dockerHubRegistry && host == dockerHubRealm {
+		return true
+	}
+	for _, trusted := range creds.RealmHosts {
+		if strings.EqualFold(trusted, host) {
+			return true
+		}
+	}
+	return false
+}
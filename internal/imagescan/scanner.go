@@ -0,0 +1,242 @@
+// Package imagescan анализирует контейнерные образы через API реестров:
+// размер, базовый образ и раздутые слои, и оценивает энергию на их доставку
+package imagescan
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/YumeNoTenshi/platypus/internal/scheduler"
+)
+
+// transferKWhPerGB - оценка энергоемкости передачи данных по сети (кВт*ч/ГБ)
+const transferKWhPerGB = 0.06
+
+type ScannerConfig struct {
+	ScanInterval      time.Duration
+	Timeout           time.Duration
+	LargeImageBytes   int64   // Порог "тяжелого" образа
+	LargeLayerBytes   int64   // Порог раздутого слоя
+	MaxLayers         int     // Рекомендуемое максимальное число слоев
+	DeploymentsPerDay float64 // Ожидаемое число развертываний образа в сутки
+	// Registries - реестры, к которым сканер может обращаться; пусто - только
+	// Docker Hub. Образы из других реестров не сканируются: ссылку на образ
+	// присылают клиенты API и метки контейнеров, и без списка сервер ходил бы
+	// по любому адресу.
+	Registries  []string
+	Credentials map[string]Credentials // Реестр -> учетные данные
+}
+
+// Report - результат сканирования образа
+type Report struct {
+	Image                 string    `json:"image"`
+	SizeBytes             int64     `json:"size_bytes"`
+	LayerCount            int       `json:"layer_count"`
+	LargestLayerBytes     int64     `json:"largest_layer_bytes"`
+	BaseImage             string    `json:"base_image,omitempty"`
+	Findings              []string  `json:"findings"`
+	DeploymentEnergyWh    float64   `json:"deployment_energy_wh"`     // Энергия на одну доставку образа
+	DailyTransferEnergyWh float64   `json:"daily_transfer_energy_wh"` // С учетом частоты развертываний
+	ScannedAt             time.Time `json:"scanned_at"`
+	Error                 string    `json:"error,omitempty"`
+}
+
+type Scanner struct {
+	config     ScannerConfig
+	registries map[string]bool
+	client     *registryClient
+	mu         sync.RWMutex
+	tracked    map[string]bool
+	reports    map[string]Report
+}
+
+func NewScanner(config ScannerConfig) *Scanner {
+	registries := map[string]bool{dockerHubRegistry: true}
+	if len(config.Registries) > 0 {
+		registries = make(map[string]bool, len(config.Registries))
+		for _, registry := range config.Registries {
+			registries[normalizeRegistry(registry)] = true
+		}
+	}
+	credentials := make(map[string]Credentials, len(config.Credentials))
+	for registry, creds := range config.Credentials {
+		credentials[normalizeRegistry(registry)] = creds
+	}
+
+	return &Scanner{
+		config:     config,
+		registries: registries,
+		client: &registryClient{
+			http:        &http.Client{Timeout: config.Timeout},
+			credentials: credentials,
+		},
+		tracked: make(map[string]bool),
+		reports: make(map[string]Report),
+	}
+}
+
+// CheckRegistry возвращает ошибку, если реестр образа не входит в
+// разрешенные
+func (s *Scanner) CheckRegistry(image string) error {
+	if registry := parseReference(image).registry; !s.registries[registry] {
+		return fmt.Errorf("registry %s is not allowed for image scanning", registry)
+	}
+	return nil
+}
+
+// Track добавляет образ в список периодически сканируемых. Образы из
+// неразрешенных реестров не отслеживаются.
+func (s *Scanner) Track(image string) {
+	if image == "" || s.CheckRegistry(image) != nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tracked[image] = true
+}
+
+// Jobs возвращает периодическое пересканирование отслеживаемых образов
+func (s *Scanner) Jobs() []scheduler.Job {
+	return []scheduler.Job{{
+		Name:     "imagescan.scan",
+		Interval: s.config.ScanInterval,
+		Run:      s.scanTracked,
+	}}
+}
+
+func (s *Scanner) scanTracked(ctx context.Context) error {
+	s.mu.RLock()
+	images := make([]string, 0, len(s.tracked))
+	for image := range s.tracked {
+		images = append(images, image)
+	}
+	s.mu.RUnlock()
+
+	for _, image := range images {
+		s.Scan(ctx, image)
+	}
+	return nil
+}
+
+// Scan сканирует образ и сохраняет отчет. Ошибка реестра сохраняется в отчете,
+// чтобы недоступный реестр был виден в API. Образ из неразрешенного реестра
+// не сканируется и не сохраняется.
+func (s *Scanner) Scan(ctx context.Context, image string) Report {
+	if err := s.CheckRegistry(image); err != nil {
+		return Report{Image: image, ScannedAt: time.Now(), Error: err.Error()}
+	}
+
+	report, err := s.scan(ctx, image)
+	if err != nil {
+		report = Report{Image: image, ScannedAt: time.Now(), Error: err.Error()}
+	}
+
+	s.mu.Lock()
+	s.tracked[image] = true
+	s.reports[image] = report
+	s.mu.Unlock()
+
+	return report
+}
+
+func (s *Scanner) scan(ctx context.Context, image string) (Report, error) {
+	ref := parseReference(image)
+
+	m, err := s.client.fetchManifest(ctx, ref)
+	if err != nil {
+		return Report{}, err
+	}
+
+	report := Report{
+		Image:      image,
+		LayerCount: len(m.Layers),
+		ScannedAt:  time.Now(),
+	}
+
+	for _, layer := range m.Layers {
+		report.SizeBytes += layer.Size
+		if layer.Size > report.LargestLayerBytes {
+			report.LargestLayerBytes = layer.Size
+		}
+	}
+
+	if cfg, err := s.client.fetchConfig(ctx, ref, m.Config.Digest); err == nil {
+		report.BaseImage = detectBaseImage(cfg)
+	}
+
+	report.Findings = s.findings(report)
+
+	gb := float64(report.SizeBytes) / (1 << 30)
+	report.DeploymentEnergyWh = gb * transferKWhPerGB * 1000
+	report.DailyTransferEnergyWh = report.DeploymentEnergyWh * s.config.DeploymentsPerDay
+
+	return report, nil
+}
+
+func (s *Scanner) findings(report Report) []string {
+	findings := []string{}
+
+	if s.config.LargeImageBytes > 0 && report.SizeBytes > s.config.LargeImageBytes {
+		findings = append(findings, fmt.Sprintf("image size %d MB exceeds %d MB", report.SizeBytes>>20, s.config.LargeImageBytes>>20))
+	}
+	if s.config.LargeLayerBytes > 0 && report.LargestLayerBytes > s.config.LargeLayerBytes {
+		findings = append(findings, fmt.Sprintf("largest layer is %d MB, consider multi-stage build", report.LargestLayerBytes>>20))
+	}
+	if s.config.MaxLayers > 0 && report.LayerCount > s.config.MaxLayers {
+		findings = append(findings, fmt.Sprintf("%d layers, consider squashing RUN steps", report.LayerCount))
+	}
+	if isHeavyBase(report.BaseImage) {
+		findings = append(findings, fmt.Sprintf("base image %s is a full distribution, consider alpine or distroless", report.BaseImage))
+	}
+
+	return findings
+}
+
+// Report возвращает последний отчет по образу
+func (s *Scanner) Report(image string) (Report, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	report, exists := s.reports[image]
+	return report, exists
+}
+
+func (s *Scanner) Reports() []Report {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	reports := make([]Report, 0, len(s.reports))
+	for _, report := range s.reports {
+		reports = append(reports, report)
+	}
+	return reports
+}
+
+func detectBaseImage(cfg *imageConfig) string {
+	if base := cfg.Config.Labels["org.opencontainers.image.base.name"]; base != "" {
+		return base
+	}
+
+	// Первая запись истории обычно содержит ADD корневой файловой системы базового образа
+	for _, h := range cfg.History {
+		if strings.Contains(h.CreatedBy, "ADD file:") || strings.Contains(h.CreatedBy, "ADD rootfs") {
+			return "unknown (rootfs layer)"
+		}
+	}
+	return ""
+}
+
+func isHeavyBase(base string) bool {
+	base = strings.ToLower(base)
+	for _, heavy := range []string{"ubuntu", "debian", "centos", "fedora", "amazonlinux"} {
+		if strings.Contains(base, heavy) && !strings.Contains(base, "slim") {
+			return true
+		}
+	}
+	return false
+}
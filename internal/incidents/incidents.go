@@ -0,0 +1,546 @@
+// Package incidents объединяет аномалии потребления отдельных серверов в
+// инциденты. Одно событие на стойке (отказ PDU, перегрев) дает десятки
+// аномалий; они группируются по близости во времени, общей топологии
+// (стойка, PDU) и корреляции рядов мощности, и API и уведомления показывают
+// один инцидент с составом, состоянием и хронологией.
+package incidents
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/YumeNoTenshi/platypus/internal/alerting"
+	"github.com/YumeNoTenshi/platypus/internal/metrics"
+	"github.com/YumeNoTenshi/platypus/internal/scheduler"
+)
+
+var (
+	ErrNotFound      = errors.New("incident not found")
+	ErrInvalidStatus = errors.New("invalid incident status")
+)
+
+type Status string
+
+const (
+	StatusOpen         Status = "open"
+	StatusAcknowledged Status = "acknowledged" // Инцидент взят в работу; новые аномалии продолжают присоединяться
+	StatusResolved     Status = "resolved"
+)
+
+type Config struct {
+	EvaluationInterval time.Duration
+	Lookback           time.Duration // За какой период рассматриваются аномалии и ряды для корреляции
+	// Window - насколько аномалия может отстоять от последней аномалии
+	// инцидента, чтобы присоединиться к нему
+	Window         time.Duration
+	MinCorrelation float64       // Порог корреляции рядов мощности для серверов без общей топологии
+	ResolveAfter   time.Duration // Инцидент без новых аномалий закрывается через этот период
+	Retention      time.Duration // Сколько хранить закрытые инциденты
+	// TopologyKeys - метки сервера, задающие общий домен отказа, например rack, pdu
+	TopologyKeys []string
+}
+
+// Member - аномалия сервера в составе инцидента
+type Member struct {
+	ServerID  string    `json:"server_id"`
+	Timestamp time.Time `json:"timestamp"`
+	Value     float64   `json:"value"`
+	Type      string    `json:"type"` // spike или drop
+	Severity  float64   `json:"severity"`
+	Reason    string    `json:"reason"` // Почему аномалия отнесена к инциденту
+}
+
+// Event - запись хронологии инцидента
+type Event struct {
+	Time    time.Time `json:"time"`
+	Type    string    `json:"type"` // opened, anomaly, acknowledged, resolved, reopened
+	Message string    `json:"message"`
+}
+
+type Incident struct {
+	ID         string            `json:"id"`
+	Status     Status            `json:"status"`
+	Title      string            `json:"title"`
+	Severity   alerting.Severity `json:"severity"`
+	Servers    []string          `json:"servers"`
+	Topology   map[string]string `json:"topology,omitempty"` // Общие для всех серверов метки топологии
+	Members    []Member          `json:"members"`
+	StartedAt  time.Time         `json:"started_at"`
+	LastSeen   time.Time         `json:"last_seen"` // Время последней аномалии
+	ResolvedAt *time.Time        `json:"resolved_at,omitempty"`
+	Timeline   []Event           `json:"timeline"`
+}
+
+// Manager периодически собирает аномалии анализатора и группирует их
+type Manager struct {
+	config    Config
+	collector *metrics.Collector
+	analyzer  *metrics.Analyzer
+	alerts    *alerting.Dispatcher // Необязателен
+	topology  func(serverID string) map[string]string
+
+	mu        sync.RWMutex
+	incidents map[string]*Incident
+	seen      map[string]time.Time // Уже учтенные аномалии: сервер/время -> время аномалии
+}
+
+func NewManager(config Config, collector *metrics.Collector, analyzer *metrics.Analyzer, alerts *alerting.Dispatcher) *Manager {
+	if config.EvaluationInterval <= 0 {
+		config.EvaluationInterval = time.Minute
+	}
+	if config.Lookback <= 0 {
+		config.Lookback = time.Hour
+	}
+	if config.Window <= 0 {
+		config.Window = 10 * time.Minute
+	}
+	if config.MinCorrelation <= 0 {
+		config.MinCorrelation = 0.8
+	}
+	if config.ResolveAfter <= 0 {
+		config.ResolveAfter = 30 * time.Minute
+	}
+	if config.Retention <= 0 {
+		config.Retention = 7 * 24 * time.Hour
+	}
+	return &Manager{
+		config:    config,
+		collector: collector,
+		analyzer:  analyzer,
+		alerts:    alerts,
+		topology:  func(string) map[string]string { return nil },
+		incidents: make(map[string]*Incident),
+		seen:      make(map[string]time.Time),
+	}
+}
+
+// SetTopology задает источник меток сервера, из которых берутся
+// Config.TopologyKeys, например метаданные инвентаря
+func (m *Manager) SetTopology(topology func(serverID string) map[string]string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.topology = topology
+}
+
+// Jobs возвращает периодические задачи группировки
+func (m *Manager) Jobs() []scheduler.Job {
+	return []scheduler.Job{{
+		Name:     "incidents.evaluate",
+		Interval: m.config.EvaluationInterval,
+		Run: func(ctx context.Context) error {
+			m.Evaluate(ctx, time.Now())
+			return nil
+		},
+	}}
+}
+
+// Evaluate присоединяет новые аномалии к инцидентам и закрывает затихшие
+func (m *Manager) Evaluate(ctx context.Context, now time.Time) {
+	var fresh []Member
+	for _, serverID := range m.collector.ServerIDs() {
+		analysis, err := m.analyzer.AnalyzeServerMetrics(serverID, metrics.DimensionPower)
+		if err != nil {
+			continue
+		}
+		for _, anomaly := range analysis.Anomalies {
+			if anomaly.Timestamp.Before(now.Add(-m.config.Lookback)) {
+				continue
+			}
+			key := fmt.Sprintf("%s/%d", serverID, anomaly.Timestamp.Unix())
+			m.mu.RLock()
+			_, known := m.seen[key]
+			m.mu.RUnlock()
+			if known {
+				continue
+			}
+			m.mu.Lock()
+			m.seen[key] = anomaly.Timestamp
+			m.mu.Unlock()
+			fresh = append(fresh, Member{
+				ServerID:  serverID,
+				Timestamp: anomaly.Timestamp,
+				Value:     anomaly.Value,
+				Type:      anomaly.Type,
+				Severity:  anomaly.Severity,
+			})
+		}
+	}
+	// Аномалии присоединяются в порядке времени, чтобы первая открыла инцидент
+	sort.Slice(fresh, func(i, j int) bool { return fresh[i].Timestamp.Before(fresh[j].Timestamp) })
+
+	series := make(map[string]map[int64]float64) // Кэш рядов мощности на один проход
+	var notify []*Incident
+	for _, member := range fresh {
+		incident := m.assign(member, now, series)
+		notify = appendUnique(notify, incident)
+	}
+	resolved := m.expire(now)
+
+	for _, incident := range notify {
+		m.notify(ctx, incident, false)
+	}
+	for i := range resolved {
+		m.notify(ctx, &resolved[i], true)
+	}
+}
+
+// assign присоединяет аномалию к подходящему инциденту или открывает новый
+func (m *Manager) assign(member Member, now time.Time, series map[string]map[int64]float64) Incident {
+	topology := m.topologyOf(member.ServerID)
+
+	m.mu.RLock()
+	candidates := make([]*Incident, 0)
+	for _, incident := range m.incidents {
+		if incident.Status == StatusResolved || member.Timestamp.Sub(incident.LastSeen) > m.config.Window || incident.LastSeen.Sub(member.Timestamp) > m.config.Window {
+			continue
+		}
+		candidates = append(candidates, incident)
+	}
+	m.mu.RUnlock()
+	// Предпочитаем самый свежий инцидент
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].LastSeen.After(candidates[j].LastSeen) })
+
+	var target *Incident
+	for _, incident := range candidates {
+		if reason, ok := m.related(member, topology, incident, now, series); ok {
+			member.Reason = reason
+			target = incident
+			break
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if target == nil {
+		member.Reason = "first anomaly"
+		target = &Incident{
+			ID:        newID(),
+			Status:    StatusOpen,
+			Topology:  topology,
+			StartedAt: member.Timestamp,
+			LastSeen:  member.Timestamp,
+		}
+		target.Timeline = append(target.Timeline, Event{
+			Time:    member.Timestamp,
+			Type:    "opened",
+			Message: fmt.Sprintf("%s on %s", member.Type, member.ServerID),
+		})
+		m.incidents[target.ID] = target
+	} else {
+		target.Timeline = append(target.Timeline, Event{
+			Time:    member.Timestamp,
+			Type:    "anomaly",
+			Message: fmt.Sprintf("%s on %s joined: %s", member.Type, member.ServerID, member.Reason),
+		})
+		// Общая топология - только метки, совпадающие у всех серверов
+		for key, value := range target.Topology {
+			if topology[key] != value {
+				delete(target.Topology, key)
+			}
+		}
+	}
+
+	target.Members = append(target.Members, member)
+	if !contains(target.Servers, member.ServerID) {
+		target.Servers = append(target.Servers, member.ServerID)
+		sort.Strings(target.Servers)
+	}
+	if member.Timestamp.After(target.LastSeen) {
+		target.LastSeen = member.Timestamp
+	}
+	if member.Timestamp.Before(target.StartedAt) {
+		target.StartedAt = member.Timestamp
+	}
+	target.Severity = m.severity(target)
+	target.Title = title(target)
+	return copyIncident(target)
+}
+
+// related проверяет, относится ли аномалия к инциденту: тот же сервер,
+// общий домен отказа или коррелирующий ряд мощности с одним из серверов
+func (m *Manager) related(member Member, topology map[string]string, incident *Incident, now time.Time, series map[string]map[int64]float64) (string, bool) {
+	m.mu.RLock()
+	servers := append([]string(nil), incident.Servers...)
+	m.mu.RUnlock()
+
+	for _, serverID := range servers {
+		if serverID == member.ServerID {
+			return "same server", true
+		}
+	}
+	for _, serverID := range servers {
+		other := m.topologyOf(serverID)
+		for _, key := range m.config.TopologyKeys {
+			if value := topology[key]; value != "" && other[key] == value {
+				return fmt.Sprintf("same %s %s as %s", key, value, serverID), true
+			}
+		}
+	}
+	for _, serverID := range servers {
+		r, ok := m.correlation(member.ServerID, serverID, now, series)
+		if ok && r >= m.config.MinCorrelation {
+			return fmt.Sprintf("power correlates with %s (r=%.2f)", serverID, r), true
+		}
+	}
+	return "", false
+}
+
+// Ряды мощности для корреляции усредняются по correlationStep; нужно не
+// меньше minCorrelationPoints общих интервалов
+const (
+	correlationStep      = time.Minute
+	minCorrelationPoints = 5
+)
+
+// correlation считает коэффициент Пирсона рядов мощности двух серверов за
+// Lookback, усредненных по минутам. ok = false - общих минут слишком мало.
+func (m *Manager) correlation(a, b string, now time.Time, series map[string]map[int64]float64) (float64, bool) {
+	load := func(serverID string) map[int64]float64 {
+		if values, cached := series[serverID]; cached {
+			return values
+		}
+		points, err := m.collector.Aggregate(serverID, "power_usage", now.Add(-m.config.Lookback), now, correlationStep, true)
+		values := make(map[int64]float64, len(points))
+		if err == nil {
+			for _, point := range points {
+				values[point.Start.Unix()] = point.Avg
+			}
+		}
+		series[serverID] = values
+		return values
+	}
+
+	x, y := load(a), load(b)
+	var n, sumX, sumY, sumXX, sumYY, sumXY float64
+	for start, vx := range x {
+		vy, exists := y[start]
+		if !exists {
+			continue
+		}
+		n++
+		sumX += vx
+		sumY += vy
+		sumXX += vx * vx
+		sumYY += vy * vy
+		sumXY += vx * vy
+	}
+	if n < minCorrelationPoints {
+		return 0, false
+	}
+	cov := sumXY/n - sumX/n*sumY/n
+	varX := sumXX/n - sumX/n*sumX/n
+	varY := sumYY/n - sumY/n*sumY/n
+	if varX <= 0 || varY <= 0 {
+		return 0, false
+	}
+	return cov / math.Sqrt(varX*varY), true
+}
+
+// expire закрывает инциденты без новых аномалий и забывает старые
+func (m *Manager) expire(now time.Time) []Incident {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var resolved []Incident
+	for id, incident := range m.incidents {
+		switch {
+		case incident.Status != StatusResolved && now.Sub(incident.LastSeen) > m.config.ResolveAfter:
+			m.resolveLocked(incident, now, fmt.Sprintf("no new anomalies for %s", m.config.ResolveAfter))
+			resolved = append(resolved, copyIncident(incident))
+		case incident.Status == StatusResolved && incident.ResolvedAt != nil && now.Sub(*incident.ResolvedAt) > m.config.Retention:
+			delete(m.incidents, id)
+		}
+	}
+	for key, timestamp := range m.seen {
+		if now.Sub(timestamp) > m.config.Lookback {
+			delete(m.seen, key)
+		}
+	}
+	return resolved
+}
+
+func (m *Manager) resolveLocked(incident *Incident, now time.Time, message string) {
+	incident.Status = StatusResolved
+	resolvedAt := now
+	incident.ResolvedAt = &resolvedAt
+	incident.Timeline = append(incident.Timeline, Event{Time: now, Type: "resolved", Message: message})
+}
+
+// SetStatus подтверждает, закрывает или вновь открывает инцидент вручную
+func (m *Manager) SetStatus(ctx context.Context, id string, status Status, note string) (Incident, error) {
+	if status != StatusOpen && status != StatusAcknowledged && status != StatusResolved {
+		return Incident{}, fmt.Errorf("%w: %q", ErrInvalidStatus, status)
+	}
+
+	m.mu.Lock()
+	incident, exists := m.incidents[id]
+	if !exists {
+		m.mu.Unlock()
+		return Incident{}, ErrNotFound
+	}
+	now := time.Now()
+	if note == "" {
+		note = "status changed to " + string(status)
+	}
+	previous := incident.Status
+	switch {
+	case status == previous:
+	case status == StatusResolved:
+		m.resolveLocked(incident, now, note)
+	case previous == StatusResolved:
+		incident.Status = status
+		incident.ResolvedAt = nil
+		// Вновь открытый инцидент не закрывается сразу по ResolveAfter
+		incident.LastSeen = now
+		incident.Timeline = append(incident.Timeline, Event{Time: now, Type: "reopened", Message: note})
+	default:
+		incident.Status = status
+		incident.Timeline = append(incident.Timeline, Event{Time: now, Type: string(status), Message: note})
+	}
+	result := copyIncident(incident)
+	m.mu.Unlock()
+
+	if status == StatusResolved && previous != StatusResolved {
+		m.notify(ctx, &result, true)
+	}
+	return result, nil
+}
+
+// Get возвращает инцидент по ID
+func (m *Manager) Get(id string) (Incident, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	incident, exists := m.incidents[id]
+	if !exists {
+		return Incident{}, ErrNotFound
+	}
+	return copyIncident(incident), nil
+}
+
+// List возвращает инциденты, начиная с последних; пустой status - все
+func (m *Manager) List(status Status) []Incident {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	list := make([]Incident, 0, len(m.incidents))
+	for _, incident := range m.incidents {
+		if status == "" || incident.Status == status {
+			list = append(list, copyIncident(incident))
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].StartedAt.After(list[j].StartedAt) })
+	return list
+}
+
+// notify рассылает одно оповещение на инцидент: рост инцидента сворачивается
+// диспетчером по ключу, закрытие рассылается отдельным ключом
+func (m *Manager) notify(ctx context.Context, incident *Incident, resolved bool) {
+	if m.alerts == nil {
+		return
+	}
+	alert := alerting.Alert{
+		Key:      "incidents/" + incident.ID,
+		Source:   "incidents",
+		Severity: incident.Severity,
+		Title:    incident.Title,
+		Message:  fmt.Sprintf("%d anomalies on %d servers since %s", len(incident.Members), len(incident.Servers), incident.StartedAt.UTC().Format(time.RFC3339)),
+		Labels: map[string]string{
+			"incident_id": incident.ID,
+			"status":      string(incident.Status),
+		},
+	}
+	for key, value := range incident.Topology {
+		alert.Labels[key] = value
+	}
+	if resolved {
+		alert.Key += "/resolved"
+		alert.Severity = alerting.SeverityInfo
+		alert.Title = "Resolved: " + incident.Title
+	}
+	m.alerts.Notify(ctx, alert)
+}
+
+func (m *Manager) topologyOf(serverID string) map[string]string {
+	m.mu.RLock()
+	source := m.topology
+	m.mu.RUnlock()
+
+	labels := source(serverID)
+	topology := make(map[string]string)
+	for _, key := range m.config.TopologyKeys {
+		if value := labels[key]; value != "" {
+			topology[key] = value
+		}
+	}
+	return topology
+}
+
+// severity - critical, если затронуто больше одного сервера: событие уровня
+// стойки или площадки важнее аномалии одного хоста
+func (m *Manager) severity(incident *Incident) alerting.Severity {
+	if len(incident.Servers) > 1 {
+		return alerting.SeverityCritical
+	}
+	return alerting.SeverityWarning
+}
+
+func title(incident *Incident) string {
+	scope := fmt.Sprintf("%d servers", len(incident.Servers))
+	if len(incident.Servers) == 1 {
+		scope = incident.Servers[0]
+	}
+	keys := make([]string, 0, len(incident.Topology))
+	for key := range incident.Topology {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	if len(keys) > 0 {
+		scope += " in " + keys[0] + " " + incident.Topology[keys[0]]
+	}
+	return "Power anomaly on " + scope
+}
+
+func copyIncident(incident *Incident) Incident {
+	result := *incident
+	result.Servers = append([]string(nil), incident.Servers...)
+	result.Members = append([]Member(nil), incident.Members...)
+	result.Timeline = append([]Event(nil), incident.Timeline...)
+	result.Topology = make(map[string]string, len(incident.Topology))
+	for key, value := range incident.Topology {
+		result.Topology[key] = value
+	}
+	return result
+}
+
+func appendUnique(list []*Incident, incident Incident) []*Incident {
+	for i, existing := range list {
+		if existing.ID == incident.ID {
+			list[i] = &incident
+			return list
+		}
+	}
+	return append(list, &incident)
+}
+
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+func newID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
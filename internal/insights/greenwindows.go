@@ -0,0 +1,180 @@
+package insights
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/YumeNoTenshi/platypus/pkg/carbon"
+)
+
+// ErrNoCarbonForecast - прогноз интенсивности не настроен
+var ErrNoCarbonForecast = errors.New("carbon intensity forecast is not configured")
+
+const (
+	defaultGreenWindowHorizon = 48 * time.Hour
+	defaultGreenWindowLimit   = 5
+)
+
+type GreenWindowQuery struct {
+	Region   string
+	Duration time.Duration // Длительность задания
+	Horizon  time.Duration // Насколько вперед искать; по умолчанию 48 ч
+	Limit    int           // По умолчанию 5
+}
+
+// GreenWindow - интервал для запуска задания и средняя прогнозная
+// интенсивность сети за время его работы
+type GreenWindow struct {
+	Start          time.Time `json:"start"`
+	End            time.Time `json:"end"`
+	AvgGramsPerKWh float64   `json:"avg_grams_per_kwh"`
+	MaxGramsPerKWh float64   `json:"max_grams_per_kwh"`
+	SavingsPercent float64   `json:"savings_percent"` // Меньше выбросов, чем при запуске сейчас
+}
+
+// GreenWindows - ответ на запрос окон: окна по возрастанию интенсивности
+// и интенсивность при запуске сейчас для сравнения
+type GreenWindows struct {
+	Region            string        `json:"region"`
+	Duration          string        `json:"duration"`
+	NowAvgGramsPerKWh float64       `json:"now_avg_grams_per_kwh"`
+	Windows           []GreenWindow `json:"windows"`
+}
+
+// SetCarbonForecast задает источник прогноза интенсивности для GreenWindows
+func (i *Insights) SetCarbonForecast(forecast carbon.Forecaster) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.forecast = forecast
+}
+
+// GreenWindows находит ближайшие непересекающиеся окна с самой чистой
+// прогнозной энергией для задания длительностью query.Duration. Решение о
+// запуске остается за командой: Platypus только подсказывает время.
+func (i *Insights) GreenWindows(ctx context.Context, query GreenWindowQuery, now time.Time) (GreenWindows, error) {
+	i.mu.RLock()
+	forecast := i.forecast
+	i.mu.RUnlock()
+	if forecast == nil {
+		return GreenWindows{}, ErrNoCarbonForecast
+	}
+	if query.Region == "" {
+		return GreenWindows{}, fmt.Errorf("region is required")
+	}
+	if query.Duration <= 0 {
+		return GreenWindows{}, fmt.Errorf("duration must be positive")
+	}
+	if query.Horizon <= 0 {
+		query.Horizon = defaultGreenWindowHorizon
+	}
+	if query.Limit <= 0 {
+		query.Limit = defaultGreenWindowLimit
+	}
+
+	points, err := forecast.Forecast(ctx, query.Region, now, now.Add(query.Horizon))
+	if err != nil {
+		return GreenWindows{}, err
+	}
+	result := GreenWindows{Region: query.Region, Duration: query.Duration.String(), Windows: []GreenWindow{}}
+	profile := newIntensityProfile(points, now.Add(query.Horizon))
+	if profile == nil || profile.end.Before(now.Add(query.Duration)) {
+		return result, fmt.Errorf("%w %s covering %s", carbon.ErrNoForecast, query.Region, query.Duration)
+	}
+	nowAvg, _ := profile.average(now, now.Add(query.Duration))
+	result.NowAvgGramsPerKWh = nowAvg
+
+	// Кандидаты - запуск сейчас и начала интервалов прогноза
+	starts := []time.Time{now}
+	for _, point := range profile.points {
+		if point.Time.After(now) && !point.Time.Add(query.Duration).After(profile.end) {
+			starts = append(starts, point.Time)
+		}
+	}
+	candidates := make([]GreenWindow, 0, len(starts))
+	for _, start := range starts {
+		end := start.Add(query.Duration)
+		avg, peak := profile.average(start, end)
+		window := GreenWindow{Start: start, End: end, AvgGramsPerKWh: avg, MaxGramsPerKWh: peak}
+		if nowAvg > 0 {
+			window.SavingsPercent = (nowAvg - avg) / nowAvg * 100
+		}
+		candidates = append(candidates, window)
+	}
+	// При равной интенсивности раньше - лучше
+	sort.SliceStable(candidates, func(a, b int) bool {
+		if candidates[a].AvgGramsPerKWh != candidates[b].AvgGramsPerKWh {
+			return candidates[a].AvgGramsPerKWh < candidates[b].AvgGramsPerKWh
+		}
+		return candidates[a].Start.Before(candidates[b].Start)
+	})
+	for _, candidate := range candidates {
+		if len(result.Windows) == query.Limit {
+			break
+		}
+		overlaps := false
+		for _, chosen := range result.Windows {
+			if candidate.Start.Before(chosen.End) && chosen.Start.Before(candidate.End) {
+				overlaps = true
+				break
+			}
+		}
+		if !overlaps {
+			result.Windows = append(result.Windows, candidate)
+		}
+	}
+	return result, nil
+}
+
+// intensityProfile - ступенчатая функция интенсивности: значение точки
+// действует до следующей точки, последняя - на свой шаг или до end
+type intensityProfile struct {
+	points []carbon.ForecastPoint
+	end    time.Time
+}
+
+func newIntensityProfile(points []carbon.ForecastPoint, horizon time.Time) *intensityProfile {
+	if len(points) == 0 {
+		return nil
+	}
+	end := horizon
+	if len(points) > 1 {
+		last := points[len(points)-1].Time
+		end = last.Add(last.Sub(points[len(points)-2].Time))
+		if end.After(horizon) {
+			end = horizon
+		}
+	}
+	return &intensityProfile{points: points, end: end}
+}
+
+// average - средняя по времени и наибольшая интенсивность в [from, to)
+func (p *intensityProfile) average(from, to time.Time) (avg, peak float64) {
+	var weighted, total float64
+	for i, point := range p.points {
+		next := p.end
+		if i+1 < len(p.points) {
+			next = p.points[i+1].Time
+		}
+		start, end := point.Time, next
+		if start.Before(from) {
+			start = from
+		}
+		if end.After(to) {
+			end = to
+		}
+		if !end.After(start) {
+			continue
+		}
+		seconds := end.Sub(start).Seconds()
+		weighted += point.GramsPerKWh * seconds
+		total += seconds
+		peak = max(peak, point.GramsPerKWh)
+	}
+	if total == 0 {
+		return 0, 0
+	}
+	return weighted / total, peak
+}
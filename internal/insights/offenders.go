@@ -0,0 +1,280 @@
+// Package insights формирует сводные выводы по парку серверов и сервисов
+// для регулярных обзоров энергоэффективности
+package insights
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/YumeNoTenshi/platypus/internal/ecotags"
+	"github.com/YumeNoTenshi/platypus/internal/metrics"
+	"github.com/YumeNoTenshi/platypus/internal/models"
+	"github.com/YumeNoTenshi/platypus/pkg/carbon"
+)
+
+// RankBy определяет критерий сортировки нарушителей
+type RankBy string
+
+const (
+	RankByPower      RankBy = "power"
+	RankByCarbon     RankBy = "carbon"
+	RankByRegression RankBy = "regression"
+)
+
+// Scope определяет, что ранжируется
+type Scope string
+
+const (
+	ScopeServers  Scope = "servers"
+	ScopeServices Scope = "services"
+	ScopeTeams    Scope = "teams" // Сервисы, сгруппированные по метке team
+)
+
+type OffenderQuery struct {
+	Scope  Scope
+	By     RankBy
+	Limit  int
+	Window time.Duration // Текущее окно; для регрессии сравнивается с предыдущим окном той же длины
+}
+
+// Offender - сервер или сервис с подтверждающими цифрами
+type Offender struct {
+	ID                 string            `json:"id"`
+	Kind               string            `json:"kind"`
+	PowerUsage         float64           `json:"power_usage"`      // Среднее за текущее окно, Вт
+	CarbonFootprint    float64           `json:"carbon_footprint"` // Суммарно за текущее окно, кг CO2
+	PreviousPowerUsage *float64          `json:"previous_power_usage,omitempty"`
+	RegressionPercent  *float64          `json:"regression_percent,omitempty"` // Рост среднего потребления к предыдущему окну
+	DataPoints         int               `json:"data_points"`
+	Labels             map[string]string `json:"labels,omitempty"`
+}
+
+type Insights struct {
+	collector *metrics.Collector
+	tags      *ecotags.TagManager
+
+	mu       sync.RWMutex
+	forecast carbon.Forecaster // Необязательный прогноз интенсивности для GreenWindows
+}
+
+func New(collector *metrics.Collector, tags *ecotags.TagManager) *Insights {
+	return &Insights{
+		collector: collector,
+		tags:      tags,
+	}
+}
+
+// TopOffenders возвращает N худших серверов или сервисов по выбранному критерию
+func (i *Insights) TopOffenders(query OffenderQuery) ([]Offender, error) {
+	if query.Limit <= 0 {
+		query.Limit = 10
+	}
+	if query.Window <= 0 {
+		query.Window = 7 * 24 * time.Hour
+	}
+
+	var offenders []Offender
+	switch query.Scope {
+	case ScopeServers, "":
+		offenders = i.serverOffenders(query.Window)
+	case ScopeServices:
+		offenders = i.serviceOffenders(query.Window)
+	case ScopeTeams:
+		offenders = i.teamOffenders(query.Window)
+	default:
+		return nil, fmt.Errorf("unknown scope: %s", query.Scope)
+	}
+
+	var key func(o Offender) float64
+	switch query.By {
+	case RankByPower, "":
+		key = func(o Offender) float64 { return o.PowerUsage }
+	case RankByCarbon:
+		key = func(o Offender) float64 { return o.CarbonFootprint }
+	case RankByRegression:
+		// Без данных за предыдущее окно регрессия не считается
+		filtered := offenders[:0]
+		for _, o := range offenders {
+			if o.RegressionPercent != nil {
+				filtered = append(filtered, o)
+			}
+		}
+		offenders = filtered
+		key = func(o Offender) float64 { return *o.RegressionPercent }
+	default:
+		return nil, fmt.Errorf("unknown ranking: %s", query.By)
+	}
+
+	sort.Slice(offenders, func(a, b int) bool {
+		return key(offenders[a]) > key(offenders[b])
+	})
+
+	if len(offenders) > query.Limit {
+		offenders = offenders[:query.Limit]
+	}
+	return offenders, nil
+}
+
+func (i *Insights) serverOffenders(window time.Duration) []Offender {
+	currentFrom, previousFrom := windowBounds(window)
+
+	var offenders []Offender
+	for _, serverID := range i.collector.ServerIDs() {
+		data, err := i.collector.GetMetrics(serverID)
+		if err != nil {
+			continue
+		}
+
+		current, previous := splitWindows(data, currentFrom, previousFrom)
+		if len(current) == 0 {
+			continue
+		}
+
+		offender := Offender{
+			ID:         serverID,
+			Kind:       "server",
+			DataPoints: len(current),
+		}
+		offender.PowerUsage, offender.CarbonFootprint = summarize(current)
+
+		if len(previous) > 0 {
+			prevPower, _ := summarize(previous)
+			offender.setPrevious(prevPower)
+		}
+
+		offenders = append(offenders, offender)
+	}
+	return offenders
+}
+
+// serviceOffenders считает потребление сервисов за текущее и предыдущее
+// окно по точкам их работающих контейнеров. Потребление сервиса - сумма
+// средних потреблений контейнеров: реплики работают одновременно.
+// Метки берутся из эко-профиля сервиса, без него - из меток контейнера.
+func (i *Insights) serviceOffenders(window time.Duration) []Offender {
+	currentFrom, previousFrom := windowBounds(window)
+
+	labels := make(map[string]map[string]string)
+	if i.tags != nil {
+		for _, profile := range i.tags.GetAllProfiles() {
+			labels[profile.ServiceName] = profile.Labels
+		}
+	}
+
+	services := make(map[string]*Offender)
+	previousPower := make(map[string]float64)
+	for _, container := range i.collector.Containers("") {
+		if container.ServiceName == "" {
+			continue
+		}
+		data, err := i.collector.GetContainerMetrics(container.ID)
+		if err != nil {
+			continue
+		}
+		current, previous := splitWindows(data, currentFrom, previousFrom)
+		if len(current) == 0 {
+			continue
+		}
+
+		offender, exists := services[container.ServiceName]
+		if !exists {
+			offender = &Offender{ID: container.ServiceName, Kind: "service", Labels: labels[container.ServiceName]}
+			if offender.Labels == nil {
+				offender.Labels = container.Labels
+			}
+			services[container.ServiceName] = offender
+		}
+		power, carbon := summarize(current)
+		offender.PowerUsage += power
+		offender.CarbonFootprint += carbon
+		offender.DataPoints += len(current)
+		if len(previous) > 0 {
+			prevPower, _ := summarize(previous)
+			previousPower[container.ServiceName] += prevPower
+		}
+	}
+
+	offenders := make([]Offender, 0, len(services))
+	for name, offender := range services {
+		if prevPower, exists := previousPower[name]; exists {
+			offender.setPrevious(prevPower)
+		}
+		offenders = append(offenders, *offender)
+	}
+	return offenders
+}
+
+// teamOffenders суммирует потребление сервисов по командам;
+// сервисы без метки team попадают в группу "unassigned"
+func (i *Insights) teamOffenders(window time.Duration) []Offender {
+	teams := make(map[string]*Offender)
+	previousPower := make(map[string]float64)
+	for _, service := range i.serviceOffenders(window) {
+		team := service.Labels["team"]
+		if team == "" {
+			team = "unassigned"
+		}
+
+		offender, exists := teams[team]
+		if !exists {
+			offender = &Offender{ID: team, Kind: "team"}
+			teams[team] = offender
+		}
+		offender.PowerUsage += service.PowerUsage
+		offender.CarbonFootprint += service.CarbonFootprint
+		offender.DataPoints += service.DataPoints
+		if service.PreviousPowerUsage != nil {
+			previousPower[team] += *service.PreviousPowerUsage
+		}
+	}
+
+	offenders := make([]Offender, 0, len(teams))
+	for team, offender := range teams {
+		if prevPower, exists := previousPower[team]; exists {
+			offender.setPrevious(prevPower)
+		}
+		offenders = append(offenders, *offender)
+	}
+	return offenders
+}
+
+// setPrevious задает потребление за предыдущее окно и рост к нему
+func (o *Offender) setPrevious(prevPower float64) {
+	o.PreviousPowerUsage = &prevPower
+	if prevPower > 0 {
+		regression := (o.PowerUsage - prevPower) / prevPower * 100
+		o.RegressionPercent = &regression
+	}
+}
+
+// windowBounds возвращает начала текущего и предыдущего окна
+func windowBounds(window time.Duration) (currentFrom, previousFrom int64) {
+	now := time.Now()
+	return now.Add(-window).Unix(), now.Add(-2 * window).Unix()
+}
+
+// splitWindows делит точки на текущее и предыдущее окно; более ранние
+// точки отбрасываются
+func splitWindows(data []models.MetricData, currentFrom, previousFrom int64) (current, previous []models.MetricData) {
+	for _, m := range data {
+		switch {
+		case m.Timestamp >= currentFrom:
+			current = append(current, m)
+		case m.Timestamp >= previousFrom:
+			previous = append(previous, m)
+		}
+	}
+	return current, previous
+}
+
+// summarize возвращает среднее потребление и суммарный углеродный след
+func summarize(data []models.MetricData) (float64, float64) {
+	var power, carbon float64
+	for _, m := range data {
+		power += m.PowerUsage
+		carbon += m.CarbonFootprint
+	}
+	return power / float64(len(data)), carbon
+}
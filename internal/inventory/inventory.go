@@ -0,0 +1,221 @@
+// Package inventory хранит сведения об обнаруженных серверах и сервисах
+// и выводит для них метки (команда, окружение, центр затрат)
+// из метаданных оркестратора и облачных тегов
+package inventory
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/YumeNoTenshi/platypus/internal/models"
+	"github.com/YumeNoTenshi/platypus/internal/scheduler"
+	"github.com/YumeNoTenshi/platypus/pkg/cloud"
+)
+
+type Config struct {
+	RefreshInterval time.Duration
+	Labels          LabelConfig
+	// RegistryPath - JSON-файл зарегистрированных серверов; пусто -
+	// регистрации живут только в памяти процесса
+	RegistryPath string
+}
+
+type Inventory struct {
+	config     Config
+	provider   cloud.CloudProvider
+	mu         sync.RWMutex
+	servers    map[string]models.Server
+	namespaces map[string]map[string]string // Namespace -> метки
+	overrides  map[string]map[string]string // Сервис -> каноническая метка -> значение
+	services   map[string]models.Container  // Последний встреченный контейнер сервиса
+	placement  map[string]map[string]bool   // Сервер -> сервисы, чьи контейнеры на нем встречались
+	metadata   map[string]ServerMetadata    // Сервер -> импортированные метаданные
+
+	registrations map[string]Registration // Сервер -> регистрация (Register)
+	saveMu        sync.Mutex              // Упорядочивает запись реестра
+}
+
+func New(config Config, provider cloud.CloudProvider) *Inventory {
+	return &Inventory{
+		config:     config,
+		provider:   provider,
+		servers:    make(map[string]models.Server),
+		namespaces: make(map[string]map[string]string),
+		overrides:  make(map[string]map[string]string),
+		services:   make(map[string]models.Container),
+		placement:  make(map[string]map[string]bool),
+		metadata:   make(map[string]ServerMetadata),
+
+		registrations: make(map[string]Registration),
+	}
+}
+
+// Jobs возвращает периодическое обновление инвентаря; первое - сразу при старте
+func (inv *Inventory) Jobs() []scheduler.Job {
+	return []scheduler.Job{{
+		Name:      "inventory.refresh",
+		Interval:  inv.config.RefreshInterval,
+		Immediate: true,
+		Run:       inv.Refresh,
+	}}
+}
+
+// Refresh перечитывает список серверов и их теги у провайдера
+func (inv *Inventory) Refresh(ctx context.Context) error {
+	servers, err := inv.provider.GetInstances(ctx)
+	if err != nil {
+		return err
+	}
+
+	index := make(map[string]models.Server, len(servers))
+	for _, server := range servers {
+		index[server.ID] = server
+	}
+
+	inv.mu.Lock()
+	inv.servers = index
+	inv.mu.Unlock()
+	return nil
+}
+
+// Servers возвращает все обнаруженные и зарегистрированные серверы
+func (inv *Inventory) Servers() []models.Server {
+	inv.mu.RLock()
+	defer inv.mu.RUnlock()
+
+	servers := make([]models.Server, 0, len(inv.servers)+len(inv.registrations))
+	for _, serverID := range inv.serverIDs() {
+		server, _ := inv.server(serverID)
+		servers = append(servers, server)
+	}
+	return servers
+}
+
+// ServerIDs возвращает идентификаторы обнаруженных и зарегистрированных серверов
+func (inv *Inventory) ServerIDs() []string {
+	inv.mu.RLock()
+	defer inv.mu.RUnlock()
+	return inv.serverIDs()
+}
+
+// serverIDs вызывается под блокировкой
+func (inv *Inventory) serverIDs() []string {
+	ids := make([]string, 0, len(inv.servers)+len(inv.registrations))
+	for serverID := range inv.servers {
+		ids = append(ids, serverID)
+	}
+	for serverID := range inv.registrations {
+		if _, discovered := inv.servers[serverID]; !discovered {
+			ids = append(ids, serverID)
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// Server возвращает последние известные сведения о сервере
+func (inv *Inventory) Server(serverID string) (models.Server, bool) {
+	inv.mu.RLock()
+	defer inv.mu.RUnlock()
+	return inv.server(serverID)
+}
+
+// SetNamespaceLabels сохраняет метки namespace Kubernetes
+func (inv *Inventory) SetNamespaceLabels(namespace string, labels map[string]string) {
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+	inv.namespaces[namespace] = labels
+}
+
+// SetOverride задает ручные значения канонических меток сервиса;
+// пустой набор снимает переопределение
+func (inv *Inventory) SetOverride(service string, labels map[string]string) {
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+
+	if len(labels) == 0 {
+		delete(inv.overrides, service)
+		return
+	}
+	inv.overrides[service] = labels
+}
+
+func (inv *Inventory) Override(service string) map[string]string {
+	inv.mu.RLock()
+	defer inv.mu.RUnlock()
+	return inv.overrides[service]
+}
+
+// ServerLabels выводит метки сервера из его метаданных и облачных тегов
+func (inv *Inventory) ServerLabels(serverID string) Labels {
+	inv.mu.RLock()
+	defer inv.mu.RUnlock()
+
+	return Resolve(inv.config.Labels, map[Source]map[string]string{
+		SourceServer: inv.metadata[serverID].tags(),
+		SourceCloud:  inv.serverTags(serverID),
+	})
+}
+
+// ContainerLabels выводит метки сервиса контейнера из всех источников
+// и запоминает контейнер как представителя сервиса для ServiceLabels
+func (inv *Inventory) ContainerLabels(container models.Container) Labels {
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+
+	if container.ServiceName != "" {
+		inv.services[container.ServiceName] = container
+		if container.ServerID != "" {
+			if inv.placement[container.ServerID] == nil {
+				inv.placement[container.ServerID] = make(map[string]bool)
+			}
+			inv.placement[container.ServerID][container.ServiceName] = true
+		}
+	}
+	return inv.resolveContainer(container)
+}
+
+// ServiceLabels возвращает метки сервиса. Если контейнеры сервиса еще не
+// встречались, учитываются только ручные переопределения.
+func (inv *Inventory) ServiceLabels(service string) Labels {
+	inv.mu.RLock()
+	defer inv.mu.RUnlock()
+
+	container, exists := inv.services[service]
+	if !exists {
+		container = models.Container{ServiceName: service}
+	}
+	return inv.resolveContainer(container)
+}
+
+// ServicesOn возвращает сервисы, контейнеры которых встречались на сервере
+func (inv *Inventory) ServicesOn(serverID string) []string {
+	inv.mu.RLock()
+	defer inv.mu.RUnlock()
+
+	services := make([]string, 0, len(inv.placement[serverID]))
+	for service := range inv.placement[serverID] {
+		services = append(services, service)
+	}
+	sort.Strings(services)
+	return services
+}
+
+func (inv *Inventory) resolveContainer(container models.Container) Labels {
+	return Resolve(inv.config.Labels, map[Source]map[string]string{
+		SourceOverride:  inv.overrides[container.ServiceName],
+		SourcePod:       container.Labels,
+		SourceNamespace: inv.namespaces[container.Namespace],
+		SourceServer:    inv.metadata[container.ServerID].tags(),
+		SourceCloud:     inv.serverTags(container.ServerID),
+	})
+}
+
+// serverTags возвращает теги сервера вместе с тегами регистрации.
+// Вызывается под блокировкой.
+func (inv *Inventory) serverTags(serverID string) map[string]string {
+	server, _ := inv.server(serverID)
+	return server.Tags
+}
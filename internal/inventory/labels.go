@@ -0,0 +1,87 @@
+package inventory
+
+import "strings"
+
+// Source - источник метаданных, из которого выводится метка
+type Source string
+
+const (
+	SourceOverride  Source = "override"  // Ручное переопределение для сервиса
+	SourcePod       Source = "pod"       // Метки пода Kubernetes
+	SourceNamespace Source = "namespace" // Метки namespace Kubernetes
+	SourceServer    Source = "server"    // Метаданные сервера из импорта
+	SourceCloud     Source = "cloud"     // Теги инстанса у облачного провайдера
+)
+
+// LabelConfig описывает, какие метки выводятся и из каких ключей
+type LabelConfig struct {
+	// Keys сопоставляет каноническую метку (team, environment, cost_center)
+	// ключам, под которыми она встречается в метаданных. Ключи сравниваются
+	// без учета регистра; каноническое имя проверяется всегда.
+	Keys map[string][]string
+	// Precedence - порядок источников, первый имеет наивысший приоритет
+	Precedence []Source
+}
+
+func DefaultLabelConfig() LabelConfig {
+	return LabelConfig{
+		Keys: map[string][]string{
+			"team":           {"team", "owner", "app.kubernetes.io/part-of"},
+			"environment":    {"environment", "env", "stage"},
+			"cost_center":    {"cost-center", "costcenter", "cost_centre"},
+			"downtime_class": {"downtime-class", "platypus.io/downtime-class"},
+		},
+		Precedence: []Source{SourceOverride, SourcePod, SourceNamespace, SourceServer, SourceCloud},
+	}
+}
+
+// Labels - выведенные метки и источник каждой из них
+type Labels struct {
+	Values  map[string]string `json:"values"`
+	Sources map[string]Source `json:"sources"`
+}
+
+// Get возвращает значение метки или пустую строку
+func (l Labels) Get(name string) string {
+	return l.Values[name]
+}
+
+// Resolve выводит канонические метки из метаданных источников с учетом приоритета
+func Resolve(config LabelConfig, metadata map[Source]map[string]string) Labels {
+	labels := Labels{
+		Values:  make(map[string]string),
+		Sources: make(map[string]Source),
+	}
+
+	for name, keys := range config.Keys {
+		candidates := append([]string{name}, keys...)
+		for _, source := range config.Precedence {
+			if value, ok := lookup(metadata[source], candidates); ok {
+				labels.Values[name] = value
+				labels.Sources[name] = source
+				break
+			}
+		}
+	}
+	return labels
+}
+
+func lookup(values map[string]string, keys []string) (string, bool) {
+	if len(values) == 0 {
+		return "", false
+	}
+	for _, key := range keys {
+		if value, ok := values[key]; ok && value != "" {
+			return value, true
+		}
+	}
+	// Теги облаков часто записаны с заглавной буквы (Team, CostCenter)
+	for key, value := range values {
+		for _, candidate := range keys {
+			if value != "" && strings.EqualFold(key, candidate) {
+				return value, true
+			}
+		}
+	}
+	return "", false
+}
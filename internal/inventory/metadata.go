@@ -0,0 +1,311 @@
+package inventory
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Подсистемы, от которых сервер можно исключить
+const (
+	ExemptMigration       = "migration"       // Не источник и не цель миграций
+	ExemptGovernor        = "governor"        // Без директив регулятора частот
+	ExemptRecommendations = "recommendations" // Без рекомендаций по экономии
+)
+
+var knownExemptions = map[string]bool{
+	ExemptMigration:       true,
+	ExemptGovernor:        true,
+	ExemptRecommendations: true,
+}
+
+// ServerMetadata - сведения о сервере, заданные вручную или импортом.
+// Владелец, метки и класс простоя участвуют в выводе меток как источник
+// SourceServer: важнее облачных тегов, но уступают меткам пода и namespace.
+type ServerMetadata struct {
+	ServerID      string            `json:"server_id" yaml:"server_id"`
+	Owner         string            `json:"owner,omitempty" yaml:"owner,omitempty"`
+	DowntimeClass string            `json:"downtime_class,omitempty" yaml:"downtime_class,omitempty"`
+	Exemptions    []string          `json:"exemptions,omitempty" yaml:"exemptions,omitempty"`
+	Labels        map[string]string `json:"labels,omitempty" yaml:"labels,omitempty"`
+}
+
+func (m ServerMetadata) validate() error {
+	if m.ServerID == "" {
+		return fmt.Errorf("server_id is required")
+	}
+	for _, exemption := range m.Exemptions {
+		if !knownExemptions[exemption] {
+			return fmt.Errorf("server %s: unknown exemption %q", m.ServerID, exemption)
+		}
+	}
+	return nil
+}
+
+// tags представляет метаданные как теги для вывода меток: владелец - под
+// ключом owner (синоним team), класс простоя - под каноническим именем
+func (m ServerMetadata) tags() map[string]string {
+	tags := make(map[string]string, len(m.Labels)+2)
+	for key, value := range m.Labels {
+		tags[key] = value
+	}
+	if m.Owner != "" {
+		tags["owner"] = m.Owner
+	}
+	if m.DowntimeClass != "" {
+		tags["downtime_class"] = m.DowntimeClass
+	}
+	return tags
+}
+
+// Форматы файлов импорта и экспорта
+const (
+	FormatCSV  = "csv"
+	FormatYAML = "yaml"
+	FormatJSON = "json"
+)
+
+// FormatFromPath определяет формат по расширению файла
+func FormatFromPath(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		return FormatCSV
+	case ".yaml", ".yml":
+		return FormatYAML
+	}
+	return FormatJSON
+}
+
+// csvColumns - столбцы CSV с полями метаданных; остальные столбцы - метки
+var csvColumns = []string{"server_id", "owner", "downtime_class", "exemptions"}
+
+// DecodeMetadata читает записи метаданных. В CSV первая строка - заголовок
+// со столбцами server_id, owner, downtime_class и exemptions (через ";");
+// каждый прочий столбец - метка с именем столбца, пустая ячейка - метки нет.
+// YAML и JSON - список объектов ServerMetadata.
+func DecodeMetadata(r io.Reader, format string) ([]ServerMetadata, error) {
+	var records []ServerMetadata
+	switch format {
+	case FormatCSV:
+		var err error
+		if records, err = decodeCSV(r); err != nil {
+			return nil, err
+		}
+	case FormatYAML:
+		if err := yaml.NewDecoder(r).Decode(&records); err != nil && err != io.EOF {
+			return nil, fmt.Errorf("invalid yaml: %w", err)
+		}
+	case FormatJSON:
+		if err := json.NewDecoder(r).Decode(&records); err != nil {
+			return nil, fmt.Errorf("invalid json: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported format %q: use csv, yaml or json", format)
+	}
+
+	seen := make(map[string]int, len(records))
+	for i, record := range records {
+		if err := record.validate(); err != nil {
+			return nil, fmt.Errorf("record %d: %w", i+1, err)
+		}
+		if first, duplicate := seen[record.ServerID]; duplicate {
+			return nil, fmt.Errorf("record %d: server %s already listed in record %d", i+1, record.ServerID, first)
+		}
+		seen[record.ServerID] = i + 1
+	}
+	return records, nil
+}
+
+func decodeCSV(r io.Reader) ([]ServerMetadata, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid csv header: %w", err)
+	}
+	for i := range header {
+		header[i] = strings.TrimSpace(header[i])
+	}
+	hasServerID := false
+	for _, column := range header {
+		hasServerID = hasServerID || column == "server_id"
+	}
+	if !hasServerID {
+		return nil, fmt.Errorf("csv header must include a server_id column")
+	}
+
+	var records []ServerMetadata
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			return records, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid csv: %w", err)
+		}
+
+		var record ServerMetadata
+		for i, value := range row {
+			value = strings.TrimSpace(value)
+			switch header[i] {
+			case "server_id":
+				record.ServerID = value
+			case "owner":
+				record.Owner = value
+			case "downtime_class":
+				record.DowntimeClass = value
+			case "exemptions":
+				for _, exemption := range strings.Split(value, ";") {
+					if exemption = strings.TrimSpace(exemption); exemption != "" {
+						record.Exemptions = append(record.Exemptions, exemption)
+					}
+				}
+			default:
+				if value == "" {
+					continue
+				}
+				if record.Labels == nil {
+					record.Labels = make(map[string]string)
+				}
+				record.Labels[header[i]] = value
+			}
+		}
+		records = append(records, record)
+	}
+}
+
+// EncodeMetadata записывает записи в формате, который читает DecodeMetadata
+func EncodeMetadata(w io.Writer, format string, records []ServerMetadata) error {
+	switch format {
+	case FormatCSV:
+		return encodeCSV(w, records)
+	case FormatYAML:
+		encoder := yaml.NewEncoder(w)
+		encoder.SetIndent(2)
+		if err := encoder.Encode(records); err != nil {
+			return err
+		}
+		return encoder.Close()
+	case FormatJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(records)
+	}
+	return fmt.Errorf("unsupported format %q: use csv, yaml or json", format)
+}
+
+func encodeCSV(w io.Writer, records []ServerMetadata) error {
+	labelSet := make(map[string]bool)
+	for _, record := range records {
+		for name := range record.Labels {
+			labelSet[name] = true
+		}
+	}
+	labels := make([]string, 0, len(labelSet))
+	for name := range labelSet {
+		labels = append(labels, name)
+	}
+	sort.Strings(labels)
+
+	writer := csv.NewWriter(w)
+	if err := writer.Write(append(append([]string(nil), csvColumns...), labels...)); err != nil {
+		return err
+	}
+	for _, record := range records {
+		row := []string{record.ServerID, record.Owner, record.DowntimeClass, strings.Join(record.Exemptions, ";")}
+		for _, name := range labels {
+			row = append(row, record.Labels[name])
+		}
+		if err := writer.Write(row); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// LoadMetadata читает файл метаданных; формат определяется по расширению
+func LoadMetadata(path string) ([]ServerMetadata, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	records, err := DecodeMetadata(file, FormatFromPath(path))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return records, nil
+}
+
+// ImportMetadata сохраняет записи: запись заменяет прежние метаданные своего
+// сервера целиком. Записи проверяются все до применения, так что при ошибке
+// инвентарь не меняется.
+func (inv *Inventory) ImportMetadata(records []ServerMetadata) error {
+	for i, record := range records {
+		if err := record.validate(); err != nil {
+			return fmt.Errorf("record %d: %w", i+1, err)
+		}
+	}
+
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+	for _, record := range records {
+		inv.metadata[record.ServerID] = record
+	}
+	return nil
+}
+
+// Metadata возвращает метаданные сервера
+func (inv *Inventory) Metadata(serverID string) (ServerMetadata, bool) {
+	inv.mu.RLock()
+	defer inv.mu.RUnlock()
+	metadata, exists := inv.metadata[serverID]
+	return metadata, exists
+}
+
+// ExportMetadata возвращает метаданные всех известных серверов, включая
+// обнаруженные у провайдера и зарегистрированные без метаданных, - заготовку для заполнения и
+// повторного импорта
+func (inv *Inventory) ExportMetadata() []ServerMetadata {
+	inv.mu.RLock()
+	defer inv.mu.RUnlock()
+
+	records := make([]ServerMetadata, 0, len(inv.metadata)+len(inv.servers))
+	for _, metadata := range inv.metadata {
+		records = append(records, metadata)
+	}
+	for _, serverID := range inv.serverIDs() {
+		if _, exists := inv.metadata[serverID]; !exists {
+			records = append(records, ServerMetadata{ServerID: serverID})
+		}
+	}
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].ServerID < records[j].ServerID
+	})
+	return records
+}
+
+// Exempt сообщает, исключен ли сервер из подсистемы (ExemptMigration и т.д.)
+func (inv *Inventory) Exempt(serverID, subsystem string) bool {
+	inv.mu.RLock()
+	defer inv.mu.RUnlock()
+	for _, exemption := range inv.metadata[serverID].Exemptions {
+		if exemption == subsystem {
+			return true
+		}
+	}
+	return false
+}
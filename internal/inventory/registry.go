@@ -0,0 +1,220 @@
+package inventory
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/YumeNoTenshi/platypus/internal/models"
+)
+
+// ProviderOnPrem - провайдер зарегистрированного сервера, если он не указан
+const ProviderOnPrem = "onprem"
+
+// Capacity - емкость сервера; нулевое поле - не известна
+type Capacity struct {
+	CPUCores      int     `json:"cpu_cores,omitempty"`
+	MemoryGB      float64 `json:"memory_gb,omitempty"`
+	MaxPowerWatts float64 `json:"max_power_watts,omitempty"` // Потребление при полной нагрузке
+}
+
+// Registration - сервер, зарегистрированный через API или при развертывании
+// агента. Регистрация дополняет обнаружение у провайдера: серверы без
+// облачного API (свое железо, периферия) попадают в инвентарь только так, а
+// у обнаруженных серверов она заполняет поля, которых провайдер не сообщил.
+type Registration struct {
+	ServerID     string            `json:"server_id"`
+	Provider     string            `json:"provider,omitempty"`
+	Region       string            `json:"region,omitempty"`
+	InstanceType string            `json:"instance_type,omitempty"`
+	Capacity     Capacity          `json:"capacity"`
+	Tags         map[string]string `json:"tags,omitempty"`
+	RegisteredAt time.Time         `json:"registered_at"`
+	UpdatedAt    time.Time         `json:"updated_at"`
+}
+
+func (r Registration) validate() error {
+	if r.ServerID == "" {
+		return fmt.Errorf("server_id is required")
+	}
+	if r.Capacity.CPUCores < 0 || r.Capacity.MemoryGB < 0 || r.Capacity.MaxPowerWatts < 0 {
+		return fmt.Errorf("server %s: capacity must not be negative", r.ServerID)
+	}
+	return nil
+}
+
+func (r Registration) server() models.Server {
+	return models.Server{
+		ID:           r.ServerID,
+		Provider:     r.Provider,
+		Region:       r.Region,
+		InstanceType: r.InstanceType,
+		Tags:         r.Tags,
+	}
+}
+
+// Register регистрирует серверы или обновляет их регистрацию. Пачка
+// принимается целиком: если хотя бы одна запись некорректна, ничего не
+// меняется, а ошибки всех записей возвращаются вместе.
+func (inv *Inventory) Register(registrations []Registration) ([]Registration, error) {
+	var errs []error
+	seen := make(map[string]bool, len(registrations))
+	for i, registration := range registrations {
+		if err := registration.validate(); err != nil {
+			errs = append(errs, fmt.Errorf("record %d: %w", i+1, err))
+			continue
+		}
+		if seen[registration.ServerID] {
+			errs = append(errs, fmt.Errorf("record %d: duplicate server_id %s", i+1, registration.ServerID))
+		}
+		seen[registration.ServerID] = true
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	registered := make([]Registration, 0, len(registrations))
+	inv.mu.Lock()
+	for _, registration := range registrations {
+		if registration.Provider == "" {
+			registration.Provider = ProviderOnPrem
+		}
+		registration.RegisteredAt, registration.UpdatedAt = now, now
+		if previous, exists := inv.registrations[registration.ServerID]; exists {
+			registration.RegisteredAt = previous.RegisteredAt
+		}
+		inv.registrations[registration.ServerID] = registration
+		registered = append(registered, registration)
+	}
+	inv.mu.Unlock()
+	inv.saveRegistrations()
+	return registered, nil
+}
+
+// Deregister снимает регистрацию серверов и возвращает число снятых.
+// Сервер, который обнаруживает провайдер, остается в инвентаре.
+func (inv *Inventory) Deregister(serverIDs ...string) int {
+	inv.mu.Lock()
+	removed := 0
+	for _, serverID := range serverIDs {
+		if _, exists := inv.registrations[serverID]; exists {
+			delete(inv.registrations, serverID)
+			removed++
+		}
+	}
+	inv.mu.Unlock()
+	if removed > 0 {
+		inv.saveRegistrations()
+	}
+	return removed
+}
+
+// Registrations возвращает регистрации по идентификатору сервера
+func (inv *Inventory) Registrations() []Registration {
+	inv.mu.RLock()
+	defer inv.mu.RUnlock()
+
+	registrations := make([]Registration, 0, len(inv.registrations))
+	for _, registration := range inv.registrations {
+		registrations = append(registrations, registration)
+	}
+	sort.Slice(registrations, func(i, j int) bool {
+		return registrations[i].ServerID < registrations[j].ServerID
+	})
+	return registrations
+}
+
+// Registration возвращает регистрацию сервера
+func (inv *Inventory) Registration(serverID string) (Registration, bool) {
+	inv.mu.RLock()
+	defer inv.mu.RUnlock()
+	registration, exists := inv.registrations[serverID]
+	return registration, exists
+}
+
+// server возвращает сервер, обнаруженный провайдером, с полями, которые
+// дополнены регистрацией, или только зарегистрированный. Вызывается под
+// блокировкой.
+func (inv *Inventory) server(serverID string) (models.Server, bool) {
+	server, discovered := inv.servers[serverID]
+	registration, registered := inv.registrations[serverID]
+	switch {
+	case !registered:
+		return server, discovered
+	case !discovered:
+		return registration.server(), true
+	}
+
+	if server.Region == "" {
+		server.Region = registration.Region
+	}
+	if server.InstanceType == "" {
+		server.InstanceType = registration.InstanceType
+	}
+	if len(registration.Tags) > 0 {
+		tags := make(map[string]string, len(registration.Tags)+len(server.Tags))
+		for key, value := range registration.Tags {
+			tags[key] = value
+		}
+		for key, value := range server.Tags {
+			tags[key] = value // Теги облака точнее регистрации
+		}
+		server.Tags = tags
+	}
+	return server, true
+}
+
+// LoadRegistrations загружает сохраненные регистрации из Config.RegistryPath
+func (inv *Inventory) LoadRegistrations() error {
+	if inv.config.RegistryPath == "" {
+		return nil
+	}
+	data, err := os.ReadFile(inv.config.RegistryPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var saved []Registration
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return fmt.Errorf("invalid server registry %s: %w", inv.config.RegistryPath, err)
+	}
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+	for _, registration := range saved {
+		inv.registrations[registration.ServerID] = registration
+	}
+	return nil
+}
+
+// saveRegistrations записывает регистрации во временный файл и подменяет им
+// прежний
+func (inv *Inventory) saveRegistrations() {
+	if inv.config.RegistryPath == "" {
+		return
+	}
+
+	inv.saveMu.Lock()
+	defer inv.saveMu.Unlock()
+
+	data, err := json.Marshal(inv.Registrations())
+	if err != nil {
+		log.Printf("Не удалось сохранить реестр серверов: %v", err)
+		return
+	}
+	tmp := inv.config.RegistryPath + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		log.Printf("Не удалось сохранить реестр серверов: %v", err)
+		return
+	}
+	if err := os.Rename(tmp, inv.config.RegistryPath); err != nil {
+		log.Printf("Не удалось сохранить реестр серверов: %v", err)
+	}
+}
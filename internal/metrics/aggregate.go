@@ -0,0 +1,125 @@
+package metrics
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/YumeNoTenshi/platypus/internal/models"
+)
+
+// ErrUnknownMetric - у точки нет числового поля с таким именем
+var ErrUnknownMetric = errors.New("unknown metric")
+
+// maxAggregateSteps ограничивает число интервалов в одном запросе агрегации
+const maxAggregateSteps = 10000
+
+// metricFields - числовые поля точки, доступные для агрегации, по именам JSON
+var metricFields = map[string]func(m models.MetricData) float64{
+	"power_usage":        func(m models.MetricData) float64 { return m.PowerUsage },
+	"carbon_footprint":   func(m models.MetricData) float64 { return m.CarbonFootprint },
+	"cpu_usage":          func(m models.MetricData) float64 { return m.CPUUsage },
+	"memory_usage":       func(m models.MetricData) float64 { return m.MemoryUsage },
+	"gpu_usage":          func(m models.MetricData) float64 { return m.GPUUsage },
+	"gpu_power_usage":    func(m models.MetricData) float64 { return m.GPUPowerUsage },
+	"network_rx_bytes":   func(m models.MetricData) float64 { return m.NetworkRxBytes },
+	"network_tx_bytes":   func(m models.MetricData) float64 { return m.NetworkTxBytes },
+	"disk_read_bps":      func(m models.MetricData) float64 { return m.DiskReadBytesPerSec },
+	"disk_write_bps":     func(m models.MetricData) float64 { return m.DiskWriteBytesPerSec },
+	"storage_used_bytes": func(m models.MetricData) float64 { return m.StorageUsedBytes },
+	"inlet_temp_c":       func(m models.MetricData) float64 { return m.InletTemperature },
+}
+
+// MetricNames возвращает имена полей, доступных для агрегации
+func MetricNames() []string {
+	names := make([]string, 0, len(metricFields))
+	for name := range metricFields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// AggregatePoint - статистика метрики за интервал
+type AggregatePoint struct {
+	Start   time.Time `json:"start"`
+	Samples int       `json:"samples"`
+	Avg     float64   `json:"avg"`
+	Min     float64   `json:"min"`
+	Max     float64   `json:"max"`
+	P95     float64   `json:"p95"`
+}
+
+// AggregateMetric считает avg, min, max и p95 поля metric по интервалам длины
+// step в [from, to), выровненным по from. step <= 0 - один интервал на весь
+// диапазон. Агрегированная точка весит столько, сколько исходных точек в ней
+// усреднено. Пустые интервалы пропускаются.
+func AggregateMetric(data []models.MetricData, metric string, from, to time.Time, step time.Duration) ([]AggregatePoint, error) {
+	value, exists := metricFields[metric]
+	if !exists {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownMetric, metric)
+	}
+	if !to.After(from) {
+		return nil, fmt.Errorf("empty range")
+	}
+	if step <= 0 {
+		step = to.Sub(from)
+	}
+	if steps := int64(to.Sub(from) / step); steps > maxAggregateSteps {
+		return nil, fmt.Errorf("too many steps: %d, at most %d are allowed", steps, maxAggregateSteps)
+	}
+
+	type sample struct {
+		value  float64
+		weight int
+	}
+	size := int64(step / time.Second)
+	if size <= 0 {
+		return nil, fmt.Errorf("step must be at least 1s")
+	}
+	origin := from.Unix()
+	groups := make(map[int64][]sample)
+	for _, m := range data {
+		if !inRange(m, from, to) {
+			continue
+		}
+		weight := m.Samples
+		if weight <= 0 {
+			weight = 1
+		}
+		start := origin + floorDiv(m.Timestamp-origin, size)*size
+		groups[start] = append(groups[start], sample{value: value(m), weight: weight})
+	}
+
+	points := make([]AggregatePoint, 0, len(groups))
+	for start, samples := range groups {
+		sort.Slice(samples, func(i, j int) bool { return samples[i].value < samples[j].value })
+
+		point := AggregatePoint{
+			Start: time.Unix(start, 0).UTC(),
+			Min:   samples[0].value,
+			Max:   samples[len(samples)-1].value,
+		}
+		var sum float64
+		for _, s := range samples {
+			point.Samples += s.weight
+			sum += s.value * float64(s.weight)
+		}
+		point.Avg = sum / float64(point.Samples)
+
+		// p95 по методу ближайшего ранга с учетом весов
+		rank := 0.95 * float64(point.Samples)
+		var cumulative float64
+		for _, s := range samples {
+			cumulative += float64(s.weight)
+			point.P95 = s.value
+			if cumulative >= rank {
+				break
+			}
+		}
+		points = append(points, point)
+	}
+	sort.Slice(points, func(i, j int) bool { return points[i].Start.Before(points[j].Start) })
+	return points, nil
+}
@@ -4,142 +4,321 @@ import (
 	"fmt"
 	"math"
 	"sort"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/YumeNoTenshi/platypus/internal/models"
+	"github.com/YumeNoTenshi/platypus/pkg/catalog"
 )
 
 type AnalyzerConfig struct {
-	MinDataPoints     int
-	SmoothingFactor   float64
-	AnomalyThreshold  float64
+	MinDataPoints      int
+	SmoothingFactor    float64 // Коэффициент EWMA (0-1) для AnomalyEWMA; иначе 0.2
+	AnomalyThreshold   float64
+	AnomalyMethod      AnomalyMethod   // zscore (по умолчанию), ewma или mad
+	AnomalyDetector    AnomalyDetector // Свой детектор аномалий; nil - встроенный по AnomalyMethod
+	Window             time.Duration // Окно данных для анализа; 0 - вся хранимая история
+	NetworkEnergyPerGB float64       // кВт*ч на ГБ переданных данных; 0 - сеть не учитывается
+	ScoreWeights       ScoreWeights  // Веса эко-рейтинга; нулевые - DefaultScoreWeights
+	// OwnBaselinePoints - сколько точек нужно серверу, чтобы аномалии
+	// искались только по его истории; до этого его базовая линия смешивается
+	// с когортой (тот же тип инстанса и сервис). 0 - когорты не используются.
+	OwnBaselinePoints int
+	// DecompositionWindow - история для разложения ряда на тренд и сезоны;
+	// 0 - две недели, чтобы оценить и недельный сезон
+	DecompositionWindow time.Duration
+}
+
+// defaultDecompositionWindow покрывает два недельных периода
+const defaultDecompositionWindow = 2 * 7 * 24 * time.Hour
+
+// ScoreWeights - веса составляющих эко-рейтинга, в сумме 1
+type ScoreWeights struct {
+	Power       float64 `json:"power"`
+	Utilization float64 `json:"utilization"`
+	Carbon      float64 `json:"carbon"`
+}
+
+// DefaultScoreWeights - веса исходной методики рейтинга
+var DefaultScoreWeights = ScoreWeights{Power: 0.4, Utilization: 0.3, Carbon: 0.3}
+
+// Validate проверяет, что веса неотрицательны и в сумме дают 1
+func (w ScoreWeights) Validate() error {
+	if w.Power < 0 || w.Utilization < 0 || w.Carbon < 0 {
+		return fmt.Errorf("score weights must not be negative")
+	}
+	if sum := w.Power + w.Utilization + w.Carbon; math.Abs(sum-1) > 1e-6 {
+		return fmt.Errorf("score weights must sum to 1, got %g", sum)
+	}
+	return nil
+}
+
+// ParseScoreWeights разбирает веса вида "0.4,0.3,0.3" (мощность, утилизация,
+// углеродный след)
+func ParseScoreWeights(value string) (ScoreWeights, error) {
+	parts := strings.Split(value, ",")
+	if len(parts) != 3 {
+		return ScoreWeights{}, fmt.Errorf("expected three comma-separated weights: power,utilization,carbon")
+	}
+	var numbers [3]float64
+	for i, part := range parts {
+		number, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return ScoreWeights{}, fmt.Errorf("invalid weight %q: %w", part, err)
+		}
+		numbers[i] = number
+	}
+	weights := ScoreWeights{Power: numbers[0], Utilization: numbers[1], Carbon: numbers[2]}
+	return weights, weights.Validate()
+}
+
+// ScoreInputs - величины, из которых складывается эко-рейтинг. Сохраненные
+// входы позволяют пересчитать рейтинг прошлых периодов по другой методике,
+// когда сырые точки уже удалены.
+type ScoreInputs struct {
+	Samples      int     `json:"samples"`
+	MeanPower    float64 `json:"mean_power"`    // W
+	NetworkPower float64 `json:"network_power"` // W
+	Utilization  float64 `json:"utilization"`   // Оценка утилизации, 0-1
+	AvgCarbon    float64 `json:"avg_carbon"`    // Средний углеродный след точки, кг CO2
+}
+
+// Score рассчитывает эко-рейтинг (0-100) по входам; powerFactor приводит
+// потребление к эталонному размеру инстанса (1 - без нормализации)
+func (w ScoreWeights) Score(in ScoreInputs, powerFactor float64) float64 {
+	if in.Samples == 0 {
+		return 0
+	}
+
+	// Нормализация: чем меньше энергопотребление, тем выше счет; 1000W как базовое значение
+	powerScore := math.Max(0, 1-(in.MeanPower+in.NetworkPower)*powerFactor/1000)
+	// Чем меньше углеродный след, тем выше счет
+	carbonScore := math.Max(0, 1-in.AvgCarbon)
+
+	return (powerScore*w.Power + in.Utilization*w.Utilization + carbonScore*w.Carbon) * 100
 }
 
 type Analyzer struct {
-	config     AnalyzerConfig
-	collector  *Collector
+	config   AnalyzerConfig
+	source   MetricsSource // Точки серверов; обычно Collector
+	detector AnomalyDetector
+
+	mu            sync.RWMutex
+	instanceTypes map[string]string // ServerID -> тип инстанса из каталога
+	cohorts       map[Dimension]map[string]CohortBaseline // Измерение -> когорта -> базовая линия, пересчитывается раз в cohortTTL
+	cohortsAt     map[Dimension]time.Time
+}
+
+// EcoScores содержит эко-рейтинг сервера в абсолютном виде и нормализованный
+// по размеру инстанса, чтобы сравнивать эффективность, а не масштаб
+type EcoScores struct {
+	Raw          float64 `json:"raw"`
+	Normalized   float64 `json:"normalized"`
+	InstanceType string  `json:"instance_type,omitempty"`
 }
 
 type MetricAnalysis struct {
-	Mean             float64
-	Median           float64
-	StdDev           float64
-	Min              float64
-	Max              float64
-	Trend            string
-	Anomalies        []Anomaly
-	PeakUsageTime    time.Time
-	EfficiencyScore  float64
+	Dimension       Dimension `json:"dimension"` // Какое поле точек анализировалось
+	Mean            float64   `json:"mean"`
+	Median          float64   `json:"median"`
+	Percentiles     Percentiles `json:"percentiles"`
+	StdDev          float64   `json:"std_dev"`
+	Min             float64   `json:"min"`
+	Max             float64   `json:"max"`
+	Trend           string    `json:"trend"` // По составляющей тренда разложения за окно анализа
+	Baseline        Baseline  `json:"baseline"` // От чего считаются аномалии
+	Anomalies       []Anomaly `json:"anomalies"`
+	PeakUsageTime   time.Time `json:"peak_usage_time"`
+	EfficiencyScore float64   `json:"efficiency_score"`
+	// Decomposition - тренд, суточный и недельный сезоны и остаток; nil -
+	// истории слишком мало
+	Decomposition *Decomposition `json:"decomposition,omitempty"`
+}
+
+// Percentiles - процентили измерения за окно анализа с линейной
+// интерполяцией между соседними точками; P50 совпадает с медианой
+type Percentiles struct {
+	P50 float64 `json:"p50"`
+	P90 float64 `json:"p90"`
+	P95 float64 `json:"p95"`
+	P99 float64 `json:"p99"`
 }
 
 type Anomaly struct {
-	Timestamp time.Time
-	Value     float64
-	Type      string
-	Severity  float64
+	Timestamp time.Time `json:"timestamp"`
+	Value     float64   `json:"value"`
+	Type      string    `json:"type"`
+	Severity  float64   `json:"severity"`
 }
 
-func NewAnalyzer(config AnalyzerConfig, collector *Collector) *Analyzer {
+// NewAnalyzer создает анализатор точек из source: коллектора или другого
+// источника, например NewStoreSource поверх реплики хранилища
+func NewAnalyzer(config AnalyzerConfig, source MetricsSource) *Analyzer {
+	if config.ScoreWeights == (ScoreWeights{}) {
+		config.ScoreWeights = DefaultScoreWeights
+	}
+	detector := config.AnomalyDetector
+	if detector == nil {
+		detector = newAnomalyDetector(config)
+	}
 	return &Analyzer{
-		config:    config,
-		collector: collector,
+		config:        config,
+		source:        source,
+		detector:      detector,
+		instanceTypes: make(map[string]string),
+		cohorts:       make(map[Dimension]map[string]CohortBaseline),
+		cohortsAt:     make(map[Dimension]time.Time),
+	}
+}
+
+// recentMetrics возвращает отфильтрованные точки сервера за окно анализа
+func (a *Analyzer) recentMetrics(serverID string) ([]models.MetricData, error) {
+	if a.config.Window <= 0 {
+		return a.source.GetFilteredMetrics(serverID)
 	}
+	now := time.Now()
+	return a.source.GetFilteredMetricsRange(serverID, now.Add(-a.config.Window), now)
 }
 
-func (a *Analyzer) AnalyzeServerMetrics(serverID string) (*MetricAnalysis, error) {
-	metrics, err := a.collector.GetMetrics(serverID)
+// AnalyzeServerMetrics считает статистику, тренд и аномалии одного измерения
+// точек сервера: потребления, загрузки CPU, памяти или углеродного следа
+func (a *Analyzer) AnalyzeServerMetrics(serverID string, dimension Dimension) (*MetricAnalysis, error) {
+	metrics, err := a.recentMetrics(serverID)
 	if err != nil {
 		return nil, err
 	}
 
-	if len(metrics) < a.config.MinDataPoints {
+	// Новый сервер без достаточной истории анализируется по когорте
+	baseline, ok := a.baseline(serverID, metrics, dimension)
+	if !ok || len(metrics) == 0 {
 		return nil, fmt.Errorf("insufficient data points for analysis")
 	}
 
-	analysis := &MetricAnalysis{}
+	analysis := &MetricAnalysis{Dimension: dimension, Baseline: baseline}
 	
 	// Базовая статистика
-	analysis.Mean = a.calculateMean(metrics)
-	analysis.Median = a.calculateMedian(metrics)
-	analysis.StdDev = a.calculateStdDev(metrics, analysis.Mean)
-	analysis.Min, analysis.Max = a.calculateMinMax(metrics)
+	analysis.Mean = a.calculateMean(metrics, dimension)
+	analysis.Percentiles = a.calculatePercentiles(metrics, dimension)
+	analysis.Median = analysis.Percentiles.P50
+	analysis.StdDev = a.calculateStdDev(metrics, dimension, analysis.Mean)
+	analysis.Min, analysis.Max = a.calculateMinMax(metrics, dimension)
 	
-	// Анализ тренда
-	analysis.Trend = a.analyzeTrend(metrics)
+	// Разложение строится по более длинной истории, чем окно анализа:
+	// суточному сезону нужны двое суток, недельному - две недели
+	analysis.Decomposition = a.decompose(serverID, dimension)
+	analysis.Trend = a.analyzeTrend(metrics, analysis.Decomposition)
 	
 	// Поиск аномалий
-	analysis.Anomalies = a.detectAnomalies(metrics, analysis.Mean, analysis.StdDev)
+	analysis.Anomalies = a.detector.Detect(metrics, dimension, baseline)
 	
 	// Определение пикового времени использования
-	analysis.PeakUsageTime = a.findPeakUsageTime(metrics)
+	analysis.PeakUsageTime = a.findPeakUsageTime(metrics, dimension)
 	
 	// Расчет общего показателя эффективности
-	analysis.EfficiencyScore = a.calculateEfficiencyScore(metrics)
+	analysis.EfficiencyScore = a.calculateEfficiencyScore(metrics, 1)
 	
 	return analysis, nil
 }
 
-func (a *Analyzer) calculateMean(metrics []models.MetricData) float64 {
+func (a *Analyzer) calculateMean(metrics []models.MetricData, dimension Dimension) float64 {
 	var sum float64
 	for _, m := range metrics {
-		sum += m.PowerUsage
+		sum += dimension.Value(m)
 	}
 	return sum / float64(len(metrics))
 }
 
-func (a *Analyzer) calculateMedian(metrics []models.MetricData) float64 {
+func (a *Analyzer) calculatePercentiles(metrics []models.MetricData, dimension Dimension) Percentiles {
 	values := make([]float64, len(metrics))
 	for i, m := range metrics {
-		values[i] = m.PowerUsage
+		values[i] = dimension.Value(m)
 	}
 	sort.Float64s(values)
-	
-	mid := len(values) / 2
-	if len(values)%2 == 0 {
-		return (values[mid-1] + values[mid]) / 2
+
+	return Percentiles{
+		P50: percentile(values, 0.50),
+		P90: percentile(values, 0.90),
+		P95: percentile(values, 0.95),
+		P99: percentile(values, 0.99),
 	}
-	return values[mid]
 }
 
-func (a *Analyzer) calculateStdDev(metrics []models.MetricData, mean float64) float64 {
+// percentile возвращает процентиль q (0-1) отсортированных значений,
+// интерполируя между соседними рангами
+func percentile(sorted []float64, q float64) float64 {
+	rank := q * float64(len(sorted)-1)
+	lower := int(rank)
+	if lower >= len(sorted)-1 {
+		return sorted[len(sorted)-1]
+	}
+	fraction := rank - float64(lower)
+	return sorted[lower] + (sorted[lower+1]-sorted[lower])*fraction
+}
+
+func (a *Analyzer) calculateStdDev(metrics []models.MetricData, dimension Dimension, mean float64) float64 {
 	var sumSquares float64
 	for _, m := range metrics {
-		diff := m.PowerUsage - mean
+		diff := dimension.Value(m) - mean
 		sumSquares += diff * diff
 	}
 	return math.Sqrt(sumSquares / float64(len(metrics)))
 }
 
-func (a *Analyzer) calculateMinMax(metrics []models.MetricData) (float64, float64) {
-	min := metrics[0].PowerUsage
-	max := metrics[0].PowerUsage
+func (a *Analyzer) calculateMinMax(metrics []models.MetricData, dimension Dimension) (float64, float64) {
+	min := dimension.Value(metrics[0])
+	max := min
 	
 	for _, m := range metrics {
-		if m.PowerUsage < min {
-			min = m.PowerUsage
+		value := dimension.Value(m)
+		if value < min {
+			min = value
 		}
-		if m.PowerUsage > max {
-			max = m.PowerUsage
+		if value > max {
+			max = value
 		}
 	}
 	
 	return min, max
 }
 
-func (a *Analyzer) analyzeTrend(metrics []models.MetricData) string {
-	if len(metrics) < 2 {
+// decompose раскладывает измерение сервера за DecompositionWindow
+func (a *Analyzer) decompose(serverID string, dimension Dimension) *Decomposition {
+	window := a.config.DecompositionWindow
+	if window <= 0 {
+		window = defaultDecompositionWindow
+	}
+	now := time.Now()
+	history, err := a.source.GetFilteredMetricsRange(serverID, now.Add(-window), now)
+	if err != nil {
+		return nil
+	}
+	decomposition, err := Decompose(history, dimension)
+	if err != nil {
+		return nil
+	}
+	return decomposition
+}
+
+// analyzeTrend сравнивает составляющую тренда в начале и в конце окна
+// анализа: суточные и недельные колебания в нее не входят, поэтому окно,
+// начатое ночью и законченное днем, не дает ложного роста
+func (a *Analyzer) analyzeTrend(metrics []models.MetricData, decomposition *Decomposition) string {
+	if len(metrics) < 2 || decomposition == nil {
 		return "stable"
 	}
 
-	// Простой линейный тренд
-	firstHalf := metrics[:len(metrics)/2]
-	secondHalf := metrics[len(metrics)/2:]
-	
-	firstMean := a.calculateMean(firstHalf)
-	secondMean := a.calculateMean(secondHalf)
-	
-	diff := secondMean - firstMean
-	threshold := 0.1 * firstMean
+	from := time.Unix(metrics[0].Timestamp, 0).Truncate(decomposition.Step)
+	points := decomposition.Points
+	first := sort.Search(len(points), func(i int) bool { return !points[i].Timestamp.Before(from) })
+	if first >= len(points)-1 {
+		return "stable"
+	}
+	start, end := points[first].Trend, points[len(points)-1].Trend
+
+	diff := end - start
+	threshold := 0.1 * math.Abs(start)
 	
 	if diff > threshold {
 		return "increasing"
@@ -149,86 +328,216 @@ func (a *Analyzer) analyzeTrend(metrics []models.MetricData) string {
 	return "stable"
 }
 
-func (a *Analyzer) detectAnomalies(metrics []models.MetricData, mean, stdDev float64) []Anomaly {
-	var anomalies []Anomaly
+func (a *Analyzer) findPeakUsageTime(metrics []models.MetricData, dimension Dimension) time.Time {
+	var maxUsage float64
+	var peakTime time.Time
 	
 	for _, m := range metrics {
-		zScore := math.Abs(m.PowerUsage - mean) / stdDev
-		if zScore > a.config.AnomalyThreshold {
-			anomaly := Anomaly{
-				Timestamp: time.Unix(m.Timestamp, 0),
-				Value:     m.PowerUsage,
-				Type:      a.classifyAnomaly(m.PowerUsage, mean),
-				Severity:  zScore,
-			}
-			anomalies = append(anomalies, anomaly)
+		if value := dimension.Value(m); value > maxUsage {
+			maxUsage = value
+			peakTime = time.Unix(m.Timestamp, 0)
 		}
 	}
 	
-	return anomalies
+	return peakTime
+}
+
+// RegisterInstance связывает сервер с типом инстанса для нормализации эко-рейтинга
+func (a *Analyzer) RegisterInstance(serverID, instanceType string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.instanceTypes[serverID] = instanceType
+}
+
+// InstanceType возвращает тип инстанса, зарегистрированный для сервера
+func (a *Analyzer) InstanceType(serverID string) (string, bool) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	instanceType, exists := a.instanceTypes[serverID]
+	return instanceType, exists
+}
+
+// CalculateEcoScore возвращает абсолютный эко-рейтинг (0-100) без учета размера инстанса
+func (a *Analyzer) CalculateEcoScore(metrics []models.MetricData) float64 {
+	return a.calculateEfficiencyScore(metrics, 1)
 }
 
-func (a *Analyzer) classifyAnomaly(value, mean float64) string {
-	if value > mean {
-		return "spike"
+// CalculateEcoScores возвращает абсолютный и нормализованный эко-рейтинг сервера.
+// Если тип инстанса неизвестен каталогу, нормализованный рейтинг равен абсолютному.
+func (a *Analyzer) CalculateEcoScores(serverID string, metrics []models.MetricData) EcoScores {
+	inputs := a.ScoreInputs(metrics)
+	scores := EcoScores{
+		Raw: a.config.ScoreWeights.Score(inputs, 1),
+	}
+	scores.Normalized = scores.Raw
+
+	a.mu.RLock()
+	instanceType := a.instanceTypes[serverID]
+	a.mu.RUnlock()
+
+	if spec, exists := catalog.Lookup(instanceType); exists {
+		scores.InstanceType = spec.Type
+		scores.Normalized = a.config.ScoreWeights.Score(inputs, spec.NormalizationFactor())
 	}
-	return "drop"
+
+	return scores
 }
 
-func (a *Analyzer) findPeakUsageTime(metrics []models.MetricData) time.Time {
-	var maxUsage float64
-	var peakTime time.Time
-	
-	for _, m := range metrics {
-		if m.PowerUsage > maxUsage {
-			maxUsage = m.PowerUsage
-			peakTime = time.Unix(m.Timestamp, 0)
-		}
+// ScoreWeights возвращает веса, по которым анализатор считает рейтинг
+func (a *Analyzer) ScoreWeights() ScoreWeights {
+	return a.config.ScoreWeights
+}
+
+// NormalizationFactor возвращает множитель потребления для нормализованного
+// рейтинга сервера; 1 - тип инстанса неизвестен каталогу
+func (a *Analyzer) NormalizationFactor(serverID string) float64 {
+	a.mu.RLock()
+	instanceType := a.instanceTypes[serverID]
+	a.mu.RUnlock()
+
+	if spec, exists := catalog.Lookup(instanceType); exists {
+		return spec.NormalizationFactor()
 	}
-	
-	return peakTime
+	return 1
 }
 
-func (a *Analyzer) calculateEfficiencyScore(metrics []models.MetricData) float64 {
+// ScoreInputs рассчитывает входы эко-рейтинга по точкам
+func (a *Analyzer) ScoreInputs(metrics []models.MetricData) ScoreInputs {
 	if len(metrics) == 0 {
-		return 0
+		return ScoreInputs{}
 	}
+	return ScoreInputs{
+		Samples:      len(metrics),
+		MeanPower:    a.calculateMean(metrics, DimensionPower),
+		NetworkPower: a.NetworkPower(metrics),
+		Utilization:  a.calculateUtilizationScore(metrics),
+		AvgCarbon:    a.calculateAvgCarbon(metrics),
+	}
+}
 
-	// Базовый показатель на основе энергопотребления
-	powerScore := a.calculatePowerScore(metrics)
-	
-	// Учитываем утилизацию CPU
-	utilizationScore := a.calculateUtilizationScore(metrics)
-	
-	// Учитываем углеродный след
-	carbonScore := a.calculateCarbonScore(metrics)
-	
-	// Взвешенная сумма всех показателей
-	return (powerScore*0.4 + utilizationScore*0.3 + carbonScore*0.3) * 100
+func (a *Analyzer) calculateEfficiencyScore(metrics []models.MetricData, powerFactor float64) float64 {
+	return a.config.ScoreWeights.Score(a.ScoreInputs(metrics), powerFactor)
 }
 
-func (a *Analyzer) calculatePowerScore(metrics []models.MetricData) float64 {
-	mean := a.calculateMean(metrics)
-	// Нормализация: чем меньше энергопотребление, тем выше счет
-	return math.Max(0, 1-mean/1000) // 1000W как базовое значение
+// NetworkPower возвращает энергозатраты на передачу трафика сервера,
+// выраженные в средних ваттах за охваченный точками период. Сеть потребляет
+// энергию вне сервера, но она вызвана его нагрузкой и растет при переносе
+// между регионами.
+func (a *Analyzer) NetworkPower(metrics []models.MetricData) float64 {
+	if a.config.NetworkEnergyPerGB <= 0 || len(metrics) < 2 {
+		return 0
+	}
+
+	var bytes float64
+	for _, m := range metrics {
+		bytes += m.NetworkRxBytes + m.NetworkTxBytes
+	}
+	if bytes == 0 {
+		return 0
+	}
+
+	// Каждая точка несет трафик за свой интервал, поэтому период - это
+	// расстояние между крайними точками плюс один шаг
+	first, last := metrics[0].Timestamp, metrics[len(metrics)-1].Timestamp
+	span := float64(last-first) * float64(len(metrics)) / float64(len(metrics)-1)
+	if span <= 0 {
+		return 0
+	}
+
+	kWh := bytes / 1e9 * a.config.NetworkEnergyPerGB
+	return kWh * 1000 / (span / 3600)
 }
 
+// calculateUtilizationScore оценивает утилизацию. На серверах с GPU оценки
+// CPU и GPU смешиваются пропорционально доле GPU в потреблении: простаивающий,
+// но включенный ускоритель должен снижать рейтинг сильнее простаивающего CPU.
 func (a *Analyzer) calculateUtilizationScore(metrics []models.MetricData) float64 {
 	var totalUtil float64
 	for _, m := range metrics {
 		// Оптимальная утилизация около 70%
 		score := 1 - math.Abs(0.7-m.CPUUsage/100)
+		if share := gpuPowerShare(m); share > 0 {
+			gpuScore := 1 - math.Abs(0.7-m.GPUUsage/100)
+			score = score*(1-share) + gpuScore*share
+		}
 		totalUtil += score
 	}
 	return totalUtil / float64(len(metrics))
 }
 
-func (a *Analyzer) calculateCarbonScore(metrics []models.MetricData) float64 {
+// gpuPowerShare возвращает долю GPU в потреблении сервера. Если сервер
+// сообщает только загрузку GPU, без потребления, CPU и GPU весят поровну.
+func gpuPowerShare(m models.MetricData) float64 {
+	switch {
+	case m.GPUPowerUsage > 0 && m.PowerUsage > 0:
+		return math.Min(1, m.GPUPowerUsage/m.PowerUsage)
+	case m.GPUUsage > 0:
+		return 0.5
+	}
+	return 0
+}
+
+func (a *Analyzer) calculateAvgCarbon(metrics []models.MetricData) float64 {
 	var totalCarbon float64
 	for _, m := range metrics {
 		totalCarbon += m.CarbonFootprint
 	}
-	avgCarbon := totalCarbon / float64(len(metrics))
-	// Нормализация: чем меньше углеродный след, тем выше счет
-	return math.Max(0, 1-avgCarbon)
-} 
\ No newline at end of file
+	return totalCarbon / float64(len(metrics))
+}
+
+// IdleWindow описывает интервал, в течение которого сервер простаивал
+type IdleWindow struct {
+	Start   time.Time
+	End     time.Time
+	AvgCPU  float64
+}
+
+// FindIdleWindows находит интервалы непрерывного простоя сервера:
+// загрузка CPU и GPU ниже cpuThreshold на протяжении не менее minDuration
+func (a *Analyzer) FindIdleWindows(serverID string, cpuThreshold float64, minDuration time.Duration) ([]IdleWindow, error) {
+	metrics, err := a.recentMetrics(serverID)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(metrics) < a.config.MinDataPoints {
+		return nil, fmt.Errorf("insufficient data points for analysis")
+	}
+
+	var windows []IdleWindow
+	start := -1
+	var cpuSum float64
+
+	closeWindow := func(end int) {
+		if start < 0 {
+			return
+		}
+		from := time.Unix(metrics[start].Timestamp, 0)
+		to := time.Unix(metrics[end].Timestamp, 0)
+		if to.Sub(from) >= minDuration {
+			windows = append(windows, IdleWindow{
+				Start:  from,
+				End:    to,
+				AvgCPU: cpuSum / float64(end-start+1),
+			})
+		}
+		start = -1
+		cpuSum = 0
+	}
+
+	for i, m := range metrics {
+		// Занятый GPU при свободном CPU - типичное обучение модели, а не простой
+		if m.CPUUsage < cpuThreshold && m.GPUUsage < cpuThreshold {
+			if start < 0 {
+				start = i
+			}
+			cpuSum += m.CPUUsage
+			continue
+		}
+		closeWindow(i - 1)
+	}
+	closeWindow(len(metrics) - 1)
+
+	return windows, nil
+}
@@ -0,0 +1,163 @@
+package metrics
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/YumeNoTenshi/platypus/internal/models"
+)
+
+// AnomalyDetector ищет аномалии измерения в точках сервера. baseline -
+// среднее и разброс сервера, при короткой истории смешанные с когортой.
+// Свой детектор подключается через AnalyzerConfig.AnomalyDetector.
+type AnomalyDetector interface {
+	Detect(metrics []models.MetricData, dimension Dimension, baseline Baseline) []Anomaly
+}
+
+// AnomalyMethod - встроенный способ поиска аномалий
+type AnomalyMethod string
+
+const (
+	// AnomalyZScore сравнивает каждую точку со средним и отклонением всего
+	// окна: аномалии сами смещают среднее, а медленный дрейф растворяется в нем
+	AnomalyZScore AnomalyMethod = "zscore"
+	// AnomalyEWMA сравнивает точку с экспоненциально сглаженным уровнем
+	// предыдущих точек и ищет дрейф контрольной картой EWMA
+	AnomalyEWMA AnomalyMethod = "ewma"
+	// AnomalyMAD сравнивает точку с медианой окна в единицах медианного
+	// абсолютного отклонения: единичный выброс не расширяет норму и не
+	// прячет следующие аномалии
+	AnomalyMAD AnomalyMethod = "mad"
+)
+
+// ParseAnomalyMethod разбирает способ поиска аномалий; пустая строка - zscore
+func ParseAnomalyMethod(value string) (AnomalyMethod, error) {
+	switch method := AnomalyMethod(value); method {
+	case "":
+		return AnomalyZScore, nil
+	case AnomalyZScore, AnomalyEWMA, AnomalyMAD:
+		return method, nil
+	}
+	return "", fmt.Errorf("unknown anomaly method %q: use zscore, ewma or mad", value)
+}
+
+// newAnomalyDetector возвращает встроенный детектор способа из конфигурации
+func newAnomalyDetector(config AnalyzerConfig) AnomalyDetector {
+	switch config.AnomalyMethod {
+	case AnomalyEWMA:
+		lambda := config.SmoothingFactor
+		if lambda <= 0 || lambda >= 1 {
+			lambda = defaultEWMALambda
+		}
+		return ewmaDetector{lambda: lambda, threshold: config.AnomalyThreshold}
+	case AnomalyMAD:
+		return madDetector{threshold: config.AnomalyThreshold}
+	}
+	return zScoreDetector{threshold: config.AnomalyThreshold}
+}
+
+// zScoreDetector отмечает точки дальше threshold отклонений от среднего
+type zScoreDetector struct {
+	threshold float64
+}
+
+func (d zScoreDetector) Detect(metrics []models.MetricData, dimension Dimension, baseline Baseline) []Anomaly {
+	var anomalies []Anomaly
+	for _, m := range metrics {
+		// Восстановленные точки не могут быть аномалией
+		if m.Interpolated {
+			continue
+		}
+		value := dimension.Value(m)
+		zScore := math.Abs(value-baseline.Mean) / baseline.StdDev
+		if zScore > d.threshold {
+			anomalies = append(anomalies, Anomaly{
+				Timestamp: time.Unix(m.Timestamp, 0),
+				Value:     value,
+				Type:      classifyAnomaly(value, baseline.Mean),
+				Severity:  zScore,
+			})
+		}
+	}
+	return anomalies
+}
+
+const (
+	// madScale переводит медианное абсолютное отклонение в оценку σ
+	// нормального распределения
+	madScale = 1.4826
+	// meanDeviationScale - то же для среднего абсолютного отклонения, когда
+	// больше половины точек равны медиане и MAD нулевое
+	meanDeviationScale = 1.2533
+)
+
+// madDetector отмечает точки дальше threshold робастных σ от медианы
+// (модифицированная z-оценка, Iglewicz и Hoaglin). Медиана и MAD считаются
+// по собственным точкам сервера; пока его история смешивается с когортой,
+// у когорты есть только среднее и разброс, и используются они.
+type madDetector struct {
+	threshold float64
+}
+
+func (d madDetector) Detect(metrics []models.MetricData, dimension Dimension, baseline Baseline) []Anomaly {
+	var values []float64
+	for _, m := range metrics {
+		if !m.Interpolated {
+			values = append(values, dimension.Value(m))
+		}
+	}
+	if len(values) == 0 {
+		return nil
+	}
+
+	center, sigma := baseline.Mean, baseline.StdDev
+	if baseline.Source != BaselineBlended {
+		center, sigma = robustSpread(values)
+	}
+	sigma = math.Max(sigma, math.Max(math.Abs(center)*minRelativeSigma, 1e-9))
+
+	var anomalies []Anomaly
+	for _, m := range metrics {
+		if m.Interpolated {
+			continue
+		}
+		value := dimension.Value(m)
+		if severity := math.Abs(value-center) / sigma; severity > d.threshold {
+			anomalies = append(anomalies, Anomaly{
+				Timestamp: time.Unix(m.Timestamp, 0),
+				Value:     value,
+				Type:      classifyAnomaly(value, center),
+				Severity:  severity,
+			})
+		}
+	}
+	return anomalies
+}
+
+// robustSpread возвращает медиану и робастную оценку σ
+func robustSpread(values []float64) (center, sigma float64) {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	center = percentile(sorted, 0.5)
+
+	deviations := make([]float64, len(sorted))
+	var total float64
+	for i, v := range sorted {
+		deviations[i] = math.Abs(v - center)
+		total += deviations[i]
+	}
+	sort.Float64s(deviations)
+	if mad := percentile(deviations, 0.5); mad > 0 {
+		return center, mad * madScale
+	}
+	return center, total / float64(len(values)) * meanDeviationScale
+}
+
+func classifyAnomaly(value, mean float64) string {
+	if value > mean {
+		return "spike"
+	}
+	return "drop"
+}
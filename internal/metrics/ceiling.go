@@ -0,0 +1,363 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/YumeNoTenshi/platypus/internal/models"
+	"github.com/YumeNoTenshi/platypus/internal/scheduler"
+)
+
+// CeilingPolicy - что делать со старыми точками при приближении к потолку памяти
+type CeilingPolicy string
+
+const (
+	// CeilingDownsample усредняет старые точки все более крупными интервалами
+	CeilingDownsample CeilingPolicy = "downsample"
+	// CeilingSpill вытесняет старые точки серверов на диск (MemoryCeiling.Spill);
+	// точки контейнеров усредняются, как при CeilingDownsample
+	CeilingSpill CeilingPolicy = "spill"
+)
+
+const (
+	defaultHighWatermark   = 0.9
+	defaultLowWatermark    = 0.75
+	defaultCeilingInterval = 15 * time.Second
+	// ceilingLevels - сколько раз окно нетронутых точек сокращается вдвое
+	// (R/2, R/4, ... от срока хранения R), прежде чем точки удаляются
+	ceilingLevels = 4
+)
+
+// ceilingResolutions - интервалы усреднения на уровнях потолка: чем старше
+// точки, тем крупнее интервал
+var ceilingResolutions = []time.Duration{5 * time.Minute, 15 * time.Minute, time.Hour, 6 * time.Hour}
+
+// MemoryCeiling ограничивает память хранилищ точек в памяти процесса
+// (MemoryStore серверов и контейнеров). Когда оценка памяти доходит до
+// HighWatermark от Limit, старые точки усредняются или вытесняются на диск,
+// пока она не опустится до LowWatermark. Если этого не хватает, самые старые
+// точки удаляются из памяти: потолок соблюдается ценой истории.
+type MemoryCeiling struct {
+	Limit         int64 // Байт
+	Policy        CeilingPolicy
+	Spill         Store   // Хранилище вытеснения для CeilingSpill, например DiskStore
+	HighWatermark float64 // Доля Limit, с которой начинается разгрузка; по умолчанию 0.9
+	LowWatermark  float64 // Доля Limit, до которой идет разгрузка; по умолчанию 0.75
+	Interval      time.Duration
+}
+
+// MemoryStats - учет памяти хранилищ точек для /status
+type MemoryStats struct {
+	Bytes         int64         `json:"bytes"`
+	Limit         int64         `json:"limit,omitempty"`
+	Policy        CeilingPolicy `json:"policy,omitempty"`
+	Servers       MemoryUsage   `json:"servers"`
+	Containers    MemoryUsage   `json:"containers"`
+	Triggered     int           `json:"triggered"` // Сколько раз память доходила до порога
+	LastTriggered time.Time     `json:"last_triggered,omitempty"`
+	Downsampled   uint64        `json:"downsampled"` // Точек, поглощенных усреднением
+	Spilled       uint64        `json:"spilled"`     // Точек, вытесненных на диск
+	Evicted       uint64        `json:"evicted"`     // Точек, удаленных из памяти без сохранения
+	LastError     string        `json:"last_error,omitempty"`
+}
+
+type memoryCeiling struct {
+	config  MemoryCeiling
+	actions *prometheus.CounterVec
+
+	mu    sync.Mutex
+	stats MemoryStats
+}
+
+func newMemoryCeiling(config MemoryCeiling) (*memoryCeiling, error) {
+	if config.Limit <= 0 {
+		return nil, fmt.Errorf("memory limit must be positive")
+	}
+	switch config.Policy {
+	case "":
+		config.Policy = CeilingDownsample
+	case CeilingDownsample:
+	case CeilingSpill:
+		if config.Spill == nil {
+			return nil, fmt.Errorf("spill policy requires a spill store")
+		}
+	default:
+		return nil, fmt.Errorf("unknown memory ceiling policy %q", config.Policy)
+	}
+	if config.HighWatermark <= 0 || config.HighWatermark > 1 {
+		config.HighWatermark = defaultHighWatermark
+	}
+	if config.LowWatermark <= 0 || config.LowWatermark >= config.HighWatermark {
+		config.LowWatermark = min(defaultLowWatermark, config.HighWatermark*0.8)
+	}
+	if config.Interval <= 0 {
+		config.Interval = defaultCeilingInterval
+	}
+	return &memoryCeiling{
+		config: config,
+		actions: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "platypus_metric_store_ceiling_points_total",
+			Help: "Points downsampled, spilled to disk or evicted to keep the metric store under its memory ceiling",
+		}, []string{"action"}),
+		stats: MemoryStats{Limit: config.Limit, Policy: config.Policy},
+	}, nil
+}
+
+func (m *memoryCeiling) count(action string, points int) {
+	if points <= 0 {
+		return
+	}
+	m.actions.WithLabelValues(action).Add(float64(points))
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	switch action {
+	case "downsampled":
+		m.stats.Downsampled += uint64(points)
+	case "spilled":
+		m.stats.Spilled += uint64(points)
+	case "evicted":
+		m.stats.Evicted += uint64(points)
+	}
+}
+
+// memoryStores возвращает хранилища в памяти процесса: внешние хранилища
+// памятью сервера не ограничиваются
+func (c *Collector) memoryStores() (servers, containers *MemoryStore) {
+	servers, _ = c.store.(*MemoryStore)
+	containers, _ = c.containers.(*MemoryStore)
+	return servers, containers
+}
+
+// MemoryStats возвращает учет памяти хранилищ точек
+func (c *Collector) MemoryStats() MemoryStats {
+	var stats MemoryStats
+	if c.ceiling != nil {
+		c.ceiling.mu.Lock()
+		stats = c.ceiling.stats
+		c.ceiling.mu.Unlock()
+	}
+	servers, containers := c.memoryStores()
+	if servers != nil {
+		stats.Servers = servers.Usage()
+	}
+	if containers != nil {
+		stats.Containers = containers.Usage()
+	}
+	stats.Bytes = stats.Servers.Bytes + stats.Containers.Bytes
+	return stats
+}
+
+func (c *Collector) memoryBytes() int64 {
+	var bytes int64
+	servers, containers := c.memoryStores()
+	if servers != nil {
+		bytes += servers.Usage().Bytes
+	}
+	if containers != nil {
+		bytes += containers.Usage().Bytes
+	}
+	return bytes
+}
+
+// initMemoryMetrics регистрирует self-метрики учета памяти хранилищ
+func (c *Collector) initMemoryMetrics() {
+	servers, containers := c.memoryStores()
+	for name, store := range map[string]*MemoryStore{"servers": servers, "containers": containers} {
+		if store == nil {
+			continue
+		}
+		store := store
+		labels := prometheus.Labels{"store": name}
+		c.config.Registerer.MustRegister(
+			prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+				Name:        "platypus_metric_store_memory_bytes",
+				Help:        "Estimated memory held by the in-memory metric store",
+				ConstLabels: labels,
+			}, func() float64 { return float64(store.Usage().Bytes) }),
+			prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+				Name:        "platypus_metric_store_points",
+				Help:        "Points held by the in-memory metric store",
+				ConstLabels: labels,
+			}, func() float64 { return float64(store.Usage().Points) }),
+		)
+	}
+	if c.ceiling != nil {
+		limit := float64(c.ceiling.config.Limit)
+		c.config.Registerer.MustRegister(
+			prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+				Name: "platypus_metric_store_memory_limit_bytes",
+				Help: "Memory ceiling of the in-memory metric stores",
+			}, func() float64 { return limit }),
+			c.ceiling.actions,
+		)
+	}
+}
+
+// ceilingJob - периодическая проверка потолка памяти
+func (c *Collector) ceilingJob() scheduler.Job {
+	return scheduler.Job{
+		Name:     "collector.memory_ceiling",
+		Interval: c.ceiling.config.Interval,
+		Run: func(ctx context.Context) error {
+			return c.EnforceMemoryCeiling(ctx, time.Now())
+		},
+	}
+}
+
+// EnforceMemoryCeiling разгружает хранилища, если память дошла до верхнего
+// порога. Сначала окно нетронутых точек сокращается вдвое за шаг: старые
+// точки усредняются или вытесняются на диск; затем, если этого не хватило,
+// так же удаляются самые старые точки.
+func (c *Collector) EnforceMemoryCeiling(ctx context.Context, now time.Time) error {
+	if c.ceiling == nil {
+		return nil
+	}
+	config := c.ceiling.config
+	usage := c.memoryBytes()
+	if float64(usage) < config.HighWatermark*float64(config.Limit) {
+		return nil
+	}
+	target := int64(config.LowWatermark * float64(config.Limit))
+
+	c.ceiling.mu.Lock()
+	c.ceiling.stats.Triggered++
+	c.ceiling.stats.LastTriggered = now
+	c.ceiling.mu.Unlock()
+
+	retention := c.config.LongestRetention()
+	if retention <= 0 {
+		retention = 7 * 24 * time.Hour
+	}
+	servers, containers := c.memoryStores()
+	var failures []error
+
+	for level := 1; level <= ceilingLevels && usage > target; level++ {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if config.Policy == CeilingSpill && servers != nil {
+			moved, err := servers.SpillBefore(now.Add(-retention >> level))
+			c.ceiling.count("spilled", moved)
+			if err != nil {
+				failures = append(failures, err)
+			}
+		} else if servers != nil {
+			failures = append(failures, c.downsample(servers, retention, level, now)...)
+		}
+		if containers != nil {
+			failures = append(failures, c.downsample(containers, retention, level, now)...)
+		}
+		usage = c.memoryBytes()
+	}
+
+	for level := 1; level <= ceilingLevels && usage > target; level++ {
+		cutoff := now.Add(-retention >> level)
+		for _, store := range []*MemoryStore{servers, containers} {
+			if store != nil {
+				c.ceiling.count("evicted", store.EvictBefore(cutoff))
+			}
+		}
+		usage = c.memoryBytes()
+	}
+	if usage > target {
+		failures = append(failures, fmt.Errorf("metric store still uses %d bytes after evicting all but the last %s", usage, retention>>ceilingLevels))
+	}
+
+	err := errors.Join(failures...)
+	c.ceiling.mu.Lock()
+	c.ceiling.stats.LastError = ""
+	if err != nil {
+		c.ceiling.stats.LastError = err.Error()
+	}
+	c.ceiling.mu.Unlock()
+	return err
+}
+
+// downsample усредняет точки хранилища уровнями level: точки старше R/2^j
+// (j = 1..level) - интервалами тем крупнее, чем старше точки
+func (c *Collector) downsample(store *MemoryStore, retention time.Duration, level int, now time.Time) []error {
+	tiers := make([]RollupTier, 0, level)
+	for j := 1; j <= level; j++ {
+		tiers = append(tiers, RollupTier{After: retention >> j, Resolution: ceilingResolutions[level-j]})
+	}
+	// RollupMetrics ожидает уровни по возрастанию After
+	for i, k := 0, len(tiers)-1; i < k; i, k = i+1, k-1 {
+		tiers[i], tiers[k] = tiers[k], tiers[i]
+	}
+
+	before := rollupCutoff(tiers[0], now)
+	ids, err := store.ServerIDs()
+	if err != nil {
+		return []error{err}
+	}
+	var failures []error
+	for _, serverID := range ids {
+		err := store.Compact(serverID, before, func(old []models.MetricData) []models.MetricData {
+			rolled := RollupMetrics(old, tiers, now)
+			c.ceiling.count("downsampled", len(old)-len(rolled))
+			return rolled
+		})
+		if err != nil {
+			failures = append(failures, fmt.Errorf("server %s: %w", serverID, err))
+		}
+	}
+	return failures
+}
+
+// ParseByteSize разбирает размер вида 512MiB, 2GiB, 1.5GB или число байт
+func ParseByteSize(value string) (int64, error) {
+	value = strings.TrimSpace(value)
+	units := []struct {
+		suffix     string
+		multiplier float64
+	}{
+		{"KiB", 1 << 10}, {"MiB", 1 << 20}, {"GiB", 1 << 30}, {"TiB", 1 << 40},
+		{"KB", 1e3}, {"MB", 1e6}, {"GB", 1e9}, {"TB", 1e12},
+		{"B", 1},
+	}
+	multiplier := 1.0
+	for _, unit := range units {
+		if number, found := strings.CutSuffix(value, unit.suffix); found {
+			value, multiplier = strings.TrimSpace(number), unit.multiplier
+			break
+		}
+	}
+	number, err := strconv.ParseFloat(value, 64)
+	if err != nil || number < 0 {
+		return 0, fmt.Errorf("invalid size %q", value)
+	}
+	return int64(number * multiplier), nil
+}
+
+// initCeiling включает потолок памяти. Ошибка настройки не останавливает
+// сборщик: хранилища работают без потолка.
+func (c *Collector) initCeiling(config MemoryCeiling) {
+	ceiling, err := newMemoryCeiling(config)
+	if err != nil {
+		log.Printf("Потолок памяти хранилища точек не действует: %v", err)
+		return
+	}
+	servers, containers := c.memoryStores()
+	if servers == nil && containers == nil {
+		log.Printf("Потолок памяти не действует: точки хранятся во внешнем хранилище")
+		return
+	}
+	if ceiling.config.Policy == CeilingSpill {
+		if servers == nil {
+			ceiling.config.Policy = CeilingDownsample
+		} else if err := servers.SetSpill(ceiling.config.Spill); err != nil {
+			log.Printf("Хранилище вытеснения недоступно, старые точки будут усредняться: %v", err)
+			ceiling.config.Policy = CeilingDownsample
+		}
+		ceiling.stats.Policy = ceiling.config.Policy
+	}
+	c.ceiling = ceiling
+}
@@ -0,0 +1,181 @@
+package metrics
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/YumeNoTenshi/platypus/internal/models"
+)
+
+// Источник базовой линии, от которой считаются отклонения при поиске аномалий
+const (
+	BaselineOwn     = "own"     // Собственная история сервера
+	BaselineBlended = "blended" // Смесь своей истории и когорты похожих серверов
+)
+
+const (
+	// cohortTTL - как долго базовые линии когорт используются без пересчета
+	cohortTTL = 5 * time.Minute
+	// minCohortMembers - меньше серверов с историей не дают когорте
+	// базовую линию: один сервер не отличить от выброса
+	minCohortMembers = 2
+	// cohortServiceLabel - метка точки с именем сервиса
+	cohortServiceLabel = "service"
+)
+
+// Baseline - среднее и разброс измерения, от которых считается z-оценка
+type Baseline struct {
+	Mean       float64 `json:"mean"`
+	StdDev     float64 `json:"std_dev"`
+	Source     string  `json:"source"`
+	Cohort     string  `json:"cohort,omitempty"`
+	OwnWeight  float64 `json:"own_weight"` // Доля собственной истории (0-1)
+	OwnPoints  int     `json:"own_points"`
+	CohortSize int     `json:"cohort_size,omitempty"`
+}
+
+// CohortBaseline - базовая линия когорты серверов одного типа инстанса и
+// сервиса, собранная по серверам с собственной историей
+type CohortBaseline struct {
+	Cohort     string    `json:"cohort"`
+	Mean       float64   `json:"mean"`
+	StdDev     float64   `json:"std_dev"`
+	Members    int       `json:"members"`
+	ComputedAt time.Time `json:"computed_at"`
+}
+
+// cohortKey - когорта сервера: тип инстанса и сервис (метка service
+// последней точки). Пусто - у сервера нет признаков для сравнения.
+func (a *Analyzer) cohortKey(serverID string, metrics []models.MetricData) string {
+	instanceType, _ := a.InstanceType(serverID)
+	service := ""
+	if len(metrics) > 0 {
+		service = metrics[len(metrics)-1].Labels[cohortServiceLabel]
+	}
+	if instanceType == "" && service == "" {
+		return ""
+	}
+	return instanceType + "/" + service
+}
+
+// baseline возвращает базовую линию сервера. Пока у сервера меньше
+// OwnBaselinePoints точек, его статистика смешивается с базовой линией
+// когорты пропорционально числу точек: новый сервер наследует поведение
+// похожих и не получает ложных аномалий, пока копит историю. ok = false -
+// своих точек меньше MinDataPoints, а когорты нет.
+func (a *Analyzer) baseline(serverID string, metrics []models.MetricData, dimension Dimension) (Baseline, bool) {
+	own := Baseline{Source: BaselineOwn, OwnWeight: 1, OwnPoints: len(metrics)}
+	if len(metrics) > 0 {
+		own.Mean = a.calculateMean(metrics, dimension)
+		own.StdDev = a.calculateStdDev(metrics, dimension, own.Mean)
+	}
+	enough := len(metrics) >= a.config.MinDataPoints && len(metrics) > 0
+
+	if a.config.OwnBaselinePoints <= 0 || len(metrics) >= a.config.OwnBaselinePoints {
+		return own, enough
+	}
+	key := a.cohortKey(serverID, metrics)
+	if key == "" {
+		return own, enough
+	}
+	cohort, exists := a.cohortBaselines(dimension)[key]
+	if !exists {
+		return own, enough
+	}
+
+	weight := float64(len(metrics)) / float64(a.config.OwnBaselinePoints)
+	mean := weight*own.Mean + (1-weight)*cohort.Mean
+	// Дисперсия смеси: разброс внутри частей и расхождение их средних
+	variance := weight*(own.StdDev*own.StdDev+math.Pow(own.Mean-mean, 2)) +
+		(1-weight)*(cohort.StdDev*cohort.StdDev+math.Pow(cohort.Mean-mean, 2))
+	return Baseline{
+		Mean:       mean,
+		StdDev:     math.Sqrt(variance),
+		Source:     BaselineBlended,
+		Cohort:     key,
+		OwnWeight:  weight,
+		OwnPoints:  len(metrics),
+		CohortSize: cohort.Members,
+	}, true
+}
+
+// cohortBaselines возвращает базовые линии когорт по измерению, пересчитывая
+// их не чаще раза в cohortTTL
+func (a *Analyzer) cohortBaselines(dimension Dimension) map[string]CohortBaseline {
+	a.mu.RLock()
+	cached, computedAt := a.cohorts[dimension], a.cohortsAt[dimension]
+	a.mu.RUnlock()
+	if cached != nil && time.Since(computedAt) < cohortTTL {
+		return cached
+	}
+
+	now := time.Now()
+	cohorts := a.computeCohorts(dimension, now)
+	a.mu.Lock()
+	a.cohorts[dimension], a.cohortsAt[dimension] = cohorts, now
+	a.mu.Unlock()
+	return cohorts
+}
+
+// computeCohorts собирает базовые линии когорт по серверам, у которых уже
+// есть OwnBaselinePoints точек. Когорта взвешивает серверы поровну:
+// среднее - среднее их средних, дисперсия - средняя дисперсия плюс разброс
+// средних между серверами.
+func (a *Analyzer) computeCohorts(dimension Dimension, now time.Time) map[string]CohortBaseline {
+	type member struct{ mean, variance float64 }
+	members := make(map[string][]member)
+	for _, serverID := range a.source.ServerIDs() {
+		metrics, err := a.recentMetrics(serverID)
+		if err != nil || len(metrics) == 0 || len(metrics) < a.config.OwnBaselinePoints {
+			continue
+		}
+		key := a.cohortKey(serverID, metrics)
+		if key == "" {
+			continue
+		}
+		mean := a.calculateMean(metrics, dimension)
+		stdDev := a.calculateStdDev(metrics, dimension, mean)
+		members[key] = append(members[key], member{mean: mean, variance: stdDev * stdDev})
+	}
+
+	cohorts := make(map[string]CohortBaseline, len(members))
+	for key, group := range members {
+		if len(group) < minCohortMembers {
+			continue
+		}
+		var mean, variance float64
+		for _, m := range group {
+			mean += m.mean
+			variance += m.variance
+		}
+		n := float64(len(group))
+		mean /= n
+		variance /= n
+		for _, m := range group {
+			variance += math.Pow(m.mean-mean, 2) / n
+		}
+		cohorts[key] = CohortBaseline{
+			Cohort:     key,
+			Mean:       mean,
+			StdDev:     math.Sqrt(variance),
+			Members:    len(group),
+			ComputedAt: now,
+		}
+	}
+	return cohorts
+}
+
+// Cohorts возвращает базовые линии потребления когорт по имени когорты
+func (a *Analyzer) Cohorts() []CohortBaseline {
+	if a.config.OwnBaselinePoints <= 0 {
+		return []CohortBaseline{}
+	}
+	cohorts := a.cohortBaselines(DimensionPower)
+	result := make([]CohortBaseline, 0, len(cohorts))
+	for _, cohort := range cohorts {
+		result = append(result, cohort)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Cohort < result[j].Cohort })
+	return result
+}
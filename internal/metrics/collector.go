@@ -2,65 +2,197 @@ package metrics
 
 import (
     "context"
+    "errors"
     "fmt"
+    "log"
+    "sort"
+    "strings"
     "sync"
     "time"
     
     "github.com/prometheus/client_golang/prometheus"
+    "github.com/YumeNoTenshi/platypus/internal/errtrack"
     "github.com/YumeNoTenshi/platypus/internal/models"
+    "github.com/YumeNoTenshi/platypus/internal/scheduler"
+    "github.com/YumeNoTenshi/platypus/internal/supervisor"
 )
 
+// ErrBufferFull - буфер приема заполнен; точку можно прислать позже
+var ErrBufferFull = errors.New("metric buffer is full")
+
 type CollectorConfig struct {
     RetentionPeriod   time.Duration
+    // MetricRetention - сроки хранения отдельных метрик по именам полей
+    // точки (power_usage, carbon_footprint, cpu_usage, ...), если они
+    // отличаются от RetentionPeriod. Точка хранится LongestRetention, а поля
+    // с более коротким сроком в ней очищаются.
+    MetricRetention   map[string]time.Duration
     CollectionInterval time.Duration
     BatchSize         int
     BufferSize        int
+    Filter            FilterConfig // Фильтрация шума при чтении через GetFilteredMetrics
+    Store             Store        // Хранилище точек; по умолчанию в памяти процесса
+    // ContainerStore хранит точки контейнеров; ключ серии (MetricBatch.ServerID) -
+    // идентификатор контейнера. По умолчанию в памяти процесса.
+    ContainerStore    Store
+    // WAL - необязательный журнал упреждающей записи: пакеты из буфера,
+    // не обработанные до падения процесса, обрабатываются при запуске
+    WAL               *WAL
+    // PointsPerServer - сколько последних точек каждой серии хранится в
+    // памяти (MemoryStore); по умолчанию LongestRetention/CollectionInterval
+    PointsPerServer   int
+    // MetricLabels - метки точек (MetricData.Labels), которые становятся
+    // метками серий Prometheus; по умолчанию DefaultMetricLabels. Список
+    // фиксирован: каждое значение метки создает отдельную серию.
+    MetricLabels      []string
+    // OTLP - необязательная отправка датчиков в OpenTelemetry Collector
+    // наряду с регистрацией в Prometheus
+    OTLP              *OTLPConfig
+    // MemoryCeiling - необязательный потолок памяти хранилищ в памяти процесса
+    MemoryCeiling     *MemoryCeiling
+    // Snapshot - необязательные периодические снимки точек в памяти на
+    // диск; при запуске точки восстанавливаются из последнего снимка
+    Snapshot          *SnapshotConfig
+    // Registerer - реестр Prometheus для датчиков и счетчиков коллектора;
+    // по умолчанию глобальный prometheus.DefaultRegisterer. Отдельный реестр
+    // нужен тестам и программам, встраивающим platypus: повторная
+    // регистрация в глобальном реестре вызывает панику.
+    Registerer        prometheus.Registerer
+    // PowerBuckets и CPUBuckets - границы корзин гистограмм потребления (Вт)
+    // и загрузки CPU (%); по умолчанию DefaultPowerBuckets и DefaultCPUBuckets
+    PowerBuckets      []float64
+    CPUBuckets        []float64
 }
 
 type Collector struct {
     config  CollectorConfig
-    metrics map[string]*ServerMetrics
+    store   Store
+    containers Store
     buffer  chan MetricBatch
+    walMu   sync.Mutex // Порядок записей журнала совпадает с порядком в буфере
     mu      sync.RWMutex
+    units   *UnitRegistry
+    counters *counterTracker
+    tenants  *tenantOwners
+    listeners []func(MetricBatch) // Вызываются для каждого сохраненного пакета (например, репликация)
+    errors    errtrack.Recorder       // Необязательный учет ошибок фонового приема
+    panics    func(err error)         // Необязательный учет паник при обработке пакетов
+
+    metricLabels []string          // Метки точек, экспортируемые в Prometheus
+    exportMu     sync.Mutex
+    exported     map[string]string // Сервер -> значения меток его текущих серий Prometheus
 
     // Prometheus метрики
     powerUsageGauge    *prometheus.GaugeVec
     carbonFootprintGauge *prometheus.GaugeVec
     cpuUsageGauge      *prometheus.GaugeVec
     memoryUsageGauge   *prometheus.GaugeVec
+    gpuUsageGauge      *prometheus.GaugeVec
+    gpuPowerUsageGauge *prometheus.GaugeVec
+    diskReadGauge      *prometheus.GaugeVec
+    diskWriteGauge     *prometheus.GaugeVec
+    storageUsedGauge   *prometheus.GaugeVec
+    inletTempGauge     *prometheus.GaugeVec
+    distributions      *distributions // Гистограммы потребления и загрузки CPU
+
+    otlp *otlpExporter // Необязательная отправка датчиков по OTLP
+    ceiling *memoryCeiling // Необязательный потолок памяти хранилищ
+    snapshots *snapshotter // Необязательные снимки точек на диск
+    rejections *rejectionCounter // Точки, отклоненные проверкой перед сохранением
+
+    subsMu        sync.RWMutex
+    subscriptions map[*Subscription]struct{} // Подписки на новые точки (Subscribe)
+
+    lifecycle *containerLifecycle // События запуска, остановки и переноса контейнеров
 }
 
 type ServerMetrics struct {
-    Data      []models.MetricData
+    points     *ring // Последние PointsPerServer точек
     LastUpdate time.Time
+    spilled    bool // Часть точек вытеснена в хранилище вытеснения MemoryStore
 }
 
 type MetricBatch struct {
     ServerID string
     Metrics  []models.MetricData
     Timestamp time.Time
+
+    walSeq uint64 // Номер записи журнала; 0 - пакет не журналируется
 }
 
 func NewCollector(config CollectorConfig) *Collector {
+    store := config.Store
+    if config.PointsPerServer <= 0 {
+        config.PointsPerServer = defaultPointsPerServer
+        if retention := config.LongestRetention(); retention > 0 && config.CollectionInterval > 0 {
+            config.PointsPerServer = int(retention / config.CollectionInterval)
+        }
+    }
+    if store == nil {
+        store = NewMemoryStore(config.PointsPerServer)
+    }
+    containers := config.ContainerStore
+    if containers == nil {
+        containers = NewMemoryStore(config.PointsPerServer)
+    }
+
+    if config.MetricLabels == nil {
+        config.MetricLabels = DefaultMetricLabels
+    }
+    if config.Registerer == nil {
+        config.Registerer = prometheus.DefaultRegisterer
+    }
+    metricLabels, err := exportLabels(config.MetricLabels)
+    if err != nil {
+        log.Printf("Метки точек не экспортируются в Prometheus: %v", err)
+    }
+
     c := &Collector{
         config:  config,
-        metrics: make(map[string]*ServerMetrics),
+        store:   store,
+        containers: containers,
         buffer:  make(chan MetricBatch, config.BufferSize),
+        units:   NewUnitRegistry(),
+        counters: newCounterTracker(),
+        tenants:  newTenantOwners(),
+        metricLabels: metricLabels,
+        exported: make(map[string]string),
+        rejections: newRejectionCounter(),
+        subscriptions: make(map[*Subscription]struct{}),
+        lifecycle: newContainerLifecycle(),
+    }
+
+    if config.MemoryCeiling != nil {
+        c.initCeiling(*config.MemoryCeiling)
+    }
+    if config.Snapshot != nil {
+        c.initSnapshots(*config.Snapshot)
     }
 
     // Инициализация Prometheus метрик
     c.initPrometheusMetrics()
+    c.initMemoryMetrics()
+
+    if config.OTLP != nil {
+        exporter, err := newOTLPExporter(*config.OTLP, c.gauges())
+        if err != nil {
+            log.Printf("Датчики не отправляются по OTLP: %v", err)
+        }
+        c.otlp = exporter
+    }
 
     return c
 }
 
 func (c *Collector) initPrometheusMetrics() {
+    labelNames := append([]string{"server_id", "region"}, c.metricLabels...)
+
     c.powerUsageGauge = prometheus.NewGaugeVec(
         prometheus.GaugeOpts{
             Name: "server_power_usage_watts",
             Help: "Current power usage in watts",
         },
-        []string{"server_id", "region"},
+        labelNames,
     )
 
     c.carbonFootprintGauge = prometheus.NewGaugeVec(
@@ -68,7 +200,7 @@ func (c *Collector) initPrometheusMetrics() {
             Name: "server_carbon_footprint_kg",
             Help: "Current carbon footprint in kg CO2",
         },
-        []string{"server_id", "region"},
+        labelNames,
     )
 
     c.cpuUsageGauge = prometheus.NewGaugeVec(
@@ -76,7 +208,7 @@ func (c *Collector) initPrometheusMetrics() {
             Name: "server_cpu_usage_percent",
             Help: "Current CPU usage percentage",
         },
-        []string{"server_id", "region"},
+        labelNames,
     )
 
     c.memoryUsageGauge = prometheus.NewGaugeVec(
@@ -84,111 +216,668 @@ func (c *Collector) initPrometheusMetrics() {
             Name: "server_memory_usage_percent",
             Help: "Current memory usage percentage",
         },
-        []string{"server_id", "region"},
+        labelNames,
     )
 
-    // Регистрация метрик в Prometheus
-    prometheus.MustRegister(
-        c.powerUsageGauge,
-        c.carbonFootprintGauge,
-        c.cpuUsageGauge,
-        c.memoryUsageGauge,
+    c.gpuUsageGauge = prometheus.NewGaugeVec(
+        prometheus.GaugeOpts{
+            Name: "server_gpu_usage_percent",
+            Help: "Current GPU usage percentage averaged over all GPUs",
+        },
+        labelNames,
     )
+
+    c.gpuPowerUsageGauge = prometheus.NewGaugeVec(
+        prometheus.GaugeOpts{
+            Name: "server_gpu_power_usage_watts",
+            Help: "Current GPU power usage in watts, included in server power usage",
+        },
+        labelNames,
+    )
+
+    c.diskReadGauge = prometheus.NewGaugeVec(
+        prometheus.GaugeOpts{
+            Name: "server_disk_read_bytes_per_second",
+            Help: "Current disk read throughput in bytes per second",
+        },
+        labelNames,
+    )
+
+    c.diskWriteGauge = prometheus.NewGaugeVec(
+        prometheus.GaugeOpts{
+            Name: "server_disk_write_bytes_per_second",
+            Help: "Current disk write throughput in bytes per second",
+        },
+        labelNames,
+    )
+
+    c.storageUsedGauge = prometheus.NewGaugeVec(
+        prometheus.GaugeOpts{
+            Name: "server_storage_used_bytes",
+            Help: "Storage used on attached volumes in bytes",
+        },
+        labelNames,
+    )
+
+    c.inletTempGauge = prometheus.NewGaugeVec(
+        prometheus.GaugeOpts{
+            Name: "server_inlet_temperature_celsius",
+            Help: "Current inlet air temperature in degrees Celsius",
+        },
+        labelNames,
+    )
+
+    // Регистрация метрик в Prometheus
+    for _, gauge := range c.gauges() {
+        c.config.Registerer.MustRegister(gauge)
+    }
+    c.distributions = newDistributions(c.config, c.metricLabels)
+    c.config.Registerer.MustRegister(c.distributions.collectors()...)
+    c.config.Registerer.MustRegister(c.rejections.total)
+}
+
+// SetErrorRecorder включает учет ошибок фонового приема метрик
+func (c *Collector) SetErrorRecorder(recorder errtrack.Recorder) {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    c.errors = recorder
+}
+
+// SetPanicHandler задает получателя паник при обработке пакетов
+// (*supervisor.PanicError). Паника не останавливает прием: пакет
+// пропускается, как при ошибке хранилища.
+func (c *Collector) SetPanicHandler(handler func(err error)) {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    c.panics = handler
 }
 
+// Start запускает обработчик буфера метрик. Очистка устаревших метрик
+// выполняется планировщиком, см. Jobs. Если включены снимки, сначала
+// восстанавливаются точки из снимка; если включен журнал, затем
+// обрабатываются пакеты, не обработанные до остановки.
 func (c *Collector) Start(ctx context.Context) error {
-    // Запускаем обработчик буфера метрик
+    if c.snapshots != nil {
+        if err := c.restoreSnapshot(time.Now()); err != nil {
+            log.Printf("Точки из снимка не восстановлены: %v", err)
+        }
+    }
+    if c.config.WAL != nil {
+        if err := c.replayWAL(); err != nil {
+            return fmt.Errorf("replay write-ahead log: %w", err)
+        }
+    }
     go c.processBuffer(ctx)
-    
-    // Запускаем очистку старых метрик
-    go c.cleanupOldMetrics(ctx)
+    return nil
+}
 
+func (c *Collector) replayWAL() error {
+    seqs, batches, err := c.config.WAL.Pending()
+    if err != nil {
+        return err
+    }
+    for i, batch := range batches {
+        if err := c.safeProcessBatch(batch); err != nil {
+            log.Printf("Ошибка сохранения метрик сервера %s из журнала: %v", batch.ServerID, err)
+        }
+        c.config.WAL.Ack(seqs[i])
+    }
+    if len(batches) > 0 {
+        log.Printf("Из журнала метрик обработано %d пакетов", len(batches))
+    }
     return nil
 }
 
+// Jobs возвращает периодические задачи коллектора
+func (c *Collector) Jobs() []scheduler.Job {
+    jobs := []scheduler.Job{{
+        Name:     "collector.cleanup",
+        Interval: c.config.CollectionInterval,
+        Run:      c.cleanupOldMetrics,
+    }}
+    if c.ceiling != nil {
+        jobs = append(jobs, c.ceilingJob())
+    }
+    if c.snapshots != nil {
+        jobs = append(jobs, c.snapshotJob())
+    }
+    if len(c.config.MetricRetention) > 0 {
+        jobs = append(jobs, c.metricRetentionJob())
+    }
+    if c.otlp != nil {
+        jobs = append(jobs, scheduler.Job{
+            Name:     "collector.otlp_export",
+            Interval: c.otlp.config.Interval,
+            Run:      c.otlp.export,
+        })
+    }
+    return jobs
+}
+
 func (c *Collector) processBuffer(ctx context.Context) {
     for {
         select {
         case <-ctx.Done():
             return
         case batch := <-c.buffer:
-            c.processBatch(batch)
+            err := c.safeProcessBatch(batch)
+            if err != nil {
+                err = fmt.Errorf("store metrics of server %s: %w", batch.ServerID, err)
+            }
+            // Ошибка хранилища не повторяется: журнал защищает от падения процесса, а не хранилища
+            if batch.walSeq != 0 {
+                c.config.WAL.Ack(batch.walSeq)
+            }
+            c.mu.RLock()
+            recorder := c.errors
+            c.mu.RUnlock()
+            if recorder != nil {
+                recorder.Record("collector.ingest", err)
+            } else if err != nil {
+                log.Printf("Ошибка сохранения метрик: %v", err)
+            }
         }
     }
 }
 
-func (c *Collector) processBatch(batch MetricBatch) {
-    c.mu.Lock()
-    defer c.mu.Unlock()
-
-    if _, exists := c.metrics[batch.ServerID]; !exists {
-        c.metrics[batch.ServerID] = &ServerMetrics{
-            Data: make([]models.MetricData, 0),
+// safeProcessBatch обрабатывает пакет, превращая панику хранилища или
+// подписчика в ошибку
+func (c *Collector) safeProcessBatch(batch MetricBatch) error {
+    err := supervisor.Call(func() error { return c.processBatch(batch) })
+    var panicErr *supervisor.PanicError
+    if errors.As(err, &panicErr) {
+        c.mu.RLock()
+        handler := c.panics
+        c.mu.RUnlock()
+        if handler != nil {
+            handler(err)
+        } else {
+            log.Printf("Паника при обработке метрик сервера %s: %v\n%s", batch.ServerID, panicErr.Value, panicErr.Stack)
         }
     }
+    return err
+}
+
+func (c *Collector) processBatch(batch MetricBatch) error {
+    if err := c.storeBatch(batch); err != nil {
+        return err
+    }
 
+    c.mu.RLock()
+    listeners := c.listeners
+    c.mu.RUnlock()
+    for _, listener := range listeners {
+        listener(batch)
+    }
+    c.publish(batch)
+    return nil
+}
+
+func (c *Collector) storeBatch(batch MetricBatch) error {
     // Добавляем новые метрики
-    c.metrics[batch.ServerID].Data = append(c.metrics[batch.ServerID].Data, batch.Metrics...)
-    c.metrics[batch.ServerID].LastUpdate = batch.Timestamp
+    if err := c.store.Append(batch); err != nil {
+        return err
+    }
 
     // Обновляем Prometheus метрики
     for _, metric := range batch.Metrics {
-        labels := prometheus.Labels{"server_id": batch.ServerID, "region": "default"}
-        
+        labels := c.prometheusLabels(batch.ServerID, metric)
+
         c.powerUsageGauge.With(labels).Set(metric.PowerUsage)
         c.carbonFootprintGauge.With(labels).Set(metric.CarbonFootprint)
         c.cpuUsageGauge.With(labels).Set(metric.CPUUsage)
         c.memoryUsageGauge.With(labels).Set(metric.MemoryUsage)
+        c.distributions.observe(labels, metric)
+
+        // Серии GPU и дисков появляются только у серверов, которые о них сообщают
+        if metric.GPUUsage > 0 || metric.GPUPowerUsage > 0 {
+            c.gpuUsageGauge.With(labels).Set(metric.GPUUsage)
+            c.gpuPowerUsageGauge.With(labels).Set(metric.GPUPowerUsage)
+        }
+        if metric.DiskReadBytesPerSec > 0 || metric.DiskWriteBytesPerSec > 0 || metric.StorageUsedBytes > 0 {
+            c.diskReadGauge.With(labels).Set(metric.DiskReadBytesPerSec)
+            c.diskWriteGauge.With(labels).Set(metric.DiskWriteBytesPerSec)
+            c.storageUsedGauge.With(labels).Set(metric.StorageUsedBytes)
+        }
+        if metric.InletTemperature != 0 {
+            c.inletTempGauge.With(labels).Set(metric.InletTemperature)
+        }
+    }
+    return nil
+}
+
+// gauges возвращает все серии коллектора
+func (c *Collector) gauges() []*prometheus.GaugeVec {
+    return []*prometheus.GaugeVec{
+        c.powerUsageGauge,
+        c.carbonFootprintGauge,
+        c.cpuUsageGauge,
+        c.memoryUsageGauge,
+        c.gpuUsageGauge,
+        c.gpuPowerUsageGauge,
+        c.diskReadGauge,
+        c.diskWriteGauge,
+        c.storageUsedGauge,
+        c.inletTempGauge,
+    }
+}
+
+// prometheusLabels возвращает метки серий сервера для точки. Отсутствующая
+// метка экспортируется пустой. Если значения меток сервера изменились
+// (например, сервер передали другой команде), старые серии удаляются,
+// чтобы сервер не учитывался дважды при суммировании по метке. Серии
+// гистограмм общие для серверов с одинаковыми метками и удаляются, когда
+// последний такой сервер получил другие метки.
+func (c *Collector) prometheusLabels(serverID string, metric models.MetricData) prometheus.Labels {
+    labels := prometheus.Labels{"server_id": serverID, "region": "default"}
+    values := make([]string, len(c.metricLabels))
+    for i, name := range c.metricLabels {
+        labels[name] = metric.Labels[name]
+        values[i] = metric.Labels[name]
+    }
+    signature := strings.Join(values, "\xff")
+
+    c.exportMu.Lock()
+    defer c.exportMu.Unlock()
+    previous, exists := c.exported[serverID]
+    if exists && previous != signature {
+        for _, gauge := range c.gauges() {
+            gauge.DeletePartialMatch(prometheus.Labels{"server_id": serverID})
+        }
+    }
+    c.exported[serverID] = signature
+    if exists && previous != signature && !c.exportedSignature(previous) {
+        c.distributions.forget(c.distributionLabels(previous))
+    }
+    return labels
+}
+
+// exportedSignature сообщает, экспортирует ли какой-либо сервер метки с
+// этой подписью. Вызывается под exportMu.
+func (c *Collector) exportedSignature(signature string) bool {
+    for _, exported := range c.exported {
+        if exported == signature {
+            return true
+        }
+    }
+    return false
+}
+
+// distributionLabels восстанавливает метки серий гистограмм по подписи
+// меток сервера
+func (c *Collector) distributionLabels(signature string) prometheus.Labels {
+    labels := prometheus.Labels{"region": "default"}
+    values := strings.Split(signature, "\xff")
+    for i, name := range c.metricLabels {
+        if i < len(values) {
+            labels[name] = values[i]
+        }
     }
+    return labels
 }
 
+// CollectMetrics ставит точку в буфер приема. Точка арендатора, которому
+// сервер не принадлежит (data.TenantID), отклоняется с ErrTenantMismatch.
 func (c *Collector) CollectMetrics(serverID string, data models.MetricData) error {
-    batch := MetricBatch{
+    if err := c.tenants.claim(serverID, data.TenantID, c.storedTenant); err != nil {
+        return err
+    }
+
+    return c.enqueue(MetricBatch{
         ServerID:  serverID,
         Metrics:   []models.MetricData{data},
         Timestamp: time.Now(),
+    })
+}
+
+// CollectBatchFrom принимает пачку точек одного сервера от источника, например
+// выгрузку накопленных агентом данных. Точки приводятся к каноническим единицам
+// как в CollectMetricsFrom и ставятся в буфер одним пакетом, поэтому пачка либо
+// принимается целиком, либо отклоняется с ErrBufferFull. Точки с неоднозначными
+// единицами, некорректными метками или недопустимыми значениями не сохраняются;
+// их ошибки возвращаются в rejected по индексу точки.
+func (c *Collector) CollectBatchFrom(source, serverID string, data []models.MetricData) (accepted int, rejected map[int]error, err error) {
+    normalized := make([]models.MetricData, 0, len(data))
+    for i, point := range data {
+        if err := c.tenants.claim(serverID, point.TenantID, c.storedTenant); err != nil {
+            return 0, nil, err
+        }
+        n, ok, err := c.normalize(source, serverID, point)
+        if err != nil {
+            if rejected == nil {
+                rejected = make(map[int]error)
+            }
+            rejected[i] = err
+            continue
+        }
+        if ok {
+            normalized = append(normalized, n)
+        }
+    }
+    if len(normalized) == 0 {
+        return 0, rejected, nil
     }
 
-    select {
-    case c.buffer <- batch:
-        return nil
-    default:
-        return fmt.Errorf("metric buffer is full")
+    err = c.enqueue(MetricBatch{
+        ServerID:  serverID,
+        Metrics:   normalized,
+        Timestamp: time.Now(),
+    })
+    if err != nil {
+        return 0, rejected, err
+    }
+    return len(normalized), rejected, nil
+}
+
+// enqueue ставит пакет в буфер приема, предварительно записав его в журнал
+func (c *Collector) enqueue(batch MetricBatch) error {
+    if c.config.WAL == nil {
+        select {
+        case c.buffer <- batch:
+            return nil
+        default:
+            return ErrBufferFull
+        }
+    }
+
+    // Пакет попадает в журнал, только если для него есть место в буфере
+    c.walMu.Lock()
+    defer c.walMu.Unlock()
+    if len(c.buffer) >= cap(c.buffer) {
+        return ErrBufferFull
+    }
+    seq, err := c.config.WAL.Append(batch)
+    if err != nil {
+        return fmt.Errorf("write-ahead log: %w", err)
+    }
+    batch.walSeq = seq
+    c.buffer <- batch
+    return nil
+}
+
+// CollectMetricsFrom принимает метрики от внешнего источника (агента), приводя их
+// к каноническим единицам. Неоднозначные данные отклоняются с *UnitError,
+// недопустимые значения - с *ValidationError.
+//
+// Если точка содержит накопительный счетчик энергии, мощность вычисляется как
+// скорость его изменения. Первое показание счетчика только запоминается, и
+// точка не сохраняется, так как мощность для нее еще неизвестна.
+func (c *Collector) CollectMetricsFrom(source, serverID string, data models.MetricData) error {
+    normalized, ok, err := c.normalize(source, serverID, data)
+    if err != nil || !ok {
+        return err
+    }
+    return c.CollectMetrics(serverID, normalized)
+}
+
+// CollectContainerMetrics принимает метрики одного контейнера от источника,
+// приводя их к каноническим единицам так же, как CollectMetricsFrom.
+// data.ServerID - сервер, на котором работает контейнер. Точки сохраняются
+// сразу, в отдельное хранилище с ключом по идентификатору контейнера.
+func (c *Collector) CollectContainerMetrics(source, containerID string, data models.MetricData) error {
+    if containerID == "" {
+        return fmt.Errorf("container id is required")
+    }
+    data.ContainerID = containerID
+    if err := c.tenants.claim(data.ServerID, data.TenantID, c.storedTenant); err != nil {
+        return err
+    }
+
+    normalized, ok, err := c.normalize(source, "container/"+containerID, data)
+    if err != nil || !ok {
+        return err
+    }
+
+    return c.containers.Append(MetricBatch{
+        ServerID:  containerID,
+        Metrics:   []models.MetricData{normalized},
+        Timestamp: time.Now(),
+    })
+}
+
+// normalize проверяет точку источника, приводит ее к каноническим единицам и
+// вычисляет мощность по накопительному счетчику энергии. Точка с NaN,
+// отрицательной мощностью, процентом вне 0-100 или меткой времени из
+// будущего отклоняется с *ValidationError до сохранения; каждый отказ
+// учитывается в счетчиках причин. ok = false - точка только обновила счетчик
+// и сохранять ее не нужно.
+func (c *Collector) normalize(source, seriesID string, data models.MetricData) (models.MetricData, bool, error) {
+    normalized, ok, err := c.sanitize(source, seriesID, data)
+    if err != nil {
+        c.rejections.count(err)
+    }
+    return normalized, ok, err
+}
+
+func (c *Collector) sanitize(source, seriesID string, data models.MetricData) (models.MetricData, bool, error) {
+    if err := ValidateLabels(data.Labels); err != nil {
+        return models.MetricData{}, false, err
+    }
+    if err := checkPoint(data, time.Now()); err != nil {
+        return models.MetricData{}, false, err
+    }
+    normalized, err := c.units.Normalize(source, data)
+    if err != nil {
+        return models.MetricData{}, false, err
+    }
+    if err := checkPercentages(normalized); err != nil {
+        return models.MetricData{}, false, err
+    }
+
+    if normalized.EnergyCounter > 0 {
+        watts, reset, ok := c.counters.rate(source+"/"+seriesID+"/energy", normalized.EnergyCounter, time.Unix(normalized.Timestamp, 0))
+        if !ok {
+            return models.MetricData{}, false, nil
+        }
+        if reset {
+            c.units.Note(source, seriesID, "energy_counter", normalized.EnergyCounter, "energy counter reset detected")
+        }
+        normalized.PowerUsage = watts
+    }
+    return normalized, true, nil
+}
+
+// OnIngest регистрирует обработчик, вызываемый после сохранения каждого пакета.
+// Обработчик выполняется в горутине обработки буфера и не должен блокироваться;
+// чтобы получать точки в своей горутине, используйте Subscribe.
+func (c *Collector) OnIngest(listener func(MetricBatch)) {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    c.listeners = append(c.listeners, listener)
+}
+
+// Ingest синхронно сохраняет пакет, минуя буфер и проверку единиц.
+// Используется для уже нормализованных данных, например при репликации.
+func (c *Collector) Ingest(batch MetricBatch) error {
+    return c.processBatch(batch)
+}
+
+// Snapshot возвращает копию всех хранимых метрик по серверам
+func (c *Collector) Snapshot() map[string][]models.MetricData {
+    snapshot := make(map[string][]models.MetricData)
+    for _, serverID := range c.ServerIDs() {
+        data, err := c.store.Metrics(serverID)
+        if err != nil {
+            continue
+        }
+        snapshot[serverID] = append([]models.MetricData(nil), data...)
     }
+    return snapshot
+}
+
+// ReplaceMetrics заменяет хранимые метрики сервера (например, снимком с основного инстанса)
+func (c *Collector) ReplaceMetrics(serverID string, data []models.MetricData) error {
+    return c.store.Replace(serverID, data)
+}
+
+// Units возвращает реестр единиц измерения источников
+func (c *Collector) Units() *UnitRegistry {
+    return c.units
 }
 
 func (c *Collector) GetMetrics(serverID string) ([]models.MetricData, error) {
-    c.mu.RLock()
-    defer c.mu.RUnlock()
+    return c.store.Metrics(serverID)
+}
+
+// GetMetricsRange возвращает точки сервера с меткой времени в [from, to).
+// В отличие от GetMetrics не читает всю хранимую историю.
+func (c *Collector) GetMetricsRange(serverID string, from, to time.Time) ([]models.MetricData, error) {
+    return c.store.Range(serverID, from, to)
+}
 
-    if metrics, exists := c.metrics[serverID]; exists {
-        return metrics.Data, nil
+// GetFilteredMetricsRange - GetMetricsRange после сглаживания и заполнения пропусков
+func (c *Collector) GetFilteredMetricsRange(serverID string, from, to time.Time) ([]models.MetricData, error) {
+    data, err := c.GetMetricsRange(serverID, from, to)
+    if err != nil {
+        return nil, err
     }
-    return nil, fmt.Errorf("no metrics found for server: %s", serverID)
+    return ApplyFilters(data, c.config.Filter), nil
 }
 
-func (c *Collector) cleanupOldMetrics(ctx context.Context) {
-    ticker := time.NewTicker(c.config.CollectionInterval)
-    defer ticker.Stop()
+// GetBuckets возвращает агрегаты метрик сервера по интервалам длины bucket.
+// Если хранилище умеет агрегировать само, данные не загружаются в память.
+func (c *Collector) GetBuckets(serverID string, from, to time.Time, bucket time.Duration) ([]UsageBucket, error) {
+    if bucket <= 0 {
+        return nil, fmt.Errorf("bucket must be positive")
+    }
+    if querier, ok := c.store.(BucketQuerier); ok {
+        return querier.Buckets(serverID, from, to, bucket)
+    }
 
-    for {
-        select {
-        case <-ctx.Done():
-            return
-        case <-ticker.C:
-            c.mu.Lock()
-            cutoff := time.Now().Add(-c.config.RetentionPeriod)
-            
-            for serverID, serverMetrics := range c.metrics {
-                filtered := make([]models.MetricData, 0)
-                for _, metric := range serverMetrics.Data {
-                    if time.Unix(metric.Timestamp, 0).After(cutoff) {
-                        filtered = append(filtered, metric)
-                    }
-                }
-                c.metrics[serverID].Data = filtered
-            }
-            c.mu.Unlock()
+    data, err := c.store.Metrics(serverID)
+    if err != nil {
+        return nil, err
+    }
+    return BucketMetrics(data, from, to, bucket), nil
+}
+
+// Aggregate возвращает avg, min, max и p95 метрики сервера по интервалам
+// длины step в [from, to): клиент получает статистику вместо сырых точек.
+// filtered - агрегировать ряд после сглаживания и заполнения пропусков.
+func (c *Collector) Aggregate(serverID, metric string, from, to time.Time, step time.Duration, filtered bool) ([]AggregatePoint, error) {
+    getRange := c.GetMetricsRange
+    if filtered {
+        getRange = c.GetFilteredMetricsRange
+    }
+    data, err := getRange(serverID, from, to)
+    if err != nil {
+        return nil, err
+    }
+    return AggregateMetric(data, metric, from, to, step)
+}
+
+// GetFilteredMetrics возвращает метрики сервера после сглаживания и заполнения пропусков.
+// Хранимые данные не изменяются.
+func (c *Collector) GetFilteredMetrics(serverID string) ([]models.MetricData, error) {
+    data, err := c.GetMetrics(serverID)
+    if err != nil {
+        return nil, err
+    }
+    return ApplyFilters(data, c.config.Filter), nil
+}
+
+// GetContainerMetrics возвращает все хранимые точки контейнера
+func (c *Collector) GetContainerMetrics(containerID string) ([]models.MetricData, error) {
+    return c.containers.Metrics(containerID)
+}
+
+// GetContainerMetricsRange возвращает точки контейнера с меткой времени в [from, to)
+func (c *Collector) GetContainerMetricsRange(containerID string, from, to time.Time) ([]models.MetricData, error) {
+    return c.containers.Range(containerID, from, to)
+}
+
+// ContainerIDs возвращает идентификаторы контейнеров, для которых есть метрики.
+// Непустой serverID оставляет только контейнеры этого сервера (ContainerServerID).
+func (c *Collector) ContainerIDs(serverID string) []string {
+    ids, err := c.containers.ServerIDs()
+    if err != nil {
+        log.Printf("Ошибка получения списка контейнеров из хранилища: %v", err)
+        return nil
+    }
+    if serverID == "" {
+        return ids
+    }
+
+    var onServer []string
+    for _, containerID := range ids {
+        if host, ok := c.ContainerServerID(containerID); ok && host == serverID {
+            onServer = append(onServer, containerID)
         }
     }
+    return onServer
+}
+
+// Containers возвращает работающие контейнеры сервера serverID; пусто - всех
+// серверов. Сервер контейнера - тот, с которого пришла последняя точка, если
+// более позднее событие жизненного цикла не остановило или не перенесло его.
+// Сервис, namespace и образ берутся из меток service, namespace и image
+// последней точки, потребление - из ее power_usage.
+func (c *Collector) Containers(serverID string) []models.Container {
+    containers := make([]models.Container, 0)
+    for _, containerID := range c.ContainerIDs("") {
+        data, err := c.containers.Metrics(containerID)
+        if err != nil || len(data) == 0 {
+            continue
+        }
+        latest := data[len(data)-1]
+        host, running := c.containerLocation(containerID, latest)
+        if !running || serverID != "" && host != serverID {
+            continue
+        }
+        containers = append(containers, models.Container{
+            ID:          containerID,
+            ServerID:    host,
+            ServiceName: latest.Labels[cohortServiceLabel],
+            Image:       latest.Labels["image"],
+            Namespace:   latest.Labels["namespace"],
+            Labels:      latest.Labels,
+            PowerUsage:  latest.PowerUsage,
+        })
+    }
+    sort.Slice(containers, func(i, j int) bool { return containers[i].ID < containers[j].ID })
+    return containers
+}
+
+// ContainerServerID возвращает сервер контейнера: по последней точке или
+// более позднему событию жизненного цикла
+func (c *Collector) ContainerServerID(containerID string) (string, bool) {
+    data, err := c.containers.Metrics(containerID)
+    if err != nil || len(data) == 0 {
+        state, known := c.lifecycle.state(containerID)
+        return state.serverID, known
+    }
+    host, _ := c.containerLocation(containerID, data[len(data)-1])
+    return host, true
+}
+
+// containerLocation возвращает сервер контейнера и работает ли он: событие
+// новее последней точки важнее нее
+func (c *Collector) containerLocation(containerID string, latest models.MetricData) (string, bool) {
+    state, known := c.lifecycle.state(containerID)
+    if !known || state.at.Unix() < latest.Timestamp {
+        return latest.ServerID, true
+    }
+    return state.serverID, state.running
+}
+
+// ServerIDs возвращает идентификаторы всех серверов, для которых есть метрики
+func (c *Collector) ServerIDs() []string {
+    ids, err := c.store.ServerIDs()
+    if err != nil {
+        log.Printf("Ошибка получения списка серверов из хранилища: %v", err)
+        return nil
+    }
+    return ids
+}
+
+func (c *Collector) cleanupOldMetrics(ctx context.Context) error {
+    cutoff := time.Now().Add(-c.config.LongestRetention())
+    if err := c.store.Prune(cutoff); err != nil {
+        return fmt.Errorf("prune metrics: %w", err)
+    }
+    if err := c.containers.Prune(cutoff); err != nil {
+        return fmt.Errorf("prune container metrics: %w", err)
+    }
+    c.lifecycle.prune(cutoff)
+    return nil
 } 
\ No newline at end of file
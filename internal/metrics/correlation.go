@@ -0,0 +1,264 @@
+package metrics
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/YumeNoTenshi/platypus/internal/models"
+)
+
+const (
+	// correlationStep - шаг сетки, на которую выравниваются ряды серверов
+	correlationStep = 5 * time.Minute
+	// correlationBaseline - окно скользящей медианы, шагов: медленные
+	// суточные колебания, общие почти для всех серверов, вычитаются, и
+	// корреляция считается по всплескам
+	correlationBaseline = 13
+	// minCorrelationOverlap - меньше общих шагов не дают корреляции
+	minCorrelationOverlap = 24
+	// minSharedSpikes - сколько совместных всплесков нужно паре, чтобы
+	// корреляция не держалась на одном событии или на шуме
+	minSharedSpikes = 2
+	// defaultMinCorrelation - порог корреляции пары по умолчанию
+	defaultMinCorrelation = 0.8
+)
+
+// ServerCorrelation - корреляция всплесков двух серверов
+type ServerCorrelation struct {
+	ServerA      string  `json:"server_a"`
+	ServerB      string  `json:"server_b"`
+	Correlation  float64 `json:"correlation"`   // Пирсон по отклонениям от скользящей медианы
+	SharedSpikes int     `json:"shared_spikes"` // Шаги, на которых всплеск у обоих
+	Overlap      int     `json:"overlap"`       // Общие шаги рядов
+}
+
+// CorrelationCluster - серверы, всплески которых идут вместе: часто это
+// общая шумная нагрузка (пакетные задания, соседи по хосту), которую стоит
+// найти до планирования миграций
+type CorrelationCluster struct {
+	Servers         []string            `json:"servers"`
+	MeanCorrelation float64             `json:"mean_correlation"` // По парам выше порога
+	Pairs           []ServerCorrelation `json:"pairs"`
+	// SharedSpikes - шаги, на которых всплеск был хотя бы у половины
+	// серверов кластера (и не меньше чем у двух)
+	SharedSpikes []time.Time `json:"shared_spikes"`
+}
+
+// spikeSeries - ряд сервера на сетке: отклонения от скользящей медианы и
+// отметки всплесков
+type spikeSeries struct {
+	residuals map[int64]float64
+	spikes    map[int64]bool
+}
+
+// CorrelationClusters ищет серверы, измерение которых всплескивает
+// одновременно. Ряды серверов за окно анализа усредняются на сетке
+// correlationStep, из них вычитается скользящая медиана, и для каждой пары
+// считается корреляция Пирсона остатков. Пары с корреляцией не ниже
+// minCorrelation (0 - 0.8) и хотя бы двумя совместными всплесками
+// связывают серверы; кластер - связная компонента. serverIDs ограничивает
+// серверы; nil - все серверы источника. Кластеры упорядочены от больших.
+func (a *Analyzer) CorrelationClusters(serverIDs []string, dimension Dimension, minCorrelation float64) ([]CorrelationCluster, error) {
+	if serverIDs == nil {
+		serverIDs = a.source.ServerIDs()
+	}
+	if minCorrelation <= 0 {
+		minCorrelation = defaultMinCorrelation
+	}
+	threshold := a.config.AnomalyThreshold
+	if threshold <= 0 {
+		threshold = 3
+	}
+
+	ids := make([]string, 0, len(serverIDs))
+	series := make(map[string]spikeSeries, len(serverIDs))
+	for _, serverID := range serverIDs {
+		data, err := a.recentMetrics(serverID)
+		if err != nil {
+			continue
+		}
+		if s, ok := newSpikeSeries(data, dimension, threshold); ok {
+			ids = append(ids, serverID)
+			series[serverID] = s
+		}
+	}
+	sort.Strings(ids)
+
+	parent := make(map[string]string, len(ids))
+	var find func(string) string
+	find = func(id string) string {
+		if parent[id] == id {
+			return id
+		}
+		parent[id] = find(parent[id])
+		return parent[id]
+	}
+	for _, id := range ids {
+		parent[id] = id
+	}
+
+	var pairs []ServerCorrelation
+	for i := range ids {
+		for j := i + 1; j < len(ids); j++ {
+			pair, ok := correlate(series[ids[i]], series[ids[j]])
+			if !ok || pair.Correlation < minCorrelation || pair.SharedSpikes < minSharedSpikes {
+				continue
+			}
+			pair.ServerA, pair.ServerB = ids[i], ids[j]
+			pairs = append(pairs, pair)
+			parent[find(ids[i])] = find(ids[j])
+		}
+	}
+
+	byRoot := make(map[string]*CorrelationCluster)
+	for _, pair := range pairs {
+		root := find(pair.ServerA)
+		cluster := byRoot[root]
+		if cluster == nil {
+			cluster = &CorrelationCluster{}
+			byRoot[root] = cluster
+		}
+		cluster.Pairs = append(cluster.Pairs, pair)
+		cluster.MeanCorrelation += pair.Correlation
+	}
+
+	clusters := make([]CorrelationCluster, 0, len(byRoot))
+	for root, cluster := range byRoot {
+		for _, id := range ids {
+			if find(id) == root {
+				cluster.Servers = append(cluster.Servers, id)
+			}
+		}
+		cluster.MeanCorrelation /= float64(len(cluster.Pairs))
+		cluster.SharedSpikes = sharedSpikes(cluster.Servers, series)
+		clusters = append(clusters, *cluster)
+	}
+	sort.Slice(clusters, func(i, j int) bool {
+		if len(clusters[i].Servers) != len(clusters[j].Servers) {
+			return len(clusters[i].Servers) > len(clusters[j].Servers)
+		}
+		return clusters[i].MeanCorrelation > clusters[j].MeanCorrelation
+	})
+	return clusters, nil
+}
+
+// newSpikeSeries выравнивает точки на сетке и отмечает всплески: шаги, где
+// остаток выше threshold робастных σ. ok ложно, если шагов меньше
+// minCorrelationOverlap или ряд без разброса.
+func newSpikeSeries(data []models.MetricData, dimension Dimension, threshold float64) (spikeSeries, bool) {
+	step := int64(correlationStep / time.Second)
+	sums := make(map[int64]float64)
+	counts := make(map[int64]int)
+	for _, m := range data {
+		// Восстановленные точки не несут всплесков
+		if m.Interpolated {
+			continue
+		}
+		bucket := m.Timestamp - m.Timestamp%step
+		sums[bucket] += dimension.Value(m)
+		counts[bucket]++
+	}
+	if len(sums) < minCorrelationOverlap {
+		return spikeSeries{}, false
+	}
+
+	buckets := make([]int64, 0, len(sums))
+	for bucket := range sums {
+		buckets = append(buckets, bucket)
+	}
+	sort.Slice(buckets, func(i, j int) bool { return buckets[i] < buckets[j] })
+	values := make([]float64, len(buckets))
+	for i, bucket := range buckets {
+		values[i] = sums[bucket] / float64(counts[bucket])
+	}
+
+	s := spikeSeries{
+		residuals: make(map[int64]float64, len(buckets)),
+		spikes:    make(map[int64]bool),
+	}
+	residuals := make([]float64, len(values))
+	for i := range values {
+		lo := max(0, i-correlationBaseline/2)
+		hi := min(len(values), lo+correlationBaseline)
+		lo = max(0, hi-correlationBaseline)
+		window := append([]float64(nil), values[lo:hi]...)
+		sort.Float64s(window)
+		residuals[i] = values[i] - percentile(window, 0.5)
+		s.residuals[buckets[i]] = residuals[i]
+	}
+
+	_, sigma := robustSpread(residuals)
+	if sigma == 0 {
+		return spikeSeries{}, false
+	}
+	for i, residual := range residuals {
+		if residual > threshold*sigma {
+			s.spikes[buckets[i]] = true
+		}
+	}
+	return s, true
+}
+
+// correlate считает корреляцию остатков двух рядов на общих шагах
+func correlate(a, b spikeSeries) (ServerCorrelation, bool) {
+	var xs, ys []float64
+	shared := 0
+	for bucket, x := range a.residuals {
+		y, ok := b.residuals[bucket]
+		if !ok {
+			continue
+		}
+		xs, ys = append(xs, x), append(ys, y)
+		if a.spikes[bucket] && b.spikes[bucket] {
+			shared++
+		}
+	}
+	if len(xs) < minCorrelationOverlap {
+		return ServerCorrelation{}, false
+	}
+
+	n := float64(len(xs))
+	var meanX, meanY float64
+	for i := range xs {
+		meanX += xs[i]
+		meanY += ys[i]
+	}
+	meanX /= n
+	meanY /= n
+	var cov, varX, varY float64
+	for i := range xs {
+		dx, dy := xs[i]-meanX, ys[i]-meanY
+		cov += dx * dy
+		varX += dx * dx
+		varY += dy * dy
+	}
+	if varX == 0 || varY == 0 {
+		return ServerCorrelation{}, false
+	}
+	return ServerCorrelation{
+		Correlation:  cov / math.Sqrt(varX*varY),
+		SharedSpikes: shared,
+		Overlap:      len(xs),
+	}, true
+}
+
+// sharedSpikes возвращает шаги, на которых всплеск был хотя бы у половины
+// серверов кластера
+func sharedSpikes(servers []string, series map[string]spikeSeries) []time.Time {
+	need := max(2, (len(servers)+1)/2)
+	counts := make(map[int64]int)
+	for _, id := range servers {
+		for bucket := range series[id].spikes {
+			counts[bucket]++
+		}
+	}
+	spikes := make([]time.Time, 0)
+	for bucket, count := range counts {
+		if count >= need {
+			spikes = append(spikes, time.Unix(bucket, 0))
+		}
+	}
+	sort.Slice(spikes, func(i, j int) bool { return spikes[i].Before(spikes[j]) })
+	return spikes
+}
@@ -0,0 +1,83 @@
+package metrics
+
+import (
+	"sync"
+	"time"
+)
+
+// EnergyUnit - единица накопительного счетчика энергии
+type EnergyUnit string
+
+const (
+	UnitJoules        EnergyUnit = "joules"
+	UnitWattHours     EnergyUnit = "wh"
+	UnitKilowattHours EnergyUnit = "kwh"
+)
+
+// toJoules приводит показание счетчика к джоулям
+func toJoules(value float64, unit EnergyUnit) float64 {
+	switch unit {
+	case UnitWattHours:
+		return value * 3600
+	case UnitKilowattHours:
+		return value * 3600 * 1000
+	}
+	return value
+}
+
+type counterReading struct {
+	value float64
+	at    time.Time
+}
+
+// counterShards - число сегментов counterTracker, как у MemoryStore
+const counterShards = 64
+
+type counterShard struct {
+	mu   sync.Mutex
+	last map[string]counterReading
+}
+
+// counterTracker вычисляет скорость изменения накопительных счетчиков
+// (например, энергии в джоулях) по последовательным показаниям
+type counterTracker struct {
+	shards [counterShards]counterShard
+}
+
+func newCounterTracker() *counterTracker {
+	t := &counterTracker{}
+	for i := range t.shards {
+		t.shards[i].last = make(map[string]counterReading)
+	}
+	return t
+}
+
+// rate возвращает скорость изменения счетчика key в единицах в секунду.
+// Первое показание только запоминается (ok=false). Уменьшение значения
+// считается сбросом счетчика (перезапуск агента, переполнение): приращением
+// тогда считается само новое значение, отсчитанное от нуля.
+func (t *counterTracker) rate(key string, value float64, at time.Time) (rate float64, reset bool, ok bool) {
+	shard := &t.shards[shardIndex(key, counterShards)]
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	previous, exists := shard.last[key]
+	if !exists {
+		shard.last[key] = counterReading{value: value, at: at}
+		return 0, false, false
+	}
+
+	elapsed := at.Sub(previous.at).Seconds()
+	if elapsed <= 0 {
+		// Повтор или запоздавшее показание: ждем следующего
+		return 0, false, false
+	}
+	shard.last[key] = counterReading{value: value, at: at}
+
+	delta := value - previous.value
+	if delta < 0 {
+		delta = value
+		reset = true
+	}
+	return delta / elapsed, reset, true
+}
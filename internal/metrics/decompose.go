@@ -0,0 +1,304 @@
+package metrics
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/YumeNoTenshi/platypus/internal/models"
+)
+
+// ErrShortSeries - точек слишком мало для разложения ряда
+var ErrShortSeries = errors.New("series too short for decomposition")
+
+const (
+	// decompositionStep - шаг сетки, на которую ряд усредняется перед разложением
+	decompositionStep = time.Hour
+	dailyPeriod       = 24  // Шагов в сутках
+	weeklyPeriod      = 168 // Шагов в неделе
+	// minTrendSteps - меньше шагов сетки не дают тренда
+	minTrendSteps = 3
+	// seasonalWindow - окно сглаживания подрядов одной фазы сезона, периодов
+	seasonalWindow = 7
+	// decompositionPasses - проходы поочередной оценки сезонов (MSTL)
+	decompositionPasses = 2
+	// innerPasses - проходы внутреннего цикла STL для одного сезона
+	innerPasses = 2
+)
+
+// DecompositionPoint - значение ряда на шаге сетки и его составляющие:
+// Value = Trend + Daily + Weekly + Residual
+type DecompositionPoint struct {
+	Timestamp time.Time `json:"timestamp"`
+	Value     float64   `json:"value"`
+	Trend     float64   `json:"trend"`
+	Daily     float64   `json:"daily"`
+	Weekly    float64   `json:"weekly"`
+	Residual  float64   `json:"residual"`
+}
+
+// Decomposition - разложение ряда на тренд, суточный и недельный сезоны и
+// остаток (STL с несколькими сезонами, MSTL). Сезон оценивается, только
+// если ряд покрывает хотя бы два его периода; иначе его составляющая нулевая.
+// Strength - сила сезона по Hyndman: 1 - Var(остаток) / Var(сезон + остаток),
+// 0 - сезона нет, близко к 1 - ряд почти целиком повторяется.
+type Decomposition struct {
+	Step           time.Duration        `json:"step"`
+	Daily          bool                 `json:"daily"`  // Суточный сезон оценен
+	Weekly         bool                 `json:"weekly"` // Недельный сезон оценен
+	DailyStrength  float64              `json:"daily_strength"`
+	WeeklyStrength float64              `json:"weekly_strength"`
+	TrendChange    float64              `json:"trend_change"` // Изменение тренда от начала до конца ряда
+	Points         []DecompositionPoint `json:"points"`
+}
+
+// Decompose усредняет измерение точек по часам, заполняет пропуски
+// линейной интерполяцией и раскладывает ряд на тренд, сезоны и остаток
+func Decompose(metrics []models.MetricData, dimension Dimension) (*Decomposition, error) {
+	start, values := hourlySeries(metrics, dimension)
+	n := len(values)
+	if n < minTrendSteps {
+		return nil, ErrShortSeries
+	}
+
+	periods := []int{}
+	if n >= 2*dailyPeriod {
+		periods = append(periods, dailyPeriod)
+	}
+	if n >= 2*weeklyPeriod {
+		periods = append(periods, weeklyPeriod)
+	}
+
+	// Сезоны оцениваются поочередно: каждый - по ряду без остальных
+	seasonals := make([][]float64, len(periods))
+	for i := range seasonals {
+		seasonals[i] = make([]float64, n)
+	}
+	for pass := 0; pass < decompositionPasses && len(periods) > 0; pass++ {
+		for i, period := range periods {
+			adjusted := append([]float64(nil), values...)
+			for j, seasonal := range seasonals {
+				if j != i {
+					subtract(adjusted, seasonal)
+				}
+			}
+			seasonals[i] = stlSeasonal(adjusted, period)
+		}
+	}
+
+	deseasonalized := append([]float64(nil), values...)
+	for _, seasonal := range seasonals {
+		subtract(deseasonalized, seasonal)
+	}
+	trendSpan := n / 2
+	if len(periods) > 0 {
+		trendSpan = trendWindow(periods[len(periods)-1])
+	}
+	trend := loess(deseasonalized, trendSpan)
+
+	d := &Decomposition{
+		Step:        decompositionStep,
+		TrendChange: trend[n-1] - trend[0],
+		Points:      make([]DecompositionPoint, n),
+	}
+	daily, weekly := make([]float64, n), make([]float64, n)
+	for i, period := range periods {
+		switch period {
+		case dailyPeriod:
+			daily, d.Daily = seasonals[i], true
+		case weeklyPeriod:
+			weekly, d.Weekly = seasonals[i], true
+		}
+	}
+
+	residual := make([]float64, n)
+	for i := range values {
+		residual[i] = values[i] - trend[i] - daily[i] - weekly[i]
+		d.Points[i] = DecompositionPoint{
+			Timestamp: start.Add(time.Duration(i) * decompositionStep),
+			Value:     values[i],
+			Trend:     trend[i],
+			Daily:     daily[i],
+			Weekly:    weekly[i],
+			Residual:  residual[i],
+		}
+	}
+	if d.Daily {
+		d.DailyStrength = seasonalStrength(daily, residual)
+	}
+	if d.Weekly {
+		d.WeeklyStrength = seasonalStrength(weekly, residual)
+	}
+	return d, nil
+}
+
+// hourlySeries усредняет измерение по часам и заполняет пустые часы
+// линейной интерполяцией между соседними
+func hourlySeries(metrics []models.MetricData, dimension Dimension) (time.Time, []float64) {
+	if len(metrics) == 0 {
+		return time.Time{}, nil
+	}
+	sums := make(map[int64]float64)
+	counts := make(map[int64]int)
+	first, last := int64(math.MaxInt64), int64(math.MinInt64)
+	step := int64(decompositionStep / time.Second)
+	for _, m := range metrics {
+		bucket := m.Timestamp - m.Timestamp%step
+		sums[bucket] += dimension.Value(m)
+		counts[bucket]++
+		first, last = min(first, bucket), max(last, bucket)
+	}
+
+	n := int((last-first)/step) + 1
+	values := make([]float64, n)
+	known := make([]int, 0, len(counts))
+	for bucket, count := range counts {
+		i := int((bucket - first) / step)
+		values[i] = sums[bucket] / float64(count)
+		known = append(known, i)
+	}
+	sort.Ints(known)
+	for k := 1; k < len(known); k++ {
+		from, to := known[k-1], known[k]
+		for i := from + 1; i < to; i++ {
+			w := float64(i-from) / float64(to-from)
+			values[i] = values[from]*(1-w) + values[to]*w
+		}
+	}
+	return time.Unix(first, 0), values
+}
+
+// stlSeasonal - внутренний цикл STL (Cleveland et al., 1990) для одного
+// периода: сглаживание подрядов одной фазы, удаление низких частот из
+// сезона и оценка тренда по ряду без сезона
+func stlSeasonal(values []float64, period int) []float64 {
+	n := len(values)
+	trend := make([]float64, n)
+	seasonal := make([]float64, n)
+	for pass := 0; pass < innerPasses; pass++ {
+		detrended := append([]float64(nil), values...)
+		subtract(detrended, trend)
+
+		// Подряды одной фазы: значения в один и тот же час суток или недели
+		cycle := make([]float64, n)
+		for phase := 0; phase < period; phase++ {
+			var subseries []float64
+			for i := phase; i < n; i += period {
+				subseries = append(subseries, detrended[i])
+			}
+			smoothed := loess(subseries, seasonalWindow)
+			for k, i := 0, phase; i < n; k, i = k+1, i+period {
+				cycle[i] = smoothed[k]
+			}
+		}
+
+		// Низкие частоты подрядов относятся к тренду, а не к сезону
+		lowPass := movingAverage(movingAverage(movingAverage(cycle, period), period), 3)
+		for i := range seasonal {
+			seasonal[i] = cycle[i] - lowPass[i]
+		}
+
+		deseasonalized := append([]float64(nil), values...)
+		subtract(deseasonalized, seasonal)
+		trend = loess(deseasonalized, trendWindow(period))
+	}
+	return seasonal
+}
+
+// trendWindow - окно тренда STL: наименьшее нечетное не меньше
+// 1.5 * period / (1 - 1.5 / seasonalWindow)
+func trendWindow(period int) int {
+	window := int(math.Ceil(1.5 * float64(period) / (1 - 1.5/float64(seasonalWindow))))
+	if window%2 == 0 {
+		window++
+	}
+	return window
+}
+
+// loess сглаживает ряд локальной линейной регрессией с весами tricube по
+// span ближайшим точкам
+func loess(values []float64, span int) []float64 {
+	n := len(values)
+	smoothed := make([]float64, n)
+	if n == 0 {
+		return smoothed
+	}
+	span = max(2, min(span, n))
+	for i := range values {
+		// Окно из span ближайших точек, сдвинутое у краев ряда
+		lo := max(0, min(i-span/2, n-span))
+		hi := lo + span - 1
+		radius := float64(max(i-lo, hi-i)) + 1
+
+		var sw, swx, swy, swxx, swxy float64
+		for j := lo; j <= hi; j++ {
+			d := math.Abs(float64(j-i)) / radius
+			w := math.Pow(1-d*d*d, 3)
+			x := float64(j - i)
+			sw += w
+			swx += w * x
+			swy += w * values[j]
+			swxx += w * x * x
+			swxy += w * x * values[j]
+		}
+		mean := swy / sw
+		denominator := swxx - swx*swx/sw
+		if denominator < 1e-12 {
+			smoothed[i] = mean
+			continue
+		}
+		// Значение прямой в x = 0, то есть в точке i
+		slope := (swxy - swx*swy/sw) / denominator
+		smoothed[i] = mean - slope*swx/sw
+	}
+	return smoothed
+}
+
+// movingAverage - центрированное скользящее среднее; у краев окно усекается
+func movingAverage(values []float64, window int) []float64 {
+	n := len(values)
+	averaged := make([]float64, n)
+	prefix := make([]float64, n+1)
+	for i, v := range values {
+		prefix[i+1] = prefix[i] + v
+	}
+	for i := range values {
+		lo := max(0, i-window/2)
+		hi := min(n, lo+window)
+		lo = max(0, hi-window)
+		averaged[i] = (prefix[hi] - prefix[lo]) / float64(hi-lo)
+	}
+	return averaged
+}
+
+func seasonalStrength(seasonal, residual []float64) float64 {
+	combined := make([]float64, len(seasonal))
+	for i := range seasonal {
+		combined[i] = seasonal[i] + residual[i]
+	}
+	total := variance(combined)
+	if total == 0 {
+		return 0
+	}
+	return math.Max(0, 1-variance(residual)/total)
+}
+
+func variance(values []float64) float64 {
+	var mean float64
+	for _, v := range values {
+		mean += v
+	}
+	mean /= float64(len(values))
+	var sum float64
+	for _, v := range values {
+		sum += (v - mean) * (v - mean)
+	}
+	return sum / float64(len(values))
+}
+
+func subtract(values, component []float64) {
+	for i := range values {
+		values[i] -= component[i]
+	}
+}
@@ -0,0 +1,50 @@
+package metrics
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/YumeNoTenshi/platypus/internal/models"
+)
+
+// Dimension - поле точки, по которому Analyzer считает статистику, тренд и
+// аномалии. Значение - имя поля в JSON, как в агрегации (?metric=).
+type Dimension string
+
+const (
+	DimensionPower  Dimension = "power_usage"
+	DimensionCPU    Dimension = "cpu_usage"
+	DimensionMemory Dimension = "memory_usage"
+	DimensionCarbon Dimension = "carbon_footprint"
+)
+
+// Dimensions - измерения, доступные анализу
+var Dimensions = []Dimension{DimensionPower, DimensionCPU, DimensionMemory, DimensionCarbon}
+
+// ParseDimension разбирает измерение по имени поля или короткому имени
+// (power, cpu, memory, carbon); пустая строка - потребление
+func ParseDimension(name string) (Dimension, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" {
+		return DimensionPower, nil
+	}
+	for _, dimension := range Dimensions {
+		if name == string(dimension) || name+"_usage" == string(dimension) || name+"_footprint" == string(dimension) {
+			return dimension, nil
+		}
+	}
+	return "", fmt.Errorf("%w for analysis: %s (use power, cpu, memory or carbon)", ErrUnknownMetric, name)
+}
+
+// Value возвращает значение измерения в точке
+func (d Dimension) Value(m models.MetricData) float64 {
+	switch d {
+	case DimensionCPU:
+		return m.CPUUsage
+	case DimensionMemory:
+		return m.MemoryUsage
+	case DimensionCarbon:
+		return m.CarbonFootprint
+	}
+	return m.PowerUsage
+}
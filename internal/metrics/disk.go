@@ -0,0 +1,321 @@
+package metrics
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/YumeNoTenshi/platypus/internal/encryption"
+	"github.com/YumeNoTenshi/platypus/internal/models"
+)
+
+const diskSeriesSuffix = ".ndjson"
+
+const (
+	// diskChunkPrefix открывает строку с зашифрованным блоком точек (base64);
+	// строка точки в JSON начинается с "{"
+	diskChunkPrefix = '!'
+	// diskChunkPoints - точек в одном зашифрованном блоке: строка блока
+	// должна поместиться в буфер чтения
+	diskChunkPoints = 500
+)
+
+// diskSeries - файл серии и границы меток ее точек
+type diskSeries struct {
+	path   string
+	oldest int64 // math.MinInt64 - неизвестно (файл с прошлого запуска)
+}
+
+// DiskStore хранит точки на диске: по файлу NDJSON на серию. Рассчитан на
+// холодные данные, вытесненные из памяти (MemoryStore.SpillBefore): запись
+// - дозапись в конец файла, чтение и очистка - полный просмотр файла серии.
+// С шифрованием (SetEncryption) строка файла - зашифрованный блок точек.
+type DiskStore struct {
+	dir  string
+	keys *encryption.Keyring // Необязательное шифрование блоков точек
+
+	mu     sync.Mutex
+	series map[string]*diskSeries
+}
+
+// OpenDiskStore открывает хранилище в каталоге, создавая его при
+// необходимости. Серии, оставшиеся с прошлого запуска, подхватываются.
+func OpenDiskStore(dir string) (*DiskStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	s := &DiskStore{dir: dir, series: make(map[string]*diskSeries)}
+
+	paths, err := filepath.Glob(filepath.Join(dir, "*"+diskSeriesSuffix))
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range paths {
+		name, err := hex.DecodeString(strings.TrimSuffix(filepath.Base(path), diskSeriesSuffix))
+		if err != nil {
+			continue // Посторонний файл
+		}
+		s.series[string(name)] = &diskSeries{path: path, oldest: math.MinInt64}
+	}
+	return s, nil
+}
+
+// SetEncryption включает шифрование новых блоков точек. Строки, записанные
+// без шифрования, по-прежнему читаются; Reencrypt переписывает их.
+func (s *DiskStore) SetEncryption(keys *encryption.Keyring) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys = keys
+}
+
+// path - имя файла серии; идентификатор кодируется, так как может содержать "/"
+func (s *DiskStore) path(serverID string) string {
+	return filepath.Join(s.dir, hex.EncodeToString([]byte(serverID))+diskSeriesSuffix)
+}
+
+func (s *DiskStore) Append(batch MetricBatch) error {
+	if len(batch.Metrics) == 0 {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	series, exists := s.series[batch.ServerID]
+	if !exists {
+		series = &diskSeries{path: s.path(batch.ServerID), oldest: math.MaxInt64}
+	}
+	file, err := os.OpenFile(series.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(file)
+	if err := writeDiskPoints(w, batch.Metrics, s.keys); err != nil {
+		file.Close()
+		return err
+	}
+	for _, m := range batch.Metrics {
+		series.oldest = min(series.oldest, m.Timestamp)
+	}
+	if err := w.Flush(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	s.series[batch.ServerID] = series
+	return nil
+}
+
+func (s *DiskStore) Metrics(serverID string) ([]models.MetricData, error) {
+	return s.read(serverID, nil)
+}
+
+func (s *DiskStore) Range(serverID string, from, to time.Time) ([]models.MetricData, error) {
+	return s.read(serverID, func(m models.MetricData) bool { return inRange(m, from, to) })
+}
+
+// read читает точки серии, отбирая их keep; nil - все. Неизвестная серия -
+// пустой результат, а не ошибка: вытесненные точки серии могли быть целиком
+// удалены очисткой, а ее новые точки остаются в памяти.
+func (s *DiskStore) read(serverID string, keep func(models.MetricData) bool) ([]models.MetricData, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	series, exists := s.series[serverID]
+	if !exists {
+		return nil, nil
+	}
+	return readDiskSeries(series.path, s.keys, keep)
+}
+
+// writeDiskPoints пишет точки строками JSON, а с шифрованием - блоками
+// по diskChunkPoints точек
+func writeDiskPoints(w io.Writer, data []models.MetricData, keys *encryption.Keyring) error {
+	if keys == nil {
+		encoder := json.NewEncoder(w)
+		for _, m := range data {
+			if err := encoder.Encode(m); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	for start := 0; start < len(data); start += diskChunkPoints {
+		var chunk bytes.Buffer
+		if err := writeDiskPoints(&chunk, data[start:min(start+diskChunkPoints, len(data))], nil); err != nil {
+			return err
+		}
+		sealed, err := keys.Seal(chunk.Bytes())
+		if err != nil {
+			return err
+		}
+		line := make([]byte, 1+base64.StdEncoding.EncodedLen(len(sealed))+1)
+		line[0] = diskChunkPrefix
+		base64.StdEncoding.Encode(line[1:], sealed)
+		line[len(line)-1] = '\n'
+		if _, err := w.Write(line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func readDiskSeries(path string, keys *encryption.Keyring, keep func(models.MetricData) bool) ([]models.MetricData, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var data []models.MetricData
+	add := func(line []byte) {
+		var m models.MetricData
+		if err := json.Unmarshal(line, &m); err != nil {
+			return // Оборванная при падении последняя строка
+		}
+		if keep == nil || keep(m) {
+			data = append(data, m)
+		}
+	}
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 4096), 4<<20)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 || line[0] != diskChunkPrefix {
+			add(line)
+			continue
+		}
+
+		sealed, err := base64.StdEncoding.DecodeString(string(line[1:]))
+		if err != nil {
+			continue // Оборванный при падении последний блок
+		}
+		if keys == nil {
+			return nil, fmt.Errorf("%s is encrypted and no encryption keys are configured", path)
+		}
+		chunk, err := keys.Open(sealed)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		for _, point := range bytes.Split(chunk, []byte{'\n'}) {
+			if len(point) > 0 {
+				add(point)
+			}
+		}
+	}
+	return data, scanner.Err()
+}
+
+func (s *DiskStore) Replace(serverID string, data []models.MetricData) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.rewrite(serverID, s.path(serverID), data)
+}
+
+// rewrite атомарно заменяет файл серии; пустые данные удаляют серию
+func (s *DiskStore) rewrite(serverID, path string, data []models.MetricData) error {
+	if len(data) == 0 {
+		delete(s.series, serverID)
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+
+	tmp := path + ".tmp"
+	file, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(file)
+	if err := writeDiskPoints(w, data, s.keys); err != nil {
+		file.Close()
+		os.Remove(tmp)
+		return err
+	}
+	oldest := int64(math.MaxInt64)
+	for _, m := range data {
+		oldest = min(oldest, m.Timestamp)
+	}
+	if err := w.Flush(); err != nil {
+		file.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return err
+	}
+	s.series[serverID] = &diskSeries{path: path, oldest: oldest}
+	return nil
+}
+
+func (s *DiskStore) ServerIDs() ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ids := make([]string, 0, len(s.series))
+	for serverID := range s.series {
+		ids = append(ids, serverID)
+	}
+	return ids, nil
+}
+
+// Prune переписывает только серии, в которых есть устаревшие точки
+func (s *DiskStore) Prune(cutoff time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	limit := cutoff.Unix()
+	for serverID, series := range s.series {
+		if series.oldest > limit {
+			continue
+		}
+		kept, err := readDiskSeries(series.path, s.keys, func(m models.MetricData) bool { return m.Timestamp > limit })
+		if err != nil {
+			return fmt.Errorf("server %s: %w", serverID, err)
+		}
+		if err := s.rewrite(serverID, series.path, kept); err != nil {
+			return fmt.Errorf("server %s: %w", serverID, err)
+		}
+	}
+	return nil
+}
+
+// Reencrypt переписывает все серии текущим основным ключом, например после
+// смены ключа, чтобы прежний ключ можно было удалить. Возвращает число
+// переписанных серий.
+func (s *DiskStore) Reencrypt() (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rewritten := 0
+	for serverID, series := range s.series {
+		data, err := readDiskSeries(series.path, s.keys, nil)
+		if err != nil {
+			return rewritten, fmt.Errorf("server %s: %w", serverID, err)
+		}
+		if err := s.rewrite(serverID, series.path, data); err != nil {
+			return rewritten, fmt.Errorf("server %s: %w", serverID, err)
+		}
+		rewritten++
+	}
+	return rewritten, nil
+}
@@ -0,0 +1,109 @@
+package metrics
+
+import (
+	"sort"
+	"time"
+
+	"github.com/YumeNoTenshi/platypus/internal/models"
+)
+
+const joulesPerKWh = 3.6e6
+
+// EnergyBucket - энергия, потребленная за интервал фиксированной длины
+type EnergyBucket struct {
+	Start    time.Time `json:"start"`
+	KWh      float64   `json:"kwh"`
+	Coverage float64   `json:"coverage"` // Доля интервала, покрытая данными (0-1)
+}
+
+// IntegrateEnergy переводит ряд мощности в потребленную энергию по интервалам
+// длины bucket (метод трапеций). Интервалы выравниваются по UTC. Соседние точки,
+// разнесенные больше чем на maxGap, не интегрируются: энергия за пропуск не
+// выдумывается, а неполнота отражается в Coverage. maxGap <= 0 снимает ограничение.
+// Агрегированная точка (Resolution > 0) считается постоянной мощностью на своем интервале.
+func IntegrateEnergy(data []models.MetricData, bucket, maxGap time.Duration) []EnergyBucket {
+	if len(data) == 0 || bucket <= 0 {
+		return nil
+	}
+
+	points := append([]models.MetricData(nil), data...)
+	sort.Slice(points, func(i, j int) bool {
+		return points[i].Timestamp < points[j].Timestamp
+	})
+
+	size := int64(bucket / time.Second)
+	joules := make(map[int64]float64)
+	covered := make(map[int64]int64)
+
+	for _, p := range points {
+		if p.Resolution <= 0 {
+			continue
+		}
+		for cur, stop := p.Timestamp, p.Timestamp+p.Resolution; cur < stop; {
+			start := floorDiv(cur, size) * size
+			end := start + size
+			if end > stop {
+				end = stop
+			}
+			joules[start] += p.PowerUsage * float64(end-cur)
+			covered[start] += end - cur
+			cur = end
+		}
+	}
+
+	for i := 1; i < len(points); i++ {
+		a, b := points[i-1], points[i]
+		if a.Resolution > 0 {
+			continue // Интервал агрегированной точки уже учтен
+		}
+		span := b.Timestamp - a.Timestamp
+		if span <= 0 || (maxGap > 0 && time.Duration(span)*time.Second > maxGap) {
+			continue
+		}
+
+		// Мощность внутри отрезка меняется линейно; отрезок делится по границам интервалов
+		powerAt := func(t int64) float64 {
+			return a.PowerUsage + (b.PowerUsage-a.PowerUsage)*float64(t-a.Timestamp)/float64(span)
+		}
+		for cur := a.Timestamp; cur < b.Timestamp; {
+			start := floorDiv(cur, size) * size
+			end := start + size
+			if end > b.Timestamp {
+				end = b.Timestamp
+			}
+			joules[start] += (powerAt(cur) + powerAt(end)) / 2 * float64(end-cur)
+			covered[start] += end - cur
+			cur = end
+		}
+	}
+
+	buckets := make([]EnergyBucket, 0, len(joules))
+	for start, value := range joules {
+		buckets = append(buckets, EnergyBucket{
+			Start:    time.Unix(start, 0).UTC(),
+			KWh:      value / joulesPerKWh,
+			Coverage: float64(covered[start]) / float64(size),
+		})
+	}
+	sort.Slice(buckets, func(i, j int) bool {
+		return buckets[i].Start.Before(buckets[j].Start)
+	})
+	return buckets
+}
+
+// TotalKWh суммирует энергию интервалов
+func TotalKWh(buckets []EnergyBucket) float64 {
+	var total float64
+	for _, b := range buckets {
+		total += b.KWh
+	}
+	return total
+}
+
+func floorDiv(a, b int64) int64 {
+	q := a / b
+	if a%b != 0 && (a < 0) != (b < 0) {
+		q--
+	}
+	return q
+}
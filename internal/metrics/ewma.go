@@ -0,0 +1,114 @@
+package metrics
+
+import (
+	"math"
+	"time"
+
+	"github.com/YumeNoTenshi/platypus/internal/models"
+)
+
+const (
+	// defaultEWMALambda - коэффициент сглаживания, если SmoothingFactor не в (0, 1)
+	defaultEWMALambda = 0.2
+	// ewmaRelearnAfter - после стольких аномалий подряд по одну сторону
+	// уровня сдвиг считается новой нормой, и уровень переучивается
+	ewmaRelearnAfter = 5
+	// minRelativeSigma - нижняя граница разброса в долях среднего: ряд без
+	// разброса не делает аномалией любое изменение
+	minRelativeSigma = 0.01
+)
+
+// ewmaDetector ищет аномалии в один проход, как на потоке: точка
+// сравнивается с EWMA предыдущих точек и полосой из экспоненциально
+// взвешенного разброса. Аномальные точки не входят ни в уровень, ни в
+// разброс, поэтому всплеск не расширяет полосу для следующих точек.
+// Дрейф, не выходящий из полосы, ловит контрольная карта EWMA (Roberts,
+// 1959): сглаженный уровень сравнивается с базовой линией, об уходе
+// сообщается один раз, пока уровень не вернется.
+type ewmaDetector struct {
+	lambda    float64
+	threshold float64
+}
+
+func (d ewmaDetector) Detect(metrics []models.MetricData, dimension Dimension, baseline Baseline) []Anomaly {
+	lambda, threshold := d.lambda, d.threshold
+	mean, stdDev := baseline.Mean, baseline.StdDev
+	floor := math.Max(math.Abs(mean)*minRelativeSigma, 1e-9)
+	spread := math.Max(stdDev, floor)
+	// Разброс сглаженного уровня в установившемся режиме
+	chartSigma := spread * math.Sqrt(lambda/(2-lambda))
+
+	level, variance := mean, spread*spread
+	var (
+		anomalies []Anomaly
+		streak    int // Аномалии подряд; знак - сторона уровня
+		drift     int // Текущий дрейф: 1 вверх, -1 вниз, 0 нет
+	)
+	for _, m := range metrics {
+		// Восстановленные точки не могут быть аномалией
+		if m.Interpolated {
+			continue
+		}
+		value := dimension.Value(m)
+		deviation := value - level
+		sigma := math.Max(math.Sqrt(variance), floor)
+
+		if severity := math.Abs(deviation) / sigma; severity > threshold {
+			anomalies = append(anomalies, Anomaly{
+				Timestamp: time.Unix(m.Timestamp, 0),
+				Value:     value,
+				Type:      classifyAnomaly(value, level),
+				Severity:  severity,
+			})
+			if streak*sign(deviation) < 0 {
+				streak = 0
+			}
+			streak += sign(deviation)
+			if abs(streak) < ewmaRelearnAfter {
+				continue
+			}
+			// Сдвиг уровня держится: дальше точки сравниваются с новым
+			level, variance, streak = value, spread*spread, 0
+		} else {
+			streak = 0
+			level += lambda * deviation
+			variance = (1 - lambda) * (variance + lambda*deviation*deviation)
+		}
+
+		shift := (level - mean) / chartSigma
+		switch {
+		case math.Abs(shift) <= threshold:
+			drift = 0
+		case drift != sign(shift):
+			drift = sign(shift)
+			anomalyType := "drift_up"
+			if drift < 0 {
+				anomalyType = "drift_down"
+			}
+			anomalies = append(anomalies, Anomaly{
+				Timestamp: time.Unix(m.Timestamp, 0),
+				Value:     level,
+				Type:      anomalyType,
+				Severity:  math.Abs(shift),
+			})
+		}
+	}
+	return anomalies
+}
+
+func sign(value float64) int {
+	switch {
+	case value > 0:
+		return 1
+	case value < 0:
+		return -1
+	}
+	return 0
+}
+
+func abs(value int) int {
+	if value < 0 {
+		return -value
+	}
+	return value
+}
@@ -0,0 +1,110 @@
+package metrics
+
+import (
+	"math"
+	"time"
+
+	"github.com/YumeNoTenshi/platypus/internal/models"
+)
+
+// FilterConfig задает фильтрацию шума, применяемую при чтении метрик
+type FilterConfig struct {
+	SmoothingFactor    float64       // Коэффициент EWMA (0-1]; 0 отключает сглаживание
+	ExpectedInterval   time.Duration // Ожидаемый шаг между точками; 0 отключает заполнение пропусков
+	MaxGapIntervals    int           // Максимальное число пропущенных интервалов для интерполяции
+	TreatZeroAsMissing bool          // Нулевые показания считаются выпадением датчика, а не реальным значением
+}
+
+// ApplyFilters заполняет короткие пропуски линейной интерполяцией и сглаживает ряд.
+// Интерполированные точки помечаются флагом Interpolated. Исходный срез не изменяется.
+func ApplyFilters(data []models.MetricData, config FilterConfig) []models.MetricData {
+	result := make([]models.MetricData, 0, len(data))
+	for _, m := range data {
+		if config.TreatZeroAsMissing && m.PowerUsage == 0 && m.CPUUsage == 0 {
+			continue
+		}
+		result = append(result, m)
+	}
+
+	if config.ExpectedInterval > 0 && config.MaxGapIntervals > 0 {
+		result = fillGaps(result, config.ExpectedInterval, config.MaxGapIntervals)
+	}
+
+	if config.SmoothingFactor > 0 && config.SmoothingFactor < 1 {
+		smooth(result, config.SmoothingFactor)
+	}
+
+	return result
+}
+
+func fillGaps(data []models.MetricData, interval time.Duration, maxGap int) []models.MetricData {
+	if len(data) < 2 {
+		return data
+	}
+
+	step := int64(interval.Seconds())
+	if step <= 0 {
+		return data
+	}
+
+	filled := make([]models.MetricData, 0, len(data))
+	filled = append(filled, data[0])
+
+	for i := 1; i < len(data); i++ {
+		prev, next := data[i-1], data[i]
+		missing := int(math.Round(float64(next.Timestamp-prev.Timestamp)/float64(step))) - 1
+
+		// Длинные пропуски не интерполируем: это реальный простой, а не шум
+		if missing >= 1 && missing <= maxGap {
+			for k := 1; k <= missing; k++ {
+				ratio := float64(k) / float64(missing+1)
+				filled = append(filled, models.MetricData{
+					ServerID:             prev.ServerID,
+					TenantID:             prev.TenantID,
+					Timestamp:            prev.Timestamp + int64(k)*step,
+					PowerUsage:           lerp(prev.PowerUsage, next.PowerUsage, ratio),
+					PowerModel:           prev.PowerModel,
+					CarbonFootprint:      lerp(prev.CarbonFootprint, next.CarbonFootprint, ratio),
+					CPUUsage:             lerp(prev.CPUUsage, next.CPUUsage, ratio),
+					MemoryUsage:          lerp(prev.MemoryUsage, next.MemoryUsage, ratio),
+					GPUUsage:             lerp(prev.GPUUsage, next.GPUUsage, ratio),
+					GPUPowerUsage:        lerp(prev.GPUPowerUsage, next.GPUPowerUsage, ratio),
+					NetworkRxBytes:       lerp(prev.NetworkRxBytes, next.NetworkRxBytes, ratio),
+					NetworkTxBytes:       lerp(prev.NetworkTxBytes, next.NetworkTxBytes, ratio),
+					DiskReadBytesPerSec:  lerp(prev.DiskReadBytesPerSec, next.DiskReadBytesPerSec, ratio),
+					DiskWriteBytesPerSec: lerp(prev.DiskWriteBytesPerSec, next.DiskWriteBytesPerSec, ratio),
+					StorageUsedBytes:     lerp(prev.StorageUsedBytes, next.StorageUsedBytes, ratio),
+					InletTemperature:     lerp(prev.InletTemperature, next.InletTemperature, ratio),
+					Interpolated:         true,
+					Labels:               prev.Labels,
+				})
+			}
+		}
+
+		filled = append(filled, next)
+	}
+
+	return filled
+}
+
+func smooth(data []models.MetricData, alpha float64) {
+	for i := 1; i < len(data); i++ {
+		prev := data[i-1]
+		data[i].PowerUsage = ewma(prev.PowerUsage, data[i].PowerUsage, alpha)
+		data[i].CarbonFootprint = ewma(prev.CarbonFootprint, data[i].CarbonFootprint, alpha)
+		data[i].CPUUsage = ewma(prev.CPUUsage, data[i].CPUUsage, alpha)
+		data[i].MemoryUsage = ewma(prev.MemoryUsage, data[i].MemoryUsage, alpha)
+		data[i].GPUUsage = ewma(prev.GPUUsage, data[i].GPUUsage, alpha)
+		data[i].GPUPowerUsage = ewma(prev.GPUPowerUsage, data[i].GPUPowerUsage, alpha)
+		data[i].DiskReadBytesPerSec = ewma(prev.DiskReadBytesPerSec, data[i].DiskReadBytesPerSec, alpha)
+		data[i].DiskWriteBytesPerSec = ewma(prev.DiskWriteBytesPerSec, data[i].DiskWriteBytesPerSec, alpha)
+	}
+}
+
+func lerp(a, b, ratio float64) float64 {
+	return a + (b-a)*ratio
+}
+
+func ewma(prev, current, alpha float64) float64 {
+	return alpha*current + (1-alpha)*prev
+}
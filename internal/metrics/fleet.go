@@ -0,0 +1,82 @@
+package metrics
+
+import "sort"
+
+// defaultFleetTop - сколько худших серверов попадает в сводку по умолчанию
+const defaultFleetTop = 10
+
+// FleetServer - сводка сервера за окно анализа
+type FleetServer struct {
+	ServerID           string  `json:"server_id"`
+	InstanceType       string  `json:"instance_type,omitempty"`
+	PowerUsage         float64 `json:"power_usage"`      // Среднее потребление, Вт
+	CarbonFootprint    float64 `json:"carbon_footprint"` // Сумма за окно, кг CO2
+	EcoScore           float64 `json:"eco_score"`
+	NormalizedEcoScore float64 `json:"normalized_eco_score"`
+}
+
+// FleetAnalysis - сводка по всем серверам за окно анализа. Худшие серверы
+// выбираются по нормализованному рейтингу: сравнивается эффективность, а
+// не размер инстанса.
+type FleetAnalysis struct {
+	Servers               int           `json:"servers"`
+	Reporting             int           `json:"reporting"`    // Серверы с точками в окне
+	TotalPower            float64       `json:"total_power"`  // Сумма средних потреблений, Вт
+	TotalCarbon           float64       `json:"total_carbon"` // Сумма за окно, кг CO2
+	AvgEcoScore           float64       `json:"avg_eco_score"`
+	AvgNormalizedEcoScore float64       `json:"avg_normalized_eco_score"`
+	Worst                 []FleetServer `json:"worst"`
+}
+
+// AnalyzeFleet сводит потребление, углеродный след и эко-рейтинг серверов
+// за окно анализа и возвращает top серверов с худшим рейтингом (0 - 10).
+// serverIDs ограничивает серверы; nil - все серверы источника.
+func (a *Analyzer) AnalyzeFleet(serverIDs []string, top int) *FleetAnalysis {
+	if serverIDs == nil {
+		serverIDs = a.source.ServerIDs()
+	}
+	if top <= 0 {
+		top = defaultFleetTop
+	}
+
+	fleet := &FleetAnalysis{Servers: len(serverIDs)}
+	servers := make([]FleetServer, 0, len(serverIDs))
+	for _, serverID := range serverIDs {
+		data, err := a.recentMetrics(serverID)
+		if err != nil || len(data) == 0 {
+			continue
+		}
+
+		server := FleetServer{ServerID: serverID}
+		for _, m := range data {
+			server.PowerUsage += m.PowerUsage
+			server.CarbonFootprint += m.CarbonFootprint
+		}
+		server.PowerUsage /= float64(len(data))
+		scores := a.CalculateEcoScores(serverID, data)
+		server.InstanceType = scores.InstanceType
+		server.EcoScore = scores.Raw
+		server.NormalizedEcoScore = scores.Normalized
+
+		servers = append(servers, server)
+		fleet.TotalPower += server.PowerUsage
+		fleet.TotalCarbon += server.CarbonFootprint
+		fleet.AvgEcoScore += server.EcoScore
+		fleet.AvgNormalizedEcoScore += server.NormalizedEcoScore
+	}
+
+	fleet.Reporting = len(servers)
+	if len(servers) > 0 {
+		fleet.AvgEcoScore /= float64(len(servers))
+		fleet.AvgNormalizedEcoScore /= float64(len(servers))
+	}
+
+	sort.Slice(servers, func(i, j int) bool {
+		if servers[i].NormalizedEcoScore != servers[j].NormalizedEcoScore {
+			return servers[i].NormalizedEcoScore < servers[j].NormalizedEcoScore
+		}
+		return servers[i].ServerID < servers[j].ServerID
+	})
+	fleet.Worst = servers[:min(top, len(servers))]
+	return fleet
+}
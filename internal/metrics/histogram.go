@@ -0,0 +1,109 @@
+package metrics
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/YumeNoTenshi/platypus/internal/models"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	// DefaultPowerBuckets - границы корзин гистограммы потребления, Вт: от
+	// простаивающего сервера до узла с несколькими GPU
+	DefaultPowerBuckets = []float64{25, 50, 75, 100, 150, 200, 300, 400, 500, 750, 1000, 1500, 2000, 3000}
+	// DefaultCPUBuckets - границы корзин гистограммы загрузки CPU, %
+	DefaultCPUBuckets = prometheus.LinearBuckets(10, 10, 10)
+)
+
+// ParseBuckets разбирает границы корзин гистограммы через запятую, например
+// "50,100,200,400,800"; границы должны возрастать
+func ParseBuckets(value string) ([]float64, error) {
+	var buckets []float64
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		bound, err := strconv.ParseFloat(item, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid bucket bound %q", item)
+		}
+		if len(buckets) > 0 && bound <= buckets[len(buckets)-1] {
+			return nil, fmt.Errorf("bucket bounds must increase: %g after %g", bound, buckets[len(buckets)-1])
+		}
+		buckets = append(buckets, bound)
+	}
+	if len(buckets) == 0 {
+		return nil, fmt.Errorf("no bucket bounds")
+	}
+	return buckets, nil
+}
+
+// distributions - гистограммы распределения потребления и загрузки CPU.
+// Датчики показывают только последнее значение сервера; гистограммы
+// накапливают все принятые точки, и квантили считаются в PromQL:
+//
+//	histogram_quantile(0.95, sum by (le, team) (rate(server_power_usage_watts_distribution_bucket[1h])))
+//
+// Метки - регион и метки точек без server_id: серия на сервер умножила бы
+// число серий на число корзин. Квантили одного сервера во времени дает
+// quantile_over_time по датчику.
+type distributions struct {
+	power *prometheus.HistogramVec
+	cpu   *prometheus.HistogramVec
+}
+
+func newDistributions(config CollectorConfig, metricLabels []string) *distributions {
+	powerBuckets, cpuBuckets := config.PowerBuckets, config.CPUBuckets
+	if len(powerBuckets) == 0 {
+		powerBuckets = DefaultPowerBuckets
+	}
+	if len(cpuBuckets) == 0 {
+		cpuBuckets = DefaultCPUBuckets
+	}
+	labelNames := append([]string{"region"}, metricLabels...)
+	return &distributions{
+		power: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "server_power_usage_watts_distribution",
+				Help:    "Distribution of reported server power usage in watts",
+				Buckets: powerBuckets,
+			},
+			labelNames,
+		),
+		cpu: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "server_cpu_usage_percent_distribution",
+				Help:    "Distribution of reported server CPU usage in percent",
+				Buckets: cpuBuckets,
+			},
+			labelNames,
+		),
+	}
+}
+
+func (d *distributions) collectors() []prometheus.Collector {
+	return []prometheus.Collector{d.power, d.cpu}
+}
+
+// observe учитывает точку; labels - метки серий датчиков сервера
+func (d *distributions) observe(labels prometheus.Labels, metric models.MetricData) {
+	histogramLabels := make(prometheus.Labels, len(labels))
+	for name, value := range labels {
+		if name != "server_id" {
+			histogramLabels[name] = value
+		}
+	}
+	d.power.With(histogramLabels).Observe(metric.PowerUsage)
+	d.cpu.With(histogramLabels).Observe(metric.CPUUsage)
+}
+
+// forget удаляет серии гистограмм с метками labels. У гистограмм нет
+// server_id, поэтому удалять серию можно, только когда в нее больше не
+// пишет ни один сервер.
+func (d *distributions) forget(labels prometheus.Labels) {
+	d.power.DeletePartialMatch(labels)
+	d.cpu.DeletePartialMatch(labels)
+}
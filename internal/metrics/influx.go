@@ -0,0 +1,365 @@
+package metrics
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/YumeNoTenshi/platypus/internal/models"
+)
+
+type InfluxConfig struct {
+	URL         string // Адрес InfluxDB 2.x, например http://influx:8086
+	Org         string
+	Bucket      string
+	Token       string
+	Measurement string        // По умолчанию server_metrics
+	Lookback    time.Duration // Насколько глубоко читать данные; обычно равен RetentionPeriod
+	Timeout     time.Duration
+}
+
+// InfluxStore пишет точки в InfluxDB 2.x в line protocol и читает их через Flux.
+// Каждый показатель - отдельное поле, server_id - тег.
+type InfluxStore struct {
+	config InfluxConfig
+	client *http.Client
+}
+
+func NewInfluxStore(config InfluxConfig) (*InfluxStore, error) {
+	if config.URL == "" || config.Org == "" || config.Bucket == "" {
+		return nil, fmt.Errorf("influx url, org and bucket are required")
+	}
+	if config.Measurement == "" {
+		config.Measurement = "server_metrics"
+	}
+	if config.Lookback <= 0 {
+		config.Lookback = 7 * 24 * time.Hour
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = 10 * time.Second
+	}
+	config.URL = strings.TrimRight(config.URL, "/")
+
+	return &InfluxStore{
+		config: config,
+		client: &http.Client{Timeout: config.Timeout},
+	}, nil
+}
+
+func (s *InfluxStore) Append(batch MetricBatch) error {
+	var body bytes.Buffer
+	for _, m := range batch.Metrics {
+		// Точка без собственной метки времени получает время пакета
+		if m.Timestamp == 0 {
+			m.Timestamp = batch.Timestamp.Unix()
+		}
+		s.writeLine(&body, batch.ServerID, m)
+	}
+	if body.Len() == 0 {
+		return nil
+	}
+	return s.write(&body)
+}
+
+// writeLine формирует строку line protocol с точностью до секунды
+func (s *InfluxStore) writeLine(w *bytes.Buffer, serverID string, m models.MetricData) {
+	fmt.Fprintf(w, "%s,server_id=%s", escapeInfluxMeasurement(s.config.Measurement), escapeInfluxTag(serverID))
+	if m.TenantID != "" {
+		fmt.Fprintf(w, ",tenant_id=%s", escapeInfluxTag(m.TenantID))
+	}
+	// Метки точки пишутся тегами с префиксом label_ в порядке имен, как
+	// рекомендует line protocol; пустые значения тегов Influx не принимает
+	names := make([]string, 0, len(m.Labels))
+	for name, value := range m.Labels {
+		if value != "" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(w, ",%s%s=%s", influxLabelPrefix, escapeInfluxTag(name), escapeInfluxTag(m.Labels[name]))
+	}
+	fmt.Fprintf(w, " power_usage=%s,carbon_footprint=%s,cpu_usage=%s,memory_usage=%s",
+		influxFloat(m.PowerUsage), influxFloat(m.CarbonFootprint), influxFloat(m.CPUUsage), influxFloat(m.MemoryUsage))
+	if m.Interpolated {
+		w.WriteString(",interpolated=true")
+	}
+	if m.PowerModel != "" {
+		fmt.Fprintf(w, ",power_model=\"%s\"", escapeInfluxString(m.PowerModel))
+	}
+	if m.EnergyCounter > 0 {
+		fmt.Fprintf(w, ",energy_counter=%s", influxFloat(m.EnergyCounter))
+	}
+	if m.GPUUsage > 0 || m.GPUPowerUsage > 0 {
+		fmt.Fprintf(w, ",gpu_usage=%s,gpu_power_usage=%s", influxFloat(m.GPUUsage), influxFloat(m.GPUPowerUsage))
+	}
+	if m.NetworkRxBytes > 0 || m.NetworkTxBytes > 0 {
+		fmt.Fprintf(w, ",network_rx_bytes=%s,network_tx_bytes=%s", influxFloat(m.NetworkRxBytes), influxFloat(m.NetworkTxBytes))
+	}
+	if m.DiskReadBytesPerSec > 0 || m.DiskWriteBytesPerSec > 0 || m.StorageUsedBytes > 0 {
+		fmt.Fprintf(w, ",disk_read_bps=%s,disk_write_bps=%s,storage_used_bytes=%s",
+			influxFloat(m.DiskReadBytesPerSec), influxFloat(m.DiskWriteBytesPerSec), influxFloat(m.StorageUsedBytes))
+	}
+	if m.InletTemperature != 0 {
+		fmt.Fprintf(w, ",inlet_temp_c=%s", influxFloat(m.InletTemperature))
+	}
+	fmt.Fprintf(w, " %d\n", m.Timestamp)
+}
+
+func (s *InfluxStore) write(body io.Reader) error {
+	query := url.Values{"org": {s.config.Org}, "bucket": {s.config.Bucket}, "precision": {"s"}}
+	resp, err := s.do(http.MethodPost, "/api/v2/write?"+query.Encode(), "text/plain; charset=utf-8", body)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// Metrics возвращает точки сервера за Lookback в порядке времени
+func (s *InfluxStore) Metrics(serverID string) ([]models.MetricData, error) {
+	data, err := s.points(serverID, "-"+fluxDuration(s.config.Lookback), "now()")
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return nil, fmt.Errorf("no metrics found for server: %s", serverID)
+	}
+	return data, nil
+}
+
+func (s *InfluxStore) Range(serverID string, from, to time.Time) ([]models.MetricData, error) {
+	return s.points(serverID, fluxTime(from), fluxTime(to))
+}
+
+// points читает точки сервера в интервале [start, stop), заданном литералами Flux
+func (s *InfluxStore) points(serverID, start, stop string) ([]models.MetricData, error) {
+	rows, err := s.query(fmt.Sprintf(`from(bucket: %s)
+  |> range(start: %s, stop: %s)
+  |> filter(fn: (r) => r._measurement == %s and r.server_id == %s)
+  |> pivot(rowKey: ["_time"], columnKey: ["_field"], valueColumn: "_value")
+  |> group()
+  |> sort(columns: ["_time"])`,
+		fluxString(s.config.Bucket), start, stop, fluxString(s.config.Measurement), fluxString(serverID)))
+	if err != nil {
+		return nil, err
+	}
+
+	data := make([]models.MetricData, 0, len(rows))
+	for _, row := range rows {
+		at, err := time.Parse(time.RFC3339Nano, row["_time"])
+		if err != nil {
+			return nil, fmt.Errorf("invalid influx timestamp %q: %w", row["_time"], err)
+		}
+		data = append(data, models.MetricData{
+			ServerID:             serverID,
+			TenantID:             row["tenant_id"],
+			Timestamp:            at.Unix(),
+			PowerUsage:           parseInfluxFloat(row["power_usage"]),
+			PowerModel:           row["power_model"],
+			CarbonFootprint:      parseInfluxFloat(row["carbon_footprint"]),
+			CPUUsage:             parseInfluxFloat(row["cpu_usage"]),
+			MemoryUsage:          parseInfluxFloat(row["memory_usage"]),
+			GPUUsage:             parseInfluxFloat(row["gpu_usage"]),
+			GPUPowerUsage:        parseInfluxFloat(row["gpu_power_usage"]),
+			NetworkRxBytes:       parseInfluxFloat(row["network_rx_bytes"]),
+			NetworkTxBytes:       parseInfluxFloat(row["network_tx_bytes"]),
+			DiskReadBytesPerSec:  parseInfluxFloat(row["disk_read_bps"]),
+			DiskWriteBytesPerSec: parseInfluxFloat(row["disk_write_bps"]),
+			StorageUsedBytes:     parseInfluxFloat(row["storage_used_bytes"]),
+			InletTemperature:     parseInfluxFloat(row["inlet_temp_c"]),
+			Interpolated:         row["interpolated"] == "true",
+			EnergyCounter:        parseInfluxFloat(row["energy_counter"]),
+			Labels:               influxLabels(row),
+		})
+	}
+	return data, nil
+}
+
+func (s *InfluxStore) Replace(serverID string, data []models.MetricData) error {
+	predicate := fmt.Sprintf(`_measurement=%s AND server_id=%s`, fluxString(s.config.Measurement), fluxString(serverID))
+	if err := s.delete(time.Unix(0, 0), time.Now(), predicate); err != nil {
+		return err
+	}
+	return s.Append(MetricBatch{ServerID: serverID, Metrics: data, Timestamp: time.Now()})
+}
+
+func (s *InfluxStore) ServerIDs() ([]string, error) {
+	rows, err := s.query(fmt.Sprintf(`import "influxdata/influxdb/schema"
+schema.tagValues(bucket: %s, tag: "server_id", predicate: (r) => r._measurement == %s, start: -%s)`,
+		fluxString(s.config.Bucket), fluxString(s.config.Measurement), fluxDuration(s.config.Lookback)))
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, 0, len(rows))
+	for _, row := range rows {
+		ids = append(ids, row["_value"])
+	}
+	return ids, nil
+}
+
+// Prune удаляет точки старше cutoff. Обычно это дублирует политику хранения
+// бакета, но позволяет соблюдать RetentionPeriod сборщика при более длинной политике.
+func (s *InfluxStore) Prune(cutoff time.Time) error {
+	return s.delete(time.Unix(0, 0), cutoff, fmt.Sprintf(`_measurement=%s`, fluxString(s.config.Measurement)))
+}
+
+func (s *InfluxStore) delete(start, stop time.Time, predicate string) error {
+	body, err := json.Marshal(map[string]string{
+		"start":     start.UTC().Format(time.RFC3339),
+		"stop":      stop.UTC().Format(time.RFC3339),
+		"predicate": predicate,
+	})
+	if err != nil {
+		return err
+	}
+
+	query := url.Values{"org": {s.config.Org}, "bucket": {s.config.Bucket}}
+	resp, err := s.do(http.MethodPost, "/api/v2/delete?"+query.Encode(), "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// query выполняет Flux-запрос и возвращает строки аннотированного CSV как словари колонка -> значение
+func (s *InfluxStore) query(flux string) ([]map[string]string, error) {
+	body, err := json.Marshal(map[string]interface{}{
+		"query":   flux,
+		"type":    "flux",
+		"dialect": map[string]interface{}{"header": true, "annotations": []string{}},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := s.do(http.MethodPost, "/api/v2/query?"+url.Values{"org": {s.config.Org}}.Encode(), "application/json", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	reader := csv.NewReader(resp.Body)
+	reader.FieldsPerRecord = -1
+
+	// Каждая таблица результата начинается со своей строки заголовка
+	var header []string
+	var rows []map[string]string
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse influx response: %w", err)
+		}
+		if len(record) < 2 {
+			header = nil
+			continue
+		}
+		if record[1] == "result" {
+			header = record
+			continue
+		}
+		if header == nil {
+			continue
+		}
+
+		row := make(map[string]string, len(header))
+		for i, column := range header {
+			if i < len(record) {
+				row[column] = record[i]
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+func (s *InfluxStore) do(method, path, contentType string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequest(method, s.config.URL+path, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("Accept", "application/csv")
+	if s.config.Token != "" {
+		req.Header.Set("Authorization", "Token "+s.config.Token)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 != 2 {
+		defer resp.Body.Close()
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("influx responded with status %d: %s", resp.StatusCode, strings.TrimSpace(string(message)))
+	}
+	return resp, nil
+}
+
+// influxLabelPrefix отделяет теги меток точки от служебных тегов
+const influxLabelPrefix = "label_"
+
+// influxLabels собирает метки точки из тегов label_* строки ответа
+func influxLabels(row map[string]string) map[string]string {
+	var labels map[string]string
+	for column, value := range row {
+		name, found := strings.CutPrefix(column, influxLabelPrefix)
+		if !found || value == "" {
+			continue
+		}
+		if labels == nil {
+			labels = make(map[string]string)
+		}
+		labels[name] = value
+	}
+	return labels
+}
+
+func escapeInfluxMeasurement(value string) string {
+	return strings.NewReplacer(",", `\,`, " ", `\ `).Replace(value)
+}
+
+func escapeInfluxTag(value string) string {
+	return strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `).Replace(value)
+}
+
+// escapeInfluxString экранирует значение строкового поля line protocol
+func escapeInfluxString(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(value)
+}
+
+func influxFloat(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
+
+func parseInfluxFloat(value string) float64 {
+	parsed, _ := strconv.ParseFloat(value, 64)
+	return parsed
+}
+
+// fluxString возвращает строковый литерал Flux
+func fluxString(value string) string {
+	return strconv.Quote(value)
+}
+
+func fluxTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
+
+// fluxDuration переводит длительность в литерал Flux с точностью до секунды
+func fluxDuration(d time.Duration) string {
+	return fmt.Sprintf("%ds", int64(d/time.Second))
+}
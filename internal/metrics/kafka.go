@@ -0,0 +1,230 @@
+package metrics
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/YumeNoTenshi/platypus/internal/errtrack"
+	"github.com/YumeNoTenshi/platypus/internal/models"
+)
+
+const (
+	kafkaRetryBackoff = 5 * time.Second        // Пауза после ошибки чтения или фиксации смещения
+	kafkaBufferWait   = 100 * time.Millisecond // Пауза, пока буфер сборщика заполнен
+)
+
+// KafkaMessage - сообщение, прочитанное из топика
+type KafkaMessage struct {
+	Topic     string
+	Partition int
+	Offset    int64
+	Key       []byte
+	Value     []byte
+}
+
+// KafkaReader читает сообщения топика в составе группы потребителей.
+// Смещение фиксируется только явно, после обработки сообщения.
+type KafkaReader interface {
+	FetchMessage(ctx context.Context) (KafkaMessage, error)
+	CommitMessages(ctx context.Context, messages ...KafkaMessage) error
+	Close() error
+}
+
+// NewKafkaReader создает клиента Kafka. Клиент подключается только в сборке
+// с тегом kafka (см. cmd/server/kafka.go); без него значение nil.
+var NewKafkaReader func(config KafkaConfig) (KafkaReader, error)
+
+type KafkaConfig struct {
+	Brokers []string
+	Topic   string
+	GroupID string
+	// Source - источник для проверки единиц, если сообщение его не указывает;
+	// пусто - идентификатор сервера
+	Source string
+	// Errors получает исход чтения топика под именем "metrics.kafka"
+	Errors errtrack.Recorder
+}
+
+// KafkaBatch - формат сообщения топика: JSON с точками одного сервера
+type KafkaBatch struct {
+	ServerID string              `json:"server_id"`
+	Source   string              `json:"source,omitempty"`
+	Metrics  []models.MetricData `json:"metrics"`
+}
+
+// KafkaStats - счетчики приема из Kafka для /status
+type KafkaStats struct {
+	Topic         string    `json:"topic"`
+	Messages      uint64    `json:"messages"`
+	Points        uint64    `json:"points"`
+	Rejected      uint64    `json:"rejected"`  // Точки, отклоненные проверкой единиц
+	Malformed     uint64    `json:"malformed"` // Сообщения, которые не удалось разобрать; пропускаются
+	LastMessageAt time.Time `json:"last_message_at,omitempty"`
+	LastError     string    `json:"last_error,omitempty"`
+}
+
+// KafkaIngester читает пакеты метрик из топика и передает их в буфер
+// сборщика. Смещение сообщения фиксируется после того, как все его точки
+// приняты буфером, поэтому при перезапуске сообщения не теряются.
+type KafkaIngester struct {
+	config    KafkaConfig
+	collector *Collector
+	reader    KafkaReader
+
+	mu    sync.Mutex
+	stats KafkaStats
+}
+
+func NewKafkaIngester(config KafkaConfig, collector *Collector) (*KafkaIngester, error) {
+	if NewKafkaReader == nil {
+		return nil, fmt.Errorf("kafka support is not compiled in; build with -tags kafka")
+	}
+	if len(config.Brokers) == 0 || config.Topic == "" || config.GroupID == "" {
+		return nil, fmt.Errorf("kafka brokers, topic and group id are required")
+	}
+
+	reader, err := NewKafkaReader(config)
+	if err != nil {
+		return nil, err
+	}
+	return &KafkaIngester{
+		config:    config,
+		collector: collector,
+		reader:    reader,
+		stats:     KafkaStats{Topic: config.Topic},
+	}, nil
+}
+
+// Start читает топик до отмены контекста
+func (k *KafkaIngester) Start(ctx context.Context) error {
+	defer k.reader.Close()
+
+	for {
+		message, err := k.reader.FetchMessage(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			k.fail(fmt.Errorf("fetch from %s: %w", k.config.Topic, err))
+			if !sleepContext(ctx, kafkaRetryBackoff) {
+				return ctx.Err()
+			}
+			continue
+		}
+
+		if err := k.handle(ctx, message); err != nil {
+			return err
+		}
+
+		// Смещение фиксируется и для неразобранных сообщений: повтор их не исправит
+		for {
+			err := k.reader.CommitMessages(ctx, message)
+			if err == nil {
+				break
+			}
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			k.fail(fmt.Errorf("commit offset %d of %s/%d: %w", message.Offset, message.Topic, message.Partition, err))
+			if !sleepContext(ctx, kafkaRetryBackoff) {
+				return ctx.Err()
+			}
+		}
+		k.succeed()
+	}
+}
+
+// handle разбирает сообщение и передает его точки сборщику. Возвращает
+// ошибку, только если контекст отменен до того, как все точки приняты.
+func (k *KafkaIngester) handle(ctx context.Context, message KafkaMessage) error {
+	var batch KafkaBatch
+	if err := json.Unmarshal(message.Value, &batch); err != nil || batch.ServerID == "" {
+		if err == nil {
+			err = fmt.Errorf("server_id is required")
+		}
+		log.Printf("Пропущено сообщение Kafka %s/%d@%d: %v", message.Topic, message.Partition, message.Offset, err)
+		k.update(func(stats *KafkaStats) { stats.Malformed++ })
+		return nil
+	}
+
+	source := batch.Source
+	if source == "" {
+		source = k.config.Source
+	}
+	if source == "" {
+		source = batch.ServerID
+	}
+
+	var accepted, rejected uint64
+	for _, point := range batch.Metrics {
+		point.ServerID = batch.ServerID
+		for {
+			err := k.collector.CollectMetricsFrom(source, batch.ServerID, point)
+			if !errors.Is(err, ErrBufferFull) {
+				if err != nil {
+					rejected++
+				} else {
+					accepted++
+				}
+				break
+			}
+			// Буфер заполнен: ждем, а не теряем точку, - Kafka подождет вместе с нами
+			if !sleepContext(ctx, kafkaBufferWait) {
+				return ctx.Err()
+			}
+		}
+	}
+
+	k.update(func(stats *KafkaStats) {
+		stats.Messages++
+		stats.Points += accepted
+		stats.Rejected += rejected
+		stats.LastMessageAt = time.Now()
+	})
+	return nil
+}
+
+// Stats возвращает счетчики приема
+func (k *KafkaIngester) Stats() KafkaStats {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return k.stats
+}
+
+func (k *KafkaIngester) update(fn func(stats *KafkaStats)) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	fn(&k.stats)
+}
+
+func (k *KafkaIngester) fail(err error) {
+	k.update(func(stats *KafkaStats) { stats.LastError = err.Error() })
+	if k.config.Errors != nil {
+		k.config.Errors.Record("metrics.kafka", err)
+	} else {
+		log.Printf("Ошибка приема метрик из Kafka: %v", err)
+	}
+}
+
+func (k *KafkaIngester) succeed() {
+	if k.config.Errors != nil {
+		k.config.Errors.Record("metrics.kafka", nil)
+	}
+}
+
+// sleepContext ждет d и возвращает false, если контекст отменен раньше
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
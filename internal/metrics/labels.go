@@ -0,0 +1,122 @@
+package metrics
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/YumeNoTenshi/platypus/internal/models"
+	"github.com/YumeNoTenshi/platypus/internal/selector"
+)
+
+// ErrInvalidLabels - метки точки не прошли проверку
+var ErrInvalidLabels = errors.New("invalid labels")
+
+const (
+	maxLabels          = 16
+	maxLabelValueBytes = 256
+)
+
+// DefaultMetricLabels - метки точек, которые по умолчанию становятся
+// метками серий Prometheus
+var DefaultMetricLabels = []string{"team", "environment", "namespace"}
+
+// labelName - допустимое имя метки, совпадает с правилами Prometheus
+var labelName = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// reservedLabels заняты метками серий коллектора
+var reservedLabels = map[string]bool{"server_id": true, "region": true}
+
+// ValidateLabels проверяет метки точки: не больше 16 меток, имена в формате
+// Prometheus без префикса __, значения не длиннее 256 байт
+func ValidateLabels(labels map[string]string) error {
+	if len(labels) > maxLabels {
+		return fmt.Errorf("%w: at most %d labels are allowed", ErrInvalidLabels, maxLabels)
+	}
+	for name, value := range labels {
+		if err := validateLabelName(name); err != nil {
+			return err
+		}
+		if len(value) > maxLabelValueBytes {
+			return fmt.Errorf("%w: value of label %s is longer than %d bytes", ErrInvalidLabels, name, maxLabelValueBytes)
+		}
+	}
+	return nil
+}
+
+func validateLabelName(name string) error {
+	if !labelName.MatchString(name) || strings.HasPrefix(name, "__") {
+		return fmt.Errorf("%w: invalid label name %q", ErrInvalidLabels, name)
+	}
+	if reservedLabels[name] {
+		return fmt.Errorf("%w: label name %s is reserved", ErrInvalidLabels, name)
+	}
+	return nil
+}
+
+// LabelSelector отбирает точки по меткам; синтаксис - пакет selector:
+// "team=payments,environment in (prod,staging),!canary"
+type LabelSelector struct {
+	selector.Selector
+}
+
+// ParseLabelSelector разбирает селектор точек. Условия должны ссылаться на
+// метки точек, а не на зарезервированные поля.
+func ParseLabelSelector(value string) (LabelSelector, error) {
+	parsed, err := selector.Parse(value)
+	if err != nil {
+		return LabelSelector{}, fmt.Errorf("%w: %v", ErrInvalidLabels, err)
+	}
+	for _, requirement := range parsed {
+		if err := validateLabelName(requirement.Key); err != nil {
+			return LabelSelector{}, err
+		}
+	}
+	return LabelSelector{Selector: parsed}, nil
+}
+
+// Matches сообщает, что точка подходит под все условия селектора
+func (s LabelSelector) Matches(m models.MetricData) bool {
+	return s.Selector.Matches(m.Labels)
+}
+
+// Filter возвращает точки, подходящие под селектор
+func (s LabelSelector) Filter(data []models.MetricData) []models.MetricData {
+	if s.Empty() {
+		return data
+	}
+	filtered := make([]models.MetricData, 0, len(data))
+	for _, m := range data {
+		if s.Matches(m) {
+			filtered = append(filtered, m)
+		}
+	}
+	return filtered
+}
+
+// ParseMetricLabels разбирает список экспортируемых меток вида "team,environment"
+func ParseMetricLabels(value string) ([]string, error) {
+	return exportLabels(strings.Split(value, ","))
+}
+
+// exportLabels проверяет имена меток, экспортируемых в Prometheus;
+// недопустимые и повторные имена отбрасываются
+func exportLabels(names []string) ([]string, error) {
+	seen := make(map[string]bool, len(names))
+	valid := make([]string, 0, len(names))
+	var errs []error
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if name == "" || seen[name] {
+			continue
+		}
+		if err := validateLabelName(name); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		seen[name] = true
+		valid = append(valid, name)
+	}
+	return valid, errors.Join(errs...)
+}
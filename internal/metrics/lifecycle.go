@@ -0,0 +1,292 @@
+package metrics
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ContainerEventType - событие жизненного цикла контейнера
+type ContainerEventType string
+
+const (
+	ContainerStarted     ContainerEventType = "start"
+	ContainerStopped     ContainerEventType = "stop"
+	ContainerRescheduled ContainerEventType = "reschedule" // Перенесен с PreviousServerID на ServerID
+)
+
+// ErrInvalidContainerEvent - событие контейнера не принято
+var ErrInvalidContainerEvent = errors.New("invalid container event")
+
+// maxEventSkew - насколько событие может опережать часы сервера
+const maxEventSkew = 5 * time.Minute
+
+// ContainerEvent - запуск, остановка или перенос контейнера, о которых
+// сообщает оркестратор или агент. По событиям энергия сервера делится только
+// между работавшими контейнерами, а не всеми, чьи точки еще хранятся.
+type ContainerEvent struct {
+	ContainerID      string             `json:"container_id"`
+	Type             ContainerEventType `json:"type"`
+	ServerID         string             `json:"server_id"`                    // Сервер события; для reschedule - новый
+	PreviousServerID string             `json:"previous_server_id,omitempty"` // Для reschedule - сервер, с которого контейнер ушел
+	ServiceName      string             `json:"service,omitempty"`
+	Image            string             `json:"image,omitempty"`
+	Reason           string             `json:"reason,omitempty"` // deploy, eviction, crash, migration, ...
+	TenantID         string             `json:"tenant_id,omitempty"`
+	Timestamp        time.Time          `json:"timestamp"` // Нулевое - время приема
+}
+
+func (e ContainerEvent) validate(now time.Time) error {
+	switch {
+	case e.ContainerID == "":
+		return fmt.Errorf("%w: container_id is required", ErrInvalidContainerEvent)
+	case e.ServerID == "":
+		return fmt.Errorf("%w: server_id is required", ErrInvalidContainerEvent)
+	case e.Timestamp.After(now.Add(maxEventSkew)):
+		return fmt.Errorf("%w: timestamp is in the future", ErrInvalidContainerEvent)
+	}
+	switch e.Type {
+	case ContainerStarted, ContainerStopped:
+	case ContainerRescheduled:
+		if e.PreviousServerID == "" || e.PreviousServerID == e.ServerID {
+			return fmt.Errorf("%w: reschedule requires previous_server_id different from server_id", ErrInvalidContainerEvent)
+		}
+	default:
+		return fmt.Errorf("%w: unknown type %q: use start, stop or reschedule", ErrInvalidContainerEvent, e.Type)
+	}
+	return nil
+}
+
+// ContainerEventFilter отбирает события; пустые поля не ограничивают
+type ContainerEventFilter struct {
+	ServerID    string // Событие на сервере или перенос с него
+	ContainerID string
+	From, To    time.Time // [From, To)
+}
+
+func (f ContainerEventFilter) matches(e ContainerEvent) bool {
+	if f.ContainerID != "" && e.ContainerID != f.ContainerID {
+		return false
+	}
+	if f.ServerID != "" && e.ServerID != f.ServerID && e.PreviousServerID != f.ServerID {
+		return false
+	}
+	if !f.From.IsZero() && e.Timestamp.Before(f.From) {
+		return false
+	}
+	return f.To.IsZero() || e.Timestamp.Before(f.To)
+}
+
+// containerState - где контейнер находится по последнему событию
+type containerState struct {
+	serverID string
+	running  bool
+	at       time.Time
+}
+
+// containerLifecycle хранит события контейнеров в порядке времени
+type containerLifecycle struct {
+	mu        sync.RWMutex
+	events    map[string][]ContainerEvent // Контейнер -> события по времени
+	listeners []func(ContainerEvent)
+}
+
+func newContainerLifecycle() *containerLifecycle {
+	return &containerLifecycle{events: make(map[string][]ContainerEvent)}
+}
+
+// state возвращает положение контейнера по последнему событию
+func (l *containerLifecycle) state(containerID string) (containerState, bool) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	events := l.events[containerID]
+	if len(events) == 0 {
+		return containerState{}, false
+	}
+	last := events[len(events)-1]
+	return containerState{serverID: last.ServerID, running: last.Type != ContainerStopped, at: last.Timestamp}, true
+}
+
+// prune удаляет события старше cutoff, кроме последнего события контейнера:
+// по нему известно, работает ли контейнер
+func (l *containerLifecycle) prune(cutoff time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for containerID, events := range l.events {
+		keep := sort.Search(len(events), func(i int) bool { return !events[i].Timestamp.Before(cutoff) })
+		keep = min(keep, len(events)-1)
+		l.events[containerID] = append([]ContainerEvent(nil), events[keep:]...)
+	}
+}
+
+// RecordContainerEvent принимает событие жизненного цикла контейнера.
+// События могут приходить не по порядку: они упорядочиваются по времени.
+func (c *Collector) RecordContainerEvent(event ContainerEvent) (ContainerEvent, error) {
+	now := time.Now()
+	if event.Timestamp.IsZero() {
+		event.Timestamp = now
+	}
+	if err := event.validate(now); err != nil {
+		return ContainerEvent{}, err
+	}
+	if owner, known := c.ServerTenant(event.ServerID); known && event.TenantID != "" && owner != event.TenantID {
+		return ContainerEvent{}, fmt.Errorf("%w: server %s", ErrTenantMismatch, event.ServerID)
+	}
+
+	l := c.lifecycle
+	l.mu.Lock()
+	events := l.events[event.ContainerID]
+	// Перенос и перезапуск обычно не повторяют сервис и образ контейнера
+	for i := len(events) - 1; i >= 0 && (event.ServiceName == "" || event.Image == ""); i-- {
+		if event.ServiceName == "" {
+			event.ServiceName = events[i].ServiceName
+		}
+		if event.Image == "" {
+			event.Image = events[i].Image
+		}
+	}
+	if event.ServiceName == "" {
+		event.ServiceName = c.containerService(event.ContainerID)
+	}
+	i := sort.Search(len(events), func(i int) bool { return events[i].Timestamp.After(event.Timestamp) })
+	events = append(events, ContainerEvent{})
+	copy(events[i+1:], events[i:])
+	events[i] = event
+	l.events[event.ContainerID] = events
+	listeners := l.listeners
+	l.mu.Unlock()
+
+	for _, listener := range listeners {
+		listener(event)
+	}
+	return event, nil
+}
+
+// OnContainerEvent регистрирует обработчик принятых событий контейнеров.
+// Обработчик вызывается в горутине запроса и не должен блокироваться.
+func (c *Collector) OnContainerEvent(listener func(ContainerEvent)) {
+	c.lifecycle.mu.Lock()
+	defer c.lifecycle.mu.Unlock()
+	c.lifecycle.listeners = append(c.lifecycle.listeners, listener)
+}
+
+// ContainerEvents возвращает события по фильтру в порядке времени
+func (c *Collector) ContainerEvents(filter ContainerEventFilter) []ContainerEvent {
+	c.lifecycle.mu.RLock()
+	defer c.lifecycle.mu.RUnlock()
+
+	result := make([]ContainerEvent, 0)
+	for containerID, events := range c.lifecycle.events {
+		if filter.ContainerID != "" && containerID != filter.ContainerID {
+			continue
+		}
+		for _, event := range events {
+			if filter.matches(event) {
+				result = append(result, event)
+			}
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool { return result[i].Timestamp.Before(result[j].Timestamp) })
+	return result
+}
+
+// ServiceRuntime возвращает, сколько контейнеры каждого сервиса работали на
+// сервере в [from, to). Контейнер без сервиса учитывается под "". ok ложно,
+// если о контейнерах сервера нет событий: тогда время работы неизвестно.
+func (c *Collector) ServiceRuntime(serverID string, from, to time.Time) (runtime map[string]time.Duration, ok bool) {
+	c.lifecycle.mu.RLock()
+	defer c.lifecycle.mu.RUnlock()
+
+	runtime = make(map[string]time.Duration)
+	for _, events := range c.lifecycle.events {
+		var (
+			seen, running bool
+			since         time.Time
+			service       string
+		)
+		add := func(end time.Time) {
+			start, end := maxTime(since, from), minTime(end, to)
+			if end.After(start) {
+				runtime[service] += end.Sub(start)
+			}
+		}
+		for _, event := range events {
+			arrives := event.ServerID == serverID && event.Type != ContainerStopped
+			if !seen {
+				if event.ServerID != serverID && event.PreviousServerID != serverID {
+					continue
+				}
+				// Первое событие на сервере - уход: контейнер работал на нем
+				// с начала интервала
+				seen, running = true, !arrives
+			}
+			if event.ServiceName != "" {
+				service = event.ServiceName
+			}
+			switch {
+			case arrives && !running:
+				running, since = true, event.Timestamp
+			case !arrives && running:
+				// Остановлен здесь или запущен на другом сервере
+				add(event.Timestamp)
+				running = false
+			}
+		}
+		if running {
+			add(to)
+		}
+		ok = ok || seen
+	}
+	return runtime, ok
+}
+
+// RunningServices возвращает сервисы контейнеров, работающих на сервере по
+// последним событиям; ok ложно, если о контейнерах сервера нет событий
+func (c *Collector) RunningServices(serverID string) (services []string, ok bool) {
+	c.lifecycle.mu.RLock()
+	defer c.lifecycle.mu.RUnlock()
+
+	unique := make(map[string]bool)
+	for _, events := range c.lifecycle.events {
+		for _, event := range events {
+			if event.ServerID == serverID || event.PreviousServerID == serverID {
+				ok = true
+				break
+			}
+		}
+		last := events[len(events)-1]
+		if last.ServerID == serverID && last.Type != ContainerStopped {
+			unique[last.ServiceName] = true
+		}
+	}
+	for service := range unique {
+		services = append(services, service)
+	}
+	sort.Strings(services)
+	return services, ok
+}
+
+// containerService возвращает сервис контейнера по метке последней точки
+func (c *Collector) containerService(containerID string) string {
+	data, err := c.containers.Metrics(containerID)
+	if err != nil || len(data) == 0 {
+		return ""
+	}
+	return data[len(data)-1].Labels[cohortServiceLabel]
+}
+
+func maxTime(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
+
+func minTime(a, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
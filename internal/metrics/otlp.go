@@ -0,0 +1,240 @@
+package metrics
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// OTLPConfig включает отправку датчиков сборщика в OpenTelemetry Collector
+// (или любой приемник OTLP/HTTP) параллельно с регистрацией в Prometheus.
+// Отправляются те же серии и метки, что видны в /metrics.
+type OTLPConfig struct {
+	Endpoint           string            // Адрес приема метрик, например http://otel-collector:4318/v1/metrics
+	Headers            map[string]string // Дополнительные заголовки, например авторизация
+	ResourceAttributes map[string]string // Атрибуты ресурса; service.name по умолчанию platypus
+	Interval           time.Duration     // Как часто отправлять текущие значения
+	Timeout            time.Duration
+}
+
+// otlpUnits - единицы датчиков в нотации UCUM, принятой в OpenTelemetry
+var otlpUnits = map[string]string{
+	"server_power_usage_watts":           "W",
+	"server_carbon_footprint_kg":         "kg",
+	"server_cpu_usage_percent":           "%",
+	"server_memory_usage_percent":        "%",
+	"server_gpu_usage_percent":           "%",
+	"server_gpu_power_usage_watts":       "W",
+	"server_disk_read_bytes_per_second":  "By/s",
+	"server_disk_write_bytes_per_second": "By/s",
+	"server_storage_used_bytes":          "By",
+	"server_inlet_temperature_celsius":   "Cel",
+}
+
+type otlpExporter struct {
+	config   OTLPConfig
+	client   *http.Client
+	gatherer prometheus.Gatherer
+}
+
+func newOTLPExporter(config OTLPConfig, gauges []*prometheus.GaugeVec) (*otlpExporter, error) {
+	if config.Endpoint == "" {
+		return nil, fmt.Errorf("otlp endpoint is required")
+	}
+	if config.Interval <= 0 {
+		config.Interval = time.Minute
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = 10 * time.Second
+	}
+	if config.ResourceAttributes["service.name"] == "" {
+		attributes := map[string]string{"service.name": "platypus"}
+		for key, value := range config.ResourceAttributes {
+			attributes[key] = value
+		}
+		config.ResourceAttributes = attributes
+	}
+
+	// Отдельный реестр с датчиками сборщика: метрики процесса Go не отправляются
+	registry := prometheus.NewRegistry()
+	for _, gauge := range gauges {
+		if err := registry.Register(gauge); err != nil {
+			return nil, err
+		}
+	}
+	return &otlpExporter{
+		config:   config,
+		client:   &http.Client{Timeout: config.Timeout},
+		gatherer: registry,
+	}, nil
+}
+
+// export отправляет текущие значения датчиков. Повторов нет: следующий
+// запуск все равно отправит свежие значения.
+func (e *otlpExporter) export(ctx context.Context) error {
+	families, err := e.gatherer.Gather()
+	if err != nil {
+		return fmt.Errorf("gather gauges: %w", err)
+	}
+
+	now := uint64(time.Now().UnixNano())
+	var metrics []byte
+	for _, family := range families {
+		var gauge []byte
+		for _, m := range family.GetMetric() {
+			var point []byte
+			for _, l := range m.GetLabel() {
+				if l.GetValue() == "" {
+					continue
+				}
+				point = protowire.AppendTag(point, 7, protowire.BytesType)
+				point = protowire.AppendBytes(point, otlpKeyValue(l.GetName(), l.GetValue()))
+			}
+			point = protowire.AppendTag(point, 3, protowire.Fixed64Type)
+			point = protowire.AppendFixed64(point, now)
+			point = protowire.AppendTag(point, 4, protowire.Fixed64Type)
+			point = protowire.AppendFixed64(point, math.Float64bits(m.GetGauge().GetValue()))
+
+			gauge = protowire.AppendTag(gauge, 1, protowire.BytesType)
+			gauge = protowire.AppendBytes(gauge, point)
+		}
+		if len(gauge) == 0 {
+			continue
+		}
+
+		var metric []byte
+		metric = protowire.AppendTag(metric, 1, protowire.BytesType)
+		metric = protowire.AppendString(metric, family.GetName())
+		metric = protowire.AppendTag(metric, 2, protowire.BytesType)
+		metric = protowire.AppendString(metric, family.GetHelp())
+		metric = protowire.AppendTag(metric, 3, protowire.BytesType)
+		metric = protowire.AppendString(metric, otlpUnits[family.GetName()])
+		metric = protowire.AppendTag(metric, 5, protowire.BytesType)
+		metric = protowire.AppendBytes(metric, gauge)
+
+		metrics = protowire.AppendTag(metrics, 2, protowire.BytesType)
+		metrics = protowire.AppendBytes(metrics, metric)
+	}
+	if len(metrics) == 0 {
+		return nil
+	}
+
+	return e.send(ctx, e.encodeRequest(metrics))
+}
+
+// encodeRequest оборачивает метрики в ExportMetricsServiceRequest:
+//
+//	message ExportMetricsServiceRequest { repeated ResourceMetrics resource_metrics = 1; }
+//	message ResourceMetrics { Resource resource = 1; repeated ScopeMetrics scope_metrics = 2; }
+//	message Resource        { repeated KeyValue attributes = 1; }
+//	message ScopeMetrics    { InstrumentationScope scope = 1; repeated Metric metrics = 2; }
+//	message Metric          { string name = 1; string description = 2; string unit = 3; Gauge gauge = 5; }
+//	message Gauge           { repeated NumberDataPoint data_points = 1; }
+//	message NumberDataPoint { fixed64 time_unix_nano = 3; double as_double = 4; repeated KeyValue attributes = 7; }
+func (e *otlpExporter) encodeRequest(metrics []byte) []byte {
+	keys := make([]string, 0, len(e.config.ResourceAttributes))
+	for key := range e.config.ResourceAttributes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var resource []byte
+	for _, key := range keys {
+		resource = protowire.AppendTag(resource, 1, protowire.BytesType)
+		resource = protowire.AppendBytes(resource, otlpKeyValue(key, e.config.ResourceAttributes[key]))
+	}
+
+	var scope []byte
+	scope = protowire.AppendTag(scope, 1, protowire.BytesType)
+	scope = protowire.AppendString(scope, "github.com/YumeNoTenshi/platypus/internal/metrics")
+
+	scopeMetrics := protowire.AppendTag(nil, 1, protowire.BytesType)
+	scopeMetrics = protowire.AppendBytes(scopeMetrics, scope)
+	scopeMetrics = append(scopeMetrics, metrics...)
+
+	var resourceMetrics []byte
+	resourceMetrics = protowire.AppendTag(resourceMetrics, 1, protowire.BytesType)
+	resourceMetrics = protowire.AppendBytes(resourceMetrics, resource)
+	resourceMetrics = protowire.AppendTag(resourceMetrics, 2, protowire.BytesType)
+	resourceMetrics = protowire.AppendBytes(resourceMetrics, scopeMetrics)
+
+	request := protowire.AppendTag(nil, 1, protowire.BytesType)
+	return protowire.AppendBytes(request, resourceMetrics)
+}
+
+// otlpKeyValue кодирует KeyValue { string key = 1; AnyValue value = 2; }
+// со строковым значением (AnyValue.string_value = 1)
+func otlpKeyValue(key, value string) []byte {
+	var anyValue []byte
+	anyValue = protowire.AppendTag(anyValue, 1, protowire.BytesType)
+	anyValue = protowire.AppendString(anyValue, value)
+
+	var kv []byte
+	kv = protowire.AppendTag(kv, 1, protowire.BytesType)
+	kv = protowire.AppendString(kv, key)
+	kv = protowire.AppendTag(kv, 2, protowire.BytesType)
+	return protowire.AppendBytes(kv, anyValue)
+}
+
+func (e *otlpExporter) send(ctx context.Context, request []byte) error {
+	var body bytes.Buffer
+	zw := gzip.NewWriter(&body)
+	if _, err := zw.Write(request); err != nil {
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.config.Endpoint, &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("Content-Encoding", "gzip")
+	req.Header.Set("User-Agent", "platypus-otlp-exporter")
+	for name, value := range e.config.Headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("otlp export: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("otlp endpoint responded with status %d: %s", resp.StatusCode, strings.TrimSpace(string(message)))
+	}
+	return nil
+}
+
+// ParseOTLPPairs разбирает заголовки или атрибуты ресурса в формате
+// переменных OTEL_EXPORTER_OTLP_HEADERS и OTEL_RESOURCE_ATTRIBUTES:
+// "key1=value1,key2=value2"
+func ParseOTLPPairs(value string) (map[string]string, error) {
+	pairs := make(map[string]string)
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		key, pairValue, found := strings.Cut(part, "=")
+		key = strings.TrimSpace(key)
+		if !found || key == "" {
+			return nil, fmt.Errorf("invalid pair %q: expected key=value", part)
+		}
+		pairs[key] = strings.TrimSpace(pairValue)
+	}
+	return pairs, nil
+}
@@ -0,0 +1,355 @@
+package metrics
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/YumeNoTenshi/platypus/internal/models"
+)
+
+// postgresColumns - число параметров на одну строку вставки
+const postgresColumns = 8
+
+var tableNamePattern = regexp.MustCompile(`^[a-z_][a-z0-9_]*$`)
+
+type PostgresConfig struct {
+	Driver          string // Имя драйвера database/sql, по умолчанию pgx
+	DSN             string
+	Table           string // По умолчанию server_metrics
+	Timescale       bool   // Создать hypertable и агрегировать через time_bucket
+	InsertBatchSize int    // Максимум строк в одном INSERT
+	Timeout         time.Duration
+}
+
+// PostgresStore хранит точки в PostgreSQL или TimescaleDB. Основные показатели
+// лежат в отдельных колонках для агрегации, полная точка - в JSONB.
+// Драйвер database/sql должен быть зарегистрирован в бинарнике; pgx
+// подключается сборкой с тегом postgres (см. cmd/server/postgres.go).
+type PostgresStore struct {
+	config PostgresConfig
+	db     *sql.DB
+}
+
+func NewPostgresStore(config PostgresConfig) (*PostgresStore, error) {
+	if config.Driver == "" {
+		config.Driver = "pgx"
+	}
+	if !slices.Contains(sql.Drivers(), config.Driver) {
+		return nil, fmt.Errorf("postgres driver %s is not compiled in; build with -tags postgres", config.Driver)
+	}
+	if config.Table == "" {
+		config.Table = "server_metrics"
+	}
+	if !tableNamePattern.MatchString(config.Table) {
+		return nil, fmt.Errorf("invalid table name: %s", config.Table)
+	}
+	if config.InsertBatchSize <= 0 || config.InsertBatchSize*postgresColumns > 65535 {
+		config.InsertBatchSize = 1000
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = 10 * time.Second
+	}
+
+	db, err := sql.Open(config.Driver, config.DSN)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open postgres: %w", err)
+	}
+
+	s := &PostgresStore{config: config, db: db}
+	ctx, cancel := s.context()
+	defer cancel()
+
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to connect to postgres: %w", err)
+	}
+	if err := s.migrate(ctx); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *PostgresStore) Close() error {
+	return s.db.Close()
+}
+
+func (s *PostgresStore) context() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), s.config.Timeout)
+}
+
+func (s *PostgresStore) migrate(ctx context.Context) error {
+	statements := []string{
+		fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+			server_id        TEXT             NOT NULL,
+			ts               TIMESTAMPTZ      NOT NULL,
+			power_usage      DOUBLE PRECISION NOT NULL,
+			carbon_footprint DOUBLE PRECISION NOT NULL,
+			cpu_usage        DOUBLE PRECISION NOT NULL,
+			memory_usage     DOUBLE PRECISION NOT NULL,
+			ingested_at      TIMESTAMPTZ      NOT NULL,
+			point            JSONB            NOT NULL
+		)`, s.config.Table),
+		fmt.Sprintf(`CREATE INDEX IF NOT EXISTS %s_server_ts_idx ON %s (server_id, ts)`, s.config.Table, s.config.Table),
+	}
+	if s.config.Timescale {
+		statements = append(statements,
+			`CREATE EXTENSION IF NOT EXISTS timescaledb`,
+			fmt.Sprintf(`SELECT create_hypertable('%s', 'ts', if_not_exists => TRUE, migrate_data => TRUE)`, s.config.Table),
+		)
+	}
+
+	for _, statement := range statements {
+		if _, err := s.db.ExecContext(ctx, statement); err != nil {
+			return fmt.Errorf("failed to migrate postgres schema: %w", err)
+		}
+	}
+	return nil
+}
+
+// Append вставляет точки пакетами по InsertBatchSize строк в одной транзакции
+func (s *PostgresStore) Append(batch MetricBatch) error {
+	ctx, cancel := s.context()
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := s.insert(ctx, tx, batch.ServerID, batch.Metrics, batch.Timestamp); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *PostgresStore) insert(ctx context.Context, tx *sql.Tx, serverID string, data []models.MetricData, ingestedAt time.Time) error {
+	if ingestedAt.IsZero() {
+		ingestedAt = time.Now()
+	}
+
+	for start := 0; start < len(data); start += s.config.InsertBatchSize {
+		end := start + s.config.InsertBatchSize
+		if end > len(data) {
+			end = len(data)
+		}
+
+		rows := make([]string, 0, end-start)
+		args := make([]interface{}, 0, (end-start)*postgresColumns)
+		for _, m := range data[start:end] {
+			point, err := json.Marshal(m)
+			if err != nil {
+				return err
+			}
+
+			n := len(args)
+			rows = append(rows, fmt.Sprintf("($%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d)",
+				n+1, n+2, n+3, n+4, n+5, n+6, n+7, n+8))
+			args = append(args, serverID, time.Unix(m.Timestamp, 0).UTC(),
+				m.PowerUsage, m.CarbonFootprint, m.CPUUsage, m.MemoryUsage, ingestedAt, point)
+		}
+
+		query := fmt.Sprintf(`INSERT INTO %s
+			(server_id, ts, power_usage, carbon_footprint, cpu_usage, memory_usage, ingested_at, point)
+			VALUES %s`, s.config.Table, strings.Join(rows, ", "))
+		if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+			return fmt.Errorf("failed to insert metrics: %w", err)
+		}
+	}
+	return nil
+}
+
+// Metrics возвращает точки сервера в порядке поступления
+func (s *PostgresStore) Metrics(serverID string) ([]models.MetricData, error) {
+	data, err := s.points(fmt.Sprintf(
+		`SELECT point FROM %s WHERE server_id = $1 ORDER BY ingested_at, ts`, s.config.Table), serverID)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return nil, fmt.Errorf("no metrics found for server: %s", serverID)
+	}
+	return data, nil
+}
+
+// Range читает только нужное окно по индексу (server_id, ts)
+func (s *PostgresStore) Range(serverID string, from, to time.Time) ([]models.MetricData, error) {
+	data, err := s.points(fmt.Sprintf(
+		`SELECT point FROM %s WHERE server_id = $1 AND ts >= $2 AND ts < $3 ORDER BY ingested_at, ts`, s.config.Table),
+		serverID, from.UTC(), to.UTC())
+	if data == nil && err == nil {
+		data = []models.MetricData{}
+	}
+	return data, err
+}
+
+func (s *PostgresStore) points(query string, args ...interface{}) ([]models.MetricData, error) {
+	ctx, cancel := s.context()
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var data []models.MetricData
+	for rows.Next() {
+		var raw []byte
+		if err := rows.Scan(&raw); err != nil {
+			return nil, err
+		}
+		var m models.MetricData
+		if err := json.Unmarshal(raw, &m); err != nil {
+			return nil, err
+		}
+		data = append(data, m)
+	}
+	return data, rows.Err()
+}
+
+func (s *PostgresStore) Replace(serverID string, data []models.MetricData) error {
+	ctx, cancel := s.context()
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, fmt.Sprintf(`DELETE FROM %s WHERE server_id = $1`, s.config.Table), serverID); err != nil {
+		return err
+	}
+	if err := s.insert(ctx, tx, serverID, data, time.Now()); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Compact заменяет старые точки в одной транзакции. Новые точки всегда свежее
+// before, поэтому конкурентные вставки не затрагиваются.
+func (s *PostgresStore) Compact(serverID string, before time.Time, fn func(old []models.MetricData) []models.MetricData) error {
+	ctx, cancel := s.context()
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, fmt.Sprintf(
+		`DELETE FROM %s WHERE server_id = $1 AND ts < $2 RETURNING point`, s.config.Table), serverID, before.UTC())
+	if err != nil {
+		return err
+	}
+
+	var old []models.MetricData
+	for rows.Next() {
+		var raw []byte
+		var m models.MetricData
+		if err := rows.Scan(&raw); err == nil {
+			err = json.Unmarshal(raw, &m)
+		}
+		if err != nil {
+			rows.Close()
+			return err
+		}
+		old = append(old, m)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if len(old) == 0 {
+		return nil
+	}
+
+	if err := s.insert(ctx, tx, serverID, fn(old), time.Now()); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *PostgresStore) ServerIDs() ([]string, error) {
+	ctx, cancel := s.context()
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, fmt.Sprintf(`SELECT DISTINCT server_id FROM %s`, s.config.Table))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// Prune удаляет устаревшие точки; в TimescaleDB целиком удаляются старые чанки
+func (s *PostgresStore) Prune(cutoff time.Time) error {
+	ctx, cancel := s.context()
+	defer cancel()
+
+	query := fmt.Sprintf(`DELETE FROM %s WHERE ts < $1`, s.config.Table)
+	if s.config.Timescale {
+		query = fmt.Sprintf(`SELECT drop_chunks('%s', older_than => $1)`, s.config.Table)
+	}
+	_, err := s.db.ExecContext(ctx, query, cutoff.UTC())
+	return err
+}
+
+// Buckets агрегирует точки сервера на стороне базы данных
+func (s *PostgresStore) Buckets(serverID string, from, to time.Time, bucket time.Duration) ([]UsageBucket, error) {
+	ctx, cancel := s.context()
+	defer cancel()
+
+	// Интервалы выровнены по эпохе Unix, как и в BucketMetrics
+	bucketExpr := `to_timestamp(floor(extract(epoch FROM ts) / $4) * $4)`
+	if s.config.Timescale {
+		bucketExpr = `time_bucket(make_interval(secs => $4), ts, TIMESTAMPTZ 'epoch')`
+	}
+
+	// Свернутые точки весят по числу усредненных в них исходных точек
+	rows, err := s.db.QueryContext(ctx, fmt.Sprintf(`
+		SELECT bucket, sum(w)::bigint, sum(power_usage * w) / sum(w), max(power_usage),
+		       sum(cpu_usage * w) / sum(w), max(cpu_usage), sum(memory_usage * w) / sum(w), sum(carbon_footprint * w)
+		FROM (
+			SELECT %s AS bucket, greatest(coalesce((point->>'samples')::int, 1), 1) AS w,
+			       power_usage, cpu_usage, memory_usage, carbon_footprint
+			FROM %s
+			WHERE server_id = $1 AND ts >= $2 AND ts < $3
+		) points
+		GROUP BY bucket
+		ORDER BY bucket`, bucketExpr, s.config.Table),
+		serverID, from.UTC(), to.UTC(), bucket.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var buckets []UsageBucket
+	for rows.Next() {
+		var b UsageBucket
+		if err := rows.Scan(&b.Start, &b.Samples, &b.AvgPower, &b.MaxPower, &b.AvgCPU, &b.MaxCPU, &b.AvgMemory, &b.CarbonFootprint); err != nil {
+			return nil, err
+		}
+		b.Start = b.Start.UTC()
+		buckets = append(buckets, b)
+	}
+	return buckets, rows.Err()
+}
@@ -0,0 +1,151 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/YumeNoTenshi/platypus/internal/models"
+	"github.com/YumeNoTenshi/platypus/internal/scheduler"
+)
+
+// metricRetentionInterval - как часто поля точек очищаются по срокам
+// хранения метрик: поле живет не дольше чем срок плюс этот интервал
+const metricRetentionInterval = time.Hour
+
+// metricClearers обнуляют поля точки по именам JSON (как в metricFields)
+var metricClearers = map[string]func(m *models.MetricData){
+	"power_usage":        func(m *models.MetricData) { m.PowerUsage, m.PowerModel = 0, "" },
+	"carbon_footprint":   func(m *models.MetricData) { m.CarbonFootprint = 0 },
+	"cpu_usage":          func(m *models.MetricData) { m.CPUUsage = 0 },
+	"memory_usage":       func(m *models.MetricData) { m.MemoryUsage = 0 },
+	"gpu_usage":          func(m *models.MetricData) { m.GPUUsage = 0 },
+	"gpu_power_usage":    func(m *models.MetricData) { m.GPUPowerUsage = 0 },
+	"network_rx_bytes":   func(m *models.MetricData) { m.NetworkRxBytes = 0 },
+	"network_tx_bytes":   func(m *models.MetricData) { m.NetworkTxBytes = 0 },
+	"disk_read_bps":      func(m *models.MetricData) { m.DiskReadBytesPerSec = 0 },
+	"disk_write_bps":     func(m *models.MetricData) { m.DiskWriteBytesPerSec = 0 },
+	"storage_used_bytes": func(m *models.MetricData) { m.StorageUsedBytes = 0 },
+	"inlet_temp_c":       func(m *models.MetricData) { m.InletTemperature = 0 },
+}
+
+// ParseMetricRetention разбирает сроки хранения метрик вида
+// "carbon_footprint=8760h,cpu_usage=72h"
+func ParseMetricRetention(value string) (map[string]time.Duration, error) {
+	retention := make(map[string]time.Duration)
+	for _, pair := range strings.Split(value, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		name, period, found := strings.Cut(pair, "=")
+		if !found {
+			return nil, fmt.Errorf("invalid retention %q: expected metric=duration", pair)
+		}
+		name = strings.TrimSpace(name)
+		if _, known := metricClearers[name]; !known {
+			return nil, fmt.Errorf("%w %q: expected one of %s", ErrUnknownMetric, name, strings.Join(MetricNames(), ", "))
+		}
+		duration, err := time.ParseDuration(strings.TrimSpace(period))
+		if err != nil || duration <= 0 {
+			return nil, fmt.Errorf("invalid retention for %s: %q", name, period)
+		}
+		retention[name] = duration
+	}
+	return retention, nil
+}
+
+// LongestRetention - срок хранения точек: самый длинный из RetentionPeriod
+// и сроков отдельных метрик. Точка удаляется целиком только после него.
+func (c CollectorConfig) LongestRetention() time.Duration {
+	longest := c.RetentionPeriod
+	for _, period := range c.MetricRetention {
+		longest = max(longest, period)
+	}
+	return longest
+}
+
+// retentionCutoffs - по каждой метрике момент, раньше которого ее значения
+// очищаются. Метрики, которые живут столько же, сколько точка, не входят.
+func (c CollectorConfig) retentionCutoffs(now time.Time) map[string]int64 {
+	longest := c.LongestRetention()
+	cutoffs := make(map[string]int64)
+	for name := range metricClearers {
+		period, exists := c.MetricRetention[name]
+		if !exists {
+			period = c.RetentionPeriod
+		}
+		if period > 0 && period < longest {
+			cutoffs[name] = now.Add(-period).Unix()
+		}
+	}
+	return cutoffs
+}
+
+// metricRetentionJob - периодическая очистка полей с истекшим сроком
+func (c *Collector) metricRetentionJob() scheduler.Job {
+	return scheduler.Job{
+		Name:     "collector.metric_retention",
+		Interval: metricRetentionInterval,
+		Run: func(ctx context.Context) error {
+			return c.ExpireMetrics(ctx, time.Now())
+		},
+	}
+}
+
+// ExpireMetrics очищает в хранимых точках поля метрик, срок хранения
+// которых истек, например сырую загрузку CPU, когда углеродный след еще
+// хранится для отчетов. Хранилища без Compactor хранят все поля до
+// LongestRetention.
+func (c *Collector) ExpireMetrics(ctx context.Context, now time.Time) error {
+	cutoffs := c.config.retentionCutoffs(now)
+	if len(cutoffs) == 0 {
+		return nil
+	}
+	var before int64
+	for _, cutoff := range cutoffs {
+		before = max(before, cutoff)
+	}
+	// Поля очищаются в порядке имен: точка обрабатывается одинаково в любом хранилище
+	names := make([]string, 0, len(cutoffs))
+	for name := range cutoffs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	expire := func(old []models.MetricData) []models.MetricData {
+		for i := range old {
+			for _, name := range names {
+				if old[i].Timestamp < cutoffs[name] {
+					metricClearers[name](&old[i])
+				}
+			}
+		}
+		return old
+	}
+
+	var failures []error
+	for _, store := range []Store{c.store, c.containers} {
+		compactor, ok := store.(Compactor)
+		if !ok {
+			continue
+		}
+		ids, err := store.ServerIDs()
+		if err != nil {
+			failures = append(failures, err)
+			continue
+		}
+		for _, seriesID := range ids {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if err := compactor.Compact(seriesID, time.Unix(before, 0), expire); err != nil {
+				failures = append(failures, fmt.Errorf("series %s: %w", seriesID, err))
+			}
+		}
+	}
+	return errors.Join(failures...)
+}
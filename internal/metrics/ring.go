@@ -0,0 +1,188 @@
+package metrics
+
+import (
+	"sort"
+	"unsafe"
+
+	"github.com/YumeNoTenshi/platypus/internal/models"
+)
+
+// minRingSlots - начальный размер буфера серии; дальше он растет вдвое до емкости
+const minRingSlots = 16
+
+// pointSize - размер точки в буфере без данных строк и меток
+const pointSize = int64(unsafe.Sizeof(models.MetricData{}))
+
+// ring - кольцевой буфер точек сервера ограниченной емкости. При
+// заполнении новая точка вытесняет самую старую. Память под точки
+// выделяется по мере поступления, а не сразу на всю емкость: редко
+// присылающие серии не занимают места на полную историю.
+//
+// Точки хранятся по возрастанию меток времени, по одной на метку: точка с
+// уже известной меткой заменяет прежнюю (повтор отправки или уточнение),
+// опоздавшая точка вставляется на свое место. Выборка по интервалу и
+// очистка ищут границы двоичным поиском.
+type ring struct {
+	data     []models.MetricData
+	capacity int
+	head     int // Индекс самой старой точки
+	size     int
+	extra    int64 // Байт строк и меток хранимых точек (оценка)
+}
+
+// pushResult - что произошло с точкой при добавлении в буфер
+type pushResult int
+
+const (
+	pushAppended  pushResult = iota // Новее всех точек буфера
+	pushReplaced                    // Заменила точку с той же меткой
+	pushInserted                    // Опоздала и вставлена на свое место
+	pushDiscarded                   // Старше всех точек заполненного буфера: вытеснилась бы сразу
+)
+
+func newRing(capacity int) *ring {
+	if capacity <= 0 {
+		capacity = 1
+	}
+	return &ring{capacity: capacity}
+}
+
+// at возвращает i-ю точку от самой старой
+func (r *ring) at(i int) models.MetricData {
+	return r.data[(r.head+i)%len(r.data)]
+}
+
+// push добавляет точку, сохраняя порядок меток времени
+func (r *ring) push(m models.MetricData) pushResult {
+	if r.size > 0 && m.Timestamp <= r.at(r.size-1).Timestamp {
+		return r.insert(m)
+	}
+	r.append(m)
+	return pushAppended
+}
+
+// insert помещает точку не новее последней на место по метке времени
+func (r *ring) insert(m models.MetricData) pushResult {
+	i := sort.Search(r.size, func(i int) bool { return r.at(i).Timestamp >= m.Timestamp })
+	if i < r.size && r.at(i).Timestamp == m.Timestamp {
+		index := (r.head + i) % len(r.data)
+		r.extra += pointExtraBytes(m) - pointExtraBytes(r.data[index])
+		r.data[index] = m
+		return pushReplaced
+	}
+	if i == 0 && r.size == r.capacity {
+		return pushDiscarded
+	}
+
+	// Точка дописывается в конец и сдвигается на место i; при заполненном
+	// буфере append вытесняет самую старую точку, и место сдвигается вместе с ней
+	if r.size == r.capacity {
+		i--
+	}
+	r.append(m)
+	for j := r.size - 1; j > i; j-- {
+		r.data[(r.head+j)%len(r.data)] = r.data[(r.head+j-1)%len(r.data)]
+	}
+	r.data[(r.head+i)%len(r.data)] = m
+	return pushInserted
+}
+
+// append дописывает точку в конец буфера
+func (r *ring) append(m models.MetricData) {
+	if r.size == len(r.data) && len(r.data) < r.capacity {
+		r.resize(min(r.capacity, max(minRingSlots, 2*len(r.data))))
+	}
+	r.extra += pointExtraBytes(m)
+	if r.size < len(r.data) {
+		r.data[(r.head+r.size)%len(r.data)] = m
+		r.size++
+		return
+	}
+	r.extra -= pointExtraBytes(r.data[r.head])
+	r.data[r.head] = m
+	r.head = (r.head + 1) % len(r.data)
+}
+
+// resize переносит точки в буфер из slots ячеек (slots >= size)
+func (r *ring) resize(slots int) {
+	data := make([]models.MetricData, slots)
+	for i := 0; i < r.size; i++ {
+		data[i] = r.at(i)
+	}
+	r.data, r.head = data, 0
+}
+
+// slice копирует точки [from, to) в порядке поступления
+func (r *ring) slice(from, to int) []models.MetricData {
+	out := make([]models.MetricData, 0, to-from)
+	for i := from; i < to; i++ {
+		out = append(out, r.at(i))
+	}
+	return out
+}
+
+func (r *ring) all() []models.MetricData {
+	return r.slice(0, r.size)
+}
+
+// between возвращает точки с меткой времени в [from, to) (в секундах Unix)
+func (r *ring) between(from, to int64) []models.MetricData {
+	start := sort.Search(r.size, func(i int) bool { return r.at(i).Timestamp >= from })
+	end := sort.Search(r.size, func(i int) bool { return r.at(i).Timestamp >= to })
+	if end < start {
+		end = start
+	}
+	return r.slice(start, end)
+}
+
+// reset заменяет содержимое буфера; из data остаются самые новые точки,
+// помещающиеся в емкость. Буфер выделяется заново по размеру данных, так
+// что свертка и очистка возвращают память.
+func (r *ring) reset(data []models.MetricData) {
+	// Упорядоченные точки дописываются в конец: повторы меток заменяются,
+	// лишние старые вытесняются
+	data = append([]models.MetricData(nil), data...)
+	sort.SliceStable(data, func(i, j int) bool { return data[i].Timestamp < data[j].Timestamp })
+	r.data = make([]models.MetricData, min(r.capacity, max(minRingSlots, len(data))))
+	r.head, r.size, r.extra = 0, 0, 0
+	for _, m := range data {
+		r.push(m)
+	}
+}
+
+// dropBefore удаляет точки с меткой времени не позже cutoff (в секундах Unix)
+func (r *ring) dropBefore(cutoff int64) {
+	// Старые точки лежат в начале: сдвигается только голова
+	n := sort.Search(r.size, func(i int) bool { return r.at(i).Timestamp > cutoff })
+	for i := 0; i < n; i++ {
+		index := (r.head + i) % len(r.data)
+		r.extra -= pointExtraBytes(r.data[index])
+		r.data[index] = models.MetricData{}
+	}
+	if n > 0 {
+		r.head = (r.head + n) % len(r.data)
+		r.size -= n
+	}
+	// Буфер, заполненный меньше чем на четверть, сжимается
+	if len(r.data) > minRingSlots && r.size < len(r.data)/4 {
+		r.resize(max(minRingSlots, 2*r.size))
+	}
+}
+
+// bytes - оценка памяти буфера: ячейки под точки и данные их строк и меток
+func (r *ring) bytes() int64 {
+	return int64(len(r.data))*pointSize + r.extra
+}
+
+// pointExtraBytes оценивает память строк и меток точки вне самой ячейки:
+// содержимое строк, заголовок и записи карты меток
+func pointExtraBytes(m models.MetricData) int64 {
+	size := int64(len(m.ServerID) + len(m.TenantID) + len(m.ContainerID) + len(m.PowerModel))
+	if len(m.Labels) > 0 {
+		size += 48 // Заголовок карты
+		for key, value := range m.Labels {
+			size += int64(len(key)+len(value)) + 40 // Заголовки строк и служебные данные записи
+		}
+	}
+	return size
+}
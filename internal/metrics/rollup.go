@@ -0,0 +1,240 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/YumeNoTenshi/platypus/internal/models"
+	"github.com/YumeNoTenshi/platypus/internal/scheduler"
+)
+
+// RollupTier - точки старше After усредняются до интервалов длины Resolution
+type RollupTier struct {
+	After      time.Duration `json:"after"`
+	Resolution time.Duration `json:"resolution"`
+}
+
+type RollupConfig struct {
+	Tiers    []RollupTier
+	Interval time.Duration // Как часто выполнять свертку
+}
+
+// RollupStats описывает результат сверток для API статуса
+type RollupStats struct {
+	Runs         int       `json:"runs"`
+	LastRun      time.Time `json:"last_run,omitempty"`
+	PointsBefore int       `json:"points_before"` // Точек до последней свертки
+	PointsAfter  int       `json:"points_after"`
+	Unsupported  bool      `json:"unsupported,omitempty"` // Хранилище не поддерживает свертку
+}
+
+// Rollup периодически заменяет старые исходные точки средними по интервалам,
+// чтобы длинная история занимала память пропорционально числу интервалов, а не точек
+type Rollup struct {
+	config    RollupConfig
+	collector *Collector
+
+	mu    sync.Mutex
+	stats RollupStats
+}
+
+func NewRollup(config RollupConfig, collector *Collector) *Rollup {
+	tiers := append([]RollupTier(nil), config.Tiers...)
+	sort.Slice(tiers, func(i, j int) bool {
+		return tiers[i].After < tiers[j].After
+	})
+	config.Tiers = tiers
+
+	return &Rollup{config: config, collector: collector}
+}
+
+// Jobs возвращает периодическую задачу свертки
+func (r *Rollup) Jobs() []scheduler.Job {
+	return []scheduler.Job{{
+		Name:     "metrics.rollup",
+		Interval: r.config.Interval,
+		Run: func(ctx context.Context) error {
+			return r.Run(time.Now())
+		},
+	}}
+}
+
+// Run сворачивает точки всех серверов относительно момента now
+func (r *Rollup) Run(now time.Time) error {
+	compactor, ok := r.collector.store.(Compactor)
+	if !ok || len(r.config.Tiers) == 0 {
+		r.mu.Lock()
+		r.stats.Unsupported = !ok
+		r.mu.Unlock()
+		return nil
+	}
+
+	// Точки моложе самого раннего порога не трогаются
+	before := rollupCutoff(r.config.Tiers[0], now)
+	var pointsBefore, pointsAfter int
+	var failures []error
+	for _, serverID := range r.collector.ServerIDs() {
+		err := compactor.Compact(serverID, before, func(old []models.MetricData) []models.MetricData {
+			rolled := RollupMetrics(old, r.config.Tiers, now)
+			pointsBefore += len(old)
+			pointsAfter += len(rolled)
+			return rolled
+		})
+		if err != nil {
+			failures = append(failures, fmt.Errorf("server %s: %w", serverID, err))
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.stats.Runs++
+	r.stats.LastRun = now
+	r.stats.PointsBefore = pointsBefore
+	r.stats.PointsAfter = pointsAfter
+	return errors.Join(failures...)
+}
+
+func (r *Rollup) Stats() RollupStats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.stats
+}
+
+// rollupCutoff выравнивает границу уровня по его интервалу, чтобы сворачивались
+// только полностью закрытые интервалы
+func rollupCutoff(tier RollupTier, now time.Time) time.Time {
+	size := int64(tier.Resolution / time.Second)
+	cutoff := now.Add(-tier.After).Unix()
+	if size > 0 {
+		cutoff = floorDiv(cutoff, size) * size
+	}
+	return time.Unix(cutoff, 0)
+}
+
+// mixedPowerModels - происхождение мощности агрегированной точки, в которую
+// попали точки с разными моделями оценки или измеренные
+const mixedPowerModels = "mixed"
+
+// RollupMetrics усредняет точки по уровням (tiers отсортированы по After):
+// каждая точка попадает в самый грубый уровень, порог которого она прошла.
+// Ранее агрегированные точки учитываются с весом по числу исходных точек,
+// поэтому повторная свертка идемпотентна. Результат отсортирован по времени.
+func RollupMetrics(data []models.MetricData, tiers []RollupTier, now time.Time) []models.MetricData {
+	type key struct {
+		resolution int64
+		start      int64
+	}
+	type accumulator struct {
+		samples                    int
+		power, cpu, memory, carbon float64
+		gpu, gpuPower              float64
+		rx, tx                     float64 // Трафик суммируется, а не усредняется
+		diskRead, diskWrite        float64
+		storage                    float64
+		inletTemp                  float64
+		energyCounter              float64
+		interpolated               bool
+		powerModel                 string            // Общая модель точек; mixedPowerModels, если они различаются
+		labels                     map[string]string // Метки последней точки интервала
+	}
+
+	cutoffs := make([]int64, len(tiers))
+	for i, tier := range tiers {
+		cutoffs[i] = rollupCutoff(tier, now).Unix()
+	}
+
+	var result []models.MetricData
+	buckets := make(map[key]*accumulator)
+	serverID, tenantID := "", ""
+	for _, m := range data {
+		serverID, tenantID = m.ServerID, m.TenantID
+
+		tier := -1
+		for i := range tiers {
+			if m.Timestamp < cutoffs[i] && int64(tiers[i].Resolution/time.Second) >= m.Resolution {
+				tier = i
+			}
+		}
+		if tier < 0 {
+			result = append(result, m)
+			continue
+		}
+
+		size := int64(tiers[tier].Resolution / time.Second)
+		if size <= 0 {
+			result = append(result, m)
+			continue
+		}
+		k := key{resolution: size, start: floorDiv(m.Timestamp, size) * size}
+		acc, ok := buckets[k]
+		if !ok {
+			acc = &accumulator{interpolated: true, powerModel: m.PowerModel}
+			buckets[k] = acc
+		}
+		if acc.powerModel != m.PowerModel {
+			acc.powerModel = mixedPowerModels
+		}
+
+		weight := m.Samples
+		if weight <= 0 {
+			weight = 1
+		}
+		w := float64(weight)
+		acc.samples += weight
+		acc.power += m.PowerUsage * w
+		acc.cpu += m.CPUUsage * w
+		acc.memory += m.MemoryUsage * w
+		acc.gpu += m.GPUUsage * w
+		acc.gpuPower += m.GPUPowerUsage * w
+		acc.diskRead += m.DiskReadBytesPerSec * w
+		acc.diskWrite += m.DiskWriteBytesPerSec * w
+		acc.storage += m.StorageUsedBytes * w
+		acc.inletTemp += m.InletTemperature * w
+		acc.rx += m.NetworkRxBytes
+		acc.tx += m.NetworkTxBytes
+		acc.carbon += m.CarbonFootprint * w
+		if m.EnergyCounter > acc.energyCounter {
+			acc.energyCounter = m.EnergyCounter
+		}
+		acc.interpolated = acc.interpolated && m.Interpolated
+		if m.Labels != nil {
+			acc.labels = m.Labels
+		}
+	}
+
+	for k, acc := range buckets {
+		n := float64(acc.samples)
+		result = append(result, models.MetricData{
+			ServerID:             serverID,
+			TenantID:             tenantID,
+			Timestamp:            k.start,
+			PowerUsage:           acc.power / n,
+			PowerModel:           acc.powerModel,
+			CarbonFootprint:      acc.carbon / n,
+			CPUUsage:             acc.cpu / n,
+			MemoryUsage:          acc.memory / n,
+			GPUUsage:             acc.gpu / n,
+			GPUPowerUsage:        acc.gpuPower / n,
+			NetworkRxBytes:       acc.rx,
+			NetworkTxBytes:       acc.tx,
+			DiskReadBytesPerSec:  acc.diskRead / n,
+			DiskWriteBytesPerSec: acc.diskWrite / n,
+			StorageUsedBytes:     acc.storage / n,
+			InletTemperature:     acc.inletTemp / n,
+			Interpolated:         acc.interpolated,
+			EnergyCounter:        acc.energyCounter,
+			Resolution:           k.resolution,
+			Samples:              acc.samples,
+			Labels:               acc.labels,
+		})
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Timestamp < result[j].Timestamp
+	})
+	return result
+}
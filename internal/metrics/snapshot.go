@@ -0,0 +1,296 @@
+package metrics
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/YumeNoTenshi/platypus/internal/encryption"
+	"github.com/YumeNoTenshi/platypus/internal/models"
+	"github.com/YumeNoTenshi/platypus/internal/scheduler"
+)
+
+const (
+	snapshotVersion         = 1
+	defaultSnapshotInterval = 5 * time.Minute
+)
+
+// SnapshotConfig - периодический снимок точек в памяти процесса на диск.
+// При запуске точки восстанавливаются из снимка, так что короткий
+// перезапуск не обнуляет историю, нужную анализатору и прогнозу.
+type SnapshotConfig struct {
+	Path     string
+	Interval time.Duration // По умолчанию 5 минут
+	// Encryption - необязательное шифрование снимка; снимок, сохраненный
+	// до включения шифрования, читается как есть
+	Encryption *encryption.Keyring
+}
+
+// SnapshotStats - состояние снимков для /status
+type SnapshotStats struct {
+	Path         string    `json:"path"`
+	LastSaved    time.Time `json:"last_saved,omitempty"`
+	Series       int       `json:"series"`
+	Points       int       `json:"points"`
+	Bytes        int64     `json:"bytes"`
+	RestoredFrom time.Time `json:"restored_from,omitempty"` // Время снимка, из которого восстановлены точки
+	Restored     int       `json:"restored"`
+	LastError    string    `json:"last_error,omitempty"`
+}
+
+// snapshotFile - содержимое снимка (gob в gzip)
+type snapshotFile struct {
+	Version    int
+	TakenAt    time.Time
+	Servers    map[string][]models.MetricData
+	Containers map[string][]models.MetricData
+}
+
+type snapshotter struct {
+	config SnapshotConfig
+
+	saveMu sync.Mutex // Снимки не пишутся одновременно (задача и остановка)
+	mu     sync.Mutex
+	stats  SnapshotStats
+}
+
+func newSnapshotter(config SnapshotConfig) (*snapshotter, error) {
+	if config.Path == "" {
+		return nil, fmt.Errorf("snapshot path is required")
+	}
+	if config.Interval <= 0 {
+		config.Interval = defaultSnapshotInterval
+	}
+	if err := os.MkdirAll(filepath.Dir(config.Path), 0o755); err != nil {
+		return nil, err
+	}
+	return &snapshotter{config: config, stats: SnapshotStats{Path: config.Path}}, nil
+}
+
+func (s *snapshotter) fail(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stats.LastError = err.Error()
+}
+
+// initSnapshots включает снимки. Ошибка настройки не останавливает
+// сборщик: он работает без снимков.
+func (c *Collector) initSnapshots(config SnapshotConfig) {
+	servers, containers := c.memoryStores()
+	if servers == nil && containers == nil {
+		log.Printf("Снимки метрик не нужны: точки хранятся во внешнем хранилище")
+		return
+	}
+	snapshots, err := newSnapshotter(config)
+	if err != nil {
+		log.Printf("Снимки метрик не сохраняются: %v", err)
+		return
+	}
+	c.snapshots = snapshots
+}
+
+// snapshotJob - периодическое сохранение снимка
+func (c *Collector) snapshotJob() scheduler.Job {
+	return scheduler.Job{
+		Name:     "collector.snapshot",
+		Interval: c.snapshots.config.Interval,
+		Run: func(ctx context.Context) error {
+			return c.SaveSnapshot()
+		},
+	}
+}
+
+// SaveSnapshot записывает точки хранилищ в памяти в файл снимка. Файл
+// заменяется атомарно: при падении во время записи остается прежний снимок.
+// Точки, вытесненные на диск потолком памяти, в снимок не входят - они и
+// так переживают перезапуск.
+func (c *Collector) SaveSnapshot() error {
+	if c.snapshots == nil {
+		return nil
+	}
+	s := c.snapshots
+	s.saveMu.Lock()
+	defer s.saveMu.Unlock()
+
+	snapshot := snapshotFile{Version: snapshotVersion, TakenAt: time.Now()}
+	servers, containers := c.memoryStores()
+	if servers != nil {
+		snapshot.Servers = servers.resident()
+	}
+	if containers != nil {
+		snapshot.Containers = containers.resident()
+	}
+
+	size, err := writeSnapshot(s.config.Path, snapshot, s.config.Encryption)
+	if err != nil {
+		err = fmt.Errorf("save metrics snapshot: %w", err)
+		s.fail(err)
+		return err
+	}
+
+	points := 0
+	for _, data := range snapshot.Servers {
+		points += len(data)
+	}
+	for _, data := range snapshot.Containers {
+		points += len(data)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stats.LastSaved = snapshot.TakenAt
+	s.stats.Series = len(snapshot.Servers) + len(snapshot.Containers)
+	s.stats.Points = points
+	s.stats.Bytes = size
+	s.stats.LastError = ""
+	return nil
+}
+
+func writeSnapshot(path string, snapshot snapshotFile, keys *encryption.Keyring) (int64, error) {
+	tmp := path + ".tmp"
+	file, err := os.Create(tmp)
+	if err != nil {
+		return 0, err
+	}
+	w := bufio.NewWriter(file)
+	err = encodeSnapshot(w, snapshot, keys)
+	if err == nil {
+		err = w.Flush()
+	}
+	if err == nil {
+		err = file.Sync()
+	}
+	var size int64
+	if err == nil {
+		var info os.FileInfo
+		if info, err = file.Stat(); err == nil {
+			size = info.Size()
+		}
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return 0, err
+	}
+	return size, os.Rename(tmp, path)
+}
+
+// encodeSnapshot пишет снимок (gob в gzip). Зашифрованный снимок сначала
+// собирается в памяти: блок шифруется целиком.
+func encodeSnapshot(w io.Writer, snapshot snapshotFile, keys *encryption.Keyring) error {
+	if keys == nil {
+		zw := gzip.NewWriter(w)
+		if err := gob.NewEncoder(zw).Encode(snapshot); err != nil {
+			return err
+		}
+		return zw.Close()
+	}
+
+	var plain bytes.Buffer
+	if err := encodeSnapshot(&plain, snapshot, nil); err != nil {
+		return err
+	}
+	sealed, err := keys.Seal(plain.Bytes())
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(sealed)
+	return err
+}
+
+// restoreSnapshot загружает точки из снимка, если он есть. Точки старше
+// срока хранения отбрасываются. Испорченный снимок не мешает запуску: сбор
+// начинается с пустой истории.
+func (c *Collector) restoreSnapshot(now time.Time) error {
+	s := c.snapshots
+	snapshot, err := readSnapshot(s.config.Path, s.config.Encryption)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		err = fmt.Errorf("restore metrics snapshot: %w", err)
+		s.fail(err)
+		return err
+	}
+
+	var cutoff int64
+	if retention := c.config.LongestRetention(); retention > 0 {
+		cutoff = now.Add(-retention).Unix()
+	}
+	restored := 0
+	restore := func(store *MemoryStore, series map[string][]models.MetricData) {
+		if store == nil {
+			return
+		}
+		for seriesID, data := range series {
+			kept := data[:0]
+			for _, m := range data {
+				if m.Timestamp > cutoff {
+					kept = append(kept, m)
+				}
+			}
+			store.restore(seriesID, kept)
+			restored += len(kept)
+		}
+	}
+	servers, containers := c.memoryStores()
+	restore(servers, snapshot.Servers)
+	restore(containers, snapshot.Containers)
+
+	s.mu.Lock()
+	s.stats.RestoredFrom = snapshot.TakenAt
+	s.stats.Restored = restored
+	s.mu.Unlock()
+	log.Printf("Из снимка метрик от %s восстановлено %d точек", snapshot.TakenAt.Format(time.RFC3339), restored)
+	return nil
+}
+
+func readSnapshot(path string, keys *encryption.Keyring) (snapshotFile, error) {
+	var snapshot snapshotFile
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return snapshot, err
+	}
+	if encryption.Encrypted(data) {
+		if keys == nil {
+			return snapshot, fmt.Errorf("snapshot is encrypted and no encryption keys are configured")
+		}
+		if data, err = keys.Open(data); err != nil {
+			return snapshot, err
+		}
+	}
+
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return snapshot, err
+	}
+	defer zr.Close()
+	if err := gob.NewDecoder(zr).Decode(&snapshot); err != nil {
+		return snapshot, err
+	}
+	if snapshot.Version != snapshotVersion {
+		return snapshot, fmt.Errorf("unsupported snapshot version %d", snapshot.Version)
+	}
+	return snapshot, nil
+}
+
+// SnapshotStats возвращает состояние снимков
+func (c *Collector) SnapshotStats() SnapshotStats {
+	if c.snapshots == nil {
+		return SnapshotStats{}
+	}
+	c.snapshots.mu.Lock()
+	defer c.snapshots.mu.Unlock()
+	return c.snapshots.stats
+}
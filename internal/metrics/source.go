@@ -0,0 +1,62 @@
+package metrics
+
+import (
+	"log"
+	"time"
+
+	"github.com/YumeNoTenshi/platypus/internal/models"
+)
+
+// MetricsSource - источник отфильтрованных точек серверов для Analyzer и
+// Predictor. Collector реализует его; StoreSource позволяет анализировать
+// любое хранилище (InfluxDB, реплику для чтения, тестовые данные) без
+// коллектора и его приема.
+type MetricsSource interface {
+	// GetFilteredMetrics возвращает точки сервера после сглаживания и
+	// заполнения пропусков
+	GetFilteredMetrics(serverID string) ([]models.MetricData, error)
+	// GetFilteredMetricsRange - то же для точек с меткой времени в [from, to)
+	GetFilteredMetricsRange(serverID string, from, to time.Time) ([]models.MetricData, error)
+	// ServerIDs возвращает серверы, для которых есть точки
+	ServerIDs() []string
+}
+
+var _ MetricsSource = (*Collector)(nil)
+
+// StoreSource читает точки напрямую из хранилища и фильтрует их так же, как
+// Collector
+type StoreSource struct {
+	store  Store
+	filter FilterConfig
+}
+
+// NewStoreSource создает источник поверх хранилища; filter - фильтрация шума
+// при чтении (CollectorConfig.Filter)
+func NewStoreSource(store Store, filter FilterConfig) *StoreSource {
+	return &StoreSource{store: store, filter: filter}
+}
+
+func (s *StoreSource) GetFilteredMetrics(serverID string) ([]models.MetricData, error) {
+	data, err := s.store.Metrics(serverID)
+	if err != nil {
+		return nil, err
+	}
+	return ApplyFilters(data, s.filter), nil
+}
+
+func (s *StoreSource) GetFilteredMetricsRange(serverID string, from, to time.Time) ([]models.MetricData, error) {
+	data, err := s.store.Range(serverID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	return ApplyFilters(data, s.filter), nil
+}
+
+func (s *StoreSource) ServerIDs() []string {
+	ids, err := s.store.ServerIDs()
+	if err != nil {
+		log.Printf("Ошибка получения списка серверов из хранилища: %v", err)
+		return nil
+	}
+	return ids
+}
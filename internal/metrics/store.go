@@ -0,0 +1,476 @@
+package metrics
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/YumeNoTenshi/platypus/internal/models"
+)
+
+// Store - хранилище точек метрик сборщика. Реализации должны быть безопасны
+// для конкурентного использования.
+type Store interface {
+	// Append сохраняет пакет точек сервера
+	Append(batch MetricBatch) error
+	// Metrics возвращает все хранимые точки сервера в порядке поступления
+	// (MemoryStore - по возрастанию меток времени)
+	Metrics(serverID string) ([]models.MetricData, error)
+	// Range возвращает точки сервера с меткой времени в [from, to)
+	Range(serverID string, from, to time.Time) ([]models.MetricData, error)
+	// Replace заменяет все точки сервера
+	Replace(serverID string, data []models.MetricData) error
+	ServerIDs() ([]string, error)
+	// Prune удаляет точки старше cutoff
+	Prune(cutoff time.Time) error
+}
+
+// BucketQuerier - необязательное расширение Store для хранилищ, умеющих
+// агрегировать точки по интервалам на своей стороне
+type BucketQuerier interface {
+	Buckets(serverID string, from, to time.Time, bucket time.Duration) ([]UsageBucket, error)
+}
+
+// Compactor - необязательное расширение Store: атомарная замена точек сервера
+// старше before результатом fn. Новые точки, поступающие во время замены, не теряются.
+type Compactor interface {
+	Compact(serverID string, before time.Time, fn func(old []models.MetricData) []models.MetricData) error
+}
+
+// UsageBucket - агрегаты метрик сервера за интервал фиксированной длины
+type UsageBucket struct {
+	Start           time.Time `json:"start"`
+	Samples         int       `json:"samples"`
+	AvgPower        float64   `json:"avg_power"`
+	MaxPower        float64   `json:"max_power"`
+	AvgCPU          float64   `json:"avg_cpu"`
+	MaxCPU          float64   `json:"max_cpu"`
+	AvgMemory       float64   `json:"avg_memory"`
+	CarbonFootprint float64   `json:"carbon_footprint"` // Сумма за интервал, кг CO2
+}
+
+// BucketMetrics группирует точки из [from, to) по интервалам, выровненным по UTC.
+// Пустые интервалы пропускаются.
+func BucketMetrics(data []models.MetricData, from, to time.Time, bucket time.Duration) []UsageBucket {
+	size := int64(bucket / time.Second)
+	if size <= 0 {
+		return nil
+	}
+
+	var buckets []UsageBucket
+	index := make(map[int64]int)
+	for _, m := range data {
+		if !inRange(m, from, to) {
+			continue
+		}
+
+		start := floorDiv(m.Timestamp, size) * size
+		i, ok := index[start]
+		if !ok {
+			i = len(buckets)
+			index[start] = i
+			buckets = append(buckets, UsageBucket{Start: time.Unix(start, 0).UTC()})
+		}
+
+		// Агрегированная точка весит столько, сколько исходных точек в ней усреднено
+		weight := m.Samples
+		if weight <= 0 {
+			weight = 1
+		}
+		w := float64(weight)
+
+		b := &buckets[i]
+		b.Samples += weight
+		b.AvgPower += m.PowerUsage * w
+		b.AvgCPU += m.CPUUsage * w
+		b.AvgMemory += m.MemoryUsage * w
+		b.CarbonFootprint += m.CarbonFootprint * w
+		if m.PowerUsage > b.MaxPower {
+			b.MaxPower = m.PowerUsage
+		}
+		if m.CPUUsage > b.MaxCPU {
+			b.MaxCPU = m.CPUUsage
+		}
+	}
+
+	for i := range buckets {
+		n := float64(buckets[i].Samples)
+		buckets[i].AvgPower /= n
+		buckets[i].AvgCPU /= n
+		buckets[i].AvgMemory /= n
+	}
+	sort.Slice(buckets, func(i, j int) bool {
+		return buckets[i].Start.Before(buckets[j].Start)
+	})
+	return buckets
+}
+
+// defaultPointsPerServer - емкость серии MemoryStore по умолчанию: неделя
+// поминутных точек
+const defaultPointsPerServer = 7 * 24 * 60
+
+// memoryShards - число сегментов MemoryStore. Серверы распределяются по
+// сегментам по хешу идентификатора, у каждого сегмента своя блокировка:
+// одновременный прием от тысяч серверов не упирается в одну блокировку.
+const memoryShards = 64
+
+type memoryShard struct {
+	mu      sync.RWMutex
+	servers map[string]*ServerMetrics
+}
+
+// MemoryStore хранит точки в памяти процесса; используется по умолчанию.
+// Серия хранит не больше pointsPerServer последних точек: при заполнении
+// новая точка вытесняет самую старую, не дожидаясь Prune. Точки серии
+// упорядочены по метке времени, повтор метки заменяет прежнюю точку: повторная
+// отправка пакета и опоздавшие точки не искажают тренды.
+//
+// Со SetSpill старые точки можно вытеснить на диск (SpillBefore): чтение
+// серии тогда объединяет точки из хранилища вытеснения и из памяти.
+type MemoryStore struct {
+	shards          [memoryShards]memoryShard
+	pointsPerServer int
+	spill           Store // Хранилище вытесненных точек; nil - вытеснения нет
+
+	duplicates atomic.Int64 // Точки, заменившие точку с той же меткой
+	outOfOrder atomic.Int64 // Опоздавшие точки, вставленные на свое место
+	discarded  atomic.Int64 // Опоздавшие точки старше всей заполненной серии
+}
+
+// MemoryUsage - учет памяти MemoryStore для self-метрик и /status
+type MemoryUsage struct {
+	Bytes  int64 `json:"bytes"` // Оценка: ячейки буферов серий и данные строк и меток точек
+	Points int   `json:"points"`
+	Series int   `json:"series"`
+
+	Duplicates int64 `json:"duplicates"`   // С запуска: точки с уже известной меткой времени
+	OutOfOrder int64 `json:"out_of_order"` // С запуска: опоздавшие точки
+	Discarded  int64 `json:"discarded"`    // С запуска: опоздавшие точки старше всей серии
+}
+
+// NewMemoryStore создает хранилище с емкостью pointsPerServer точек на
+// серию; 0 - емкость по умолчанию
+func NewMemoryStore(pointsPerServer int) *MemoryStore {
+	if pointsPerServer <= 0 {
+		pointsPerServer = defaultPointsPerServer
+	}
+	s := &MemoryStore{pointsPerServer: pointsPerServer}
+	for i := range s.shards {
+		s.shards[i].servers = make(map[string]*ServerMetrics)
+	}
+	return s
+}
+
+func (s *MemoryStore) shard(serverID string) *memoryShard {
+	return &s.shards[shardIndex(serverID, memoryShards)]
+}
+
+// shardIndex распределяет ключи по n сегментам (FNV-1a без выделения памяти)
+func shardIndex(key string, n int) int {
+	hash := uint32(2166136261)
+	for i := 0; i < len(key); i++ {
+		hash ^= uint32(key[i])
+		hash *= 16777619
+	}
+	return int(hash % uint32(n))
+}
+
+func (s *MemoryStore) Append(batch MetricBatch) error {
+	shard := s.shard(batch.ServerID)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	serverMetrics, exists := shard.servers[batch.ServerID]
+	if !exists {
+		serverMetrics = &ServerMetrics{points: newRing(s.pointsPerServer)}
+		shard.servers[batch.ServerID] = serverMetrics
+	}
+
+	for _, m := range batch.Metrics {
+		s.count(serverMetrics.points.push(m))
+	}
+	serverMetrics.LastUpdate = batch.Timestamp
+	return nil
+}
+
+// count учитывает повторы и опоздавшие точки
+func (s *MemoryStore) count(result pushResult) {
+	switch result {
+	case pushReplaced:
+		s.duplicates.Add(1)
+	case pushInserted:
+		s.outOfOrder.Add(1)
+	case pushDiscarded:
+		s.discarded.Add(1)
+	}
+}
+
+// Metrics возвращает копию точек: буфер серии переиспользуется
+func (s *MemoryStore) Metrics(serverID string) ([]models.MetricData, error) {
+	shard := s.shard(serverID)
+	shard.mu.RLock()
+	defer shard.mu.RUnlock()
+
+	metrics, exists := shard.servers[serverID]
+	if !exists {
+		return nil, fmt.Errorf("no metrics found for server: %s", serverID)
+	}
+	if !metrics.spilled {
+		return metrics.points.all(), nil
+	}
+	spilled, err := s.spill.Metrics(serverID)
+	if err != nil {
+		return nil, fmt.Errorf("read spilled metrics: %w", err)
+	}
+	return append(spilled, metrics.points.all()...), nil
+}
+
+func (s *MemoryStore) Range(serverID string, from, to time.Time) ([]models.MetricData, error) {
+	shard := s.shard(serverID)
+	shard.mu.RLock()
+	defer shard.mu.RUnlock()
+
+	metrics, exists := shard.servers[serverID]
+	if !exists {
+		return nil, fmt.Errorf("no metrics found for server: %s", serverID)
+	}
+	if !metrics.spilled {
+		return metrics.points.between(ceilUnix(from), ceilUnix(to)), nil
+	}
+	spilled, err := s.spill.Range(serverID, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("read spilled metrics: %w", err)
+	}
+	return append(spilled, metrics.points.between(ceilUnix(from), ceilUnix(to))...), nil
+}
+
+// ceilUnix - наименьшая целая секунда Unix не раньше t: метка точки m
+// попадает в [from, to) тогда и только тогда, когда
+// ceilUnix(from) <= m.Timestamp < ceilUnix(to)
+func ceilUnix(t time.Time) int64 {
+	if t.Nanosecond() > 0 {
+		return t.Unix() + 1
+	}
+	return t.Unix()
+}
+
+// inRange проверяет, что метка времени точки попадает в [from, to)
+func inRange(m models.MetricData, from, to time.Time) bool {
+	at := time.Unix(m.Timestamp, 0)
+	return !at.Before(from) && at.Before(to)
+}
+
+func (s *MemoryStore) Replace(serverID string, data []models.MetricData) error {
+	shard := s.shard(serverID)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	if existing, exists := shard.servers[serverID]; exists && existing.spilled {
+		if err := s.spill.Replace(serverID, nil); err != nil {
+			return fmt.Errorf("clear spilled metrics: %w", err)
+		}
+	}
+	points := newRing(s.pointsPerServer)
+	points.reset(data)
+	shard.servers[serverID] = &ServerMetrics{
+		points:     points,
+		LastUpdate: time.Now(),
+	}
+	return nil
+}
+
+func (s *MemoryStore) Compact(serverID string, before time.Time, fn func(old []models.MetricData) []models.MetricData) error {
+	shard := s.shard(serverID)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	serverMetrics, exists := shard.servers[serverID]
+	if !exists {
+		return nil
+	}
+
+	var old, rest []models.MetricData
+	for _, m := range serverMetrics.points.all() {
+		if time.Unix(m.Timestamp, 0).Before(before) {
+			old = append(old, m)
+		} else {
+			rest = append(rest, m)
+		}
+	}
+	if len(old) == 0 {
+		return nil
+	}
+
+	data := make([]models.MetricData, 0, len(old)+len(rest))
+	data = append(data, fn(old)...)
+	serverMetrics.points.reset(append(data, rest...))
+	return nil
+}
+
+func (s *MemoryStore) ServerIDs() ([]string, error) {
+	ids := make([]string, 0)
+	for i := range s.shards {
+		shard := &s.shards[i]
+		shard.mu.RLock()
+		for serverID := range shard.servers {
+			ids = append(ids, serverID)
+		}
+		shard.mu.RUnlock()
+	}
+	return ids, nil
+}
+
+// Prune удаляет устаревшие точки; в серии, упорядоченной по времени, -
+// сдвигом начала буфера без перестройки
+func (s *MemoryStore) Prune(cutoff time.Time) error {
+	// Точка сохраняется, если ее метка позже cutoff
+	limit := cutoff.Unix()
+	// Сегменты очищаются по очереди: прием в остальные сегменты не ждет очистки
+	for i := range s.shards {
+		shard := &s.shards[i]
+		shard.mu.Lock()
+		for _, serverMetrics := range shard.servers {
+			serverMetrics.points.dropBefore(limit)
+		}
+		shard.mu.Unlock()
+	}
+	if s.spill != nil {
+		return s.spill.Prune(cutoff)
+	}
+	return nil
+}
+
+// SetSpill задает хранилище, куда SpillBefore вытесняет старые точки.
+// Серии, вытесненные до перезапуска, снова доступны для чтения.
+func (s *MemoryStore) SetSpill(spill Store) error {
+	s.spill = spill
+	ids, err := spill.ServerIDs()
+	if err != nil {
+		return err
+	}
+	for _, serverID := range ids {
+		shard := s.shard(serverID)
+		shard.mu.Lock()
+		if serverMetrics, exists := shard.servers[serverID]; exists {
+			serverMetrics.spilled = true
+		} else {
+			shard.servers[serverID] = &ServerMetrics{points: newRing(s.pointsPerServer), spilled: true}
+		}
+		shard.mu.Unlock()
+	}
+	return nil
+}
+
+// SpillBefore переносит точки с меткой времени не позже cutoff в хранилище
+// вытеснения и освобождает занятую ими память. Возвращает число перенесенных
+// точек. Серия, которую не удалось записать, остается в памяти целиком.
+func (s *MemoryStore) SpillBefore(cutoff time.Time) (int, error) {
+	if s.spill == nil {
+		return 0, fmt.Errorf("spill store is not configured")
+	}
+	limit := cutoff.Unix()
+	moved := 0
+	var failures []error
+	for i := range s.shards {
+		shard := &s.shards[i]
+		shard.mu.Lock()
+		for serverID, serverMetrics := range shard.servers {
+			var old []models.MetricData
+			for _, m := range serverMetrics.points.all() {
+				if m.Timestamp <= limit {
+					old = append(old, m)
+				}
+			}
+			if len(old) == 0 {
+				continue
+			}
+			if err := s.spill.Append(MetricBatch{ServerID: serverID, Metrics: old, Timestamp: time.Now()}); err != nil {
+				failures = append(failures, fmt.Errorf("server %s: %w", serverID, err))
+				continue
+			}
+			serverMetrics.points.dropBefore(limit)
+			serverMetrics.spilled = true
+			moved += len(old)
+		}
+		shard.mu.Unlock()
+	}
+	return moved, errors.Join(failures...)
+}
+
+// EvictBefore удаляет из памяти точки с меткой времени не позже cutoff, не
+// трогая хранилище вытеснения. Возвращает число удаленных точек.
+func (s *MemoryStore) EvictBefore(cutoff time.Time) int {
+	limit := cutoff.Unix()
+	evicted := 0
+	for i := range s.shards {
+		shard := &s.shards[i]
+		shard.mu.Lock()
+		for _, serverMetrics := range shard.servers {
+			before := serverMetrics.points.size
+			serverMetrics.points.dropBefore(limit)
+			evicted += before - serverMetrics.points.size
+		}
+		shard.mu.Unlock()
+	}
+	return evicted
+}
+
+// Usage возвращает текущий учет памяти
+func (s *MemoryStore) Usage() MemoryUsage {
+	var usage MemoryUsage
+	for i := range s.shards {
+		shard := &s.shards[i]
+		shard.mu.RLock()
+		for _, serverMetrics := range shard.servers {
+			usage.Bytes += serverMetrics.points.bytes()
+			usage.Points += serverMetrics.points.size
+			usage.Series++
+		}
+		shard.mu.RUnlock()
+	}
+	usage.Duplicates = s.duplicates.Load()
+	usage.OutOfOrder = s.outOfOrder.Load()
+	usage.Discarded = s.discarded.Load()
+	return usage
+}
+
+// resident возвращает копию точек, хранимых в памяти, по сериям; точки в
+// хранилище вытеснения не входят
+func (s *MemoryStore) resident() map[string][]models.MetricData {
+	series := make(map[string][]models.MetricData)
+	for i := range s.shards {
+		shard := &s.shards[i]
+		shard.mu.RLock()
+		for serverID, serverMetrics := range shard.servers {
+			if serverMetrics.points.size > 0 {
+				series[serverID] = serverMetrics.points.all()
+			}
+		}
+		shard.mu.RUnlock()
+	}
+	return series
+}
+
+// restore дописывает точки в серию, не трогая хранилище вытеснения (в
+// отличие от Replace)
+func (s *MemoryStore) restore(serverID string, data []models.MetricData) {
+	if len(data) == 0 {
+		return
+	}
+	shard := s.shard(serverID)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	serverMetrics, exists := shard.servers[serverID]
+	if !exists {
+		serverMetrics = &ServerMetrics{points: newRing(s.pointsPerServer)}
+		shard.servers[serverID] = serverMetrics
+	}
+	for _, m := range data {
+		serverMetrics.points.push(m)
+	}
+	serverMetrics.LastUpdate = time.Unix(data[len(data)-1].Timestamp, 0)
+}
@@ -0,0 +1,79 @@
+package metrics
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/YumeNoTenshi/platypus/internal/models"
+)
+
+// defaultSubscriptionBuffer - точек в канале подписки по умолчанию
+const defaultSubscriptionBuffer = 256
+
+// Subscription - подписка на точки, сохраненные коллектором. Точки приходят
+// в C в порядке сохранения. Если подписчик не успевает читать и канал полон,
+// новые точки отбрасываются (Dropped), а прием метрик не ждет подписчика.
+type Subscription struct {
+	C <-chan models.MetricData
+
+	serverID  string // Пусто - точки всех серверов
+	ch        chan models.MetricData
+	collector *Collector
+	dropped   atomic.Uint64
+	closeOnce sync.Once
+}
+
+// Subscribe подписывается на новые точки сервера (serverID = "" - всех
+// серверов), чтобы реагировать на них без опроса GetMetrics. buffer - емкость
+// канала; 0 - по умолчанию. Подписку нужно закрыть вызовом Close.
+func (c *Collector) Subscribe(serverID string, buffer int) *Subscription {
+	if buffer <= 0 {
+		buffer = defaultSubscriptionBuffer
+	}
+	ch := make(chan models.MetricData, buffer)
+	sub := &Subscription{C: ch, serverID: serverID, ch: ch, collector: c}
+
+	c.subsMu.Lock()
+	defer c.subsMu.Unlock()
+	c.subscriptions[sub] = struct{}{}
+	return sub
+}
+
+// Close отменяет подписку и закрывает C; повторный вызов ничего не делает
+func (s *Subscription) Close() {
+	s.closeOnce.Do(func() {
+		s.collector.subsMu.Lock()
+		defer s.collector.subsMu.Unlock()
+		delete(s.collector.subscriptions, s)
+		close(s.ch)
+	})
+}
+
+// Dropped возвращает число точек, отброшенных из-за полного канала
+func (s *Subscription) Dropped() uint64 {
+	return s.dropped.Load()
+}
+
+// publish рассылает точки сохраненного пакета подписчикам, не блокируясь
+func (c *Collector) publish(batch MetricBatch) {
+	c.subsMu.RLock()
+	defer c.subsMu.RUnlock()
+	if len(c.subscriptions) == 0 {
+		return
+	}
+	for sub := range c.subscriptions {
+		if sub.serverID != "" && sub.serverID != batch.ServerID {
+			continue
+		}
+		for _, point := range batch.Metrics {
+			if point.ServerID == "" {
+				point.ServerID = batch.ServerID
+			}
+			select {
+			case sub.ch <- point:
+			default:
+				sub.dropped.Add(1)
+			}
+		}
+	}
+}
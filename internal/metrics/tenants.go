@@ -0,0 +1,110 @@
+package metrics
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// ErrTenantMismatch - сервер принадлежит другому арендатору
+var ErrTenantMismatch = errors.New("server belongs to another tenant")
+
+// DefaultTenant - арендатор точек без TenantID: однопользовательская
+// установка и клиенты API без арендатора
+const DefaultTenant = ""
+
+// tenantOwners запоминает арендатора каждого сервера. Сервер принадлежит
+// арендатору, приславшему его первую точку; идентификаторы серверов
+// поэтому должны быть уникальны для всего инстанса, как идентификаторы
+// облачных инстансов.
+type tenantOwners struct {
+	mu      sync.RWMutex
+	servers map[string]string
+}
+
+func newTenantOwners() *tenantOwners {
+	return &tenantOwners{servers: make(map[string]string)}
+}
+
+// claim закрепляет сервер за арендатором или проверяет, что он уже за ним
+// закреплен. lookup возвращает арендатора по хранимым точкам для серверов,
+// известных до перезапуска.
+func (o *tenantOwners) claim(serverID, tenantID string, lookup func(serverID string) (string, bool)) error {
+	owner, known := o.owner(serverID, lookup)
+	if known {
+		if owner != tenantID {
+			return fmt.Errorf("%w: %s", ErrTenantMismatch, serverID)
+		}
+		return nil
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if owner, exists := o.servers[serverID]; exists && owner != tenantID {
+		return fmt.Errorf("%w: %s", ErrTenantMismatch, serverID)
+	}
+	o.servers[serverID] = tenantID
+	return nil
+}
+
+func (o *tenantOwners) owner(serverID string, lookup func(serverID string) (string, bool)) (string, bool) {
+	o.mu.RLock()
+	owner, exists := o.servers[serverID]
+	o.mu.RUnlock()
+	if exists {
+		return owner, true
+	}
+
+	owner, exists = lookup(serverID)
+	if !exists {
+		return "", false
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if current, claimed := o.servers[serverID]; claimed {
+		return current, true
+	}
+	o.servers[serverID] = owner
+	return owner, true
+}
+
+// ServerTenant возвращает арендатора сервера; known = false - у сервера еще
+// нет точек
+func (c *Collector) ServerTenant(serverID string) (tenantID string, known bool) {
+	return c.tenants.owner(serverID, c.storedTenant)
+}
+
+// storedTenant определяет арендатора по последней хранимой точке сервера
+func (c *Collector) storedTenant(serverID string) (string, bool) {
+	data, err := c.store.Metrics(serverID)
+	if err != nil || len(data) == 0 {
+		return "", false
+	}
+	return data[len(data)-1].TenantID, true
+}
+
+// TenantServerIDs возвращает серверы арендатора
+func (c *Collector) TenantServerIDs(tenantID string) []string {
+	var ids []string
+	for _, serverID := range c.ServerIDs() {
+		if owner, _ := c.ServerTenant(serverID); owner == tenantID {
+			ids = append(ids, serverID)
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// Tenants возвращает число серверов каждого арендатора для /status
+func (c *Collector) Tenants() map[string]int {
+	counts := make(map[string]int)
+	for _, serverID := range c.ServerIDs() {
+		owner, _ := c.ServerTenant(serverID)
+		if owner == DefaultTenant {
+			owner = "default"
+		}
+		counts[owner]++
+	}
+	return counts
+}
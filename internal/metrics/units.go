@@ -0,0 +1,297 @@
+package metrics
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/YumeNoTenshi/platypus/internal/models"
+)
+
+type RatioUnit string
+
+const (
+	UnitPercent  RatioUnit = "percent"  // 0-100
+	UnitFraction RatioUnit = "fraction" // 0-1
+)
+
+type PowerUnit string
+
+const (
+	UnitWatts      PowerUnit = "watts"
+	UnitMilliwatts PowerUnit = "milliwatts"
+	UnitKilowatts  PowerUnit = "kilowatts"
+)
+
+type CarbonUnit string
+
+const (
+	UnitKilograms CarbonUnit = "kg"
+	UnitGrams     CarbonUnit = "g"
+)
+
+// maxPlausibleWatts - верхняя граница правдоподобного потребления одного сервера
+const maxPlausibleWatts = 50000.0
+
+// Границы правдоподобной температуры воздуха на входе, °C: за их пределами
+// почти наверняка прислали Фаренгейты или показание другого датчика
+const (
+	minPlausibleInletTemp = -40.0
+	maxPlausibleInletTemp = 70.0
+)
+
+// maxDiagnostics - сколько последних отказов хранится на источник
+const maxDiagnostics = 20
+
+// SourceUnits - единицы измерения, в которых источник (агент) присылает метрики.
+// Пустое поле означает, что единица не объявлена и определяется по данным.
+type SourceUnits struct {
+	CPU    RatioUnit  `json:"cpu,omitempty"`
+	Memory RatioUnit  `json:"memory,omitempty"`
+	GPU    RatioUnit  `json:"gpu,omitempty"`
+	Power  PowerUnit  `json:"power,omitempty"`
+	Carbon CarbonUnit `json:"carbon,omitempty"`
+	Energy EnergyUnit `json:"energy,omitempty"` // Единица energy_counter, по умолчанию джоули
+}
+
+// Diagnostic описывает отклоненную или исправленную точку
+type Diagnostic struct {
+	Source    string    `json:"source"`
+	ServerID  string    `json:"server_id"`
+	Field     string    `json:"field"`
+	Value     float64   `json:"value"`
+	Message   string    `json:"message"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// UnitError возвращается при отказе в приеме неоднозначных данных
+type UnitError struct {
+	Diagnostics []Diagnostic
+}
+
+func (e *UnitError) Error() string {
+	if len(e.Diagnostics) == 1 {
+		return e.Diagnostics[0].Message
+	}
+	return fmt.Sprintf("%d unit validation errors, first: %s", len(e.Diagnostics), e.Diagnostics[0].Message)
+}
+
+// UnitRegistry хранит объявленные и выведенные единицы измерения источников
+// и приводит входящие метрики к каноническим единицам: %, Вт, кг CO2
+type UnitRegistry struct {
+	mu          sync.RWMutex
+	declared    map[string]SourceUnits
+	inferred    map[string]SourceUnits
+	diagnostics map[string][]Diagnostic
+}
+
+func NewUnitRegistry() *UnitRegistry {
+	return &UnitRegistry{
+		declared:    make(map[string]SourceUnits),
+		inferred:    make(map[string]SourceUnits),
+		diagnostics: make(map[string][]Diagnostic),
+	}
+}
+
+// Declare объявляет единицы источника
+func (r *UnitRegistry) Declare(source string, units SourceUnits) error {
+	if !validRatio(units.CPU) || !validRatio(units.Memory) || !validRatio(units.GPU) {
+		return fmt.Errorf("ratio units must be %q or %q", UnitPercent, UnitFraction)
+	}
+	switch units.Power {
+	case "", UnitWatts, UnitMilliwatts, UnitKilowatts:
+	default:
+		return fmt.Errorf("unknown power unit: %s", units.Power)
+	}
+	switch units.Carbon {
+	case "", UnitKilograms, UnitGrams:
+	default:
+		return fmt.Errorf("unknown carbon unit: %s", units.Carbon)
+	}
+	switch units.Energy {
+	case "", UnitJoules, UnitWattHours, UnitKilowattHours:
+	default:
+		return fmt.Errorf("unknown energy unit: %s", units.Energy)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.declared[source] = units
+	return nil
+}
+
+// Units возвращает действующие единицы источника: объявленные или выведенные из данных
+func (r *UnitRegistry) Units(source string) SourceUnits {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.effective(source)
+}
+
+func (r *UnitRegistry) effective(source string) SourceUnits {
+	units := r.declared[source]
+	inferred := r.inferred[source]
+	if units.CPU == "" {
+		units.CPU = inferred.CPU
+	}
+	if units.Memory == "" {
+		units.Memory = inferred.Memory
+	}
+	if units.GPU == "" {
+		units.GPU = inferred.GPU
+	}
+	if units.Power == "" {
+		units.Power = UnitWatts
+	}
+	if units.Carbon == "" {
+		units.Carbon = UnitKilograms
+	}
+	if units.Energy == "" {
+		units.Energy = UnitJoules
+	}
+	return units
+}
+
+// Normalize приводит точку к каноническим единицам. Неоднозначные значения
+// от источников без объявленных единиц отклоняются с диагностикой.
+func (r *UnitRegistry) Normalize(source string, data models.MetricData) (models.MetricData, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.learn(source, data)
+	units := r.effective(source)
+
+	var problems []Diagnostic
+	reject := func(field string, value float64, message string) {
+		problems = append(problems, Diagnostic{
+			Source:    source,
+			ServerID:  data.ServerID,
+			Field:     field,
+			Value:     value,
+			Message:   message,
+			Timestamp: time.Now(),
+		})
+	}
+
+	var ok bool
+	if data.CPUUsage, ok = normalizeRatio(data.CPUUsage, units.CPU); !ok {
+		reject("cpu_usage", data.CPUUsage, "cpu_usage is ambiguous (fraction or percent); declare units for source "+source)
+	}
+	if data.MemoryUsage, ok = normalizeRatio(data.MemoryUsage, units.Memory); !ok {
+		reject("memory_usage", data.MemoryUsage, "memory_usage is ambiguous (fraction or percent); declare units for source "+source)
+	}
+	if data.GPUUsage, ok = normalizeRatio(data.GPUUsage, units.GPU); !ok {
+		reject("gpu_usage", data.GPUUsage, "gpu_usage is ambiguous (fraction or percent); declare units for source "+source)
+	}
+
+	switch units.Power {
+	case UnitMilliwatts:
+		data.PowerUsage /= 1000
+		data.GPUPowerUsage /= 1000
+	case UnitKilowatts:
+		data.PowerUsage *= 1000
+		data.GPUPowerUsage *= 1000
+	}
+	if data.PowerUsage > maxPlausibleWatts {
+		reject("power_usage", data.PowerUsage, fmt.Sprintf("power_usage %.0f W is implausible for a single server; check power unit", data.PowerUsage))
+	}
+	// Потребление GPU - часть общего потребления сервера, а не добавка к нему
+	if data.GPUPowerUsage < 0 || data.GPUPowerUsage > data.PowerUsage {
+		reject("gpu_power_usage", data.GPUPowerUsage, "gpu_power_usage must be between 0 and power_usage; power_usage must include GPU power")
+	}
+
+	if units.Carbon == UnitGrams {
+		data.CarbonFootprint /= 1000
+	}
+
+	if data.NetworkRxBytes < 0 || data.NetworkTxBytes < 0 {
+		reject("network_bytes", math.Min(data.NetworkRxBytes, data.NetworkTxBytes), "network_rx_bytes and network_tx_bytes must not be negative")
+	}
+	if data.DiskReadBytesPerSec < 0 || data.DiskWriteBytesPerSec < 0 || data.StorageUsedBytes < 0 {
+		reject("disk", math.Min(math.Min(data.DiskReadBytesPerSec, data.DiskWriteBytesPerSec), data.StorageUsedBytes), "disk_read_bps, disk_write_bps and storage_used_bytes must not be negative")
+	}
+
+	if data.InletTemperature < minPlausibleInletTemp || data.InletTemperature > maxPlausibleInletTemp {
+		reject("inlet_temp_c", data.InletTemperature, fmt.Sprintf("inlet_temp_c must be between %.0f and %.0f degrees Celsius", minPlausibleInletTemp, maxPlausibleInletTemp))
+	}
+
+	data.EnergyCounter = toJoules(data.EnergyCounter, units.Energy)
+	if data.EnergyCounter < 0 {
+		reject("energy_counter", data.EnergyCounter, "energy_counter must not be negative")
+	}
+
+	if len(problems) > 0 {
+		r.record(source, problems...)
+		return data, &UnitError{Diagnostics: problems}
+	}
+
+	return data, nil
+}
+
+// Note сохраняет диагностику об исправленной, но принятой точке
+func (r *UnitRegistry) Note(source, serverID, field string, value float64, message string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.record(source, Diagnostic{
+		Source:    source,
+		ServerID:  serverID,
+		Field:     field,
+		Value:     value,
+		Message:   message,
+		Timestamp: time.Now(),
+	})
+}
+
+func (r *UnitRegistry) record(source string, problems ...Diagnostic) {
+	diags := append(r.diagnostics[source], problems...)
+	if len(diags) > maxDiagnostics {
+		diags = diags[len(diags)-maxDiagnostics:]
+	}
+	r.diagnostics[source] = diags
+}
+
+// learn запоминает единицы, однозначно следующие из значения: доля не может превышать 1
+func (r *UnitRegistry) learn(source string, data models.MetricData) {
+	inferred := r.inferred[source]
+	if data.CPUUsage > 1 {
+		inferred.CPU = UnitPercent
+	}
+	if data.MemoryUsage > 1 {
+		inferred.Memory = UnitPercent
+	}
+	if data.GPUUsage > 1 {
+		inferred.GPU = UnitPercent
+	}
+	r.inferred[source] = inferred
+}
+
+// Diagnostics возвращает последние отказы и исправления по всем источникам
+func (r *UnitRegistry) Diagnostics() map[string][]Diagnostic {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	result := make(map[string][]Diagnostic, len(r.diagnostics))
+	for source, diags := range r.diagnostics {
+		result[source] = append([]Diagnostic(nil), diags...)
+	}
+	return result
+}
+
+func normalizeRatio(value float64, unit RatioUnit) (float64, bool) {
+	switch unit {
+	case UnitPercent:
+		return value, true
+	case UnitFraction:
+		return value * 100, true
+	}
+
+	// Единица не объявлена и не выведена: 0 и значения > 1 однозначны
+	if value == 0 || value > 1 {
+		return value, true
+	}
+	return value, false
+}
+
+func validRatio(unit RatioUnit) bool {
+	return unit == "" || unit == UnitPercent || unit == UnitFraction
+}
@@ -0,0 +1,205 @@
+package metrics
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/YumeNoTenshi/platypus/internal/models"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// maxFutureSkew - насколько метка времени точки может опережать часы
+// платформы: больше - часы источника сбиты, и точка исказит окна агрегации
+const maxFutureSkew = 5 * time.Minute
+
+// Причины отказа в приеме точки
+const (
+	RejectNotFinite       = "not_finite"       // NaN или бесконечность
+	RejectNegative        = "negative"         // Отрицательная мощность, углерод и т.п.
+	RejectOutOfRange      = "out_of_range"     // Процент вне 0-100
+	RejectFutureTimestamp = "future_timestamp" // Метка времени далеко впереди часов платформы
+	RejectInvalidTime     = "invalid_timestamp"
+	RejectUnits           = "units"  // Неоднозначные или неправдоподобные единицы (*UnitError)
+	RejectLabels          = "labels" // Некорректные метки (ErrInvalidLabels)
+)
+
+// Violation - нарушение в одном поле точки. Value - строка, так как NaN и
+// бесконечность не представимы в JSON.
+type Violation struct {
+	Field   string `json:"field"`
+	Value   string `json:"value"`
+	Reason  string `json:"reason"`
+	Message string `json:"message"`
+}
+
+// ValidationError возвращается, если точка не прошла проверку перед
+// сохранением; Violations перечисляет все нарушения точки
+type ValidationError struct {
+	ServerID   string
+	Violations []Violation
+}
+
+func (e *ValidationError) Error() string {
+	if len(e.Violations) == 1 {
+		return e.Violations[0].Message
+	}
+	return fmt.Sprintf("%d validation errors, first: %s", len(e.Violations), e.Violations[0].Message)
+}
+
+// pointCheck собирает нарушения одной точки
+type pointCheck struct {
+	violations []Violation
+}
+
+func (p *pointCheck) reject(field string, value float64, reason, message string) {
+	p.violations = append(p.violations, Violation{
+		Field:   field,
+		Value:   strconv.FormatFloat(value, 'f', -1, 64),
+		Reason:  reason,
+		Message: message,
+	})
+}
+
+func (p *pointCheck) err(serverID string) error {
+	if len(p.violations) == 0 {
+		return nil
+	}
+	return &ValidationError{ServerID: serverID, Violations: p.violations}
+}
+
+// pointFields возвращает числовые поля точки с их именами в JSON
+func pointFields(data models.MetricData) []struct {
+	name  string
+	value float64
+} {
+	return []struct {
+		name  string
+		value float64
+	}{
+		{"power_usage", data.PowerUsage},
+		{"carbon_footprint", data.CarbonFootprint},
+		{"cpu_usage", data.CPUUsage},
+		{"memory_usage", data.MemoryUsage},
+		{"gpu_usage", data.GPUUsage},
+		{"gpu_power_usage", data.GPUPowerUsage},
+		{"network_rx_bytes", data.NetworkRxBytes},
+		{"network_tx_bytes", data.NetworkTxBytes},
+		{"disk_read_bps", data.DiskReadBytesPerSec},
+		{"disk_write_bps", data.DiskWriteBytesPerSec},
+		{"storage_used_bytes", data.StorageUsedBytes},
+		{"inlet_temp_c", data.InletTemperature},
+		{"energy_counter", data.EnergyCounter},
+	}
+}
+
+// checkPoint проверяет точку до приведения единиц: числа конечны, мощность
+// и углерод неотрицательны (знак от единиц не зависит), метка времени не
+// опережает часы платформы больше чем на maxFutureSkew. NaN не проходит ни
+// одно сравнение, поэтому без этой проверки выглядел бы как неоднозначная
+// доля или прошел бы проверки единиц.
+func checkPoint(data models.MetricData, now time.Time) error {
+	var check pointCheck
+	for _, field := range pointFields(data) {
+		if math.IsNaN(field.value) || math.IsInf(field.value, 0) {
+			check.reject(field.name, field.value, RejectNotFinite, field.name+" must be a finite number")
+		}
+	}
+	if data.PowerUsage < 0 {
+		check.reject("power_usage", data.PowerUsage, RejectNegative, "power_usage must not be negative")
+	}
+	if data.CarbonFootprint < 0 {
+		check.reject("carbon_footprint", data.CarbonFootprint, RejectNegative, "carbon_footprint must not be negative")
+	}
+	if data.Timestamp < 0 {
+		check.reject("timestamp", float64(data.Timestamp), RejectInvalidTime, "timestamp must not be negative")
+	} else if time.Unix(data.Timestamp, 0).After(now.Add(maxFutureSkew)) {
+		check.reject("timestamp", float64(data.Timestamp), RejectFutureTimestamp,
+			fmt.Sprintf("timestamp is more than %s ahead of server time", maxFutureSkew))
+	}
+	return check.err(data.ServerID)
+}
+
+// checkPercentages проверяет, что загрузка в канонических единицах лежит в
+// пределах 0-100%
+func checkPercentages(data models.MetricData) error {
+	var check pointCheck
+	for _, field := range []struct {
+		name  string
+		value float64
+	}{
+		{"cpu_usage", data.CPUUsage},
+		{"memory_usage", data.MemoryUsage},
+		{"gpu_usage", data.GPUUsage},
+	} {
+		if field.value < 0 || field.value > 100 {
+			check.reject(field.name, field.value, RejectOutOfRange, field.name+" must be between 0 and 100 percent")
+		}
+	}
+	return check.err(data.ServerID)
+}
+
+// rejectionCounter считает отклоненные точки по причинам: для Prometheus
+// (platypus_metric_points_rejected_total) и для API диагностики приема
+type rejectionCounter struct {
+	total *prometheus.CounterVec
+
+	mu     sync.Mutex
+	counts map[string]uint64
+}
+
+func newRejectionCounter() *rejectionCounter {
+	return &rejectionCounter{
+		total: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "platypus_metric_points_rejected_total",
+			Help: "Metric points rejected before storage, by reason",
+		}, []string{"reason"}),
+		counts: make(map[string]uint64),
+	}
+}
+
+// count учитывает отказ по каждой причине из err; точка с нарушениями
+// нескольких видов учитывается по каждому из них один раз
+func (r *rejectionCounter) count(err error) {
+	reasons := map[string]bool{}
+	var validationErr *ValidationError
+	var unitErr *UnitError
+	switch {
+	case errors.As(err, &validationErr):
+		for _, violation := range validationErr.Violations {
+			reasons[violation.Reason] = true
+		}
+	case errors.As(err, &unitErr):
+		reasons[RejectUnits] = true
+	case errors.Is(err, ErrInvalidLabels):
+		reasons[RejectLabels] = true
+	default:
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for reason := range reasons {
+		r.total.WithLabelValues(reason).Inc()
+		r.counts[reason]++
+	}
+}
+
+func (r *rejectionCounter) snapshot() map[string]uint64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	counts := make(map[string]uint64, len(r.counts))
+	for reason, n := range r.counts {
+		counts[reason] = n
+	}
+	return counts
+}
+
+// Rejections возвращает число точек, отклоненных проверкой перед
+// сохранением, по причинам с момента запуска
+func (c *Collector) Rejections() map[string]uint64 {
+	return c.rejections.snapshot()
+}
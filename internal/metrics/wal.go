@@ -0,0 +1,415 @@
+package metrics
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/YumeNoTenshi/platypus/internal/encryption"
+)
+
+const (
+	walSegmentSuffix      = ".wal"
+	walCheckpointFile     = "checkpoint"
+	walHeaderSize         = 8 // Длина записи и CRC32, по 4 байта
+	walMaxRecordSize      = 64 << 20
+	defaultWALSegmentSize = 16 << 20
+	defaultWALSyncEvery   = time.Second
+)
+
+type WALConfig struct {
+	Dir         string
+	SegmentSize int64         // Размер сегмента, после которого начинается новый; по умолчанию 16 МиБ
+	SyncEvery   time.Duration // Период fsync и записи контрольной точки; по умолчанию 1 с
+	// Encryption - необязательное шифрование записей. Записи, сделанные до
+	// включения шифрования, читаются как есть.
+	Encryption *encryption.Keyring
+}
+
+// WALStats - состояние журнала для /status
+type WALStats struct {
+	Dir       string `json:"dir"`
+	Segments  int    `json:"segments"`
+	Pending   uint64 `json:"pending"` // Записанные, но еще не обработанные пакеты
+	LastError string `json:"last_error,omitempty"`
+}
+
+type walSegment struct {
+	first uint64 // Номер первой записи
+	path  string
+}
+
+type walRecord struct {
+	Seq   uint64      `json:"seq"`
+	Batch MetricBatch `json:"batch"`
+}
+
+// WAL - журнал упреждающей записи буфера сборщика. Пакет записывается в
+// журнал до постановки в буфер и отмечается обработанным после сохранения;
+// при запуске необработанные пакеты обрабатываются повторно. Запись идет
+// сразу в файл, поэтому падение процесса пакетов не теряет; от потери
+// питания защищает fsync раз в SyncEvery.
+//
+// Обработка пакетов из журнала - "хотя бы один раз": пакеты, обработанные
+// после последней контрольной точки, при повторе сохраняются еще раз.
+type WAL struct {
+	config WALConfig
+
+	mu        sync.Mutex
+	segments  []walSegment
+	file      *os.File // Текущий (последний) сегмент
+	size      int64
+	nextSeq   uint64
+	processed uint64 // Последний обработанный номер
+	saved     uint64 // processed на момент последней контрольной точки
+	unsynced  bool
+	lastError string
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// OpenWAL открывает журнал в каталоге, создавая его при необходимости.
+// Оборванная при падении последняя запись отбрасывается.
+func OpenWAL(config WALConfig) (*WAL, error) {
+	if config.Dir == "" {
+		return nil, fmt.Errorf("wal directory is required")
+	}
+	if config.SegmentSize <= 0 {
+		config.SegmentSize = defaultWALSegmentSize
+	}
+	if config.SyncEvery <= 0 {
+		config.SyncEvery = defaultWALSyncEvery
+	}
+	if err := os.MkdirAll(config.Dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	w := &WAL{config: config, stop: make(chan struct{}), done: make(chan struct{})}
+	if err := w.load(); err != nil {
+		return nil, err
+	}
+	go w.syncLoop()
+	return w, nil
+}
+
+func (w *WAL) load() error {
+	processed, err := w.readCheckpoint()
+	if err != nil {
+		return err
+	}
+	w.processed, w.saved = processed, processed
+
+	entries, err := os.ReadDir(w.config.Dir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasSuffix(name, walSegmentSuffix) {
+			continue
+		}
+		first, err := strconv.ParseUint(strings.TrimSuffix(name, walSegmentSuffix), 10, 64)
+		if err != nil {
+			continue
+		}
+		w.segments = append(w.segments, walSegment{first: first, path: filepath.Join(w.config.Dir, name)})
+	}
+	sort.Slice(w.segments, func(i, j int) bool { return w.segments[i].first < w.segments[j].first })
+
+	if len(w.segments) == 0 {
+		w.nextSeq = processed + 1
+		return w.rotate()
+	}
+
+	// Номер следующей записи и конец последней целой записи берутся из последнего сегмента
+	last := w.segments[len(w.segments)-1]
+	lastSeq, validSize, err := scanSegment(last.path, w.config.Encryption, nil)
+	if err != nil {
+		return err
+	}
+	w.nextSeq = last.first
+	if lastSeq >= last.first {
+		w.nextSeq = lastSeq + 1
+	}
+	if w.nextSeq <= processed {
+		w.nextSeq = processed + 1
+	}
+
+	file, err := os.OpenFile(last.path, os.O_RDWR, 0o644)
+	if err != nil {
+		return err
+	}
+	if err := file.Truncate(validSize); err != nil {
+		file.Close()
+		return err
+	}
+	if _, err := file.Seek(validSize, io.SeekStart); err != nil {
+		file.Close()
+		return err
+	}
+	w.file, w.size = file, validSize
+	return nil
+}
+
+// scanSegment читает записи сегмента по порядку, передавая их fn (если
+// задана), и возвращает номер последней целой записи и смещение ее конца.
+// Чтение останавливается на первой поврежденной или оборванной записи.
+// Целая запись, которую нечем расшифровать, - ошибка, а не обрыв: иначе
+// сегмент был бы обрезан и записи потеряны.
+func scanSegment(path string, keys *encryption.Keyring, fn func(record walRecord)) (uint64, int64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	var lastSeq uint64
+	var offset int64
+	header := make([]byte, walHeaderSize)
+	for {
+		if _, err := io.ReadFull(reader, header); err != nil {
+			return lastSeq, offset, nil
+		}
+		length := binary.LittleEndian.Uint32(header[:4])
+		checksum := binary.LittleEndian.Uint32(header[4:])
+		if length > walMaxRecordSize {
+			return lastSeq, offset, nil
+		}
+		payload := make([]byte, length)
+		if _, err := io.ReadFull(reader, payload); err != nil || crc32.ChecksumIEEE(payload) != checksum {
+			return lastSeq, offset, nil
+		}
+
+		data := payload
+		if encryption.Encrypted(payload) {
+			if keys == nil {
+				return lastSeq, offset, fmt.Errorf("wal segment %s is encrypted and no encryption keys are configured", path)
+			}
+			if data, err = keys.Open(payload); err != nil {
+				return lastSeq, offset, fmt.Errorf("wal segment %s: %w", path, err)
+			}
+		}
+
+		var record walRecord
+		if err := json.Unmarshal(data, &record); err != nil {
+			return lastSeq, offset, nil
+		}
+		if fn != nil {
+			fn(record)
+		}
+		lastSeq = record.Seq
+		offset += walHeaderSize + int64(length)
+	}
+}
+
+// rotate начинает новый сегмент с номера nextSeq. Вызывается под блокировкой.
+func (w *WAL) rotate() error {
+	if w.file != nil {
+		if err := w.file.Sync(); err != nil {
+			return err
+		}
+		if err := w.file.Close(); err != nil {
+			return err
+		}
+	}
+
+	path := filepath.Join(w.config.Dir, fmt.Sprintf("%020d%s", w.nextSeq, walSegmentSuffix))
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	w.segments = append(w.segments, walSegment{first: w.nextSeq, path: path})
+	w.file, w.size = file, 0
+	return nil
+}
+
+// Append записывает пакет и возвращает его номер
+func (w *WAL) Append(batch MetricBatch) (uint64, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.size >= w.config.SegmentSize {
+		if err := w.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	seq := w.nextSeq
+	payload, err := json.Marshal(walRecord{Seq: seq, Batch: batch})
+	if err != nil {
+		return 0, err
+	}
+	if w.config.Encryption != nil {
+		if payload, err = w.config.Encryption.Seal(payload); err != nil {
+			return 0, err
+		}
+	}
+	record := make([]byte, walHeaderSize+len(payload))
+	binary.LittleEndian.PutUint32(record[:4], uint32(len(payload)))
+	binary.LittleEndian.PutUint32(record[4:8], crc32.ChecksumIEEE(payload))
+	copy(record[walHeaderSize:], payload)
+
+	if _, err := w.file.Write(record); err != nil {
+		return 0, err
+	}
+	w.size += int64(len(record))
+	w.nextSeq++
+	w.unsynced = true
+	return seq, nil
+}
+
+// Ack отмечает пакеты до seq включительно обработанными
+func (w *WAL) Ack(seq uint64) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if seq > w.processed {
+		w.processed = seq
+	}
+}
+
+// Pending возвращает записанные, но не обработанные пакеты с их номерами
+func (w *WAL) Pending() ([]uint64, []MetricBatch, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	var seqs []uint64
+	var batches []MetricBatch
+	for i, segment := range w.segments {
+		// Сегмент целиком обработан, если следующий начинается не дальше processed+1
+		if i+1 < len(w.segments) && w.segments[i+1].first <= w.processed+1 {
+			continue
+		}
+		_, _, err := scanSegment(segment.path, w.config.Encryption, func(record walRecord) {
+			if record.Seq > w.processed {
+				seqs = append(seqs, record.Seq)
+				batches = append(batches, record.Batch)
+			}
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	return seqs, batches, nil
+}
+
+// Stats возвращает состояние журнала
+func (w *WAL) Stats() WALStats {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return WALStats{
+		Dir:       w.config.Dir,
+		Segments:  len(w.segments),
+		Pending:   w.nextSeq - 1 - w.processed,
+		LastError: w.lastError,
+	}
+}
+
+func (w *WAL) syncLoop() {
+	defer close(w.done)
+	ticker := time.NewTicker(w.config.SyncEvery)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.stop:
+			return
+		case <-ticker.C:
+			if err := w.Sync(); err != nil {
+				log.Printf("Ошибка синхронизации журнала метрик: %v", err)
+			}
+		}
+	}
+}
+
+// Sync сбрасывает сегмент на диск, сохраняет контрольную точку и удаляет
+// полностью обработанные сегменты
+func (w *WAL) Sync() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	err := w.syncLocked()
+	if err != nil {
+		w.lastError = err.Error()
+	} else {
+		w.lastError = ""
+	}
+	return err
+}
+
+func (w *WAL) syncLocked() error {
+	if w.unsynced {
+		if err := w.file.Sync(); err != nil {
+			return err
+		}
+		w.unsynced = false
+	}
+	if w.processed == w.saved {
+		return nil
+	}
+	if err := w.writeCheckpoint(w.processed); err != nil {
+		return err
+	}
+	w.saved = w.processed
+
+	// Последний сегмент открыт для записи и не удаляется
+	for len(w.segments) > 1 && w.segments[1].first <= w.processed+1 {
+		if err := os.Remove(w.segments[0].path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		w.segments = w.segments[1:]
+	}
+	return nil
+}
+
+func (w *WAL) readCheckpoint() (uint64, error) {
+	data, err := os.ReadFile(filepath.Join(w.config.Dir, walCheckpointFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	seq, err := strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid wal checkpoint: %w", err)
+	}
+	return seq, nil
+}
+
+// writeCheckpoint заменяет файл контрольной точки атомарно
+func (w *WAL) writeCheckpoint(seq uint64) error {
+	path := filepath.Join(w.config.Dir, walCheckpointFile)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(strconv.FormatUint(seq, 10)), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// Close сохраняет контрольную точку и закрывает журнал
+func (w *WAL) Close() error {
+	close(w.stop)
+	<-w.done
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err := w.syncLocked(); err != nil {
+		w.file.Close()
+		return err
+	}
+	return w.file.Close()
+}
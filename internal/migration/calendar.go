@@ -0,0 +1,48 @@
+package migration
+
+import (
+    "context"
+    "fmt"
+    "time"
+
+    "github.com/YumeNoTenshi/platypus/internal/calendar"
+)
+
+// minCalendarDuration - длительность события миграции без оценки простоя
+const minCalendarDuration = 5 * time.Minute
+
+// Events публикует миграции из очереди в календаре: миграция с простоем -
+// действие, о котором дежурному стоит знать заранее. Время запуска зависит
+// от ограничений очереди, поэтому ожидающие миграции помечены как
+// предварительные и показаны с ближайшего возможного момента.
+func (p *Planner) Events(ctx context.Context, from, to time.Time) ([]calendar.Event, error) {
+    now := time.Now()
+    var events []calendar.Event
+    for _, plan := range p.Plans() {
+        start := now
+        if plan.NotBefore.After(start) {
+            start = plan.NotBefore
+        }
+        duration := plan.DowntimeEstimate
+        if duration < minCalendarDuration {
+            duration = minCalendarDuration
+        }
+
+        description := fmt.Sprintf("Container %s moves from %s (%s) to %s (%s). Estimated downtime: %s.",
+            plan.ContainerID, plan.SourceServerID, plan.SourceRegion, plan.TargetServerID, plan.TargetRegion, plan.DowntimeEstimate)
+        if plan.BlockedReason != "" {
+            description += " Waiting: " + plan.BlockedReason + "."
+        }
+
+        events = append(events, calendar.Event{
+            UID:         fmt.Sprintf("migration-%s-%d@platypus", plan.ContainerID, plan.QueuedAt.Unix()),
+            Summary:     fmt.Sprintf("Migration of %s to %s", plan.ContainerID, plan.TargetServerID),
+            Description: description,
+            Start:       start,
+            End:         start.Add(duration),
+            Category:    calendar.CategoryMigration,
+            Tentative:   !plan.Running,
+        })
+    }
+    return events, nil
+}
@@ -0,0 +1,54 @@
+package migration
+
+import (
+    "time"
+
+    "github.com/YumeNoTenshi/platypus/internal/models"
+)
+
+// ToleranceClass - класс устойчивости сервиса к простою при миграции
+type ToleranceClass struct {
+    MaxDowntime      time.Duration `json:"max_downtime"`      // 0 - используется общий PlannerConfig.MaxDowntime
+    PriorityModifier int           `json:"priority_modifier"` // Добавляется к приоритету плана
+    Pinned           bool          `json:"pinned"`            // Сервис не переносится вовсе
+}
+
+// DowntimeClassifier определяет класс сервиса; пустая строка - класс не определен
+type DowntimeClassifier func(container models.Container) string
+
+// DowntimePolicy сопоставляет сервисы классам устойчивости к простою
+type DowntimePolicy struct {
+    Classes  map[string]ToleranceClass `json:"classes"`
+    Services map[string]string         `json:"services"` // Имя сервиса -> класс
+    Default  string                    `json:"default"`
+}
+
+// DefaultDowntimePolicy возвращает встроенные классы: stateless-сервисы переносятся
+// охотнее и с большим простоем, stateful - только быстро, critical не переносятся
+func DefaultDowntimePolicy() DowntimePolicy {
+    return DowntimePolicy{
+        Classes: map[string]ToleranceClass{
+            "stateless": {MaxDowntime: 5 * time.Minute, PriorityModifier: 1},
+            "standard":  {},
+            "stateful":  {MaxDowntime: 30 * time.Second, PriorityModifier: -2},
+            "critical":  {Pinned: true},
+        },
+        Default: "standard",
+    }
+}
+
+// class возвращает имя и параметры класса контейнера: сначала внешний
+// классификатор (например, метки инвентаря), затем явное сопоставление сервиса
+func (p DowntimePolicy) class(container models.Container, classifier DowntimeClassifier) (string, ToleranceClass) {
+    name := ""
+    if classifier != nil {
+        name = classifier(container)
+    }
+    if _, ok := p.Classes[name]; !ok {
+        name = p.Services[container.ServiceName]
+    }
+    if _, ok := p.Classes[name]; !ok {
+        name = p.Default
+    }
+    return name, p.Classes[name]
+}
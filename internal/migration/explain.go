@@ -0,0 +1,116 @@
+package migration
+
+import (
+    "fmt"
+    "time"
+
+    "github.com/YumeNoTenshi/platypus/internal/objectives"
+)
+
+// Причины, по которым цель не выбрана
+const (
+    ReasonExempt              = "exempt"                 // Сервер исключен из миграций
+    ReasonObserveOnly         = "observe_only"           // Провайдер цели в режиме наблюдения
+    ReasonSavingsBelowMinimum = "savings_below_minimum"  // Экономия меньше MinPowerSaving
+    ReasonDowntimeExceeded    = "downtime_exceeds_limit" // Простой больше предела класса сервиса
+    ReasonThermal             = "thermal_limit"          // Цель у предела охлаждения
+    ReasonObjectiveScore      = "objective_score"        // Взвешенная оценка не положительна: простой перевешивает выгоды
+    ReasonOutscored           = "outscored"              // Есть цель с большей оценкой
+)
+
+// Итог планирования контейнера
+const (
+    OutcomePlanned  = "planned"   // Миграция в очереди
+    OutcomeWithheld = "withheld"  // Подходящие цели отклонены ограничениями размещения
+    OutcomeNoTarget = "no_target" // Ни одна цель не дает достаточной экономии при допустимом простое
+    OutcomePinned   = "pinned"    // Класс сервиса запрещает миграции
+)
+
+// CandidateEvaluation - цель, рассмотренная для контейнера, с оценками и
+// причиной отказа
+type CandidateEvaluation struct {
+    ServerID         string            `json:"server_id"`
+    Region           string            `json:"region,omitempty"`
+    PowerSaving      float64           `json:"power_saving"`
+    DowntimeEstimate time.Duration     `json:"downtime_estimate"`
+    Objectives       objectives.Scores `json:"objectives"`
+    Score            float64           `json:"score,omitempty"` // Взвешенная оценка с учетом подсказок размещения и резервов
+    Reserved         string            `json:"reservation,omitempty"`
+    Chosen           bool              `json:"chosen,omitempty"`
+    Reason           string            `json:"reason,omitempty"`
+    Detail           string            `json:"detail,omitempty"`
+}
+
+// PlanInputs - данные, на которых основано решение
+type PlanInputs struct {
+    SourceEcoScore    float64 `json:"source_eco_score"` // Нормализованный эко-рейтинг источника
+    SourceRegion      string  `json:"source_region"`
+    SourceReservation string  `json:"source_reservation,omitempty"`
+    ContainerPower    float64 `json:"container_power"` // Среднее потребление контейнера, Вт
+}
+
+// PlanThresholds - пороги, действовавшие при планировании
+type PlanThresholds struct {
+    SourceEcoScoreMax   float64       `json:"source_eco_score_max"` // Источник с рейтингом выше не разгружается
+    MinPowerSaving      float64       `json:"min_power_saving"`
+    MaxDowntime         time.Duration `json:"max_downtime"`
+    DowntimeClass       string        `json:"downtime_class"`
+    MaxInletTemperature float64       `json:"max_inlet_temp_c,omitempty"`
+    ThermalHeadroom     float64       `json:"thermal_headroom_c,omitempty"`
+    PreferGreenRegions  bool          `json:"prefer_green_regions,omitempty"`
+}
+
+// PlanExplanation объясняет решение планировщика по контейнеру: входные
+// данные, пороги, рассмотренные цели и почему они отклонены
+type PlanExplanation struct {
+    ContainerID    string                `json:"container_id"`
+    SourceServerID string                `json:"source_server_id"`
+    Outcome        string                `json:"outcome"`
+    TargetServerID string                `json:"target_server_id,omitempty"`
+    Summary        string                `json:"summary"`
+    Inputs         PlanInputs            `json:"inputs"`
+    Thresholds     PlanThresholds        `json:"thresholds"`
+    Objectives     objectives.Selection  `json:"objective_weights"`
+    Candidates     []CandidateEvaluation `json:"candidates"`
+    ExplainedAt    time.Time             `json:"explained_at"`
+}
+
+// Explain возвращает объяснение последнего решения по контейнеру; false -
+// контейнер не рассматривался (например, его сервер достаточно эффективен)
+func (p *Planner) Explain(containerID string) (PlanExplanation, bool) {
+    p.mu.RLock()
+    defer p.mu.RUnlock()
+    explanation, exists := p.explanations[containerID]
+    return explanation, exists
+}
+
+// finish подводит итог объяснения: цели, проигравшие выбранной, получают
+// причину outscored
+func (e *PlanExplanation) finish(plan *MigrationPlan, rejected []TargetRejection) {
+    switch {
+    case plan != nil:
+        e.Outcome, e.TargetServerID = OutcomePlanned, plan.TargetServerID
+        var best float64
+        for i := range e.Candidates {
+            if e.Candidates[i].ServerID == plan.TargetServerID {
+                e.Candidates[i].Chosen = true
+                best = e.Candidates[i].Score
+            }
+        }
+        for i := range e.Candidates {
+            candidate := &e.Candidates[i]
+            if !candidate.Chosen && candidate.Reason == "" {
+                candidate.Reason = ReasonOutscored
+                candidate.Detail = fmt.Sprintf("score %.2f is below %.2f of %s", candidate.Score, best, plan.TargetServerID)
+            }
+        }
+        e.Summary = fmt.Sprintf("migrate to %s saving %.1f W with %s downtime", plan.TargetServerID, plan.PowerSaving, plan.DowntimeEstimate)
+    case len(rejected) > 0:
+        e.Outcome = OutcomeWithheld
+        e.Summary = fmt.Sprintf("%d suitable targets rejected by placement constraints", len(rejected))
+    default:
+        e.Outcome = OutcomeNoTarget
+        e.Summary = fmt.Sprintf("none of %d targets saves at least %.1f W within %s downtime with a positive %s score",
+            len(e.Candidates), e.Thresholds.MinPowerSaving, e.Thresholds.MaxDowntime, e.Objectives.Environment)
+    }
+}
@@ -0,0 +1,93 @@
+package migration
+
+import (
+    "sort"
+    "time"
+
+    "github.com/YumeNoTenshi/platypus/internal/metrics"
+)
+
+const (
+    // maxHistory - сколько выполненных миграций хранится
+    maxHistory = 1000
+    // restartWindow - запуски контейнера в течение этого времени после
+    // миграции связываются с ней: частые перезапуски на цели - признак
+    // неудачного переноса
+    restartWindow = 24 * time.Hour
+)
+
+// MigrationRecord - выполненная миграция и последовавшие за ней запуски и
+// переносы контейнера по событиям жизненного цикла
+type MigrationRecord struct {
+    ContainerID    string                   `json:"container_id"`
+    SourceServerID string                   `json:"source_server_id"`
+    TargetServerID string                   `json:"target_server_id"`
+    Provider       string                   `json:"provider"`
+    PowerSaving    float64                  `json:"power_saving"`
+    CompletedAt    time.Time                `json:"completed_at"`
+    Restarts       []metrics.ContainerEvent `json:"restarts,omitempty"`
+}
+
+// recordMigration добавляет выполненную миграцию в историю. Вызывается под блокировкой.
+func (p *Planner) recordMigration(plan MigrationPlan, completedAt time.Time) {
+    p.history = append(p.history, MigrationRecord{
+        ContainerID:    plan.ContainerID,
+        SourceServerID: plan.SourceServerID,
+        TargetServerID: plan.TargetServerID,
+        Provider:       plan.Provider,
+        PowerSaving:    plan.PowerSaving,
+        CompletedAt:    completedAt,
+    })
+    if len(p.history) > maxHistory {
+        p.history = append([]MigrationRecord(nil), p.history[len(p.history)-maxHistory:]...)
+    }
+}
+
+// ContainerEvent связывает запуск или перенос контейнера с его последней
+// миграцией, если событие произошло в течение restartWindow после нее.
+// Перенос, которым была сама миграция, не учитывается.
+func (p *Planner) ContainerEvent(event metrics.ContainerEvent) {
+    if event.Type == metrics.ContainerStopped {
+        return
+    }
+
+    p.mu.Lock()
+    defer p.mu.Unlock()
+
+    for i := len(p.history) - 1; i >= 0; i-- {
+        record := &p.history[i]
+        if record.ContainerID != event.ContainerID || record.CompletedAt.After(event.Timestamp) {
+            continue
+        }
+        if event.Timestamp.Sub(record.CompletedAt) > restartWindow {
+            return
+        }
+        if event.Type == metrics.ContainerRescheduled &&
+            event.PreviousServerID == record.SourceServerID && event.ServerID == record.TargetServerID {
+            return
+        }
+        record.Restarts = append(record.Restarts, event)
+        sort.SliceStable(record.Restarts, func(a, b int) bool {
+            return record.Restarts[a].Timestamp.Before(record.Restarts[b].Timestamp)
+        })
+        return
+    }
+}
+
+// History возвращает выполненные миграции от новых к старым; непустой
+// containerID оставляет миграции одного контейнера
+func (p *Planner) History(containerID string) []MigrationRecord {
+    p.mu.RLock()
+    defer p.mu.RUnlock()
+
+    records := make([]MigrationRecord, 0)
+    for i := len(p.history) - 1; i >= 0; i-- {
+        record := p.history[i]
+        if containerID != "" && record.ContainerID != containerID {
+            continue
+        }
+        record.Restarts = append([]metrics.ContainerEvent(nil), record.Restarts...)
+        records = append(records, record)
+    }
+    return records
+}
@@ -0,0 +1,158 @@
+package migration
+
+import (
+    "fmt"
+    "sort"
+    "sync"
+    "time"
+)
+
+// RateLimit ограничивает число запусков миграций за скользящее окно
+type RateLimit struct {
+    Max int           `json:"max"`
+    Per time.Duration `json:"per"`
+}
+
+// MigrationLimits - ограничения на выполнение миграций поверх общего ConcurrentMigrations.
+// Нулевое значение означает отсутствие ограничения.
+type MigrationLimits struct {
+    PerProvider   int                  `json:"per_provider"`   // Одновременных миграций на провайдера
+    PerRegion     int                  `json:"per_region"`     // Одновременных миграций, затрагивающих регион
+    Providers     map[string]int       `json:"providers"`      // Переопределения PerProvider
+    Regions       map[string]int       `json:"regions"`        // Переопределения PerRegion
+    Rate          RateLimit            `json:"rate"`           // Запусков по всему парку за окно
+    ProviderRates map[string]RateLimit `json:"provider_rates"` // Запусков на провайдера за окно (лимиты облачных API)
+}
+
+// QueueStatus описывает очередь миграций для API статуса
+type QueueStatus struct {
+    Queued     int                   `json:"queued"`
+    Running    int                   `json:"running"`
+    ByProvider map[string]QueueDepth `json:"by_provider"`
+    ByRegion   map[string]QueueDepth `json:"by_region"`
+    Blocked    []BlockedMigration    `json:"blocked,omitempty"`
+}
+
+type QueueDepth struct {
+    Queued  int `json:"queued"`
+    Running int `json:"running"`
+}
+
+// BlockedMigration - миграция, ожидающая в очереди, и причина ожидания
+type BlockedMigration struct {
+    ContainerID string    `json:"container_id"`
+    Reason      string    `json:"reason"`
+    QueuedAt    time.Time `json:"queued_at"`
+}
+
+// limiter считает выполняющиеся миграции и запуски в окнах
+type limiter struct {
+    concurrent int
+    limits     MigrationLimits
+
+    mu         sync.Mutex
+    running    int
+    byProvider map[string]int
+    byRegion   map[string]int
+    starts     []time.Time
+    provStarts map[string][]time.Time
+}
+
+func newLimiter(concurrent int, limits MigrationLimits) *limiter {
+    return &limiter{
+        concurrent: concurrent,
+        limits:     limits,
+        byProvider: make(map[string]int),
+        byRegion:   make(map[string]int),
+        provStarts: make(map[string][]time.Time),
+    }
+}
+
+// regions возвращает затрагиваемые миграцией регионы без повторов
+func (p MigrationPlan) regions() []string {
+    if p.SourceRegion == p.TargetRegion {
+        return []string{p.SourceRegion}
+    }
+    return []string{p.SourceRegion, p.TargetRegion}
+}
+
+// acquire занимает слоты для плана или возвращает причину, по которой он остается в очереди
+func (l *limiter) acquire(plan MigrationPlan, now time.Time) (bool, string) {
+    l.mu.Lock()
+    defer l.mu.Unlock()
+
+    if l.concurrent > 0 && l.running >= l.concurrent {
+        return false, "concurrent migration limit reached"
+    }
+    if limit := limitFor(l.limits.Providers, plan.Provider, l.limits.PerProvider); limit > 0 && l.byProvider[plan.Provider] >= limit {
+        return false, fmt.Sprintf("provider %s concurrency limit reached", plan.Provider)
+    }
+    for _, region := range plan.regions() {
+        if limit := limitFor(l.limits.Regions, region, l.limits.PerRegion); limit > 0 && l.byRegion[region] >= limit {
+            return false, fmt.Sprintf("region %s concurrency limit reached", region)
+        }
+    }
+
+    l.starts = prune(l.starts, now, l.limits.Rate.Per)
+    if rate := l.limits.Rate; rate.Max > 0 && len(l.starts) >= rate.Max {
+        return false, "migration rate limit reached"
+    }
+    rate, limited := l.limits.ProviderRates[plan.Provider]
+    if limited {
+        l.provStarts[plan.Provider] = prune(l.provStarts[plan.Provider], now, rate.Per)
+        if rate.Max > 0 && len(l.provStarts[plan.Provider]) >= rate.Max {
+            return false, fmt.Sprintf("provider %s rate limit reached", plan.Provider)
+        }
+    }
+
+    l.running++
+    l.byProvider[plan.Provider]++
+    for _, region := range plan.regions() {
+        l.byRegion[region]++
+    }
+    if l.limits.Rate.Max > 0 {
+        l.starts = append(l.starts, now)
+    }
+    if limited {
+        l.provStarts[plan.Provider] = append(l.provStarts[plan.Provider], now)
+    }
+    return true, ""
+}
+
+func (l *limiter) release(plan MigrationPlan) {
+    l.mu.Lock()
+    defer l.mu.Unlock()
+
+    l.running--
+    l.byProvider[plan.Provider]--
+    for _, region := range plan.regions() {
+        l.byRegion[region]--
+    }
+}
+
+func limitFor(overrides map[string]int, key string, fallback int) int {
+    if limit, ok := overrides[key]; ok {
+        return limit
+    }
+    return fallback
+}
+
+// prune удаляет из окна запуски старше per
+func prune(starts []time.Time, now time.Time, per time.Duration) []time.Time {
+    cutoff := now.Add(-per)
+    i := 0
+    for i < len(starts) && !starts[i].After(cutoff) {
+        i++
+    }
+    return starts[i:]
+}
+
+// sortByPriority упорядочивает планы: сначала высокий приоритет, затем более давние
+func sortByPriority(plans []*MigrationPlan) {
+    sort.Slice(plans, func(i, j int) bool {
+        if plans[i].Priority != plans[j].Priority {
+            return plans[i].Priority > plans[j].Priority
+        }
+        return plans[i].QueuedAt.Before(plans[j].QueuedAt)
+    })
+}
@@ -2,31 +2,110 @@ package migration
 
 import (
     "context"
+    "errors"
+    "fmt"
+    "log"
+    "math"
     "sort"
     "sync"
     "time"
     
+    "github.com/YumeNoTenshi/platypus/internal/alerting"
     "github.com/YumeNoTenshi/platypus/internal/metrics"
     "github.com/YumeNoTenshi/platypus/internal/models"
+    "github.com/YumeNoTenshi/platypus/internal/objectives"
+    "github.com/YumeNoTenshi/platypus/internal/scheduler"
+    "github.com/YumeNoTenshi/platypus/internal/supervisor"
+    "github.com/YumeNoTenshi/platypus/pkg/catalog"
     "github.com/YumeNoTenshi/platypus/pkg/cloud"
 )
 
+// greenRegionBonus - во сколько раз экономия в низкоуглеродном регионе
+// ценится выше при включенной подсказке PreferGreenRegions
+const greenRegionBonus = 1.25
+
+// sourceEcoScoreMax - серверы с нормализованным эко-рейтингом выше не
+// разгружаются: они достаточно эффективны
+const sourceEcoScoreMax = 70
+
+const (
+    throttleBackoff = 1 * time.Minute // Пауза после троттлинга, если провайдер не подсказал свою
+    failureBackoff  = 5 * time.Minute // Пауза перед повтором после прочих ошибок
+)
+
+// PlacementHints - необязательные предпочтения при выборе места размещения
+type PlacementHints struct {
+    PreferGreenRegions bool `json:"prefer_green_regions"`
+}
+
+// weight возвращает множитель привлекательности размещения в регионе
+func (h PlacementHints) weight(region string) float64 {
+    if h.PreferGreenRegions && catalog.IsGreenRegion(region) {
+        return greenRegionBonus
+    }
+    return 1
+}
+
 type MigrationPlan struct {
-    ContainerID     string
-    SourceServerID  string
-    TargetServerID  string
-    Priority        int     // 1-10, где 10 - наивысший приоритет
-    PowerSaving     float64 // Ожидаемая экономия энергии в ваттах
-    DowntimeEstimate time.Duration
+    ContainerID     string        `json:"container_id"`
+    SourceServerID  string        `json:"source_server_id"`
+    TargetServerID  string        `json:"target_server_id"`
+    Priority        int           `json:"priority"`     // 1-10, где 10 - наивысший приоритет
+    PowerSaving     float64       `json:"power_saving"` // Ожидаемая экономия энергии в ваттах
+    DowntimeEstimate time.Duration `json:"downtime_estimate"`
+    DowntimeClass   string        `json:"downtime_class"` // Класс устойчивости сервиса к простою
+    Provider        string        `json:"provider"`
+    SourceRegion    string        `json:"source_region"`
+    TargetRegion    string        `json:"target_region"`
+    QueuedAt        time.Time     `json:"queued_at"`
+    Running         bool          `json:"running"`
+    BlockedReason   string        `json:"blocked_reason,omitempty"` // Почему план ждет в очереди
+    LastError       string        `json:"last_error,omitempty"`     // Ошибка последней попытки; план будет повторен
+    NotBefore       time.Time     `json:"not_before,omitempty"`     // Не повторять раньше этого времени
+    Rejected        []TargetRejection `json:"rejected_targets,omitempty"` // Подходящие цели, отклоненные ограничениями размещения
+    // SourceReservation - резервирование, покрывающее исходный хост. Его
+    // освобождение экономит энергию, но не деньги: резерв оплачивается дальше.
+    SourceReservation string `json:"source_reservation,omitempty"`
+    TargetReservation string `json:"target_reservation,omitempty"`
+    Objectives        objectives.Selection `json:"objective_weights"` // Веса целей, с которыми выбрана цель
+}
+
+// WithheldMigration - контейнер, для которого все подходящие цели отклонены
+// ограничениями размещения
+type WithheldMigration struct {
+    ContainerID    string            `json:"container_id"`
+    SourceServerID string            `json:"source_server_id"`
+    Rejected       []TargetRejection `json:"rejected_targets"`
+    CheckedAt      time.Time         `json:"checked_at"`
+}
+
+// PlanPreview - очередь миграций вместе с миграциями, не запланированными
+// из-за ограничений
+type PlanPreview struct {
+    Plans    []MigrationPlan     `json:"plans"`
+    Withheld []WithheldMigration `json:"withheld,omitempty"`
+    // Reservations - использование резервирований сейчас и после очереди,
+    // рядом с экономией мощности планов
+    Reservations []ReservationUtilization `json:"reservations,omitempty"`
 }
 
 type PlannerConfig struct {
     MinPowerSaving      float64       // Минимальная экономия энергии для миграции (ватты)
-    MaxDowntime         time.Duration // Максимальное допустимое время простоя
+    MaxDowntime         time.Duration // Максимальное допустимое время простоя по умолчанию
     PlanningInterval    time.Duration // Интервал планирования миграций
     ConcurrentMigrations int         // Максимальное количество одновременных миграций
+    Limits              MigrationLimits // Ограничения по провайдерам, регионам и окнам
+    DispatchInterval    time.Duration   // Как часто пробовать запустить миграции из очереди
+    Hints               PlacementHints
+    Downtime            DowntimePolicy // Классы устойчивости сервисов к простою
+    Thermal             ThermalPolicy  // Отказ от целей, работающих у предела охлаждения
+    Objectives          objectives.Policy // Веса целей по окружениям; нулевые - только экономия мощности
 }
 
+// EnvironmentClassifier определяет окружение контейнера (метка environment)
+// для выбора весов целей; пустая строка - веса по умолчанию
+type EnvironmentClassifier func(container models.Container) string
+
 type Planner struct {
     config      PlannerConfig
     collector   *metrics.Collector
@@ -34,57 +113,249 @@ type Planner struct {
     provider    cloud.CloudProvider
     mu          sync.RWMutex
     activePlans map[string]*MigrationPlan // ContainerID -> Plan
+    withheld    map[string]WithheldMigration // ContainerID -> отклоненные цели последнего планирования
+    explanations map[string]PlanExplanation  // ContainerID -> объяснение последнего решения
+    classifier  DowntimeClassifier
+    environment EnvironmentClassifier
+    exempt      func(serverID string) bool
+    rollout     *cloud.Rollout // Провайдеры в режиме наблюдения не участвуют в миграциях
+    limiter     *limiter
+    finished    chan struct{} // Сигнал о завершении миграции: освободились слоты
+    alerts      *alerting.Dispatcher
+    panics      func(err error)
+
+    reservations []Reservation
+    covered      map[string]string // Сервер -> покрывающее его резервирование, по последнему планированию
+    vacating     map[string]bool   // Серверы, с которых очередь уносит все контейнеры
+
+    history []MigrationRecord // Выполненные миграции, от старых к новым
 }
 
 func NewPlanner(config PlannerConfig, collector *metrics.Collector, analyzer *metrics.Analyzer, provider cloud.CloudProvider) *Planner {
+    if config.DispatchInterval <= 0 {
+        config.DispatchInterval = 10 * time.Second
+    }
+
     return &Planner{
         config:      config,
         collector:   collector,
         analyzer:    analyzer,
         provider:    provider,
         activePlans: make(map[string]*MigrationPlan),
+        withheld:    make(map[string]WithheldMigration),
+        explanations: make(map[string]PlanExplanation),
+        covered:     make(map[string]string),
+        vacating:    make(map[string]bool),
+        limiter:     newLimiter(config.ConcurrentMigrations, config.Limits),
+        finished:    make(chan struct{}, 1),
     }
 }
 
-func (p *Planner) Start(ctx context.Context) error {
-    ticker := time.NewTicker(p.config.PlanningInterval)
-    defer ticker.Stop()
+// SetMinPowerSaving обновляет минимальную экономию для миграции (например, по политике федерации)
+func (p *Planner) SetMinPowerSaving(watts float64) {
+    p.mu.Lock()
+    defer p.mu.Unlock()
+    p.config.MinPowerSaving = watts
+}
 
-    for {
-        select {
-        case <-ctx.Done():
-            return ctx.Err()
-        case <-ticker.C:
-            if err := p.planMigrations(ctx); err != nil {
-                // Логируем ошибку, но продолжаем работу
-                continue
-            }
-            if err := p.executeMigrations(ctx); err != nil {
-                continue
-            }
-        }
+// SetDowntimeClassifier подключает внешний источник классов простоя (например, метки инвентаря)
+func (p *Planner) SetDowntimeClassifier(classifier DowntimeClassifier) {
+    p.mu.Lock()
+    defer p.mu.Unlock()
+    p.classifier = classifier
+}
+
+// SetEnvironmentClassifier подключает источник окружений контейнеров для
+// выбора весов целей (например, метки инвентаря)
+func (p *Planner) SetEnvironmentClassifier(classifier EnvironmentClassifier) {
+    p.mu.Lock()
+    defer p.mu.Unlock()
+    p.environment = classifier
+}
+
+// objectiveWeights возвращает веса целей окружения контейнера
+func (p *Planner) objectiveWeights(container models.Container) objectives.Selection {
+    p.mu.RLock()
+    classifier := p.environment
+    p.mu.RUnlock()
+
+    environment := ""
+    if classifier != nil {
+        environment = classifier(container)
+    }
+    return p.config.Objectives.For(environment)
+}
+
+// SetExemptions подключает список серверов, исключенных из миграций: они не
+// бывают ни источником, ни целью
+func (p *Planner) SetExemptions(exempt func(serverID string) bool) {
+    p.mu.Lock()
+    defer p.mu.Unlock()
+    p.exempt = exempt
+}
+
+// SetRollout подключает режимы провайдеров: серверы провайдеров в режиме
+// наблюдения не бывают ни источником, ни целью миграций
+func (p *Planner) SetRollout(rollout *cloud.Rollout) {
+    p.mu.Lock()
+    defer p.mu.Unlock()
+    p.rollout = rollout
+}
+
+func (p *Planner) observeOnly(server models.Server) bool {
+    p.mu.RLock()
+    rollout := p.rollout
+    p.mu.RUnlock()
+    return rollout.ObserveOnly(server.Provider)
+}
+
+func (p *Planner) exempted(serverID string) bool {
+    p.mu.RLock()
+    exempt := p.exempt
+    p.mu.RUnlock()
+    return exempt != nil && exempt(serverID)
+}
+
+// SetAlerts включает оповещения об ошибках провайдера, требующих вмешательства
+func (p *Planner) SetAlerts(alerts *alerting.Dispatcher) {
+    p.mu.Lock()
+    defer p.mu.Unlock()
+    p.alerts = alerts
+}
+
+// SetPanicHandler задает получателя паник при выполнении миграции
+// (*supervisor.PanicError). Миграция с паникой повторяется как после ошибки.
+func (p *Planner) SetPanicHandler(handler func(err error)) {
+    p.mu.Lock()
+    defer p.mu.Unlock()
+    p.panics = handler
+}
+
+// downtimeClass возвращает класс контейнера и его предел простоя
+func (p *Planner) downtimeClass(container models.Container) (string, ToleranceClass, time.Duration) {
+    p.mu.RLock()
+    classifier := p.classifier
+    p.mu.RUnlock()
+
+    name, class := p.config.Downtime.class(container, classifier)
+    limit := class.MaxDowntime
+    if limit == 0 {
+        limit = p.config.MaxDowntime
+    }
+    return name, class, limit
+}
+
+func (p *Planner) minPowerSaving() float64 {
+    p.mu.RLock()
+    defer p.mu.RUnlock()
+    return p.config.MinPowerSaving
+}
+
+// Plans возвращает копию запланированных, но еще не выполненных миграций
+func (p *Planner) Plans() []MigrationPlan {
+    p.mu.RLock()
+    defer p.mu.RUnlock()
+
+    plans := make([]MigrationPlan, 0, len(p.activePlans))
+    for _, plan := range p.activePlans {
+        plans = append(plans, *plan)
+    }
+    return plans
+}
+
+// Preview возвращает очередь миграций и контейнеры, для которых при последнем
+// планировании не нашлось цели из-за ограничений, с причинами отказа
+func (p *Planner) Preview() PlanPreview {
+    p.mu.RLock()
+    defer p.mu.RUnlock()
+
+    preview := PlanPreview{Plans: make([]MigrationPlan, 0, len(p.activePlans))}
+    for _, plan := range p.activePlans {
+        preview.Plans = append(preview.Plans, *plan)
+    }
+    for _, withheld := range p.withheld {
+        preview.Withheld = append(preview.Withheld, withheld)
+    }
+    preview.Reservations = p.reservationUtilization()
+    sort.Slice(preview.Plans, func(i, j int) bool { return preview.Plans[i].ContainerID < preview.Plans[j].ContainerID })
+    sort.Slice(preview.Withheld, func(i, j int) bool { return preview.Withheld[i].ContainerID < preview.Withheld[j].ContainerID })
+    return preview
+}
+
+// Jobs возвращает периодические задачи планировщика миграций. Очередь
+// разбирается чаще, чем строятся планы: окна ограничений сдвигаются со
+// временем, а завершение миграции освобождает слоты и будит разбор сразу.
+func (p *Planner) Jobs() []scheduler.Job {
+    return []scheduler.Job{
+        {
+            Name:     "migration.plan",
+            Interval: p.config.PlanningInterval,
+            Run: func(ctx context.Context) error {
+                if err := p.planMigrations(ctx); err != nil {
+                    return err
+                }
+                p.executeMigrations(ctx)
+                return nil
+            },
+        },
+        {
+            Name:     "migration.dispatch",
+            Interval: p.config.DispatchInterval,
+            Wake:     p.finished,
+            Run: func(ctx context.Context) error {
+                p.executeMigrations(ctx)
+                return nil
+            },
+        },
     }
 }
 
 func (p *Planner) planMigrations(ctx context.Context) error {
-    // Получаем все серверы
+    // Получаем все серверы. Пока провайдер отклоняет учетные данные, этот
+    // вызов - проба: успех возвращает провайдер в рабочее состояние.
     servers, err := p.provider.GetInstances(ctx)
     if err != nil {
         return err
     }
+    if health, paused := cloud.Paused(p.provider); paused {
+        log.Printf("Планирование миграций пропущено: провайдер %s в состоянии %s", health.Provider, health.State)
+        return nil
+    }
 
-    // Сортируем серверы по энергоэффективности
-    sort.Slice(servers, func(i, j int) bool {
+    for _, server := range servers {
+        p.analyzer.RegisterInstance(server.ID, server.InstanceType)
+    }
+
+    p.mu.RLock()
+    covered := coverage(p.reservations, servers, time.Now())
+    p.mu.RUnlock()
+
+    // Сначала освобождаются хосты по требованию: освобождение
+    // зарезервированного хоста не экономит деньги. Внутри групп серверы
+    // сортируются по энергоэффективности.
+    sort.SliceStable(servers, func(i, j int) bool {
+        _, reservedI := covered[servers[i].ID]
+        _, reservedJ := covered[servers[j].ID]
+        if reservedI != reservedJ {
+            return !reservedI
+        }
         scoreI := p.getServerEcoScore(servers[i].ID)
         scoreJ := p.getServerEcoScore(servers[j].ID)
         return scoreI > scoreJ
     })
 
+    withheld := make(map[string]WithheldMigration)
+    vacating := make(map[string]bool)
+    explanations := make(map[string]PlanExplanation)
+
     // Анализируем каждый сервер с низкой энергоэффективностью
     for _, sourceServer := range servers {
-        if p.getServerEcoScore(sourceServer.ID) > 70 {
+        if p.getServerEcoScore(sourceServer.ID) > sourceEcoScoreMax {
             continue // Сервер достаточно эффективен
         }
+        if p.exempted(sourceServer.ID) || p.observeOnly(sourceServer) {
+            continue
+        }
 
         // Получаем контейнеры на сервере
         containers, err := p.getServerContainers(ctx, sourceServer.ID)
@@ -93,107 +364,366 @@ func (p *Planner) planMigrations(ctx context.Context) error {
         }
 
         // Для каждого контейнера ищем лучший целевой сервер
+        planned := 0
         for _, container := range containers {
-            if _, exists := p.activePlans[container.ID]; exists {
+            p.mu.RLock()
+            _, exists := p.activePlans[container.ID]
+            previous, explained := p.explanations[container.ID]
+            p.mu.RUnlock()
+            if exists {
+                planned++
+                if explained {
+                    explanations[container.ID] = previous
+                }
                 continue // Для этого контейнера уже есть план миграции
             }
 
-            bestPlan := p.findBestMigrationPlan(ctx, container, sourceServer, servers)
+            bestPlan, rejected, explanation := p.findBestMigrationPlan(ctx, container, sourceServer, servers, covered)
+            explanations[container.ID] = explanation
             if bestPlan != nil {
+                planned++
+                bestPlan.QueuedAt = time.Now()
                 p.mu.Lock()
                 p.activePlans[container.ID] = bestPlan
                 p.mu.Unlock()
+            } else if len(rejected) > 0 {
+                withheld[container.ID] = WithheldMigration{
+                    ContainerID:    container.ID,
+                    SourceServerID: sourceServer.ID,
+                    Rejected:       rejected,
+                    CheckedAt:      time.Now(),
+                }
             }
         }
+        if len(containers) > 0 && planned == len(containers) {
+            vacating[sourceServer.ID] = true
+        }
     }
 
+    p.mu.Lock()
+    p.withheld = withheld
+    p.explanations = explanations
+    p.covered = covered
+    p.vacating = vacating
+    p.mu.Unlock()
     return nil
 }
 
+// findBestMigrationPlan выбирает цель с наибольшей оценкой экономии и
+// объясняет выбор: каждая рассмотренная цель попадает в объяснение с
+// оценками и причиной отказа
 func (p *Planner) findBestMigrationPlan(
     ctx context.Context,
     container models.Container,
     sourceServer models.Server,
     targetServers []models.Server,
-) *MigrationPlan {
+    covered map[string]string,
+) (*MigrationPlan, []TargetRejection, PlanExplanation) {
     var bestPlan *MigrationPlan
-    var maxPowerSaving float64
+    var bestScore float64
+    var rejected []TargetRejection
+
+    className, class, maxDowntime := p.downtimeClass(container)
+    minSaving := p.minPowerSaving()
+    weights := p.objectiveWeights(container)
+    now := time.Now()
+    explanation := PlanExplanation{
+        ContainerID:    container.ID,
+        SourceServerID: sourceServer.ID,
+        Inputs: PlanInputs{
+            SourceEcoScore:    p.getServerEcoScore(sourceServer.ID),
+            SourceRegion:      sourceServer.Region,
+            SourceReservation: covered[sourceServer.ID],
+            ContainerPower:    p.containerPower(container),
+        },
+        Thresholds: PlanThresholds{
+            SourceEcoScoreMax:   sourceEcoScoreMax,
+            MinPowerSaving:      minSaving,
+            MaxDowntime:         maxDowntime,
+            DowntimeClass:       className,
+            MaxInletTemperature: p.config.Thermal.MaxInletTemperature,
+            ThermalHeadroom:     p.config.Thermal.Headroom,
+            PreferGreenRegions:  p.config.Hints.PreferGreenRegions,
+        },
+        Objectives:  weights,
+        Candidates:  []CandidateEvaluation{},
+        ExplainedAt: now,
+    }
+    if class.Pinned {
+        explanation.Outcome = OutcomePinned
+        explanation.Summary = "downtime class " + className + " does not allow migrations"
+        return nil, nil, explanation
+    }
 
     for _, targetServer := range targetServers {
         if targetServer.ID == sourceServer.ID {
             continue
         }
+        candidate := CandidateEvaluation{ServerID: targetServer.ID, Region: targetServer.Region, Reserved: covered[targetServer.ID]}
+        if p.exempted(targetServer.ID) {
+            candidate.Reason = ReasonExempt
+            explanation.Candidates = append(explanation.Candidates, candidate)
+            continue
+        }
+        if p.observeOnly(targetServer) {
+            candidate.Reason = ReasonObserveOnly
+            explanation.Candidates = append(explanation.Candidates, candidate)
+            continue
+        }
 
         // Оцениваем потенциальную экономию энергии
         powerSaving := p.estimatePowerSaving(container, sourceServer, targetServer)
-        if powerSaving < p.config.MinPowerSaving {
+        candidate.PowerSaving = powerSaving
+        if powerSaving < minSaving {
+            candidate.Reason = ReasonSavingsBelowMinimum
+            candidate.Detail = fmt.Sprintf("%.1f W is below the %.1f W minimum", powerSaving, minSaving)
+            explanation.Candidates = append(explanation.Candidates, candidate)
             continue
         }
 
         // Оцениваем время простоя при миграции
         downtime := p.estimateDowntime(container, sourceServer, targetServer)
-        if downtime > p.config.MaxDowntime {
+        candidate.DowntimeEstimate = downtime
+        if downtime > maxDowntime {
+            candidate.Reason = ReasonDowntimeExceeded
+            candidate.Detail = fmt.Sprintf("%s exceeds the %s limit of class %s", downtime, maxDowntime, className)
+            explanation.Candidates = append(explanation.Candidates, candidate)
+            continue
+        }
+
+        // Цель у предела охлаждения не принимает дополнительную нагрузку
+        if rejection := p.thermalRejection(targetServer, now); rejection != nil {
+            rejected = append(rejected, *rejection)
+            candidate.Reason, candidate.Detail = ReasonThermal, rejection.Reason
+            explanation.Candidates = append(explanation.Candidates, candidate)
             continue
         }
 
-        // Если это лучший вариант - сохраняем
-        if powerSaving > maxPowerSaving {
-            maxPowerSaving = powerSaving
+        // Цель оценивается по весам целей окружения. Подсказки размещения и
+        // оплаченные резервы влияют только на выбор цели, но не на оценку экономии
+        scores := p.objectiveScores(container, sourceServer, targetServer, powerSaving, minSaving, downtime, maxDowntime, covered)
+        score := weights.Score(scores) * p.config.Hints.weight(targetServer.Region)
+        if _, reserved := covered[targetServer.ID]; reserved {
+            score *= reservedTargetBonus
+        }
+        candidate.Objectives, candidate.Score = scores, score
+        if score <= 0 {
+            candidate.Reason = ReasonObjectiveScore
+            candidate.Detail = fmt.Sprintf("weighted score %.2f with %s weights", score, weights.Environment)
+            explanation.Candidates = append(explanation.Candidates, candidate)
+            continue
+        }
+        explanation.Candidates = append(explanation.Candidates, candidate)
+        if score > bestScore {
+            bestScore = score
             bestPlan = &MigrationPlan{
                 ContainerID:     container.ID,
                 SourceServerID:  sourceServer.ID,
                 TargetServerID:  targetServer.ID,
-                Priority:        p.calculatePriority(powerSaving, downtime),
+                Priority:        p.calculatePriority(powerSaving, downtime, maxDowntime, class, covered[sourceServer.ID] != ""),
                 PowerSaving:     powerSaving,
                 DowntimeEstimate: downtime,
+                DowntimeClass:   className,
+                Provider:        targetServer.Provider,
+                SourceRegion:    sourceServer.Region,
+                TargetRegion:    targetServer.Region,
+                SourceReservation: covered[sourceServer.ID],
+                TargetReservation: covered[targetServer.ID],
+                Objectives:        weights,
             }
         }
     }
 
-    return bestPlan
+    if bestPlan != nil {
+        bestPlan.Rejected = rejected
+    }
+    explanation.finish(bestPlan, rejected)
+    return bestPlan, rejected, explanation
 }
 
-func (p *Planner) executeMigrations(ctx context.Context) error {
+// executeMigrations запускает ожидающие планы в порядке приоритета, пока позволяют
+// ограничения. Не дожидается завершения: остальные планы остаются в очереди.
+func (p *Planner) executeMigrations(ctx context.Context) {
     p.mu.Lock()
     defer p.mu.Unlock()
 
+    if health, paused := cloud.Paused(p.provider); paused {
+        p.holdForProvider(health)
+        return
+    }
+
     // Сортируем планы по приоритету
     var plans []*MigrationPlan
     for _, plan := range p.activePlans {
-        plans = append(plans, plan)
+        if !plan.Running {
+            plans = append(plans, plan)
+        }
+    }
+    sortByPriority(plans)
+
+    now := time.Now()
+    for _, plan := range plans {
+        if now.Before(plan.NotBefore) {
+            plan.BlockedReason = "waiting to retry after provider error"
+            continue
+        }
+
+        ok, reason := p.limiter.acquire(*plan, now)
+        if !ok {
+            plan.BlockedReason = reason
+            continue
+        }
+
+        plan.Running = true
+        plan.BlockedReason = ""
+        go p.runMigration(ctx, *plan)
     }
-    sort.Slice(plans, func(i, j int) bool {
-        return plans[i].Priority > plans[j].Priority
+}
+
+func (p *Planner) runMigration(ctx context.Context, plan MigrationPlan) {
+    // Паника провайдера не должна останавливать сервер: она становится
+    // ошибкой миграции, а слот ограничителя освобождается
+    err := supervisor.Call(func() error {
+        return p.provider.MigrateContainer(
+            ctx,
+            plan.ContainerID,
+            plan.SourceServerID,
+            plan.TargetServerID,
+        )
     })
+    p.limiter.release(plan)
+
+    var panicErr *supervisor.PanicError
+    if errors.As(err, &panicErr) {
+        p.mu.RLock()
+        handler := p.panics
+        p.mu.RUnlock()
+        if handler != nil {
+            handler(err)
+        } else {
+            log.Printf("Паника при миграции контейнера %s: %v\n%s", plan.ContainerID, panicErr.Value, panicErr.Stack)
+        }
+    }
+
+    p.mu.Lock()
+    active, ok := p.activePlans[plan.ContainerID]
+    switch {
+    case err == nil || !ok:
+        delete(p.activePlans, plan.ContainerID)
+        if err == nil {
+            p.recordMigration(plan, time.Now())
+        }
+    case errors.Is(err, cloud.ErrNotFound), errors.Is(err, cloud.ErrUnsupported), errors.Is(err, cloud.ErrCapacity):
+        // Контейнер или цель исчезли либо на цели нет места: план неактуален,
+        // следующее планирование выберет другую цель
+        delete(p.activePlans, plan.ContainerID)
+    case errors.Is(err, cloud.ErrThrottled):
+        active.Running = false
+        active.LastError = err.Error()
+        active.NotBefore = time.Now().Add(cloud.RetryAfter(err, throttleBackoff))
+    default:
+        // План остается в очереди и будет повторен
+        active.Running = false
+        active.LastError = err.Error()
+        active.NotBefore = time.Now().Add(failureBackoff)
+    }
+    p.mu.Unlock()
 
-    // Выполняем миграции с учетом ограничения на количество одновременных операций
-    var wg sync.WaitGroup
-    sem := make(chan struct{}, p.config.ConcurrentMigrations)
+    if errors.Is(err, cloud.ErrUnauthorized) || errors.Is(err, cloud.ErrCapacity) {
+        p.notifyProviderError(ctx, err)
+    }
 
-    for _, plan := range plans {
-        wg.Add(1)
-        sem <- struct{}{} // Захватываем слот для миграции
-
-        go func(plan *MigrationPlan) {
-            defer wg.Done()
-            defer func() { <-sem }() // Освобождаем слот
-
-            err := p.provider.MigrateContainer(
-                ctx,
-                plan.ContainerID,
-                plan.SourceServerID,
-                plan.TargetServerID,
-            )
-            if err == nil {
-                p.mu.Lock()
-                delete(p.activePlans, plan.ContainerID)
-                p.mu.Unlock()
+    select {
+    case p.finished <- struct{}{}:
+    default:
+    }
+}
+
+// holdForProvider не запускает миграции, пока провайдер отклоняет учетные
+// данные. В первой фазе планы ждут в очереди; во второй ожидающие планы
+// сбрасываются: они построены по устаревшему списку инстансов, и после
+// восстановления планирование построит их заново. Вызывается под блокировкой.
+func (p *Planner) holdForProvider(health cloud.Health) {
+    for containerID, plan := range p.activePlans {
+        if plan.Running {
+            continue
+        }
+        if health.State == cloud.HealthSuspended {
+            delete(p.activePlans, containerID)
+            continue
+        }
+        plan.BlockedReason = "provider " + health.Provider + " is degraded: credentials rejected"
+    }
+}
+
+// notifyProviderError оповещает о проблемах, которые не пройдут сами:
+// отозванные учетные данные или исчерпанные квоты
+func (p *Planner) notifyProviderError(ctx context.Context, err error) {
+    p.mu.RLock()
+    alerts := p.alerts
+    p.mu.RUnlock()
+    if alerts == nil {
+        return
+    }
+
+    provider := cloud.ProviderOf(err)
+    severity := alerting.SeverityWarning
+    if errors.Is(err, cloud.ErrUnauthorized) {
+        severity = alerting.SeverityCritical
+    }
+
+    alerts.Notify(ctx, alerting.Alert{
+        Key:      "migration/provider/" + provider + "/" + cloud.Kind(err).Error(),
+        Source:   "migration",
+        Severity: severity,
+        Title:    "Container migration rejected by cloud provider",
+        Message:  err.Error(),
+        Labels:   map[string]string{"provider": provider},
+    })
+}
+
+// QueueStatus возвращает глубину очереди миграций и причины ожидания
+func (p *Planner) QueueStatus() QueueStatus {
+    p.mu.RLock()
+    defer p.mu.RUnlock()
+
+    status := QueueStatus{
+        ByProvider: make(map[string]QueueDepth),
+        ByRegion:   make(map[string]QueueDepth),
+    }
+    for _, plan := range p.activePlans {
+        add := func(depth QueueDepth) QueueDepth {
+            if plan.Running {
+                depth.Running++
+            } else {
+                depth.Queued++
             }
-        }(plan)
+            return depth
+        }
+
+        status.ByProvider[plan.Provider] = add(status.ByProvider[plan.Provider])
+        for _, region := range plan.regions() {
+            status.ByRegion[region] = add(status.ByRegion[region])
+        }
+        if plan.Running {
+            status.Running++
+            continue
+        }
+
+        status.Queued++
+        if plan.BlockedReason != "" {
+            status.Blocked = append(status.Blocked, BlockedMigration{
+                ContainerID: plan.ContainerID,
+                Reason:      plan.BlockedReason,
+                QueuedAt:    plan.QueuedAt,
+            })
+        }
     }
 
-    wg.Wait()
-    return nil
+    sort.Slice(status.Blocked, func(i, j int) bool {
+        return status.Blocked[i].QueuedAt.Before(status.Blocked[j].QueuedAt)
+    })
+    return status
 }
 
 func (p *Planner) getServerEcoScore(serverID string) float64 {
@@ -201,18 +731,102 @@ func (p *Planner) getServerEcoScore(serverID string) float64 {
     if err != nil {
         return 0
     }
-    return p.analyzer.CalculateEcoScore(metrics)
+    // Нормализованный рейтинг сравнивает эффективность серверов разного размера
+    return p.analyzer.CalculateEcoScores(serverID, metrics).Normalized
 }
 
 func (p *Planner) estimatePowerSaving(
     container models.Container,
     sourceServer, targetServer models.Server,
 ) float64 {
-    sourcePower := container.PowerUsage
+    sourcePower := p.containerPower(container)
     // Оценка энергопотребления на целевом сервере
     targetPower := sourcePower * (p.getServerEcoScore(targetServer.ID) / 
                                  p.getServerEcoScore(sourceServer.ID))
-    return sourcePower - targetPower
+    saving := sourcePower - targetPower
+
+    // После переноса в другой регион обмен контейнера с соседями, оставшимися
+    // в исходном регионе, идет по межрегиональным каналам
+    if sourceServer.Region != targetServer.Region {
+        saving -= p.containerNetworkPower(container, sourceServer.ID)
+    }
+    return saving
+}
+
+// containerPower возвращает среднее потребление контейнера по его метрикам,
+// а без них - последнее известное значение из описания контейнера
+func (p *Planner) containerPower(container models.Container) float64 {
+    metrics, err := p.collector.GetContainerMetrics(container.ID)
+    if err != nil || len(metrics) == 0 {
+        return container.PowerUsage
+    }
+
+    var total float64
+    for _, m := range metrics {
+        total += m.PowerUsage
+    }
+    return total / float64(len(metrics))
+}
+
+// containerNetworkPower оценивает сетевые энергозатраты контейнера по его
+// сетевым метрикам, а без них - как долю сетевых затрат сервера,
+// пропорциональную доле контейнера в потреблении
+func (p *Planner) containerNetworkPower(container models.Container, serverID string) float64 {
+    if own, err := p.collector.GetContainerMetrics(container.ID); err == nil {
+        if watts := p.analyzer.NetworkPower(own); watts > 0 {
+            return watts
+        }
+    }
+
+    metrics, err := p.collector.GetMetrics(serverID)
+    if err != nil || len(metrics) == 0 {
+        return 0
+    }
+
+    var serverPower float64
+    for _, m := range metrics {
+        serverPower += m.PowerUsage
+    }
+    serverPower /= float64(len(metrics))
+    if serverPower <= 0 {
+        return 0
+    }
+
+    share := math.Min(1, p.containerPower(container)/serverPower)
+    return p.analyzer.NetworkPower(metrics) * share
+}
+
+// objectiveScores оценивает перенос по целям в долях минимальной экономии:
+// мощность - экономия; углерод - сокращение потребления энергии не из
+// безуглеродных источников с учетом регионов; деньги - экономия, если
+// источник не зарезервирован (резерв оплачивается и после переноса);
+// простой - доля допустимого простоя класса.
+func (p *Planner) objectiveScores(
+    container models.Container,
+    sourceServer, targetServer models.Server,
+    powerSaving, minSaving float64,
+    downtime, maxDowntime time.Duration,
+    covered map[string]string,
+) objectives.Scores {
+    if minSaving <= 0 {
+        minSaving = 1
+    }
+    sourcePower := p.containerPower(container)
+    targetPower := sourcePower - powerSaving
+    carbonSaving := sourcePower*(1-objectives.CarbonFreeShare(sourceServer.Region)) -
+        targetPower*(1-objectives.CarbonFreeShare(targetServer.Region))
+
+    scores := objectives.Scores{
+        Power:  powerSaving / minSaving,
+        Carbon: carbonSaving / minSaving,
+    }
+    if covered[sourceServer.ID] == "" {
+        scores.Cost = powerSaving / minSaving
+    }
+    if maxDowntime > 0 {
+        scores.Disruption = float64(downtime) / float64(maxDowntime)
+    }
+    return scores
 }
 
 func (p *Planner) estimateDowntime(
@@ -230,14 +844,18 @@ func (p *Planner) estimateDowntime(
     return baseTime
 }
 
-func (p *Planner) calculatePriority(powerSaving float64, downtime time.Duration) int {
+func (p *Planner) calculatePriority(powerSaving float64, downtime, maxDowntime time.Duration, class ToleranceClass, sourceReserved bool) int {
     // Приоритет зависит от экономии энергии и времени простоя
-    priority := int((powerSaving / p.config.MinPowerSaving) * 10)
+    priority := int((powerSaving / p.minPowerSaving()) * 10)
     
-    // Уменьшаем приоритет, если время простоя большое
-    if downtime > p.config.MaxDowntime/2 {
+    // Уменьшаем приоритет, если время простоя большое для класса сервиса
+    if downtime > maxDowntime/2 {
         priority -= 2
     }
+    priority += class.PriorityModifier
+    if sourceReserved {
+        priority -= reservedSourcePenalty
+    }
 
     // Ограничиваем приоритет диапазоном 1-10
     if priority < 1 {
@@ -250,8 +868,7 @@ func (p *Planner) calculatePriority(powerSaving float64, downtime time.Duration)
     return priority
 }
 
+// getServerContainers возвращает контейнеры сервера по их последним точкам
 func (p *Planner) getServerContainers(ctx context.Context, serverID string) ([]models.Container, error) {
-    // Здесь должна быть реализация получения списка контейнеров с сервера
-    // Можно использовать Kubernetes API или другие механизмы
-    return []models.Container{}, nil
+    return p.collector.Containers(serverID), nil
 } 
\ No newline at end of file
@@ -0,0 +1,157 @@
+package migration
+
+import (
+    "encoding/json"
+    "fmt"
+    "os"
+    "sort"
+    "time"
+
+    "github.com/YumeNoTenshi/platypus/internal/models"
+)
+
+// reservedTargetBonus - во сколько раз цель на зарезервированном хосте
+// привлекательнее: его мощности уже оплачены
+const reservedTargetBonus = 1.1
+
+// reservedSourcePenalty - на сколько снижается приоритет миграции с
+// зарезервированного хоста: освобождение не экономит деньги, резерв все
+// равно оплачивается, поэтому сначала освобождаются хосты по требованию
+const reservedSourcePenalty = 3
+
+// Виды резервирования мощностей
+const (
+    ReservationReservedInstance = "reserved_instance" // AWS Reserved Instances, Azure Reservations
+    ReservationCommittedUse     = "committed_use"     // GCP committed use discounts
+    ReservationSavingsPlan      = "savings_plan"
+)
+
+// Reservation - оплаченные заранее Count экземпляров типа InstanceType.
+// Скидка применяется к любым подходящим работающим экземплярам, поэтому
+// покрытыми считаются первые Count из них по идентификатору. Обязательства
+// на vCPU (committed use) задаются в пересчете на экземпляры.
+type Reservation struct {
+    ID           string    `json:"id"`
+    Kind         string    `json:"kind"`
+    Provider     string    `json:"provider,omitempty"` // Пусто - любой провайдер
+    Region       string    `json:"region,omitempty"`   // Пусто - любой регион (региональные RI)
+    InstanceType string    `json:"instance_type"`
+    Count        int       `json:"count"`
+    HourlyCost   float64   `json:"hourly_cost,omitempty"` // Стоимость часа одного экземпляра; сгорает и без использования
+    ExpiresAt    time.Time `json:"expires_at,omitempty"`  // Нулевое - бессрочно
+}
+
+func (r Reservation) validate() error {
+    if r.ID == "" || r.InstanceType == "" {
+        return fmt.Errorf("reservation requires id and instance_type")
+    }
+    if r.Count <= 0 {
+        return fmt.Errorf("reservation %s: count must be positive", r.ID)
+    }
+    switch r.Kind {
+    case "", ReservationReservedInstance, ReservationCommittedUse, ReservationSavingsPlan:
+    default:
+        return fmt.Errorf("reservation %s: unknown kind %q", r.ID, r.Kind)
+    }
+    return nil
+}
+
+func (r Reservation) matches(server models.Server, now time.Time) bool {
+    if !r.ExpiresAt.IsZero() && now.After(r.ExpiresAt) {
+        return false
+    }
+    return server.InstanceType == r.InstanceType &&
+        (r.Provider == "" || r.Provider == server.Provider) &&
+        (r.Region == "" || r.Region == server.Region)
+}
+
+// LoadReservations читает резервирования из JSON-файла (массив Reservation)
+func LoadReservations(path string) ([]Reservation, error) {
+    data, err := os.ReadFile(path)
+    if err != nil {
+        return nil, err
+    }
+    var reservations []Reservation
+    if err := json.Unmarshal(data, &reservations); err != nil {
+        return nil, fmt.Errorf("invalid reservations file %s: %w", path, err)
+    }
+    seen := make(map[string]bool, len(reservations))
+    for _, reservation := range reservations {
+        if err := reservation.validate(); err != nil {
+            return nil, err
+        }
+        if seen[reservation.ID] {
+            return nil, fmt.Errorf("duplicate reservation %s", reservation.ID)
+        }
+        seen[reservation.ID] = true
+    }
+    return reservations, nil
+}
+
+// ReservationUtilization - использование резервирования и его прогноз после
+// выполнения очереди миграций
+type ReservationUtilization struct {
+    Reservation
+    Covered     int      `json:"covered"`     // Работающие экземпляры под резервированием
+    Utilization float64  `json:"utilization"` // Процент
+    // Vacating - покрытые хосты, с которых очередь уносит все контейнеры.
+    // После миграции они простаивают, а резерв продолжает оплачиваться.
+    Vacating             []string `json:"vacating,omitempty"`
+    ProjectedUtilization float64  `json:"projected_utilization"`
+    IdleHourlyCost       float64  `json:"idle_hourly_cost,omitempty"` // Оплачиваемые, но не используемые экземпляры после очереди
+}
+
+// coverage сопоставляет серверы резервированиям: серверID -> ID резервирования
+func coverage(reservations []Reservation, servers []models.Server, now time.Time) map[string]string {
+    sorted := append([]models.Server(nil), servers...)
+    sort.Slice(sorted, func(i, j int) bool { return sorted[i].ID < sorted[j].ID })
+
+    covered := make(map[string]string)
+    for _, reservation := range reservations {
+        remaining := reservation.Count
+        for _, server := range sorted {
+            if remaining == 0 {
+                break
+            }
+            if _, taken := covered[server.ID]; taken || !reservation.matches(server, now) {
+                continue
+            }
+            covered[server.ID] = reservation.ID
+            remaining--
+        }
+    }
+    return covered
+}
+
+// SetReservations задает резервирования мощностей; они учитываются со
+// следующего планирования
+func (p *Planner) SetReservations(reservations []Reservation) {
+    p.mu.Lock()
+    defer p.mu.Unlock()
+    p.reservations = append([]Reservation(nil), reservations...)
+}
+
+// reservationUtilization считает использование резервирований; вызывается под p.mu
+func (p *Planner) reservationUtilization() []ReservationUtilization {
+    result := make([]ReservationUtilization, 0, len(p.reservations))
+    for _, reservation := range p.reservations {
+        usage := ReservationUtilization{Reservation: reservation}
+        for serverID, id := range p.covered {
+            if id != reservation.ID {
+                continue
+            }
+            usage.Covered++
+            if p.vacating[serverID] {
+                usage.Vacating = append(usage.Vacating, serverID)
+            }
+        }
+        sort.Strings(usage.Vacating)
+
+        used := usage.Covered - len(usage.Vacating)
+        usage.Utilization = 100 * float64(usage.Covered) / float64(reservation.Count)
+        usage.ProjectedUtilization = 100 * float64(used) / float64(reservation.Count)
+        usage.IdleHourlyCost = float64(reservation.Count-used) * reservation.HourlyCost
+        result = append(result, usage)
+    }
+    return result
+}
@@ -0,0 +1,136 @@
+package migration
+
+import (
+    "context"
+    "fmt"
+    "sort"
+    "time"
+
+    "github.com/YumeNoTenshi/platypus/internal/metrics"
+    "github.com/YumeNoTenshi/platypus/internal/models"
+    "github.com/YumeNoTenshi/platypus/pkg/carbon"
+    "github.com/YumeNoTenshi/platypus/pkg/catalog"
+    "github.com/YumeNoTenshi/platypus/pkg/cloud"
+)
+
+// regionMoveDowntime - оценка простоя при переносе сервера в другой регион
+const regionMoveDowntime = 15 * time.Minute
+
+// RegionMoveEstimate - результат моделирования переноса сервера в другой регион
+type RegionMoveEstimate struct {
+    ServerID           string        `json:"server_id"`
+    SourceRegion       string        `json:"source_region"`
+    TargetRegion       string        `json:"target_region"`
+    PowerWatts         float64       `json:"power_watts"`
+    SourceGramsPerKWh  float64       `json:"source_grams_per_kwh"`
+    TargetGramsPerKWh  float64       `json:"target_grams_per_kwh"`
+    MonthlyCO2SavingKg float64       `json:"monthly_co2_saving_kg"`
+    TargetGreen        bool          `json:"target_green"`
+    CarbonFreePercent  float64       `json:"carbon_free_percent,omitempty"`
+    DowntimeEstimate   time.Duration `json:"downtime_estimate"`
+}
+
+// RegionSimulator оценивает эффект переноса серверов между регионами
+// без выполнения миграции
+type RegionSimulator struct {
+    collector *metrics.Collector
+    provider  cloud.CloudProvider
+    dataset   *carbon.Dataset
+}
+
+func NewRegionSimulator(collector *metrics.Collector, provider cloud.CloudProvider, dataset *carbon.Dataset) *RegionSimulator {
+    return &RegionSimulator{
+        collector: collector,
+        provider:  provider,
+        dataset:   dataset,
+    }
+}
+
+// Simulate оценивает перенос сервера в targetRegion. Если targetRegion пуст,
+// возвращает все регионы провайдера, упорядоченные по экономии с учетом подсказок.
+func (s *RegionSimulator) Simulate(ctx context.Context, serverID, targetRegion string, hints PlacementHints) ([]RegionMoveEstimate, error) {
+    server, err := s.findServer(ctx, serverID)
+    if err != nil {
+        return nil, err
+    }
+
+    sourceRegion := catalog.RegionOf(server.Region)
+    sourceIntensity, ok := s.dataset.Intensity(sourceRegion)
+    if !ok {
+        return nil, fmt.Errorf("no carbon intensity data for region %s", sourceRegion)
+    }
+
+    power := s.averagePower(serverID)
+
+    var targets []string
+    if targetRegion != "" {
+        targets = []string{catalog.RegionOf(targetRegion)}
+    } else {
+        for _, region := range s.dataset.Regions {
+            if region.Provider == server.Provider && region.Region != sourceRegion {
+                targets = append(targets, region.Region)
+            }
+        }
+    }
+
+    estimates := make([]RegionMoveEstimate, 0, len(targets))
+    for _, target := range targets {
+        targetIntensity, ok := s.dataset.Intensity(target)
+        if !ok {
+            return nil, fmt.Errorf("no carbon intensity data for region %s", target)
+        }
+
+        profile, _ := catalog.LookupRegion(target)
+        estimates = append(estimates, RegionMoveEstimate{
+            ServerID:           serverID,
+            SourceRegion:       sourceRegion,
+            TargetRegion:       target,
+            PowerWatts:         power,
+            SourceGramsPerKWh:  sourceIntensity,
+            TargetGramsPerKWh:  targetIntensity,
+            MonthlyCO2SavingKg: carbon.FootprintKg(power, 30*24*time.Hour, sourceIntensity-targetIntensity),
+            TargetGreen:        profile.Green,
+            CarbonFreePercent:  profile.CarbonFreePercent,
+            DowntimeEstimate:   regionMoveDowntime,
+        })
+    }
+
+    // При PreferGreenRegions низкоуглеродные регионы идут первыми,
+    // внутри групп - по убыванию экономии CO2
+    sort.SliceStable(estimates, func(i, j int) bool {
+        a, b := estimates[i], estimates[j]
+        if hints.PreferGreenRegions && a.TargetGreen != b.TargetGreen {
+            return a.TargetGreen
+        }
+        return a.MonthlyCO2SavingKg > b.MonthlyCO2SavingKg
+    })
+
+    return estimates, nil
+}
+
+func (s *RegionSimulator) findServer(ctx context.Context, serverID string) (models.Server, error) {
+    servers, err := s.provider.GetInstances(ctx)
+    if err != nil {
+        return models.Server{}, err
+    }
+
+    for _, server := range servers {
+        if server.ID == serverID {
+            return server, nil
+        }
+    }
+    return models.Server{}, fmt.Errorf("server not found: %s", serverID)
+}
+
+func (s *RegionSimulator) averagePower(serverID string) float64 {
+    data, err := s.collector.GetFilteredMetrics(serverID)
+    if err != nil || len(data) == 0 {
+        return 0
+    }
+
+    var total float64
+    for _, point := range data {
+        total += point.PowerUsage
+    }
+    return total / float64(len(data))
+}
@@ -0,0 +1,63 @@
+package migration
+
+import (
+    "fmt"
+    "time"
+
+    "github.com/YumeNoTenshi/platypus/internal/models"
+)
+
+// defaultThermalWindow - за какой период берется телеметрия цели, если окно не задано
+const defaultThermalWindow = 15 * time.Minute
+
+// ThermalPolicy - ограничение выбора цели по температуре воздуха на входе.
+// Перенос добавляет цели нагрузку, а значит и тепло: цель, которая уже
+// работает у предела охлаждения, отклоняется.
+type ThermalPolicy struct {
+    MaxInletTemperature float64       // Предел, °C; 0 - ограничение выключено
+    Headroom            float64       // Запас до предела, °C
+    Window              time.Duration // Период телеметрии; берется самое горячее показание
+}
+
+// TargetRejection - цель, отклоненная ограничением размещения, и причина
+type TargetRejection struct {
+    ServerID         string  `json:"server_id"`
+    Reason           string  `json:"reason"`
+    InletTemperature float64 `json:"inlet_temp_c,omitempty"`
+}
+
+// thermalRejection возвращает отказ, если температура на входе цели близка
+// к пределу. Цели без тепловой телеметрии не ограничиваются.
+func (p *Planner) thermalRejection(target models.Server, now time.Time) *TargetRejection {
+    policy := p.config.Thermal
+    if policy.MaxInletTemperature <= 0 {
+        return nil
+    }
+    window := policy.Window
+    if window <= 0 {
+        window = defaultThermalWindow
+    }
+
+    data, err := p.collector.GetMetricsRange(target.ID, now.Add(-window), now)
+    if err != nil {
+        return nil
+    }
+
+    hottest, reported := 0.0, false
+    for _, m := range data {
+        if m.InletTemperature != 0 && (!reported || m.InletTemperature > hottest) {
+            hottest, reported = m.InletTemperature, true
+        }
+    }
+    limit := policy.MaxInletTemperature - policy.Headroom
+    if !reported || hottest < limit {
+        return nil
+    }
+
+    return &TargetRejection{
+        ServerID: target.ID,
+        Reason: fmt.Sprintf("inlet temperature %.1f°C is within %.1f°C of the %.1f°C cooling limit",
+            hottest, policy.Headroom, policy.MaxInletTemperature),
+        InletTemperature: hottest,
+    }
+}
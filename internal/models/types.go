@@ -2,11 +2,27 @@ package models
 
 type MetricData struct {
     ServerID      string    `json:"server_id"`
+    TenantID      string    `json:"tenant_id,omitempty"` // Арендатор (организация); пусто - арендатор по умолчанию
+    ContainerID   string    `json:"container_id,omitempty"` // Контейнер, к которому относится точка; пусто - весь сервер
     Timestamp     int64     `json:"timestamp"`
     PowerUsage    float64   `json:"power_usage"`    // Ватты
+    PowerModel    string    `json:"power_model,omitempty"` // Модель, которой оценена мощность; пусто - измеренное значение
     CarbonFootprint float64 `json:"carbon_footprint"` // кг CO2
     CPUUsage      float64   `json:"cpu_usage"`      // Процент
     MemoryUsage   float64   `json:"memory_usage"`   // Процент
+    GPUUsage      float64   `json:"gpu_usage,omitempty"`       // Процент, среднее по всем GPU сервера
+    GPUPowerUsage float64   `json:"gpu_power_usage,omitempty"` // Ватты, входит в PowerUsage
+    NetworkRxBytes float64  `json:"network_rx_bytes,omitempty"` // Байт принято за интервал точки
+    NetworkTxBytes float64  `json:"network_tx_bytes,omitempty"` // Байт отправлено за интервал точки
+    DiskReadBytesPerSec  float64 `json:"disk_read_bps,omitempty"`      // Чтение с дисков, байт/с
+    DiskWriteBytesPerSec float64 `json:"disk_write_bps,omitempty"`     // Запись на диски, байт/с
+    StorageUsedBytes     float64 `json:"storage_used_bytes,omitempty"` // Занято на подключенных томах
+    InletTemperature     float64 `json:"inlet_temp_c,omitempty"`       // Температура воздуха на входе сервера/стойки, °C
+    Interpolated  bool      `json:"interpolated,omitempty"` // Точка восстановлена при заполнении пропуска
+    EnergyCounter float64   `json:"energy_counter,omitempty"` // Накопительный счетчик энергии (единица объявляется источником)
+    Resolution    int64     `json:"resolution,omitempty"` // Секунд, усредненных в агрегированной точке; 0 - исходная точка
+    Samples       int       `json:"samples,omitempty"`    // Сколько исходных точек усреднено
+    Labels        map[string]string `json:"labels,omitempty"` // Произвольные метки точки: team, environment, namespace и т.п.
 }
 
 type Server struct {
@@ -15,12 +31,17 @@ type Server struct {
     Region        string    `json:"region"`
     InstanceType  string    `json:"instance_type"`
     EcoScore      float64   `json:"eco_score"` // 0-100
+    NormalizedEcoScore float64 `json:"normalized_eco_score"` // 0-100, с учетом размера инстанса
+    Tags          map[string]string `json:"tags,omitempty"` // Теги/метки инстанса в облаке
 }
 
 type Container struct {
     ID            string    `json:"id"`
     ServerID      string    `json:"server_id"`
     ServiceName   string    `json:"service_name"`
+    Image         string    `json:"image"`
+    Namespace     string    `json:"namespace,omitempty"` // Namespace Kubernetes
+    Labels        map[string]string `json:"labels,omitempty"` // Метки пода
     EcoTags       []string  `json:"eco_tags"`
     PowerUsage    float64   `json:"power_usage"`
 } 
\ No newline at end of file
@@ -0,0 +1,369 @@
+// Package mqtt принимает показания мощности, которые умные PDU и счетчики
+// публикуют в брокер MQTT. Топики сопоставляются серверам по правилам,
+// показания передаются сборщику как PowerUsage.
+package mqtt
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	paho "github.com/eclipse/paho.mqtt.golang"
+
+	"github.com/YumeNoTenshi/platypus/internal/errtrack"
+	"github.com/YumeNoTenshi/platypus/internal/metrics"
+	"github.com/YumeNoTenshi/platypus/internal/models"
+)
+
+const (
+	defaultSource     = "mqtt"
+	defaultStaleAfter = 2 * time.Minute
+	connectTimeout    = 10 * time.Second
+)
+
+// placeholder - ссылка {N} на значение N-го уровня + в ServerID
+var placeholder = regexp.MustCompile(`\{(\d+)\}`)
+
+// Mapping сопоставляет топики серверу. Topic - фильтр MQTT с + и #;
+// в ServerID {1}, {2}... заменяются значениями уровней, совпавших с +
+// по порядку: "pdu/+/outlet/+/power" и "{1}" дают сервер из второго уровня.
+type Mapping struct {
+	Topic    string `json:"topic"`
+	ServerID string `json:"server_id"`
+	// Field - поле JSON-объекта с мощностью; пусто - сообщение содержит только число
+	Field string `json:"field,omitempty"`
+	// Source - источник для единиц измерения (PUT /ingest/sources/{source}/units),
+	// например если PDU публикует киловатты; пусто - "mqtt"
+	Source string `json:"source,omitempty"`
+}
+
+type Config struct {
+	Broker   string // tcp://host:1883, ssl://host:8883
+	ClientID string
+	Username string
+	Password string
+	QoS      byte
+	Mappings []Mapping
+	// StaleAfter - сколько показание топика учитывается в сумме сервера.
+	// Сервер с несколькими блоками питания получает мощность по нескольким
+	// топикам; его потребление - сумма свежих показаний.
+	StaleAfter time.Duration
+	// Errors получает исход подключения к брокеру под именем "metrics.mqtt"
+	Errors errtrack.Recorder
+}
+
+// LoadMappings читает правила сопоставления из JSON-файла (массив Mapping)
+func LoadMappings(path string) ([]Mapping, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var mappings []Mapping
+	if err := json.Unmarshal(data, &mappings); err != nil {
+		return nil, fmt.Errorf("invalid mqtt mapping file %s: %w", path, err)
+	}
+	for _, mapping := range mappings {
+		if err := mapping.validate(); err != nil {
+			return nil, err
+		}
+	}
+	return mappings, nil
+}
+
+func (m Mapping) validate() error {
+	if m.Topic == "" || m.ServerID == "" {
+		return fmt.Errorf("mqtt mapping requires topic and server_id")
+	}
+	levels := strings.Split(m.Topic, "/")
+	wildcards := 0
+	for i, level := range levels {
+		switch {
+		case level == "+":
+			wildcards++
+		case level == "#" && i != len(levels)-1:
+			return fmt.Errorf("mqtt topic %s: # must be the last level", m.Topic)
+		case strings.ContainsAny(level, "+#") && len(level) > 1:
+			return fmt.Errorf("mqtt topic %s: wildcards must occupy a whole level", m.Topic)
+		}
+	}
+	for _, ref := range placeholder.FindAllStringSubmatch(m.ServerID, -1) {
+		if n, _ := strconv.Atoi(ref[1]); n < 1 || n > wildcards {
+			return fmt.Errorf("mqtt mapping %s: server_id refers to %s, topic has %d wildcards", m.Topic, ref[0], wildcards)
+		}
+	}
+	return nil
+}
+
+// match проверяет топик по фильтру и возвращает значения уровней, совпавших с +
+func match(filter, topic string) ([]string, bool) {
+	filterLevels := strings.Split(filter, "/")
+	topicLevels := strings.Split(topic, "/")
+
+	var captured []string
+	for i, level := range filterLevels {
+		if level == "#" {
+			return captured, true
+		}
+		if i >= len(topicLevels) {
+			return nil, false
+		}
+		switch level {
+		case "+":
+			captured = append(captured, topicLevels[i])
+		case topicLevels[i]:
+		default:
+			return nil, false
+		}
+	}
+	return captured, len(filterLevels) == len(topicLevels)
+}
+
+func (m Mapping) serverID(captured []string) string {
+	serverID := m.ServerID
+	for i, value := range captured {
+		serverID = strings.ReplaceAll(serverID, "{"+strconv.Itoa(i+1)+"}", value)
+	}
+	return serverID
+}
+
+// Stats - счетчики приема из MQTT для /status
+type Stats struct {
+	Broker        string    `json:"broker"`
+	Connected     bool      `json:"connected"`
+	Messages      uint64    `json:"messages"`
+	Points        uint64    `json:"points"`
+	Unmapped      uint64    `json:"unmapped"`  // Сообщения топиков без правила
+	Malformed     uint64    `json:"malformed"` // Сообщения, из которых не удалось прочитать мощность
+	Rejected      uint64    `json:"rejected"`  // Точки, отклоненные проверкой единиц
+	Dropped       uint64    `json:"dropped"`   // Точки, не принятые заполненным буфером сборщика
+	LastMessageAt time.Time `json:"last_message_at,omitempty"`
+	LastError     string    `json:"last_error,omitempty"`
+}
+
+type reading struct {
+	watts float64
+	at    time.Time
+}
+
+// Subscriber подписывается на топики правил и передает показания сборщику
+type Subscriber struct {
+	config    Config
+	collector *metrics.Collector
+	client    paho.Client
+
+	mu       sync.Mutex
+	readings map[string]map[string]reading // Сервер -> топик -> последнее показание
+	stats    Stats
+}
+
+func NewSubscriber(config Config, collector *metrics.Collector) (*Subscriber, error) {
+	if config.Broker == "" {
+		return nil, fmt.Errorf("mqtt broker is required")
+	}
+	if len(config.Mappings) == 0 {
+		return nil, fmt.Errorf("at least one mqtt topic mapping is required")
+	}
+	for _, mapping := range config.Mappings {
+		if err := mapping.validate(); err != nil {
+			return nil, err
+		}
+	}
+	if config.ClientID == "" {
+		config.ClientID = "platypus"
+	}
+	if config.StaleAfter <= 0 {
+		config.StaleAfter = defaultStaleAfter
+	}
+
+	s := &Subscriber{
+		config:    config,
+		collector: collector,
+		readings:  make(map[string]map[string]reading),
+		stats:     Stats{Broker: config.Broker},
+	}
+
+	options := paho.NewClientOptions().
+		AddBroker(config.Broker).
+		SetClientID(config.ClientID).
+		SetUsername(config.Username).
+		SetPassword(config.Password).
+		SetAutoReconnect(true).
+		SetConnectRetry(true).
+		// Подписки восстанавливаются при каждом подключении: брокер мог их забыть
+		SetOnConnectHandler(s.subscribe).
+		SetConnectionLostHandler(func(_ paho.Client, err error) {
+			s.update(func(stats *Stats) { stats.Connected = false })
+			s.fail(fmt.Errorf("connection to %s lost: %w", config.Broker, err))
+		})
+	s.client = paho.NewClient(options)
+	return s, nil
+}
+
+// Start подключается к брокеру и принимает сообщения до отмены контекста.
+// Брокер, недоступный при старте, переподключается в фоне.
+func (s *Subscriber) Start(ctx context.Context) error {
+	token := s.client.Connect()
+	if token.WaitTimeout(connectTimeout) && token.Error() != nil {
+		return token.Error()
+	}
+
+	<-ctx.Done()
+	s.client.Disconnect(250)
+	return ctx.Err()
+}
+
+func (s *Subscriber) subscribe(client paho.Client) {
+	filters := make(map[string]byte, len(s.config.Mappings))
+	for _, mapping := range s.config.Mappings {
+		filters[mapping.Topic] = s.config.QoS
+	}
+
+	token := client.SubscribeMultiple(filters, s.handle)
+	if token.Wait() && token.Error() != nil {
+		s.fail(fmt.Errorf("subscribe on %s: %w", s.config.Broker, token.Error()))
+		return
+	}
+	log.Printf("Подписка на %d топиков MQTT на %s", len(filters), s.config.Broker)
+	s.update(func(stats *Stats) { stats.Connected = true })
+	s.succeed()
+}
+
+func (s *Subscriber) handle(_ paho.Client, message paho.Message) {
+	s.ingest(message.Topic(), message.Payload(), time.Now())
+}
+
+// ingest сопоставляет топик серверу и передает сборщику сумму свежих
+// показаний всех топиков этого сервера
+func (s *Subscriber) ingest(topic string, payload []byte, now time.Time) {
+	mapping, captured, found := s.mapping(topic)
+	if !found {
+		s.update(func(stats *Stats) { stats.Messages++; stats.Unmapped++ })
+		return
+	}
+	serverID := mapping.serverID(captured)
+
+	watts, err := parsePower(payload, mapping.Field)
+	if err != nil {
+		log.Printf("Пропущено сообщение MQTT %s: %v", topic, err)
+		s.update(func(stats *Stats) { stats.Messages++; stats.Malformed++ })
+		return
+	}
+
+	s.mu.Lock()
+	topics, exists := s.readings[serverID]
+	if !exists {
+		topics = make(map[string]reading)
+		s.readings[serverID] = topics
+	}
+	topics[topic] = reading{watts: watts, at: now}
+
+	total := 0.0
+	for name, r := range topics {
+		if now.Sub(r.at) > s.config.StaleAfter {
+			delete(topics, name)
+			continue
+		}
+		total += r.watts
+	}
+	s.mu.Unlock()
+
+	source := mapping.Source
+	if source == "" {
+		source = defaultSource
+	}
+	err = s.collector.CollectMetricsFrom(source, serverID, models.MetricData{
+		ServerID:   serverID,
+		Timestamp:  now.Unix(),
+		PowerUsage: total,
+	})
+
+	// Клиент MQTT вызывает обработчик в своем потоке: при заполненном буфере
+	// точка теряется, следующее показание придет через период публикации PDU
+	s.update(func(stats *Stats) {
+		stats.Messages++
+		stats.LastMessageAt = now
+		switch {
+		case errors.Is(err, metrics.ErrBufferFull):
+			stats.Dropped++
+		case err != nil:
+			stats.Rejected++
+		default:
+			stats.Points++
+		}
+	})
+}
+
+func (s *Subscriber) mapping(topic string) (Mapping, []string, bool) {
+	for _, mapping := range s.config.Mappings {
+		if captured, ok := match(mapping.Topic, topic); ok {
+			return mapping, captured, true
+		}
+	}
+	return Mapping{}, nil, false
+}
+
+// parsePower читает мощность из сообщения: число ("245.3") или поле JSON-объекта
+func parsePower(payload []byte, field string) (float64, error) {
+	if field == "" {
+		value, err := strconv.ParseFloat(strings.TrimSpace(string(payload)), 64)
+		if err != nil {
+			return 0, fmt.Errorf("payload is not a number: %w", err)
+		}
+		return value, nil
+	}
+
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal(payload, &object); err != nil {
+		return 0, fmt.Errorf("payload is not a JSON object: %w", err)
+	}
+	raw, exists := object[field]
+	if !exists {
+		return 0, fmt.Errorf("field %s is missing", field)
+	}
+	// Некоторые PDU передают числа строками
+	var text string
+	if json.Unmarshal(raw, &text) == nil {
+		raw = json.RawMessage(text)
+	}
+	var value float64
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return 0, fmt.Errorf("field %s is not a number: %w", field, err)
+	}
+	return value, nil
+}
+
+// Stats возвращает счетчики приема
+func (s *Subscriber) Stats() Stats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.stats
+}
+
+func (s *Subscriber) update(fn func(stats *Stats)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fn(&s.stats)
+}
+
+func (s *Subscriber) fail(err error) {
+	s.update(func(stats *Stats) { stats.LastError = err.Error() })
+	if s.config.Errors != nil {
+		s.config.Errors.Record("metrics.mqtt", err)
+	} else {
+		log.Printf("Ошибка приема метрик из MQTT: %v", err)
+	}
+}
+
+func (s *Subscriber) succeed() {
+	if s.config.Errors != nil {
+		s.config.Errors.Record("metrics.mqtt", nil)
+	}
+}
@@ -0,0 +1,146 @@
+package objectives
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+
+	"github.com/YumeNoTenshi/platypus/pkg/catalog"
+)
+
+// DefaultEnvironment - имя набора весов для окружений без своего набора
+const DefaultEnvironment = "default"
+
+// Weights - веса целей планировщика и автоскейлера, в сумме 1: экономия
+// мощности, снижение углеродного следа, экономия денег и простой сервиса
+type Weights struct {
+	Power      float64 `json:"power"`
+	Carbon     float64 `json:"carbon"`
+	Cost       float64 `json:"cost"`
+	Disruption float64 `json:"disruption"`
+}
+
+// DefaultWeights - только экономия мощности, как до появления весов
+var DefaultWeights = Weights{Power: 1}
+
+// Validate проверяет, что веса неотрицательны и в сумме дают 1
+func (w Weights) Validate() error {
+	if w.Power < 0 || w.Carbon < 0 || w.Cost < 0 || w.Disruption < 0 {
+		return fmt.Errorf("objective weights must not be negative")
+	}
+	if sum := w.Power + w.Carbon + w.Cost + w.Disruption; math.Abs(sum-1) > 1e-6 {
+		return fmt.Errorf("objective weights must sum to 1, got %g", sum)
+	}
+	if w.Disruption == 1 {
+		return fmt.Errorf("objective weights must include at least one benefit: power, carbon or cost")
+	}
+	return nil
+}
+
+// Scores - оценки варианта по целям. Выгоды (Power, Carbon, Cost)
+// приведены к одному масштабу вызывающей стороной, Disruption - доля
+// допустимого простоя (0-1) и вычитается.
+type Scores struct {
+	Power      float64 `json:"power"`
+	Carbon     float64 `json:"carbon"`
+	Cost       float64 `json:"cost"`
+	Disruption float64 `json:"disruption"`
+}
+
+// Score - взвешенная оценка варианта
+func (w Weights) Score(s Scores) float64 {
+	return w.Power*s.Power + w.Carbon*s.Carbon + w.Cost*s.Cost - w.Disruption*s.Disruption
+}
+
+// Selection - набор весов, действовавший при решении; попадает в
+// объяснение решения и в план миграции
+type Selection struct {
+	Environment string `json:"environment"`
+	Weights
+}
+
+// Policy - веса по окружениям (метка environment): например, в prod
+// простой весит больше, в dev - экономия
+type Policy struct {
+	Default      Weights            `json:"default"`
+	Environments map[string]Weights `json:"environments,omitempty"`
+}
+
+// DefaultPolicy - DefaultWeights для всех окружений
+func DefaultPolicy() Policy {
+	return Policy{Default: DefaultWeights}
+}
+
+// Validate проверяет все наборы весов
+func (p Policy) Validate() error {
+	if err := p.Default.Validate(); err != nil {
+		return fmt.Errorf("%s: %w", DefaultEnvironment, err)
+	}
+	for _, environment := range p.EnvironmentNames() {
+		if err := p.Environments[environment].Validate(); err != nil {
+			return fmt.Errorf("%s: %w", environment, err)
+		}
+	}
+	return nil
+}
+
+// EnvironmentNames возвращает окружения со своими весами
+func (p Policy) EnvironmentNames() []string {
+	names := make([]string, 0, len(p.Environments))
+	for name := range p.Environments {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// For возвращает веса окружения; окружение без своего набора (и
+// неизвестное) получает веса по умолчанию. Нулевая политика дает DefaultWeights.
+func (p Policy) For(environment string) Selection {
+	if weights, ok := p.Environments[environment]; ok && environment != "" {
+		return Selection{Environment: environment, Weights: weights}
+	}
+	if p.Default == (Weights{}) {
+		return Selection{Environment: DefaultEnvironment, Weights: DefaultWeights}
+	}
+	return Selection{Environment: DefaultEnvironment, Weights: p.Default}
+}
+
+// LoadPolicy читает веса из JSON-файла вида {"default": {"power": 0.6,
+// "carbon": 0.2, "cost": 0.2}, "environments": {"prod": {"power": 0.3, ...}}}.
+// Без default используются DefaultWeights.
+func LoadPolicy(path string) (Policy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Policy{}, err
+	}
+	var policy Policy
+	if err := json.Unmarshal(data, &policy); err != nil {
+		return Policy{}, fmt.Errorf("invalid objective weights file %s: %w", path, err)
+	}
+	if policy.Default == (Weights{}) {
+		policy.Default = DefaultWeights
+	}
+	return policy, policy.Validate()
+}
+
+// greenRegionShare - доля безуглеродной энергии региона, который провайдер
+// относит к низкоуглеродным, но долю не публикует
+const greenRegionShare = 0.5
+
+// CarbonFreeShare - доля безуглеродной энергии региона (0-1) по каталогу;
+// неизвестный регион - 0
+func CarbonFreeShare(region string) float64 {
+	profile, ok := catalog.LookupRegion(region)
+	switch {
+	case !ok:
+		return 0
+	case profile.CarbonFreePercent > 0:
+		return profile.CarbonFreePercent / 100
+	case profile.Green:
+		return greenRegionShare
+	}
+	return 0
+}
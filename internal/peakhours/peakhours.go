@@ -0,0 +1,223 @@
+// Package peakhours определяет часы пик по регионам и арендаторам: окна по
+// дням недели в часовом поясе площадки и праздники, когда пика нет. Пик
+// франкфуртского дата-центра и сингапурского приходится на разные часы UTC.
+package peakhours
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// dateLayout - формат праздничной даты
+const dateLayout = "2006-01-02"
+
+// Window - окно пика: с Start до End ("09:00"-"18:00") в дни Days ("mon",
+// "tue", ...; пусто - каждый день). End раньше Start - окно через полночь.
+type Window struct {
+	Days  []string `json:"days,omitempty"`
+	Start string   `json:"start"`
+	End   string   `json:"end"`
+}
+
+// Definition - часы пик площадки. Timezone - зона IANA (Europe/Berlin);
+// пусто - локальное время сервера Platypus. Holidays - даты без пика.
+type Definition struct {
+	Timezone string   `json:"timezone,omitempty"`
+	Windows  []Window `json:"windows"`
+	Holidays []string `json:"holidays,omitempty"`
+}
+
+// Default - прежнее определение: 09:00-18:00 каждый день по локальному времени
+var Default = Definition{Windows: []Window{{Start: "09:00", End: "18:00"}}}
+
+// Policy - часы пик по умолчанию, по регионам и по арендаторам. Определение
+// арендатора важнее региона: у арендатора свой рабочий день.
+type Policy struct {
+	Default Definition            `json:"default"`
+	Regions map[string]Definition `json:"regions,omitempty"`
+	Tenants map[string]Definition `json:"tenants,omitempty"`
+
+	schedules map[string]*Schedule // "" - по умолчанию, region/<name>, tenant/<name>
+}
+
+// LoadPolicy читает часы пик из JSON-файла вида {"default": {"windows":
+// [{"start": "09:00", "end": "18:00"}]}, "regions": {"eu-central-1":
+// {"timezone": "Europe/Berlin", "windows": [...], "holidays": ["2026-12-25"]}}}
+func LoadPolicy(path string) (*Policy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var policy Policy
+	if err := json.Unmarshal(data, &policy); err != nil {
+		return nil, fmt.Errorf("invalid peak hours file %s: %w", path, err)
+	}
+	if len(policy.Default.Windows) == 0 {
+		policy.Default = Default
+	}
+	if err := policy.compile(); err != nil {
+		return nil, err
+	}
+	return &policy, nil
+}
+
+// DefaultPolicy возвращает политику из одного определения Default
+func DefaultPolicy() *Policy {
+	policy := &Policy{Default: Default}
+	if err := policy.compile(); err != nil {
+		panic(err)
+	}
+	return policy
+}
+
+func (p *Policy) compile() error {
+	p.schedules = make(map[string]*Schedule, 1+len(p.Regions)+len(p.Tenants))
+	schedule, err := compile(p.Default)
+	if err != nil {
+		return fmt.Errorf("default: %w", err)
+	}
+	p.schedules[""] = schedule
+	for _, scope := range []struct {
+		prefix      string
+		definitions map[string]Definition
+	}{{"region", p.Regions}, {"tenant", p.Tenants}} {
+		for name, definition := range scope.definitions {
+			schedule, err := compile(definition)
+			if err != nil {
+				return fmt.Errorf("%s %s: %w", scope.prefix, name, err)
+			}
+			p.schedules[scope.prefix+"/"+name] = schedule
+		}
+	}
+	return nil
+}
+
+// For возвращает расписание пика для арендатора и региона: арендатора, если
+// у него свое определение, затем региона, затем по умолчанию
+func (p *Policy) For(region, tenant string) *Schedule {
+	if schedule, ok := p.schedules["tenant/"+tenant]; ok && tenant != "" {
+		return schedule
+	}
+	if schedule, ok := p.schedules["region/"+region]; ok && region != "" {
+		return schedule
+	}
+	return p.schedules[""]
+}
+
+// Schedule - разобранное определение часов пик
+type Schedule struct {
+	location *time.Location
+	windows  []window
+	holidays map[string]bool
+}
+
+type window struct {
+	days       map[time.Weekday]bool // nil - каждый день
+	start, end int                   // Минуты от полуночи
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+func compile(definition Definition) (*Schedule, error) {
+	location := time.Local
+	if definition.Timezone != "" {
+		loaded, err := time.LoadLocation(definition.Timezone)
+		if err != nil {
+			return nil, fmt.Errorf("invalid timezone %q: %w", definition.Timezone, err)
+		}
+		location = loaded
+	}
+	if len(definition.Windows) == 0 {
+		return nil, fmt.Errorf("at least one window is required")
+	}
+
+	schedule := &Schedule{location: location, holidays: make(map[string]bool, len(definition.Holidays))}
+	for _, w := range definition.Windows {
+		compiled, err := compileWindow(w)
+		if err != nil {
+			return nil, err
+		}
+		schedule.windows = append(schedule.windows, compiled)
+	}
+	for _, holiday := range definition.Holidays {
+		if _, err := time.Parse(dateLayout, holiday); err != nil {
+			return nil, fmt.Errorf("invalid holiday %q: use YYYY-MM-DD", holiday)
+		}
+		schedule.holidays[holiday] = true
+	}
+	return schedule, nil
+}
+
+func compileWindow(w Window) (window, error) {
+	start, err := parseClock(w.Start)
+	if err != nil {
+		return window{}, err
+	}
+	end, err := parseClock(w.End)
+	if err != nil {
+		return window{}, err
+	}
+	if start == end {
+		return window{}, fmt.Errorf("window %s-%s is empty", w.Start, w.End)
+	}
+
+	compiled := window{start: start, end: end}
+	if len(w.Days) > 0 {
+		compiled.days = make(map[time.Weekday]bool, len(w.Days))
+		for _, day := range w.Days {
+			weekday, ok := weekdays[strings.ToLower(day)]
+			if !ok {
+				return window{}, fmt.Errorf("invalid day %q: use mon, tue, wed, thu, fri, sat or sun", day)
+			}
+			compiled.days[weekday] = true
+		}
+	}
+	return compiled, nil
+}
+
+// parseClock разбирает время суток "HH:MM"; "24:00" - конец суток
+func parseClock(value string) (int, error) {
+	if value == "24:00" {
+		return 24 * 60, nil
+	}
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q: use HH:MM", value)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// IsPeak сообщает, приходится ли момент на часы пик площадки
+func (s *Schedule) IsPeak(t time.Time) bool {
+	local := t.In(s.location)
+	if s.holidays[local.Format(dateLayout)] {
+		return false
+	}
+	minute := local.Hour()*60 + local.Minute()
+	for _, w := range s.windows {
+		if w.start < w.end {
+			if minute >= w.start && minute < w.end && w.onDay(local.Weekday()) {
+				return true
+			}
+			continue
+		}
+		// Окно через полночь относится ко дню, в который началось
+		if minute >= w.start && w.onDay(local.Weekday()) {
+			return true
+		}
+		if minute < w.end && w.onDay((local.Weekday()+6)%7) && !s.holidays[local.AddDate(0, 0, -1).Format(dateLayout)] {
+			return true
+		}
+	}
+	return false
+}
+
+func (w window) onDay(day time.Weekday) bool {
+	return w.days == nil || w.days[day]
+}
@@ -0,0 +1,137 @@
+package preflight
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// durationKeys - ключи, значения которых должны разбираться как time.Duration
+var durationKeys = map[string]bool{
+	"timeout": true, "window": true, "after": true, "resolution": true,
+	"interval": true, "lookback": true,
+}
+
+// durationSuffixes - окончания ключей с длительностями
+var durationSuffixes = []string{"_period", "_interval", "_timeout", "_backoff", "_cooldown", "_window", "_downtime"}
+
+// durationSections - разделы, все значения которых - длительности
+var durationSections = []string{"metrics.collector.metric_retention."}
+
+// enumValues - допустимые значения перечислимых настроек
+var enumValues = map[string][]string{
+	"metrics.collector.store.type": {"memory", "postgres", "timescale", "influxdb"},
+	"federation.mode":              {"standalone", "edge", "central"},
+	"replication.mode":             {"", "primary", "standby"},
+}
+
+// ValidateConfigFile проверяет синтаксис файла конфигурации, длительности,
+// перечислимые значения и порт. Каждая найденная ошибка - отдельный результат.
+func ValidateConfigFile(path string) []Result {
+	check := "config " + path
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return []Result{failed(check, err, "Укажите существующий файл через --config")}
+	}
+
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return []Result{failed(check, err, "Исправьте синтаксис YAML")}
+	}
+	if len(root.Content) == 0 {
+		return []Result{failed(check, fmt.Errorf("empty config"), "Файл конфигурации не содержит настроек")}
+	}
+
+	var results []Result
+	walkConfig(root.Content[0], "", func(key string, node *yaml.Node) {
+		if err := validateValue(key, node); err != nil {
+			results = append(results, Result{
+				Name:    check,
+				Status:  StatusFailed,
+				Message: fmt.Sprintf("line %d: %s: %v", node.Line, key, err),
+				Hint:    hintFor(key),
+			})
+		}
+	})
+	if len(results) == 0 {
+		results = append(results, ok(check, ""))
+	}
+	return results
+}
+
+// walkConfig обходит скалярные значения, передавая полный путь ключа
+func walkConfig(node *yaml.Node, prefix string, visit func(key string, node *yaml.Node)) {
+	switch node.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key := node.Content[i].Value
+			if prefix != "" {
+				key = prefix + "." + key
+			}
+			walkConfig(node.Content[i+1], key, visit)
+		}
+	case yaml.SequenceNode:
+		for i, item := range node.Content {
+			walkConfig(item, fmt.Sprintf("%s[%d]", prefix, i), visit)
+		}
+	case yaml.ScalarNode:
+		visit(prefix, node)
+	}
+}
+
+func validateValue(key string, node *yaml.Node) error {
+	if allowed, exists := enumValues[key]; exists {
+		for _, value := range allowed {
+			if node.Value == value {
+				return nil
+			}
+		}
+		return fmt.Errorf("%q is not one of %s", node.Value, strings.Join(allowed, ", "))
+	}
+
+	if key == "server.port" {
+		var port int
+		if err := node.Decode(&port); err != nil || port < 1 || port > 65535 {
+			return fmt.Errorf("%q is not a valid port", node.Value)
+		}
+		return nil
+	}
+
+	if isDurationKey(key) && node.Tag == "!!str" && node.Value != "" {
+		if _, err := time.ParseDuration(node.Value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func isDurationKey(key string) bool {
+	for _, section := range durationSections {
+		if strings.HasPrefix(key, section) {
+			return true
+		}
+	}
+	name := key[strings.LastIndex(key, ".")+1:]
+	if durationKeys[name] {
+		return true
+	}
+	for _, suffix := range durationSuffixes {
+		if strings.HasSuffix(name, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+func hintFor(key string) string {
+	if _, exists := enumValues[key]; exists {
+		return "Выберите одно из допустимых значений"
+	}
+	if key == "server.port" {
+		return "Порт - целое число от 1 до 65535"
+	}
+	return "Длительность записывается как \"30s\", \"5m\", \"24h\""
+}
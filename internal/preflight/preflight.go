@@ -0,0 +1,462 @@
+// Package preflight проверяет окружение перед запуском: учетные данные
+// провайдера, доступность внешних сервисов, хранилища и порта. Сервер с
+// неработающей подсистемой не должен молча стартовать.
+package preflight
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/YumeNoTenshi/platypus/internal/api"
+	"github.com/YumeNoTenshi/platypus/internal/calendar"
+	"github.com/YumeNoTenshi/platypus/internal/ecotags"
+	"github.com/YumeNoTenshi/platypus/internal/encryption"
+	"github.com/YumeNoTenshi/platypus/internal/inventory"
+	"github.com/YumeNoTenshi/platypus/internal/metrics"
+	"github.com/YumeNoTenshi/platypus/internal/migration"
+	"github.com/YumeNoTenshi/platypus/internal/mqtt"
+	"github.com/YumeNoTenshi/platypus/internal/objectives"
+	"github.com/YumeNoTenshi/platypus/internal/peakhours"
+	"github.com/YumeNoTenshi/platypus/internal/scrape"
+	"github.com/YumeNoTenshi/platypus/internal/statsd"
+	"github.com/YumeNoTenshi/platypus/pkg/carbon"
+	"github.com/YumeNoTenshi/platypus/pkg/cloud"
+	"github.com/YumeNoTenshi/platypus/pkg/powermodel"
+)
+
+// Status - итог одной проверки
+type Status string
+
+const (
+	StatusOK      Status = "ok"
+	StatusWarning Status = "warning" // Запуск возможен, но что-то работает не в полную силу
+	StatusFailed  Status = "failed"
+)
+
+// Result - результат проверки с подсказкой, как исправить проблему
+type Result struct {
+	Name    string `json:"name"`
+	Status  Status `json:"status"`
+	Message string `json:"message,omitempty"`
+	Hint    string `json:"hint,omitempty"`
+}
+
+// Check выполняет одну проверку
+type Check func(ctx context.Context) Result
+
+// Report - результаты всех проверок
+type Report struct {
+	Results []Result `json:"results"`
+}
+
+// Run выполняет проверки по очереди
+func Run(ctx context.Context, checks ...Check) Report {
+	report := Report{Results: make([]Result, 0, len(checks))}
+	for _, check := range checks {
+		report.Results = append(report.Results, check(ctx))
+	}
+	return report
+}
+
+// Add добавляет результаты, полученные вне Run
+func (r *Report) Add(results ...Result) {
+	r.Results = append(r.Results, results...)
+}
+
+// Failed сообщает, провалилась ли хотя бы одна проверка
+func (r Report) Failed() bool {
+	for _, result := range r.Results {
+		if result.Status == StatusFailed {
+			return true
+		}
+	}
+	return false
+}
+
+// Write выводит отчет в читаемом виде
+func (r Report) Write(w io.Writer) {
+	for _, result := range r.Results {
+		fmt.Fprintf(w, "[%-7s] %s", result.Status, result.Name)
+		if result.Message != "" {
+			fmt.Fprintf(w, ": %s", result.Message)
+		}
+		fmt.Fprintln(w)
+		if result.Hint != "" && result.Status != StatusOK {
+			fmt.Fprintf(w, "          -> %s\n", result.Hint)
+		}
+	}
+}
+
+func ok(name, message string) Result {
+	return Result{Name: name, Status: StatusOK, Message: message}
+}
+
+func failed(name string, err error, hint string) Result {
+	return Result{Name: name, Status: StatusFailed, Message: err.Error(), Hint: hint}
+}
+
+// ProviderCredentials проверяет, что провайдер принимает учетные данные и
+// отвечает на запрос списка инстансов
+func ProviderCredentials(name string, provider cloud.CloudProvider, timeout time.Duration) Check {
+	return func(ctx context.Context) Result {
+		check := "provider " + name
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+
+		servers, err := provider.GetInstances(ctx)
+		switch {
+		case err == nil:
+			return ok(check, fmt.Sprintf("%d instances visible", len(servers)))
+		case errors.Is(err, cloud.ErrUnauthorized):
+			return failed(check, err, "Учетные данные отклонены: проверьте AWS_ACCESS_KEY_ID/AWS_SECRET_ACCESS_KEY или GOOGLE_APPLICATION_CREDENTIALS и права на чтение инстансов")
+		case errors.Is(err, cloud.ErrThrottled):
+			return Result{Name: check, Status: StatusWarning, Message: err.Error(),
+				Hint: "Провайдер ограничивает частоту запросов; учетные данные, вероятно, верны"}
+		case errors.Is(err, context.DeadlineExceeded):
+			return failed(check, err, "Провайдер не ответил вовремя: проверьте сетевой доступ к API провайдера и настройки прокси")
+		default:
+			return failed(check, err, "Проверьте регион, учетные данные и сетевой доступ к API провайдера; в автономном режиме - файл инвентаря")
+		}
+	}
+}
+
+// CarbonData проверяет источник данных об углеродной интенсивности.
+// Пустой путь означает встроенный набор, который всегда доступен.
+func CarbonData(path string) Check {
+	return func(ctx context.Context) Result {
+		const check = "carbon data"
+		if path == "" {
+			return ok(check, "bundled dataset "+carbon.Bundled().Version)
+		}
+		dataset, err := carbon.LoadDataset(path)
+		if err != nil {
+			return failed(check, err, "Исправьте файл PLATYPUS_CARBON_DATASET или уберите переменную, чтобы использовать встроенный набор")
+		}
+		return ok(check, fmt.Sprintf("%s (%d regions)", dataset.Version, len(dataset.Regions)))
+	}
+}
+
+// PowerModels проверяет файл моделей оценки энергопотребления
+func PowerModels(path string) Check {
+	return func(ctx context.Context) Result {
+		const check = "power models"
+		if _, err := powermodel.Load(path); err != nil {
+			return failed(check, err, "Исправьте файл PLATYPUS_POWER_MODELS или уберите переменную, чтобы использовать линейную модель")
+		}
+		return ok(check, path)
+	}
+}
+
+// APIKeyScopes проверяет файл областей ключей API
+func APIKeyScopes(path string) Check {
+	return func(ctx context.Context) Result {
+		const check = "api key scopes"
+		if _, err := api.LoadScopes(path); err != nil {
+			return failed(check, err, "Исправьте файл PLATYPUS_API_KEY_SCOPES; без него ключи получают доступ ко всем серверам")
+		}
+		return ok(check, path)
+	}
+}
+
+// MQTTMappings проверяет файл правил сопоставления топиков MQTT серверам
+func MQTTMappings(path string) Check {
+	return func(ctx context.Context) Result {
+		const check = "mqtt mappings"
+		if _, err := mqtt.LoadMappings(path); err != nil {
+			return failed(check, err, "Укажите в PLATYPUS_MQTT_MAPPINGS JSON-файл с правилами топиков")
+		}
+		return ok(check, path)
+	}
+}
+
+// StatsDMappings проверяет файл правил сопоставления метрик StatsD серверам
+func StatsDMappings(path string) Check {
+	return func(ctx context.Context) Result {
+		const check = "statsd mappings"
+		if _, err := statsd.LoadMappings(path); err != nil {
+			return failed(check, err, "Укажите в PLATYPUS_STATSD_MAPPINGS JSON-файл с правилами имен метрик")
+		}
+		return ok(check, path)
+	}
+}
+
+// ScrapeTargets проверяет файл целей опроса экспортеров
+func ScrapeTargets(path string) Check {
+	return func(ctx context.Context) Result {
+		const check = "scrape targets"
+		targets, err := scrape.LoadTargets(path)
+		if err != nil {
+			return failed(check, err, "Укажите в PLATYPUS_SCRAPE_TARGETS JSON-массив целей: url, server_id")
+		}
+		return ok(check, fmt.Sprintf("%s: %d targets", path, len(targets)))
+	}
+}
+
+// Reservations проверяет файл резервирований мощностей
+func Reservations(path string) Check {
+	return func(ctx context.Context) Result {
+		const check = "reservations"
+		reservations, err := migration.LoadReservations(path)
+		if err != nil {
+			return failed(check, err, "Укажите в PLATYPUS_RESERVATIONS JSON-массив резервирований: id, instance_type, count")
+		}
+		return ok(check, fmt.Sprintf("%s: %d reservations", path, len(reservations)))
+	}
+}
+
+// CarbonForecast проверяет файл прогноза углеродной интенсивности
+func CarbonForecast(path string) Check {
+	return func(ctx context.Context) Result {
+		const check = "carbon forecast"
+		forecast, err := carbon.LoadForecast(path)
+		if err != nil {
+			return failed(check, err, "Укажите в PLATYPUS_CARBON_FORECAST JSON с прогнозом по регионам: time, grams_per_kwh")
+		}
+		return ok(check, fmt.Sprintf("%s: %d regions", path, len(forecast.Regions())))
+	}
+}
+
+// ElectricityMapsZones проверяет соответствие регионов зонам Electricity Maps
+func ElectricityMapsZones(value string) Check {
+	return func(ctx context.Context) Result {
+		const check = "electricity maps zones"
+		zones, err := carbon.ParseZones(value)
+		if err == nil && len(zones) == 0 {
+			err = fmt.Errorf("no zones configured")
+		}
+		if err != nil {
+			return failed(check, err, "Укажите в PLATYPUS_ELECTRICITYMAPS_ZONES пары регион=зона через запятую, например eu-west-1=IE")
+		}
+		return ok(check, fmt.Sprintf("%d regions", len(zones)))
+	}
+}
+
+// CalendarWindows проверяет файл окон масштабирования и обслуживания
+func CalendarWindows(path string) Check {
+	return func(ctx context.Context) Result {
+		const check = "calendar windows"
+		windows, err := calendar.LoadWindows(path)
+		if err != nil {
+			return failed(check, err, "Исправьте JSON-файл окон PLATYPUS_CALENDAR_WINDOWS: schedule и duration либо starts_at и ends_at")
+		}
+		return ok(check, fmt.Sprintf("%s: %d windows", path, len(windows)))
+	}
+}
+
+// MetricLabels проверяет список меток точек, экспортируемых в Prometheus
+func MetricLabels(value string) Check {
+	return func(ctx context.Context) Result {
+		const check = "metric labels"
+		names, err := metrics.ParseMetricLabels(value)
+		if err != nil {
+			return failed(check, err, "Перечислите в PLATYPUS_METRIC_LABELS через запятую имена меток Prometheus, кроме server_id и region")
+		}
+		return ok(check, strings.Join(names, ","))
+	}
+}
+
+// HistogramBuckets проверяет границы корзин гистограммы из переменной name
+func HistogramBuckets(name, value string) Check {
+	return func(ctx context.Context) Result {
+		check := "histogram buckets " + name
+		buckets, err := metrics.ParseBuckets(value)
+		if err != nil {
+			return failed(check, err, "Перечислите в "+name+" возрастающие границы корзин через запятую, например 50,100,200,400,800")
+		}
+		return ok(check, fmt.Sprintf("%d buckets", len(buckets)))
+	}
+}
+
+// MetricRetention проверяет сроки хранения отдельных метрик
+func MetricRetention(value string) Check {
+	return func(ctx context.Context) Result {
+		const check = "metric retention"
+		retention, err := metrics.ParseMetricRetention(value)
+		if err != nil {
+			return failed(check, err, "Укажите в PLATYPUS_METRIC_RETENTION пары метрика=срок через запятую, например carbon_footprint=8760h")
+		}
+		names := make([]string, 0, len(retention))
+		for name, period := range retention {
+			names = append(names, name+"="+period.String())
+		}
+		sort.Strings(names)
+		return ok(check, strings.Join(names, ","))
+	}
+}
+
+// ScoreWeights проверяет веса эко-рейтинга
+func ScoreWeights(value string) Check {
+	return func(ctx context.Context) Result {
+		const check = "eco-score weights"
+		weights, err := metrics.ParseScoreWeights(value)
+		if err != nil {
+			return failed(check, err, "Укажите в PLATYPUS_ECO_SCORE_WEIGHTS три неотрицательных веса power,utilization,carbon с суммой 1")
+		}
+		return ok(check, fmt.Sprintf("power %g, utilization %g, carbon %g", weights.Power, weights.Utilization, weights.Carbon))
+	}
+}
+
+// AnomalyMethod проверяет способ поиска аномалий анализатора
+func AnomalyMethod(value string) Check {
+	return func(ctx context.Context) Result {
+		const check = "anomaly method"
+		method, err := metrics.ParseAnomalyMethod(value)
+		if err != nil {
+			return failed(check, err, "Укажите в PLATYPUS_ANOMALY_METHOD zscore, ewma или mad")
+		}
+		return ok(check, string(method))
+	}
+}
+
+// ObjectiveWeights проверяет веса целей планировщика и автоскейлера
+func ObjectiveWeights(path string) Check {
+	return func(ctx context.Context) Result {
+		const check = "objective weights"
+		policy, err := objectives.LoadPolicy(path)
+		if err != nil {
+			return failed(check, err, "Укажите в PLATYPUS_OBJECTIVE_WEIGHTS JSON с весами power, carbon, cost, disruption (сумма 1) для default и окружений")
+		}
+		environments := policy.EnvironmentNames()
+		if len(environments) == 0 {
+			return ok(check, path+": default weights only")
+		}
+		return ok(check, fmt.Sprintf("%s: default and %s", path, strings.Join(environments, ", ")))
+	}
+}
+
+// EcoSLOs проверяет эко-цели сервисов
+func EcoSLOs(path string) Check {
+	return func(ctx context.Context) Result {
+		const check = "eco slos"
+		policy, err := ecotags.LoadSLOPolicy(path)
+		if err != nil {
+			return failed(check, err, "Укажите в PLATYPUS_ECO_SLOS JSON с целями default и services: min_eco_score, max_power_usage, max_carbon_footprint, max_regression_percent, max_fingerprint_shift_percent")
+		}
+		return ok(check, fmt.Sprintf("%s: %d services with own objectives", path, len(policy.Services)))
+	}
+}
+
+// PeakHours проверяет часы пик по регионам и арендаторам
+func PeakHours(path string) Check {
+	return func(ctx context.Context) Result {
+		const check = "peak hours"
+		policy, err := peakhours.LoadPolicy(path)
+		if err != nil {
+			return failed(check, err, "Укажите в PLATYPUS_PEAK_HOURS JSON с default, regions и tenants: timezone (зона IANA), windows (start, end в HH:MM, days), holidays (YYYY-MM-DD)")
+		}
+		return ok(check, fmt.Sprintf("%s: default, %d regions and %d tenants", path, len(policy.Regions), len(policy.Tenants)))
+	}
+}
+
+// EncryptionKeys проверяет ключи шифрования данных на диске
+func EncryptionKeys(source encryption.KeySource) Check {
+	return func(ctx context.Context) Result {
+		const check = "encryption keys"
+		keys, err := encryption.Load(ctx, source)
+		if err != nil {
+			return failed(check, err, "Укажите ключи как id:base64 через запятую, первый - основной; ключ создается командой openssl rand -base64 32")
+		}
+		return ok(check, fmt.Sprintf("primary key %s", keys.Primary()))
+	}
+}
+
+// ServerMetadata проверяет файл метаданных серверов
+func ServerMetadata(path string) Check {
+	return func(ctx context.Context) Result {
+		const check = "server metadata"
+		records, err := inventory.LoadMetadata(path)
+		if err != nil {
+			return failed(check, err, "Исправьте файл PLATYPUS_SERVER_METADATA; формат определяется расширением (.csv, .yaml, .json)")
+		}
+		return ok(check, fmt.Sprintf("%s: %d servers", path, len(records)))
+	}
+}
+
+// Endpoint проверяет, что внешний сервис принимает TCP-соединения
+func Endpoint(name, rawURL string, timeout time.Duration) Check {
+	return func(ctx context.Context) Result {
+		check := "endpoint " + name
+		address, err := dialAddress(rawURL)
+		if err != nil {
+			return failed(check, err, "Укажите адрес в виде http(s)://host[:port]")
+		}
+
+		dialer := net.Dialer{Timeout: timeout}
+		conn, err := dialer.DialContext(ctx, "tcp", address)
+		if err != nil {
+			return failed(check, err, fmt.Sprintf("%s недоступен: проверьте адрес, DNS и сетевые правила", address))
+		}
+		conn.Close()
+		return ok(check, address)
+	}
+}
+
+func dialAddress(rawURL string) (string, error) {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+	if parsed.Hostname() == "" {
+		return "", fmt.Errorf("no host in %q", rawURL)
+	}
+	if port := parsed.Port(); port != "" {
+		return net.JoinHostPort(parsed.Hostname(), port), nil
+	}
+	switch parsed.Scheme {
+	case "https":
+		return net.JoinHostPort(parsed.Hostname(), "443"), nil
+	case "http":
+		return net.JoinHostPort(parsed.Hostname(), "80"), nil
+	}
+	return "", fmt.Errorf("unsupported scheme %q", parsed.Scheme)
+}
+
+// Writable проверяет, что в каталог можно писать, создавая его при необходимости
+func Writable(name, dir string) Check {
+	return func(ctx context.Context) Result {
+		check := "storage " + name
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return failed(check, err, "Создайте каталог "+dir+" или смонтируйте том с правами на запись")
+		}
+		file, err := os.CreateTemp(dir, ".preflight-*")
+		if err != nil {
+			return failed(check, err, "Выдайте пользователю сервиса права на запись в "+dir)
+		}
+		file.Close()
+		os.Remove(file.Name())
+		return ok(check, dir)
+	}
+}
+
+// MetricsStore проверяет, что хранилище метрик отвечает на запросы
+func MetricsStore(name string, store metrics.Store) Check {
+	return func(ctx context.Context) Result {
+		check := "metrics store " + name
+		ids, err := store.ServerIDs()
+		if err != nil {
+			return failed(check, err, "Проверьте строку подключения, доступность сервера и права на таблицу/бакет")
+		}
+		return ok(check, fmt.Sprintf("%d servers stored", len(ids)))
+	}
+}
+
+// PortAvailable проверяет, что адрес для HTTP-сервера свободен
+func PortAvailable(addr string) Check {
+	return func(ctx context.Context) Result {
+		check := "listen " + addr
+		listener, err := net.Listen("tcp", addr)
+		if err != nil {
+			return failed(check, err, "Порт занят другим процессом или требует прав; остановите его или выберите другой адрес")
+		}
+		listener.Close()
+		return ok(check, "")
+	}
+}
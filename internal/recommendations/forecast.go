@@ -0,0 +1,111 @@
+package recommendations
+
+import "time"
+
+type ForecastConfig struct {
+	Horizon            time.Duration // Период, на который проецируется экономия
+	PricePerKWh        float64       // Стоимость электроэнергии, $/кВт*ч
+	DefaultGramsPerKWh float64       // Углеродная интенсивность, если регион цели неизвестен
+}
+
+// TeamResolver определяет команду-владельца цели рекомендации
+type TeamResolver func(item Recommendation) string
+
+// IntensityResolver возвращает углеродную интенсивность (г CO2/кВт*ч) для цели рекомендации
+type IntensityResolver func(item Recommendation) (float64, bool)
+
+// Savings - прогнозируемая экономия группы рекомендаций
+type Savings struct {
+	Count   int     `json:"count"`
+	Watts   float64 `json:"watts"`
+	KWh     float64 `json:"kwh"`
+	CO2Kg   float64 `json:"co2_kg"`
+	CostUSD float64 `json:"cost_usd"`
+}
+
+// Forecast - экономия при внедрении всех незакрытых рекомендаций
+type Forecast struct {
+	Horizon     string             `json:"horizon"`
+	GeneratedAt time.Time          `json:"generated_at"`
+	Total       Savings            `json:"total"`
+	ByType      map[Type]Savings   `json:"by_type"`
+	ByTeam      map[string]Savings `json:"by_team"`
+}
+
+type Forecaster struct {
+	config    ForecastConfig
+	manager   *Manager
+	team      TeamResolver
+	intensity IntensityResolver
+}
+
+// NewForecaster создает агрегатор прогноза; резолверы могут быть nil
+func NewForecaster(config ForecastConfig, manager *Manager, team TeamResolver, intensity IntensityResolver) *Forecaster {
+	return &Forecaster{
+		config:    config,
+		manager:   manager,
+		team:      team,
+		intensity: intensity,
+	}
+}
+
+// Forecast проецирует экономию открытых и принятых рекомендаций на горизонт horizon
+// (0 - горизонт из конфигурации)
+func (f *Forecaster) Forecast(horizon time.Duration) Forecast {
+	if horizon <= 0 {
+		horizon = f.config.Horizon
+	}
+
+	forecast := Forecast{
+		Horizon:     horizon.String(),
+		GeneratedAt: time.Now(),
+		ByType:      make(map[Type]Savings),
+		ByTeam:      make(map[string]Savings),
+	}
+
+	pending := append(f.manager.List(Filter{State: StateOpen}), f.manager.List(Filter{State: StateAccepted})...)
+	for _, item := range pending {
+		savings := f.project(item, horizon)
+
+		forecast.Total = forecast.Total.add(savings)
+		forecast.ByType[item.Type] = forecast.ByType[item.Type].add(savings)
+
+		team := "unassigned"
+		if f.team != nil {
+			if resolved := f.team(item); resolved != "" {
+				team = resolved
+			}
+		}
+		forecast.ByTeam[team] = forecast.ByTeam[team].add(savings)
+	}
+
+	return forecast
+}
+
+func (f *Forecaster) project(item Recommendation, horizon time.Duration) Savings {
+	gramsPerKWh := f.config.DefaultGramsPerKWh
+	if f.intensity != nil {
+		if intensity, ok := f.intensity(item); ok {
+			gramsPerKWh = intensity
+		}
+	}
+
+	kwh := item.EstimatedSavingWatts * horizon.Hours() / 1000
+	return Savings{
+		Count:   1,
+		Watts:   item.EstimatedSavingWatts,
+		KWh:     kwh,
+		CO2Kg:   kwh * gramsPerKWh / 1000,
+		CostUSD: kwh * f.config.PricePerKWh,
+	}
+}
+
+func (s Savings) add(other Savings) Savings {
+	return Savings{
+		Count:   s.Count + other.Count,
+		Watts:   s.Watts + other.Watts,
+		KWh:     s.KWh + other.KWh,
+		CO2Kg:   s.CO2Kg + other.CO2Kg,
+		CostUSD: s.CostUSD + other.CostUSD,
+	}
+}
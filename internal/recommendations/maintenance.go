@@ -0,0 +1,344 @@
+package recommendations
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/YumeNoTenshi/platypus/internal/metrics"
+)
+
+// TypeHardwareInspection - проверка оборудования: растущее потребление в
+// простое часто предшествует отказу блока питания или вентиляторов
+const TypeHardwareInspection Type = "hardware_inspection"
+
+const (
+	dayLayout = "2006-01-02"
+	// minIdlePoints - меньше точек простоя за сутки не дают базовой линии
+	minIdlePoints = 12
+	// minCohortSize - с меньшей когортой сравнение идет со всем парком
+	minCohortSize = 3
+	// madScale переводит медианное абсолютное отклонение в оценку σ
+	madScale = 1.4826
+)
+
+// HardwareInspectorConfig - параметры детектора износа оборудования
+type HardwareInspectorConfig struct {
+	Path      string        // JSON-файл суточных базовых линий; пусто - только в памяти процесса
+	IdleCPU   float64       // Загрузка CPU, %, ниже которой точка - простой; 0 - 10
+	Period    time.Duration // За сколько оценивается рост; 0 - 4 недели
+	MinRise   float64       // Рост базовой линии в долях, ниже которого сервер не отмечается; 0 - 0.1
+	Deviation float64       // На сколько σ рост должен превышать медиану когорты; 0 - 3
+	Retention time.Duration // Сколько хранить суточные базовые линии; 0 - 90 дней
+}
+
+// HardwareInspector отмечает серверы, базовая линия потребления которых в
+// простое неделями растет быстрее, чем у когорты того же типа инстанса.
+// Сырые точки хранятся меньше срока оценки, поэтому детектор сам копит
+// медиану потребления в простое за каждые сутки.
+type HardwareInspector struct {
+	config    HardwareInspectorConfig
+	collector *metrics.Collector
+	analyzer  *metrics.Analyzer
+
+	mu     sync.Mutex
+	daily  map[string]map[string]float64 // Сервер -> дата UTC -> базовая линия, Вт
+	saveMu sync.Mutex                    // Упорядочивает запись файла
+}
+
+// NewHardwareInspector создает детектор и загружает сохраненные базовые линии
+func NewHardwareInspector(config HardwareInspectorConfig, collector *metrics.Collector, analyzer *metrics.Analyzer) (*HardwareInspector, error) {
+	if config.IdleCPU <= 0 {
+		config.IdleCPU = 10
+	}
+	if config.Period <= 0 {
+		config.Period = 4 * 7 * 24 * time.Hour
+	}
+	if config.MinRise <= 0 {
+		config.MinRise = 0.1
+	}
+	if config.Deviation <= 0 {
+		config.Deviation = 3
+	}
+	if config.Retention <= 0 {
+		config.Retention = 90 * 24 * time.Hour
+	}
+
+	h := &HardwareInspector{
+		config:    config,
+		collector: collector,
+		analyzer:  analyzer,
+		daily:     make(map[string]map[string]float64),
+	}
+	if config.Path == "" {
+		return h, nil
+	}
+	data, err := os.ReadFile(config.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return h, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &h.daily); err != nil {
+		return nil, fmt.Errorf("invalid idle baseline file %s: %w", config.Path, err)
+	}
+	return h, nil
+}
+
+func (h *HardwareInspector) Name() string { return "hardware-inspector" }
+
+// idleDrift - рост базовой линии сервера в простое за период оценки
+type idleDrift struct {
+	serverID string
+	cohort   string
+	from, to float64 // Базовая линия по прямой тренда в начале и в конце периода, Вт
+	rise     float64 // (to - from) / from
+	days     []string
+}
+
+func (h *HardwareInspector) Recommendations(ctx context.Context) ([]Recommendation, error) {
+	now := time.Now().UTC()
+	h.sample(now)
+
+	h.mu.Lock()
+	var drifts []idleDrift
+	for serverID, days := range h.daily {
+		if drift, ok := h.drift(serverID, days, now); ok {
+			drifts = append(drifts, drift)
+		}
+	}
+	h.mu.Unlock()
+	h.save()
+
+	cohorts := make(map[string][]float64)
+	var fleet []float64
+	for _, drift := range drifts {
+		cohorts[drift.cohort] = append(cohorts[drift.cohort], drift.rise)
+		fleet = append(fleet, drift.rise)
+	}
+
+	var result []Recommendation
+	for _, drift := range drifts {
+		if drift.rise < h.config.MinRise {
+			continue
+		}
+		peers, scope := cohorts[drift.cohort], "instance type "+drift.cohort
+		if drift.cohort == "" || len(peers) < minCohortSize {
+			peers, scope = fleet, "fleet"
+		}
+		if len(peers) < minCohortSize {
+			continue
+		}
+		cohortMedian, sigma := robustSpread(peers)
+		threshold := cohortMedian + h.config.Deviation*math.Max(sigma, 0.01)
+		if drift.rise <= threshold {
+			continue
+		}
+
+		result = append(result, Recommendation{
+			Type:       TypeHardwareInspection,
+			TargetID:   drift.serverID,
+			TargetKind: "server",
+			Title:      "Inspect hardware: idle power is rising",
+			Description: fmt.Sprintf("Idle power baseline rose from %.0f W to %.0f W (+%.0f%%) over %d days, "+
+				"while the %s median rose %+.0f%%; a failing PSU or fan often draws more power before it fails",
+				drift.from, drift.to, drift.rise*100, len(drift.days), scope, cohortMedian*100),
+			EstimatedSavingWatts: drift.to - drift.from,
+			Evidence:             h.evidence(drift, scope, cohortMedian, threshold),
+		})
+	}
+	return result, nil
+}
+
+// sample обновляет базовые линии прошедших суток, точки которых еще хранятся
+func (h *HardwareInspector) sample(now time.Time) {
+	today := now.Truncate(24 * time.Hour)
+	cutoff := today.Add(-h.config.Retention).Format(dayLayout)
+
+	for _, serverID := range h.collector.ServerIDs() {
+		data, err := h.collector.GetMetricsRange(serverID, today.Add(-h.config.Retention), today)
+		if err != nil {
+			continue
+		}
+		idle := make(map[string][]float64)
+		for _, m := range data {
+			if m.Interpolated || m.CPUUsage >= h.config.IdleCPU || m.PowerUsage <= 0 {
+				continue
+			}
+			day := time.Unix(m.Timestamp, 0).UTC().Format(dayLayout)
+			idle[day] = append(idle[day], m.PowerUsage)
+		}
+
+		h.mu.Lock()
+		days := h.daily[serverID]
+		if days == nil {
+			days = make(map[string]float64)
+			h.daily[serverID] = days
+		}
+		for day, values := range idle {
+			if len(values) >= minIdlePoints {
+				days[day] = median(values)
+			}
+		}
+		h.mu.Unlock()
+	}
+
+	h.mu.Lock()
+	for serverID, days := range h.daily {
+		for day := range days {
+			if day < cutoff {
+				delete(days, day)
+			}
+		}
+		if len(days) == 0 {
+			delete(h.daily, serverID)
+		}
+	}
+	h.mu.Unlock()
+}
+
+// drift оценивает рост базовой линии по прямой наименьших квадратов через
+// суточные значения периода. Нужны значения хотя бы в половине дней
+// периода, охватывающие три четверти его. Вызывается под блокировкой.
+func (h *HardwareInspector) drift(serverID string, days map[string]float64, now time.Time) (idleDrift, bool) {
+	start := now.Add(-h.config.Period).Format(dayLayout)
+	periodDays := h.config.Period.Hours() / 24
+
+	var dates []string
+	for day := range days {
+		if day >= start {
+			dates = append(dates, day)
+		}
+	}
+	if float64(len(dates)) < periodDays/2 {
+		return idleDrift{}, false
+	}
+	sort.Strings(dates)
+
+	first, _ := time.Parse(dayLayout, dates[0])
+	last, _ := time.Parse(dayLayout, dates[len(dates)-1])
+	span := last.Sub(first).Hours() / 24
+	if span < periodDays*3/4 {
+		return idleDrift{}, false
+	}
+
+	var sx, sy, sxx, sxy float64
+	for _, date := range dates {
+		t, _ := time.Parse(dayLayout, date)
+		x := t.Sub(first).Hours() / 24
+		y := days[date]
+		sx += x
+		sy += y
+		sxx += x * x
+		sxy += x * y
+	}
+	n := float64(len(dates))
+	slope := (n*sxy - sx*sy) / (n*sxx - sx*sx)
+	intercept := (sy - slope*sx) / n
+	from, to := intercept, intercept+slope*span
+	if from <= 0 {
+		return idleDrift{}, false
+	}
+
+	cohort, _ := h.analyzer.InstanceType(serverID)
+	return idleDrift{
+		serverID: serverID,
+		cohort:   cohort,
+		from:     from,
+		to:       to,
+		rise:     (to - from) / from,
+		days:     dates,
+	}, true
+}
+
+// evidence - недельные медианы базовой линии и сравнение с когортой
+func (h *HardwareInspector) evidence(drift idleDrift, scope string, cohortMedian, threshold float64) []Evidence {
+	h.mu.Lock()
+	days := h.daily[drift.serverID]
+	weeks := make(map[string][]float64)
+	for _, date := range drift.days {
+		t, _ := time.Parse(dayLayout, date)
+		// Неделя начинается с понедельника
+		monday := t.AddDate(0, 0, -((int(t.Weekday()) + 6) % 7))
+		weeks[monday.Format(dayLayout)] = append(weeks[monday.Format(dayLayout)], days[date])
+	}
+	h.mu.Unlock()
+
+	starts := make([]string, 0, len(weeks))
+	for start := range weeks {
+		starts = append(starts, start)
+	}
+	sort.Strings(starts)
+
+	var evidence []Evidence
+	for _, start := range starts {
+		from, _ := time.Parse(dayLayout, start)
+		evidence = append(evidence, Evidence{
+			Label: "idle power baseline",
+			Value: median(weeks[start]),
+			Unit:  "W",
+			From:  from,
+			To:    from.AddDate(0, 0, 7),
+		})
+	}
+	return append(evidence,
+		Evidence{Label: "idle power rise", Value: drift.rise * 100, Unit: "%"},
+		Evidence{Label: scope + " median rise", Value: cohortMedian * 100, Unit: "%"},
+		Evidence{Label: scope + " rise threshold", Value: threshold * 100, Unit: "%"},
+	)
+}
+
+// save записывает базовые линии во временный файл и заменяет им прежний
+func (h *HardwareInspector) save() {
+	if h.config.Path == "" {
+		return
+	}
+	h.saveMu.Lock()
+	defer h.saveMu.Unlock()
+
+	h.mu.Lock()
+	data, err := json.Marshal(h.daily)
+	h.mu.Unlock()
+	if err != nil {
+		log.Printf("Ошибка сериализации базовых линий простоя: %v", err)
+		return
+	}
+
+	tmp := h.config.Path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		log.Printf("Ошибка записи базовых линий простоя: %v", err)
+		return
+	}
+	if err := os.Rename(tmp, h.config.Path); err != nil {
+		log.Printf("Ошибка сохранения базовых линий простоя: %v", err)
+	}
+}
+
+// robustSpread возвращает медиану и оценку σ по медианному абсолютному
+// отклонению: сами отказывающие серверы не расширяют норму когорты
+func robustSpread(values []float64) (float64, float64) {
+	center := median(values)
+	deviations := make([]float64, len(values))
+	for i, v := range values {
+		deviations[i] = math.Abs(v - center)
+	}
+	return center, median(deviations) * madScale
+}
+
+func median(values []float64) float64 {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[mid-1] + sorted[mid]) / 2
+	}
+	return sorted[mid]
+}
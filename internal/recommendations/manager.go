@@ -0,0 +1,309 @@
+package recommendations
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/YumeNoTenshi/platypus/internal/errtrack"
+	"github.com/YumeNoTenshi/platypus/internal/metrics"
+	"github.com/YumeNoTenshi/platypus/internal/models"
+	"github.com/YumeNoTenshi/platypus/internal/requestid"
+	"github.com/YumeNoTenshi/platypus/internal/scheduler"
+)
+
+type ManagerConfig struct {
+	RefreshInterval time.Duration // Интервал опроса источников
+	ImpactWindow    time.Duration // Окно усреднения потребления до и после внедрения
+	// Errors получает исход опроса источников под именем "recommendations";
+	// необязателен
+	Errors errtrack.Recorder
+}
+
+// Filter ограничивает выборку рекомендаций; пустые поля не фильтруют
+type Filter struct {
+	State    State
+	Type     Type
+	TargetID string
+}
+
+type Manager struct {
+	config    ManagerConfig
+	collector *metrics.Collector
+	sources   []Source
+	mu        sync.RWMutex
+	items     map[string]*Recommendation // ID -> рекомендация
+	byKey     map[string]string          // ключ дедупликации -> ID
+	exempt    func(serverID string) bool
+}
+
+func NewManager(config ManagerConfig, collector *metrics.Collector, sources ...Source) *Manager {
+	return &Manager{
+		config:    config,
+		collector: collector,
+		sources:   sources,
+		items:     make(map[string]*Recommendation),
+		byKey:     make(map[string]string),
+	}
+}
+
+// Jobs возвращает периодический опрос источников и оценку эффекта
+// внедренных рекомендаций
+func (m *Manager) Jobs() []scheduler.Job {
+	return []scheduler.Job{{
+		Name:     "recommendations.refresh",
+		Interval: m.config.RefreshInterval,
+		Run: func(ctx context.Context) error {
+			m.refresh(ctx)
+			m.measureImpact()
+			return nil
+		},
+	}}
+}
+
+// SetExemptions подключает список серверов, для которых источники не
+// создают рекомендаций. Созданные вручную рекомендации не фильтруются.
+func (m *Manager) SetExemptions(exempt func(serverID string) bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.exempt = exempt
+}
+
+func (m *Manager) refresh(ctx context.Context) {
+	m.mu.RLock()
+	exempt := m.exempt
+	m.mu.RUnlock()
+
+	var errs []error
+	for _, source := range m.sources {
+		items, err := source.Recommendations(ctx)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("source %s: %w", source.Name(), err))
+			continue
+		}
+		for _, item := range items {
+			if exempt != nil && item.TargetKind == "server" && exempt(item.TargetID) {
+				continue
+			}
+			item.Source = source.Name()
+			m.upsert(item)
+		}
+	}
+	if m.config.Errors != nil {
+		m.config.Errors.Record("recommendations", errors.Join(errs...))
+	}
+}
+
+// upsert добавляет рекомендацию или обновляет оценку существующей.
+// Отклоненные и внедренные рекомендации не пересоздаются источником.
+func (m *Manager) upsert(item Recommendation) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if id, exists := m.byKey[item.key()]; exists {
+		existing := m.items[id]
+		if existing.State == StateOpen || existing.State == StateAccepted {
+			existing.Description = item.Description
+			existing.EstimatedSavingWatts = item.EstimatedSavingWatts
+			existing.Evidence = item.Evidence
+			existing.UpdatedAt = time.Now()
+		}
+		return
+	}
+
+	m.insertLocked(item)
+}
+
+func (m *Manager) insertLocked(item Recommendation) *Recommendation {
+	now := time.Now()
+	item.ID = newID()
+	item.State = StateOpen
+	item.CreatedAt = now
+	item.UpdatedAt = now
+
+	m.items[item.ID] = &item
+	m.byKey[item.key()] = item.ID
+	return &item
+}
+
+// Create добавляет рекомендацию вручную
+func (m *Manager) Create(item Recommendation) (Recommendation, error) {
+	if item.TargetID == "" || item.Title == "" {
+		return Recommendation{}, fmt.Errorf("target_id and title are required")
+	}
+	if item.Type == "" {
+		item.Type = TypeManual
+	}
+	if item.TargetKind == "" {
+		item.TargetKind = "server"
+	}
+	item.Source = "api"
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, exists := m.byKey[item.key()]; exists {
+		return Recommendation{}, fmt.Errorf("recommendation already exists for %s", item.key())
+	}
+	return *m.insertLocked(item), nil
+}
+
+func (m *Manager) Get(id string) (Recommendation, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	item, exists := m.items[id]
+	if !exists {
+		return Recommendation{}, fmt.Errorf("recommendation not found: %s", id)
+	}
+	return *item, nil
+}
+
+func (m *Manager) List(filter Filter) []Recommendation {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var result []Recommendation
+	for _, item := range m.items {
+		if filter.State != "" && item.State != filter.State {
+			continue
+		}
+		if filter.Type != "" && item.Type != filter.Type {
+			continue
+		}
+		if filter.TargetID != "" && item.TargetID != filter.TargetID {
+			continue
+		}
+		result = append(result, *item)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].EstimatedSavingWatts > result[j].EstimatedSavingWatts
+	})
+	return result
+}
+
+// SetState переводит рекомендацию в новое состояние. При внедрении фиксируется
+// базовое потребление цели для последующего измерения эффекта.
+func (m *Manager) SetState(ctx context.Context, id string, state State) (Recommendation, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	item, exists := m.items[id]
+	if !exists {
+		return Recommendation{}, fmt.Errorf("recommendation not found: %s", id)
+	}
+	if err := validateTransition(item.State, state); err != nil {
+		return Recommendation{}, err
+	}
+
+	now := time.Now()
+	if state == StateImplemented && item.State != StateImplemented {
+		item.ImplementedAt = &now
+		if item.TargetKind == "server" {
+			if before, ok := m.averagePower(item.TargetID, now.Add(-m.config.ImpactWindow), now); ok {
+				item.BaselineWatts = &before
+			}
+		}
+	}
+
+	item.State = state
+	item.UpdatedAt = now
+	item.RequestID = requestid.FromContext(ctx)
+	return *item, nil
+}
+
+func (m *Manager) Delete(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	item, exists := m.items[id]
+	if !exists {
+		return fmt.Errorf("recommendation not found: %s", id)
+	}
+	delete(m.byKey, item.key())
+	delete(m.items, id)
+	return nil
+}
+
+// measureImpact измеряет эффект рекомендаций, внедренных не менее ImpactWindow назад
+func (m *Manager) measureImpact() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	for _, item := range m.items {
+		if item.State != StateImplemented || item.MeasuredImpact != nil ||
+			item.BaselineWatts == nil || item.ImplementedAt == nil {
+			continue
+		}
+		if now.Sub(*item.ImplementedAt) < m.config.ImpactWindow {
+			continue
+		}
+
+		windowEnd := item.ImplementedAt.Add(m.config.ImpactWindow)
+		after, ok := m.averagePower(item.TargetID, *item.ImplementedAt, windowEnd)
+		if !ok {
+			// Цель перестала присылать метрики (например, сервер выключен) - экономия полная
+			after = 0
+		}
+		baselineKWh := *item.BaselineWatts * m.config.ImpactWindow.Hours() / 1000
+		item.MeasuredImpact = &Impact{
+			BeforeWatts: *item.BaselineWatts,
+			AfterWatts:  after,
+			SavingWatts: *item.BaselineWatts - after,
+			SavingKWh:   baselineKWh - m.energy(item.TargetID, *item.ImplementedAt, windowEnd),
+			MeasuredAt:  now,
+		}
+		// Эффект измеряется через ImpactWindow после запроса, отметившего внедрение
+		log.Printf("%sИзмерен эффект рекомендации %s: %.1f W", requestid.LogPrefix(item.RequestID), item.ID, item.MeasuredImpact.SavingWatts)
+	}
+}
+
+func (m *Manager) averagePower(serverID string, from, to time.Time) (float64, bool) {
+	data, err := m.collector.GetMetrics(serverID)
+	if err != nil {
+		return 0, false
+	}
+
+	var sum float64
+	var count int
+	for _, d := range data {
+		if d.Timestamp >= from.Unix() && d.Timestamp < to.Unix() {
+			sum += d.PowerUsage
+			count++
+		}
+	}
+	if count == 0 {
+		return 0, false
+	}
+	return sum / float64(count), true
+}
+
+// energy интегрирует потребление цели за [from, to), кВт*ч
+func (m *Manager) energy(serverID string, from, to time.Time) float64 {
+	data, err := m.collector.GetMetrics(serverID)
+	if err != nil {
+		return 0
+	}
+
+	var window []models.MetricData
+	for _, d := range data {
+		if d.Timestamp >= from.Unix() && d.Timestamp < to.Unix() {
+			window = append(window, d)
+		}
+	}
+	return metrics.TotalKWh(metrics.IntegrateEnergy(window, to.Sub(from), 0))
+}
+
+func newID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,189 @@
+package recommendations
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/YumeNoTenshi/platypus/internal/metrics"
+	"github.com/YumeNoTenshi/platypus/internal/migration"
+	"github.com/YumeNoTenshi/platypus/pkg/catalog"
+)
+
+// armPowerSaving - консервативная оценка снижения потребления при переходе на ARM
+const armPowerSaving = 0.2
+
+// IdleDetector рекомендует выключить или сократить серверы, простаивающие прямо сейчас
+type IdleDetector struct {
+	Collector    *metrics.Collector
+	Analyzer     *metrics.Analyzer
+	CPUThreshold float64
+	MinIdle      time.Duration
+}
+
+func (d *IdleDetector) Name() string { return "idle-detector" }
+
+func (d *IdleDetector) Recommendations(ctx context.Context) ([]Recommendation, error) {
+	var result []Recommendation
+	for _, serverID := range d.Collector.ServerIDs() {
+		windows, err := d.Analyzer.FindIdleWindows(serverID, d.CPUThreshold, d.MinIdle)
+		if err != nil || len(windows) == 0 {
+			continue
+		}
+
+		data, err := d.Collector.GetFilteredMetrics(serverID)
+		if err != nil || len(data) == 0 {
+			continue
+		}
+		last := windows[len(windows)-1]
+		if last.End.Unix() != data[len(data)-1].Timestamp {
+			continue
+		}
+
+		var power float64
+		var count int
+		for _, m := range data {
+			if m.Timestamp >= last.Start.Unix() {
+				power += m.PowerUsage
+				count++
+			}
+		}
+
+		result = append(result, Recommendation{
+			Type:       TypeIdle,
+			TargetID:   serverID,
+			TargetKind: "server",
+			Title:      "Shut down or scale in idle server",
+			Description: fmt.Sprintf("CPU below %.0f%% (avg %.1f%%) since %s",
+				d.CPUThreshold, last.AvgCPU, last.Start.Format(time.RFC3339)),
+			EstimatedSavingWatts: power / float64(count),
+		})
+	}
+	return result, nil
+}
+
+// RightSizer рекомендует меньший тип инстанса того же семейства для недогруженных серверов
+type RightSizer struct {
+	Collector    *metrics.Collector
+	Analyzer     *metrics.Analyzer
+	CPUThreshold float64 // Средняя загрузка CPU, ниже которой сервер избыточен
+}
+
+func (r *RightSizer) Name() string { return "right-sizer" }
+
+func (r *RightSizer) Recommendations(ctx context.Context) ([]Recommendation, error) {
+	var result []Recommendation
+	for _, serverID := range r.Collector.ServerIDs() {
+		spec, ok := r.instanceSpec(serverID)
+		if !ok {
+			continue
+		}
+		smaller, ok := catalog.SmallerInFamily(spec)
+		if !ok {
+			continue
+		}
+
+		avgCPU, ok := r.averageCPU(serverID)
+		if !ok || avgCPU >= r.CPUThreshold {
+			continue
+		}
+
+		// Та же нагрузка на меньшем инстансе дает пропорционально большую загрузку
+		targetCPU := avgCPU * float64(spec.VCPUs) / float64(smaller.VCPUs)
+		saving := spec.EstimatePower(avgCPU) - smaller.EstimatePower(targetCPU)
+		if saving <= 0 {
+			continue
+		}
+
+		result = append(result, Recommendation{
+			Type:                 TypeRightsize,
+			TargetID:             serverID,
+			TargetKind:           "server",
+			Title:                fmt.Sprintf("Downsize %s to %s", spec.Type, smaller.Type),
+			Description:          fmt.Sprintf("Average CPU %.1f%% would become %.1f%% on %s", avgCPU, targetCPU, smaller.Type),
+			EstimatedSavingWatts: saving,
+		})
+	}
+	return result, nil
+}
+
+func (r *RightSizer) instanceSpec(serverID string) (catalog.InstanceSpec, bool) {
+	instanceType, ok := r.Analyzer.InstanceType(serverID)
+	if !ok {
+		return catalog.InstanceSpec{}, false
+	}
+	return catalog.Lookup(instanceType)
+}
+
+func (r *RightSizer) averageCPU(serverID string) (float64, bool) {
+	data, err := r.Collector.GetFilteredMetrics(serverID)
+	if err != nil || len(data) == 0 {
+		return 0, false
+	}
+	var sum float64
+	for _, m := range data {
+		sum += m.CPUUsage
+	}
+	return sum / float64(len(data)), true
+}
+
+// ARMAdvisor рекомендует перевод x86-инстансов на ARM-аналоги
+type ARMAdvisor struct {
+	Collector *metrics.Collector
+	Analyzer  *metrics.Analyzer
+}
+
+func (a *ARMAdvisor) Name() string { return "arm-advisor" }
+
+func (a *ARMAdvisor) Recommendations(ctx context.Context) ([]Recommendation, error) {
+	sizer := &RightSizer{Collector: a.Collector, Analyzer: a.Analyzer}
+
+	var result []Recommendation
+	for _, serverID := range a.Collector.ServerIDs() {
+		spec, ok := sizer.instanceSpec(serverID)
+		if !ok || spec.ARM {
+			continue
+		}
+		armType, ok := catalog.ARMEquivalent(spec)
+		if !ok {
+			continue
+		}
+		avgCPU, ok := sizer.averageCPU(serverID)
+		if !ok {
+			continue
+		}
+
+		result = append(result, Recommendation{
+			Type:                 TypeARM,
+			TargetID:             serverID,
+			TargetKind:           "server",
+			Title:                fmt.Sprintf("Move %s to ARM instance %s", spec.Type, armType),
+			Description:          "Requires arm64 container images for all workloads on this server",
+			EstimatedSavingWatts: spec.EstimatePower(avgCPU) * armPowerSaving,
+		})
+	}
+	return result, nil
+}
+
+// ConsolidationPlanner превращает планы миграции в рекомендации
+type ConsolidationPlanner struct {
+	Planner *migration.Planner
+}
+
+func (c *ConsolidationPlanner) Name() string { return "consolidation-planner" }
+
+func (c *ConsolidationPlanner) Recommendations(ctx context.Context) ([]Recommendation, error) {
+	var result []Recommendation
+	for _, plan := range c.Planner.Plans() {
+		result = append(result, Recommendation{
+			Type:       TypeConsolidation,
+			TargetID:   plan.ContainerID,
+			TargetKind: "container",
+			Title:      fmt.Sprintf("Migrate container from %s to %s", plan.SourceServerID, plan.TargetServerID),
+			Description: fmt.Sprintf("Priority %d, estimated downtime %s",
+				plan.Priority, plan.DowntimeEstimate),
+			EstimatedSavingWatts: plan.PowerSaving,
+		})
+	}
+	return result, nil
+}
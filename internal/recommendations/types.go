@@ -0,0 +1,100 @@
+// Package recommendations объединяет рекомендации по энергосбережению из разных
+// источников (right-sizer, ARM-советник, детектор простоя, планировщик консолидации)
+// в единый входящий список с жизненным циклом и измерением фактического эффекта
+package recommendations
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// State - состояние рекомендации
+type State string
+
+const (
+	StateOpen        State = "open"
+	StateAccepted    State = "accepted"
+	StateDismissed   State = "dismissed"
+	StateImplemented State = "implemented"
+)
+
+// Type - вид рекомендации
+type Type string
+
+const (
+	TypeRightsize     Type = "rightsize"
+	TypeARM           Type = "arm"
+	TypeIdle          Type = "idle"
+	TypeConsolidation Type = "consolidation"
+	TypeManual        Type = "manual"
+)
+
+// Impact - фактический эффект внедренной рекомендации
+type Impact struct {
+	BeforeWatts float64   `json:"before_watts"`
+	AfterWatts  float64   `json:"after_watts"`
+	SavingWatts float64   `json:"saving_watts"`
+	SavingKWh   float64   `json:"saving_kwh"` // Сэкономленная энергия за окно измерения
+	MeasuredAt  time.Time `json:"measured_at"`
+}
+
+// Evidence - наблюдение, на котором основана рекомендация; From и To -
+// интервал, к которому оно относится
+type Evidence struct {
+	Label string    `json:"label"`
+	Value float64   `json:"value"`
+	Unit  string    `json:"unit,omitempty"`
+	From  time.Time `json:"from,omitempty"`
+	To    time.Time `json:"to,omitempty"`
+}
+
+type Recommendation struct {
+	ID                   string     `json:"id"`
+	Type                 Type       `json:"type"`
+	Source               string     `json:"source"`
+	TargetID             string     `json:"target_id"`   // Сервер или сервис, к которому относится рекомендация
+	TargetKind           string     `json:"target_kind"` // server | service | container
+	Title                string     `json:"title"`
+	Description          string     `json:"description"`
+	EstimatedSavingWatts float64    `json:"estimated_saving_watts"`
+	State                State      `json:"state"`
+	CreatedAt            time.Time  `json:"created_at"`
+	UpdatedAt            time.Time  `json:"updated_at"`
+	ImplementedAt        *time.Time `json:"implemented_at,omitempty"`
+	BaselineWatts        *float64   `json:"baseline_watts,omitempty"` // Потребление цели до внедрения
+	MeasuredImpact       *Impact    `json:"measured_impact,omitempty"`
+	Evidence             []Evidence `json:"evidence,omitempty"`   // Данные, подтверждающие рекомендацию
+	RequestID            string     `json:"request_id,omitempty"` // Запрос API, последним изменивший состояние
+}
+
+// key идентифицирует рекомендацию для дедупликации между запусками источников
+func (r Recommendation) key() string {
+	return string(r.Type) + "/" + r.TargetKind + "/" + r.TargetID
+}
+
+// Source - генератор рекомендаций
+type Source interface {
+	Name() string
+	Recommendations(ctx context.Context) ([]Recommendation, error)
+}
+
+// transitions - допустимые переходы между состояниями
+var transitions = map[State][]State{
+	StateOpen:        {StateAccepted, StateDismissed, StateImplemented},
+	StateAccepted:    {StateImplemented, StateDismissed, StateOpen},
+	StateDismissed:   {StateOpen},
+	StateImplemented: {},
+}
+
+func validateTransition(from, to State) error {
+	if from == to {
+		return nil
+	}
+	for _, allowed := range transitions[from] {
+		if allowed == to {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid state transition: %s -> %s", from, to)
+}
@@ -0,0 +1,147 @@
+package remotewrite
+
+import (
+	"encoding/binary"
+	"math"
+	"sort"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// label и series повторяют сообщения prometheus.Label и prometheus.TimeSeries
+// протокола remote write 1.0
+type label struct {
+	name, value string
+}
+
+type sample struct {
+	value     float64
+	timestamp int64 // Миллисекунды Unix
+}
+
+type series struct {
+	labels  []label // Упорядочены по имени
+	samples []sample
+}
+
+// encodeWriteRequest кодирует prometheus.WriteRequest:
+//
+//	message WriteRequest { repeated TimeSeries timeseries = 1; }
+//	message TimeSeries   { repeated Label labels = 1; repeated Sample samples = 2; }
+//	message Label        { string name = 1; string value = 2; }
+//	message Sample       { double value = 1; int64 timestamp = 2; }
+func encodeWriteRequest(all []series) []byte {
+	var request []byte
+	for _, s := range all {
+		var ts []byte
+		for _, l := range s.labels {
+			var encoded []byte
+			encoded = protowire.AppendTag(encoded, 1, protowire.BytesType)
+			encoded = protowire.AppendString(encoded, l.name)
+			encoded = protowire.AppendTag(encoded, 2, protowire.BytesType)
+			encoded = protowire.AppendString(encoded, l.value)
+
+			ts = protowire.AppendTag(ts, 1, protowire.BytesType)
+			ts = protowire.AppendBytes(ts, encoded)
+		}
+		for _, smp := range s.samples {
+			var encoded []byte
+			encoded = protowire.AppendTag(encoded, 1, protowire.Fixed64Type)
+			encoded = protowire.AppendFixed64(encoded, math.Float64bits(smp.value))
+			encoded = protowire.AppendTag(encoded, 2, protowire.VarintType)
+			encoded = protowire.AppendVarint(encoded, uint64(smp.timestamp))
+
+			ts = protowire.AppendTag(ts, 2, protowire.BytesType)
+			ts = protowire.AppendBytes(ts, encoded)
+		}
+
+		request = protowire.AppendTag(request, 1, protowire.BytesType)
+		request = protowire.AppendBytes(request, ts)
+	}
+	return request
+}
+
+// sortLabels упорядочивает метки по имени, как требует протокол
+func sortLabels(labels []label) {
+	sort.Slice(labels, func(i, j int) bool { return labels[i].name < labels[j].name })
+}
+
+const (
+	snappyMaxOffset = 1<<16 - 1
+	snappyMinMatch  = 4
+	snappyTableBits = 14
+)
+
+// snappyEncode сжимает данные в блочном формате snappy, которого требует
+// remote write. Кодировщик простой: совпадения ищутся по хешу четырех байт и
+// записываются копиями с двухбайтовым смещением. Сжатие хуже эталонного, но
+// поток читается любым декодером snappy.
+func snappyEncode(src []byte) []byte {
+	dst := binary.AppendUvarint(make([]byte, 0, len(src)/2+16), uint64(len(src)))
+	if len(src) < snappyMinMatch {
+		return appendLiteral(dst, src)
+	}
+
+	var table [1 << snappyTableBits]int32
+	for i := range table {
+		table[i] = -1
+	}
+	hash := func(u uint32) uint32 {
+		return (u * 0x1e35a7bd) >> (32 - snappyTableBits)
+	}
+	load := func(i int) uint32 {
+		return binary.LittleEndian.Uint32(src[i:])
+	}
+
+	literalStart := 0
+	for i := 0; i+snappyMinMatch <= len(src); {
+		h := hash(load(i))
+		candidate := int(table[h])
+		table[h] = int32(i)
+		if candidate < 0 || i-candidate > snappyMaxOffset || load(candidate) != load(i) {
+			i++
+			continue
+		}
+
+		length := snappyMinMatch
+		for i+length < len(src) && src[candidate+length] == src[i+length] {
+			length++
+		}
+		dst = appendLiteral(dst, src[literalStart:i])
+		dst = appendCopy(dst, i-candidate, length)
+		i += length
+		literalStart = i
+	}
+	return appendLiteral(dst, src[literalStart:])
+}
+
+func appendLiteral(dst, literal []byte) []byte {
+	for len(literal) > 0 {
+		chunk := literal
+		if len(chunk) > 1<<16 {
+			chunk = chunk[:1<<16]
+		}
+		n := len(chunk) - 1
+		switch {
+		case n < 60:
+			dst = append(dst, byte(n)<<2)
+		case n < 1<<8:
+			dst = append(dst, 60<<2, byte(n))
+		default:
+			dst = append(dst, 61<<2, byte(n), byte(n>>8))
+		}
+		dst = append(dst, chunk...)
+		literal = literal[len(chunk):]
+	}
+	return dst
+}
+
+// appendCopy записывает копию длины length со смещением offset блоками по 64 байта
+func appendCopy(dst []byte, offset, length int) []byte {
+	for length > 0 {
+		chunk := min(length, 64)
+		dst = append(dst, byte(chunk-1)<<2|2, byte(offset), byte(offset>>8))
+		length -= chunk
+	}
+	return dst
+}
@@ -0,0 +1,337 @@
+// Package remotewrite отправляет принятые точки метрик в хранилище,
+// совместимое с Prometheus remote write 1.0 (Prometheus, Thanos Receive,
+// Mimir, Cortex, VictoriaMetrics). Долгосрочная история тогда хранится вне
+// процесса, а серии называются так же, как датчики сборщика.
+package remotewrite
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/YumeNoTenshi/platypus/internal/metrics"
+	"github.com/YumeNoTenshi/platypus/internal/models"
+	"github.com/YumeNoTenshi/platypus/internal/scheduler"
+)
+
+type Config struct {
+	URL            string            // Адрес приема, например https://mimir.example.com/api/v1/push
+	Headers        map[string]string // Дополнительные заголовки, например X-Scope-OrgID для Mimir
+	BearerToken    string
+	Username       string // Basic-аутентификация, если нет BearerToken
+	Password       string
+	ExternalLabels map[string]string // Метки, добавляемые ко всем сериям, например cluster
+	// Labels - метки точек, которые становятся метками серий; обычно те же,
+	// что экспортирует сборщик (CollectorConfig.MetricLabels)
+	Labels           []string
+	QueueSize        int           // Сколько пакетов держать в очереди; при переполнении теряются самые старые
+	MaxSamples       int           // Максимум отсчетов в одном запросе
+	FlushInterval    time.Duration // Как часто отправлять накопленные пакеты
+	RetryInterval    time.Duration // Пауза перед первым повтором; удваивается до MaxRetryInterval
+	MaxRetryInterval time.Duration
+	Timeout          time.Duration
+}
+
+// Stats описывает состояние отправки для /status
+type Stats struct {
+	URL         string    `json:"url"`
+	Queued      int       `json:"queued"`
+	SentSamples uint64    `json:"sent_samples"`
+	Dropped     uint64    `json:"dropped"`  // Пакеты, вытесненные из переполненной очереди
+	Rejected    uint64    `json:"rejected"` // Отсчеты, отвергнутые хранилищем (ответ 4xx)
+	Failures    uint64    `json:"failures"`
+	LastError   string    `json:"last_error,omitempty"`
+	LastSuccess time.Time `json:"last_success,omitempty"`
+}
+
+// errRejected - хранилище отвергло запрос; повтор его не исправит
+type errRejected struct {
+	status  int
+	message string
+}
+
+func (e *errRejected) Error() string {
+	return fmt.Sprintf("remote write endpoint rejected samples with status %d: %s", e.status, e.message)
+}
+
+// Writer асинхронно отправляет сохраненные пакеты сборщика. Подписывается
+// на них через Collector.OnIngest и никогда не блокирует прием.
+type Writer struct {
+	config Config
+	client *http.Client
+	queue  chan metrics.MetricBatch
+
+	mu    sync.Mutex
+	stats Stats
+}
+
+func New(config Config) (*Writer, error) {
+	if config.URL == "" {
+		return nil, fmt.Errorf("remote write url is required")
+	}
+	if config.QueueSize <= 0 {
+		config.QueueSize = 10000
+	}
+	if config.MaxSamples <= 0 {
+		config.MaxSamples = 2000
+	}
+	if config.FlushInterval <= 0 {
+		config.FlushInterval = 5 * time.Second
+	}
+	if config.RetryInterval <= 0 {
+		config.RetryInterval = time.Second
+	}
+	if config.MaxRetryInterval < config.RetryInterval {
+		config.MaxRetryInterval = time.Minute
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = 30 * time.Second
+	}
+
+	return &Writer{
+		config: config,
+		client: &http.Client{Timeout: config.Timeout},
+		queue:  make(chan metrics.MetricBatch, config.QueueSize),
+		stats:  Stats{URL: config.URL},
+	}, nil
+}
+
+// Enqueue ставит пакет в очередь на отправку и никогда не блокирует сборщик
+func (w *Writer) Enqueue(batch metrics.MetricBatch) {
+	for {
+		select {
+		case w.queue <- batch:
+			return
+		default:
+		}
+
+		// Очередь переполнена: хранилище отстало, освобождаем место за счет самого старого пакета
+		select {
+		case <-w.queue:
+			w.mu.Lock()
+			w.stats.Dropped++
+			w.mu.Unlock()
+		default:
+		}
+	}
+}
+
+// Jobs возвращает отправку накопленных пакетов раз в FlushInterval
+func (w *Writer) Jobs() []scheduler.Job {
+	return []scheduler.Job{{
+		Name:     "remotewrite.flush",
+		Interval: w.config.FlushInterval,
+		Run:      w.flush,
+	}}
+}
+
+// flush отправляет очередь запросами не больше MaxSamples отсчетов
+func (w *Writer) flush(ctx context.Context) error {
+	for {
+		request, samples := w.drain()
+		if samples == 0 {
+			return nil
+		}
+		if err := w.sendWithRetry(ctx, request, samples); err != nil {
+			return err
+		}
+	}
+}
+
+// Stats возвращает текущее состояние отправки
+func (w *Writer) Stats() Stats {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	stats := w.stats
+	stats.Queued = len(w.queue)
+	return stats
+}
+
+// drain забирает из очереди пакеты, пока в запросе меньше MaxSamples
+// отсчетов, и возвращает тело запроса
+func (w *Writer) drain() ([]byte, int) {
+	var all []series
+	index := make(map[string]int) // Отсчеты одной серии собираются вместе
+	samples := 0
+	for samples < w.config.MaxSamples {
+		select {
+		case batch := <-w.queue:
+			for _, m := range batch.Metrics {
+				for _, s := range w.series(batch.ServerID, m) {
+					key := seriesKey(s.labels)
+					if i, exists := index[key]; exists {
+						all[i].samples = append(all[i].samples, s.samples...)
+					} else {
+						index[key] = len(all)
+						all = append(all, s)
+					}
+					samples += len(s.samples)
+				}
+			}
+		default:
+			return snappyEncode(encodeWriteRequest(all)), samples
+		}
+	}
+	return snappyEncode(encodeWriteRequest(all)), samples
+}
+
+func seriesKey(labels []label) string {
+	var key strings.Builder
+	for _, l := range labels {
+		key.WriteString(l.name)
+		key.WriteByte(0)
+		key.WriteString(l.value)
+		key.WriteByte(0)
+	}
+	return key.String()
+}
+
+// series переводит точку в серии с теми же именами, что у датчиков сборщика.
+// Серии GPU, дисков и температуры отправляются только при ненулевых значениях.
+func (w *Writer) series(serverID string, m models.MetricData) []series {
+	base := []label{{name: "server_id", value: serverID}}
+	if m.TenantID != "" {
+		base = append(base, label{name: "tenant_id", value: m.TenantID})
+	}
+	if m.ContainerID != "" {
+		base = append(base, label{name: "container_id", value: m.ContainerID})
+	}
+	for _, name := range w.config.Labels {
+		if value := m.Labels[name]; value != "" {
+			base = append(base, label{name: name, value: value})
+		}
+	}
+	for name, value := range w.config.ExternalLabels {
+		base = append(base, label{name: name, value: value})
+	}
+
+	values := []struct {
+		name  string
+		value float64
+		send  bool
+	}{
+		{"server_power_usage_watts", m.PowerUsage, true},
+		{"server_carbon_footprint_kg", m.CarbonFootprint, true},
+		{"server_cpu_usage_percent", m.CPUUsage, true},
+		{"server_memory_usage_percent", m.MemoryUsage, true},
+		{"server_gpu_usage_percent", m.GPUUsage, m.GPUUsage > 0 || m.GPUPowerUsage > 0},
+		{"server_gpu_power_usage_watts", m.GPUPowerUsage, m.GPUUsage > 0 || m.GPUPowerUsage > 0},
+		{"server_disk_read_bytes_per_second", m.DiskReadBytesPerSec, m.DiskReadBytesPerSec > 0},
+		{"server_disk_write_bytes_per_second", m.DiskWriteBytesPerSec, m.DiskWriteBytesPerSec > 0},
+		{"server_storage_used_bytes", m.StorageUsedBytes, m.StorageUsedBytes > 0},
+		{"server_inlet_temperature_celsius", m.InletTemperature, m.InletTemperature != 0},
+	}
+
+	timestamp := m.Timestamp * 1000
+	result := make([]series, 0, len(values))
+	for _, v := range values {
+		if !v.send {
+			continue
+		}
+		labels := make([]label, 0, len(base)+1)
+		labels = append(labels, label{name: "__name__", value: v.name})
+		labels = append(labels, base...)
+		sortLabels(labels)
+		result = append(result, series{labels: labels, samples: []sample{{value: v.value, timestamp: timestamp}}})
+	}
+	return result
+}
+
+// sendWithRetry повторяет отправку при сетевых ошибках, 5xx и 429 с растущей
+// паузой. Запрос, отвергнутый с другим кодом 4xx, отбрасывается: повтор не
+// исправит некорректные данные, а очередь не должна на нем застревать.
+func (w *Writer) sendWithRetry(ctx context.Context, body []byte, samples int) error {
+	backoff := w.config.RetryInterval
+	for {
+		err := w.send(ctx, body)
+
+		var rejected *errRejected
+		isRejected := errors.As(err, &rejected)
+
+		w.mu.Lock()
+		switch {
+		case err == nil:
+			w.stats.SentSamples += uint64(samples)
+			w.stats.LastSuccess = time.Now()
+			w.stats.LastError = ""
+		case isRejected:
+			w.stats.Rejected += uint64(samples)
+			w.stats.LastError = err.Error()
+		default:
+			w.stats.Failures++
+			w.stats.LastError = err.Error()
+		}
+		w.mu.Unlock()
+
+		if err == nil || isRejected {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, w.config.MaxRetryInterval)
+	}
+}
+
+func (w *Writer) send(ctx context.Context, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.config.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+	req.Header.Set("User-Agent", "platypus-remote-write")
+	for name, value := range w.config.Headers {
+		req.Header.Set(name, value)
+	}
+	switch {
+	case w.config.BearerToken != "":
+		req.Header.Set("Authorization", "Bearer "+w.config.BearerToken)
+	case w.config.Username != "":
+		req.SetBasicAuth(w.config.Username, w.config.Password)
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 == 2 {
+		return nil
+	}
+	message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	if resp.StatusCode/100 == 4 && resp.StatusCode != http.StatusTooManyRequests {
+		return &errRejected{status: resp.StatusCode, message: strings.TrimSpace(string(message))}
+	}
+	return fmt.Errorf("remote write endpoint responded with status %d: %s", resp.StatusCode, strings.TrimSpace(string(message)))
+}
+
+// ParseExternalLabels разбирает метки вида "cluster=eu-1,replica=a"
+func ParseExternalLabels(value string) (map[string]string, error) {
+	labels := make(map[string]string)
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, labelValue, found := strings.Cut(part, "=")
+		name = strings.TrimSpace(name)
+		if !found || name == "" || strings.HasPrefix(name, "__") {
+			return nil, fmt.Errorf("invalid external label %q: expected name=value", part)
+		}
+		labels[name] = strings.TrimSpace(labelValue)
+	}
+	return labels, nil
+}
@@ -0,0 +1,93 @@
+package replication
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/YumeNoTenshi/platypus/internal/metrics"
+)
+
+// ErrSnapshotRequired означает, что резерв не может применить приращение без полного снимка
+var ErrSnapshotRequired = errors.New("snapshot required before incremental batches")
+
+// ReceiverStats описывает состояние репликации на резервном инстансе
+type ReceiverStats struct {
+	SourceID        string    `json:"source_id,omitempty"`
+	Sequence        uint64    `json:"sequence"`
+	AppliedBatches  uint64    `json:"applied_batches"`
+	Duplicates      uint64    `json:"duplicates"`
+	SnapshotApplied bool      `json:"snapshot_applied"`
+	LastReceived    time.Time `json:"last_received,omitempty"`
+	LagSeconds      float64   `json:"lag_seconds"` // Задержка между сохранением пакета на основном инстансе и его применением
+}
+
+// Receiver работает на резервном инстансе и применяет конверты основного
+type Receiver struct {
+	collector *metrics.Collector
+	mu        sync.Mutex
+	stats     ReceiverStats
+}
+
+func NewReceiver(collector *metrics.Collector) *Receiver {
+	return &Receiver{collector: collector}
+}
+
+// Apply применяет конверт. Повторно доставленный конверт подтверждается без изменений;
+// снимок сбрасывает ожидаемую последовательность, так как основной инстанс перезапустился.
+func (r *Receiver) Apply(envelope Envelope) error {
+	if envelope.SourceID == "" {
+		return fmt.Errorf("source_id is required")
+	}
+	if envelope.Sequence == 0 {
+		return fmt.Errorf("sequence is required")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !envelope.Snapshot && envelope.SourceID == r.stats.SourceID && envelope.Sequence <= r.stats.Sequence {
+		r.stats.Duplicates++
+		return nil
+	}
+	if !envelope.Snapshot && (envelope.SourceID != r.stats.SourceID || !r.stats.SnapshotApplied) {
+		return ErrSnapshotRequired
+	}
+
+	for _, batch := range envelope.Batches {
+		if envelope.Snapshot {
+			if err := r.collector.ReplaceMetrics(batch.ServerID, batch.Metrics); err != nil {
+				return err
+			}
+			continue
+		}
+		err := r.collector.Ingest(metrics.MetricBatch{
+			ServerID:  batch.ServerID,
+			Metrics:   batch.Metrics,
+			Timestamp: batch.IngestedAt,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	now := time.Now()
+	r.stats.SourceID = envelope.SourceID
+	r.stats.Sequence = envelope.Sequence
+	r.stats.AppliedBatches += uint64(len(envelope.Batches))
+	r.stats.LastReceived = now
+	if envelope.Snapshot {
+		r.stats.SnapshotApplied = true
+	}
+	if len(envelope.Batches) > 0 {
+		r.stats.LagSeconds = now.Sub(envelope.Batches[0].IngestedAt).Seconds()
+	}
+	return nil
+}
+
+func (r *Receiver) Stats() ReceiverStats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.stats
+}
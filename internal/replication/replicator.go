@@ -0,0 +1,258 @@
+package replication
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/YumeNoTenshi/platypus/internal/metrics"
+)
+
+type ReplicatorConfig struct {
+	SourceID      string
+	StandbyURL    string // Базовый адрес резервного инстанса, например https://standby.example.com
+	APIKey        string
+	QueueSize     int           // Сколько пакетов держать в очереди; при переполнении теряются самые старые
+	MaxBatches    int           // Максимум пакетов в одном конверте
+	FlushInterval time.Duration // Как часто отправлять накопленные пакеты
+	RetryInterval time.Duration // Пауза между повторами неудачной отправки
+	Timeout       time.Duration
+}
+
+// ReplicatorStats описывает состояние репликации на основном инстансе
+type ReplicatorStats struct {
+	StandbyURL   string    `json:"standby_url"`
+	Sequence     uint64    `json:"sequence"`
+	Queued       int       `json:"queued"`
+	SentBatches  uint64    `json:"sent_batches"`
+	Dropped      uint64    `json:"dropped"`
+	Failures     uint64    `json:"failures"`
+	LastError    string    `json:"last_error,omitempty"`
+	LastSuccess  time.Time `json:"last_success,omitempty"`
+	SnapshotSent bool      `json:"snapshot_sent"`
+	LagSeconds   float64   `json:"lag_seconds"` // Возраст самого старого неподтвержденного пакета
+}
+
+type pending struct {
+	batch metrics.MetricBatch
+	at    time.Time
+}
+
+// Replicator работает на основном инстансе: асинхронно передает сохраненные
+// пакеты метрик резервному инстансу, чтобы тот держал то же окно в памяти
+type Replicator struct {
+	config    ReplicatorConfig
+	collector *metrics.Collector
+	client    *http.Client
+	queue     chan pending
+
+	mu            sync.Mutex
+	stats         ReplicatorStats
+	inFlightSince time.Time
+	snapshotAt    time.Time
+}
+
+func NewReplicator(config ReplicatorConfig, collector *metrics.Collector) *Replicator {
+	if config.QueueSize <= 0 {
+		config.QueueSize = 10000
+	}
+	if config.MaxBatches <= 0 {
+		config.MaxBatches = 500
+	}
+	if config.FlushInterval <= 0 {
+		config.FlushInterval = time.Second
+	}
+	if config.RetryInterval <= 0 {
+		config.RetryInterval = 5 * time.Second
+	}
+
+	return &Replicator{
+		config:    config,
+		collector: collector,
+		client:    &http.Client{Timeout: config.Timeout},
+		queue:     make(chan pending, config.QueueSize),
+		stats:     ReplicatorStats{StandbyURL: config.StandbyURL},
+	}
+}
+
+// Enqueue ставит пакет в очередь на репликацию и никогда не блокирует сборщик
+func (r *Replicator) Enqueue(batch metrics.MetricBatch) {
+	item := pending{batch: batch, at: time.Now()}
+	for {
+		select {
+		case r.queue <- item:
+			return
+		default:
+		}
+
+		// Очередь переполнена: резерв отстал, освобождаем место за счет самого старого пакета
+		select {
+		case <-r.queue:
+			r.mu.Lock()
+			r.stats.Dropped++
+			r.mu.Unlock()
+		default:
+		}
+	}
+}
+
+// Start отправляет резерву снимок текущего окна, затем передает новые пакеты
+func (r *Replicator) Start(ctx context.Context) error {
+	if err := r.sendWithRetry(ctx, r.snapshot(), time.Now()); err != nil {
+		return err
+	}
+
+	ticker := time.NewTicker(r.config.FlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			for {
+				batches, oldest := r.drain()
+				if len(batches) == 0 {
+					break
+				}
+				if err := r.sendWithRetry(ctx, Envelope{Batches: batches}, oldest); err != nil {
+					return err
+				}
+			}
+		}
+	}
+}
+
+// Stats возвращает текущее состояние репликации
+func (r *Replicator) Stats() ReplicatorStats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stats := r.stats
+	stats.Queued = len(r.queue)
+	if !r.inFlightSince.IsZero() {
+		stats.LagSeconds = time.Since(r.inFlightSince).Seconds()
+	}
+	return stats
+}
+
+func (r *Replicator) snapshot() Envelope {
+	envelope := Envelope{Snapshot: true}
+	now := time.Now()
+
+	r.mu.Lock()
+	r.snapshotAt = now
+	r.mu.Unlock()
+	for serverID, data := range r.collector.Snapshot() {
+		envelope.Batches = append(envelope.Batches, Batch{ServerID: serverID, Metrics: data, IngestedAt: now})
+	}
+	return envelope
+}
+
+// drain забирает из очереди до MaxBatches пакетов
+func (r *Replicator) drain() ([]Batch, time.Time) {
+	var batches []Batch
+	var oldest time.Time
+	for len(batches) < r.config.MaxBatches {
+		select {
+		case item := <-r.queue:
+			r.mu.Lock()
+			covered := item.at.Before(r.snapshotAt)
+			r.mu.Unlock()
+			if covered {
+				// Пакет сохранен до снимка и уже передан в его составе
+				continue
+			}
+			if oldest.IsZero() {
+				oldest = item.at
+			}
+			batches = append(batches, Batch{ServerID: item.batch.ServerID, Metrics: item.batch.Metrics, IngestedAt: item.at})
+		default:
+			return batches, oldest
+		}
+	}
+	return batches, oldest
+}
+
+// sendWithRetry повторяет отправку конверта, пока резерв его не примет.
+// Номер конверта не меняется между повторами, поэтому резерв не применит его дважды.
+// Если резерв потерял окно, вместо конверта отправляется новый снимок: он уже содержит эти пакеты.
+func (r *Replicator) sendWithRetry(ctx context.Context, envelope Envelope, oldest time.Time) error {
+	r.mu.Lock()
+	r.stats.Sequence++
+	envelope.Sequence = r.stats.Sequence
+	r.inFlightSince = oldest
+	r.mu.Unlock()
+
+	envelope.SourceID = r.config.SourceID
+	for {
+		envelope.SentAt = time.Now()
+		err := r.send(ctx, envelope)
+
+		r.mu.Lock()
+		if err == nil {
+			r.stats.SentBatches += uint64(len(envelope.Batches))
+			r.stats.LastSuccess = time.Now()
+			r.stats.LastError = ""
+			if envelope.Snapshot {
+				r.stats.SnapshotSent = true
+			}
+			r.inFlightSince = time.Time{}
+		} else {
+			r.stats.Failures++
+			r.stats.LastError = err.Error()
+		}
+		r.mu.Unlock()
+
+		if err == nil {
+			return nil
+		}
+
+		if errors.Is(err, ErrSnapshotRequired) {
+			sequence := envelope.Sequence
+			envelope = r.snapshot()
+			envelope.SourceID = r.config.SourceID
+			envelope.Sequence = sequence
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(r.config.RetryInterval):
+		}
+	}
+}
+
+func (r *Replicator) send(ctx context.Context, envelope Envelope) error {
+	body, err := json.Marshal(envelope)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.config.StandbyURL+"/api/v1/replication/batches", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-API-Key", r.config.APIKey)
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusConflict {
+		return ErrSnapshotRequired
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("standby instance responded with status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,34 @@
+package replication
+
+import (
+	"time"
+
+	"github.com/YumeNoTenshi/platypus/internal/models"
+)
+
+// Mode определяет роль инстанса в репликации
+type Mode string
+
+const (
+	ModeDisabled Mode = ""
+	ModePrimary  Mode = "primary"
+	ModeStandby  Mode = "standby"
+)
+
+// Batch - пакет метрик одного сервера в том виде, в котором его сохранил основной инстанс
+type Batch struct {
+	ServerID   string              `json:"server_id"`
+	Metrics    []models.MetricData `json:"metrics"`
+	IngestedAt time.Time           `json:"ingested_at"`
+}
+
+// Envelope - единица передачи между основным и резервным инстансом.
+// Sequence монотонно растет, повторно доставленные конверты игнорируются.
+// Snapshot означает полный снимок окна: данные серверов заменяются, а не дополняются.
+type Envelope struct {
+	SourceID string    `json:"source_id"`
+	Sequence uint64    `json:"sequence"`
+	Snapshot bool      `json:"snapshot"`
+	SentAt   time.Time `json:"sent_at"`
+	Batches  []Batch   `json:"batches"`
+}
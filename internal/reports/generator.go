@@ -0,0 +1,208 @@
+// Package reports формирует регулярные отчеты об энергоэффективности
+// из разделов, которые регистрируют подсистемы платформы
+package reports
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/YumeNoTenshi/platypus/internal/scheduler"
+)
+
+// SectionFunc формирует раздел отчета за период [from, to). Раздел,
+// который умеет сужаться до команды или региона, учитывает filter; пустой
+// фильтр - весь парк.
+type SectionFunc func(from, to time.Time, filter Filter) (interface{}, error)
+
+// Filter сужает отчет до команды или региона
+type Filter struct {
+	Team   string `json:"team,omitempty"`
+	Region string `json:"region,omitempty"`
+}
+
+// Empty сообщает, что фильтр не сужает отчет
+func (f Filter) Empty() bool {
+	return f == Filter{}
+}
+
+type Report struct {
+	ID          string                 `json:"id"`
+	Type        string                 `json:"type"`
+	PeriodStart time.Time              `json:"period_start"`
+	PeriodEnd   time.Time              `json:"period_end"`
+	GeneratedAt time.Time              `json:"generated_at"`
+	Filter      *Filter                `json:"filter,omitempty"`
+	Sections    map[string]interface{} `json:"sections"`
+	Errors      map[string]string      `json:"errors,omitempty"` // Разделы, которые не удалось сформировать
+	// Summary - резюме отчета для руководителей; SummarySource - кто его
+	// составил: template или model
+	Summary       string `json:"summary,omitempty"`
+	SummarySource string `json:"summary_source,omitempty"`
+}
+
+type GeneratorConfig struct {
+	Type     string        // Название отчета, например "weekly"
+	Interval time.Duration // Период отчета и интервал формирования
+	Retain   int           // Сколько последних отчетов хранить
+}
+
+type section struct {
+	name string
+	fn   SectionFunc
+}
+
+type Generator struct {
+	config   GeneratorConfig
+	mu       sync.RWMutex
+	sections []section
+	reports  []Report
+
+	summarizer Summarizer // Необязателен; без него отчеты без резюме
+}
+
+func NewGenerator(config GeneratorConfig) *Generator {
+	return &Generator{config: config}
+}
+
+// AddSection регистрирует раздел; разделы выводятся в порядке регистрации
+func (g *Generator) AddSection(name string, fn SectionFunc) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.sections = append(g.sections, section{name: name, fn: fn})
+}
+
+// SetSummarizer включает резюме отчетов. Если резюме модели не удалось,
+// отчет получает резюме по шаблону, а ошибка попадает в Errors["summary"].
+func (g *Generator) SetSummarizer(summarizer Summarizer) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.summarizer = summarizer
+}
+
+// Jobs возвращает формирование отчета раз в период
+func (g *Generator) Jobs() []scheduler.Job {
+	return []scheduler.Job{{
+		Name:     "reports.generate",
+		Interval: g.config.Interval,
+		Run: func(ctx context.Context) error {
+			g.Generate()
+			return nil
+		},
+	}}
+}
+
+// Generate формирует отчет за последний период и сохраняет его
+func (g *Generator) Generate() Report {
+	g.mu.RLock()
+	var previous *Report
+	if len(g.reports) > 0 {
+		last := g.reports[len(g.reports)-1]
+		previous = &last
+	}
+	g.mu.RUnlock()
+
+	end := time.Now()
+	report := g.Build(g.config.Type, end.Add(-g.config.Interval), end, Filter{}, previous)
+
+	g.mu.Lock()
+	g.reports = append(g.reports, report)
+	if g.config.Retain > 0 && len(g.reports) > g.config.Retain {
+		g.reports = g.reports[len(g.reports)-g.config.Retain:]
+	}
+	g.mu.Unlock()
+
+	return report
+}
+
+// Build формирует отчет за [from, to), суженный фильтром, не сохраняя его.
+// previous - прошлый отчет того же вида для сравнения в резюме.
+func (g *Generator) Build(reportType string, from, to time.Time, filter Filter, previous *Report) Report {
+	g.mu.RLock()
+	sections := append([]section(nil), g.sections...)
+	summarizer := g.summarizer
+	g.mu.RUnlock()
+
+	report := Report{
+		ID:          newID(),
+		Type:        reportType,
+		PeriodStart: from,
+		PeriodEnd:   to,
+		GeneratedAt: time.Now(),
+		Sections:    make(map[string]interface{}, len(sections)),
+	}
+	if !filter.Empty() {
+		report.Filter = &filter
+	}
+
+	for _, s := range sections {
+		data, err := s.fn(from, to, filter)
+		if err != nil {
+			if report.Errors == nil {
+				report.Errors = make(map[string]string)
+			}
+			report.Errors[s.name] = err.Error()
+			continue
+		}
+		report.Sections[s.name] = data
+	}
+	if summarizer != nil {
+		g.summarize(summarizer, &report, previous)
+	}
+	return report
+}
+
+func (g *Generator) summarize(summarizer Summarizer, report *Report, previous *Report) {
+	summary, err := summarizer.Summarize(context.Background(), *report, previous)
+	if err == nil {
+		report.Summary, report.SummarySource = summary, SummaryModel
+		if _, isTemplate := summarizer.(TemplateSummarizer); isTemplate {
+			report.SummarySource = SummaryTemplate
+		}
+		return
+	}
+
+	if _, isTemplate := summarizer.(TemplateSummarizer); !isTemplate {
+		if report.Errors == nil {
+			report.Errors = make(map[string]string)
+		}
+		report.Errors["summary"] = err.Error()
+	}
+	// Отчет без итоговых чисел остается без резюме
+	if summary, err := (TemplateSummarizer{}).Summarize(context.Background(), *report, previous); err == nil {
+		report.Summary, report.SummarySource = summary, SummaryTemplate
+	}
+}
+
+// List возвращает сохраненные отчеты, от новых к старым
+func (g *Generator) List() []Report {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	reports := make([]Report, 0, len(g.reports))
+	for i := len(g.reports) - 1; i >= 0; i-- {
+		reports = append(reports, g.reports[i])
+	}
+	return reports
+}
+
+func (g *Generator) Get(id string) (Report, error) {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	for _, report := range g.reports {
+		if report.ID == id {
+			return report, nil
+		}
+	}
+	return Report{}, fmt.Errorf("report not found: %s", id)
+}
+
+func newID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
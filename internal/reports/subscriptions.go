@@ -0,0 +1,427 @@
+package reports
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/YumeNoTenshi/platypus/internal/scheduler"
+	"github.com/YumeNoTenshi/platypus/pkg/schedule"
+)
+
+var ErrSubscriptionNotFound = errors.New("report subscription not found")
+
+// Виды отчетов подписки: период отчета и расписание по умолчанию
+const (
+	TypeDaily   = "daily"
+	TypeWeekly  = "weekly"
+	TypeMonthly = "monthly"
+)
+
+var defaultSchedules = map[string]string{
+	TypeDaily:   "0 8 * * *", // Каждый день в 08:00
+	TypeWeekly:  "0 8 * * 1", // По понедельникам в 08:00
+	TypeMonthly: "0 8 1 * *", // Первого числа в 08:00
+}
+
+// Каналы доставки подписки
+const (
+	ChannelSlack   = "slack"   // Входящий вебхук Slack: резюме отчета текстом
+	ChannelWebhook = "webhook" // POST с отчетом целиком в JSON
+)
+
+// maxRecipients - предел получателей одной подписки
+const maxRecipients = 10
+
+// Subscription - регулярная рассылка отчета, которую команда заводит сама
+// через API: вид отчета, фильтр, расписание и получатели. Отчет подписки
+// строится отдельно от общего еженедельного и в список отчетов не попадает.
+type Subscription struct {
+	ID         string   `json:"id"`
+	Name       string   `json:"name"`
+	Owner      string   `json:"owner,omitempty"` // Клиент API, создавший подписку
+	ReportType string   `json:"report_type"`     // daily, weekly или monthly
+	Filter     Filter   `json:"filter"`
+	Schedule   string   `json:"schedule,omitempty"` // cron; пусто - по виду отчета
+	Timezone   string   `json:"timezone,omitempty"` // IANA, по умолчанию UTC
+	Channel    string   `json:"channel"`            // slack или webhook
+	Recipients []string `json:"recipients"`         // URL вебхуков
+	Paused     bool     `json:"paused,omitempty"`
+
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+	NextRunAt  time.Time  `json:"next_run_at"`
+	LastSentAt *time.Time `json:"last_sent_at,omitempty"`
+	LastError  string     `json:"last_error,omitempty"`
+}
+
+// parse проверяет подписку и возвращает ее расписание в ее часовом поясе
+func (sub *Subscription) parse() (schedule.Schedule, *time.Location, error) {
+	if sub.Name == "" {
+		return schedule.Schedule{}, nil, fmt.Errorf("name is required")
+	}
+	if _, known := defaultSchedules[sub.ReportType]; !known {
+		return schedule.Schedule{}, nil, fmt.Errorf("report_type must be %q, %q or %q", TypeDaily, TypeWeekly, TypeMonthly)
+	}
+	if sub.Filter.Team != "" && sub.Filter.Region != "" {
+		return schedule.Schedule{}, nil, fmt.Errorf("filter must set either team or region, not both")
+	}
+	if sub.Channel != ChannelSlack && sub.Channel != ChannelWebhook {
+		return schedule.Schedule{}, nil, fmt.Errorf("channel must be %q or %q", ChannelSlack, ChannelWebhook)
+	}
+	if len(sub.Recipients) == 0 || len(sub.Recipients) > maxRecipients {
+		return schedule.Schedule{}, nil, fmt.Errorf("between 1 and %d recipients are required", maxRecipients)
+	}
+	for _, recipient := range sub.Recipients {
+		if u, err := url.Parse(recipient); err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			return schedule.Schedule{}, nil, fmt.Errorf("recipient %q must be an http(s) webhook url", recipient)
+		}
+	}
+
+	expr := sub.Schedule
+	if expr == "" {
+		expr = defaultSchedules[sub.ReportType]
+	}
+	parsed, err := schedule.Parse(expr)
+	if err != nil {
+		return schedule.Schedule{}, nil, err
+	}
+	location := time.UTC
+	if sub.Timezone != "" {
+		if location, err = time.LoadLocation(sub.Timezone); err != nil {
+			return schedule.Schedule{}, nil, fmt.Errorf("invalid timezone: %s", sub.Timezone)
+		}
+	}
+	return parsed, location, nil
+}
+
+// period возвращает начало периода отчета, который рассылается в момент at
+func (sub Subscription) period(at time.Time) time.Time {
+	switch sub.ReportType {
+	case TypeDaily:
+		return at.AddDate(0, 0, -1)
+	case TypeMonthly:
+		return at.AddDate(0, -1, 0)
+	}
+	return at.AddDate(0, 0, -7)
+}
+
+type SubscriptionsConfig struct {
+	Path string // JSON-файл подписок; пусто - подписки живут только в памяти процесса
+}
+
+// Subscriptions хранит подписки и рассылает их отчеты по расписанию
+type Subscriptions struct {
+	config    SubscriptionsConfig
+	generator *Generator
+	client    *http.Client
+
+	mu            sync.RWMutex
+	subscriptions map[string]*Subscription
+	previous      map[string]Report // Последний отчет подписки для сравнения в резюме
+
+	saveMu sync.Mutex // Упорядочивает запись файла
+}
+
+// NewSubscriptions создает хранилище подписок и загружает сохраненные
+func NewSubscriptions(config SubscriptionsConfig, generator *Generator) (*Subscriptions, error) {
+	s := &Subscriptions{
+		config:        config,
+		generator:     generator,
+		client:        &http.Client{Timeout: 10 * time.Second},
+		subscriptions: make(map[string]*Subscription),
+		previous:      make(map[string]Report),
+	}
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Jobs возвращает задачу рассылки: раз в минуту отправляются подписки,
+// время которых пришло
+func (s *Subscriptions) Jobs() []scheduler.Job {
+	return []scheduler.Job{{
+		Name:     "reports.subscriptions",
+		Interval: time.Minute,
+		Jitter:   time.Second,
+		Run: func(ctx context.Context) error {
+			return s.Deliver(ctx, time.Now())
+		},
+	}}
+}
+
+func (s *Subscriptions) Create(sub Subscription) (Subscription, error) {
+	parsed, location, err := sub.parse()
+	if err != nil {
+		return Subscription{}, err
+	}
+	now := time.Now()
+	sub.ID = newID()
+	sub.CreatedAt, sub.UpdatedAt = now, now
+	sub.NextRunAt = parsed.Next(now.In(location))
+	sub.LastSentAt, sub.LastError = nil, ""
+
+	s.mu.Lock()
+	s.subscriptions[sub.ID] = &sub
+	s.mu.Unlock()
+	s.save()
+	return sub, nil
+}
+
+// Update заменяет настройки подписки; владелец и история доставки сохраняются
+func (s *Subscriptions) Update(id string, sub Subscription) (Subscription, error) {
+	parsed, location, err := sub.parse()
+	if err != nil {
+		return Subscription{}, err
+	}
+
+	s.mu.Lock()
+	existing, exists := s.subscriptions[id]
+	if !exists {
+		s.mu.Unlock()
+		return Subscription{}, fmt.Errorf("%w: %s", ErrSubscriptionNotFound, id)
+	}
+	now := time.Now()
+	sub.ID, sub.Owner, sub.CreatedAt, sub.UpdatedAt = existing.ID, existing.Owner, existing.CreatedAt, now
+	sub.LastSentAt, sub.LastError = existing.LastSentAt, existing.LastError
+	sub.NextRunAt = parsed.Next(now.In(location))
+	s.subscriptions[id] = &sub
+	if existing.ReportType != sub.ReportType || existing.Filter != sub.Filter {
+		delete(s.previous, id)
+	}
+	s.mu.Unlock()
+	s.save()
+	return sub, nil
+}
+
+func (s *Subscriptions) Get(id string) (Subscription, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	sub, exists := s.subscriptions[id]
+	if !exists {
+		return Subscription{}, fmt.Errorf("%w: %s", ErrSubscriptionNotFound, id)
+	}
+	return *sub, nil
+}
+
+// List возвращает подписки владельца по имени; пустой owner - все подписки
+func (s *Subscriptions) List(owner string) []Subscription {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make([]Subscription, 0, len(s.subscriptions))
+	for _, sub := range s.subscriptions {
+		if owner == "" || sub.Owner == owner {
+			result = append(result, *sub)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Name != result[j].Name {
+			return result[i].Name < result[j].Name
+		}
+		return result[i].ID < result[j].ID
+	})
+	return result
+}
+
+func (s *Subscriptions) Delete(id string) error {
+	s.mu.Lock()
+	if _, exists := s.subscriptions[id]; !exists {
+		s.mu.Unlock()
+		return fmt.Errorf("%w: %s", ErrSubscriptionNotFound, id)
+	}
+	delete(s.subscriptions, id)
+	delete(s.previous, id)
+	s.mu.Unlock()
+	s.save()
+	return nil
+}
+
+// Deliver рассылает отчеты подписок, время которых пришло к моменту now.
+// Пропущенные за время простоя запуски не наверстываются: подписка
+// отправляется один раз и переходит к следующему запуску после now.
+func (s *Subscriptions) Deliver(ctx context.Context, now time.Time) error {
+	var due []string
+	s.mu.RLock()
+	for id, sub := range s.subscriptions {
+		if !sub.Paused && !now.Before(sub.NextRunAt) {
+			due = append(due, id)
+		}
+	}
+	s.mu.RUnlock()
+
+	var errs []error
+	for _, id := range due {
+		if _, err := s.send(ctx, id, now, true); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// SendNow строит и отправляет отчет подписки вне расписания, например чтобы
+// проверить получателей; следующий плановый запуск не сдвигается
+func (s *Subscriptions) SendNow(ctx context.Context, id string) (Report, error) {
+	return s.send(ctx, id, time.Now(), false)
+}
+
+func (s *Subscriptions) send(ctx context.Context, id string, now time.Time, scheduled bool) (Report, error) {
+	s.mu.RLock()
+	stored, exists := s.subscriptions[id]
+	var sub Subscription
+	if exists {
+		sub = *stored
+	}
+	var previous *Report
+	if last, ok := s.previous[id]; ok {
+		previous = &last
+	}
+	s.mu.RUnlock()
+	if !exists {
+		return Report{}, fmt.Errorf("%w: %s", ErrSubscriptionNotFound, id)
+	}
+
+	report := s.generator.Build(sub.ReportType, sub.period(now), now, sub.Filter, previous)
+	var errs []error
+	for _, recipient := range sub.Recipients {
+		if err := s.post(ctx, sub, recipient, report); err != nil {
+			// URL вебхука - секрет получателя, в ошибку попадает только хост
+			host := recipient
+			if u, parseErr := url.Parse(recipient); parseErr == nil {
+				host = u.Host
+			}
+			errs = append(errs, fmt.Errorf("subscription %s: %s: %w", sub.Name, host, err))
+		}
+	}
+	err := errors.Join(errs...)
+
+	s.mu.Lock()
+	if current, exists := s.subscriptions[id]; exists {
+		current.LastError = ""
+		if err != nil {
+			current.LastError = err.Error()
+		}
+		if err == nil || len(errs) < len(sub.Recipients) {
+			sentAt := now
+			current.LastSentAt = &sentAt
+			s.previous[id] = report
+		}
+		if scheduled {
+			if parsed, location, parseErr := current.parse(); parseErr == nil {
+				current.NextRunAt = parsed.Next(now.In(location))
+			}
+		}
+	}
+	s.mu.Unlock()
+	s.save()
+
+	if err != nil {
+		log.Printf("Не удалось доставить отчет подписки %s: %v", sub.Name, err)
+	}
+	return report, err
+}
+
+// post отправляет отчет получателю в формате канала подписки
+func (s *Subscriptions) post(ctx context.Context, sub Subscription, recipient string, report Report) error {
+	var payload interface{}
+	switch sub.Channel {
+	case ChannelSlack:
+		text := fmt.Sprintf("*%s* (%s report, %s - %s)", sub.Name, report.Type,
+			report.PeriodStart.Format("2006-01-02"), report.PeriodEnd.Format("2006-01-02"))
+		if report.Summary != "" {
+			text += "\n" + report.Summary
+		}
+		if len(report.Errors) > 0 {
+			text += fmt.Sprintf("\n_%d sections could not be generated_", len(report.Errors))
+		}
+		payload = map[string]string{"text": text}
+	default:
+		payload = map[string]interface{}{
+			"subscription_id":   sub.ID,
+			"subscription_name": sub.Name,
+			"report":            report,
+		}
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, recipient, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
+
+func (s *Subscriptions) load() error {
+	if s.config.Path == "" {
+		return nil
+	}
+	data, err := os.ReadFile(s.config.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var saved []Subscription
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return fmt.Errorf("invalid report subscriptions file %s: %w", s.config.Path, err)
+	}
+	for i := range saved {
+		sub := saved[i]
+		if _, _, err := sub.parse(); err != nil {
+			return fmt.Errorf("report subscription %s: %w", sub.ID, err)
+		}
+		s.subscriptions[sub.ID] = &sub
+	}
+	return nil
+}
+
+// save записывает подписки во временный файл и подменяет им прежний
+func (s *Subscriptions) save() {
+	if s.config.Path == "" {
+		return
+	}
+
+	s.saveMu.Lock()
+	defer s.saveMu.Unlock()
+
+	data, err := json.Marshal(s.List(""))
+	if err != nil {
+		log.Printf("Не удалось сохранить подписки на отчеты: %v", err)
+		return
+	}
+	tmp := s.config.Path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		log.Printf("Не удалось сохранить подписки на отчеты: %v", err)
+		return
+	}
+	if err := os.Rename(tmp, s.config.Path); err != nil {
+		log.Printf("Не удалось сохранить подписки на отчеты: %v", err)
+	}
+}
@@ -0,0 +1,274 @@
+package reports
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Summarizer формирует краткое резюме отчета для руководителей. previous -
+// предыдущий отчет того же генератора или nil.
+type Summarizer interface {
+	Summarize(ctx context.Context, report Report, previous *Report) (string, error)
+}
+
+// Источники резюме (Report.SummarySource)
+const (
+	SummaryTemplate = "template"
+	SummaryModel    = "model"
+)
+
+// units - подписи единиц по окончанию поля total_*
+var units = map[string]string{
+	"kwh":   "kWh",
+	"wh":    "Wh",
+	"watts": "W",
+	"kg":    "kg CO2",
+	"usd":   "USD",
+}
+
+// TemplateSummarizer строит резюме по шаблону без внешних сервисов. В каждом
+// разделе учитываются числовые поля total_* и словари by_* с числами: итог
+// сравнивается с предыдущим отчетом, а из словарей называется группа с
+// наибольшим изменением, например "Energy: 1204.3 kWh, down 6.0% from
+// 1281.1 kWh, driven by eu-west-1 (-61.4 kWh)."
+type TemplateSummarizer struct{}
+
+func (TemplateSummarizer) Summarize(ctx context.Context, report Report, previous *Report) (string, error) {
+	names := make([]string, 0, len(report.Sections))
+	for name := range report.Sections {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var sentences []string
+	for _, name := range names {
+		current := flatten(report.Sections[name])
+		var before map[string]interface{}
+		if previous != nil {
+			before = flatten(previous.Sections[name])
+		}
+		sentences = append(sentences, summarizeSection(name, current, before)...)
+	}
+	if len(sentences) == 0 {
+		return "", fmt.Errorf("report has no totals to summarize")
+	}
+	return strings.Join(sentences, " "), nil
+}
+
+// flatten приводит раздел к JSON-объекту; разделы другого вида пропускаются
+func flatten(section interface{}) map[string]interface{} {
+	data, err := json.Marshal(section)
+	if err != nil {
+		return nil
+	}
+	var object map[string]interface{}
+	if json.Unmarshal(data, &object) != nil {
+		return nil
+	}
+	return object
+}
+
+func summarizeSection(section string, current, previous map[string]interface{}) []string {
+	keys := make([]string, 0, len(current))
+	for key := range current {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var sentences []string
+	for _, key := range keys {
+		value, ok := current[key].(float64)
+		if !ok || !strings.HasPrefix(key, "total_") {
+			continue
+		}
+		unit := strings.TrimPrefix(key, "total_")
+		if label, known := units[unit]; known {
+			unit = label
+		}
+
+		sentence := fmt.Sprintf("%s: %.1f %s", title(section), value, unit)
+		before, hasBefore := previous[key].(float64)
+		if hasBefore && before != 0 {
+			change := (value - before) / math.Abs(before) * 100
+			direction := "up"
+			if change < 0 {
+				direction = "down"
+			}
+			sentence += fmt.Sprintf(", %s %.1f%% from %.1f %s", direction, math.Abs(change), before, unit)
+			if group, delta, found := largestChange(current, previous); found {
+				sentence += fmt.Sprintf(", driven by %s (%+.1f %s)", group, delta, unit)
+			}
+		} else if group, share, found := largestShare(current, value); found {
+			sentence += fmt.Sprintf(", largest share %s (%.0f%%)", group, share)
+		}
+		sentences = append(sentences, sentence+".")
+	}
+	return sentences
+}
+
+// groups возвращает числовые значения словарей by_* раздела по полям
+func groups(section map[string]interface{}) map[string]map[string]float64 {
+	fields := make(map[string]map[string]float64)
+	for key, field := range section {
+		object, ok := field.(map[string]interface{})
+		if !ok || !strings.HasPrefix(key, "by_") {
+			continue
+		}
+		values := make(map[string]float64, len(object))
+		for group, value := range object {
+			if number, ok := value.(float64); ok {
+				values[group] = number
+			}
+		}
+		fields[key] = values
+	}
+	return fields
+}
+
+// largestChange - группа с наибольшим по модулю изменением среди всех
+// словарей by_*
+func largestChange(current, previous map[string]interface{}) (string, float64, bool) {
+	before := groups(previous)
+
+	var best string
+	var bestDelta float64
+	for field, now := range groups(current) {
+		names := make(map[string]bool, len(now)+len(before[field]))
+		for name := range now {
+			names[name] = true
+		}
+		for name := range before[field] {
+			names[name] = true
+		}
+		for name := range names {
+			delta := now[name] - before[field][name]
+			if math.Abs(delta) > math.Abs(bestDelta) || (math.Abs(delta) == math.Abs(bestDelta) && name < best) {
+				best, bestDelta = name, delta
+			}
+		}
+	}
+	return best, bestDelta, best != "" && bestDelta != 0
+}
+
+// largestShare - группа с наибольшей долей итога, в процентах
+func largestShare(current map[string]interface{}, total float64) (string, float64, bool) {
+	if total == 0 {
+		return "", 0, false
+	}
+	var best string
+	var bestValue float64
+	for _, values := range groups(current) {
+		for name, value := range values {
+			if value > bestValue || (value == bestValue && name < best) {
+				best, bestValue = name, value
+			}
+		}
+	}
+	return best, bestValue / total * 100, best != ""
+}
+
+func title(name string) string {
+	name = strings.ReplaceAll(name, "_", " ")
+	if name == "" {
+		return name
+	}
+	return strings.ToUpper(name[:1]) + name[1:]
+}
+
+// ChatSummarizerConfig - подключение к модели с API, совместимым с OpenAI
+// Chat Completions (облачные сервисы, vLLM, Ollama и т.п.)
+type ChatSummarizerConfig struct {
+	URL     string // Адрес вида https://host/v1/chat/completions
+	Model   string
+	APIKey  string // Необязателен для локальных моделей
+	Timeout time.Duration
+}
+
+// ChatSummarizer поручает резюме языковой модели. Модели передаются разделы
+// отчета, предыдущий отчет и резюме по шаблону как проверенные факты: модель
+// должна переформулировать их, не добавляя новых чисел.
+type ChatSummarizer struct {
+	config ChatSummarizerConfig
+	client *http.Client
+}
+
+func NewChatSummarizer(config ChatSummarizerConfig) (*ChatSummarizer, error) {
+	if config.URL == "" || config.Model == "" {
+		return nil, fmt.Errorf("summarizer url and model are required")
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = time.Minute
+	}
+	return &ChatSummarizer{config: config, client: &http.Client{Timeout: config.Timeout}}, nil
+}
+
+const summaryPrompt = `You write the executive summary of a data-center energy efficiency report.
+Write one paragraph of at most four sentences in plain English for a non-technical reader.
+Use only numbers that appear in the report data or in the draft; never invent figures or causes.
+Reply with the paragraph only.`
+
+func (s *ChatSummarizer) Summarize(ctx context.Context, report Report, previous *Report) (string, error) {
+	draft, _ := TemplateSummarizer{}.Summarize(ctx, report, previous)
+	facts, err := json.Marshal(map[string]interface{}{
+		"report":   report,
+		"previous": previous,
+		"draft":    draft,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	body, err := json.Marshal(map[string]interface{}{
+		"model":       s.config.Model,
+		"temperature": 0.2,
+		"messages": []map[string]string{
+			{"role": "system", "content": summaryPrompt},
+			{"role": "user", "content": string(facts)},
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, s.config.URL, bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	if s.config.APIKey != "" {
+		request.Header.Set("Authorization", "Bearer "+s.config.APIKey)
+	}
+
+	response, err := s.client.Do(request)
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(response.Body, 1024))
+		return "", fmt.Errorf("summarizer returned %s: %s", response.Status, strings.TrimSpace(string(message)))
+	}
+
+	var result struct {
+		Choices []struct {
+			Message struct {
+				Content string `json:"content"`
+			} `json:"message"`
+		} `json:"choices"`
+	}
+	if err := json.NewDecoder(response.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("invalid summarizer response: %w", err)
+	}
+	if len(result.Choices) == 0 || strings.TrimSpace(result.Choices[0].Message.Content) == "" {
+		return "", fmt.Errorf("summarizer returned an empty summary")
+	}
+	return strings.TrimSpace(result.Choices[0].Message.Content), nil
+}
@@ -0,0 +1,70 @@
+// Package requestid связывает запрос API с действиями, которые он запустил.
+// Идентификатор запроса возвращается клиенту в X-Request-ID и передается
+// через контекст в фоновые действия: перестройку профилей, изменение
+// рекомендаций, оповещения. По нему запрос находится в журнале и в
+// записях, которые он оставил.
+package requestid
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+)
+
+// Header - заголовок запроса и ответа с идентификатором
+const Header = "X-Request-ID"
+
+const maxLength = 128
+
+type contextKey struct{}
+
+// New создает случайный идентификатор
+func New() string {
+	var id [16]byte
+	rand.Read(id[:])
+	return hex.EncodeToString(id[:])
+}
+
+// Valid сообщает, что идентификатор клиента можно принять: не длиннее 128
+// символов из букв, цифр и "-_.:". Остальные заменяются новыми, чтобы
+// клиент не мог подделать строки журнала.
+func Valid(id string) bool {
+	if id == "" || len(id) > maxLength {
+		return false
+	}
+	for _, r := range id {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case r == '-' || r == '_' || r == '.' || r == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// NewContext сохраняет идентификатор в контексте
+func NewContext(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, contextKey{}, id)
+}
+
+// FromContext возвращает идентификатор запроса; пусто - действие запущено
+// не запросом (например, плановой задачей)
+func FromContext(ctx context.Context) string {
+	id, _ := ctx.Value(contextKey{}).(string)
+	return id
+}
+
+// Prefix возвращает префикс строки журнала вида "[request abc] " или пустую
+// строку без идентификатора
+func Prefix(ctx context.Context) string {
+	return LogPrefix(FromContext(ctx))
+}
+
+// LogPrefix - Prefix для идентификатора, сохраненного вместе с действием
+func LogPrefix(id string) string {
+	if id == "" {
+		return ""
+	}
+	return "[request " + id + "] "
+}
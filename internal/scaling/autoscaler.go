@@ -2,11 +2,17 @@ package scaling
 
 import (
     "context"
+    "errors"
+    "slices"
     "sync"
     "time"
     
+    "github.com/YumeNoTenshi/platypus/internal/alerting"
+    "github.com/YumeNoTenshi/platypus/internal/groups"
     "github.com/YumeNoTenshi/platypus/internal/metrics"
     "github.com/YumeNoTenshi/platypus/internal/models"
+    "github.com/YumeNoTenshi/platypus/internal/objectives"
+    "github.com/YumeNoTenshi/platypus/internal/scheduler"
     "github.com/YumeNoTenshi/platypus/pkg/cloud"
 )
 
@@ -17,6 +23,8 @@ type AutoscalerConfig struct {
     ScaleUpCooldown     time.Duration // Период ожидания между масштабированиями вверх
     ScaleDownCooldown   time.Duration // Период ожидания между масштабированиями вниз
     EvaluationInterval  time.Duration // Интервал проверки метрик
+    Groups              []string      // Группы серверов под управлением; пусто - все серверы
+    Objectives          objectives.Policy // Веса целей при выборе цели переноса по окружениям
 }
 
 type Autoscaler struct {
@@ -27,6 +35,12 @@ type Autoscaler struct {
     mu          sync.RWMutex
     lastScaleUp time.Time
     lastScaleDown time.Time
+    groups      *groups.Manager
+    alerts      *alerting.Dispatcher
+    backoffUntil time.Time // Провайдер троттлит запросы: до этого времени проверки пропускаются
+    explanations map[string]ScalingExplanation // ServerID -> объяснение последней проверки
+    environment func(serverID string) string  // Окружение сервера для выбора весов целей
+    rollout     *cloud.Rollout // Провайдеры в режиме наблюдения не масштабируются
 }
 
 func NewAutoscaler(config AutoscalerConfig, collector *metrics.Collector, analyzer *metrics.Analyzer, provider cloud.CloudProvider) *Autoscaler {
@@ -35,24 +49,145 @@ func NewAutoscaler(config AutoscalerConfig, collector *metrics.Collector, analyz
         collector: collector,
         analyzer:  analyzer,
         provider:  provider,
+        explanations: make(map[string]ScalingExplanation),
     }
 }
 
-func (a *Autoscaler) Start(ctx context.Context) error {
-    ticker := time.NewTicker(a.config.EvaluationInterval)
-    defer ticker.Stop()
+// SetThresholds обновляет пороги масштабирования (например, по политике федерации)
+func (a *Autoscaler) SetThresholds(cpuHigh, cpuLow, powerHigh float64) {
+    a.mu.Lock()
+    defer a.mu.Unlock()
+
+    a.config.CPUThresholdHigh = cpuHigh
+    a.config.CPUThresholdLow = cpuLow
+    a.config.PowerThresholdHigh = powerHigh
+}
+
+// SetGroups подключает сохраненные группы серверов для ограничения AutoscalerConfig.Groups
+func (a *Autoscaler) SetGroups(g *groups.Manager) {
+    a.mu.Lock()
+    defer a.mu.Unlock()
+    a.groups = g
+}
+
+// ScalingGroups возвращает группы серверов под управлением (ID или имена
+// из AutoscalerConfig.Groups); пусто - под управлением все серверы
+func (a *Autoscaler) ScalingGroups() []string {
+    a.mu.RLock()
+    defer a.mu.RUnlock()
+    return append([]string(nil), a.config.Groups...)
+}
+
+// AddScalingGroup ставит группу под управление; группа, уже добавленная по
+// ID или имени, не дублируется
+func (a *Autoscaler) AddScalingGroup(groupID string) {
+    a.mu.Lock()
+    defer a.mu.Unlock()
 
-    for {
-        select {
-        case <-ctx.Done():
-            return ctx.Err()
-        case <-ticker.C:
-            if err := a.evaluate(ctx); err != nil {
-                // Логируем ошибку, но продолжаем работу
-                continue
+    if a.scalingGroupIndex(groupID) < 0 {
+        a.config.Groups = append(slices.Clip(a.config.Groups), groupID)
+    }
+}
+
+// RemoveScalingGroup снимает группу с управления; false - группа не была
+// под управлением. Когда групп не остается, автоскейлер снова управляет
+// всеми серверами, как при пустом AutoscalerConfig.Groups.
+func (a *Autoscaler) RemoveScalingGroup(groupID string) bool {
+    a.mu.Lock()
+    defer a.mu.Unlock()
+
+    i := a.scalingGroupIndex(groupID)
+    if i < 0 {
+        return false
+    }
+    a.config.Groups = slices.Delete(slices.Clone(a.config.Groups), i, i+1)
+    return true
+}
+
+// scalingGroupIndex ищет группу среди управляемых по ID, в том числе
+// заданную в конфигурации именем; вызывается под a.mu
+func (a *Autoscaler) scalingGroupIndex(groupID string) int {
+    for i, ref := range a.config.Groups {
+        if ref == groupID {
+            return i
+        }
+        if a.groups != nil {
+            if group, err := a.groups.Get(ref); err == nil && group.ID == groupID {
+                return i
             }
         }
     }
+    return -1
+}
+
+// SetAlerts включает оповещения об ошибках провайдера, требующих вмешательства
+func (a *Autoscaler) SetAlerts(alerts *alerting.Dispatcher) {
+    a.mu.Lock()
+    defer a.mu.Unlock()
+    a.alerts = alerts
+}
+
+// SetEnvironmentClassifier подключает источник окружений серверов (метка
+// environment) для выбора весов целей
+func (a *Autoscaler) SetEnvironmentClassifier(classifier func(serverID string) string) {
+    a.mu.Lock()
+    defer a.mu.Unlock()
+    a.environment = classifier
+}
+
+// SetRollout подключает режимы провайдеров: серверы провайдеров в режиме
+// наблюдения не масштабируются и не становятся целью переноса
+func (a *Autoscaler) SetRollout(rollout *cloud.Rollout) {
+    a.mu.Lock()
+    defer a.mu.Unlock()
+    a.rollout = rollout
+}
+
+func (a *Autoscaler) observeOnly(server models.Server) bool {
+    a.mu.RLock()
+    rollout := a.rollout
+    a.mu.RUnlock()
+    return rollout.ObserveOnly(server.Provider)
+}
+
+// managed сообщает, находится ли сервер под управлением автомасштабирования
+func (a *Autoscaler) managed(serverID string) bool {
+    a.mu.RLock()
+    defer a.mu.RUnlock()
+
+    if len(a.config.Groups) == 0 || a.groups == nil {
+        return true
+    }
+    for _, group := range a.config.Groups {
+        if a.groups.Contains(group, serverID) {
+            return true
+        }
+    }
+    return false
+}
+
+// Jobs возвращает периодические задачи автоскейлера
+func (a *Autoscaler) Jobs() []scheduler.Job {
+    return []scheduler.Job{{
+        Name:     "autoscaler.evaluate",
+        Interval: a.config.EvaluationInterval,
+        Run:      a.runEvaluation,
+    }}
+}
+
+func (a *Autoscaler) runEvaluation(ctx context.Context) error {
+    a.mu.RLock()
+    throttled := time.Now().Before(a.backoffUntil)
+    a.mu.RUnlock()
+    if throttled {
+        return nil
+    }
+
+    if err := a.evaluate(ctx); err != nil {
+        a.handleProviderError(ctx, err)
+        return err
+    }
+    return nil
 }
 
 func (a *Autoscaler) evaluate(ctx context.Context) error {
@@ -61,66 +196,122 @@ func (a *Autoscaler) evaluate(ctx context.Context) error {
     if err != nil {
         return err
     }
+    // Пока провайдер отклоняет учетные данные, действия не выполняются;
+    // успешный GetInstances выше возвращает его в рабочее состояние
+    health, paused := cloud.Paused(a.provider)
 
     for _, server := range servers {
+        a.analyzer.RegisterInstance(server.ID, server.InstanceType)
+        explanation := a.newExplanation(server.ID, time.Now())
+        if paused {
+            explanation.because("provider %s is %s: credentials rejected", health.Provider, health.State)
+            a.record(explanation)
+            continue
+        }
+        if !a.managed(server.ID) {
+            explanation.because("server is outside the managed groups")
+            a.record(explanation)
+            continue
+        }
+        if a.observeOnly(server) {
+            explanation.because("provider %s is observe-only", server.Provider)
+            a.record(explanation)
+            continue
+        }
+
         metrics, err := a.collector.GetMetrics(server.ID)
         if err != nil {
+            explanation.because("no metrics: %v", err)
+            a.record(explanation)
             continue
         }
 
         if len(metrics) == 0 {
+            explanation.because("no metrics")
+            a.record(explanation)
             continue
         }
 
         // Анализируем последние метрики
         lastMetric := metrics[len(metrics)-1]
+        explanation.Inputs = &ScalingInputs{
+            CPUUsage:   lastMetric.CPUUsage,
+            PowerUsage: lastMetric.PowerUsage,
+            Timestamp:  lastMetric.Timestamp,
+        }
         
         // Проверяем необходимость масштабирования
-        if a.shouldScaleUp(lastMetric) {
-            if err := a.scaleUp(ctx, server); err != nil {
-                return err
-            }
-        } else if a.shouldScaleDown(lastMetric) {
-            if err := a.scaleDown(ctx, server); err != nil {
-                return err
-            }
+        if a.shouldScaleUp(lastMetric, &explanation) {
+            explanation.Decision = DecisionScaleUp
+            err = a.scaleUp(ctx, server, &explanation)
+        } else if a.shouldScaleDown(lastMetric, &explanation) {
+            explanation.Decision = DecisionScaleDown
+            err = a.scaleDown(ctx, server, &explanation)
+        } else if len(explanation.Reasons) == 0 {
+            explanation.because("cpu %.1f%% and power %.1f W are within thresholds", lastMetric.CPUUsage, lastMetric.PowerUsage)
+        }
+        if err != nil {
+            explanation.Error = err.Error()
+        }
+        a.record(explanation)
+        if err != nil {
+            return err
         }
     }
 
     return nil
 }
 
-func (a *Autoscaler) shouldScaleUp(metric models.MetricData) bool {
+// shouldScaleUp проверяет верхние пороги и паузу; причины попадают в explanation
+func (a *Autoscaler) shouldScaleUp(metric models.MetricData, explanation *ScalingExplanation) bool {
     a.mu.RLock()
     defer a.mu.RUnlock()
 
+    // Проверяем пороги
+    exceeded := false
+    if metric.CPUUsage > a.config.CPUThresholdHigh {
+        explanation.because("cpu %.1f%% is above the %.1f%% threshold", metric.CPUUsage, a.config.CPUThresholdHigh)
+        exceeded = true
+    }
+    if metric.PowerUsage > a.config.PowerThresholdHigh {
+        explanation.because("power %.1f W is above the %.1f W threshold", metric.PowerUsage, a.config.PowerThresholdHigh)
+        exceeded = true
+    }
+    if !exceeded {
+        return false
+    }
+
     // Проверяем, прошло ли достаточно времени с последнего масштабирования
     if time.Since(a.lastScaleUp) < a.config.ScaleUpCooldown {
+        explanation.because("scale-up cooldown is active until %s", a.lastScaleUp.Add(a.config.ScaleUpCooldown).Format(time.RFC3339))
         return false
     }
-
-    // Проверяем пороги
-    return metric.CPUUsage > a.config.CPUThresholdHigh ||
-           metric.PowerUsage > a.config.PowerThresholdHigh
+    return true
 }
 
-func (a *Autoscaler) shouldScaleDown(metric models.MetricData) bool {
+// shouldScaleDown проверяет нижний порог и паузу; причины попадают в explanation
+func (a *Autoscaler) shouldScaleDown(metric models.MetricData, explanation *ScalingExplanation) bool {
     a.mu.RLock()
     defer a.mu.RUnlock()
 
-    if time.Since(a.lastScaleDown) < a.config.ScaleDownCooldown {
+    if metric.CPUUsage >= a.config.CPUThresholdLow {
         return false
     }
+    explanation.because("cpu %.1f%% is below the %.1f%% threshold", metric.CPUUsage, a.config.CPUThresholdLow)
 
-    return metric.CPUUsage < a.config.CPUThresholdLow
+    if time.Since(a.lastScaleDown) < a.config.ScaleDownCooldown {
+        explanation.because("scale-down cooldown is active until %s", a.lastScaleDown.Add(a.config.ScaleDownCooldown).Format(time.RFC3339))
+        return false
+    }
+    return true
 }
 
-func (a *Autoscaler) scaleUp(ctx context.Context, server models.Server) error {
+func (a *Autoscaler) scaleUp(ctx context.Context, server models.Server, explanation *ScalingExplanation) error {
     a.mu.Lock()
     defer a.mu.Unlock()
 
     // Находим сервер с наименьшим энергопотреблением для миграции
-    targetServer, err := a.findEnergyEfficientServer(ctx)
+    targetServer, err := a.findEnergyEfficientServer(ctx, server, explanation)
     if err != nil {
         return err
     }
@@ -139,10 +330,11 @@ func (a *Autoscaler) scaleUp(ctx context.Context, server models.Server) error {
     }
 
     a.lastScaleUp = time.Now()
+    explanation.TargetServerID = targetServer.ID
     return nil
 }
 
-func (a *Autoscaler) scaleDown(ctx context.Context, server models.Server) error {
+func (a *Autoscaler) scaleDown(ctx context.Context, server models.Server, explanation *ScalingExplanation) error {
     a.mu.Lock()
     defer a.mu.Unlock()
 
@@ -150,11 +342,13 @@ func (a *Autoscaler) scaleDown(ctx context.Context, server models.Server) error
     ecoScore := a.analyzer.CalculateEcoScore([]models.MetricData{})
     if ecoScore > 80 {
         // Если сервер энергоэффективен, сохраняем его
+        explanation.Decision = DecisionNone
+        explanation.because("eco score %.1f is above 80, server is kept", ecoScore)
         return nil
     }
 
     // Находим более энергоэффективный сервер для миграции
-    targetServer, err := a.findEnergyEfficientServer(ctx)
+    targetServer, err := a.findEnergyEfficientServer(ctx, server, explanation)
     if err != nil {
         return err
     }
@@ -167,15 +361,53 @@ func (a *Autoscaler) scaleDown(ctx context.Context, server models.Server) error
 
     for _, container := range containers {
         if err := a.provider.MigrateContainer(ctx, container.ID, server.ID, targetServer.ID); err != nil {
+            if errors.Is(err, cloud.ErrNotFound) {
+                continue // Контейнер уже перемещен или удален
+            }
             return err
         }
     }
 
     a.lastScaleDown = time.Now()
+    explanation.TargetServerID = targetServer.ID
     return nil
 }
 
-func (a *Autoscaler) findEnergyEfficientServer(ctx context.Context) (models.Server, error) {
+// handleProviderError откладывает проверки при троттлинге и оповещает о проблемах,
+// которые не пройдут без вмешательства (учетные данные, квоты)
+func (a *Autoscaler) handleProviderError(ctx context.Context, err error) {
+    a.mu.Lock()
+    if errors.Is(err, cloud.ErrThrottled) {
+        a.backoffUntil = time.Now().Add(cloud.RetryAfter(err, a.config.EvaluationInterval))
+    }
+    alerts := a.alerts
+    a.mu.Unlock()
+
+    if alerts == nil || !(errors.Is(err, cloud.ErrUnauthorized) || errors.Is(err, cloud.ErrCapacity)) {
+        return
+    }
+
+    provider := cloud.ProviderOf(err)
+    severity := alerting.SeverityWarning
+    if errors.Is(err, cloud.ErrUnauthorized) {
+        severity = alerting.SeverityCritical
+    }
+    alerts.Notify(ctx, alerting.Alert{
+        Key:      "autoscaler/provider/" + provider + "/" + cloud.Kind(err).Error(),
+        Source:   "autoscaler",
+        Severity: severity,
+        Title:    "Autoscaling blocked by cloud provider",
+        Message:  err.Error(),
+        Labels:   map[string]string{"provider": provider},
+    })
+}
+
+// findEnergyEfficientServer выбирает цель переноса с сервера source по весам
+// целей его окружения: мощность - нормализованный эко-рейтинг, углерод -
+// доля безуглеродной энергии региона, простой - перенос в другой регион.
+// Цен инстансов автоскейлер не знает, вес денег не учитывается.
+// Рассмотренные серверы и их оценки попадают в explanation.
+func (a *Autoscaler) findEnergyEfficientServer(ctx context.Context, source models.Server, explanation *ScalingExplanation) (models.Server, error) {
     servers, err := a.provider.GetInstances(ctx)
     if err != nil {
         return models.Server{}, err
@@ -185,23 +417,47 @@ func (a *Autoscaler) findEnergyEfficientServer(ctx context.Context) (models.Serv
     var bestScore float64
 
     for _, server := range servers {
+        if a.observeOnly(server) {
+            explanation.Candidates = append(explanation.Candidates, ScalingCandidate{ServerID: server.ID, Reason: "observe-only provider"})
+            continue
+        }
         metrics, err := a.collector.GetMetrics(server.ID)
         if err != nil {
+            explanation.Candidates = append(explanation.Candidates, ScalingCandidate{ServerID: server.ID, Reason: "no metrics"})
             continue
         }
 
-        score := a.analyzer.CalculateEcoScore(metrics)
+        // Сравниваем нормализованный рейтинг, чтобы крупные хосты не проигрывали мелким VM
+        ecoScore := a.analyzer.CalculateEcoScores(server.ID, metrics).Normalized
+        scores := objectives.Scores{Power: ecoScore / 100, Carbon: objectives.CarbonFreeShare(server.Region)}
+        if server.Region != source.Region {
+            scores.Disruption = 1
+        }
+        score := explanation.Objectives.Score(scores)
+        explanation.Candidates = append(explanation.Candidates, ScalingCandidate{
+            ServerID:   server.ID,
+            EcoScore:   ecoScore,
+            Objectives: scores,
+            Score:      score,
+        })
         if score > bestScore {
             bestScore = score
             bestServer = server
         }
     }
 
+    for i := range explanation.Candidates {
+        candidate := &explanation.Candidates[i]
+        if candidate.ServerID == bestServer.ID && candidate.Reason == "" {
+            candidate.Chosen = true
+        } else if candidate.Reason == "" {
+            candidate.Reason = "outscored"
+        }
+    }
     return bestServer, nil
 }
 
+// getServerContainers возвращает контейнеры сервера по их последним точкам
 func (a *Autoscaler) getServerContainers(ctx context.Context, serverID string) ([]models.Container, error) {
-    // Здесь должна быть реализация получения списка контейнеров с сервера
-    // Можно использовать Kubernetes API или другие механизмы
-    return []models.Container{}, nil
+    return a.collector.Containers(serverID), nil
 } 
\ No newline at end of file
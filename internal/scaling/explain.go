@@ -0,0 +1,111 @@
+package scaling
+
+import (
+    "fmt"
+    "time"
+
+    "github.com/YumeNoTenshi/platypus/internal/objectives"
+)
+
+// Решение автоскейлера по серверу
+const (
+    DecisionScaleUp   = "scale_up"
+    DecisionScaleDown = "scale_down"
+    DecisionNone      = "none"
+)
+
+// ScalingInputs - последняя точка сервера, по которой принято решение
+type ScalingInputs struct {
+    CPUUsage   float64 `json:"cpu_usage"`
+    PowerUsage float64 `json:"power_usage"`
+    Timestamp  int64   `json:"timestamp"`
+}
+
+// ScalingThresholds - пороги и паузы, действовавшие при проверке
+type ScalingThresholds struct {
+    CPUThresholdHigh   float64       `json:"cpu_threshold_high"`
+    CPUThresholdLow    float64       `json:"cpu_threshold_low"`
+    PowerThresholdHigh float64       `json:"power_threshold_high"`
+    ScaleUpCooldown    time.Duration `json:"scale_up_cooldown"`
+    ScaleDownCooldown  time.Duration `json:"scale_down_cooldown"`
+    ScaleUpReadyAt     time.Time     `json:"scale_up_ready_at,omitempty"` // Конец паузы после масштабирования вверх
+    ScaleDownReadyAt   time.Time     `json:"scale_down_ready_at,omitempty"`
+}
+
+// ScalingCandidate - сервер, рассмотренный как цель переноса контейнеров
+type ScalingCandidate struct {
+    ServerID   string            `json:"server_id"`
+    EcoScore   float64           `json:"eco_score"` // Нормализованный эко-рейтинг
+    Objectives objectives.Scores `json:"objectives"`
+    Score      float64           `json:"score"` // Взвешенная оценка
+    Chosen     bool              `json:"chosen,omitempty"`
+    Reason     string            `json:"reason,omitempty"`
+}
+
+// ScalingExplanation объясняет последнее решение автоскейлера по серверу:
+// входные данные, пороги, причины и рассмотренные цели
+type ScalingExplanation struct {
+    ServerID       string               `json:"server_id"`
+    Decision       string               `json:"decision"`
+    TargetServerID string               `json:"target_server_id,omitempty"`
+    Reasons        []string             `json:"reasons"`
+    Inputs         *ScalingInputs       `json:"inputs,omitempty"` // Нет, если у сервера нет точек
+    Thresholds     ScalingThresholds    `json:"thresholds"`
+    Objectives     objectives.Selection `json:"objective_weights"`
+    Candidates     []ScalingCandidate   `json:"candidates"`
+    Error          string               `json:"error,omitempty"`
+    ExplainedAt    time.Time            `json:"explained_at"`
+}
+
+// Explain возвращает объяснение последней проверки сервера; false - сервер
+// еще не проверялся
+func (a *Autoscaler) Explain(serverID string) (ScalingExplanation, bool) {
+    a.mu.RLock()
+    defer a.mu.RUnlock()
+    explanation, exists := a.explanations[serverID]
+    return explanation, exists
+}
+
+// newExplanation начинает объяснение проверки сервера с текущими порогами
+func (a *Autoscaler) newExplanation(serverID string, now time.Time) ScalingExplanation {
+    a.mu.RLock()
+    defer a.mu.RUnlock()
+    environment := ""
+    if a.environment != nil {
+        environment = a.environment(serverID)
+    }
+    thresholds := ScalingThresholds{
+        CPUThresholdHigh:   a.config.CPUThresholdHigh,
+        CPUThresholdLow:    a.config.CPUThresholdLow,
+        PowerThresholdHigh: a.config.PowerThresholdHigh,
+        ScaleUpCooldown:    a.config.ScaleUpCooldown,
+        ScaleDownCooldown:  a.config.ScaleDownCooldown,
+    }
+    if ready := a.lastScaleUp.Add(a.config.ScaleUpCooldown); ready.After(now) {
+        thresholds.ScaleUpReadyAt = ready
+    }
+    if ready := a.lastScaleDown.Add(a.config.ScaleDownCooldown); ready.After(now) {
+        thresholds.ScaleDownReadyAt = ready
+    }
+    return ScalingExplanation{
+        ServerID:    serverID,
+        Decision:    DecisionNone,
+        Reasons:     []string{},
+        Thresholds:  thresholds,
+        Objectives:  a.config.Objectives.For(environment),
+        Candidates:  []ScalingCandidate{},
+        ExplainedAt: now,
+    }
+}
+
+// because добавляет причину решения
+func (e *ScalingExplanation) because(format string, args ...interface{}) {
+    e.Reasons = append(e.Reasons, fmt.Sprintf(format, args...))
+}
+
+// record сохраняет объяснение проверки сервера
+func (a *Autoscaler) record(explanation ScalingExplanation) {
+    a.mu.Lock()
+    defer a.mu.Unlock()
+    a.explanations[explanation.ServerID] = explanation
+}
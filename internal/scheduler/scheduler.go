@@ -0,0 +1,222 @@
+// Package scheduler запускает периодические задачи подсистем: именованные
+// задачи с интервалом и джиттером, без наложения запусков, с перехватом
+// паники и статусом последнего запуска для /status.
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/YumeNoTenshi/platypus/internal/errtrack"
+	"github.com/YumeNoTenshi/platypus/internal/supervisor"
+)
+
+// Job - периодическая задача
+type Job struct {
+	Name     string
+	Interval time.Duration
+	Jitter   time.Duration // Случайная добавка к каждому интервалу; 0 - доля из Config
+	// Immediate - первый запуск сразу после старта, а не через интервал
+	Immediate bool
+	// Wake - необязательный канал внеочередного запуска. Сигналы, пришедшие
+	// во время выполнения, схлопываются в один следующий запуск.
+	Wake <-chan struct{}
+	Run  func(ctx context.Context) error
+}
+
+// Config задает общие параметры планировщика
+type Config struct {
+	JitterFraction float64 // Доля интервала для джиттера задач без явного Jitter
+	// Errors получает исход каждого запуска под именем задачи. Без него
+	// ошибки только пишутся в журнал.
+	Errors errtrack.Recorder
+	// Panics получает перехваченные паники задач (*supervisor.PanicError со
+	// стеком). Без него паника пишется в журнал.
+	Panics func(job string, err error)
+}
+
+// JobStatus - состояние задачи для /status
+type JobStatus struct {
+	Name         string        `json:"name"`
+	Interval     time.Duration `json:"interval"`
+	Running      bool          `json:"running"`
+	Runs         int           `json:"runs"`
+	Failures     int           `json:"failures"`
+	Panics       int           `json:"panics"`
+	LastStart    time.Time     `json:"last_start,omitempty"`
+	LastDuration time.Duration `json:"last_duration"`
+	LastSuccess  time.Time     `json:"last_success,omitempty"`
+	LastError    string        `json:"last_error,omitempty"`
+	NextRun      time.Time     `json:"next_run,omitempty"`
+}
+
+type job struct {
+	Job
+	status JobStatus
+}
+
+// Scheduler выполняет зарегистрированные задачи. Каждая задача работает в
+// своей горутине, поэтому ее запуски никогда не накладываются друг на друга.
+type Scheduler struct {
+	config Config
+
+	mu      sync.Mutex
+	jobs    map[string]*job
+	ctx     context.Context // Не nil после Start: поздние задачи запускаются сразу
+	running sync.WaitGroup
+}
+
+func New(config Config) *Scheduler {
+	return &Scheduler{
+		config: config,
+		jobs:   make(map[string]*job),
+	}
+}
+
+// Register добавляет задачи. Имена должны быть уникальны.
+func (s *Scheduler) Register(jobs ...Job) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, j := range jobs {
+		if j.Name == "" || j.Run == nil {
+			return fmt.Errorf("job must have a name and a run function")
+		}
+		if j.Interval <= 0 {
+			return fmt.Errorf("job %s: interval must be positive", j.Name)
+		}
+		if _, exists := s.jobs[j.Name]; exists {
+			return fmt.Errorf("job %s already registered", j.Name)
+		}
+		if j.Jitter == 0 {
+			j.Jitter = time.Duration(float64(j.Interval) * s.config.JitterFraction)
+		}
+
+		registered := &job{Job: j, status: JobStatus{Name: j.Name, Interval: j.Interval}}
+		s.jobs[j.Name] = registered
+		if s.ctx != nil {
+			s.launch(registered)
+		}
+	}
+	return nil
+}
+
+// Start запускает все задачи и блокируется до отмены контекста и
+// завершения выполняющихся запусков
+func (s *Scheduler) Start(ctx context.Context) error {
+	s.mu.Lock()
+	s.ctx = ctx
+	for _, j := range s.jobs {
+		s.launch(j)
+	}
+	s.mu.Unlock()
+
+	<-ctx.Done()
+	s.running.Wait()
+	return ctx.Err()
+}
+
+// Status возвращает состояние всех задач, упорядоченное по имени
+func (s *Scheduler) Status() []JobStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	statuses := make([]JobStatus, 0, len(s.jobs))
+	for _, j := range s.jobs {
+		statuses = append(statuses, j.status)
+	}
+	sort.Slice(statuses, func(i, k int) bool { return statuses[i].Name < statuses[k].Name })
+	return statuses
+}
+
+// launch запускает цикл задачи. Вызывается под блокировкой.
+func (s *Scheduler) launch(j *job) {
+	s.running.Add(1)
+	go func() {
+		defer s.running.Done()
+		s.loop(s.ctx, j)
+	}()
+}
+
+func (s *Scheduler) loop(ctx context.Context, j *job) {
+	first := s.delay(j)
+	if j.Immediate {
+		first = 0
+	}
+	timer := time.NewTimer(first)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		case <-j.Wake:
+			timer.Stop()
+		}
+
+		s.execute(ctx, j)
+		timer.Reset(s.delay(j))
+	}
+}
+
+// delay возвращает паузу до следующего запуска и запоминает его время
+func (s *Scheduler) delay(j *job) time.Duration {
+	delay := j.Interval
+	if j.Jitter > 0 {
+		delay += rand.N(j.Jitter)
+	}
+
+	s.mu.Lock()
+	j.status.NextRun = time.Now().Add(delay)
+	s.mu.Unlock()
+	return delay
+}
+
+// execute выполняет один запуск, перехватывая панику
+func (s *Scheduler) execute(ctx context.Context, j *job) {
+	started := time.Now()
+	s.mu.Lock()
+	j.status.Running = true
+	j.status.LastStart = started
+	s.mu.Unlock()
+
+	err := supervisor.Call(func() error { return j.Run(ctx) })
+	var panicErr *supervisor.PanicError
+	panicked := errors.As(err, &panicErr)
+	if panicked {
+		if s.config.Panics != nil {
+			s.config.Panics(j.Name, err)
+		} else {
+			log.Printf("Паника в периодической задаче %s: %v\n%s", j.Name, panicErr.Value, panicErr.Stack)
+		}
+	}
+
+	s.mu.Lock()
+	j.status.Running = false
+	j.status.Runs++
+	j.status.LastDuration = time.Since(started)
+	if panicked {
+		j.status.Panics++
+	}
+	if err != nil {
+		j.status.Failures++
+		j.status.LastError = err.Error()
+	} else {
+		j.status.LastSuccess = time.Now()
+		j.status.LastError = ""
+	}
+	s.mu.Unlock()
+
+	if s.config.Errors != nil {
+		s.config.Errors.Record(j.Name, err)
+	} else if err != nil {
+		log.Printf("Задача %s завершилась с ошибкой: %v", j.Name, err)
+	}
+}
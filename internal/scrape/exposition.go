@@ -0,0 +1,137 @@
+package scrape
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// sample - одна серия текстового формата Prometheus/OpenMetrics
+type sample struct {
+	name   string
+	labels map[string]string
+	value  float64
+}
+
+// parseExposition разбирает текстовый формат экспозиции Prometheus (0.0.4) и
+// совместимый с ним OpenMetrics. Комментарии, HELP и TYPE пропускаются: тип
+// серии понятен по имени (_total - счетчик).
+func parseExposition(r io.Reader) ([]sample, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64<<10), 1<<20)
+
+	var samples []sample
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		s, err := parseSample(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNo, err)
+		}
+		samples = append(samples, s)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return samples, nil
+}
+
+// parseSample разбирает строку name{label="value",...} value [timestamp]
+func parseSample(line string) (sample, error) {
+	end := strings.IndexAny(line, "{ \t")
+	if end <= 0 {
+		return sample{}, fmt.Errorf("missing value")
+	}
+	s := sample{name: line[:end]}
+	rest := line[end:]
+
+	if rest[0] == '{' {
+		labels, remaining, err := parseLabels(rest[1:])
+		if err != nil {
+			return sample{}, err
+		}
+		s.labels = labels
+		rest = remaining
+	}
+
+	fields := strings.Fields(rest)
+	if len(fields) == 0 {
+		return sample{}, fmt.Errorf("missing value")
+	}
+	value, err := parseValue(fields[0])
+	if err != nil {
+		return sample{}, err
+	}
+	s.value = value
+	return s, nil
+}
+
+// parseLabels разбирает метки до закрывающей скобки и возвращает остаток строки
+func parseLabels(text string) (map[string]string, string, error) {
+	labels := make(map[string]string)
+	for {
+		text = strings.TrimLeft(text, " \t,")
+		if text == "" {
+			return nil, "", fmt.Errorf("unterminated label set")
+		}
+		if text[0] == '}' {
+			return labels, text[1:], nil
+		}
+
+		eq := strings.IndexByte(text, '=')
+		if eq <= 0 || len(text) < eq+2 || text[eq+1] != '"' {
+			return nil, "", fmt.Errorf("invalid label in %q", text)
+		}
+		name := strings.TrimSpace(text[:eq])
+		text = text[eq+2:]
+
+		var value strings.Builder
+		closed := false
+		for i := 0; i < len(text); i++ {
+			c := text[i]
+			if c == '\\' && i+1 < len(text) {
+				i++
+				switch text[i] {
+				case 'n':
+					value.WriteByte('\n')
+				default:
+					value.WriteByte(text[i])
+				}
+				continue
+			}
+			if c == '"' {
+				text = text[i+1:]
+				closed = true
+				break
+			}
+			value.WriteByte(c)
+		}
+		if !closed {
+			return nil, "", fmt.Errorf("unterminated label value for %s", name)
+		}
+		labels[name] = value.String()
+	}
+}
+
+func parseValue(text string) (float64, error) {
+	switch text {
+	case "+Inf", "Inf":
+		return math.Inf(1), nil
+	case "-Inf":
+		return math.Inf(-1), nil
+	case "NaN":
+		return math.NaN(), nil
+	}
+	value, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value %q", text)
+	}
+	return value, nil
+}
@@ -0,0 +1,356 @@
+// Package scrape опрашивает экспортеры Prometheus (node_exporter, экспортеры
+// RAPL) по списку целей и передает сборщику мощность и загрузку хостов. Хостам
+// не нужно ничего отправлять самим: сервер сам забирает показания по HTTP.
+package scrape
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/YumeNoTenshi/platypus/internal/metrics"
+	"github.com/YumeNoTenshi/platypus/internal/models"
+	"github.com/YumeNoTenshi/platypus/internal/scheduler"
+)
+
+const (
+	defaultSource   = "node_exporter"
+	defaultInterval = 30 * time.Second
+	defaultTimeout  = 10 * time.Second
+	maxBodyBytes    = 16 << 20
+	maxConcurrent   = 8
+)
+
+// raplMetrics - счетчики энергии node_exporter (коллектор rapl). Домен core
+// входит в package и не суммируется.
+var raplMetrics = []string{"node_rapl_package_joules_total", "node_rapl_dram_joules_total"}
+
+// virtualInterfaces - префиксы виртуальных интерфейсов, трафик которых
+// дублирует трафик физических
+var virtualInterfaces = []string{"lo", "veth", "docker", "br-", "cni", "flannel", "virbr"}
+
+// Target - экспортер одного хоста
+type Target struct {
+	URL      string `json:"url"`                 // Например http://10.0.0.5:9100/metrics
+	ServerID string `json:"server_id,omitempty"` // Пусто - имя хоста из URL
+	// Source - источник для единиц измерения (PUT /ingest/sources/{source}/units);
+	// пусто - "node_exporter"
+	Source string `json:"source,omitempty"`
+	// PowerMetric - серия с мощностью вместо счетчиков RAPL. Имя на _joules_total -
+	// накопительный счетчик энергии, иначе датчик в ваттах; значения всех серий
+	// с этим именем суммируются.
+	PowerMetric string            `json:"power_metric,omitempty"`
+	Headers     map[string]string `json:"headers,omitempty"` // Например Authorization
+	Labels      map[string]string `json:"labels,omitempty"`  // Метки, добавляемые к точкам
+}
+
+func (t Target) validate() error {
+	parsed, err := url.Parse(t.URL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return fmt.Errorf("scrape target %q: url must be http(s)://host[:port]/path", t.URL)
+	}
+	return nil
+}
+
+func (t Target) serverID() string {
+	if t.ServerID != "" {
+		return t.ServerID
+	}
+	parsed, _ := url.Parse(t.URL)
+	return parsed.Hostname()
+}
+
+// LoadTargets читает цели из JSON-файла (массив Target)
+func LoadTargets(path string) ([]Target, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var targets []Target
+	if err := json.Unmarshal(data, &targets); err != nil {
+		return nil, fmt.Errorf("invalid scrape targets file %s: %w", path, err)
+	}
+	seen := make(map[string]bool, len(targets))
+	for _, target := range targets {
+		if err := target.validate(); err != nil {
+			return nil, err
+		}
+		if seen[target.serverID()] {
+			return nil, fmt.Errorf("duplicate scrape target for server %s", target.serverID())
+		}
+		seen[target.serverID()] = true
+	}
+	return targets, nil
+}
+
+type Config struct {
+	Targets  []Target
+	Interval time.Duration // Как часто опрашивать цели
+	Timeout  time.Duration // Таймаут одного опроса
+}
+
+// TargetStatus - состояние опроса цели для /status
+type TargetStatus struct {
+	URL        string        `json:"url"`
+	ServerID   string        `json:"server_id"`
+	Health     string        `json:"health"` // up, down, unknown
+	LastScrape time.Time     `json:"last_scrape,omitempty"`
+	Duration   time.Duration `json:"duration"`
+	Series     int           `json:"series"`
+	Points     uint64        `json:"points"`
+	LastError  string        `json:"last_error,omitempty"`
+}
+
+// counters - накопительные счетчики хоста из одного опроса
+type counters struct {
+	at        time.Time
+	cpuBusy   float64
+	cpuTotal  float64
+	diskRead  float64
+	diskWrite float64
+	netRx     float64
+	netTx     float64
+}
+
+type target struct {
+	Target
+	previous *counters
+	status   TargetStatus
+}
+
+// Scraper периодически опрашивает цели
+type Scraper struct {
+	config    Config
+	collector *metrics.Collector
+	client    *http.Client
+
+	mu      sync.Mutex
+	targets []*target
+}
+
+func NewScraper(config Config, collector *metrics.Collector) (*Scraper, error) {
+	if len(config.Targets) == 0 {
+		return nil, fmt.Errorf("at least one scrape target is required")
+	}
+	if config.Interval <= 0 {
+		config.Interval = defaultInterval
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = defaultTimeout
+	}
+
+	s := &Scraper{
+		config:    config,
+		collector: collector,
+		client:    &http.Client{Timeout: config.Timeout},
+	}
+	for _, t := range config.Targets {
+		if err := t.validate(); err != nil {
+			return nil, err
+		}
+		if t.Source == "" {
+			t.Source = defaultSource
+		}
+		s.targets = append(s.targets, &target{
+			Target: t,
+			status: TargetStatus{URL: t.URL, ServerID: t.serverID(), Health: "unknown"},
+		})
+	}
+	return s, nil
+}
+
+// Jobs возвращает периодический опрос целей
+func (s *Scraper) Jobs() []scheduler.Job {
+	return []scheduler.Job{{
+		Name:      "scrape.targets",
+		Interval:  s.config.Interval,
+		Immediate: true,
+		Run:       s.ScrapeAll,
+	}}
+}
+
+// ScrapeAll опрашивает все цели параллельно. Недоступная цель не мешает
+// остальным; ошибки возвращаются вместе.
+func (s *Scraper) ScrapeAll(ctx context.Context) error {
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		failures []error
+	)
+	limit := make(chan struct{}, maxConcurrent)
+	for _, t := range s.targets {
+		wg.Add(1)
+		limit <- struct{}{}
+		go func(t *target) {
+			defer wg.Done()
+			defer func() { <-limit }()
+			if err := s.scrape(ctx, t); err != nil {
+				mu.Lock()
+				failures = append(failures, fmt.Errorf("%s: %w", t.URL, err))
+				mu.Unlock()
+			}
+		}(t)
+	}
+	wg.Wait()
+	return errors.Join(failures...)
+}
+
+// Status возвращает состояние опроса целей
+func (s *Scraper) Status() []TargetStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	status := make([]TargetStatus, 0, len(s.targets))
+	for _, t := range s.targets {
+		status = append(status, t.status)
+	}
+	return status
+}
+
+func (s *Scraper) scrape(ctx context.Context, t *target) error {
+	started := time.Now()
+	samples, err := s.fetch(ctx, t.Target)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t.status.LastScrape = started
+	t.status.Duration = time.Since(started)
+	if err != nil {
+		t.status.Health = "down"
+		t.status.LastError = err.Error()
+		return err
+	}
+	t.status.Health = "up"
+	t.status.LastError = ""
+	t.status.Series = len(samples)
+
+	point, current := convert(samples, t.Target, t.previous, started)
+	t.previous = current
+	if point == nil {
+		return nil // Первый опрос только запоминает счетчики
+	}
+	point.ServerID = t.serverID()
+	point.Labels = t.Labels
+	if err := s.collector.CollectMetricsFrom(t.Source, point.ServerID, *point); err != nil {
+		return err
+	}
+	t.status.Points++
+	return nil
+}
+
+func (s *Scraper) fetch(ctx context.Context, t Target) ([]sample, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, t.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "text/plain;version=0.0.4;q=1,*/*;q=0.1")
+	req.Header.Set("User-Agent", "platypus-scraper")
+	for name, value := range t.Headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("target responded with status %d", resp.StatusCode)
+	}
+	return parseExposition(io.LimitReader(resp.Body, maxBodyBytes))
+}
+
+// convert превращает серии экспортера в точку. Загрузка CPU, дисков и сети
+// считается по приращению счетчиков с прошлого опроса, поэтому без
+// предыдущего опроса точки нет (nil).
+func convert(samples []sample, t Target, previous *counters, at time.Time) (*models.MetricData, *counters) {
+	current := &counters{at: at}
+	var memTotal, memAvailable, energy, power float64
+	energySeries := make(map[string]bool, len(raplMetrics))
+	for _, name := range raplMetrics {
+		energySeries[name] = true
+	}
+	powerIsEnergy := t.PowerMetric != "" && strings.HasSuffix(t.PowerMetric, "_joules_total")
+
+	for _, s := range samples {
+		if math.IsNaN(s.value) || math.IsInf(s.value, 0) {
+			continue
+		}
+		switch {
+		case s.name == "node_cpu_seconds_total":
+			current.cpuTotal += s.value
+			if mode := s.labels["mode"]; mode != "idle" && mode != "iowait" {
+				current.cpuBusy += s.value
+			}
+		case s.name == "node_memory_MemTotal_bytes":
+			memTotal = s.value
+		case s.name == "node_memory_MemAvailable_bytes":
+			memAvailable = s.value
+		case s.name == "node_disk_read_bytes_total" && !strings.HasPrefix(s.labels["device"], "dm-"):
+			current.diskRead += s.value
+		case s.name == "node_disk_written_bytes_total" && !strings.HasPrefix(s.labels["device"], "dm-"):
+			current.diskWrite += s.value
+		case s.name == "node_network_receive_bytes_total" && physical(s.labels["device"]):
+			current.netRx += s.value
+		case s.name == "node_network_transmit_bytes_total" && physical(s.labels["device"]):
+			current.netTx += s.value
+		case t.PowerMetric != "" && s.name == t.PowerMetric && powerIsEnergy:
+			energy += s.value
+		case t.PowerMetric != "" && s.name == t.PowerMetric:
+			power += s.value
+		case t.PowerMetric == "" && energySeries[s.name]:
+			energy += s.value
+		}
+	}
+
+	if previous == nil {
+		return nil, current
+	}
+	elapsed := at.Sub(previous.at).Seconds()
+	if elapsed <= 0 {
+		return nil, current
+	}
+
+	point := &models.MetricData{
+		Timestamp:     at.Unix(),
+		PowerUsage:    power,
+		EnergyCounter: energy, // Мощность по счетчику вычисляет сборщик
+	}
+	if memTotal > 0 {
+		point.MemoryUsage = 100 * (memTotal - memAvailable) / memTotal
+	}
+	// Уменьшение счетчика - перезапуск экспортера; такое приращение пропускается
+	if delta := current.cpuTotal - previous.cpuTotal; delta > 0 && current.cpuBusy >= previous.cpuBusy {
+		point.CPUUsage = math.Min(100, 100*(current.cpuBusy-previous.cpuBusy)/delta)
+	}
+	if delta := current.diskRead - previous.diskRead; delta >= 0 {
+		point.DiskReadBytesPerSec = delta / elapsed
+	}
+	if delta := current.diskWrite - previous.diskWrite; delta >= 0 {
+		point.DiskWriteBytesPerSec = delta / elapsed
+	}
+	if delta := current.netRx - previous.netRx; delta >= 0 {
+		point.NetworkRxBytes = delta
+	}
+	if delta := current.netTx - previous.netTx; delta >= 0 {
+		point.NetworkTxBytes = delta
+	}
+	return point, current
+}
+
+func physical(device string) bool {
+	for _, prefix := range virtualInterfaces {
+		if strings.HasPrefix(device, prefix) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,318 @@
+// Package selector разбирает селекторы меток - общий язык выборки для
+// точек метрик, групп серверов, бюджетов и списков API. Синтаксис повторяет
+// селекторы меток Kubernetes: условия через запятую, все должны выполняться.
+//
+//	environment=prod          метка равна значению (== - то же)
+//	team!=infra               метка не равна значению или отсутствует
+//	region in (eu-west,eu-north)
+//	tier notin (batch)
+//	gpu                       метка есть
+//	!spot                     метки нет
+//
+// Пустое значение (owner=) выбирает объекты без метки.
+package selector
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// ErrInvalid - селектор не разобран
+var ErrInvalid = errors.New("invalid selector")
+
+// Operator - отношение метки к значениям условия
+type Operator string
+
+const (
+	Equals       Operator = "="
+	NotEquals    Operator = "!="
+	In           Operator = "in"
+	NotIn        Operator = "notin"
+	Exists       Operator = "exists"
+	DoesNotExist Operator = "!"
+)
+
+// keyPattern - допустимое имя метки: правила Prometheus и точки, дефисы и
+// косые черты облачных тегов и меток Kubernetes (app.kubernetes.io/name)
+var keyPattern = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_./-]*$`)
+
+// Requirement - одно условие селектора
+type Requirement struct {
+	Key      string   `json:"key"`
+	Operator Operator `json:"operator"`
+	Values   []string `json:"values,omitempty"`
+}
+
+// Matches проверяет условие на наборе меток
+func (r Requirement) Matches(labels map[string]string) bool {
+	value, exists := labels[r.Key]
+	switch r.Operator {
+	case Equals:
+		return value == r.Values[0]
+	case NotEquals:
+		return value != r.Values[0]
+	case In:
+		return contains(r.Values, value)
+	case NotIn:
+		return !contains(r.Values, value)
+	case Exists:
+		return exists && value != ""
+	case DoesNotExist:
+		return !exists || value == ""
+	}
+	return false
+}
+
+func (r Requirement) String() string {
+	switch r.Operator {
+	case Exists:
+		return r.Key
+	case DoesNotExist:
+		return "!" + r.Key
+	case In, NotIn:
+		return r.Key + " " + string(r.Operator) + " (" + strings.Join(r.Values, ",") + ")"
+	}
+	return r.Key + string(r.Operator) + r.Values[0]
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// Selector - условия, которые должны выполняться все. Пустой селектор
+// выбирает всё.
+type Selector []Requirement
+
+// Parse разбирает селектор. Пустая строка - пустой селектор.
+func Parse(value string) (Selector, error) {
+	p := &parser{input: value}
+	var selector Selector
+	for {
+		p.skipSpace()
+		if p.done() {
+			break
+		}
+		requirement, err := p.requirement()
+		if err != nil {
+			return nil, fmt.Errorf("%w %q: %v", ErrInvalid, value, err)
+		}
+		selector = append(selector, requirement)
+
+		p.skipSpace()
+		if p.done() {
+			break
+		}
+		if !p.consume(",") {
+			return nil, fmt.Errorf("%w %q: expected ',' at position %d", ErrInvalid, value, p.pos+1)
+		}
+	}
+	return selector, nil
+}
+
+// MustParse - Parse для селекторов в коде; паникует на ошибке
+func MustParse(value string) Selector {
+	selector, err := Parse(value)
+	if err != nil {
+		panic(err)
+	}
+	return selector
+}
+
+// FromMap создает селектор из условий равенства, например сохраненных в
+// прежнем виде {"team": "payments"}
+func FromMap(labels map[string]string) Selector {
+	keys := make([]string, 0, len(labels))
+	for key := range labels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	selector := make(Selector, 0, len(keys))
+	for _, key := range keys {
+		selector = append(selector, Requirement{Key: key, Operator: Equals, Values: []string{labels[key]}})
+	}
+	return selector
+}
+
+// Empty сообщает, что селектор ничего не ограничивает
+func (s Selector) Empty() bool {
+	return len(s) == 0
+}
+
+// Matches сообщает, что метки удовлетворяют всем условиям
+func (s Selector) Matches(labels map[string]string) bool {
+	for _, requirement := range s {
+		if !requirement.Matches(labels) {
+			return false
+		}
+	}
+	return true
+}
+
+// Value возвращает значение, которое селектор требует от метки условием
+// равенства, например сервис выборки для пометок
+func (s Selector) Value(key string) (string, bool) {
+	for _, requirement := range s {
+		if requirement.Key == key && (requirement.Operator == Equals || requirement.Operator == In && len(requirement.Values) == 1) {
+			return requirement.Values[0], true
+		}
+	}
+	return "", false
+}
+
+// String возвращает селектор в каноническом виде, пригодном для Parse
+func (s Selector) String() string {
+	parts := make([]string, len(s))
+	for i, requirement := range s {
+		parts[i] = requirement.String()
+	}
+	return strings.Join(parts, ",")
+}
+
+// MarshalJSON сохраняет селектор строкой
+func (s Selector) MarshalJSON() ([]byte, error) {
+	return []byte(fmt.Sprintf("%q", s.String())), nil
+}
+
+// UnmarshalJSON принимает строку селектора или объект равенств прежнего
+// формата {"team": "payments"}
+func (s *Selector) UnmarshalJSON(data []byte) error {
+	trimmed := strings.TrimSpace(string(data))
+	if trimmed == "null" {
+		*s = nil
+		return nil
+	}
+	if strings.HasPrefix(trimmed, "{") {
+		var labels map[string]string
+		if err := json.Unmarshal(data, &labels); err != nil {
+			return err
+		}
+		for key := range labels {
+			if !keyPattern.MatchString(key) {
+				return fmt.Errorf("%w: invalid label name %q", ErrInvalid, key)
+			}
+		}
+		*s = FromMap(labels)
+		return nil
+	}
+
+	var value string
+	if err := json.Unmarshal(data, &value); err != nil {
+		return fmt.Errorf("%w: selector must be a string or an object of labels", ErrInvalid)
+	}
+	parsed, err := Parse(value)
+	if err != nil {
+		return err
+	}
+	*s = parsed
+	return nil
+}
+
+type parser struct {
+	input string
+	pos   int
+}
+
+func (p *parser) done() bool {
+	return p.pos >= len(p.input)
+}
+
+func (p *parser) skipSpace() {
+	for !p.done() && unicode.IsSpace(rune(p.input[p.pos])) {
+		p.pos++
+	}
+}
+
+func (p *parser) consume(token string) bool {
+	if strings.HasPrefix(p.input[p.pos:], token) {
+		p.pos += len(token)
+		return true
+	}
+	return false
+}
+
+// word читает имя или значение: до пробела, запятой, скобки или оператора
+func (p *parser) word() string {
+	start := p.pos
+	for !p.done() && !strings.ContainsRune(" \t,()=!", rune(p.input[p.pos])) {
+		p.pos++
+	}
+	return p.input[start:p.pos]
+}
+
+func (p *parser) key() (string, error) {
+	p.skipSpace()
+	key := p.word()
+	if key == "" {
+		return "", fmt.Errorf("expected label name at position %d", p.pos+1)
+	}
+	if !keyPattern.MatchString(key) {
+		return "", fmt.Errorf("invalid label name %q", key)
+	}
+	return key, nil
+}
+
+func (p *parser) requirement() (Requirement, error) {
+	if p.consume("!") {
+		key, err := p.key()
+		if err != nil {
+			return Requirement{}, err
+		}
+		return Requirement{Key: key, Operator: DoesNotExist}, nil
+	}
+
+	key, err := p.key()
+	if err != nil {
+		return Requirement{}, err
+	}
+	p.skipSpace()
+	switch {
+	case p.done() || p.input[p.pos] == ',':
+		return Requirement{Key: key, Operator: Exists}, nil
+	case p.consume("!="):
+		return p.single(key, NotEquals)
+	case p.consume("=="), p.consume("="):
+		return p.single(key, Equals)
+	}
+
+	// in и notin - слова, за которыми идет список в скобках
+	operator := Operator(p.word())
+	if operator != In && operator != NotIn {
+		return Requirement{}, fmt.Errorf("unknown operator after %s: expected =, ==, !=, in or notin", key)
+	}
+	p.skipSpace()
+	if !p.consume("(") {
+		return Requirement{}, fmt.Errorf("expected '(' after %s %s", key, operator)
+	}
+	var values []string
+	for {
+		p.skipSpace()
+		values = append(values, p.word())
+		p.skipSpace()
+		if p.consume(")") {
+			break
+		}
+		if !p.consume(",") {
+			return Requirement{}, fmt.Errorf("expected ',' or ')' in values of %s", key)
+		}
+	}
+	return Requirement{Key: key, Operator: operator, Values: values}, nil
+}
+
+func (p *parser) single(key string, operator Operator) (Requirement, error) {
+	p.skipSpace()
+	value := p.word()
+	if !p.done() && strings.ContainsRune("()=!", rune(p.input[p.pos])) {
+		return Requirement{}, fmt.Errorf("unexpected %q in value of %s", p.input[p.pos], key)
+	}
+	return Requirement{Key: key, Operator: operator, Values: []string{value}}, nil
+}
@@ -0,0 +1,430 @@
+// Package statsd принимает оценки мощности и загрузки, которые сервисы
+// отправляют по протоколу StatsD или DogStatsD (UDP). Имена метрик
+// сопоставляются серверам и полям точки по правилам; значения копятся и раз в
+// интервал сброса передаются сборщику одной точкой на сервер.
+package statsd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/YumeNoTenshi/platypus/internal/errtrack"
+	"github.com/YumeNoTenshi/platypus/internal/metrics"
+	"github.com/YumeNoTenshi/platypus/internal/models"
+	"github.com/YumeNoTenshi/platypus/internal/scheduler"
+)
+
+const (
+	defaultSource        = "statsd"
+	defaultFlushInterval = 10 * time.Second
+	maxPacketSize        = 65535
+)
+
+// placeholder - ссылка на часть имени ({1}) или тег DogStatsD ({tag:host}) в ServerID
+var placeholder = regexp.MustCompile(`\{(\d+|tag:[^}]+)\}`)
+
+// fields - поля точки, которые можно заполнить из StatsD
+var fields = map[string]func(m *models.MetricData, value float64){
+	"power_usage":      func(m *models.MetricData, v float64) { m.PowerUsage = v },
+	"carbon_footprint": func(m *models.MetricData, v float64) { m.CarbonFootprint = v },
+	"cpu_usage":        func(m *models.MetricData, v float64) { m.CPUUsage = v },
+	"memory_usage":     func(m *models.MetricData, v float64) { m.MemoryUsage = v },
+	"gpu_usage":        func(m *models.MetricData, v float64) { m.GPUUsage = v },
+	"gpu_power_usage":  func(m *models.MetricData, v float64) { m.GPUPowerUsage = v },
+	"inlet_temp_c":     func(m *models.MetricData, v float64) { m.InletTemperature = v },
+}
+
+// Mapping сопоставляет имя метрики серверу и полю точки. В Name части,
+// разделенные точками, можно заменить на *; в ServerID {1}, {2}... заменяются
+// частями имени, совпавшими со * по порядку, а {tag:name} - значением тега
+// DogStatsD: "svc.*.power_watts" и "{1}" дают сервер из второй части имени.
+type Mapping struct {
+	Name     string `json:"name"`
+	ServerID string `json:"server_id"`
+	Field    string `json:"field"` // power_usage, cpu_usage, memory_usage, ...
+	// Source - источник для единиц измерения (PUT /ingest/sources/{source}/units),
+	// например если сервис отправляет милливатты; пусто - "statsd"
+	Source string `json:"source,omitempty"`
+	// Labels - теги DogStatsD, которые становятся метками точки (team, environment)
+	Labels []string `json:"labels,omitempty"`
+}
+
+type Config struct {
+	Address       string // UDP-адрес, например :8125
+	Mappings      []Mapping
+	FlushInterval time.Duration // Как часто накопленные значения передаются сборщику
+	// Errors получает исход приема под именем "metrics.statsd"
+	Errors errtrack.Recorder
+}
+
+// LoadMappings читает правила сопоставления из JSON-файла (массив Mapping)
+func LoadMappings(path string) ([]Mapping, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var mappings []Mapping
+	if err := json.Unmarshal(data, &mappings); err != nil {
+		return nil, fmt.Errorf("invalid statsd mapping file %s: %w", path, err)
+	}
+	for _, mapping := range mappings {
+		if err := mapping.validate(); err != nil {
+			return nil, err
+		}
+	}
+	return mappings, nil
+}
+
+func (m Mapping) validate() error {
+	if m.Name == "" || m.ServerID == "" {
+		return fmt.Errorf("statsd mapping requires name and server_id")
+	}
+	if _, exists := fields[m.Field]; !exists {
+		return fmt.Errorf("statsd mapping %s: unknown field %q", m.Name, m.Field)
+	}
+	wildcards := 0
+	for _, part := range strings.Split(m.Name, ".") {
+		switch {
+		case part == "*":
+			wildcards++
+		case strings.Contains(part, "*"):
+			return fmt.Errorf("statsd name %s: * must occupy a whole part", m.Name)
+		}
+	}
+	for _, ref := range placeholder.FindAllStringSubmatch(m.ServerID, -1) {
+		if strings.HasPrefix(ref[1], "tag:") {
+			continue
+		}
+		if n, _ := strconv.Atoi(ref[1]); n < 1 || n > wildcards {
+			return fmt.Errorf("statsd mapping %s: server_id refers to %s, name has %d wildcards", m.Name, ref[0], wildcards)
+		}
+	}
+	return nil
+}
+
+// match проверяет имя по шаблону и возвращает части, совпавшие со *
+func match(pattern, name string) ([]string, bool) {
+	patternParts := strings.Split(pattern, ".")
+	nameParts := strings.Split(name, ".")
+	if len(patternParts) != len(nameParts) {
+		return nil, false
+	}
+	var captured []string
+	for i, part := range patternParts {
+		switch part {
+		case "*":
+			captured = append(captured, nameParts[i])
+		case nameParts[i]:
+		default:
+			return nil, false
+		}
+	}
+	return captured, true
+}
+
+// serverID подставляет части имени и теги; пусто - нужного тега нет
+func (m Mapping) serverID(captured []string, tags map[string]string) string {
+	missing := false
+	serverID := placeholder.ReplaceAllStringFunc(m.ServerID, func(ref string) string {
+		key := ref[1 : len(ref)-1]
+		if tag, found := strings.CutPrefix(key, "tag:"); found {
+			if tags[tag] == "" {
+				missing = true
+			}
+			return tags[tag]
+		}
+		n, _ := strconv.Atoi(key)
+		return captured[n-1]
+	})
+	if missing {
+		return ""
+	}
+	return serverID
+}
+
+// sample - одна строка протокола: name:value|type|@rate|#tags
+type sample struct {
+	name     string
+	value    float64
+	kind     string // g, c, ms, h, d, s
+	relative bool   // Значение датчика со знаком - приращение
+	tags     map[string]string
+}
+
+// parseLine разбирает строку StatsD/DogStatsD
+func parseLine(line string) (sample, error) {
+	name, rest, found := strings.Cut(line, ":")
+	if !found || name == "" {
+		return sample{}, fmt.Errorf("missing metric name")
+	}
+	parts := strings.Split(rest, "|")
+	if len(parts) < 2 {
+		return sample{}, fmt.Errorf("missing metric type")
+	}
+
+	s := sample{name: name, kind: parts[1]}
+	value, err := strconv.ParseFloat(parts[0], 64)
+	if err != nil {
+		return sample{}, fmt.Errorf("invalid value %q", parts[0])
+	}
+	s.value = value
+	s.relative = s.kind == "g" && (strings.HasPrefix(parts[0], "+") || strings.HasPrefix(parts[0], "-"))
+
+	for _, part := range parts[2:] {
+		switch {
+		case strings.HasPrefix(part, "@"):
+			// Частота выборки важна только для счетчиков, которые не принимаются
+		case strings.HasPrefix(part, "#"):
+			s.tags = make(map[string]string)
+			for _, tag := range strings.Split(part[1:], ",") {
+				key, value, _ := strings.Cut(tag, ":")
+				if key != "" {
+					s.tags[key] = value
+				}
+			}
+		}
+	}
+	return s, nil
+}
+
+// Stats - счетчики приема из StatsD для /status
+type Stats struct {
+	Address     string    `json:"address"`
+	Packets     uint64    `json:"packets"`
+	Lines       uint64    `json:"lines"`
+	Points      uint64    `json:"points"`
+	Unmapped    uint64    `json:"unmapped"`    // Строки метрик без правила
+	Malformed   uint64    `json:"malformed"`   // Строки, которые не удалось разобрать
+	Unsupported uint64    `json:"unsupported"` // Не датчики и не таймеры: счетчики и множества
+	Rejected    uint64    `json:"rejected"`    // Точки, отклоненные проверкой единиц
+	Dropped     uint64    `json:"dropped"`     // Точки, не принятые заполненным буфером сборщика
+	LastPacket  time.Time `json:"last_packet_at,omitempty"`
+	LastError   string    `json:"last_error,omitempty"`
+}
+
+// pending - значения сервера, накопленные до следующего сброса
+type pending struct {
+	source string
+	values map[string]float64 // Поле -> значение
+	labels map[string]string
+}
+
+// Listener принимает пакеты StatsD и передает значения сборщику
+type Listener struct {
+	config    Config
+	collector *metrics.Collector
+
+	mu      sync.Mutex
+	gauges  map[string]float64  // Сервер/поле -> последнее значение, для относительных датчиков
+	pending map[string]*pending // Сервер -> значения до сброса
+	stats   Stats
+}
+
+func NewListener(config Config, collector *metrics.Collector) (*Listener, error) {
+	if config.Address == "" {
+		return nil, fmt.Errorf("statsd address is required")
+	}
+	if len(config.Mappings) == 0 {
+		return nil, fmt.Errorf("at least one statsd mapping is required")
+	}
+	for _, mapping := range config.Mappings {
+		if err := mapping.validate(); err != nil {
+			return nil, err
+		}
+	}
+	if config.FlushInterval <= 0 {
+		config.FlushInterval = defaultFlushInterval
+	}
+	return &Listener{
+		config:    config,
+		collector: collector,
+		gauges:    make(map[string]float64),
+		pending:   make(map[string]*pending),
+		stats:     Stats{Address: config.Address},
+	}, nil
+}
+
+// Start принимает пакеты до отмены контекста. Накопленные значения
+// передает сборщику задача из Jobs; при остановке они сбрасываются сразу.
+func (l *Listener) Start(ctx context.Context) error {
+	conn, err := net.ListenPacket("udp", l.config.Address)
+	if err != nil {
+		l.fail(err)
+		return err
+	}
+	log.Printf("Прием StatsD на %s", conn.LocalAddr())
+	l.succeed()
+
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+
+	buffer := make([]byte, maxPacketSize)
+	for {
+		n, _, err := conn.ReadFrom(buffer)
+		if err != nil {
+			if ctx.Err() != nil {
+				l.flush(time.Now())
+				return ctx.Err()
+			}
+			l.fail(err)
+			return err
+		}
+		l.handle(buffer[:n], time.Now())
+	}
+}
+
+// Jobs возвращает сброс накопленных значений раз в FlushInterval
+func (l *Listener) Jobs() []scheduler.Job {
+	return []scheduler.Job{{
+		Name:     "statsd.flush",
+		Interval: l.config.FlushInterval,
+		Run: func(ctx context.Context) error {
+			l.flush(time.Now())
+			return nil
+		},
+	}}
+}
+
+// handle разбирает пакет: несколько строк, разделенных переводом строки
+func (l *Listener) handle(packet []byte, now time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.stats.Packets++
+	l.stats.LastPacket = now
+
+	for _, line := range strings.Split(string(packet), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		l.stats.Lines++
+
+		s, err := parseLine(line)
+		if err != nil {
+			l.stats.Malformed++
+			continue
+		}
+		// Датчики передают текущую оценку; таймеры и гистограммы - отдельные
+		// измерения, последнее из них считается текущим значением
+		switch s.kind {
+		case "g", "ms", "h", "d":
+		default:
+			l.stats.Unsupported++
+			continue
+		}
+
+		mapping, captured, found := l.mapping(s.name)
+		if !found {
+			l.stats.Unmapped++
+			continue
+		}
+		serverID := mapping.serverID(captured, s.tags)
+		if serverID == "" {
+			l.stats.Unmapped++
+			continue
+		}
+
+		key := serverID + "/" + mapping.Field
+		value := s.value
+		if s.relative {
+			value += l.gauges[key]
+		}
+		l.gauges[key] = value
+
+		source := mapping.Source
+		if source == "" {
+			source = defaultSource
+		}
+		p, exists := l.pending[serverID]
+		if !exists {
+			p = &pending{source: source, values: make(map[string]float64)}
+			l.pending[serverID] = p
+		}
+		p.values[mapping.Field] = value
+		for _, name := range mapping.Labels {
+			if tag := s.tags[name]; tag != "" {
+				if p.labels == nil {
+					p.labels = make(map[string]string)
+				}
+				p.labels[name] = tag
+			}
+		}
+	}
+}
+
+func (l *Listener) mapping(name string) (Mapping, []string, bool) {
+	for _, mapping := range l.config.Mappings {
+		if captured, ok := match(mapping.Name, name); ok {
+			return mapping, captured, true
+		}
+	}
+	return Mapping{}, nil, false
+}
+
+// flush передает сборщику по точке на сервер со значениями, полученными
+// после предыдущего сброса
+func (l *Listener) flush(now time.Time) {
+	l.mu.Lock()
+	batch := l.pending
+	l.pending = make(map[string]*pending)
+	l.mu.Unlock()
+
+	for serverID, p := range batch {
+		point := models.MetricData{
+			ServerID:  serverID,
+			Timestamp: now.Unix(),
+			Labels:    p.labels,
+		}
+		for field, value := range p.values {
+			fields[field](&point, value)
+		}
+		err := l.collector.CollectMetricsFrom(p.source, serverID, point)
+
+		l.mu.Lock()
+		switch {
+		case errors.Is(err, metrics.ErrBufferFull):
+			l.stats.Dropped++
+		case err != nil:
+			l.stats.Rejected++
+		default:
+			l.stats.Points++
+		}
+		l.mu.Unlock()
+	}
+}
+
+// Stats возвращает счетчики приема
+func (l *Listener) Stats() Stats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.stats
+}
+
+func (l *Listener) fail(err error) {
+	l.mu.Lock()
+	l.stats.LastError = err.Error()
+	l.mu.Unlock()
+	if l.config.Errors != nil {
+		l.config.Errors.Record("metrics.statsd", err)
+	} else {
+		log.Printf("Ошибка приема метрик StatsD: %v", err)
+	}
+}
+
+func (l *Listener) succeed() {
+	if l.config.Errors != nil {
+		l.config.Errors.Record("metrics.statsd", nil)
+	}
+}
@@ -0,0 +1,249 @@
+// Package supervisor запускает долгоживущие горутины подсистем (планировщик
+// миграций, предиктор, прием из MQTT и т.п.) так, чтобы паника одной из них
+// не останавливала сервер: паника перехватывается вместе со стеком, а
+// подсистема перезапускается с растущей паузой. Счетчики сбоев видны в
+// /status и в собственных метриках Prometheus.
+package supervisor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"runtime/debug"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/YumeNoTenshi/platypus/internal/errtrack"
+)
+
+// maxStackBytes ограничивает стек паники, сохраняемый для /status
+const maxStackBytes = 8 << 10
+
+// Состояния подсистемы
+const (
+	StateRunning = "running"
+	StateBackoff = "backoff" // Ждет перезапуска после сбоя
+	StateStopped = "stopped" // Завершилась без ошибки или контекст отменен
+)
+
+// PanicError - перехваченная паника
+type PanicError struct {
+	Value interface{}
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v", e.Value)
+}
+
+// Call выполняет fn и возвращает панику как *PanicError
+func Call(fn func() error) (err error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err = &PanicError{Value: recovered, Stack: debug.Stack()}
+		}
+	}()
+	return fn()
+}
+
+// Config задает паузы перезапуска
+type Config struct {
+	InitialBackoff time.Duration // Пауза после первого сбоя, по умолчанию 1s
+	MaxBackoff     time.Duration // Пауза удваивается до этого предела, по умолчанию 5m
+	// StableAfter - после такой работы без сбоев пауза снова начинается с
+	// InitialBackoff; по умолчанию 10m
+	StableAfter time.Duration
+	// Errors получает сбои подсистем, запущенных через Go, под именем
+	// "supervisor.<имя>": повторные падения вызывают оповещение
+	Errors errtrack.Recorder
+	// Registerer - реестр Prometheus для счетчиков подсистем; по умолчанию
+	// глобальный prometheus.DefaultRegisterer
+	Registerer prometheus.Registerer
+}
+
+// Status - состояние подсистемы для /status
+type Status struct {
+	Name        string    `json:"name"`
+	State       string    `json:"state,omitempty"` // Пусто - подсистема не запускается через Go и только сообщает о сбоях
+	Starts      int       `json:"starts"`
+	Restarts    int       `json:"restarts"`
+	Panics      int       `json:"panics"`
+	LastError   string    `json:"last_error,omitempty"`
+	LastPanicAt time.Time `json:"last_panic_at,omitempty"`
+	LastStack   string    `json:"last_stack,omitempty"`
+	NextRestart time.Time `json:"next_restart,omitempty"`
+}
+
+// Supervisor следит за подсистемами
+type Supervisor struct {
+	config Config
+
+	mu         sync.Mutex
+	subsystems map[string]*Status
+
+	panics   *prometheus.CounterVec
+	restarts *prometheus.CounterVec
+	up       *prometheus.GaugeVec
+}
+
+func New(config Config) *Supervisor {
+	if config.InitialBackoff <= 0 {
+		config.InitialBackoff = time.Second
+	}
+	if config.MaxBackoff < config.InitialBackoff {
+		config.MaxBackoff = 5 * time.Minute
+	}
+	if config.StableAfter <= 0 {
+		config.StableAfter = 10 * time.Minute
+	}
+	if config.Registerer == nil {
+		config.Registerer = prometheus.DefaultRegisterer
+	}
+
+	s := &Supervisor{
+		config:     config,
+		subsystems: make(map[string]*Status),
+		panics: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "platypus_subsystem_panics_total",
+			Help: "Panics recovered in background subsystems",
+		}, []string{"subsystem"}),
+		restarts: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "platypus_subsystem_restarts_total",
+			Help: "Restarts of background subsystems after a panic or an error",
+		}, []string{"subsystem"}),
+		up: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "platypus_subsystem_up",
+			Help: "Whether a supervised subsystem is running (1) or waiting to restart (0)",
+		}, []string{"subsystem"}),
+	}
+	config.Registerer.MustRegister(s.panics, s.restarts, s.up)
+	return s
+}
+
+// Go запускает подсистему в отдельной горутине. Если run паникует или
+// возвращает ошибку до отмены ctx, подсистема перезапускается после паузы.
+// run, вернувший nil, считается завершенным и не перезапускается.
+func (s *Supervisor) Go(ctx context.Context, name string, run func(ctx context.Context) error) {
+	s.mu.Lock()
+	if _, exists := s.subsystems[name]; !exists {
+		s.subsystems[name] = &Status{Name: name}
+	}
+	s.mu.Unlock()
+
+	go s.loop(ctx, name, run)
+}
+
+func (s *Supervisor) loop(ctx context.Context, name string, run func(ctx context.Context) error) {
+	backoff := s.config.InitialBackoff
+	for {
+		s.update(name, func(status *Status) {
+			status.State = StateRunning
+			status.Starts++
+			status.NextRestart = time.Time{}
+		})
+		s.up.WithLabelValues(name).Set(1)
+
+		started := time.Now()
+		err := Call(func() error { return run(ctx) })
+		if ctx.Err() != nil || err == nil {
+			s.update(name, func(status *Status) { status.State = StateStopped })
+			s.up.WithLabelValues(name).Set(0)
+			return
+		}
+
+		// Долгая работа без сбоев прерывает серию падений
+		if time.Since(started) >= s.config.StableAfter {
+			backoff = s.config.InitialBackoff
+			if s.config.Errors != nil {
+				s.config.Errors.Record("supervisor."+name, nil)
+			}
+		}
+		s.Report(name, err)
+		if s.config.Errors != nil {
+			s.config.Errors.Record("supervisor."+name, err)
+		}
+		log.Printf("Подсистема %s перезапускается через %s", name, backoff)
+
+		s.update(name, func(status *Status) {
+			status.State = StateBackoff
+			status.NextRestart = time.Now().Add(backoff)
+		})
+		s.up.WithLabelValues(name).Set(0)
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			s.update(name, func(status *Status) {
+				status.State = StateStopped
+				status.NextRestart = time.Time{}
+			})
+			return
+		case <-timer.C:
+		}
+		backoff = min(backoff*2, s.config.MaxBackoff)
+
+		s.update(name, func(status *Status) { status.Restarts++ })
+		s.restarts.WithLabelValues(name).Inc()
+	}
+}
+
+// Report учитывает сбой подсистемы, которую supervisor не запускает сам:
+// например, панику в запуске периодической задачи или в отдельной миграции.
+// Паника (*PanicError) пишется в журнал со стеком. Config.Errors такие сбои
+// не получает: их учитывает сама подсистема.
+func (s *Supervisor) Report(name string, err error) {
+	if err == nil {
+		return
+	}
+
+	var panicErr *PanicError
+	panicked := errors.As(err, &panicErr)
+	if panicked {
+		log.Printf("Паника в подсистеме %s: %v\n%s", name, panicErr.Value, panicErr.Stack)
+		s.panics.WithLabelValues(name).Inc()
+	} else {
+		log.Printf("Подсистема %s завершилась с ошибкой: %v", name, err)
+	}
+
+	s.update(name, func(status *Status) {
+		status.LastError = err.Error()
+		if panicked {
+			status.Panics++
+			status.LastPanicAt = time.Now()
+			stack := panicErr.Stack
+			if len(stack) > maxStackBytes {
+				stack = stack[:maxStackBytes]
+			}
+			status.LastStack = string(stack)
+		}
+	})
+}
+
+// Status возвращает состояние всех подсистем, упорядоченное по имени
+func (s *Supervisor) Status() []Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	statuses := make([]Status, 0, len(s.subsystems))
+	for _, status := range s.subsystems {
+		statuses = append(statuses, *status)
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Name < statuses[j].Name })
+	return statuses
+}
+
+func (s *Supervisor) update(name string, change func(status *Status)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	status, exists := s.subsystems[name]
+	if !exists {
+		status = &Status{Name: name}
+		s.subsystems[name] = status
+	}
+	change(status)
+}
@@ -0,0 +1,81 @@
+// Package carbon предоставляет данные об углеродной интенсивности
+// электросетей в регионах облачных провайдеров
+package carbon
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+//go:embed data/grid_intensity.json
+var bundledDataset []byte
+
+// RegionIntensity - средняя углеродная интенсивность электросети региона
+type RegionIntensity struct {
+	Provider    string  `json:"provider"`
+	Region      string  `json:"region"`
+	Location    string  `json:"location"`
+	GramsPerKWh float64 `json:"grams_per_kwh"` // г CO2 на кВт*ч
+}
+
+// Dataset - набор данных об интенсивности по регионам
+type Dataset struct {
+	Version string            `json:"version"`
+	Source  string            `json:"source"`
+	Regions []RegionIntensity `json:"regions"`
+
+	index map[string]RegionIntensity
+}
+
+// Bundled возвращает встроенный в бинарник набор данных, доступный без сети
+func Bundled() *Dataset {
+	dataset, err := parseDataset(bundledDataset)
+	if err != nil {
+		// Встроенные данные проверяются при сборке, ошибка здесь - дефект релиза
+		panic(fmt.Sprintf("invalid bundled carbon dataset: %v", err))
+	}
+	return dataset
+}
+
+// LoadDataset загружает набор данных из файла того же формата, что и встроенный
+func LoadDataset(path string) (*Dataset, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return parseDataset(data)
+}
+
+func parseDataset(data []byte) (*Dataset, error) {
+	var dataset Dataset
+	if err := json.Unmarshal(data, &dataset); err != nil {
+		return nil, err
+	}
+
+	dataset.index = make(map[string]RegionIntensity, len(dataset.Regions))
+	for _, region := range dataset.Regions {
+		dataset.index[region.Region] = region
+	}
+	return &dataset, nil
+}
+
+// Intensity возвращает углеродную интенсивность региона (г CO2/кВт*ч)
+func (d *Dataset) Intensity(region string) (float64, bool) {
+	r, exists := d.index[region]
+	return r.GramsPerKWh, exists
+}
+
+// Region возвращает полную запись о регионе
+func (d *Dataset) Region(region string) (RegionIntensity, bool) {
+	r, exists := d.index[region]
+	return r, exists
+}
+
+// FootprintKg рассчитывает углеродный след (кг CO2) потребления мощности watts в течение duration
+func FootprintKg(watts float64, duration time.Duration, gramsPerKWh float64) float64 {
+	kwh := watts * duration.Hours() / 1000
+	return kwh * gramsPerKWh / 1000
+}
@@ -0,0 +1,182 @@
+package carbon
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ErrNoForecast - для региона нет прогноза интенсивности
+var ErrNoForecast = errors.New("no carbon intensity forecast for region")
+
+// ForecastPoint - прогноз интенсивности электросети начиная с Time и до
+// следующей точки прогноза
+type ForecastPoint struct {
+	Time        time.Time `json:"time"`
+	GramsPerKWh float64   `json:"grams_per_kwh"`
+}
+
+// Forecaster прогнозирует углеродную интенсивность электросети региона.
+// Точки возвращаются по возрастанию времени.
+type Forecaster interface {
+	Forecast(ctx context.Context, region string, from, to time.Time) ([]ForecastPoint, error)
+}
+
+// FileForecast - прогноз, загруженный из файла: например, выгрузка внешнего
+// сервиса, обновляемая по расписанию, или прогноз в автономном режиме
+type FileForecast struct {
+	regions map[string][]ForecastPoint
+}
+
+// LoadForecast читает прогноз из JSON вида
+// {"regions": {"eu-west-1": [{"time": "2024-05-01T00:00:00Z", "grams_per_kwh": 210}, ...]}}
+func LoadForecast(path string) (*FileForecast, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file struct {
+		Regions map[string][]ForecastPoint `json:"regions"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, err
+	}
+	for region, points := range file.Regions {
+		for _, point := range points {
+			if point.Time.IsZero() || point.GramsPerKWh < 0 {
+				return nil, fmt.Errorf("region %s: invalid forecast point %+v", region, point)
+			}
+		}
+		sort.Slice(points, func(i, j int) bool { return points[i].Time.Before(points[j].Time) })
+	}
+	return &FileForecast{regions: file.Regions}, nil
+}
+
+// Regions возвращает регионы, для которых есть прогноз
+func (f *FileForecast) Regions() []string {
+	regions := make([]string, 0, len(f.regions))
+	for region := range f.regions {
+		regions = append(regions, region)
+	}
+	sort.Strings(regions)
+	return regions
+}
+
+func (f *FileForecast) Forecast(ctx context.Context, region string, from, to time.Time) ([]ForecastPoint, error) {
+	points, exists := f.regions[region]
+	if !exists {
+		return nil, fmt.Errorf("%w %s", ErrNoForecast, region)
+	}
+	return clipForecast(points, from, to), nil
+}
+
+// clipForecast оставляет точки, действующие в [from, to): включая
+// последнюю точку до from, которая действует и в момент from
+func clipForecast(points []ForecastPoint, from, to time.Time) []ForecastPoint {
+	start := sort.Search(len(points), func(i int) bool { return points[i].Time.After(from) })
+	if start > 0 {
+		start--
+	}
+	end := sort.Search(len(points), func(i int) bool { return !points[i].Time.Before(to) })
+	if end < start {
+		end = start
+	}
+	return append([]ForecastPoint(nil), points[start:end]...)
+}
+
+const defaultElectricityMapsURL = "https://api.electricitymap.org"
+
+// ElectricityMapsConfig - прогноз Electricity Maps. Зоны сети задаются для
+// каждого облачного региона: регион облака не совпадает с зоной сети.
+type ElectricityMapsConfig struct {
+	BaseURL string            // По умолчанию https://api.electricitymap.org
+	Token   string            // Заголовок auth-token
+	Zones   map[string]string // Регион облака -> зона, например eu-west-1 -> IE
+	Timeout time.Duration
+}
+
+// ElectricityMaps получает прогноз из API Electricity Maps
+// (v3/carbon-intensity/forecast)
+type ElectricityMaps struct {
+	config ElectricityMapsConfig
+	client *http.Client
+}
+
+func NewElectricityMaps(config ElectricityMapsConfig) (*ElectricityMaps, error) {
+	if config.Token == "" {
+		return nil, fmt.Errorf("electricity maps token is required")
+	}
+	if len(config.Zones) == 0 {
+		return nil, fmt.Errorf("at least one region=zone mapping is required")
+	}
+	if config.BaseURL == "" {
+		config.BaseURL = defaultElectricityMapsURL
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = 10 * time.Second
+	}
+	return &ElectricityMaps{config: config, client: &http.Client{Timeout: config.Timeout}}, nil
+}
+
+// ParseZones разбирает соответствие регионов зонам вида "eu-west-1=IE,us-west-2=US-NW-PACW"
+func ParseZones(value string) (map[string]string, error) {
+	zones := make(map[string]string)
+	for _, pair := range strings.Split(value, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		region, zone, found := strings.Cut(pair, "=")
+		region, zone = strings.TrimSpace(region), strings.TrimSpace(zone)
+		if !found || region == "" || zone == "" {
+			return nil, fmt.Errorf("invalid zone mapping %q: expected region=zone", pair)
+		}
+		zones[region] = zone
+	}
+	return zones, nil
+}
+
+func (e *ElectricityMaps) Forecast(ctx context.Context, region string, from, to time.Time) ([]ForecastPoint, error) {
+	zone, exists := e.config.Zones[region]
+	if !exists {
+		return nil, fmt.Errorf("%w %s", ErrNoForecast, region)
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet,
+		strings.TrimRight(e.config.BaseURL, "/")+"/v3/carbon-intensity/forecast?zone="+url.QueryEscape(zone), nil)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("auth-token", e.config.Token)
+
+	response, err := e.client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("electricity maps: zone %s: status %d", zone, response.StatusCode)
+	}
+
+	var body struct {
+		Forecast []struct {
+			CarbonIntensity float64   `json:"carbonIntensity"`
+			Datetime        time.Time `json:"datetime"`
+		} `json:"forecast"`
+	}
+	if err := json.NewDecoder(response.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("electricity maps: zone %s: %w", zone, err)
+	}
+	points := make([]ForecastPoint, 0, len(body.Forecast))
+	for _, item := range body.Forecast {
+		points = append(points, ForecastPoint{Time: item.Datetime, GramsPerKWh: item.CarbonIntensity})
+	}
+	sort.Slice(points, func(i, j int) bool { return points[i].Time.Before(points[j].Time) })
+	return clipForecast(points, from, to), nil
+}
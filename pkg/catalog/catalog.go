@@ -0,0 +1,187 @@
+// Package catalog содержит справочник типов инстансов облачных провайдеров
+// с их вычислительными характеристиками и оценкой энергопотребления
+package catalog
+
+import (
+	"strings"
+	"sync"
+)
+
+// ReferenceVCPUs - размер эталонного инстанса, к которому приводится
+// энергопотребление при нормализации эко-рейтинга
+const ReferenceVCPUs = 16
+
+// Коэффициенты энергопотребления на vCPU и на ГиБ памяти (Вт), усредненные
+// по опубликованным оценкам для серверного оборудования облачных провайдеров
+const (
+	memoryWattsPerGiB = 0.392
+)
+
+var providerCoefficients = map[string]struct{ minWatts, maxWatts float64 }{
+	"aws":   {minWatts: 0.74, maxWatts: 3.5},
+	"gcp":   {minWatts: 0.71, maxWatts: 4.26},
+	"azure": {minWatts: 0.78, maxWatts: 3.76},
+}
+
+// InstanceSpec описывает характеристики типа инстанса
+type InstanceSpec struct {
+	Provider  string  `json:"provider"`
+	Type      string  `json:"type"`
+	VCPUs     int     `json:"vcpus"`
+	MemoryGiB float64 `json:"memory_gib"`
+	IdleWatts float64 `json:"idle_watts"`       // Потребление CPU в простое
+	MaxWatts  float64 `json:"max_watts"`        // Потребление CPU при 100% загрузке
+	Family    string  `json:"family,omitempty"` // Семейство инстансов, например m5 или n2-standard
+	ARM       bool    `json:"arm,omitempty"`
+}
+
+// EstimatePower оценивает потребление инстанса (Вт) при заданной загрузке CPU (%)
+func (s InstanceSpec) EstimatePower(cpuPercent float64) float64 {
+	if cpuPercent < 0 {
+		cpuPercent = 0
+	}
+	if cpuPercent > 100 {
+		cpuPercent = 100
+	}
+	cpuWatts := s.IdleWatts + (s.MaxWatts-s.IdleWatts)*cpuPercent/100
+	return cpuWatts + s.MemoryWatts()
+}
+
+// MemoryWatts оценивает потребление памяти инстанса (Вт), не зависящее от загрузки
+func (s InstanceSpec) MemoryWatts() float64 {
+	return s.MemoryGiB * memoryWattsPerGiB
+}
+
+// NormalizationFactor возвращает множитель, приводящий энергопотребление
+// инстанса к эталонному размеру ReferenceVCPUs
+func (s InstanceSpec) NormalizationFactor() float64 {
+	if s.VCPUs <= 0 {
+		return 1
+	}
+	return float64(ReferenceVCPUs) / float64(s.VCPUs)
+}
+
+func newSpec(provider, instanceType string, vcpus int, memoryGiB float64) InstanceSpec {
+	coef := providerCoefficients[provider]
+	spec := InstanceSpec{
+		Provider:  provider,
+		Type:      instanceType,
+		VCPUs:     vcpus,
+		MemoryGiB: memoryGiB,
+		IdleWatts: coef.minWatts * float64(vcpus),
+		MaxWatts:  coef.maxWatts * float64(vcpus),
+	}
+
+	switch provider {
+	case "aws":
+		spec.Family, _, _ = strings.Cut(instanceType, ".")
+		// Graviton: буква g после номера поколения (m6g, c6g, r6g)
+		spec.ARM = strings.HasSuffix(spec.Family, "g")
+	case "gcp":
+		if i := strings.LastIndex(instanceType, "-"); i > 0 {
+			spec.Family = instanceType[:i]
+		}
+	}
+	return spec
+}
+
+var (
+	mu    sync.RWMutex
+	specs = map[string]InstanceSpec{}
+)
+
+func init() {
+	builtin := []InstanceSpec{
+		newSpec("aws", "t3.micro", 2, 1),
+		newSpec("aws", "t3.medium", 2, 4),
+		newSpec("aws", "m5.large", 2, 8),
+		newSpec("aws", "m5.xlarge", 4, 16),
+		newSpec("aws", "m5.2xlarge", 8, 32),
+		newSpec("aws", "m5.4xlarge", 16, 64),
+		newSpec("aws", "m5.16xlarge", 64, 256),
+		newSpec("aws", "c5.large", 2, 4),
+		newSpec("aws", "c5.xlarge", 4, 8),
+		newSpec("aws", "c5.4xlarge", 16, 32),
+		newSpec("aws", "r5.large", 2, 16),
+		newSpec("aws", "m6g.large", 2, 8),
+		newSpec("aws", "c6g.xlarge", 4, 8),
+		newSpec("gcp", "e2-medium", 2, 4),
+		newSpec("gcp", "e2-standard-4", 4, 16),
+		newSpec("gcp", "n2-standard-2", 2, 8),
+		newSpec("gcp", "n2-standard-4", 4, 16),
+		newSpec("gcp", "n2-standard-8", 8, 32),
+		newSpec("gcp", "n2-standard-16", 16, 64),
+		newSpec("gcp", "n2-standard-64", 64, 256),
+		newSpec("gcp", "c2-standard-8", 8, 32),
+		newSpec("azure", "Standard_B2s", 2, 4),
+		newSpec("azure", "Standard_D2s_v3", 2, 8),
+		newSpec("azure", "Standard_D4s_v3", 4, 16),
+		newSpec("azure", "Standard_D16s_v3", 16, 64),
+		newSpec("azure", "Standard_D64s_v3", 64, 256),
+	}
+
+	for _, spec := range builtin {
+		specs[spec.Type] = spec
+	}
+}
+
+// Lookup ищет характеристики по типу инстанса. Для GCP допускается
+// полный URL machineType, возвращаемый Compute API.
+func Lookup(instanceType string) (InstanceSpec, bool) {
+	if i := strings.LastIndex(instanceType, "/"); i >= 0 {
+		instanceType = instanceType[i+1:]
+	}
+
+	mu.RLock()
+	defer mu.RUnlock()
+
+	spec, exists := specs[instanceType]
+	return spec, exists
+}
+
+// SmallerInFamily возвращает ближайший меньший тип того же семейства
+func SmallerInFamily(spec InstanceSpec) (InstanceSpec, bool) {
+	if spec.Family == "" {
+		return InstanceSpec{}, false
+	}
+
+	mu.RLock()
+	defer mu.RUnlock()
+
+	var best InstanceSpec
+	found := false
+	for _, candidate := range specs {
+		if candidate.Provider != spec.Provider || candidate.Family != spec.Family || candidate.VCPUs >= spec.VCPUs {
+			continue
+		}
+		if !found || candidate.VCPUs > best.VCPUs {
+			best = candidate
+			found = true
+		}
+	}
+	return best, found
+}
+
+// armFamilies сопоставляет x86-семейства AWS с ARM (Graviton) аналогами
+var armFamilies = map[string]string{
+	"m5": "m6g",
+	"c5": "c6g",
+	"r5": "r6g",
+}
+
+// ARMEquivalent возвращает имя ARM-аналога того же размера, например m5.large -> m6g.large
+func ARMEquivalent(spec InstanceSpec) (string, bool) {
+	family, exists := armFamilies[spec.Family]
+	if !exists {
+		return "", false
+	}
+	_, size, _ := strings.Cut(spec.Type, ".")
+	return family + "." + size, true
+}
+
+// Register добавляет или переопределяет тип инстанса (например, для on-premise серверов)
+func Register(spec InstanceSpec) {
+	mu.Lock()
+	defer mu.Unlock()
+	specs[spec.Type] = spec
+}
@@ -0,0 +1,124 @@
+package catalog
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// RegionProfile описывает опубликованные провайдером характеристики
+// устойчивости региона
+type RegionProfile struct {
+	Provider          string  `json:"provider"`
+	Region            string  `json:"region"`
+	CarbonFreePercent float64 `json:"carbon_free_percent,omitempty"` // Доля безуглеродной энергии (CFE), если провайдер ее публикует
+	Green             bool    `json:"green"`                         // Провайдер относит регион к низкоуглеродным
+}
+
+var regions = map[string]RegionProfile{}
+
+func init() {
+	builtin := []RegionProfile{
+		// GCP публикует почасовую долю CFE и отмечает регионы "Low CO2"
+		{Provider: "gcp", Region: "europe-north1", CarbonFreePercent: 97, Green: true},
+		{Provider: "gcp", Region: "europe-west1", CarbonFreePercent: 80, Green: true},
+		{Provider: "gcp", Region: "europe-west6", CarbonFreePercent: 87, Green: true},
+		{Provider: "gcp", Region: "europe-west9", CarbonFreePercent: 96, Green: true},
+		{Provider: "gcp", Region: "northamerica-northeast1", CarbonFreePercent: 99, Green: true},
+		{Provider: "gcp", Region: "southamerica-east1", CarbonFreePercent: 88, Green: true},
+		{Provider: "gcp", Region: "us-central1", CarbonFreePercent: 93, Green: true},
+		{Provider: "gcp", Region: "us-west1", CarbonFreePercent: 88, Green: true},
+		{Provider: "gcp", Region: "europe-west3", CarbonFreePercent: 68},
+		{Provider: "gcp", Region: "europe-west4", CarbonFreePercent: 64},
+		{Provider: "gcp", Region: "us-east1", CarbonFreePercent: 31},
+		{Provider: "gcp", Region: "asia-northeast1", CarbonFreePercent: 16},
+		{Provider: "gcp", Region: "asia-southeast1", CarbonFreePercent: 4},
+		// AWS не публикует CFE по регионам, только список регионов
+		// с низкоуглеродным энергоснабжением
+		{Provider: "aws", Region: "us-west-2", Green: true},
+		{Provider: "aws", Region: "ca-central-1", Green: true},
+		{Provider: "aws", Region: "eu-north-1", Green: true},
+		{Provider: "aws", Region: "eu-west-1", Green: true},
+		{Provider: "aws", Region: "eu-central-1", Green: true},
+		{Provider: "aws", Region: "sa-east-1", Green: true},
+		// Azure
+		{Provider: "azure", Region: "swedencentral", Green: true},
+		{Provider: "azure", Region: "norwayeast", Green: true},
+		{Provider: "azure", Region: "westeurope", Green: true},
+		{Provider: "azure", Region: "northeurope", Green: true},
+	}
+
+	for _, profile := range builtin {
+		regions[profile.Region] = profile
+	}
+}
+
+// RegionOf приводит зону доступности к региону: us-east-1a -> us-east-1,
+// europe-west1-b -> europe-west1. Имена регионов возвращаются без изменений.
+func RegionOf(zone string) string {
+	mu.RLock()
+	_, known := regions[zone]
+	mu.RUnlock()
+	if known || zone == "" {
+		return zone
+	}
+
+	last := rune(zone[len(zone)-1])
+	if len(zone) < 2 || !unicode.IsLetter(last) {
+		return zone
+	}
+
+	// Зона GCP: <регион>-<буква>
+	if i := strings.LastIndex(zone, "-"); i > 0 && len(zone)-i == 2 {
+		return zone[:i]
+	}
+
+	// Зона AWS: <регион><буква>
+	if unicode.IsDigit(rune(zone[len(zone)-2])) {
+		return zone[:len(zone)-1]
+	}
+
+	return zone
+}
+
+// LookupRegion возвращает профиль региона; допускается имя зоны доступности
+func LookupRegion(region string) (RegionProfile, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+	profile, exists := regions[RegionOf(region)]
+	return profile, exists
+}
+
+// IsGreenRegion сообщает, относит ли провайдер регион к низкоуглеродным
+func IsGreenRegion(region string) bool {
+	profile, exists := LookupRegion(region)
+	return exists && profile.Green
+}
+
+// GreenRegions возвращает низкоуглеродные регионы провайдера,
+// отсортированные по убыванию доли CFE
+func GreenRegions(provider string) []RegionProfile {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	var result []RegionProfile
+	for _, profile := range regions {
+		if profile.Green && profile.Provider == provider {
+			result = append(result, profile)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].CarbonFreePercent != result[j].CarbonFreePercent {
+			return result[i].CarbonFreePercent > result[j].CarbonFreePercent
+		}
+		return result[i].Region < result[j].Region
+	})
+	return result
+}
+
+// RegisterRegion добавляет или переопределяет профиль региона
+func RegisterRegion(profile RegionProfile) {
+	mu.Lock()
+	defer mu.Unlock()
+	regions[profile.Region] = profile
+}
@@ -0,0 +1,115 @@
+// Package client - клиент API Platypus для управления конфигурацией:
+// группами серверов, углеродными бюджетами, эко-тегами, политикой
+// федерации, группами автомасштабирования и ключами API. Типы повторяют форматы сервера и не зависят от internal-пакетов,
+// поэтому клиент можно подключать из внешних инструментов, например
+// провайдера Terraform.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ErrNotFound - ресурса нет на сервере; провайдеры инфраструктуры по нему
+// удаляют ресурс из состояния
+var ErrNotFound = errors.New("resource not found")
+
+// APIError - ответ сервера с кодом ошибки
+type APIError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("platypus api: %d: %s", e.StatusCode, e.Message)
+}
+
+func (e *APIError) Is(target error) bool {
+	return target == ErrNotFound && e.StatusCode == http.StatusNotFound
+}
+
+type Config struct {
+	ServerURL string // Адрес сервера Platypus, например https://platypus.example.com
+	APIKey    string
+	Timeout   time.Duration // Таймаут одного запроса; 0 - 30 секунд
+}
+
+type Client struct {
+	baseURL string
+	apiKey  string
+	http    *http.Client
+}
+
+func New(config Config) (*Client, error) {
+	if config.ServerURL == "" {
+		return nil, fmt.Errorf("server url is required")
+	}
+	if _, err := url.Parse(config.ServerURL); err != nil {
+		return nil, fmt.Errorf("invalid server url: %w", err)
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = 30 * time.Second
+	}
+	return &Client{
+		baseURL: strings.TrimRight(config.ServerURL, "/") + "/api/v1",
+		apiKey:  config.APIKey,
+		http:    &http.Client{Timeout: config.Timeout},
+	}, nil
+}
+
+// do отправляет запрос и разбирает поле data ответа в out (если out не nil)
+func (c *Client) do(ctx context.Context, method, path string, body, out interface{}) error {
+	var payload io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		payload = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, payload)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.apiKey != "" {
+		req.Header.Set("X-API-Key", c.apiKey)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var envelope struct {
+		Status  string          `json:"status"`
+		Message string          `json:"message"`
+		Data    json.RawMessage `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&envelope); err != nil && err != io.EOF {
+		return fmt.Errorf("invalid response from %s %s: %w", method, path, err)
+	}
+	if resp.StatusCode >= 300 {
+		message := envelope.Message
+		if message == "" {
+			message = http.StatusText(resp.StatusCode)
+		}
+		return &APIError{StatusCode: resp.StatusCode, Message: message}
+	}
+	if out == nil || len(envelope.Data) == 0 {
+		return nil
+	}
+	return json.Unmarshal(envelope.Data, out)
+}
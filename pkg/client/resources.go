@@ -0,0 +1,261 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Group повторяет формат группы серверов (groups.Group)
+type Group struct {
+	ID          string    `json:"id,omitempty"`
+	Name        string    `json:"name"`
+	Description string    `json:"description,omitempty"`
+	ServerIDs   []string  `json:"server_ids,omitempty"`
+	Selector    string    `json:"selector,omitempty"` // Например "environment=prod,team!=infra"
+	CreatedAt   time.Time `json:"created_at,omitempty"`
+	UpdatedAt   time.Time `json:"updated_at,omitempty"`
+}
+
+// Budget повторяет формат углеродного бюджета (budgets.Budget)
+type Budget struct {
+	ID              string    `json:"id,omitempty"`
+	Name            string    `json:"name"`
+	Scope           string    `json:"scope"`  // team, region, group или selector
+	Target          string    `json:"target"` // Команда, регион, группа или селектор
+	MonthlyLimitKg  float64   `json:"monthly_limit_kg"`
+	AlertThresholds []float64 `json:"alert_thresholds,omitempty"` // % от лимита
+	CreatedAt       time.Time `json:"created_at,omitempty"`
+	UpdatedAt       time.Time `json:"updated_at,omitempty"`
+}
+
+// EcoTag повторяет формат эко-тега (ecotags.EcoTag)
+type EcoTag struct {
+	Name        string  `json:"name"`
+	Description string  `json:"description"`
+	Score       float64 `json:"score"`
+	Weight      float64 `json:"weight"`
+	Threshold   float64 `json:"threshold"`
+}
+
+// EcoTagDefinitions - версия определений эко-тегов
+type EcoTagDefinitions struct {
+	Version   int               `json:"version"`
+	Tags      map[string]EcoTag `json:"tags"`
+	ChangedAt time.Time         `json:"changed_at"`
+}
+
+// FederationPolicy повторяет формат политики федерации (federation.Policy)
+type FederationPolicy struct {
+	Version            int64     `json:"version,omitempty"`
+	UpdatedAt          time.Time `json:"updated_at,omitempty"`
+	CPUThresholdHigh   float64   `json:"cpu_threshold_high"`
+	CPUThresholdLow    float64   `json:"cpu_threshold_low"`
+	PowerThresholdHigh float64   `json:"power_threshold_high"`
+	MinPowerSaving     float64   `json:"min_power_saving"`
+}
+
+// ScalingGroup - группа серверов под управлением автоскейлера
+type ScalingGroup struct {
+	GroupID string   `json:"group_id"`
+	Name    string   `json:"name"`
+	Servers []string `json:"servers"`
+}
+
+// APIKeyScope повторяет формат области ключа (api.Scope)
+type APIKeyScope struct {
+	Tenant   string   `json:"tenant,omitempty"`
+	Access   string   `json:"access,omitempty"`   // "", read или write
+	Servers  []string `json:"servers,omitempty"`  // Идентификаторы или шаблоны, например team-a-*
+	Selector string   `json:"selector,omitempty"` // Например "team=payments,environment!=dev"
+}
+
+// APIKey повторяет формат ключа клиента (api.APIKey). Key заполнен только
+// в ответе CreateAPIKey.
+type APIKey struct {
+	ID        string       `json:"id"`
+	Scope     *APIKeyScope `json:"scope,omitempty"`
+	Managed   bool         `json:"managed"`
+	CreatedAt time.Time    `json:"created_at,omitempty"`
+	Key       string       `json:"key,omitempty"`
+}
+
+func (c *Client) ListGroups(ctx context.Context) ([]Group, error) {
+	var groups []Group
+	return groups, c.do(ctx, http.MethodGet, "/groups", nil, &groups)
+}
+
+func (c *Client) GetGroup(ctx context.Context, id string) (*Group, error) {
+	var group Group
+	if err := c.do(ctx, http.MethodGet, "/groups/"+url.PathEscape(id), nil, &group); err != nil {
+		return nil, err
+	}
+	return &group, nil
+}
+
+func (c *Client) CreateGroup(ctx context.Context, group Group) (*Group, error) {
+	var created Group
+	if err := c.do(ctx, http.MethodPost, "/groups", group, &created); err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
+
+func (c *Client) UpdateGroup(ctx context.Context, id string, group Group) (*Group, error) {
+	var updated Group
+	if err := c.do(ctx, http.MethodPut, "/groups/"+url.PathEscape(id), group, &updated); err != nil {
+		return nil, err
+	}
+	return &updated, nil
+}
+
+func (c *Client) DeleteGroup(ctx context.Context, id string) error {
+	return c.do(ctx, http.MethodDelete, "/groups/"+url.PathEscape(id), nil, nil)
+}
+
+func (c *Client) ListBudgets(ctx context.Context) ([]Budget, error) {
+	var budgets []Budget
+	return budgets, c.do(ctx, http.MethodGet, "/budgets", nil, &budgets)
+}
+
+func (c *Client) GetBudget(ctx context.Context, id string) (*Budget, error) {
+	var budget Budget
+	if err := c.do(ctx, http.MethodGet, "/budgets/"+url.PathEscape(id), nil, &budget); err != nil {
+		return nil, err
+	}
+	return &budget, nil
+}
+
+func (c *Client) CreateBudget(ctx context.Context, budget Budget) (*Budget, error) {
+	var created Budget
+	if err := c.do(ctx, http.MethodPost, "/budgets", budget, &created); err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
+
+func (c *Client) UpdateBudget(ctx context.Context, id string, budget Budget) (*Budget, error) {
+	var updated Budget
+	if err := c.do(ctx, http.MethodPut, "/budgets/"+url.PathEscape(id), budget, &updated); err != nil {
+		return nil, err
+	}
+	return &updated, nil
+}
+
+func (c *Client) DeleteBudget(ctx context.Context, id string) error {
+	return c.do(ctx, http.MethodDelete, "/budgets/"+url.PathEscape(id), nil, nil)
+}
+
+// EcoTags возвращает текущую версию определений эко-тегов
+func (c *Client) EcoTags(ctx context.Context) (*EcoTagDefinitions, error) {
+	var definitions EcoTagDefinitions
+	if err := c.do(ctx, http.MethodGet, "/eco-tags", nil, &definitions); err != nil {
+		return nil, err
+	}
+	return &definitions, nil
+}
+
+// GetEcoTag возвращает тег текущей версии определений или ErrNotFound
+func (c *Client) GetEcoTag(ctx context.Context, name string) (*EcoTag, error) {
+	definitions, err := c.EcoTags(ctx)
+	if err != nil {
+		return nil, err
+	}
+	tag, ok := definitions.Tags[name]
+	if !ok {
+		return nil, &APIError{StatusCode: http.StatusNotFound, Message: "eco tag " + name + " not found"}
+	}
+	return &tag, nil
+}
+
+// PutEcoTag добавляет или изменяет тег и возвращает новую версию
+// определений; сервер сразу перестраивает эко-профили
+func (c *Client) PutEcoTag(ctx context.Context, tag EcoTag) (*EcoTagDefinitions, error) {
+	var result struct {
+		Definitions EcoTagDefinitions `json:"definitions"`
+	}
+	if err := c.do(ctx, http.MethodPut, "/eco-tags/"+url.PathEscape(tag.Name), tag, &result); err != nil {
+		return nil, err
+	}
+	return &result.Definitions, nil
+}
+
+func (c *Client) DeleteEcoTag(ctx context.Context, name string) error {
+	return c.do(ctx, http.MethodDelete, "/eco-tags/"+url.PathEscape(name), nil, nil)
+}
+
+func (c *Client) FederationPolicy(ctx context.Context) (*FederationPolicy, error) {
+	var policy FederationPolicy
+	if err := c.do(ctx, http.MethodGet, "/federation/policy", nil, &policy); err != nil {
+		return nil, err
+	}
+	return &policy, nil
+}
+
+// SetFederationPolicy заменяет политику федерации; версию назначает сервер
+func (c *Client) SetFederationPolicy(ctx context.Context, policy FederationPolicy) (*FederationPolicy, error) {
+	var updated FederationPolicy
+	if err := c.do(ctx, http.MethodPut, "/federation/policy", policy, &updated); err != nil {
+		return nil, err
+	}
+	return &updated, nil
+}
+
+// ListScalingGroups возвращает группы под управлением автоскейлера; пустой
+// список - автоскейлер управляет всеми серверами
+func (c *Client) ListScalingGroups(ctx context.Context) ([]ScalingGroup, error) {
+	var groups []ScalingGroup
+	return groups, c.do(ctx, http.MethodGet, "/scaling/groups", nil, &groups)
+}
+
+func (c *Client) GetScalingGroup(ctx context.Context, groupID string) (*ScalingGroup, error) {
+	var group ScalingGroup
+	if err := c.do(ctx, http.MethodGet, "/scaling/groups/"+url.PathEscape(groupID), nil, &group); err != nil {
+		return nil, err
+	}
+	return &group, nil
+}
+
+// PutScalingGroup ставит сохраненную группу под управление автоскейлера
+func (c *Client) PutScalingGroup(ctx context.Context, groupID string) (*ScalingGroup, error) {
+	var group ScalingGroup
+	if err := c.do(ctx, http.MethodPut, "/scaling/groups/"+url.PathEscape(groupID), nil, &group); err != nil {
+		return nil, err
+	}
+	return &group, nil
+}
+
+func (c *Client) DeleteScalingGroup(ctx context.Context, groupID string) error {
+	return c.do(ctx, http.MethodDelete, "/scaling/groups/"+url.PathEscape(groupID), nil, nil)
+}
+
+func (c *Client) ListAPIKeys(ctx context.Context) ([]APIKey, error) {
+	var keys []APIKey
+	return keys, c.do(ctx, http.MethodGet, "/api-keys", nil, &keys)
+}
+
+func (c *Client) GetAPIKey(ctx context.Context, id string) (*APIKey, error) {
+	var key APIKey
+	if err := c.do(ctx, http.MethodGet, "/api-keys/"+url.PathEscape(id), nil, &key); err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+// CreateAPIKey выпускает ключ клиенту id; сам ключ сервер возвращает только здесь
+func (c *Client) CreateAPIKey(ctx context.Context, id string, scope *APIKeyScope) (*APIKey, error) {
+	request := struct {
+		ID    string       `json:"id"`
+		Scope *APIKeyScope `json:"scope,omitempty"`
+	}{ID: id, Scope: scope}
+	var key APIKey
+	if err := c.do(ctx, http.MethodPost, "/api-keys", request, &key); err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+func (c *Client) DeleteAPIKey(ctx context.Context, id string) error {
+	return c.do(ctx, http.MethodDelete, "/api-keys/"+url.PathEscape(id), nil, nil)
+}
@@ -2,6 +2,8 @@ package cloud
 
 import (
 	"context"
+	"fmt"
+	"sort"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/config"
@@ -35,7 +37,7 @@ func (a *AWSProvider) GetInstances(ctx context.Context) ([]models.Server, error)
 	input := &ec2.DescribeInstancesInput{}
 	result, err := a.ec2Client.DescribeInstances(ctx, input)
 	if err != nil {
-		return nil, err
+		return nil, wrapAWS("DescribeInstances", err)
 	}
 
 	var servers []models.Server
@@ -46,6 +48,12 @@ func (a *AWSProvider) GetInstances(ctx context.Context) ([]models.Server, error)
 				Provider:     "aws",
 				Region:      a.region,
 				InstanceType: string(instance.InstanceType),
+				Tags:         make(map[string]string, len(instance.Tags)),
+			}
+			for _, tag := range instance.Tags {
+				if tag.Key != nil && tag.Value != nil {
+					server.Tags[*tag.Key] = *tag.Value
+				}
 			}
 			servers = append(servers, server)
 		}
@@ -58,59 +66,100 @@ func (a *AWSProvider) GetInstanceMetrics(ctx context.Context, instanceID string,
 	endTime := time.Now()
 	startTime := endTime.Add(-period)
 
+	// Трафик берется суммой за период, чтобы точка несла объем переданных данных
 	input := &cloudwatch.GetMetricDataInput{
 		StartTime: &startTime,
 		EndTime:   &endTime,
 		MetricDataQueries: []cloudwatch.MetricDataQuery{
-			{
-				Id: aws.String("cpu"),
-				MetricStat: &cloudwatch.MetricStat{
-					Metric: &cloudwatch.Metric{
-						Namespace:  aws.String("AWS/EC2"),
-						MetricName: aws.String("CPUUtilization"),
-						Dimensions: []cloudwatch.Dimension{
-							{
-								Name:  aws.String("InstanceId"),
-								Value: aws.String(instanceID),
-							},
-						},
-					},
-					Period: aws.Int32(300),
-					Stat:   aws.String("Average"),
-				},
-			},
+			ec2MetricQuery("cpu", "CPUUtilization", "Average", instanceID),
+			ec2MetricQuery("rx", "NetworkIn", "Sum", instanceID),
+			ec2MetricQuery("tx", "NetworkOut", "Sum", instanceID),
 		},
 	}
 
 	result, err := a.cloudWatchClient.GetMetricData(ctx, input)
 	if err != nil {
-		return nil, err
+		return nil, wrapAWS("GetMetricData", err)
 	}
 
-	var metrics []models.MetricData
-	for i, timestamp := range result.MetricDataResults[0].Timestamps {
-		metric := models.MetricData{
-			ServerID:  instanceID,
-			Timestamp: timestamp.Unix(),
-			CPUUsage:  *result.MetricDataResults[0].Values[i],
+	points := make(map[int64]*models.MetricData)
+	for _, series := range result.MetricDataResults {
+		for i, timestamp := range series.Timestamps {
+			point, exists := points[timestamp.Unix()]
+			if !exists {
+				point = &models.MetricData{ServerID: instanceID, Timestamp: timestamp.Unix()}
+				points[timestamp.Unix()] = point
+			}
+			switch aws.ToString(series.Id) {
+			case "cpu":
+				point.CPUUsage = series.Values[i]
+			case "rx":
+				point.NetworkRxBytes = series.Values[i]
+			case "tx":
+				point.NetworkTxBytes = series.Values[i]
+			}
 		}
-		metrics = append(metrics, metric)
 	}
 
+	// CloudWatch не отдает потребление: мощность оценивается по типу инстанса
+	instanceType, err := a.instanceType(ctx, instanceID)
+	if err != nil {
+		return nil, err
+	}
+	metrics := sortedPoints(points)
+	estimatePoints(instanceType, metrics)
 	return metrics, nil
 }
 
+func ec2MetricQuery(id, metricName, stat, instanceID string) cloudwatch.MetricDataQuery {
+	return cloudwatch.MetricDataQuery{
+		Id: aws.String(id),
+		MetricStat: &cloudwatch.MetricStat{
+			Metric: &cloudwatch.Metric{
+				Namespace:  aws.String("AWS/EC2"),
+				MetricName: aws.String(metricName),
+				Dimensions: []cloudwatch.Dimension{
+					{
+						Name:  aws.String("InstanceId"),
+						Value: aws.String(instanceID),
+					},
+				},
+			},
+			Period: aws.Int32(300),
+			Stat:   aws.String(stat),
+		},
+	}
+}
+
+// sortedPoints возвращает точки, собранные из нескольких рядов, по возрастанию времени
+func sortedPoints(points map[int64]*models.MetricData) []models.MetricData {
+	metrics := make([]models.MetricData, 0, len(points))
+	for _, point := range points {
+		metrics = append(metrics, *point)
+	}
+	sort.Slice(metrics, func(i, j int) bool { return metrics[i].Timestamp < metrics[j].Timestamp })
+	return metrics
+}
+
 func (a *AWSProvider) GetPowerUsage(ctx context.Context, instanceID string) (float64, error) {
 	// В AWS нет прямого API для получения энергопотребления
 	// Используем приблизительные расчеты на основе типа инстанса и его загрузки
+	instanceType, err := a.instanceType(ctx, instanceID)
+	if err != nil {
+		return 0, err
+	}
+	return calculatePowerUsage(instanceType), nil
+}
+
+func (a *AWSProvider) instanceType(ctx context.Context, instanceID string) (string, error) {
 	instance, err := a.ec2Client.DescribeInstances(ctx, &ec2.DescribeInstancesInput{
 		InstanceIds: []string{instanceID},
 	})
 	if err != nil {
-		return 0, err
+		return "", wrapAWS("DescribeInstances", err)
 	}
-
-	// Примерный расчет энергопотребления на основе типа инстанса
-	instanceType := instance.Reservations[0].Instances[0].InstanceType
-	return calculatePowerUsage(string(instanceType)), nil
+	if len(instance.Reservations) == 0 || len(instance.Reservations[0].Instances) == 0 {
+		return "", &Error{Provider: "aws", Op: "DescribeInstances", Kind: ErrNotFound, Err: fmt.Errorf("instance %s", instanceID)}
+	}
+	return string(instance.Reservations[0].Instances[0].InstanceType), nil
 }
@@ -0,0 +1,142 @@
+package cloud
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/aws/smithy-go"
+	"google.golang.org/api/googleapi"
+)
+
+// Классы ошибок провайдеров. Проверяются через errors.Is, чтобы вызывающий
+// код мог реагировать по-разному: повторить позже, отбросить план или оповестить.
+var (
+	ErrThrottled    = errors.New("provider request throttled")
+	ErrNotFound     = errors.New("resource not found")
+	ErrUnauthorized = errors.New("provider credentials rejected")
+	ErrCapacity     = errors.New("insufficient provider capacity")
+	ErrUnsupported  = errors.New("operation not supported by provider")
+)
+
+// Error - ошибка вызова провайдера с классом и исходной причиной
+type Error struct {
+	Provider   string
+	Op         string
+	Kind       error         // Один из Err*, nil - класс не определен
+	RetryAfter time.Duration // Подсказка провайдера о паузе перед повтором
+	Err        error
+}
+
+func (e *Error) Error() string {
+	if e.Kind == nil {
+		return fmt.Sprintf("%s %s: %v", e.Provider, e.Op, e.Err)
+	}
+	return fmt.Sprintf("%s %s: %v: %v", e.Provider, e.Op, e.Kind, e.Err)
+}
+
+func (e *Error) Unwrap() []error {
+	if e.Kind == nil {
+		return []error{e.Err}
+	}
+	return []error{e.Kind, e.Err}
+}
+
+// Kind возвращает класс ошибки провайдера или nil
+func Kind(err error) error {
+	for _, kind := range []error{ErrThrottled, ErrNotFound, ErrUnauthorized, ErrCapacity, ErrUnsupported} {
+		if errors.Is(err, kind) {
+			return kind
+		}
+	}
+	return nil
+}
+
+// ProviderOf возвращает имя провайдера из ошибки, если оно известно
+func ProviderOf(err error) string {
+	var providerErr *Error
+	if errors.As(err, &providerErr) {
+		return providerErr.Provider
+	}
+	return ""
+}
+
+// RetryAfter возвращает рекомендованную паузу перед повтором или fallback
+func RetryAfter(err error, fallback time.Duration) time.Duration {
+	var providerErr *Error
+	if errors.As(err, &providerErr) && providerErr.RetryAfter > 0 {
+		return providerErr.RetryAfter
+	}
+	return fallback
+}
+
+// awsErrorKinds сопоставляет коды ошибок AWS API классам
+var awsErrorKinds = map[string]error{
+	"Throttling":                   ErrThrottled,
+	"ThrottlingException":          ErrThrottled,
+	"RequestLimitExceeded":         ErrThrottled,
+	"TooManyRequestsException":     ErrThrottled,
+	"InvalidInstanceID.NotFound":   ErrNotFound,
+	"InvalidInstanceID.Malformed":  ErrNotFound,
+	"ResourceNotFoundException":    ErrNotFound,
+	"UnauthorizedOperation":        ErrUnauthorized,
+	"AuthFailure":                  ErrUnauthorized,
+	"AccessDenied":                 ErrUnauthorized,
+	"AccessDeniedException":        ErrUnauthorized,
+	"InvalidClientTokenId":         ErrUnauthorized,
+	"ExpiredToken":                 ErrUnauthorized,
+	"InsufficientInstanceCapacity": ErrCapacity,
+	"InsufficientCapacity":         ErrCapacity,
+	"InstanceLimitExceeded":        ErrCapacity,
+	"VcpuLimitExceeded":            ErrCapacity,
+}
+
+// wrapAWS классифицирует ошибку AWS SDK
+func wrapAWS(op string, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	providerErr := &Error{Provider: "aws", Op: op, Err: err}
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		providerErr.Kind = awsErrorKinds[apiErr.ErrorCode()]
+	}
+	return providerErr
+}
+
+// wrapGCP классифицирует ошибку Google API по HTTP-коду и причине
+func wrapGCP(op string, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	providerErr := &Error{Provider: "gcp", Op: op, Err: err}
+	var apiErr *googleapi.Error
+	if !errors.As(err, &apiErr) {
+		return providerErr
+	}
+
+	reasons := make([]string, 0, len(apiErr.Errors))
+	for _, item := range apiErr.Errors {
+		reasons = append(reasons, item.Reason)
+	}
+	reason := strings.Join(reasons, " ")
+
+	switch {
+	case apiErr.Code == http.StatusTooManyRequests || strings.Contains(reason, "rateLimitExceeded"):
+		providerErr.Kind = ErrThrottled
+		if seconds, err := time.ParseDuration(apiErr.Header.Get("Retry-After") + "s"); err == nil {
+			providerErr.RetryAfter = seconds
+		}
+	case strings.Contains(reason, "quotaExceeded") || strings.Contains(apiErr.Message, "RESOURCE_POOL_EXHAUSTED"):
+		providerErr.Kind = ErrCapacity
+	case apiErr.Code == http.StatusNotFound:
+		providerErr.Kind = ErrNotFound
+	case apiErr.Code == http.StatusUnauthorized || apiErr.Code == http.StatusForbidden:
+		providerErr.Kind = ErrUnauthorized
+	}
+	return providerErr
+}
@@ -0,0 +1,64 @@
+package cloud
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/YumeNoTenshi/platypus/internal/models"
+)
+
+// FileProvider читает инвентарь серверов из локального JSON-файла.
+// Используется в автономном режиме, когда API облачных провайдеров недоступны.
+type FileProvider struct {
+	path string
+}
+
+func NewFileProvider(path string) (*FileProvider, error) {
+	if _, err := os.Stat(path); err != nil {
+		return nil, err
+	}
+	return &FileProvider{path: path}, nil
+}
+
+func (f *FileProvider) GetInstances(ctx context.Context) ([]models.Server, error) {
+	// Файл перечитывается при каждом вызове, чтобы изменения инвентаря
+	// подхватывались без перезапуска
+	data, err := os.ReadFile(f.path)
+	if err != nil {
+		return nil, err
+	}
+
+	var servers []models.Server
+	if err := json.Unmarshal(data, &servers); err != nil {
+		return nil, fmt.Errorf("invalid inventory file %s: %w", f.path, err)
+	}
+	return servers, nil
+}
+
+func (f *FileProvider) GetInstanceMetrics(ctx context.Context, instanceID string, period time.Duration) ([]models.MetricData, error) {
+	// Метрики в автономном режиме поступают только от агентов через API
+	return []models.MetricData{}, nil
+}
+
+func (f *FileProvider) MigrateContainer(ctx context.Context, containerID, sourceID, targetID string) error {
+	return &Error{Provider: "file", Op: "MigrateContainer", Kind: ErrUnsupported,
+		Err: fmt.Errorf("container migration is not available with file-based inventory")}
+}
+
+func (f *FileProvider) GetPowerUsage(ctx context.Context, instanceID string) (float64, error) {
+	servers, err := f.GetInstances(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, server := range servers {
+		if server.ID == instanceID {
+			return calculatePowerUsage(server.InstanceType), nil
+		}
+	}
+	return 0, &Error{Provider: "file", Op: "GetPowerUsage", Kind: ErrNotFound,
+		Err: fmt.Errorf("instance not found in inventory: %s", instanceID)}
+}
@@ -39,7 +39,7 @@ func NewGCPProvider(ctx context.Context, projectID, zone string) (*GCPProvider,
 func (g *GCPProvider) GetInstances(ctx context.Context) ([]models.Server, error) {
 	instances, err := g.computeService.Instances.List(g.projectID, g.zone).Context(ctx).Do()
 	if err != nil {
-		return nil, err
+		return nil, wrapGCP("instances.list", err)
 	}
 
 	var servers []models.Server
@@ -49,6 +49,7 @@ func (g *GCPProvider) GetInstances(ctx context.Context) ([]models.Server, error)
 			Provider:     "gcp",
 			Region:      g.zone,
 			InstanceType: instance.MachineType,
+			Tags:         instance.Labels,
 		}
 		servers = append(servers, server)
 	}
@@ -60,38 +61,59 @@ func (g *GCPProvider) GetInstanceMetrics(ctx context.Context, instanceID string,
 	endTime := time.Now()
 	startTime := endTime.Add(-period)
 
-	request := &monitoring.ListTimeSeriesRequest{
-		Filter: fmt.Sprintf(
-			`metric.type="compute.googleapis.com/instance/cpu/utilization" AND 
-			 resource.labels.instance_id="%s"`,
-			instanceID,
-		),
-		Interval: &monitoring.TimeInterval{
-			StartTime: startTime.Format(time.RFC3339),
-			EndTime:   endTime.Format(time.RFC3339),
-		},
-	}
+	points := make(map[int64]*models.MetricData)
+	for _, metricType := range []string{
+		"compute.googleapis.com/instance/cpu/utilization",
+		"compute.googleapis.com/instance/network/received_bytes_count",
+		"compute.googleapis.com/instance/network/sent_bytes_count",
+	} {
+		resp, err := g.monitoringService.Projects.TimeSeries.List("projects/"+g.projectID).
+			Filter(fmt.Sprintf(`metric.type="%s" AND resource.labels.instance_id="%s"`, metricType, instanceID)).
+			IntervalStartTime(startTime.Format(time.RFC3339)).
+			IntervalEndTime(endTime.Format(time.RFC3339)).
+			Context(ctx).
+			Do()
+		if err != nil {
+			return nil, wrapGCP("timeSeries.list", err)
+		}
 
-	resp, err := g.monitoringService.Projects.TimeSeries.List("projects/"+g.projectID).
-		Filter(request.Filter).
-		IntervalStartTime(request.Interval.StartTime).
-		IntervalEndTime(request.Interval.EndTime).
-		Do()
-	if err != nil {
-		return nil, err
-	}
+		for _, series := range resp.TimeSeries {
+			for _, value := range series.Points {
+				at, err := time.Parse(time.RFC3339, value.Interval.EndTime)
+				if err != nil {
+					continue
+				}
+				point, exists := points[at.Unix()]
+				if !exists {
+					point = &models.MetricData{ServerID: instanceID, Timestamp: at.Unix()}
+					points[at.Unix()] = point
+				}
 
-	var metrics []models.MetricData
-	for _, series := range resp.TimeSeries {
-		for _, point := range series.Points {
-			metric := models.MetricData{
-				ServerID:  instanceID,
-				Timestamp: point.Interval.EndTime,
-				CPUUsage:  point.Value.DoubleValue,
+				// Загрузка CPU приходит долей, счетчики трафика - байтами за интервал
+				switch metricType {
+				case "compute.googleapis.com/instance/cpu/utilization":
+					if value.Value.DoubleValue != nil {
+						point.CPUUsage = *value.Value.DoubleValue * 100
+					}
+				case "compute.googleapis.com/instance/network/received_bytes_count":
+					if value.Value.Int64Value != nil {
+						point.NetworkRxBytes += float64(*value.Value.Int64Value)
+					}
+				case "compute.googleapis.com/instance/network/sent_bytes_count":
+					if value.Value.Int64Value != nil {
+						point.NetworkTxBytes += float64(*value.Value.Int64Value)
+					}
+				}
 			}
-			metrics = append(metrics, metric)
 		}
 	}
 
+	// Cloud Monitoring не отдает потребление: мощность оценивается по типу машины
+	instance, err := g.computeService.Instances.Get(g.projectID, g.zone, instanceID).Context(ctx).Do()
+	if err != nil {
+		return nil, wrapGCP("instances.get", err)
+	}
+	metrics := sortedPoints(points)
+	estimatePoints(instance.MachineType, metrics)
 	return metrics, nil
 }
@@ -0,0 +1,119 @@
+package cloud
+
+import (
+	"errors"
+	"log"
+	"time"
+)
+
+// ErrProviderDegraded возвращается без обращения к провайдеру для действий
+// (миграций), пока провайдер отклоняет учетные данные. Ошибка
+// классифицируется как ErrUnauthorized.
+var ErrProviderDegraded = errors.New("provider degraded after credential failures")
+
+// HealthState - состояние провайдера для автоматики. Отказ учетных данных
+// останавливает автоматику в две фазы: сначала новые действия
+// приостанавливаются, а очередь сохраняется; если отказ длится дольше
+// SuspendAfter, очередь сбрасывается - планы построены по списку инстансов,
+// который уже нельзя проверить. Любой успешный вызов возвращает провайдер
+// в HealthOK.
+type HealthState string
+
+const (
+	HealthOK        HealthState = "healthy"
+	HealthDegraded  HealthState = "degraded"  // Действия приостановлены, очередь сохраняется
+	HealthSuspended HealthState = "suspended" // Действия остановлены, очередь сброшена
+)
+
+// Health - состояние провайдера для страницы статуса
+type Health struct {
+	Provider     string      `json:"provider"`
+	State        HealthState `json:"state"`
+	Since        *time.Time  `json:"since,omitempty"`      // Первый отказ учетных данных
+	SuspendAt    *time.Time  `json:"suspend_at,omitempty"` // Переход во вторую фазу
+	AuthFailures int         `json:"auth_failures,omitempty"`
+	LastError    string      `json:"last_error,omitempty"`
+	RecoveredAt  *time.Time  `json:"recovered_at,omitempty"` // Последнее возвращение в healthy
+}
+
+// HealthReporter сообщает состояние провайдера; его реализует ResilientProvider
+type HealthReporter interface {
+	Health() Health
+}
+
+// Paused возвращает состояние провайдера и true, если автоматика не должна
+// выполнять действия через него. Провайдер без учета состояния всегда здоров.
+func Paused(provider CloudProvider) (Health, bool) {
+	reporter, ok := provider.(HealthReporter)
+	if !ok {
+		return Health{State: HealthOK}, false
+	}
+	health := reporter.Health()
+	return health, health.State != HealthOK
+}
+
+type healthTracker struct {
+	since       time.Time // Нулевое - провайдер здоров
+	failures    int
+	lastError   string
+	suspended   bool
+	recoveredAt time.Time
+}
+
+// recordHealth учитывает результат вызова в состоянии провайдера. Ошибки,
+// кроме отказа учетных данных, состояние не меняют: их обрабатывают
+// повторы и предохранители. Вызывается под блокировкой.
+func (p *ResilientProvider) recordHealth(err error) {
+	h := &p.health
+	switch {
+	case err == nil:
+		if h.since.IsZero() {
+			return
+		}
+		log.Printf("Провайдер %s снова принимает учетные данные, автоматика возобновлена", p.name)
+		p.health = healthTracker{recoveredAt: time.Now()}
+	case errors.Is(err, ErrUnauthorized) && !errors.Is(err, ErrProviderDegraded):
+		if h.since.IsZero() {
+			h.since = time.Now()
+			log.Printf("Провайдер %s отклоняет учетные данные, действия автоматики приостановлены: %v", p.name, err)
+		}
+		h.failures++
+		h.lastError = err.Error()
+	}
+}
+
+// Health возвращает состояние провайдера
+func (p *ResilientProvider) Health() Health {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	h := &p.health
+	health := Health{Provider: p.name, State: HealthOK}
+	if !h.recoveredAt.IsZero() {
+		recoveredAt := h.recoveredAt
+		health.RecoveredAt = &recoveredAt
+	}
+	if h.since.IsZero() {
+		return health
+	}
+
+	since, suspendAt := h.since, h.since.Add(p.config.SuspendAfter)
+	health.State = HealthDegraded
+	health.Since, health.SuspendAt = &since, &suspendAt
+	health.AuthFailures, health.LastError = h.failures, h.lastError
+	if !time.Now().Before(suspendAt) {
+		health.State = HealthSuspended
+		if !h.suspended {
+			h.suspended = true
+			log.Printf("Провайдер %s отклоняет учетные данные дольше %s, очередь действий сброшена", p.name, p.config.SuspendAfter)
+		}
+	}
+	return health
+}
+
+// degraded сообщает, отклоняет ли провайдер учетные данные
+func (p *ResilientProvider) degraded() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return !p.health.since.IsZero()
+}
@@ -0,0 +1,59 @@
+package cloud
+
+import (
+	"sync"
+
+	"github.com/YumeNoTenshi/platypus/internal/models"
+	"github.com/YumeNoTenshi/platypus/pkg/catalog"
+	"github.com/YumeNoTenshi/platypus/pkg/powermodel"
+)
+
+// defaultPowerUsage используется для типов инстансов, отсутствующих в каталоге (Вт)
+const defaultPowerUsage = 100.0
+
+// defaultPowerModel - происхождение оценки для типов, отсутствующих в каталоге
+const defaultPowerModel = "default"
+
+var (
+	powerMu     sync.RWMutex
+	powerModels = powermodel.Default()
+)
+
+// SetPowerModels задает модели оценки мощности для провайдеров, которые не
+// отдают измеренное потребление
+func SetPowerModels(registry *powermodel.Registry) {
+	powerMu.Lock()
+	defer powerMu.Unlock()
+	powerModels = registry
+}
+
+// estimatePower оценивает потребление инстанса при загрузке CPU cpuPercent (%)
+// и возвращает происхождение оценки
+func estimatePower(instanceType string, cpuPercent float64) (float64, string) {
+	spec, exists := catalog.Lookup(instanceType)
+	if !exists {
+		return defaultPowerUsage, defaultPowerModel
+	}
+
+	powerMu.RLock()
+	registry := powerModels
+	powerMu.RUnlock()
+	return registry.Estimate(spec, cpuPercent)
+}
+
+// calculatePowerUsage оценивает энергопотребление инстанса при средней загрузке
+func calculatePowerUsage(instanceType string) float64 {
+	watts, _ := estimatePower(instanceType, 50)
+	return watts
+}
+
+// estimatePoints заполняет мощность точек, для которых провайдер ее не отдал,
+// оценкой по загрузке CPU
+func estimatePoints(instanceType string, points []models.MetricData) {
+	for i := range points {
+		if points[i].PowerUsage > 0 {
+			continue
+		}
+		points[i].PowerUsage, points[i].PowerModel = estimatePower(instanceType, points[i].CPUUsage)
+	}
+}
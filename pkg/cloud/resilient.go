@@ -0,0 +1,372 @@
+package cloud
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/YumeNoTenshi/platypus/internal/models"
+)
+
+// ErrCircuitOpen возвращается без обращения к провайдеру, пока предохранитель
+// эндпоинта разомкнут. Ошибка классифицируется как ErrThrottled, поэтому
+// вызывающий код откладывает повтор так же, как при ограничении частоты.
+var ErrCircuitOpen = errors.New("provider circuit open")
+
+// Эндпоинты провайдера, для каждого из которых ведется свой предохранитель
+const (
+	OpGetInstances       = "GetInstances"
+	OpGetInstanceMetrics = "GetInstanceMetrics"
+	OpMigrateContainer   = "MigrateContainer"
+	OpGetPowerUsage      = "GetPowerUsage"
+)
+
+// BreakerState - состояние предохранителя эндпоинта
+type BreakerState string
+
+const (
+	BreakerClosed   BreakerState = "closed"
+	BreakerOpen     BreakerState = "open"
+	BreakerHalfOpen BreakerState = "half-open"
+)
+
+// ResilienceConfig задает политику вызовов провайдера
+type ResilienceConfig struct {
+	Timeout          time.Duration // Дедлайн одной попытки
+	MigrationTimeout time.Duration // Дедлайн миграции, она дольше остальных вызовов
+	MaxAttempts      int           // Всего попыток для идемпотентных вызовов
+	BaseBackoff      time.Duration // Пауза перед второй попыткой, далее удваивается
+	MaxBackoff       time.Duration // Верхняя граница паузы между попытками
+	BreakerThreshold int           // Подряд идущих сбоев до размыкания
+	BreakerCooldown  time.Duration // Время в разомкнутом состоянии до пробного вызова
+	// SuspendAfter - сколько провайдер может отклонять учетные данные, прежде
+	// чем автоматика сбросит очередь действий (см. HealthState)
+	SuspendAfter time.Duration
+}
+
+// DefaultResilienceConfig возвращает политику по умолчанию
+func DefaultResilienceConfig() ResilienceConfig {
+	return ResilienceConfig{
+		Timeout:          30 * time.Second,
+		MigrationTimeout: 10 * time.Minute,
+		MaxAttempts:      3,
+		BaseBackoff:      500 * time.Millisecond,
+		MaxBackoff:       10 * time.Second,
+		BreakerThreshold: 5,
+		BreakerCooldown:  time.Minute,
+		SuspendAfter:     15 * time.Minute,
+	}
+}
+
+// CallObserver получает события вызовов провайдера для метрик самого сервиса
+type CallObserver interface {
+	// ObserveCall вызывается после каждой попытки
+	ObserveCall(provider, op string, attempt int, duration time.Duration, err error)
+	// ObserveBreaker вызывается при смене состояния предохранителя
+	ObserveBreaker(provider, op string, state BreakerState)
+}
+
+// BreakerStatus - состояние предохранителя для страницы статуса
+type BreakerStatus struct {
+	Op        string       `json:"op"`
+	State     BreakerState `json:"state"`
+	Failures  int          `json:"consecutive_failures"`
+	OpenUntil *time.Time   `json:"open_until,omitempty"`
+	LastError string       `json:"last_error,omitempty"`
+}
+
+type breaker struct {
+	state     BreakerState
+	failures  int
+	openUntil time.Time
+	probing   bool
+	lastError string
+}
+
+// ResilientProvider оборачивает CloudProvider дедлайнами, ограниченными
+// повторами с джиттером и предохранителями по эндпоинтам
+type ResilientProvider struct {
+	name     string
+	next     CloudProvider
+	config   ResilienceConfig
+	observer CallObserver
+
+	mu       sync.Mutex
+	breakers map[string]*breaker
+	health   healthTracker
+}
+
+// NewResilientProvider создает обертку над провайдером
+func NewResilientProvider(name string, next CloudProvider, config ResilienceConfig) *ResilientProvider {
+	defaults := DefaultResilienceConfig()
+	if config.Timeout <= 0 {
+		config.Timeout = defaults.Timeout
+	}
+	if config.MigrationTimeout <= 0 {
+		config.MigrationTimeout = defaults.MigrationTimeout
+	}
+	if config.MaxAttempts <= 0 {
+		config.MaxAttempts = defaults.MaxAttempts
+	}
+	if config.BaseBackoff <= 0 {
+		config.BaseBackoff = defaults.BaseBackoff
+	}
+	if config.MaxBackoff < config.BaseBackoff {
+		config.MaxBackoff = config.BaseBackoff
+	}
+	if config.BreakerThreshold <= 0 {
+		config.BreakerThreshold = defaults.BreakerThreshold
+	}
+	if config.BreakerCooldown <= 0 {
+		config.BreakerCooldown = defaults.BreakerCooldown
+	}
+	if config.SuspendAfter <= 0 {
+		config.SuspendAfter = defaults.SuspendAfter
+	}
+
+	return &ResilientProvider{
+		name:     name,
+		next:     next,
+		config:   config,
+		breakers: make(map[string]*breaker),
+	}
+}
+
+// SetObserver подключает получателя событий вызовов
+func (p *ResilientProvider) SetObserver(observer CallObserver) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.observer = observer
+}
+
+// Breakers возвращает состояние предохранителей всех вызывавшихся эндпоинтов
+func (p *ResilientProvider) Breakers() []BreakerStatus {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	statuses := make([]BreakerStatus, 0, len(p.breakers))
+	for op, b := range p.breakers {
+		status := BreakerStatus{Op: op, State: b.state, Failures: b.failures, LastError: b.lastError}
+		if b.state == BreakerOpen {
+			openUntil := b.openUntil
+			status.OpenUntil = &openUntil
+		}
+		statuses = append(statuses, status)
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Op < statuses[j].Op })
+	return statuses
+}
+
+func (p *ResilientProvider) GetInstances(ctx context.Context) ([]models.Server, error) {
+	var servers []models.Server
+	err := p.call(ctx, OpGetInstances, true, func(ctx context.Context) error {
+		var err error
+		servers, err = p.next.GetInstances(ctx)
+		return err
+	})
+	return servers, err
+}
+
+func (p *ResilientProvider) GetInstanceMetrics(ctx context.Context, instanceID string, period time.Duration) ([]models.MetricData, error) {
+	var data []models.MetricData
+	err := p.call(ctx, OpGetInstanceMetrics, true, func(ctx context.Context) error {
+		var err error
+		data, err = p.next.GetInstanceMetrics(ctx, instanceID, period)
+		return err
+	})
+	return data, err
+}
+
+// MigrateContainer не повторяется: миграция не идемпотентна, и повтор после
+// таймаута может запустить второй перенос того же контейнера
+func (p *ResilientProvider) MigrateContainer(ctx context.Context, containerID, sourceID, targetID string) error {
+	return p.call(ctx, OpMigrateContainer, false, func(ctx context.Context) error {
+		return p.next.MigrateContainer(ctx, containerID, sourceID, targetID)
+	})
+}
+
+func (p *ResilientProvider) GetPowerUsage(ctx context.Context, instanceID string) (float64, error) {
+	var usage float64
+	err := p.call(ctx, OpGetPowerUsage, true, func(ctx context.Context) error {
+		var err error
+		usage, err = p.next.GetPowerUsage(ctx, instanceID)
+		return err
+	})
+	return usage, err
+}
+
+// call выполняет вызов с дедлайном, повторами и учетом предохранителя.
+// Миграции не выполняются, пока провайдер отклоняет учетные данные;
+// чтения проходят и служат пробой: первое успешное возвращает провайдер
+// в рабочее состояние.
+func (p *ResilientProvider) call(ctx context.Context, op string, idempotent bool, fn func(context.Context) error) error {
+	timeout := p.config.Timeout
+	attempts := p.config.MaxAttempts
+	if op == OpMigrateContainer {
+		timeout = p.config.MigrationTimeout
+		if p.degraded() {
+			return &Error{Provider: p.name, Op: op, Kind: ErrUnauthorized, Err: ErrProviderDegraded}
+		}
+	}
+	if !idempotent {
+		attempts = 1
+	}
+
+	var err error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		if wait, open := p.allow(op); open {
+			return &Error{Provider: p.name, Op: op, Kind: ErrThrottled, RetryAfter: wait, Err: ErrCircuitOpen}
+		}
+
+		attemptCtx, cancel := context.WithTimeout(ctx, timeout)
+		started := time.Now()
+		err = fn(attemptCtx)
+		cancel()
+		duration := time.Since(started)
+
+		// Отмена вызывающим не говорит о здоровье провайдера
+		if ctx.Err() != nil {
+			p.abandon(op)
+			p.observeCall(op, attempt, duration, err)
+			return err
+		}
+		if err != nil && errors.Is(err, context.DeadlineExceeded) && ProviderOf(err) == "" {
+			err = &Error{Provider: p.name, Op: op, Err: err}
+		}
+
+		p.record(op, err)
+		p.observeCall(op, attempt, duration, err)
+		if err == nil || !retryable(err) || attempt == attempts {
+			return err
+		}
+
+		if !sleep(ctx, p.backoff(attempt, err)) {
+			return err
+		}
+	}
+	return err
+}
+
+// retryable сообщает, имеет ли смысл повторить вызов. Ошибки с определенным
+// классом, кроме ограничения частоты, повтором не исправляются.
+func retryable(err error) bool {
+	kind := Kind(err)
+	return kind == nil || kind == ErrThrottled
+}
+
+// failure сообщает, свидетельствует ли ошибка о неисправности эндпоинта.
+// Отсутствующий ресурс или нехватка мощности - штатные ответы провайдера.
+func failure(err error) bool {
+	return err != nil && retryable(err)
+}
+
+// backoff возвращает паузу перед следующей попыткой: экспонента с полным
+// джиттером, но не меньше подсказки провайдера
+func (p *ResilientProvider) backoff(attempt int, err error) time.Duration {
+	ceiling := p.config.BaseBackoff << (attempt - 1)
+	if ceiling <= 0 || ceiling > p.config.MaxBackoff {
+		ceiling = p.config.MaxBackoff
+	}
+	wait := rand.N(ceiling) + 1
+	if hint := RetryAfter(err, 0); hint > wait {
+		wait = min(hint, p.config.MaxBackoff)
+	}
+	return wait
+}
+
+func sleep(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// allow проверяет предохранитель. Возвращает true и оставшееся время, если
+// вызов не разрешен. В полуоткрытом состоянии пропускается один пробный вызов.
+func (p *ResilientProvider) allow(op string) (time.Duration, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	b := p.breaker(op)
+	switch b.state {
+	case BreakerOpen:
+		now := time.Now()
+		if now.Before(b.openUntil) {
+			return b.openUntil.Sub(now), true
+		}
+		p.transition(op, b, BreakerHalfOpen)
+		b.probing = true
+		return 0, false
+	case BreakerHalfOpen:
+		if b.probing {
+			return p.config.BreakerCooldown, true
+		}
+		b.probing = true
+	}
+	return 0, false
+}
+
+// record учитывает результат вызова в предохранителе
+func (p *ResilientProvider) record(op string, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.recordHealth(err)
+	b := p.breaker(op)
+	b.probing = false
+	if !failure(err) {
+		b.failures = 0
+		if b.state != BreakerClosed {
+			p.transition(op, b, BreakerClosed)
+		}
+		return
+	}
+
+	b.failures++
+	b.lastError = err.Error()
+	if b.state == BreakerHalfOpen || b.failures >= p.config.BreakerThreshold {
+		b.openUntil = time.Now().Add(p.config.BreakerCooldown)
+		p.transition(op, b, BreakerOpen)
+	}
+}
+
+// abandon снимает пробный вызов, отмененный вызывающим
+func (p *ResilientProvider) abandon(op string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.breaker(op).probing = false
+}
+
+// breaker возвращает предохранитель эндпоинта. Вызывается под блокировкой.
+func (p *ResilientProvider) breaker(op string) *breaker {
+	b, exists := p.breakers[op]
+	if !exists {
+		b = &breaker{state: BreakerClosed}
+		p.breakers[op] = b
+	}
+	return b
+}
+
+// transition меняет состояние и уведомляет наблюдателя. Вызывается под блокировкой.
+func (p *ResilientProvider) transition(op string, b *breaker, state BreakerState) {
+	b.state = state
+	if p.observer != nil {
+		p.observer.ObserveBreaker(p.name, op, state)
+	}
+}
+
+func (p *ResilientProvider) observeCall(op string, attempt int, duration time.Duration, err error) {
+	p.mu.Lock()
+	observer := p.observer
+	p.mu.Unlock()
+	if observer != nil {
+		observer.ObserveCall(p.name, op, attempt, duration, err)
+	}
+}
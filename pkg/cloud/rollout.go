@@ -0,0 +1,67 @@
+package cloud
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ProviderMode - режим провайдера для автоматики
+type ProviderMode struct {
+	Provider    string    `json:"provider"`
+	ObserveOnly bool      `json:"observe_only"`
+	ChangedAt   time.Time `json:"changed_at,omitempty"`
+}
+
+// Rollout хранит провайдеров в режиме наблюдения (dark launch): их
+// инстансы собираются, оцениваются и попадают в отчеты, но не становятся
+// ни источником, ни целью миграций и масштабирования, пока оператор не
+// снимет флаг. Так новый провайдер подключается в рабочей среде без риска.
+type Rollout struct {
+	mu    sync.RWMutex
+	modes map[string]ProviderMode
+}
+
+// NewRollout создает реестр режимов; observeOnly - провайдеры в режиме наблюдения
+func NewRollout(observeOnly ...string) *Rollout {
+	r := &Rollout{modes: make(map[string]ProviderMode)}
+	for _, provider := range observeOnly {
+		if provider = strings.TrimSpace(provider); provider != "" {
+			r.modes[provider] = ProviderMode{Provider: provider, ObserveOnly: true}
+		}
+	}
+	return r
+}
+
+// ObserveOnly сообщает, находится ли провайдер в режиме наблюдения.
+// Пустой реестр (nil) ничего не ограничивает.
+func (r *Rollout) ObserveOnly(provider string) bool {
+	if r == nil {
+		return false
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.modes[provider].ObserveOnly
+}
+
+// SetObserveOnly включает или снимает режим наблюдения провайдера
+func (r *Rollout) SetObserveOnly(provider string, observeOnly bool) ProviderMode {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	mode := ProviderMode{Provider: provider, ObserveOnly: observeOnly, ChangedAt: time.Now()}
+	r.modes[provider] = mode
+	return mode
+}
+
+// Modes возвращает режимы провайдеров, для которых флаг задавался
+func (r *Rollout) Modes() []ProviderMode {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	modes := make([]ProviderMode, 0, len(r.modes))
+	for _, mode := range r.modes {
+		modes = append(modes, mode)
+	}
+	sort.Slice(modes, func(i, j int) bool { return modes[i].Provider < modes[j].Provider })
+	return modes
+}
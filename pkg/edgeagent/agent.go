@@ -0,0 +1,395 @@
+// Package edgeagent - облегченный агент Platypus для периферийных и IoT
+// устройств. Показания копятся на диске (store-and-forward) и выгружаются
+// сжатыми пачками, когда есть связь; интервалы сбора и выгрузки задает сервер
+// (POST /api/v1/edge/{server_id}/upload). В памяти агент держит только
+// текущую точку и одну выгрузку.
+package edgeagent
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/YumeNoTenshi/platypus/pkg/hostpower"
+)
+
+type Config struct {
+	ServerURL    string // Адрес сервера Platypus, например https://platypus.example.com
+	ServerID     string
+	APIKey       string
+	Dir          string            // Каталог спула
+	SegmentBytes int64             // Размер сегмента спула
+	MaxBytes     int64             // Максимум диска под спул; при переполнении теряются самые старые точки
+	Labels       map[string]string // Метки, добавляемые к каждой точке
+	Timeout      time.Duration     // Таймаут одной выгрузки
+
+	// Начальные интервалы, пока сервер не прислал указание
+	SampleInterval time.Duration
+	UploadInterval time.Duration
+	MaxBatch       int
+}
+
+// Directive повторяет формат указания сервера (edge.Directive)
+type Directive struct {
+	SampleInterval int    `json:"sample_interval_seconds"`
+	UploadInterval int    `json:"upload_interval_seconds"`
+	MaxBatch       int    `json:"max_batch"`
+	Reason         string `json:"reason"`
+}
+
+// Stats описывает состояние агента
+type Stats struct {
+	PendingSegments int       `json:"pending_segments"`
+	PendingBytes    int64     `json:"pending_bytes"`
+	Uploaded        uint64    `json:"uploaded"`
+	Rejected        uint64    `json:"rejected"` // Точки, отклоненные сервером
+	Dropped         uint64    `json:"dropped"`  // Точки, удаленные при переполнении спула
+	LastUpload      time.Time `json:"last_upload,omitempty"`
+	LastError       string    `json:"last_error,omitempty"`
+}
+
+// point - строка спула: показания хоста с меткой устройства
+type point struct {
+	hostpower.Sample
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+// errRetryLater - сервер не принял выгрузку сейчас; сегмент остается в спуле
+var errRetryLater = errors.New("server asked to retry later")
+
+type Agent struct {
+	config  Config
+	sampler hostpower.Sampler
+	spool   *spool
+	client  *http.Client
+	trigger chan struct{}
+
+	mu        sync.Mutex
+	directive Directive
+	stats     Stats
+}
+
+func New(config Config, sampler hostpower.Sampler) (*Agent, error) {
+	if config.ServerURL == "" || config.ServerID == "" {
+		return nil, fmt.Errorf("server url and server id are required")
+	}
+	if sampler == nil {
+		return nil, fmt.Errorf("sampler is required")
+	}
+	if config.Dir == "" {
+		return nil, fmt.Errorf("spool directory is required")
+	}
+	if config.SegmentBytes <= 0 {
+		config.SegmentBytes = 256 << 10
+	}
+	if config.MaxBytes <= 0 {
+		config.MaxBytes = 64 << 20
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = 30 * time.Second
+	}
+	if config.SampleInterval <= 0 {
+		config.SampleInterval = time.Minute
+	}
+	if config.UploadInterval <= 0 {
+		config.UploadInterval = 5 * time.Minute
+	}
+	if config.MaxBatch <= 0 {
+		config.MaxBatch = 1000
+	}
+
+	spool, err := openSpool(config.Dir, config.SegmentBytes, config.MaxBytes)
+	if err != nil {
+		return nil, err
+	}
+	spool.setMaxLines(config.MaxBatch)
+
+	return &Agent{
+		config:  config,
+		sampler: sampler,
+		spool:   spool,
+		client:  &http.Client{Timeout: config.Timeout},
+		trigger: make(chan struct{}, 1),
+		directive: Directive{
+			SampleInterval: int(config.SampleInterval / time.Second),
+			UploadInterval: int(config.UploadInterval / time.Second),
+			MaxBatch:       config.MaxBatch,
+		},
+	}, nil
+}
+
+// Run снимает показания и выгружает спул до отмены контекста. Сбор не
+// зависит от связи: пока сервер недоступен, точки копятся на диске.
+func (a *Agent) Run(ctx context.Context) error {
+	defer a.spool.close()
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		a.uploadLoop(ctx)
+	}()
+	defer wg.Wait()
+
+	for {
+		sample, err := a.sampler.Sample(ctx, a.sampleInterval())
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if errors.Is(err, hostpower.ErrSamplingUnsupported) {
+			return err
+		}
+		if err != nil {
+			log.Printf("Не удалось снять показания: %v", err)
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(a.sampleInterval()):
+			}
+			continue
+		}
+
+		line, err := json.Marshal(point{Sample: sample, Labels: a.config.Labels})
+		if err != nil {
+			return err
+		}
+		if err := a.spool.append(line); err != nil {
+			log.Printf("Не удалось записать точку в спул: %v", err)
+		}
+	}
+}
+
+// Flush закрывает текущий сегмент и просит выгрузить спул немедленно,
+// например при появлении связи
+func (a *Agent) Flush() {
+	select {
+	case a.trigger <- struct{}{}:
+	default:
+	}
+}
+
+// Stats возвращает состояние агента
+func (a *Agent) Stats() Stats {
+	a.mu.Lock()
+	stats := a.stats
+	a.mu.Unlock()
+	stats.PendingSegments, stats.PendingBytes, stats.Dropped = a.spool.pending()
+	return stats
+}
+
+// Directive возвращает действующее указание сервера
+func (a *Agent) Directive() Directive {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.directive
+}
+
+func (a *Agent) sampleInterval() time.Duration {
+	return time.Duration(a.Directive().SampleInterval) * time.Second
+}
+
+func (a *Agent) uploadLoop(ctx context.Context) {
+	for {
+		timer := time.NewTimer(time.Duration(a.Directive().UploadInterval) * time.Second)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		case <-a.trigger:
+			timer.Stop()
+		}
+
+		if err := a.spool.rotate(); err != nil {
+			log.Printf("Не удалось закрыть сегмент спула: %v", err)
+		}
+		if err := a.uploadAll(ctx); err != nil && ctx.Err() == nil {
+			a.mu.Lock()
+			a.stats.LastError = err.Error()
+			a.mu.Unlock()
+			log.Printf("Выгрузка спула отложена: %v", err)
+		}
+	}
+}
+
+// uploadAll выгружает закрытые сегменты от старых к новым. При первой же
+// ошибке связи выгрузка прекращается до следующего интервала.
+func (a *Agent) uploadAll(ctx context.Context) error {
+	for {
+		seg, ok := a.spool.oldest()
+		if !ok {
+			return nil
+		}
+		if err := a.uploadSegment(ctx, seg); err != nil {
+			return err
+		}
+	}
+}
+
+// uploadSegment отправляет сегмент пачками не больше MaxBatch точек. Если
+// выгрузка прервалась посередине, в сегменте остаются только неотправленные
+// точки.
+func (a *Agent) uploadSegment(ctx context.Context, seg segment) error {
+	file, err := os.Open(seg.path)
+	if os.IsNotExist(err) {
+		return a.spool.remove(seg)
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 4096), 1<<20)
+
+	var batch bytes.Buffer
+	lines := 0
+	var sent int64 // Байт сегмента, уже принятых сервером
+	var batchBytes int64
+	flush := func() error {
+		if lines == 0 {
+			return nil
+		}
+		if err := a.upload(ctx, batch.Bytes()); err != nil {
+			return err
+		}
+		sent += batchBytes
+		batch.Reset()
+		lines, batchBytes = 0, 0
+		return nil
+	}
+
+	for scanner.Scan() {
+		batch.Write(scanner.Bytes())
+		batch.WriteByte('\n')
+		batchBytes += int64(len(scanner.Bytes())) + 1
+		lines++
+		if lines >= a.Directive().MaxBatch {
+			if err := flush(); err != nil {
+				return a.keepUnsent(seg, file, sent, err)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	if err := flush(); err != nil {
+		return a.keepUnsent(seg, file, sent, err)
+	}
+	return a.spool.remove(seg)
+}
+
+// keepUnsent переписывает сегмент без точек, уже принятых сервером, чтобы
+// при повторе они не дублировались
+func (a *Agent) keepUnsent(seg segment, file *os.File, sent int64, cause error) error {
+	if sent == 0 {
+		return cause
+	}
+	if _, err := file.Seek(sent, io.SeekStart); err != nil {
+		return cause
+	}
+	tmp := seg.path + ".tmp"
+	out, err := os.Create(tmp)
+	if err != nil {
+		return cause
+	}
+	if _, err := io.Copy(out, file); err != nil {
+		out.Close()
+		os.Remove(tmp)
+		return cause
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(tmp)
+		return cause
+	}
+	// На Windows открытый файл нельзя заменить
+	file.Close()
+	os.Rename(tmp, seg.path)
+	return cause
+}
+
+// upload отправляет пачку NDJSON со сжатием gzip и применяет указание из ответа
+func (a *Agent) upload(ctx context.Context, ndjson []byte) error {
+	var body bytes.Buffer
+	zw := gzip.NewWriter(&body)
+	if _, err := zw.Write(ndjson); err != nil {
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+
+	endpoint := fmt.Sprintf("%s/api/v1/edge/%s/upload", a.config.ServerURL, url.PathEscape(a.config.ServerID))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	req.Header.Set("Content-Encoding", "gzip")
+	req.Header.Set("User-Agent", "platypus-edge-agent")
+	if a.config.APIKey != "" {
+		req.Header.Set("X-API-Key", a.config.APIKey)
+	}
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusServiceUnavailable || resp.StatusCode == http.StatusTooManyRequests:
+		return errRetryLater
+	case resp.StatusCode/100 != 2:
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("upload failed with status %d: %s", resp.StatusCode, bytes.TrimSpace(message))
+	}
+
+	var result struct {
+		Data struct {
+			Accepted  int               `json:"accepted"`
+			Rejected  map[string]string `json:"rejected"`
+			Directive Directive         `json:"directive"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&result); err != nil {
+		return fmt.Errorf("decode upload response: %w", err)
+	}
+
+	a.mu.Lock()
+	a.stats.Uploaded += uint64(result.Data.Accepted)
+	a.stats.Rejected += uint64(len(result.Data.Rejected))
+	a.stats.LastUpload = time.Now()
+	a.stats.LastError = ""
+	a.mu.Unlock()
+	a.apply(result.Data.Directive)
+	return nil
+}
+
+// apply применяет указание сервера; нулевые поля оставляют прежние значения
+func (a *Agent) apply(directive Directive) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if directive.SampleInterval > 0 {
+		a.directive.SampleInterval = directive.SampleInterval
+	}
+	if directive.UploadInterval > 0 {
+		a.directive.UploadInterval = directive.UploadInterval
+	}
+	if directive.MaxBatch > 0 {
+		a.directive.MaxBatch = directive.MaxBatch
+		a.spool.setMaxLines(directive.MaxBatch)
+	}
+	a.directive.Reason = directive.Reason
+}
@@ -0,0 +1,200 @@
+package edgeagent
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const segmentExt = ".ndjson"
+
+// spool хранит неотправленные точки на диске сегментами NDJSON. Точки
+// дописываются в текущий сегмент; заполненный сегмент закрывается и ждет
+// выгрузки. В памяти держатся только имена и размеры сегментов.
+type spool struct {
+	dir          string
+	segmentBytes int64
+	maxBytes     int64
+
+	mu       sync.Mutex
+	closed   []segment // Закрытые сегменты, от старых к новым
+	current  *os.File
+	seq      uint64
+	size     int64 // Размер текущего сегмента
+	lines    int   // Точек в текущем сегменте
+	maxLines int
+	dropped  uint64 // Точки, удаленные при переполнении диска
+}
+
+type segment struct {
+	path  string
+	size  int64
+	lines int // -1 - неизвестно (сегмент остался от прошлого запуска)
+}
+
+func openSpool(dir string, segmentBytes, maxBytes int64) (*spool, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("create spool directory: %w", err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("read spool directory: %w", err)
+	}
+
+	s := &spool{dir: dir, segmentBytes: segmentBytes, maxBytes: maxBytes}
+	// Сегменты прошлого запуска, включая недописанный, отправляются как есть
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, segmentExt) {
+			continue
+		}
+		seq, err := strconv.ParseUint(strings.TrimSuffix(name, segmentExt), 10, 64)
+		if err != nil {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil, err
+		}
+		if info.Size() == 0 {
+			os.Remove(filepath.Join(dir, name))
+			continue
+		}
+		s.closed = append(s.closed, segment{path: filepath.Join(dir, name), size: info.Size(), lines: -1})
+		s.seq = max(s.seq, seq)
+	}
+	sort.Slice(s.closed, func(i, j int) bool { return s.closed[i].path < s.closed[j].path })
+	return s, nil
+}
+
+// append дописывает строку NDJSON в текущий сегмент
+func (s *spool) append(line []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.current == nil {
+		s.seq++
+		file, err := os.OpenFile(s.segmentPath(s.seq), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return fmt.Errorf("open spool segment: %w", err)
+		}
+		s.current, s.size, s.lines = file, 0, 0
+	}
+
+	n, err := s.current.Write(append(line, '\n'))
+	s.size += int64(n)
+	if err != nil {
+		return fmt.Errorf("write spool segment: %w", err)
+	}
+	s.lines++
+	if s.size >= s.segmentBytes || (s.maxLines > 0 && s.lines >= s.maxLines) {
+		return s.rotateLocked()
+	}
+	return nil
+}
+
+// rotate закрывает текущий сегмент, чтобы его можно было выгрузить
+func (s *spool) rotate() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.rotateLocked()
+}
+
+func (s *spool) rotateLocked() error {
+	if s.current == nil {
+		return nil
+	}
+	// Сегмент сбрасывается на диск перед закрытием: устройство может потерять
+	// питание в любой момент
+	syncErr := s.current.Sync()
+	closeErr := s.current.Close()
+	s.closed = append(s.closed, segment{path: s.current.Name(), size: s.size, lines: s.lines})
+	s.current = nil
+	s.enforceLimitLocked()
+	if syncErr != nil {
+		return syncErr
+	}
+	return closeErr
+}
+
+// enforceLimitLocked удаляет самые старые сегменты, пока спул больше maxBytes:
+// свежие данные ценнее старых, а диск устройства мал
+func (s *spool) enforceLimitLocked() {
+	if s.maxBytes <= 0 {
+		return
+	}
+	total := s.size
+	for _, seg := range s.closed {
+		total += seg.size
+	}
+	for total > s.maxBytes && len(s.closed) > 1 {
+		oldest := s.closed[0]
+		if err := os.Remove(oldest.path); err != nil && !os.IsNotExist(err) {
+			return
+		}
+		if oldest.lines > 0 {
+			s.dropped += uint64(oldest.lines)
+		}
+		total -= oldest.size
+		s.closed = s.closed[1:]
+	}
+}
+
+// oldest возвращает самый старый закрытый сегмент
+func (s *spool) oldest() (segment, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.closed) == 0 {
+		return segment{}, false
+	}
+	return s.closed[0], true
+}
+
+// remove удаляет выгруженный сегмент
+func (s *spool) remove(seg segment) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, closed := range s.closed {
+		if closed.path == seg.path {
+			s.closed = append(s.closed[:i], s.closed[i+1:]...)
+			break
+		}
+	}
+	if err := os.Remove(seg.path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (s *spool) setMaxLines(lines int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.maxLines = lines
+}
+
+// pending возвращает число сегментов и байт, ожидающих выгрузки
+func (s *spool) pending() (segments int, bytes int64, dropped uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	bytes = s.size
+	for _, seg := range s.closed {
+		bytes += seg.size
+	}
+	segments = len(s.closed)
+	if s.current != nil {
+		segments++
+	}
+	return segments, bytes, s.dropped
+}
+
+func (s *spool) close() error {
+	return s.rotate()
+}
+
+func (s *spool) segmentPath(seq uint64) string {
+	return filepath.Join(s.dir, fmt.Sprintf("%020d%s", seq, segmentExt))
+}
@@ -0,0 +1,24 @@
+package hostpower
+
+import "time"
+
+// ContainerSample - загрузка одного контейнера по его cgroup
+type ContainerSample struct {
+	ContainerID string  // Идентификатор контейнера среды выполнения (64 шестнадцатеричных символа)
+	CPUUsage    float64 // Процент всех CPU хоста
+	MemoryUsage float64 // Процент лимита памяти контейнера, без лимита - памяти хоста
+}
+
+// ContainerSampler снимает загрузку контейнеров хоста. Загрузка CPU
+// считается по приращению с прошлого вызова, поэтому первый вызов только
+// запоминает счетчики и возвращает пустой список.
+type ContainerSampler interface {
+	Sample(now time.Time) ([]ContainerSample, error)
+}
+
+// NewContainerSampler создает сборщик загрузки контейнеров по иерархии cgroup
+// v2, смонтированной в root (обычно /sys/fs/cgroup). Поддерживается только
+// Linux; на остальных платформах возвращает ErrSamplingUnsupported.
+func NewContainerSampler(root string) (ContainerSampler, error) {
+	return newContainerSampler(root)
+}
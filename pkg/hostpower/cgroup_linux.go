@@ -0,0 +1,112 @@
+//go:build linux
+
+package hostpower
+
+import (
+	"bufio"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// containerScope выделяет идентификатор контейнера из имени каталога cgroup:
+// docker-<id>.scope, cri-containerd-<id>.scope, crio-<id>.scope (драйвер
+// systemd) или просто <id> (драйвер cgroupfs)
+var containerScope = regexp.MustCompile(`^(?:(?:docker|cri-containerd|crio|libpod)-)?([0-9a-f]{64})(?:\.scope)?$`)
+
+type cgroupReading struct {
+	usage float64 // usage_usec из cpu.stat
+	at    time.Time
+}
+
+type cgroupSampler struct {
+	root     string
+	previous map[string]cgroupReading
+}
+
+func newContainerSampler(root string) (ContainerSampler, error) {
+	if _, err := os.Stat(filepath.Join(root, "cgroup.controllers")); err != nil {
+		return nil, fmt.Errorf("%w: %s is not a cgroup v2 hierarchy", ErrSamplingUnsupported, root)
+	}
+	return &cgroupSampler{root: root, previous: make(map[string]cgroupReading)}, nil
+}
+
+func (s *cgroupSampler) Sample(now time.Time) ([]ContainerSample, error) {
+	var hostMemory float64
+	if info, err := readMeminfo(); err == nil {
+		hostMemory = info["MemTotal"]
+	}
+	cpus := float64(runtime.NumCPU())
+
+	current := make(map[string]cgroupReading)
+	var samples []ContainerSample
+	err := filepath.WalkDir(s.root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			// Контейнер мог завершиться во время обхода
+			return fs.SkipDir
+		}
+		if !entry.IsDir() {
+			return nil
+		}
+		match := containerScope.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil
+		}
+		id := match[1]
+
+		usage, err := cgroupCPUUsage(path)
+		if err != nil {
+			return fs.SkipDir
+		}
+		current[id] = cgroupReading{usage: usage, at: now}
+
+		previous, seen := s.previous[id]
+		elapsed := now.Sub(previous.at).Microseconds()
+		if !seen || elapsed <= 0 || usage < previous.usage {
+			return fs.SkipDir
+		}
+		sample := ContainerSample{
+			ContainerID: id,
+			CPUUsage:    min(100, 100*(usage-previous.usage)/(float64(elapsed)*cpus)),
+		}
+		if used, err := readFloat(filepath.Join(path, "memory.current")); err == nil {
+			limit, err := readFloat(filepath.Join(path, "memory.max")) // "max" - без лимита
+			if err != nil || limit <= 0 {
+				limit = hostMemory
+			}
+			if limit > 0 {
+				sample.MemoryUsage = 100 * used / limit
+			}
+		}
+		samples = append(samples, sample)
+		return fs.SkipDir
+	})
+	s.previous = current
+	return samples, err
+}
+
+// cgroupCPUUsage читает usage_usec из cpu.stat
+func cgroupCPUUsage(dir string) (float64, error) {
+	file, err := os.Open(filepath.Join(dir, "cpu.stat"))
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if value, found := strings.CutPrefix(scanner.Text(), "usage_usec "); found {
+			return strconv.ParseFloat(value, 64)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, err
+	}
+	return 0, fmt.Errorf("usage_usec missing in %s/cpu.stat", dir)
+}
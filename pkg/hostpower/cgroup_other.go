@@ -0,0 +1,7 @@
+//go:build !linux
+
+package hostpower
+
+func newContainerSampler(root string) (ContainerSampler, error) {
+	return nil, ErrSamplingUnsupported
+}
@@ -0,0 +1,23 @@
+// Package hostpower содержит функции агента Platypus на хосте: снятие
+// показаний мощности и загрузки (Linux, Windows, macOS) и управление
+// энергопотреблением CPU (Linux)
+package hostpower
+
+import "errors"
+
+// ErrUnsupported возвращается на платформах без поддержки управления частотой CPU
+var ErrUnsupported = errors.New("cpu frequency management is not supported on this platform")
+
+// GovernorDirective повторяет формат указания, выдаваемого сервером
+type GovernorDirective struct {
+	Governor        string `json:"governor"`
+	MaxFrequencyMHz int    `json:"max_frequency_mhz,omitempty"`
+}
+
+// Apply применяет указание сервера к локальному хосту
+func (d GovernorDirective) Apply() error {
+	if err := SetGovernor(d.Governor); err != nil {
+		return err
+	}
+	return SetMaxFrequency(d.MaxFrequencyMHz)
+}
@@ -0,0 +1,87 @@
+//go:build linux
+
+package hostpower
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const cpufreqGlob = "/sys/devices/system/cpu/cpu[0-9]*/cpufreq"
+
+// SetGovernor устанавливает scaling_governor для всех ядер
+func SetGovernor(governor string) error {
+	dirs, err := cpufreqDirs()
+	if err != nil {
+		return err
+	}
+
+	for _, dir := range dirs {
+		available, err := os.ReadFile(filepath.Join(dir, "scaling_available_governors"))
+		if err == nil && !strings.Contains(string(available), governor) {
+			return fmt.Errorf("governor %s is not available in %s", governor, dir)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "scaling_governor"), []byte(governor), 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SetMaxFrequency ограничивает максимальную частоту всех ядер.
+// Значение 0 снимает ограничение (возвращает аппаратный максимум).
+func SetMaxFrequency(mhz int) error {
+	dirs, err := cpufreqDirs()
+	if err != nil {
+		return err
+	}
+
+	for _, dir := range dirs {
+		khz := mhz * 1000
+		if mhz == 0 {
+			khz, err = readInt(filepath.Join(dir, "cpuinfo_max_freq"))
+			if err != nil {
+				return err
+			}
+		}
+		if err := os.WriteFile(filepath.Join(dir, "scaling_max_freq"), []byte(strconv.Itoa(khz)), 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CurrentGovernor возвращает governor первого ядра
+func CurrentGovernor() (string, error) {
+	dirs, err := cpufreqDirs()
+	if err != nil {
+		return "", err
+	}
+	data, err := os.ReadFile(filepath.Join(dirs[0], "scaling_governor"))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+func cpufreqDirs() ([]string, error) {
+	dirs, err := filepath.Glob(cpufreqGlob)
+	if err != nil {
+		return nil, err
+	}
+	if len(dirs) == 0 {
+		return nil, ErrUnsupported
+	}
+	return dirs, nil
+}
+
+func readInt(path string) (int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(strings.TrimSpace(string(data)))
+}
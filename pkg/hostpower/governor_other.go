@@ -0,0 +1,15 @@
+//go:build !linux
+
+package hostpower
+
+func SetGovernor(governor string) error {
+	return ErrUnsupported
+}
+
+func SetMaxFrequency(mhz int) error {
+	return ErrUnsupported
+}
+
+func CurrentGovernor() (string, error) {
+	return "", ErrUnsupported
+}
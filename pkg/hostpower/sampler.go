@@ -0,0 +1,46 @@
+package hostpower
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrSamplingUnsupported возвращается на платформах, где агент не умеет
+// снимать показания хоста
+var ErrSamplingUnsupported = errors.New("host power sampling is not supported on this platform")
+
+// Источники мощности (Sample.PowerSource)
+const (
+	PowerSourcePowerMeter   = "power-meter"  // Измеритель мощности ACPI (Windows Power Meter)
+	PowerSourceEnergyMeter  = "energy-meter" // Каналы Energy Meter Interface (Windows)
+	PowerSourcePowermetrics = "powermetrics" // Оценка powermetrics (macOS)
+	PowerSourceACPIMeter    = "acpi-meter"   // Измеритель мощности ACPI в hwmon (Linux)
+	PowerSourceRAPL         = "rapl"         // Счетчики энергии Intel/AMD RAPL: пакеты CPU и память (Linux)
+)
+
+// Sample - показания хоста за интервал в формате точки метрик сервера
+// (POST /api/v1/metrics): агент дополняет их server_id и отправляет
+type Sample struct {
+	Timestamp     int64   `json:"timestamp"`
+	PowerUsage    float64 `json:"power_usage"`  // Ватты; 0 - платформа не сообщает мощность, ее оценит модель на сервере
+	CPUUsage      float64 `json:"cpu_usage"`    // Процент
+	MemoryUsage   float64 `json:"memory_usage"` // Процент
+	GPUUsage      float64 `json:"gpu_usage,omitempty"`
+	GPUPowerUsage float64 `json:"gpu_power_usage,omitempty"` // Ватты, входит в PowerUsage
+	PowerSource   string  `json:"-"`                         // Откуда взята мощность; пусто - мощности нет
+}
+
+// Sampler снимает показания хоста. Sample блокируется на interval: загрузка
+// и мощность усредняются за этот интервал.
+type Sampler interface {
+	Sample(ctx context.Context, interval time.Duration) (Sample, error)
+	Close() error
+}
+
+// NewSampler создает сборщик показаний текущей платформы: sysfs и procfs на
+// Linux, счетчики производительности на Windows, powermetrics на macOS. На
+// остальных платформах возвращает ErrSamplingUnsupported.
+func NewSampler() (Sampler, error) {
+	return newSampler()
+}
@@ -0,0 +1,154 @@
+//go:build darwin
+
+package hostpower
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// darwinSampler снимает показания утилитами системы: мощность и загрузку GPU
+// - powermetrics (требует прав root), загрузку CPU и памяти - top. Обе
+// утилиты запускаются одновременно и усредняют показания за один интервал.
+type darwinSampler struct{}
+
+func newSampler() (Sampler, error) {
+	if _, err := exec.LookPath("powermetrics"); err != nil {
+		return nil, fmt.Errorf("%w: powermetrics not found", ErrSamplingUnsupported)
+	}
+	return darwinSampler{}, nil
+}
+
+func (darwinSampler) Sample(ctx context.Context, interval time.Duration) (Sample, error) {
+	type result struct {
+		output []byte
+		err    error
+	}
+	milliseconds := max(interval.Milliseconds(), 100)
+	seconds := max(int(interval.Round(time.Second).Seconds()), 1)
+
+	power := make(chan result, 1)
+	go func() {
+		output, err := exec.CommandContext(ctx, "powermetrics", "--samplers", "cpu_power,gpu_power",
+			"-i", strconv.FormatInt(milliseconds, 10), "-n", "1").Output()
+		power <- result{output, err}
+	}()
+	// top выводит два отсчета: первый - с момента загрузки системы, второй - за интервал
+	load, err := exec.CommandContext(ctx, "top", "-l", "2", "-n", "0", "-s", strconv.Itoa(seconds)).Output()
+	if err != nil {
+		return Sample{}, fmt.Errorf("top failed: %w", err)
+	}
+	metered := <-power
+
+	sample := Sample{Timestamp: time.Now().Unix()}
+	if err := parseTop(string(load), &sample); err != nil {
+		return Sample{}, err
+	}
+	if metered.err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(metered.err, &exitErr) {
+			return Sample{}, fmt.Errorf("powermetrics failed (it requires root): %s", strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return Sample{}, fmt.Errorf("powermetrics failed: %w", metered.err)
+	}
+	parsePowermetrics(string(metered.output), &sample)
+	return sample, nil
+}
+
+func (darwinSampler) Close() error {
+	return nil
+}
+
+var (
+	// Apple Silicon: "Combined Power (CPU + GPU + ANE): 1290 mW"
+	combinedPower = regexp.MustCompile(`Combined Power \([^)]*\):\s*([\d.]+)\s*mW`)
+	// Apple Silicon: "CPU Power: 1234 mW"; Intel - только пакет, см. intelPower
+	cpuPower = regexp.MustCompile(`(?m)^CPU Power:\s*([\d.]+)\s*mW`)
+	gpuPower = regexp.MustCompile(`(?m)^GPU Power:\s*([\d.]+)\s*mW`)
+	// Intel: "Intel energy model derived package power (CPUs+GT+SA): 5.43W"
+	intelPower = regexp.MustCompile(`package power \([^)]*\):\s*([\d.]+)\s*W`)
+	// "GPU HW active residency:  12.34%" (Apple Silicon) или "GPU active residency: 12.34%"
+	gpuResidency = regexp.MustCompile(`GPU (?:HW )?active residency:\s*([\d.]+)%`)
+
+	// "CPU usage: 5.12% user, 8.40% sys, 86.47% idle"
+	topCPU = regexp.MustCompile(`CPU usage:.*?([\d.]+)% idle`)
+	// "PhysMem: 15G used (2101M wired), 1024M unused."
+	topMemory = regexp.MustCompile(`PhysMem:\s*([\d.]+[KMGT]?)\s+used.*?([\d.]+[KMGT]?)\s+unused`)
+)
+
+// parsePowermetrics заполняет мощность и загрузку GPU. Мощность
+// powermetrics - оценка по модели энергопотребления SoC, а не измерение на
+// входе питания: дисплей и периферия в нее не входят.
+func parsePowermetrics(output string, sample *Sample) {
+	if milliwatts, ok := lastFloat(combinedPower, output); ok {
+		sample.PowerUsage = milliwatts / 1000
+	} else if milliwatts, ok := lastFloat(cpuPower, output); ok {
+		sample.PowerUsage = milliwatts / 1000
+		if gpu, ok := lastFloat(gpuPower, output); ok {
+			sample.PowerUsage += gpu / 1000
+		}
+	} else if watts, ok := lastFloat(intelPower, output); ok {
+		sample.PowerUsage = watts
+	}
+	if gpu, ok := lastFloat(gpuPower, output); ok {
+		sample.GPUPowerUsage = gpu / 1000
+	}
+	if residency, ok := lastFloat(gpuResidency, output); ok {
+		sample.GPUUsage = residency
+	}
+	if sample.PowerUsage > 0 {
+		sample.PowerSource = PowerSourcePowermetrics
+	}
+}
+
+// parseTop берет загрузку CPU и памяти из последнего отсчета top
+func parseTop(output string, sample *Sample) error {
+	idle, ok := lastFloat(topCPU, output)
+	if !ok {
+		return fmt.Errorf("unexpected top output: no CPU usage")
+	}
+	sample.CPUUsage = 100 - idle
+
+	matches := topMemory.FindAllStringSubmatch(output, -1)
+	if len(matches) == 0 {
+		return fmt.Errorf("unexpected top output: no PhysMem")
+	}
+	last := matches[len(matches)-1]
+	used, unused := parseSize(last[1]), parseSize(last[2])
+	if used+unused > 0 {
+		sample.MemoryUsage = used / (used + unused) * 100
+	}
+	return nil
+}
+
+func lastFloat(pattern *regexp.Regexp, output string) (float64, bool) {
+	matches := pattern.FindAllStringSubmatch(output, -1)
+	if len(matches) == 0 {
+		return 0, false
+	}
+	value, err := strconv.ParseFloat(matches[len(matches)-1][1], 64)
+	return value, err == nil
+}
+
+// parseSize разбирает размер top вида "2101M"
+func parseSize(value string) float64 {
+	multiplier := 1.0
+	switch value[len(value)-1] {
+	case 'K':
+		multiplier = 1 << 10
+	case 'M':
+		multiplier = 1 << 20
+	case 'G':
+		multiplier = 1 << 30
+	case 'T':
+		multiplier = 1 << 40
+	}
+	number, _ := strconv.ParseFloat(strings.TrimRight(value, "KMGT"), 64)
+	return number * multiplier
+}
@@ -0,0 +1,259 @@
+//go:build linux
+
+package hostpower
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	powercapGlob = "/sys/class/powercap/intel-rapl:*"
+	hwmonGlob    = "/sys/class/hwmon/hwmon*"
+)
+
+// raplZone - домен RAPL со счетчиком энергии в микроджоулях, который
+// переполняется на max_energy_range_uj
+type raplZone struct {
+	name     string
+	energy   string // Путь к energy_uj
+	maxRange float64
+}
+
+// linuxSampler снимает показания из sysfs и procfs: мощность - по
+// измерителю ACPI (hwmon power_meter) всей системы или по счетчикам RAPL
+// (пакеты CPU и память), загрузку - по /proc/stat и /proc/meminfo.
+type linuxSampler struct {
+	meter string // Путь к power*_average измерителя ACPI; пусто - нет
+	zones []raplZone
+}
+
+func newSampler() (Sampler, error) {
+	s := &linuxSampler{meter: findPowerMeter()}
+	zones, err := raplZones()
+	if err != nil {
+		return nil, err
+	}
+	s.zones = zones
+	return s, nil
+}
+
+// findPowerMeter ищет измеритель мощности ACPI (драйвер acpi_power_meter)
+func findPowerMeter() string {
+	dirs, _ := filepath.Glob(hwmonGlob)
+	for _, dir := range dirs {
+		if readString(filepath.Join(dir, "name")) != "power_meter" {
+			continue
+		}
+		for _, file := range []string{"power1_average", "power1_input"} {
+			path := filepath.Join(dir, file)
+			if _, err := readFloat(path); err == nil {
+				return path
+			}
+		}
+	}
+	return ""
+}
+
+// raplZones возвращает домены RAPL без двойного счета: psys охватывает всю
+// платформу и используется один; иначе суммируются пакеты и их память (dram).
+// Домены core и uncore входят в пакет.
+func raplZones() ([]raplZone, error) {
+	dirs, _ := filepath.Glob(powercapGlob)
+	var packages []raplZone
+	for _, dir := range dirs {
+		// intel-rapl:0 - пакет, intel-rapl:0:1 - его поддомен
+		subzone := strings.Count(filepath.Base(dir), ":") > 1
+		name := readString(filepath.Join(dir, "name"))
+		if subzone && name != "dram" {
+			continue
+		}
+		zone := raplZone{name: name, energy: filepath.Join(dir, "energy_uj")}
+		zone.maxRange, _ = readFloat(filepath.Join(dir, "max_energy_range_uj"))
+		if _, err := readFloat(zone.energy); err != nil {
+			if os.IsPermission(err) {
+				// С исправления CVE-2020-8694 energy_uj доступен только root
+				return nil, fmt.Errorf("read %s: %w (the agent needs root to read RAPL counters)", zone.energy, err)
+			}
+			continue
+		}
+		if name == "psys" {
+			return []raplZone{zone}, nil
+		}
+		packages = append(packages, zone)
+	}
+	return packages, nil
+}
+
+func (s *linuxSampler) Sample(ctx context.Context, interval time.Duration) (Sample, error) {
+	startEnergy := s.energy()
+	startCPU, err := readCPUTimes()
+	if err != nil {
+		return Sample{}, err
+	}
+	startMeter, _ := readFloat(s.meter)
+	started := time.Now()
+
+	select {
+	case <-ctx.Done():
+		return Sample{}, ctx.Err()
+	case <-time.After(interval):
+	}
+
+	elapsed := time.Since(started).Seconds()
+	sample := Sample{Timestamp: time.Now().Unix()}
+
+	endCPU, err := readCPUTimes()
+	if err != nil {
+		return Sample{}, err
+	}
+	sample.CPUUsage = endCPU.usage(startCPU)
+	if sample.MemoryUsage, err = memoryUsage(); err != nil {
+		return Sample{}, err
+	}
+
+	// Измеритель ACPI видит всю систему, включая диски и вентиляторы, поэтому
+	// предпочтительнее RAPL
+	if s.meter != "" {
+		if endMeter, err := readFloat(s.meter); err == nil {
+			sample.PowerUsage = (startMeter + endMeter) / 2 / 1e6
+			sample.PowerSource = PowerSourceACPIMeter
+			return sample, nil
+		}
+	}
+	if len(s.zones) > 0 && elapsed > 0 {
+		var microjoules float64
+		endEnergy := s.energy()
+		for i, zone := range s.zones {
+			delta := endEnergy[i] - startEnergy[i]
+			if delta < 0 {
+				delta += zone.maxRange // Счетчик переполнился
+			}
+			microjoules += delta
+		}
+		sample.PowerUsage = microjoules / 1e6 / elapsed
+		sample.PowerSource = PowerSourceRAPL
+	}
+	return sample, nil
+}
+
+func (s *linuxSampler) energy() []float64 {
+	values := make([]float64, len(s.zones))
+	for i, zone := range s.zones {
+		values[i], _ = readFloat(zone.energy)
+	}
+	return values
+}
+
+func (s *linuxSampler) Close() error {
+	return nil
+}
+
+// cpuTimes - счетчики первой строки /proc/stat в тиках
+type cpuTimes struct {
+	busy, total float64
+}
+
+func readCPUTimes() (cpuTimes, error) {
+	file, err := os.Open("/proc/stat")
+	if err != nil {
+		return cpuTimes{}, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	if !scanner.Scan() {
+		return cpuTimes{}, fmt.Errorf("empty /proc/stat")
+	}
+	// cpu user nice system idle iowait irq softirq steal guest guest_nice;
+	// guest уже входит в user
+	fields := strings.Fields(scanner.Text())
+	if len(fields) < 5 || fields[0] != "cpu" {
+		return cpuTimes{}, fmt.Errorf("unexpected /proc/stat format")
+	}
+	var times cpuTimes
+	for i, field := range fields[1:min(len(fields), 9)] {
+		value, err := strconv.ParseFloat(field, 64)
+		if err != nil {
+			return cpuTimes{}, fmt.Errorf("unexpected /proc/stat value %q", field)
+		}
+		times.total += value
+		if i != 3 && i != 4 { // idle, iowait
+			times.busy += value
+		}
+	}
+	return times, nil
+}
+
+func (t cpuTimes) usage(previous cpuTimes) float64 {
+	total := t.total - previous.total
+	if total <= 0 {
+		return 0
+	}
+	return min(100, 100*(t.busy-previous.busy)/total)
+}
+
+// memoryUsage - доля занятой памяти хоста по MemAvailable
+func memoryUsage() (float64, error) {
+	info, err := readMeminfo()
+	if err != nil {
+		return 0, err
+	}
+	if info["MemTotal"] == 0 {
+		return 0, fmt.Errorf("MemTotal missing in /proc/meminfo")
+	}
+	return 100 * (info["MemTotal"] - info["MemAvailable"]) / info["MemTotal"], nil
+}
+
+// readMeminfo возвращает поля /proc/meminfo в байтах
+func readMeminfo() (map[string]float64, error) {
+	file, err := os.Open("/proc/meminfo")
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	info := make(map[string]float64)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		key, rest, found := strings.Cut(scanner.Text(), ":")
+		if !found {
+			continue
+		}
+		fields := strings.Fields(rest)
+		if len(fields) == 0 {
+			continue
+		}
+		value, err := strconv.ParseFloat(fields[0], 64)
+		if err != nil {
+			continue
+		}
+		if len(fields) > 1 && fields[1] == "kB" {
+			value *= 1024
+		}
+		info[key] = value
+	}
+	return info, scanner.Err()
+}
+
+func readString(path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+func readFloat(path string) (float64, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseFloat(strings.TrimSpace(string(data)), 64)
+}
@@ -0,0 +1,7 @@
+//go:build !windows && !darwin && !linux
+
+package hostpower
+
+func newSampler() (Sampler, error) {
+	return nil, ErrSamplingUnsupported
+}
@@ -0,0 +1,222 @@
+//go:build windows
+
+package hostpower
+
+import (
+	"context"
+	"fmt"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+var (
+	modpdh                          = syscall.NewLazyDLL("pdh.dll")
+	procPdhOpenQuery                = modpdh.NewProc("PdhOpenQueryW")
+	procPdhAddEnglishCounter        = modpdh.NewProc("PdhAddEnglishCounterW")
+	procPdhCollectQueryData         = modpdh.NewProc("PdhCollectQueryData")
+	procPdhGetFormattedCounterArray = modpdh.NewProc("PdhGetFormattedCounterArrayW")
+	procPdhCloseQuery               = modpdh.NewProc("PdhCloseQuery")
+
+	modkernel32              = syscall.NewLazyDLL("kernel32.dll")
+	procGlobalMemoryStatusEx = modkernel32.NewProc("GlobalMemoryStatusEx")
+)
+
+const (
+	pdhFmtDouble   = 0x00000200
+	pdhFmtNoCap100 = 0x00008000
+	pdhMoreData    = 0x800007D2
+	pdhNewData     = 1 // PDH_CSTATUS_NEW_DATA; 0 - PDH_CSTATUS_VALID_DATA
+)
+
+// Счетчики производительности. Power Meter - измеритель мощности ACPI всей
+// системы. Energy Meter - каналы Energy Meter Interface (пакет CPU, память),
+// по которым Windows оценивает потребление в Energy Estimation Engine; на
+// ноутбуках и части серверов это единственный источник мощности.
+const (
+	counterCPU         = `\Processor Information(_Total)\% Processor Utility`
+	counterCPULegacy   = `\Processor(_Total)\% Processor Time` // До Windows 8 и Server 2012
+	counterPowerMeter  = `\Power Meter(*)\Power`               // мВт
+	counterEnergyMeter = `\Energy Meter(*)\Power`              // мВт
+	counterGPU         = `\GPU Engine(*engtype_3D)\Utilization Percentage`
+)
+
+// pdhCounterValue повторяет PDH_FMT_COUNTERVALUE с форматом PDH_FMT_DOUBLE
+type pdhCounterValue struct {
+	status uint32
+	_      uint32 // Выравнивание объединения значений
+	value  float64
+}
+
+// pdhCounterItem повторяет PDH_FMT_COUNTERVALUE_ITEM_W
+type pdhCounterItem struct {
+	name  *uint16
+	value pdhCounterValue
+}
+
+// memoryStatusEx повторяет MEMORYSTATUSEX
+type memoryStatusEx struct {
+	length               uint32
+	memoryLoad           uint32
+	totalPhys            uint64
+	availPhys            uint64
+	totalPageFile        uint64
+	availPageFile        uint64
+	totalVirtual         uint64
+	availVirtual         uint64
+	availExtendedVirtual uint64
+}
+
+type windowsSampler struct {
+	query uintptr
+	// Счетчики запроса; 0 - счетчика нет на хосте
+	cpu, powerMeter, energyMeter, gpu uintptr
+}
+
+func newSampler() (Sampler, error) {
+	if err := procPdhOpenQuery.Find(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrSamplingUnsupported, err)
+	}
+
+	s := &windowsSampler{}
+	if status, _, _ := procPdhOpenQuery.Call(0, 0, uintptr(unsafe.Pointer(&s.query))); status != 0 {
+		return nil, fmt.Errorf("PdhOpenQuery failed: 0x%x", status)
+	}
+
+	var err error
+	if s.cpu, err = s.addCounter(counterCPU); err != nil {
+		if s.cpu, err = s.addCounter(counterCPULegacy); err != nil {
+			s.Close()
+			return nil, err
+		}
+	}
+	// Мощность и GPU необязательны: без них точка уходит с загрузкой, а
+	// мощность оценивает модель на сервере
+	s.powerMeter, _ = s.addCounter(counterPowerMeter)
+	s.energyMeter, _ = s.addCounter(counterEnergyMeter)
+	s.gpu, _ = s.addCounter(counterGPU)
+	return s, nil
+}
+
+func (s *windowsSampler) addCounter(path string) (uintptr, error) {
+	name, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		return 0, err
+	}
+	var counter uintptr
+	status, _, _ := procPdhAddEnglishCounter.Call(s.query, uintptr(unsafe.Pointer(name)), 0, uintptr(unsafe.Pointer(&counter)))
+	if status != 0 {
+		return 0, fmt.Errorf("counter %s is not available: 0x%x", path, status)
+	}
+	return counter, nil
+}
+
+// Sample собирает счетчики в начале и в конце интервала: счетчики загрузки
+// и мощности усредняются PDH между двумя сборами
+func (s *windowsSampler) Sample(ctx context.Context, interval time.Duration) (Sample, error) {
+	if err := s.collect(); err != nil {
+		return Sample{}, err
+	}
+	timer := time.NewTimer(interval)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return Sample{}, ctx.Err()
+	case <-timer.C:
+	}
+	if err := s.collect(); err != nil {
+		return Sample{}, err
+	}
+
+	sample := Sample{Timestamp: time.Now().Unix()}
+	cpu, err := s.sum(s.cpu)
+	if err != nil {
+		return Sample{}, err
+	}
+	// % Processor Utility учитывает турбочастоты и может превышать 100
+	sample.CPUUsage = min(cpu, 100)
+
+	if milliwatts, err := s.sum(s.powerMeter); err == nil && milliwatts > 0 {
+		sample.PowerUsage = milliwatts / 1000
+		sample.PowerSource = PowerSourcePowerMeter
+	} else if milliwatts, err := s.sum(s.energyMeter); err == nil && milliwatts > 0 {
+		sample.PowerUsage = milliwatts / 1000
+		sample.PowerSource = PowerSourceEnergyMeter
+	}
+	if utilization, err := s.sum(s.gpu); err == nil {
+		sample.GPUUsage = min(utilization, 100)
+	}
+
+	memory := memoryStatusEx{}
+	memory.length = uint32(unsafe.Sizeof(memory))
+	if ok, _, err := procGlobalMemoryStatusEx.Call(uintptr(unsafe.Pointer(&memory))); ok == 0 {
+		return Sample{}, fmt.Errorf("GlobalMemoryStatusEx failed: %w", err)
+	}
+	if memory.totalPhys > 0 {
+		sample.MemoryUsage = float64(memory.totalPhys-memory.availPhys) / float64(memory.totalPhys) * 100
+	}
+	return sample, nil
+}
+
+func (s *windowsSampler) collect() error {
+	if status, _, _ := procPdhCollectQueryData.Call(s.query); status != 0 {
+		return fmt.Errorf("PdhCollectQueryData failed: 0x%x", status)
+	}
+	return nil
+}
+
+// sum складывает значения всех экземпляров счетчика, кроме _Total
+func (s *windowsSampler) sum(counter uintptr) (float64, error) {
+	if counter == 0 {
+		return 0, fmt.Errorf("counter is not available")
+	}
+
+	var size, count uint32
+	status, _, _ := procPdhGetFormattedCounterArray.Call(counter, pdhFmtDouble|pdhFmtNoCap100,
+		uintptr(unsafe.Pointer(&size)), uintptr(unsafe.Pointer(&count)), 0)
+	if uint32(status) != pdhMoreData {
+		return 0, fmt.Errorf("PdhGetFormattedCounterArray failed: 0x%x", status)
+	}
+
+	// Буфер выделяется элементами, чтобы сохранить их выравнивание; имена
+	// экземпляров PDH пишет в тот же буфер после элементов
+	itemSize := uint32(unsafe.Sizeof(pdhCounterItem{}))
+	buffer := make([]pdhCounterItem, (size+itemSize-1)/itemSize)
+	status, _, _ = procPdhGetFormattedCounterArray.Call(counter, pdhFmtDouble|pdhFmtNoCap100,
+		uintptr(unsafe.Pointer(&size)), uintptr(unsafe.Pointer(&count)), uintptr(unsafe.Pointer(&buffer[0])))
+	if status != 0 {
+		return 0, fmt.Errorf("PdhGetFormattedCounterArray failed: 0x%x", status)
+	}
+
+	var total float64
+	for _, item := range buffer[:count] {
+		if item.value.status > pdhNewData {
+			continue
+		}
+		if count > 1 && utf16PtrToString(item.name) == "_Total" {
+			continue
+		}
+		total += item.value.value
+	}
+	return total, nil
+}
+
+func utf16PtrToString(p *uint16) string {
+	if p == nil {
+		return ""
+	}
+	var chars []uint16
+	for ptr := unsafe.Pointer(p); *(*uint16)(ptr) != 0; ptr = unsafe.Add(ptr, 2) {
+		chars = append(chars, *(*uint16)(ptr))
+	}
+	return syscall.UTF16ToString(chars)
+}
+
+func (s *windowsSampler) Close() error {
+	if s.query == 0 {
+		return nil
+	}
+	procPdhCloseQuery.Call(s.query)
+	s.query = 0
+	return nil
+}
@@ -10,6 +10,7 @@ import (
     
     "github.com/YumeNoTenshi/platypus/internal/metrics"
     "github.com/YumeNoTenshi/platypus/internal/models"
+    "github.com/YumeNoTenshi/platypus/internal/scheduler"
     "gonum.org/v1/gonum/stat"
 )
 
@@ -34,7 +35,8 @@ type PredictorConfig struct {
 
 type Predictor struct {
     config    PredictorConfig
-    collector *metrics.Collector
+    source    metrics.MetricsSource // Точки серверов; обычно коллектор
+    servers   ServerLister          // Активные серверы; nil - все серверы с точками
     models    map[string]*TimeSeriesModel // ServerID -> Model
     mu        sync.RWMutex
 }
@@ -63,36 +65,46 @@ const (
     TrendStable     TrendType = "stable"
 )
 
-func NewPredictor(config PredictorConfig, collector *metrics.Collector) *Predictor {
+// NewPredictor создает прогнозист по точкам из source: коллектора или
+// другого источника, например metrics.NewStoreSource поверх реплики хранилища
+func NewPredictor(config PredictorConfig, source metrics.MetricsSource) *Predictor {
     return &Predictor{
         config:    config,
-        collector: collector,
+        source:    source,
         models:    make(map[string]*TimeSeriesModel),
     }
 }
 
-func (p *Predictor) Start(ctx context.Context) error {
-    // Загружаем сохраненные модели
-    if err := p.loadModels(); err != nil {
-        return err
-    }
+// ServerLister перечисляет активные серверы, например инвентарь
+type ServerLister interface {
+    ServerIDs() []string
+}
+
+// SetServers подключает список активных серверов: модели строятся только
+// для них, а не для всех серверов, точки которых еще хранятся
+func (p *Predictor) SetServers(servers ServerLister) {
+    p.mu.Lock()
+    defer p.mu.Unlock()
+    p.servers = servers
+}
 
-    ticker := time.NewTicker(p.config.UpdateInterval)
-    defer ticker.Stop()
+// LoadModels загружает сохраненные модели; вызывается до запуска задач
+func (p *Predictor) LoadModels() error {
+    return p.loadModels()
+}
 
-    for {
-        select {
-        case <-ctx.Done():
-            return ctx.Err()
-        case <-ticker.C:
+// Jobs возвращает периодические задачи предиктора
+func (p *Predictor) Jobs() []scheduler.Job {
+    return []scheduler.Job{{
+        Name:     "predictor.update",
+        Interval: p.config.UpdateInterval,
+        Run: func(ctx context.Context) error {
             if err := p.updateModels(ctx); err != nil {
-                continue
+                return err
             }
-            if err := p.saveModels(); err != nil {
-                continue
-            }
-        }
-    }
+            return p.saveModels()
+        },
+    }}
 }
 
 func (p *Predictor) PredictServerMetrics(ctx context.Context, serverID string, horizon time.Duration) ([]Prediction, error) {
@@ -105,7 +117,7 @@ func (p *Predictor) PredictServerMetrics(ctx context.Context, serverID string, h
     }
 
     // Получаем последние метрики для начальной точки прогноза
-    metrics, err := p.collector.GetMetrics(serverID)
+    metrics, err := p.history(serverID)
     if err != nil {
         return nil, err
     }
@@ -127,6 +139,15 @@ func (p *Predictor) PredictServerMetrics(ctx context.Context, serverID string, h
     return predictions, nil
 }
 
+// history возвращает отфильтрованные метрики сервера за HistoryWindow
+func (p *Predictor) history(serverID string) ([]models.MetricData, error) {
+    if p.config.HistoryWindow <= 0 {
+        return p.source.GetFilteredMetrics(serverID)
+    }
+    now := time.Now()
+    return p.source.GetFilteredMetricsRange(serverID, now.Add(-p.config.HistoryWindow), now)
+}
+
 func (p *Predictor) generatePrediction(model *TimeSeriesModel, historicalData []models.MetricData, targetTime time.Time) Prediction {
     // Применяем сезонную декомпозицию
     seasonal := p.calculateSeasonalComponent(historicalData, targetTime)
@@ -165,7 +186,7 @@ func (p *Predictor) updateModels(ctx context.Context) error {
 
     for _, serverID := range servers {
         // Получаем исторические данные
-        metrics, err := p.collector.GetMetrics(serverID)
+        metrics, err := p.history(serverID)
         if err != nil {
             continue
         }
@@ -316,7 +337,14 @@ func (p *Predictor) loadModels() error {
     return nil
 }
 
+// getActiveServers возвращает серверы, для которых строятся модели: из
+// списка активных серверов, а пока он пуст (инвентарь еще не обновлен) - все
+// серверы с точками. Вызывается под блокировкой.
 func (p *Predictor) getActiveServers(ctx context.Context) ([]string, error) {
-    // Здесь должен быть код для получения списка активных серверов
-    return []string{}, nil
+    if p.servers != nil {
+        if ids := p.servers.ServerIDs(); len(ids) > 0 {
+            return ids, nil
+        }
+    }
+    return p.source.ServerIDs(), nil
 }
@@ -0,0 +1,187 @@
+// Package powermodel оценивает потребление инстанса по загрузке CPU для
+// провайдеров, которые не отдают измеренную мощность. Модель выбирается по
+// семейству инстансов: линейная, кривая SPECpower или собственные коэффициенты.
+package powermodel
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+
+	"github.com/YumeNoTenshi/platypus/pkg/catalog"
+)
+
+// Model оценивает потребление инстанса (Вт) при загрузке CPU cpuPercent (%)
+type Model interface {
+	Name() string
+	Estimate(spec catalog.InstanceSpec, cpuPercent float64) float64
+}
+
+// Linear - потребление растет линейно от простоя до полной загрузки
+type Linear struct{}
+
+func (Linear) Name() string { return "linear" }
+
+func (Linear) Estimate(spec catalog.InstanceSpec, cpuPercent float64) float64 {
+	return spec.EstimatePower(cpuPercent)
+}
+
+// CurvePoint - доля диапазона от простоя до пика, достигаемая при загрузке Load (%)
+type CurvePoint struct {
+	Load     float64 `json:"load"`
+	Fraction float64 `json:"fraction"`
+}
+
+// SPECpowerCurve - типовая форма кривых SPECpower_ssj2008 для серверов
+// последних поколений: на малой загрузке потребление растет быстрее
+var SPECpowerCurve = []CurvePoint{
+	{0, 0}, {10, 0.27}, {20, 0.40}, {30, 0.50}, {40, 0.58}, {50, 0.65},
+	{60, 0.72}, {70, 0.79}, {80, 0.86}, {90, 0.93}, {100, 1},
+}
+
+// Curve - потребление между простоем и пиком из каталога, интерполированное
+// по точкам кривой
+type Curve struct {
+	Points []CurvePoint // Упорядочены по Load
+}
+
+func (Curve) Name() string { return "specpower" }
+
+func (c Curve) Estimate(spec catalog.InstanceSpec, cpuPercent float64) float64 {
+	fraction := interpolate(c.Points, clampPercent(cpuPercent))
+	return spec.IdleWatts + (spec.MaxWatts-spec.IdleWatts)*fraction + spec.MemoryWatts()
+}
+
+func interpolate(points []CurvePoint, load float64) float64 {
+	i := sort.Search(len(points), func(i int) bool { return points[i].Load >= load })
+	switch {
+	case i == 0:
+		return points[0].Fraction
+	case i == len(points):
+		return points[len(points)-1].Fraction
+	}
+	prev, next := points[i-1], points[i]
+	return prev.Fraction + (next.Fraction-prev.Fraction)*(load-prev.Load)/(next.Load-prev.Load)
+}
+
+// Coefficients - собственные коэффициенты на vCPU и ГиБ памяти, например по
+// замерам на своем оборудовании. Exponent задает нелинейность: потребление
+// CPU растет как загрузка в степени Exponent.
+type Coefficients struct {
+	IdleWattsPerVCPU  float64 `json:"idle_watts_per_vcpu"`
+	MaxWattsPerVCPU   float64 `json:"max_watts_per_vcpu"`
+	MemoryWattsPerGiB float64 `json:"memory_watts_per_gib"`
+	Exponent          float64 `json:"exponent,omitempty"` // 0 - линейно
+}
+
+func (Coefficients) Name() string { return "coefficients" }
+
+func (c Coefficients) Estimate(spec catalog.InstanceSpec, cpuPercent float64) float64 {
+	exponent := c.Exponent
+	if exponent <= 0 {
+		exponent = 1
+	}
+	load := math.Pow(clampPercent(cpuPercent)/100, exponent)
+	vcpus := float64(spec.VCPUs)
+	return vcpus*(c.IdleWattsPerVCPU+(c.MaxWattsPerVCPU-c.IdleWattsPerVCPU)*load) + spec.MemoryGiB*c.MemoryWattsPerGiB
+}
+
+func clampPercent(value float64) float64 {
+	return math.Max(0, math.Min(100, value))
+}
+
+// ModelConfig описывает модель в конфигурации
+type ModelConfig struct {
+	Type         string        `json:"type"`            // linear, specpower или coefficients
+	Curve        []CurvePoint  `json:"curve,omitempty"` // Для specpower; пусто - SPECpowerCurve
+	Coefficients *Coefficients `json:"coefficients,omitempty"`
+}
+
+// Config задает модель по умолчанию и модели отдельных семейств (m5, n2-standard)
+type Config struct {
+	Default  ModelConfig            `json:"default"`
+	Families map[string]ModelConfig `json:"families,omitempty"`
+}
+
+// Registry выбирает модель по семейству инстанса
+type Registry struct {
+	fallback Model
+	families map[string]Model
+}
+
+// Default возвращает реестр с линейной моделью для всех семейств
+func Default() *Registry {
+	return &Registry{fallback: Linear{}, families: map[string]Model{}}
+}
+
+func New(config Config) (*Registry, error) {
+	fallback, err := config.Default.build()
+	if err != nil {
+		return nil, fmt.Errorf("default power model: %w", err)
+	}
+
+	registry := &Registry{fallback: fallback, families: make(map[string]Model, len(config.Families))}
+	for family, modelConfig := range config.Families {
+		model, err := modelConfig.build()
+		if err != nil {
+			return nil, fmt.Errorf("power model for family %s: %w", family, err)
+		}
+		registry.families[family] = model
+	}
+	return registry, nil
+}
+
+// Load читает конфигурацию моделей из JSON-файла
+func Load(path string) (*Registry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var config Config
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("invalid power model file %s: %w", path, err)
+	}
+	return New(config)
+}
+
+func (c ModelConfig) build() (Model, error) {
+	switch c.Type {
+	case "", "linear":
+		return Linear{}, nil
+	case "specpower":
+		if len(c.Curve) == 0 {
+			return Curve{Points: SPECpowerCurve}, nil
+		}
+		points := append([]CurvePoint(nil), c.Curve...)
+		sort.Slice(points, func(i, j int) bool { return points[i].Load < points[j].Load })
+		for i, point := range points {
+			if point.Load < 0 || point.Load > 100 || point.Fraction < 0 || point.Fraction > 1 {
+				return nil, fmt.Errorf("curve point %v out of range: load is 0-100, fraction is 0-1", point)
+			}
+			if i > 0 && point.Load == points[i-1].Load {
+				return nil, fmt.Errorf("duplicate curve point at load %v", point.Load)
+			}
+		}
+		return Curve{Points: points}, nil
+	case "coefficients":
+		if c.Coefficients == nil {
+			return nil, fmt.Errorf("coefficients are required")
+		}
+		if c.Coefficients.IdleWattsPerVCPU < 0 || c.Coefficients.MaxWattsPerVCPU < c.Coefficients.IdleWattsPerVCPU || c.Coefficients.MemoryWattsPerGiB < 0 {
+			return nil, fmt.Errorf("coefficients must satisfy 0 <= idle <= max and memory >= 0")
+		}
+		return *c.Coefficients, nil
+	}
+	return nil, fmt.Errorf("unknown power model type %q", c.Type)
+}
+
+// Estimate оценивает потребление инстанса и возвращает происхождение оценки:
+// имя модели, а для модели семейства - "имя/семейство"
+func (r *Registry) Estimate(spec catalog.InstanceSpec, cpuPercent float64) (float64, string) {
+	if model, exists := r.families[spec.Family]; exists && spec.Family != "" {
+		return model.Estimate(spec, cpuPercent), model.Name() + "/" + spec.Family
+	}
+	return r.fallback.Estimate(spec, cpuPercent), r.fallback.Name()
+}
@@ -0,0 +1,141 @@
+// Package schedule разбирает расписания в формате cron из пяти полей:
+// минута, час, день месяца, месяц, день недели
+package schedule
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type field struct {
+	name     string
+	min, max int
+}
+
+var fields = []field{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day of month", 1, 31},
+	{"month", 1, 12},
+	{"day of week", 0, 6}, // 0 - воскресенье
+}
+
+// Schedule - разобранное cron-выражение
+type Schedule struct {
+	expr    string
+	sets    [5]map[int]bool
+	anyDay  bool // День месяца задан как *
+	anyWeek bool // День недели задан как *
+}
+
+// Parse разбирает выражение вида "0 22 * * 1-5". Поддерживаются *, числа,
+// диапазоны a-b, списки через запятую и шаг /n.
+func Parse(expr string) (Schedule, error) {
+	parts := strings.Fields(expr)
+	if len(parts) != len(fields) {
+		return Schedule{}, fmt.Errorf("cron expression must have %d fields, got %d", len(fields), len(parts))
+	}
+
+	s := Schedule{
+		expr:    expr,
+		anyDay:  parts[2] == "*",
+		anyWeek: parts[4] == "*",
+	}
+	for i, part := range parts {
+		set, err := parseField(part, fields[i])
+		if err != nil {
+			return Schedule{}, err
+		}
+		s.sets[i] = set
+	}
+	return s, nil
+}
+
+func parseField(part string, f field) (map[int]bool, error) {
+	set := make(map[int]bool)
+	for _, item := range strings.Split(part, ",") {
+		step := 1
+		if base, stepText, found := strings.Cut(item, "/"); found {
+			n, err := strconv.Atoi(stepText)
+			if err != nil || n <= 0 {
+				return nil, fmt.Errorf("invalid step in %s field: %q", f.name, item)
+			}
+			step = n
+			item = base
+		}
+
+		lo, hi := f.min, f.max
+		if item != "*" {
+			from, to, isRange := strings.Cut(item, "-")
+			var err error
+			if lo, err = strconv.Atoi(from); err != nil {
+				return nil, fmt.Errorf("invalid %s field: %q", f.name, item)
+			}
+			hi = lo
+			if isRange {
+				if hi, err = strconv.Atoi(to); err != nil {
+					return nil, fmt.Errorf("invalid %s field: %q", f.name, item)
+				}
+			}
+		}
+		if lo < f.min || hi > f.max || lo > hi {
+			return nil, fmt.Errorf("%s field out of range %d-%d: %q", f.name, f.min, f.max, item)
+		}
+
+		for v := lo; v <= hi; v += step {
+			set[v] = true
+		}
+	}
+	return set, nil
+}
+
+// Matches сообщает, соответствует ли минута t расписанию. Как в cron, если
+// ограничены и день месяца, и день недели, достаточно совпадения одного из них.
+func (s Schedule) Matches(t time.Time) bool {
+	if !s.sets[0][t.Minute()] || !s.sets[1][t.Hour()] || !s.sets[3][int(t.Month())] {
+		return false
+	}
+
+	dayMatch := s.sets[2][t.Day()]
+	weekMatch := s.sets[4][int(t.Weekday())]
+	switch {
+	case s.anyDay && s.anyWeek:
+		return true
+	case s.anyDay:
+		return weekMatch
+	case s.anyWeek:
+		return dayMatch
+	default:
+		return dayMatch || weekMatch
+	}
+}
+
+// Next возвращает ближайшую минуту строго после t, соответствующую расписанию,
+// или нулевое время, если такой нет в пределах года
+func (s Schedule) Next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	for limit := t.AddDate(1, 0, 0); t.Before(limit); t = t.Add(time.Minute) {
+		if s.Matches(t) {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// ActiveWithin сообщает, попадает ли t в окно длины duration, начатое
+// каким-либо срабатыванием расписания
+func (s Schedule) ActiveWithin(t time.Time, duration time.Duration) bool {
+	start := t.Truncate(time.Minute)
+	for at := start; t.Sub(at) < duration; at = at.Add(-time.Minute) {
+		if s.Matches(at) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s Schedule) String() string {
+	return s.expr
+}
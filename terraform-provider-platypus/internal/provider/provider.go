@@ -0,0 +1,173 @@
+// Package provider - провайдер Terraform для конфигурации Platypus поверх
+// pkg/client. Провайдер реализует протокол Terraform 6 напрямую
+// (terraform-plugin-go): ресурсы описываются таблицей атрибутов и
+// функциями над клиентом API, а план, применение, чтение и импорт общие.
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/YumeNoTenshi/platypus/pkg/client"
+	"github.com/hashicorp/terraform-plugin-go/tfprotov6"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+)
+
+// providerAttributes - настройки провайдера; пустые значения берутся из
+// PLATYPUS_SERVER_URL и PLATYPUS_API_KEY
+var providerAttributes = []attribute{
+	{name: "server_url", kind: kindString, optional: true, description: "Адрес сервера Platypus; по умолчанию PLATYPUS_SERVER_URL"},
+	{name: "api_key", kind: kindString, optional: true, sensitive: true, description: "Ключ API без области; по умолчанию PLATYPUS_API_KEY"},
+}
+
+type provider struct {
+	resources map[string]*resource
+	client    *client.Client
+}
+
+// New создает сервер провайдера для tf6server.Serve
+func New() tfprotov6.ProviderServer {
+	return &provider{resources: resources()}
+}
+
+func (p *provider) GetMetadata(ctx context.Context, req *tfprotov6.GetMetadataRequest) (*tfprotov6.GetMetadataResponse, error) {
+	resp := &tfprotov6.GetMetadataResponse{
+		ServerCapabilities: &tfprotov6.ServerCapabilities{GetProviderSchemaOptional: true},
+	}
+	for _, name := range p.resourceNames() {
+		resp.Resources = append(resp.Resources, tfprotov6.ResourceMetadata{TypeName: name})
+	}
+	return resp, nil
+}
+
+func (p *provider) GetProviderSchema(ctx context.Context, req *tfprotov6.GetProviderSchemaRequest) (*tfprotov6.GetProviderSchemaResponse, error) {
+	resp := &tfprotov6.GetProviderSchemaResponse{
+		ServerCapabilities:       &tfprotov6.ServerCapabilities{GetProviderSchemaOptional: true},
+		Provider:                 schemaOf("Провайдер конфигурации Platypus", providerAttributes),
+		ResourceSchemas:          make(map[string]*tfprotov6.Schema, len(p.resources)),
+		DataSourceSchemas:        map[string]*tfprotov6.Schema{},
+		Functions:                map[string]*tfprotov6.Function{},
+		EphemeralResourceSchemas: map[string]*tfprotov6.Schema{},
+	}
+	for name, r := range p.resources {
+		resp.ResourceSchemas[name] = schemaOf(r.description, r.attributes)
+	}
+	return resp, nil
+}
+
+func (p *provider) GetResourceIdentitySchemas(ctx context.Context, req *tfprotov6.GetResourceIdentitySchemasRequest) (*tfprotov6.GetResourceIdentitySchemasResponse, error) {
+	return &tfprotov6.GetResourceIdentitySchemasResponse{
+		IdentitySchemas: map[string]*tfprotov6.ResourceIdentitySchema{},
+	}, nil
+}
+
+func (p *provider) ValidateProviderConfig(ctx context.Context, req *tfprotov6.ValidateProviderConfigRequest) (*tfprotov6.ValidateProviderConfigResponse, error) {
+	return &tfprotov6.ValidateProviderConfigResponse{PreparedConfig: req.Config}, nil
+}
+
+// ConfigureProvider создает клиент API по настройкам провайдера
+func (p *provider) ConfigureProvider(ctx context.Context, req *tfprotov6.ConfigureProviderRequest) (*tfprotov6.ConfigureProviderResponse, error) {
+	config, err := decode(req.Config, providerAttributes)
+	if err != nil {
+		return &tfprotov6.ConfigureProviderResponse{Diagnostics: diagnostics("invalid provider configuration", err)}, nil
+	}
+
+	serverURL := config.string("server_url")
+	if serverURL == "" {
+		serverURL = os.Getenv("PLATYPUS_SERVER_URL")
+	}
+	apiKey := config.string("api_key")
+	if apiKey == "" {
+		apiKey = os.Getenv("PLATYPUS_API_KEY")
+	}
+
+	c, err := client.New(client.Config{ServerURL: serverURL, APIKey: apiKey})
+	if err != nil {
+		return &tfprotov6.ConfigureProviderResponse{Diagnostics: diagnostics("invalid provider configuration", err)}, nil
+	}
+	p.client = c
+	return &tfprotov6.ConfigureProviderResponse{}, nil
+}
+
+func (p *provider) StopProvider(ctx context.Context, req *tfprotov6.StopProviderRequest) (*tfprotov6.StopProviderResponse, error) {
+	return &tfprotov6.StopProviderResponse{}, nil
+}
+
+func (p *provider) resourceNames() []string {
+	names := make([]string, 0, len(p.resources))
+	for name := range p.resources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// resource возвращает тип ресурса запроса и настроенный клиент
+func (p *provider) resource(typeName string) (*resource, error) {
+	r, exists := p.resources[typeName]
+	if !exists {
+		return nil, fmt.Errorf("unknown resource type %s", typeName)
+	}
+	if p.client == nil {
+		return nil, errors.New("provider is not configured")
+	}
+	return r, nil
+}
+
+// Источники данных, функции и эфемерные ресурсы провайдер не объявляет
+
+func (p *provider) ValidateDataResourceConfig(ctx context.Context, req *tfprotov6.ValidateDataResourceConfigRequest) (*tfprotov6.ValidateDataResourceConfigResponse, error) {
+	return &tfprotov6.ValidateDataResourceConfigResponse{Diagnostics: unsupported("data source " + req.TypeName)}, nil
+}
+
+func (p *provider) ReadDataSource(ctx context.Context, req *tfprotov6.ReadDataSourceRequest) (*tfprotov6.ReadDataSourceResponse, error) {
+	return &tfprotov6.ReadDataSourceResponse{Diagnostics: unsupported("data source " + req.TypeName)}, nil
+}
+
+func (p *provider) GetFunctions(ctx context.Context, req *tfprotov6.GetFunctionsRequest) (*tfprotov6.GetFunctionsResponse, error) {
+	return &tfprotov6.GetFunctionsResponse{Functions: map[string]*tfprotov6.Function{}}, nil
+}
+
+func (p *provider) CallFunction(ctx context.Context, req *tfprotov6.CallFunctionRequest) (*tfprotov6.CallFunctionResponse, error) {
+	return &tfprotov6.CallFunctionResponse{
+		Error: &tfprotov6.FunctionError{Text: "function " + req.Name + " is not supported"},
+	}, nil
+}
+
+func (p *provider) ValidateEphemeralResourceConfig(ctx context.Context, req *tfprotov6.ValidateEphemeralResourceConfigRequest) (*tfprotov6.ValidateEphemeralResourceConfigResponse, error) {
+	return &tfprotov6.ValidateEphemeralResourceConfigResponse{Diagnostics: unsupported("ephemeral resource " + req.TypeName)}, nil
+}
+
+func (p *provider) OpenEphemeralResource(ctx context.Context, req *tfprotov6.OpenEphemeralResourceRequest) (*tfprotov6.OpenEphemeralResourceResponse, error) {
+	return &tfprotov6.OpenEphemeralResourceResponse{Diagnostics: unsupported("ephemeral resource " + req.TypeName)}, nil
+}
+
+func (p *provider) RenewEphemeralResource(ctx context.Context, req *tfprotov6.RenewEphemeralResourceRequest) (*tfprotov6.RenewEphemeralResourceResponse, error) {
+	return &tfprotov6.RenewEphemeralResourceResponse{Diagnostics: unsupported("ephemeral resource " + req.TypeName)}, nil
+}
+
+func (p *provider) CloseEphemeralResource(ctx context.Context, req *tfprotov6.CloseEphemeralResourceRequest) (*tfprotov6.CloseEphemeralResourceResponse, error) {
+	return &tfprotov6.CloseEphemeralResourceResponse{Diagnostics: unsupported("ephemeral resource " + req.TypeName)}, nil
+}
+
+func diagnostics(summary string, err error) []*tfprotov6.Diagnostic {
+	return []*tfprotov6.Diagnostic{{
+		Severity: tfprotov6.DiagnosticSeverityError,
+		Summary:  summary,
+		Detail:   err.Error(),
+	}}
+}
+
+func unsupported(what string) []*tfprotov6.Diagnostic {
+	return diagnostics("unsupported", fmt.Errorf("%s is not supported by the platypus provider", what))
+}
+
+// nullState - пустое состояние: ресурс удален или его нет на сервере
+func nullState(attributes []attribute) *tfprotov6.DynamicValue {
+	typ := objectType(attributes)
+	state, _ := tfprotov6.NewDynamicValue(typ, tftypes.NewValue(typ, nil))
+	return &state
+}
@@ -0,0 +1,459 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+
+	"github.com/YumeNoTenshi/platypus/pkg/client"
+	"github.com/hashicorp/terraform-plugin-go/tfprotov6"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+)
+
+// kind - тип значения атрибута
+type kind int
+
+const (
+	kindString kind = iota
+	kindNumber
+	kindStringList
+	kindNumberList
+)
+
+func (k kind) tfType() tftypes.Type {
+	switch k {
+	case kindNumber:
+		return tftypes.Number
+	case kindStringList:
+		return tftypes.List{ElementType: tftypes.String}
+	case kindNumberList:
+		return tftypes.List{ElementType: tftypes.Number}
+	}
+	return tftypes.String
+}
+
+// attribute - атрибут ресурса или провайдера
+type attribute struct {
+	name        string
+	kind        kind
+	description string
+	required    bool
+	optional    bool
+	computed    bool // Значение назначает сервер, если оно не задано
+	sensitive   bool
+	forceNew    bool // Изменение пересоздает ресурс
+}
+
+// values - значения атрибутов: string, float64, []string или []float64;
+// nil - значение не задано (null) или еще неизвестно
+type values map[string]interface{}
+
+func (v values) string(name string) string {
+	s, _ := v[name].(string)
+	return s
+}
+
+func (v values) number(name string) float64 {
+	f, _ := v[name].(float64)
+	return f
+}
+
+func (v values) strings(name string) []string {
+	s, _ := v[name].([]string)
+	return s
+}
+
+func (v values) numbers(name string) []float64 {
+	f, _ := v[name].([]float64)
+	return f
+}
+
+// resource - тип ресурса Terraform поверх клиента API. update равен nil,
+// если все задаваемые атрибуты пересоздают ресурс. Функции возвращают
+// значения всех атрибутов; client.ErrNotFound из read удаляет ресурс из
+// состояния.
+type resource struct {
+	description string
+	attributes  []attribute
+	create      func(ctx context.Context, c *client.Client, planned values) (values, error)
+	read        func(ctx context.Context, c *client.Client, state values) (values, error)
+	update      func(ctx context.Context, c *client.Client, prior, planned values) (values, error)
+	delete      func(ctx context.Context, c *client.Client, state values) error
+	// importState - состояние, по которому read находит ресурс при импорте
+	importState func(id string) values
+}
+
+func (p *provider) ValidateResourceConfig(ctx context.Context, req *tfprotov6.ValidateResourceConfigRequest) (*tfprotov6.ValidateResourceConfigResponse, error) {
+	if _, exists := p.resources[req.TypeName]; !exists {
+		return &tfprotov6.ValidateResourceConfigResponse{Diagnostics: unsupported("resource " + req.TypeName)}, nil
+	}
+	return &tfprotov6.ValidateResourceConfigResponse{}, nil
+}
+
+// UpgradeResourceState переносит состояние как есть: у схем одна версия
+func (p *provider) UpgradeResourceState(ctx context.Context, req *tfprotov6.UpgradeResourceStateRequest) (*tfprotov6.UpgradeResourceStateResponse, error) {
+	r, exists := p.resources[req.TypeName]
+	if !exists {
+		return &tfprotov6.UpgradeResourceStateResponse{Diagnostics: unsupported("resource " + req.TypeName)}, nil
+	}
+
+	typ := objectType(r.attributes)
+	state, err := req.RawState.UnmarshalWithOpts(typ, tfprotov6.UnmarshalOpts{
+		ValueFromJSONOpts: tftypes.ValueFromJSONOpts{IgnoreUndefinedAttributes: true},
+	})
+	if err != nil {
+		return &tfprotov6.UpgradeResourceStateResponse{Diagnostics: diagnostics("invalid resource state", err)}, nil
+	}
+	upgraded, err := tfprotov6.NewDynamicValue(typ, state)
+	if err != nil {
+		return &tfprotov6.UpgradeResourceStateResponse{Diagnostics: diagnostics("invalid resource state", err)}, nil
+	}
+	return &tfprotov6.UpgradeResourceStateResponse{UpgradedState: &upgraded}, nil
+}
+
+// ReadResource обновляет состояние по серверу; ресурс, которого нет на
+// сервере, удаляется из состояния
+func (p *provider) ReadResource(ctx context.Context, req *tfprotov6.ReadResourceRequest) (*tfprotov6.ReadResourceResponse, error) {
+	r, err := p.resource(req.TypeName)
+	if err != nil {
+		return &tfprotov6.ReadResourceResponse{Diagnostics: diagnostics("cannot read resource", err)}, nil
+	}
+	state, err := decode(req.CurrentState, r.attributes)
+	if err != nil {
+		return &tfprotov6.ReadResourceResponse{Diagnostics: diagnostics("invalid resource state", err)}, nil
+	}
+
+	current, err := r.read(ctx, p.client, state)
+	if errors.Is(err, client.ErrNotFound) {
+		return &tfprotov6.ReadResourceResponse{NewState: nullState(r.attributes)}, nil
+	}
+	if err != nil {
+		return &tfprotov6.ReadResourceResponse{Diagnostics: diagnostics("cannot read "+req.TypeName, err)}, nil
+	}
+
+	newState, err := encode(normalize(current, state, r.attributes), r.attributes)
+	if err != nil {
+		return &tfprotov6.ReadResourceResponse{Diagnostics: diagnostics("cannot read "+req.TypeName, err)}, nil
+	}
+	return &tfprotov6.ReadResourceResponse{NewState: newState}, nil
+}
+
+// PlanResourceChange дополняет предложенное состояние: значения, которые
+// назначит сервер, неизвестны при создании и пересоздании и сохраняются из
+// состояния при изменении; изменение атрибутов forceNew пересоздает ресурс
+func (p *provider) PlanResourceChange(ctx context.Context, req *tfprotov6.PlanResourceChangeRequest) (*tfprotov6.PlanResourceChangeResponse, error) {
+	r, exists := p.resources[req.TypeName]
+	if !exists {
+		return &tfprotov6.PlanResourceChangeResponse{Diagnostics: unsupported("resource " + req.TypeName)}, nil
+	}
+
+	typ := objectType(r.attributes)
+	proposed, err := req.ProposedNewState.Unmarshal(typ)
+	if err != nil {
+		return &tfprotov6.PlanResourceChangeResponse{Diagnostics: diagnostics("invalid plan", err)}, nil
+	}
+	if proposed.IsNull() {
+		// Удаление
+		return &tfprotov6.PlanResourceChangeResponse{PlannedState: req.ProposedNewState}, nil
+	}
+	prior, err := req.PriorState.Unmarshal(typ)
+	if err != nil {
+		return &tfprotov6.PlanResourceChangeResponse{Diagnostics: diagnostics("invalid resource state", err)}, nil
+	}
+	config, err := req.Config.Unmarshal(typ)
+	if err != nil {
+		return &tfprotov6.PlanResourceChangeResponse{Diagnostics: diagnostics("invalid resource configuration", err)}, nil
+	}
+
+	var planned, priorAttrs, configAttrs map[string]tftypes.Value
+	if err := proposed.As(&planned); err != nil {
+		return &tfprotov6.PlanResourceChangeResponse{Diagnostics: diagnostics("invalid plan", err)}, nil
+	}
+	if err := config.As(&configAttrs); err != nil {
+		return &tfprotov6.PlanResourceChangeResponse{Diagnostics: diagnostics("invalid resource configuration", err)}, nil
+	}
+	create := prior.IsNull()
+	if !create {
+		if err := prior.As(&priorAttrs); err != nil {
+			return &tfprotov6.PlanResourceChangeResponse{Diagnostics: diagnostics("invalid resource state", err)}, nil
+		}
+	}
+
+	resp := &tfprotov6.PlanResourceChangeResponse{}
+	for _, a := range r.attributes {
+		if !create && a.forceNew && !planned[a.name].Equal(priorAttrs[a.name]) {
+			resp.RequiresReplace = append(resp.RequiresReplace, tftypes.NewAttributePath().WithAttributeName(a.name))
+		}
+	}
+	replace := len(resp.RequiresReplace) > 0
+	for _, a := range r.attributes {
+		if !a.computed || !configAttrs[a.name].IsNull() {
+			continue
+		}
+		if create || replace {
+			planned[a.name] = tftypes.NewValue(a.kind.tfType(), tftypes.UnknownValue)
+		} else {
+			planned[a.name] = priorAttrs[a.name]
+		}
+	}
+
+	plannedState, err := tfprotov6.NewDynamicValue(typ, tftypes.NewValue(typ, planned))
+	if err != nil {
+		return &tfprotov6.PlanResourceChangeResponse{Diagnostics: diagnostics("invalid plan", err)}, nil
+	}
+	resp.PlannedState = &plannedState
+	return resp, nil
+}
+
+// ApplyResourceChange создает, изменяет или удаляет ресурс на сервере
+func (p *provider) ApplyResourceChange(ctx context.Context, req *tfprotov6.ApplyResourceChangeRequest) (*tfprotov6.ApplyResourceChangeResponse, error) {
+	r, err := p.resource(req.TypeName)
+	if err != nil {
+		return &tfprotov6.ApplyResourceChangeResponse{Diagnostics: diagnostics("cannot apply changes", err)}, nil
+	}
+
+	typ := objectType(r.attributes)
+	plannedValue, err := req.PlannedState.Unmarshal(typ)
+	if err != nil {
+		return &tfprotov6.ApplyResourceChangeResponse{Diagnostics: diagnostics("invalid plan", err)}, nil
+	}
+	prior, err := decode(req.PriorState, r.attributes)
+	if err != nil {
+		return &tfprotov6.ApplyResourceChangeResponse{Diagnostics: diagnostics("invalid resource state", err)}, nil
+	}
+
+	if plannedValue.IsNull() {
+		if err := r.delete(ctx, p.client, prior); err != nil && !errors.Is(err, client.ErrNotFound) {
+			return &tfprotov6.ApplyResourceChangeResponse{Diagnostics: diagnostics("cannot delete "+req.TypeName, err)}, nil
+		}
+		return &tfprotov6.ApplyResourceChangeResponse{NewState: nullState(r.attributes)}, nil
+	}
+
+	planned, err := toValues(plannedValue, r.attributes)
+	if err != nil {
+		return &tfprotov6.ApplyResourceChangeResponse{Diagnostics: diagnostics("invalid plan", err)}, nil
+	}
+	var result values
+	switch {
+	case prior == nil:
+		result, err = r.create(ctx, p.client, planned)
+	case r.update == nil:
+		err = errors.New("resource cannot be updated in place")
+	default:
+		result, err = r.update(ctx, p.client, prior, planned)
+	}
+	if err != nil {
+		return &tfprotov6.ApplyResourceChangeResponse{Diagnostics: diagnostics("cannot apply "+req.TypeName, err)}, nil
+	}
+
+	newState, err := encode(normalize(result, planned, r.attributes), r.attributes)
+	if err != nil {
+		return &tfprotov6.ApplyResourceChangeResponse{Diagnostics: diagnostics("cannot apply "+req.TypeName, err)}, nil
+	}
+	return &tfprotov6.ApplyResourceChangeResponse{NewState: newState}, nil
+}
+
+// ImportResourceState ставит ресурс в состояние по идентификатору; значения
+// атрибутов Terraform затем читает через ReadResource
+func (p *provider) ImportResourceState(ctx context.Context, req *tfprotov6.ImportResourceStateRequest) (*tfprotov6.ImportResourceStateResponse, error) {
+	r, exists := p.resources[req.TypeName]
+	if !exists {
+		return &tfprotov6.ImportResourceStateResponse{Diagnostics: unsupported("resource " + req.TypeName)}, nil
+	}
+
+	state, err := encode(r.importState(req.ID), r.attributes)
+	if err != nil {
+		return &tfprotov6.ImportResourceStateResponse{Diagnostics: diagnostics("cannot import "+req.TypeName, err)}, nil
+	}
+	return &tfprotov6.ImportResourceStateResponse{
+		ImportedResources: []*tfprotov6.ImportedResource{{TypeName: req.TypeName, State: state}},
+	}, nil
+}
+
+func (p *provider) MoveResourceState(ctx context.Context, req *tfprotov6.MoveResourceStateRequest) (*tfprotov6.MoveResourceStateResponse, error) {
+	return &tfprotov6.MoveResourceStateResponse{Diagnostics: unsupported("moving resource state")}, nil
+}
+
+func (p *provider) UpgradeResourceIdentity(ctx context.Context, req *tfprotov6.UpgradeResourceIdentityRequest) (*tfprotov6.UpgradeResourceIdentityResponse, error) {
+	return &tfprotov6.UpgradeResourceIdentityResponse{Diagnostics: unsupported("resource identity")}, nil
+}
+
+// normalize возвращает пустые строки и списки сервера как null, если в
+// reference (плане или прежнем состоянии) значение не задано: иначе
+// Terraform видит расхождение с конфигурацией без атрибута
+func normalize(result, reference values, attributes []attribute) values {
+	for _, a := range attributes {
+		if reference[a.name] != nil || a.computed {
+			continue
+		}
+		switch v := result[a.name].(type) {
+		case string:
+			if v == "" {
+				result[a.name] = nil
+			}
+		case []string:
+			if len(v) == 0 {
+				result[a.name] = nil
+			}
+		case []float64:
+			if len(v) == 0 {
+				result[a.name] = nil
+			}
+		}
+	}
+	return result
+}
+
+func objectType(attributes []attribute) tftypes.Object {
+	types := make(map[string]tftypes.Type, len(attributes))
+	for _, a := range attributes {
+		types[a.name] = a.kind.tfType()
+	}
+	return tftypes.Object{AttributeTypes: types}
+}
+
+func schemaOf(description string, attributes []attribute) *tfprotov6.Schema {
+	block := &tfprotov6.SchemaBlock{Description: description}
+	for _, a := range attributes {
+		block.Attributes = append(block.Attributes, &tfprotov6.SchemaAttribute{
+			Name:        a.name,
+			Type:        a.kind.tfType(),
+			Description: a.description,
+			Required:    a.required,
+			Optional:    a.optional,
+			Computed:    a.computed,
+			Sensitive:   a.sensitive,
+		})
+	}
+	return &tfprotov6.Schema{Block: block}
+}
+
+// decode разбирает состояние или конфигурацию; nil - объект не задан
+func decode(value *tfprotov6.DynamicValue, attributes []attribute) (values, error) {
+	if value == nil {
+		return nil, nil
+	}
+	object, err := value.Unmarshal(objectType(attributes))
+	if err != nil {
+		return nil, err
+	}
+	if object.IsNull() {
+		return nil, nil
+	}
+	return toValues(object, attributes)
+}
+
+// toValues переводит объект Terraform в значения атрибутов; неизвестные
+// значения становятся nil
+func toValues(object tftypes.Value, attributes []attribute) (values, error) {
+	var attrs map[string]tftypes.Value
+	if err := object.As(&attrs); err != nil {
+		return nil, err
+	}
+
+	result := make(values, len(attributes))
+	for _, a := range attributes {
+		value := attrs[a.name]
+		if value.IsNull() || !value.IsKnown() {
+			result[a.name] = nil
+			continue
+		}
+		converted, err := fromTerraform(a.kind, value)
+		if err != nil {
+			return nil, fmt.Errorf("attribute %s: %w", a.name, err)
+		}
+		result[a.name] = converted
+	}
+	return result, nil
+}
+
+func fromTerraform(k kind, value tftypes.Value) (interface{}, error) {
+	switch k {
+	case kindString:
+		var s string
+		return s, value.As(&s)
+	case kindNumber:
+		var f big.Float
+		if err := value.As(&f); err != nil {
+			return nil, err
+		}
+		number, _ := f.Float64()
+		return number, nil
+	}
+
+	var elements []tftypes.Value
+	if err := value.As(&elements); err != nil {
+		return nil, err
+	}
+	if k == kindStringList {
+		list := make([]string, 0, len(elements))
+		for _, element := range elements {
+			var s string
+			if err := element.As(&s); err != nil {
+				return nil, err
+			}
+			list = append(list, s)
+		}
+		return list, nil
+	}
+	list := make([]float64, 0, len(elements))
+	for _, element := range elements {
+		var f big.Float
+		if err := element.As(&f); err != nil {
+			return nil, err
+		}
+		number, _ := f.Float64()
+		list = append(list, number)
+	}
+	return list, nil
+}
+
+// encode переводит значения атрибутов в состояние Terraform
+func encode(v values, attributes []attribute) (*tfprotov6.DynamicValue, error) {
+	typ := objectType(attributes)
+	attrs := make(map[string]tftypes.Value, len(attributes))
+	for _, a := range attributes {
+		attrs[a.name] = toTerraform(a.kind, v[a.name])
+	}
+	state, err := tfprotov6.NewDynamicValue(typ, tftypes.NewValue(typ, attrs))
+	if err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+func toTerraform(k kind, value interface{}) tftypes.Value {
+	typ := k.tfType()
+	switch v := value.(type) {
+	case string:
+		return tftypes.NewValue(typ, v)
+	case float64:
+		return tftypes.NewValue(typ, number(v))
+	case []string:
+		elements := make([]tftypes.Value, len(v))
+		for i, s := range v {
+			elements[i] = tftypes.NewValue(tftypes.String, s)
+		}
+		return tftypes.NewValue(typ, elements)
+	case []float64:
+		elements := make([]tftypes.Value, len(v))
+		for i, f := range v {
+			elements[i] = tftypes.NewValue(tftypes.Number, number(f))
+		}
+		return tftypes.NewValue(typ, elements)
+	}
+	return tftypes.NewValue(typ, nil)
+}
+
+// number переводит float64 в число Terraform через кратчайшую десятичную
+// запись: так 0.1 из ответа сервера равно 0.1 из конфигурации, которое
+// Terraform разбирает с точностью 512 бит
+func number(f float64) *big.Float {
+	n, _, err := big.ParseFloat(strconv.FormatFloat(f, 'g', -1, 64), 10, 512, big.ToNearestEven)
+	if err != nil {
+		return big.NewFloat(f)
+	}
+	return n
+}
@@ -0,0 +1,372 @@
+package provider
+
+import (
+	"context"
+
+	"github.com/YumeNoTenshi/platypus/pkg/client"
+)
+
+// federationPolicyID - идентификатор единственной политики федерации
+const federationPolicyID = "federation"
+
+func resources() map[string]*resource {
+	return map[string]*resource{
+		"platypus_group":             groupResource(),
+		"platypus_budget":            budgetResource(),
+		"platypus_eco_tag":           ecoTagResource(),
+		"platypus_federation_policy": federationPolicyResource(),
+		"platypus_scaling_group":     scalingGroupResource(),
+		"platypus_api_key":           apiKeyResource(),
+	}
+}
+
+func groupResource() *resource {
+	return &resource{
+		description: "Сохраненная группа серверов",
+		attributes: []attribute{
+			{name: "id", kind: kindString, computed: true},
+			{name: "name", kind: kindString, required: true},
+			{name: "description", kind: kindString, optional: true},
+			{name: "server_ids", kind: kindStringList, optional: true, description: "Статический состав группы"},
+			{name: "selector", kind: kindString, optional: true, description: "Динамический состав в каноническом виде, например environment=prod,team!=infra"},
+		},
+		create: func(ctx context.Context, c *client.Client, planned values) (values, error) {
+			group, err := c.CreateGroup(ctx, groupFromValues(planned))
+			if err != nil {
+				return nil, err
+			}
+			return groupValues(group), nil
+		},
+		read: func(ctx context.Context, c *client.Client, state values) (values, error) {
+			group, err := c.GetGroup(ctx, state.string("id"))
+			if err != nil {
+				return nil, err
+			}
+			return groupValues(group), nil
+		},
+		update: func(ctx context.Context, c *client.Client, prior, planned values) (values, error) {
+			group, err := c.UpdateGroup(ctx, prior.string("id"), groupFromValues(planned))
+			if err != nil {
+				return nil, err
+			}
+			return groupValues(group), nil
+		},
+		delete: func(ctx context.Context, c *client.Client, state values) error {
+			return c.DeleteGroup(ctx, state.string("id"))
+		},
+		// Группа импортируется по ID или имени
+		importState: func(id string) values {
+			return values{"id": id}
+		},
+	}
+}
+
+func groupFromValues(v values) client.Group {
+	return client.Group{
+		Name:        v.string("name"),
+		Description: v.string("description"),
+		ServerIDs:   v.strings("server_ids"),
+		Selector:    v.string("selector"),
+	}
+}
+
+func groupValues(group *client.Group) values {
+	return values{
+		"id":          group.ID,
+		"name":        group.Name,
+		"description": group.Description,
+		"server_ids":  group.ServerIDs,
+		"selector":    group.Selector,
+	}
+}
+
+func budgetResource() *resource {
+	return &resource{
+		description: "Месячный углеродный бюджет",
+		attributes: []attribute{
+			{name: "id", kind: kindString, computed: true},
+			{name: "name", kind: kindString, required: true},
+			{name: "scope", kind: kindString, required: true, description: "team, region, group или selector"},
+			{name: "target", kind: kindString, required: true, description: "Команда, регион, группа или селектор"},
+			{name: "monthly_limit_kg", kind: kindNumber, required: true},
+			{name: "alert_thresholds", kind: kindNumberList, optional: true, computed: true, description: "Пороги оповещений, % от лимита, по возрастанию; по умолчанию пороги сервера"},
+		},
+		create: func(ctx context.Context, c *client.Client, planned values) (values, error) {
+			budget, err := c.CreateBudget(ctx, budgetFromValues(planned))
+			if err != nil {
+				return nil, err
+			}
+			return budgetValues(budget), nil
+		},
+		read: func(ctx context.Context, c *client.Client, state values) (values, error) {
+			budget, err := c.GetBudget(ctx, state.string("id"))
+			if err != nil {
+				return nil, err
+			}
+			return budgetValues(budget), nil
+		},
+		update: func(ctx context.Context, c *client.Client, prior, planned values) (values, error) {
+			budget, err := c.UpdateBudget(ctx, prior.string("id"), budgetFromValues(planned))
+			if err != nil {
+				return nil, err
+			}
+			return budgetValues(budget), nil
+		},
+		delete: func(ctx context.Context, c *client.Client, state values) error {
+			return c.DeleteBudget(ctx, state.string("id"))
+		},
+		importState: func(id string) values {
+			return values{"id": id}
+		},
+	}
+}
+
+func budgetFromValues(v values) client.Budget {
+	return client.Budget{
+		Name:            v.string("name"),
+		Scope:           v.string("scope"),
+		Target:          v.string("target"),
+		MonthlyLimitKg:  v.number("monthly_limit_kg"),
+		AlertThresholds: v.numbers("alert_thresholds"),
+	}
+}
+
+func budgetValues(budget *client.Budget) values {
+	return values{
+		"id":               budget.ID,
+		"name":             budget.Name,
+		"scope":            budget.Scope,
+		"target":           budget.Target,
+		"monthly_limit_kg": budget.MonthlyLimitKg,
+		"alert_thresholds": budget.AlertThresholds,
+	}
+}
+
+// ecoTagResource - эко-тег; каждое изменение создает новую версию
+// определений, и сервер сразу перестраивает эко-профили
+func ecoTagResource() *resource {
+	put := func(ctx context.Context, c *client.Client, planned values) (values, error) {
+		tag := client.EcoTag{
+			Name:        planned.string("name"),
+			Description: planned.string("description"),
+			Score:       planned.number("score"),
+			Weight:      planned.number("weight"),
+			Threshold:   planned.number("threshold"),
+		}
+		definitions, err := c.PutEcoTag(ctx, tag)
+		if err != nil {
+			return nil, err
+		}
+		if saved, exists := definitions.Tags[tag.Name]; exists {
+			tag = saved
+		}
+		return ecoTagValues(&tag), nil
+	}
+
+	return &resource{
+		description: "Эко-тег",
+		attributes: []attribute{
+			{name: "id", kind: kindString, computed: true},
+			{name: "name", kind: kindString, required: true, forceNew: true},
+			{name: "description", kind: kindString, optional: true},
+			{name: "score", kind: kindNumber, required: true},
+			{name: "weight", kind: kindNumber, required: true},
+			{name: "threshold", kind: kindNumber, required: true},
+		},
+		create: put,
+		read: func(ctx context.Context, c *client.Client, state values) (values, error) {
+			tag, err := c.GetEcoTag(ctx, state.string("id"))
+			if err != nil {
+				return nil, err
+			}
+			return ecoTagValues(tag), nil
+		},
+		update: func(ctx context.Context, c *client.Client, prior, planned values) (values, error) {
+			return put(ctx, c, planned)
+		},
+		delete: func(ctx context.Context, c *client.Client, state values) error {
+			return c.DeleteEcoTag(ctx, state.string("id"))
+		},
+		importState: func(id string) values {
+			return values{"id": id}
+		},
+	}
+}
+
+func ecoTagValues(tag *client.EcoTag) values {
+	return values{
+		"id":          tag.Name,
+		"name":        tag.Name,
+		"description": tag.Description,
+		"score":       tag.Score,
+		"weight":      tag.Weight,
+		"threshold":   tag.Threshold,
+	}
+}
+
+// federationPolicyResource - политика федерации. Она есть у основного
+// инстанса всегда, поэтому создание заменяет ее, а удаление только убирает
+// ресурс из состояния.
+func federationPolicyResource() *resource {
+	set := func(ctx context.Context, c *client.Client, planned values) (values, error) {
+		policy, err := c.SetFederationPolicy(ctx, client.FederationPolicy{
+			CPUThresholdHigh:   planned.number("cpu_threshold_high"),
+			CPUThresholdLow:    planned.number("cpu_threshold_low"),
+			PowerThresholdHigh: planned.number("power_threshold_high"),
+			MinPowerSaving:     planned.number("min_power_saving"),
+		})
+		if err != nil {
+			return nil, err
+		}
+		return federationPolicyValues(policy), nil
+	}
+
+	return &resource{
+		description: "Политика федерации, которую основной инстанс раздает площадкам",
+		attributes: []attribute{
+			{name: "id", kind: kindString, computed: true},
+			{name: "cpu_threshold_high", kind: kindNumber, required: true},
+			{name: "cpu_threshold_low", kind: kindNumber, required: true},
+			{name: "power_threshold_high", kind: kindNumber, required: true},
+			{name: "min_power_saving", kind: kindNumber, required: true},
+		},
+		create: set,
+		read: func(ctx context.Context, c *client.Client, state values) (values, error) {
+			policy, err := c.FederationPolicy(ctx)
+			if err != nil {
+				return nil, err
+			}
+			return federationPolicyValues(policy), nil
+		},
+		update: func(ctx context.Context, c *client.Client, prior, planned values) (values, error) {
+			return set(ctx, c, planned)
+		},
+		delete: func(ctx context.Context, c *client.Client, state values) error {
+			return nil
+		},
+		importState: func(id string) values {
+			return values{"id": federationPolicyID}
+		},
+	}
+}
+
+func federationPolicyValues(policy *client.FederationPolicy) values {
+	return values{
+		"id":                   federationPolicyID,
+		"cpu_threshold_high":   policy.CPUThresholdHigh,
+		"cpu_threshold_low":    policy.CPUThresholdLow,
+		"power_threshold_high": policy.PowerThresholdHigh,
+		"min_power_saving":     policy.MinPowerSaving,
+	}
+}
+
+// scalingGroupResource ставит сохраненную группу под управление
+// автоскейлера. Когда снята последняя группа, автоскейлер снова управляет
+// всеми серверами.
+func scalingGroupResource() *resource {
+	return &resource{
+		description: "Группа серверов под управлением автоскейлера",
+		attributes: []attribute{
+			{name: "id", kind: kindString, computed: true},
+			{name: "group_id", kind: kindString, required: true, forceNew: true, description: "ID или имя сохраненной группы"},
+			{name: "name", kind: kindString, computed: true},
+			{name: "servers", kind: kindStringList, computed: true, description: "Текущий состав группы"},
+		},
+		create: func(ctx context.Context, c *client.Client, planned values) (values, error) {
+			group, err := c.PutScalingGroup(ctx, planned.string("group_id"))
+			if err != nil {
+				return nil, err
+			}
+			return scalingGroupValues(planned.string("group_id"), group), nil
+		},
+		read: func(ctx context.Context, c *client.Client, state values) (values, error) {
+			group, err := c.GetScalingGroup(ctx, state.string("group_id"))
+			if err != nil {
+				return nil, err
+			}
+			return scalingGroupValues(state.string("group_id"), group), nil
+		},
+		delete: func(ctx context.Context, c *client.Client, state values) error {
+			return c.DeleteScalingGroup(ctx, state.string("group_id"))
+		},
+		importState: func(id string) values {
+			return values{"group_id": id}
+		},
+	}
+}
+
+// scalingGroupValues сохраняет group_id как в конфигурации: группу можно
+// задать и именем
+func scalingGroupValues(ref string, group *client.ScalingGroup) values {
+	servers := group.Servers
+	if servers == nil {
+		servers = []string{}
+	}
+	return values{
+		"id":       group.GroupID,
+		"group_id": ref,
+		"name":     group.Name,
+		"servers":  servers,
+	}
+}
+
+// apiKeyResource выпускает ключ клиента. Ключ сервер возвращает только при
+// создании: у импортированного ресурса атрибута key нет.
+func apiKeyResource() *resource {
+	return &resource{
+		description: "Ключ API клиента",
+		attributes: []attribute{
+			{name: "id", kind: kindString, required: true, forceNew: true, description: "Идентификатор клиента"},
+			{name: "tenant", kind: kindString, optional: true, forceNew: true},
+			{name: "access", kind: kindString, optional: true, forceNew: true, description: "read или write; по умолчанию чтение и запись"},
+			{name: "servers", kind: kindStringList, optional: true, forceNew: true, description: "Идентификаторы или шаблоны серверов, например team-a-*"},
+			{name: "selector", kind: kindString, optional: true, forceNew: true, description: "Серверы по каноническим меткам, например team=payments,environment!=dev"},
+			{name: "key", kind: kindString, computed: true, sensitive: true},
+		},
+		create: func(ctx context.Context, c *client.Client, planned values) (values, error) {
+			key, err := c.CreateAPIKey(ctx, planned.string("id"), apiKeyScope(planned))
+			if err != nil {
+				return nil, err
+			}
+			return apiKeyValues(key, key.Key), nil
+		},
+		read: func(ctx context.Context, c *client.Client, state values) (values, error) {
+			key, err := c.GetAPIKey(ctx, state.string("id"))
+			if err != nil {
+				return nil, err
+			}
+			return apiKeyValues(key, state["key"]), nil
+		},
+		delete: func(ctx context.Context, c *client.Client, state values) error {
+			return c.DeleteAPIKey(ctx, state.string("id"))
+		},
+		importState: func(id string) values {
+			return values{"id": id}
+		},
+	}
+}
+
+// apiKeyScope - область ключа из атрибутов; nil - ключ на весь парк
+func apiKeyScope(v values) *client.APIKeyScope {
+	scope := &client.APIKeyScope{
+		Tenant:   v.string("tenant"),
+		Access:   v.string("access"),
+		Servers:  v.strings("servers"),
+		Selector: v.string("selector"),
+	}
+	if scope.Tenant == "" && scope.Access == "" && len(scope.Servers) == 0 && scope.Selector == "" {
+		return nil
+	}
+	return scope
+}
+
+func apiKeyValues(key *client.APIKey, secret interface{}) values {
+	v := values{"id": key.ID, "key": secret}
+	if key.Scope != nil {
+		v["tenant"] = key.Scope.Tenant
+		v["access"] = key.Scope.Access
+		v["servers"] = key.Scope.Servers
+		v["selector"] = key.Scope.Selector
+	}
+	return v
+}
@@ -0,0 +1,17 @@
+// Провайдер Terraform для конфигурации Platypus: группы серверов, бюджеты,
+// эко-теги, политика федерации, группы автомасштабирования и ключи API.
+// Отдельный модуль, чтобы зависимости Terraform не попадали в сервер.
+package main
+
+import (
+	"log"
+
+	"github.com/YumeNoTenshi/platypus/terraform-provider-platypus/internal/provider"
+	"github.com/hashicorp/terraform-plugin-go/tfprotov6/tf6server"
+)
+
+func main() {
+	if err := tf6server.Serve("registry.terraform.io/yumenotenshi/platypus", provider.New); err != nil {
+		log.Fatal(err)
+	}
+}